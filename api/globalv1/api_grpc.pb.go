@@ -24,6 +24,9 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type SetsClient interface {
 	Watch(ctx context.Context, opts ...grpc.CallOption) (Sets_WatchClient, error)
+	// GetState returns the current global state, for dashboards and scripts (ie: through the JSON
+	// gateway).
+	GetState(ctx context.Context, in *GlobalStateReq, opts ...grpc.CallOption) (*GlobalState, error)
 }
 
 type setsClient struct {
@@ -65,11 +68,23 @@ func (x *setsWatchClient) Recv() (*localv1.OpItem, error) {
 	return m, nil
 }
 
+func (c *setsClient) GetState(ctx context.Context, in *GlobalStateReq, opts ...grpc.CallOption) (*GlobalState, error) {
+	out := new(GlobalState)
+	err := c.cc.Invoke(ctx, "/globalv1.Sets/GetState", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SetsServer is the server API for Sets service.
 // All implementations must embed UnimplementedSetsServer
 // for forward compatibility
 type SetsServer interface {
 	Watch(Sets_WatchServer) error
+	// GetState returns the current global state, for dashboards and scripts (ie: through the JSON
+	// gateway).
+	GetState(context.Context, *GlobalStateReq) (*GlobalState, error)
 	mustEmbedUnimplementedSetsServer()
 }
 
@@ -80,6 +95,9 @@ type UnimplementedSetsServer struct {
 func (UnimplementedSetsServer) Watch(Sets_WatchServer) error {
 	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
 }
+func (UnimplementedSetsServer) GetState(context.Context, *GlobalStateReq) (*GlobalState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetState not implemented")
+}
 func (UnimplementedSetsServer) mustEmbedUnimplementedSetsServer() {}
 
 // UnsafeSetsServer may be embedded to opt out of forward compatibility for this service.
@@ -119,13 +137,36 @@ func (x *setsWatchServer) Recv() (*GlobalWatchReq, error) {
 	return m, nil
 }
 
+func _Sets_GetState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GlobalStateReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SetsServer).GetState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/globalv1.Sets/GetState",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SetsServer).GetState(ctx, req.(*GlobalStateReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Sets_ServiceDesc is the grpc.ServiceDesc for Sets service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var Sets_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "globalv1.Sets",
 	HandlerType: (*SetsServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetState",
+			Handler:    _Sets_GetState_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "Watch",
@@ -40,6 +40,9 @@ const (
 	Set_UnknownSet   Set = 0
 	Set_ServicesSet  Set = 1
 	Set_EndpointsSet Set = 2
+	// ExternalNamesSet holds the ExternalName services; they don't need rules, so they're not in
+	// ServicesSet but still sent for the sinks acting on them (ie: DNS).
+	Set_ExternalNamesSet Set = 3
 	// FIXME move to a 3rd generic proto ???
 	Set_GlobalServiceInfos  Set = 10
 	Set_GlobalEndpointInfos Set = 11
@@ -52,6 +55,7 @@ var (
 		0:  "UnknownSet",
 		1:  "ServicesSet",
 		2:  "EndpointsSet",
+		3:  "ExternalNamesSet",
 		10: "GlobalServiceInfos",
 		11: "GlobalEndpointInfos",
 		12: "GlobalNodeInfos",
@@ -60,6 +64,7 @@ var (
 		"UnknownSet":          0,
 		"ServicesSet":         1,
 		"EndpointsSet":        2,
+		"ExternalNamesSet":    3,
 		"GlobalServiceInfos":  10,
 		"GlobalEndpointInfos": 11,
 		"GlobalNodeInfos":     12,
@@ -145,7 +150,110 @@ func (Protocol) EnumDescriptor() ([]byte, []int) {
 	return file_api_localv1_api_proto_rawDescGZIP(), []int{1}
 }
 
-// any time were watching for the local model, we need to send the
+// Priority is the programming priority class of a service.
+type Priority int32
+
+const (
+	Priority_NormalPriority Priority = 0
+	Priority_LowPriority    Priority = 1
+	Priority_HighPriority   Priority = 2
+	// the system services the cluster depends on (ie: kube-dns).
+	Priority_CriticalPriority Priority = 3
+)
+
+// Enum value maps for Priority.
+var (
+	Priority_name = map[int32]string{
+		0: "NormalPriority",
+		1: "LowPriority",
+		2: "HighPriority",
+		3: "CriticalPriority",
+	}
+	Priority_value = map[string]int32{
+		"NormalPriority":   0,
+		"LowPriority":      1,
+		"HighPriority":     2,
+		"CriticalPriority": 3,
+	}
+)
+
+func (x Priority) Enum() *Priority {
+	p := new(Priority)
+	*p = x
+	return p
+}
+
+func (x Priority) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Priority) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_localv1_api_proto_enumTypes[2].Descriptor()
+}
+
+func (Priority) Type() protoreflect.EnumType {
+	return &file_api_localv1_api_proto_enumTypes[2]
+}
+
+func (x Priority) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Priority.Descriptor instead.
+func (Priority) EnumDescriptor() ([]byte, []int) {
+	return file_api_localv1_api_proto_rawDescGZIP(), []int{2}
+}
+
+type IPFamily int32
+
+const (
+	IPFamily_UnknownIPFamily IPFamily = 0
+	IPFamily_IPv4            IPFamily = 1
+	IPFamily_IPv6            IPFamily = 2
+)
+
+// Enum value maps for IPFamily.
+var (
+	IPFamily_name = map[int32]string{
+		0: "UnknownIPFamily",
+		1: "IPv4",
+		2: "IPv6",
+	}
+	IPFamily_value = map[string]int32{
+		"UnknownIPFamily": 0,
+		"IPv4":            1,
+		"IPv6":            2,
+	}
+)
+
+func (x IPFamily) Enum() *IPFamily {
+	p := new(IPFamily)
+	*p = x
+	return p
+}
+
+func (x IPFamily) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (IPFamily) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_localv1_api_proto_enumTypes[3].Descriptor()
+}
+
+func (IPFamily) Type() protoreflect.EnumType {
+	return &file_api_localv1_api_proto_enumTypes[3]
+}
+
+func (x IPFamily) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use IPFamily.Descriptor instead.
+func (IPFamily) EnumDescriptor() ([]byte, []int) {
+	return file_api_localv1_api_proto_rawDescGZIP(), []int{3}
+}
+
+// To request ENLS(Expected Node Local State) a client must specify the desired NodeName
 // "location" from where we are watching.
 type WatchReq struct {
 	state         protoimpl.MessageState
@@ -154,6 +262,13 @@ type WatchReq struct {
 
 	// NodeName of the requester
 	NodeName string `protobuf:"bytes,1,opt,name=NodeName,proto3" json:"NodeName,omitempty"`
+	// IPFamilies handled by the requester: the IPs of the other families are not sent, nor the
+	// services and endpoints left without IPs. All the IPs are sent if empty.
+	IPFamilies []IPFamily `protobuf:"varint,2,rep,packed,name=IPFamilies,proto3,enum=localv1.IPFamily" json:"IPFamilies,omitempty"`
+	// WithTerminatingEndpoints requests the endpoints that are not ready but still serving while
+	// terminating, with the Conditions of all the endpoints set. Only the ready endpoints are sent
+	// otherwise.
+	WithTerminatingEndpoints bool `protobuf:"varint,3,opt,name=WithTerminatingEndpoints,proto3" json:"WithTerminatingEndpoints,omitempty"`
 }
 
 func (x *WatchReq) Reset() {
@@ -195,15 +310,29 @@ func (x *WatchReq) GetNodeName() string {
 	return ""
 }
 
+func (x *WatchReq) GetIPFamilies() []IPFamily {
+	if x != nil {
+		return x.IPFamilies
+	}
+	return nil
+}
+
+func (x *WatchReq) GetWithTerminatingEndpoints() bool {
+	if x != nil {
+		return x.WithTerminatingEndpoints
+	}
+	return false
+}
+
 type OpItem struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
 	// Types that are assignable to Op:
-	//
 	//	*OpItem_Sync
 	//	*OpItem_Reset_
+	//	*OpItem_InitialSync
 	//	*OpItem_Set
 	//	*OpItem_Delete
 	Op isOpItem_Op `protobuf_oneof:"Op"`
@@ -262,6 +391,13 @@ func (x *OpItem) GetReset_() *EmptyOp {
 	return nil
 }
 
+func (x *OpItem) GetInitialSync() *EmptyOp {
+	if x, ok := x.GetOp().(*OpItem_InitialSync); ok {
+		return x.InitialSync
+	}
+	return nil
+}
+
 func (x *OpItem) GetSet() *Value {
 	if x, ok := x.GetOp().(*OpItem_Set); ok {
 		return x.Set
@@ -290,6 +426,12 @@ type OpItem_Reset_ struct {
 	Reset_ *EmptyOp `protobuf:"bytes,4,opt,name=Reset,proto3,oneof"`
 }
 
+type OpItem_InitialSync struct {
+	// InitialSync is sent once per stream, right before the Sync of the first complete state
+	// (the previous syncs may have carried a partial state)
+	InitialSync *EmptyOp `protobuf:"bytes,5,opt,name=InitialSync,proto3,oneof"`
+}
+
 type OpItem_Set struct {
 	// Add/update a value in a set
 	Set *Value `protobuf:"bytes,2,opt,name=Set,proto3,oneof"`
@@ -304,6 +446,8 @@ func (*OpItem_Sync) isOpItem_Op() {}
 
 func (*OpItem_Reset_) isOpItem_Op() {}
 
+func (*OpItem_InitialSync) isOpItem_Op() {}
+
 func (*OpItem_Set) isOpItem_Op() {}
 
 func (*OpItem_Delete) isOpItem_Op() {}
@@ -474,10 +618,24 @@ type Service struct {
 	Ports                  []*PortMapping `protobuf:"bytes,6,rep,name=Ports,proto3" json:"Ports,omitempty"`
 	ExternalTrafficToLocal bool           `protobuf:"varint,7,opt,name=ExternalTrafficToLocal,proto3" json:"ExternalTrafficToLocal,omitempty"`
 	// Types that are assignable to SessionAffinity:
-	//
 	//	*Service_ClientIP
 	SessionAffinity        isService_SessionAffinity `protobuf_oneof:"SessionAffinity"`
 	InternalTrafficToLocal bool                      `protobuf:"varint,12,opt,name=InternalTrafficToLocal,proto3" json:"InternalTrafficToLocal,omitempty"`
+	// true if the LoadBalancer service didn't allocate node ports
+	// (allocateLoadBalancerNodePorts=false): only the ports with a NodePort
+	// set explicitly are exposed on the nodes.
+	NoLoadBalancerNodePorts bool `protobuf:"varint,13,opt,name=NoLoadBalancerNodePorts,proto3" json:"NoLoadBalancerNodePorts,omitempty"`
+	// the external reference of an ExternalName service (a DNS name).
+	ExternalName string `protobuf:"bytes,14,opt,name=ExternalName,proto3" json:"ExternalName,omitempty"`
+	// true if the backends should bypass conntrack (NOTRACK) for the UDP traffic of the
+	// service's endpoints (high packet rate services, like DNS or game servers).
+	NoTrack bool `protobuf:"varint,15,opt,name=NoTrack,proto3" json:"NoTrack,omitempty"`
+	// the DSCP value (0 to 63) the backends set on the packets sent to the service, for the
+	// QoS-aware fabrics. Not set if 0.
+	DSCP uint32 `protobuf:"varint,16,opt,name=DSCP,proto3" json:"DSCP,omitempty"`
+	// the programming priority class of the service: within a sync, the backends program the
+	// services of the higher classes first.
+	Priority Priority `protobuf:"varint,17,opt,name=Priority,proto3,enum=localv1.Priority" json:"Priority,omitempty"`
 }
 
 func (x *Service) Reset() {
@@ -603,6 +761,41 @@ func (x *Service) GetInternalTrafficToLocal() bool {
 	return false
 }
 
+func (x *Service) GetNoLoadBalancerNodePorts() bool {
+	if x != nil {
+		return x.NoLoadBalancerNodePorts
+	}
+	return false
+}
+
+func (x *Service) GetExternalName() string {
+	if x != nil {
+		return x.ExternalName
+	}
+	return ""
+}
+
+func (x *Service) GetNoTrack() bool {
+	if x != nil {
+		return x.NoTrack
+	}
+	return false
+}
+
+func (x *Service) GetDSCP() uint32 {
+	if x != nil {
+		return x.DSCP
+	}
+	return 0
+}
+
+func (x *Service) GetPriority() Priority {
+	if x != nil {
+		return x.Priority
+	}
+	return Priority_NormalPriority
+}
+
 type isService_SessionAffinity interface {
 	isService_SessionAffinity()
 }
@@ -679,6 +872,9 @@ type ServiceIPs struct {
 	ExternalIPs     *IPSet `protobuf:"bytes,2,opt,name=ExternalIPs,proto3" json:"ExternalIPs,omitempty"`
 	LoadBalancerIPs *IPSet `protobuf:"bytes,4,opt,name=LoadBalancerIPs,proto3" json:"LoadBalancerIPs,omitempty"`
 	Headless        bool   `protobuf:"varint,3,opt,name=Headless,proto3" json:"Headless,omitempty"`
+	// the LoadBalancerIPs with the Proxy ipMode: the load-balancer sends the traffic to the
+	// nodes, so the backends don't write rules for these IPs.
+	ProxyLoadBalancerIPs *IPSet `protobuf:"bytes,5,opt,name=ProxyLoadBalancerIPs,proto3" json:"ProxyLoadBalancerIPs,omitempty"`
 }
 
 func (x *ServiceIPs) Reset() {
@@ -741,6 +937,13 @@ func (x *ServiceIPs) GetHeadless() bool {
 	return false
 }
 
+func (x *ServiceIPs) GetProxyLoadBalancerIPs() *IPSet {
+	if x != nil {
+		return x.ProxyLoadBalancerIPs
+	}
+	return nil
+}
+
 type Endpoint struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -751,6 +954,11 @@ type Endpoint struct {
 	Local         bool            `protobuf:"varint,3,opt,name=Local,proto3" json:"Local,omitempty"`
 	PortOverrides []*PortName     `protobuf:"bytes,4,rep,name=PortOverrides,proto3" json:"PortOverrides,omitempty"`
 	Scopes        *EndpointScopes `protobuf:"bytes,5,opt,name=Scopes,proto3" json:"Scopes,omitempty"`
+	// Weight of the endpoint relative to the others of the service (0 if not set).
+	Weight int32 `protobuf:"varint,6,opt,name=Weight,proto3" json:"Weight,omitempty"`
+	// Conditions of the endpoint, set only when requested by WithTerminatingEndpoints (the
+	// endpoint is ready if not set).
+	Conditions *EndpointConditions `protobuf:"bytes,7,opt,name=Conditions,proto3" json:"Conditions,omitempty"`
 }
 
 func (x *Endpoint) Reset() {
@@ -820,6 +1028,83 @@ func (x *Endpoint) GetScopes() *EndpointScopes {
 	return nil
 }
 
+func (x *Endpoint) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+func (x *Endpoint) GetConditions() *EndpointConditions {
+	if x != nil {
+		return x.Conditions
+	}
+	return nil
+}
+
+type EndpointConditions struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Ready       bool `protobuf:"varint,1,opt,name=Ready,proto3" json:"Ready,omitempty"`
+	Serving     bool `protobuf:"varint,2,opt,name=Serving,proto3" json:"Serving,omitempty"`
+	Terminating bool `protobuf:"varint,3,opt,name=Terminating,proto3" json:"Terminating,omitempty"`
+}
+
+func (x *EndpointConditions) Reset() {
+	*x = EndpointConditions{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv1_api_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EndpointConditions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EndpointConditions) ProtoMessage() {}
+
+func (x *EndpointConditions) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv1_api_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EndpointConditions.ProtoReflect.Descriptor instead.
+func (*EndpointConditions) Descriptor() ([]byte, []int) {
+	return file_api_localv1_api_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *EndpointConditions) GetReady() bool {
+	if x != nil {
+		return x.Ready
+	}
+	return false
+}
+
+func (x *EndpointConditions) GetServing() bool {
+	if x != nil {
+		return x.Serving
+	}
+	return false
+}
+
+func (x *EndpointConditions) GetTerminating() bool {
+	if x != nil {
+		return x.Terminating
+	}
+	return false
+}
+
 type EndpointScopes struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -832,7 +1117,7 @@ type EndpointScopes struct {
 func (x *EndpointScopes) Reset() {
 	*x = EndpointScopes{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_localv1_api_proto_msgTypes[9]
+		mi := &file_api_localv1_api_proto_msgTypes[10]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -845,7 +1130,7 @@ func (x *EndpointScopes) String() string {
 func (*EndpointScopes) ProtoMessage() {}
 
 func (x *EndpointScopes) ProtoReflect() protoreflect.Message {
-	mi := &file_api_localv1_api_proto_msgTypes[9]
+	mi := &file_api_localv1_api_proto_msgTypes[10]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -858,7 +1143,7 @@ func (x *EndpointScopes) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use EndpointScopes.ProtoReflect.Descriptor instead.
 func (*EndpointScopes) Descriptor() ([]byte, []int) {
-	return file_api_localv1_api_proto_rawDescGZIP(), []int{9}
+	return file_api_localv1_api_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *EndpointScopes) GetInternal() bool {
@@ -887,7 +1172,7 @@ type IPSet struct {
 func (x *IPSet) Reset() {
 	*x = IPSet{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_localv1_api_proto_msgTypes[10]
+		mi := &file_api_localv1_api_proto_msgTypes[11]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -900,7 +1185,7 @@ func (x *IPSet) String() string {
 func (*IPSet) ProtoMessage() {}
 
 func (x *IPSet) ProtoReflect() protoreflect.Message {
-	mi := &file_api_localv1_api_proto_msgTypes[10]
+	mi := &file_api_localv1_api_proto_msgTypes[11]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -913,7 +1198,7 @@ func (x *IPSet) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use IPSet.ProtoReflect.Descriptor instead.
 func (*IPSet) Descriptor() ([]byte, []int) {
-	return file_api_localv1_api_proto_rawDescGZIP(), []int{10}
+	return file_api_localv1_api_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *IPSet) GetV4() []string {
@@ -942,7 +1227,7 @@ type PortName struct {
 func (x *PortName) Reset() {
 	*x = PortName{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_localv1_api_proto_msgTypes[11]
+		mi := &file_api_localv1_api_proto_msgTypes[12]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -955,7 +1240,7 @@ func (x *PortName) String() string {
 func (*PortName) ProtoMessage() {}
 
 func (x *PortName) ProtoReflect() protoreflect.Message {
-	mi := &file_api_localv1_api_proto_msgTypes[11]
+	mi := &file_api_localv1_api_proto_msgTypes[12]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -968,7 +1253,7 @@ func (x *PortName) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PortName.ProtoReflect.Descriptor instead.
 func (*PortName) Descriptor() ([]byte, []int) {
-	return file_api_localv1_api_proto_rawDescGZIP(), []int{11}
+	return file_api_localv1_api_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *PortName) GetName() string {
@@ -1001,7 +1286,7 @@ type PortMapping struct {
 func (x *PortMapping) Reset() {
 	*x = PortMapping{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_localv1_api_proto_msgTypes[12]
+		mi := &file_api_localv1_api_proto_msgTypes[13]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1014,7 +1299,7 @@ func (x *PortMapping) String() string {
 func (*PortMapping) ProtoMessage() {}
 
 func (x *PortMapping) ProtoReflect() protoreflect.Message {
-	mi := &file_api_localv1_api_proto_msgTypes[12]
+	mi := &file_api_localv1_api_proto_msgTypes[13]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1027,7 +1312,7 @@ func (x *PortMapping) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PortMapping.ProtoReflect.Descriptor instead.
 func (*PortMapping) Descriptor() ([]byte, []int) {
-	return file_api_localv1_api_proto_rawDescGZIP(), []int{12}
+	return file_api_localv1_api_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *PortMapping) GetName() string {
@@ -1083,7 +1368,7 @@ type ClientIPAffinity struct {
 func (x *ClientIPAffinity) Reset() {
 	*x = ClientIPAffinity{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_localv1_api_proto_msgTypes[13]
+		mi := &file_api_localv1_api_proto_msgTypes[14]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1096,7 +1381,7 @@ func (x *ClientIPAffinity) String() string {
 func (*ClientIPAffinity) ProtoMessage() {}
 
 func (x *ClientIPAffinity) ProtoReflect() protoreflect.Message {
-	mi := &file_api_localv1_api_proto_msgTypes[13]
+	mi := &file_api_localv1_api_proto_msgTypes[14]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1109,7 +1394,7 @@ func (x *ClientIPAffinity) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ClientIPAffinity.ProtoReflect.Descriptor instead.
 func (*ClientIPAffinity) Descriptor() ([]byte, []int) {
-	return file_api_localv1_api_proto_rawDescGZIP(), []int{13}
+	return file_api_localv1_api_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *ClientIPAffinity) GetTimeoutSeconds() int32 {
@@ -1124,146 +1409,194 @@ var File_api_localv1_api_proto protoreflect.FileDescriptor
 var file_api_localv1_api_proto_rawDesc = []byte{
 	0x0a, 0x15, 0x61, 0x70, 0x69, 0x2f, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2f, 0x61, 0x70,
 	0x69, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x07, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31,
-	0x22, 0x26, 0x0a, 0x08, 0x57, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71, 0x12, 0x1a, 0x0a, 0x08,
-	0x4e, 0x6f, 0x64, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
-	0x4e, 0x6f, 0x64, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x22, 0xac, 0x01, 0x0a, 0x06, 0x4f, 0x70, 0x49,
+	0x22, 0x95, 0x01, 0x0a, 0x08, 0x57, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71, 0x12, 0x1a, 0x0a,
+	0x08, 0x4e, 0x6f, 0x64, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x08, 0x4e, 0x6f, 0x64, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x31, 0x0a, 0x0a, 0x49, 0x50, 0x46,
+	0x61, 0x6d, 0x69, 0x6c, 0x69, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0e, 0x32, 0x11, 0x2e,
+	0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x49, 0x50, 0x46, 0x61, 0x6d, 0x69, 0x6c, 0x79,
+	0x52, 0x0a, 0x49, 0x50, 0x46, 0x61, 0x6d, 0x69, 0x6c, 0x69, 0x65, 0x73, 0x12, 0x3a, 0x0a, 0x18,
+	0x57, 0x69, 0x74, 0x68, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x45,
+	0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x18,
+	0x57, 0x69, 0x74, 0x68, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x45,
+	0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x22, 0xe2, 0x01, 0x0a, 0x06, 0x4f, 0x70, 0x49,
 	0x74, 0x65, 0x6d, 0x12, 0x26, 0x0a, 0x04, 0x53, 0x79, 0x6e, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28,
 	0x0b, 0x32, 0x10, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x45, 0x6d, 0x70, 0x74,
 	0x79, 0x4f, 0x70, 0x48, 0x00, 0x52, 0x04, 0x53, 0x79, 0x6e, 0x63, 0x12, 0x28, 0x0a, 0x05, 0x52,
 	0x65, 0x73, 0x65, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x6c, 0x6f, 0x63,
 	0x61, 0x6c, 0x76, 0x31, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x4f, 0x70, 0x48, 0x00, 0x52, 0x05,
-	0x52, 0x65, 0x73, 0x65, 0x74, 0x12, 0x22, 0x0a, 0x03, 0x53, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01,
-	0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x56, 0x61, 0x6c,
-	0x75, 0x65, 0x48, 0x00, 0x52, 0x03, 0x53, 0x65, 0x74, 0x12, 0x26, 0x0a, 0x06, 0x44, 0x65, 0x6c,
-	0x65, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x6c, 0x6f, 0x63, 0x61,
-	0x6c, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x66, 0x48, 0x00, 0x52, 0x06, 0x44, 0x65, 0x6c, 0x65, 0x74,
-	0x65, 0x42, 0x04, 0x0a, 0x02, 0x4f, 0x70, 0x22, 0x09, 0x0a, 0x07, 0x45, 0x6d, 0x70, 0x74, 0x79,
-	0x4f, 0x70, 0x22, 0x39, 0x0a, 0x03, 0x52, 0x65, 0x66, 0x12, 0x1e, 0x0a, 0x03, 0x53, 0x65, 0x74,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0c, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31,
-	0x2e, 0x53, 0x65, 0x74, 0x52, 0x03, 0x53, 0x65, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x50, 0x61, 0x74,
-	0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x50, 0x61, 0x74, 0x68, 0x22, 0x3d, 0x0a,
-	0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x1e, 0x0a, 0x03, 0x52, 0x65, 0x66, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x52, 0x65,
-	0x66, 0x52, 0x03, 0x52, 0x65, 0x66, 0x12, 0x14, 0x0a, 0x05, 0x42, 0x79, 0x74, 0x65, 0x73, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x42, 0x79, 0x74, 0x65, 0x73, 0x22, 0x9b, 0x05, 0x0a,
-	0x07, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x4e, 0x61, 0x6d, 0x65,
-	0x73, 0x70, 0x61, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x4e, 0x61, 0x6d,
-	0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x54, 0x79,
-	0x70, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x54, 0x79, 0x70, 0x65, 0x12, 0x34,
-	0x0a, 0x06, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c,
-	0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
-	0x2e, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x06, 0x4c, 0x61,
-	0x62, 0x65, 0x6c, 0x73, 0x12, 0x43, 0x0a, 0x0b, 0x41, 0x6e, 0x6e, 0x6f, 0x74, 0x61, 0x74, 0x69,
-	0x6f, 0x6e, 0x73, 0x18, 0x09, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x6c, 0x6f, 0x63, 0x61,
-	0x6c, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x41, 0x6e, 0x6e, 0x6f,
-	0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0b, 0x41, 0x6e,
-	0x6e, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x25, 0x0a, 0x03, 0x49, 0x50, 0x73,
-	0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31,
-	0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x49, 0x50, 0x73, 0x52, 0x03, 0x49, 0x50, 0x73,
-	0x12, 0x2f, 0x0a, 0x09, 0x49, 0x50, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x73, 0x18, 0x0a, 0x20,
-	0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x49, 0x50,
-	0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x52, 0x09, 0x49, 0x50, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72,
-	0x73, 0x12, 0x14, 0x0a, 0x05, 0x4d, 0x61, 0x70, 0x49, 0x50, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08,
-	0x52, 0x05, 0x4d, 0x61, 0x70, 0x49, 0x50, 0x12, 0x2a, 0x0a, 0x05, 0x50, 0x6f, 0x72, 0x74, 0x73,
-	0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31,
-	0x2e, 0x50, 0x6f, 0x72, 0x74, 0x4d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x52, 0x05, 0x50, 0x6f,
-	0x72, 0x74, 0x73, 0x12, 0x36, 0x0a, 0x16, 0x45, 0x78, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x54,
-	0x72, 0x61, 0x66, 0x66, 0x69, 0x63, 0x54, 0x6f, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x18, 0x07, 0x20,
-	0x01, 0x28, 0x08, 0x52, 0x16, 0x45, 0x78, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x54, 0x72, 0x61,
-	0x66, 0x66, 0x69, 0x63, 0x54, 0x6f, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x12, 0x37, 0x0a, 0x08, 0x43,
-	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x50, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e,
-	0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x50,
-	0x41, 0x66, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x79, 0x48, 0x00, 0x52, 0x08, 0x43, 0x6c, 0x69, 0x65,
-	0x6e, 0x74, 0x49, 0x50, 0x12, 0x36, 0x0a, 0x16, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c,
-	0x54, 0x72, 0x61, 0x66, 0x66, 0x69, 0x63, 0x54, 0x6f, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x18, 0x0c,
-	0x20, 0x01, 0x28, 0x08, 0x52, 0x16, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x54, 0x72,
-	0x61, 0x66, 0x66, 0x69, 0x63, 0x54, 0x6f, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x1a, 0x39, 0x0a, 0x0b,
-	0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b,
-	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a,
-	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61,
-	0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x3e, 0x0a, 0x10, 0x41, 0x6e, 0x6e, 0x6f, 0x74,
-	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b,
-	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a,
-	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61,
-	0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x42, 0x11, 0x0a, 0x0f, 0x53, 0x65, 0x73, 0x73, 0x69,
-	0x6f, 0x6e, 0x41, 0x66, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x79, 0x22, 0x5c, 0x0a, 0x08, 0x49, 0x50,
-	0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x12, 0x2c, 0x0a, 0x09, 0x54, 0x61, 0x72, 0x67, 0x65, 0x74,
-	0x49, 0x50, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x6c, 0x6f, 0x63, 0x61,
-	0x6c, 0x76, 0x31, 0x2e, 0x49, 0x50, 0x53, 0x65, 0x74, 0x52, 0x09, 0x54, 0x61, 0x72, 0x67, 0x65,
-	0x74, 0x49, 0x50, 0x73, 0x12, 0x22, 0x0a, 0x0c, 0x53, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x52, 0x61,
-	0x6e, 0x67, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0c, 0x53, 0x6f, 0x75, 0x72,
-	0x63, 0x65, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x22, 0xc4, 0x01, 0x0a, 0x0a, 0x53, 0x65, 0x72,
-	0x76, 0x69, 0x63, 0x65, 0x49, 0x50, 0x73, 0x12, 0x2e, 0x0a, 0x0a, 0x43, 0x6c, 0x75, 0x73, 0x74,
-	0x65, 0x72, 0x49, 0x50, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x6c, 0x6f,
-	0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x49, 0x50, 0x53, 0x65, 0x74, 0x52, 0x0a, 0x43, 0x6c, 0x75,
-	0x73, 0x74, 0x65, 0x72, 0x49, 0x50, 0x73, 0x12, 0x30, 0x0a, 0x0b, 0x45, 0x78, 0x74, 0x65, 0x72,
-	0x6e, 0x61, 0x6c, 0x49, 0x50, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x6c,
-	0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x49, 0x50, 0x53, 0x65, 0x74, 0x52, 0x0b, 0x45, 0x78,
-	0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x49, 0x50, 0x73, 0x12, 0x38, 0x0a, 0x0f, 0x4c, 0x6f, 0x61,
-	0x64, 0x42, 0x61, 0x6c, 0x61, 0x6e, 0x63, 0x65, 0x72, 0x49, 0x50, 0x73, 0x18, 0x04, 0x20, 0x01,
-	0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x49, 0x50, 0x53,
-	0x65, 0x74, 0x52, 0x0f, 0x4c, 0x6f, 0x61, 0x64, 0x42, 0x61, 0x6c, 0x61, 0x6e, 0x63, 0x65, 0x72,
-	0x49, 0x50, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x48, 0x65, 0x61, 0x64, 0x6c, 0x65, 0x73, 0x73, 0x18,
-	0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x48, 0x65, 0x61, 0x64, 0x6c, 0x65, 0x73, 0x73, 0x22,
-	0xc8, 0x01, 0x0a, 0x08, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x12, 0x1a, 0x0a, 0x08,
-	0x48, 0x6f, 0x73, 0x74, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
-	0x48, 0x6f, 0x73, 0x74, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x20, 0x0a, 0x03, 0x49, 0x50, 0x73, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e,
-	0x49, 0x50, 0x53, 0x65, 0x74, 0x52, 0x03, 0x49, 0x50, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x4c, 0x6f,
-	0x63, 0x61, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x4c, 0x6f, 0x63, 0x61, 0x6c,
-	0x12, 0x37, 0x0a, 0x0d, 0x50, 0x6f, 0x72, 0x74, 0x4f, 0x76, 0x65, 0x72, 0x72, 0x69, 0x64, 0x65,
-	0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76,
-	0x31, 0x2e, 0x50, 0x6f, 0x72, 0x74, 0x4e, 0x61, 0x6d, 0x65, 0x52, 0x0d, 0x50, 0x6f, 0x72, 0x74,
-	0x4f, 0x76, 0x65, 0x72, 0x72, 0x69, 0x64, 0x65, 0x73, 0x12, 0x2f, 0x0a, 0x06, 0x53, 0x63, 0x6f,
-	0x70, 0x65, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x6c, 0x6f, 0x63, 0x61,
-	0x6c, 0x76, 0x31, 0x2e, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x53, 0x63, 0x6f, 0x70,
-	0x65, 0x73, 0x52, 0x06, 0x53, 0x63, 0x6f, 0x70, 0x65, 0x73, 0x22, 0x48, 0x0a, 0x0e, 0x45, 0x6e,
-	0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x53, 0x63, 0x6f, 0x70, 0x65, 0x73, 0x12, 0x1a, 0x0a, 0x08,
-	0x49, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08,
-	0x49, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x12, 0x1a, 0x0a, 0x08, 0x45, 0x78, 0x74, 0x65,
-	0x72, 0x6e, 0x61, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x45, 0x78, 0x74, 0x65,
-	0x72, 0x6e, 0x61, 0x6c, 0x22, 0x27, 0x0a, 0x05, 0x49, 0x50, 0x53, 0x65, 0x74, 0x12, 0x0e, 0x0a,
-	0x02, 0x56, 0x34, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x02, 0x56, 0x34, 0x12, 0x0e, 0x0a,
-	0x02, 0x56, 0x36, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x02, 0x56, 0x36, 0x22, 0x32, 0x0a,
-	0x08, 0x50, 0x6f, 0x72, 0x74, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x4e, 0x61, 0x6d,
-	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a,
-	0x04, 0x50, 0x6f, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x50, 0x6f, 0x72,
-	0x74, 0x22, 0xc8, 0x01, 0x0a, 0x0b, 0x50, 0x6f, 0x72, 0x74, 0x4d, 0x61, 0x70, 0x70, 0x69, 0x6e,
-	0x67, 0x12, 0x12, 0x0a, 0x04, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x04, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x2d, 0x0a, 0x08, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f,
-	0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x11, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76,
-	0x31, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x52, 0x08, 0x50, 0x72, 0x6f, 0x74,
-	0x6f, 0x63, 0x6f, 0x6c, 0x12, 0x12, 0x0a, 0x04, 0x50, 0x6f, 0x72, 0x74, 0x18, 0x03, 0x20, 0x01,
-	0x28, 0x05, 0x52, 0x04, 0x50, 0x6f, 0x72, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x4e, 0x6f, 0x64, 0x65,
-	0x50, 0x6f, 0x72, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x4e, 0x6f, 0x64, 0x65,
-	0x50, 0x6f, 0x72, 0x74, 0x12, 0x1e, 0x0a, 0x0a, 0x54, 0x61, 0x72, 0x67, 0x65, 0x74, 0x50, 0x6f,
-	0x72, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a, 0x54, 0x61, 0x72, 0x67, 0x65, 0x74,
-	0x50, 0x6f, 0x72, 0x74, 0x12, 0x26, 0x0a, 0x0e, 0x54, 0x61, 0x72, 0x67, 0x65, 0x74, 0x50, 0x6f,
-	0x72, 0x74, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x54, 0x61,
-	0x72, 0x67, 0x65, 0x74, 0x50, 0x6f, 0x72, 0x74, 0x4e, 0x61, 0x6d, 0x65, 0x22, 0x3a, 0x0a, 0x10,
-	0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x50, 0x41, 0x66, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x79,
-	0x12, 0x26, 0x0a, 0x0e, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x53, 0x65, 0x63, 0x6f, 0x6e,
-	0x64, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0e, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75,
-	0x74, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x2a, 0x7e, 0x0a, 0x03, 0x53, 0x65, 0x74, 0x12,
-	0x0e, 0x0a, 0x0a, 0x55, 0x6e, 0x6b, 0x6e, 0x6f, 0x77, 0x6e, 0x53, 0x65, 0x74, 0x10, 0x00, 0x12,
-	0x0f, 0x0a, 0x0b, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x53, 0x65, 0x74, 0x10, 0x01,
-	0x12, 0x10, 0x0a, 0x0c, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x53, 0x65, 0x74,
-	0x10, 0x02, 0x12, 0x16, 0x0a, 0x12, 0x47, 0x6c, 0x6f, 0x62, 0x61, 0x6c, 0x53, 0x65, 0x72, 0x76,
-	0x69, 0x63, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x73, 0x10, 0x0a, 0x12, 0x17, 0x0a, 0x13, 0x47, 0x6c,
-	0x6f, 0x62, 0x61, 0x6c, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x49, 0x6e, 0x66, 0x6f,
-	0x73, 0x10, 0x0b, 0x12, 0x13, 0x0a, 0x0f, 0x47, 0x6c, 0x6f, 0x62, 0x61, 0x6c, 0x4e, 0x6f, 0x64,
-	0x65, 0x49, 0x6e, 0x66, 0x6f, 0x73, 0x10, 0x0c, 0x2a, 0x3b, 0x0a, 0x08, 0x50, 0x72, 0x6f, 0x74,
-	0x6f, 0x63, 0x6f, 0x6c, 0x12, 0x13, 0x0a, 0x0f, 0x55, 0x6e, 0x6b, 0x6e, 0x6f, 0x77, 0x6e, 0x50,
-	0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x10, 0x00, 0x12, 0x07, 0x0a, 0x03, 0x54, 0x43, 0x50,
-	0x10, 0x01, 0x12, 0x07, 0x0a, 0x03, 0x55, 0x44, 0x50, 0x10, 0x02, 0x12, 0x08, 0x0a, 0x04, 0x53,
-	0x43, 0x54, 0x50, 0x10, 0x03, 0x32, 0x37, 0x0a, 0x04, 0x53, 0x65, 0x74, 0x73, 0x12, 0x2f, 0x0a,
-	0x05, 0x57, 0x61, 0x74, 0x63, 0x68, 0x12, 0x11, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31,
-	0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71, 0x1a, 0x0f, 0x2e, 0x6c, 0x6f, 0x63, 0x61,
-	0x6c, 0x76, 0x31, 0x2e, 0x4f, 0x70, 0x49, 0x74, 0x65, 0x6d, 0x28, 0x01, 0x30, 0x01, 0x42, 0x1e,
-	0x5a, 0x1c, 0x73, 0x69, 0x67, 0x73, 0x2e, 0x6b, 0x38, 0x73, 0x2e, 0x69, 0x6f, 0x2f, 0x6b, 0x70,
-	0x6e, 0x67, 0x2f, 0x61, 0x70, 0x69, 0x2f, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x62, 0x06,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x52, 0x65, 0x73, 0x65, 0x74, 0x12, 0x34, 0x0a, 0x0b, 0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c,
+	0x53, 0x79, 0x6e, 0x63, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x6c, 0x6f, 0x63,
+	0x61, 0x6c, 0x76, 0x31, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x4f, 0x70, 0x48, 0x00, 0x52, 0x0b,
+	0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x53, 0x79, 0x6e, 0x63, 0x12, 0x22, 0x0a, 0x03, 0x53,
+	0x65, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c,
+	0x76, 0x31, 0x2e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x48, 0x00, 0x52, 0x03, 0x53, 0x65, 0x74, 0x12,
+	0x26, 0x0a, 0x06, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x0c, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x66, 0x48, 0x00, 0x52,
+	0x06, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x42, 0x04, 0x0a, 0x02, 0x4f, 0x70, 0x22, 0x09, 0x0a,
+	0x07, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x4f, 0x70, 0x22, 0x39, 0x0a, 0x03, 0x52, 0x65, 0x66, 0x12,
+	0x1e, 0x0a, 0x03, 0x53, 0x65, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0c, 0x2e, 0x6c,
+	0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x74, 0x52, 0x03, 0x53, 0x65, 0x74, 0x12,
+	0x12, 0x0a, 0x04, 0x50, 0x61, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x50,
+	0x61, 0x74, 0x68, 0x22, 0x3d, 0x0a, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x1e, 0x0a, 0x03,
+	0x52, 0x65, 0x66, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x6c, 0x6f, 0x63, 0x61,
+	0x6c, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x66, 0x52, 0x03, 0x52, 0x65, 0x66, 0x12, 0x14, 0x0a, 0x05,
+	0x42, 0x79, 0x74, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x42, 0x79, 0x74,
+	0x65, 0x73, 0x22, 0xd6, 0x06, 0x0a, 0x07, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x1c,
+	0x0a, 0x09, 0x4e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x09, 0x4e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x12, 0x12, 0x0a, 0x04,
+	0x4e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x4e, 0x61, 0x6d, 0x65,
+	0x12, 0x12, 0x0a, 0x04, 0x54, 0x79, 0x70, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x54, 0x79, 0x70, 0x65, 0x12, 0x34, 0x0a, 0x06, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x18, 0x08,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x53,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x52, 0x06, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x12, 0x43, 0x0a, 0x0b, 0x41, 0x6e,
+	0x6e, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x09, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x21, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x2e, 0x41, 0x6e, 0x6e, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x52, 0x0b, 0x41, 0x6e, 0x6e, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12,
+	0x25, 0x0a, 0x03, 0x49, 0x50, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x6c,
+	0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x49, 0x50,
+	0x73, 0x52, 0x03, 0x49, 0x50, 0x73, 0x12, 0x2f, 0x0a, 0x09, 0x49, 0x50, 0x46, 0x69, 0x6c, 0x74,
+	0x65, 0x72, 0x73, 0x18, 0x0a, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x6c, 0x6f, 0x63, 0x61,
+	0x6c, 0x76, 0x31, 0x2e, 0x49, 0x50, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x52, 0x09, 0x49, 0x50,
+	0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x4d, 0x61, 0x70, 0x49, 0x50,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x4d, 0x61, 0x70, 0x49, 0x50, 0x12, 0x2a, 0x0a,
+	0x05, 0x50, 0x6f, 0x72, 0x74, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x6c,
+	0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x50, 0x6f, 0x72, 0x74, 0x4d, 0x61, 0x70, 0x70, 0x69,
+	0x6e, 0x67, 0x52, 0x05, 0x50, 0x6f, 0x72, 0x74, 0x73, 0x12, 0x36, 0x0a, 0x16, 0x45, 0x78, 0x74,
+	0x65, 0x72, 0x6e, 0x61, 0x6c, 0x54, 0x72, 0x61, 0x66, 0x66, 0x69, 0x63, 0x54, 0x6f, 0x4c, 0x6f,
+	0x63, 0x61, 0x6c, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08, 0x52, 0x16, 0x45, 0x78, 0x74, 0x65, 0x72,
+	0x6e, 0x61, 0x6c, 0x54, 0x72, 0x61, 0x66, 0x66, 0x69, 0x63, 0x54, 0x6f, 0x4c, 0x6f, 0x63, 0x61,
+	0x6c, 0x12, 0x37, 0x0a, 0x08, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x50, 0x18, 0x0b, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x43, 0x6c,
+	0x69, 0x65, 0x6e, 0x74, 0x49, 0x50, 0x41, 0x66, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x79, 0x48, 0x00,
+	0x52, 0x08, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x50, 0x12, 0x36, 0x0a, 0x16, 0x49, 0x6e,
+	0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x54, 0x72, 0x61, 0x66, 0x66, 0x69, 0x63, 0x54, 0x6f, 0x4c,
+	0x6f, 0x63, 0x61, 0x6c, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x08, 0x52, 0x16, 0x49, 0x6e, 0x74, 0x65,
+	0x72, 0x6e, 0x61, 0x6c, 0x54, 0x72, 0x61, 0x66, 0x66, 0x69, 0x63, 0x54, 0x6f, 0x4c, 0x6f, 0x63,
+	0x61, 0x6c, 0x12, 0x38, 0x0a, 0x17, 0x4e, 0x6f, 0x4c, 0x6f, 0x61, 0x64, 0x42, 0x61, 0x6c, 0x61,
+	0x6e, 0x63, 0x65, 0x72, 0x4e, 0x6f, 0x64, 0x65, 0x50, 0x6f, 0x72, 0x74, 0x73, 0x18, 0x0d, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x17, 0x4e, 0x6f, 0x4c, 0x6f, 0x61, 0x64, 0x42, 0x61, 0x6c, 0x61, 0x6e,
+	0x63, 0x65, 0x72, 0x4e, 0x6f, 0x64, 0x65, 0x50, 0x6f, 0x72, 0x74, 0x73, 0x12, 0x22, 0x0a, 0x0c,
+	0x45, 0x78, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x0e, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0c, 0x45, 0x78, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x4e, 0x61, 0x6d, 0x65,
+	0x12, 0x18, 0x0a, 0x07, 0x4e, 0x6f, 0x54, 0x72, 0x61, 0x63, 0x6b, 0x18, 0x0f, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x07, 0x4e, 0x6f, 0x54, 0x72, 0x61, 0x63, 0x6b, 0x12, 0x12, 0x0a, 0x04, 0x44, 0x53,
+	0x43, 0x50, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x44, 0x53, 0x43, 0x50, 0x12, 0x2d,
+	0x0a, 0x08, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x18, 0x11, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x11, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x50, 0x72, 0x69, 0x6f, 0x72,
+	0x69, 0x74, 0x79, 0x52, 0x08, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x1a, 0x39, 0x0a,
+	0x0b, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
+	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x3e, 0x0a, 0x10, 0x41, 0x6e, 0x6e, 0x6f,
+	0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
+	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x42, 0x11, 0x0a, 0x0f, 0x53, 0x65, 0x73, 0x73,
+	0x69, 0x6f, 0x6e, 0x41, 0x66, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x79, 0x22, 0x5c, 0x0a, 0x08, 0x49,
+	0x50, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x12, 0x2c, 0x0a, 0x09, 0x54, 0x61, 0x72, 0x67, 0x65,
+	0x74, 0x49, 0x50, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x6c, 0x6f, 0x63,
+	0x61, 0x6c, 0x76, 0x31, 0x2e, 0x49, 0x50, 0x53, 0x65, 0x74, 0x52, 0x09, 0x54, 0x61, 0x72, 0x67,
+	0x65, 0x74, 0x49, 0x50, 0x73, 0x12, 0x22, 0x0a, 0x0c, 0x53, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x52,
+	0x61, 0x6e, 0x67, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0c, 0x53, 0x6f, 0x75,
+	0x72, 0x63, 0x65, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x22, 0x88, 0x02, 0x0a, 0x0a, 0x53, 0x65,
+	0x72, 0x76, 0x69, 0x63, 0x65, 0x49, 0x50, 0x73, 0x12, 0x2e, 0x0a, 0x0a, 0x43, 0x6c, 0x75, 0x73,
+	0x74, 0x65, 0x72, 0x49, 0x50, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x6c,
+	0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x49, 0x50, 0x53, 0x65, 0x74, 0x52, 0x0a, 0x43, 0x6c,
+	0x75, 0x73, 0x74, 0x65, 0x72, 0x49, 0x50, 0x73, 0x12, 0x30, 0x0a, 0x0b, 0x45, 0x78, 0x74, 0x65,
+	0x72, 0x6e, 0x61, 0x6c, 0x49, 0x50, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e,
+	0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x49, 0x50, 0x53, 0x65, 0x74, 0x52, 0x0b, 0x45,
+	0x78, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x49, 0x50, 0x73, 0x12, 0x38, 0x0a, 0x0f, 0x4c, 0x6f,
+	0x61, 0x64, 0x42, 0x61, 0x6c, 0x61, 0x6e, 0x63, 0x65, 0x72, 0x49, 0x50, 0x73, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x49, 0x50,
+	0x53, 0x65, 0x74, 0x52, 0x0f, 0x4c, 0x6f, 0x61, 0x64, 0x42, 0x61, 0x6c, 0x61, 0x6e, 0x63, 0x65,
+	0x72, 0x49, 0x50, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x48, 0x65, 0x61, 0x64, 0x6c, 0x65, 0x73, 0x73,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x48, 0x65, 0x61, 0x64, 0x6c, 0x65, 0x73, 0x73,
+	0x12, 0x42, 0x0a, 0x14, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x4c, 0x6f, 0x61, 0x64, 0x42, 0x61, 0x6c,
+	0x61, 0x6e, 0x63, 0x65, 0x72, 0x49, 0x50, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e,
+	0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x49, 0x50, 0x53, 0x65, 0x74, 0x52, 0x14,
+	0x50, 0x72, 0x6f, 0x78, 0x79, 0x4c, 0x6f, 0x61, 0x64, 0x42, 0x61, 0x6c, 0x61, 0x6e, 0x63, 0x65,
+	0x72, 0x49, 0x50, 0x73, 0x22, 0x9d, 0x02, 0x0a, 0x08, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e,
+	0x74, 0x12, 0x1a, 0x0a, 0x08, 0x48, 0x6f, 0x73, 0x74, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x48, 0x6f, 0x73, 0x74, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x20, 0x0a,
+	0x03, 0x49, 0x50, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x6c, 0x6f, 0x63,
+	0x61, 0x6c, 0x76, 0x31, 0x2e, 0x49, 0x50, 0x53, 0x65, 0x74, 0x52, 0x03, 0x49, 0x50, 0x73, 0x12,
+	0x14, 0x0a, 0x05, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05,
+	0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x12, 0x37, 0x0a, 0x0d, 0x50, 0x6f, 0x72, 0x74, 0x4f, 0x76, 0x65,
+	0x72, 0x72, 0x69, 0x64, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x6c,
+	0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x50, 0x6f, 0x72, 0x74, 0x4e, 0x61, 0x6d, 0x65, 0x52,
+	0x0d, 0x50, 0x6f, 0x72, 0x74, 0x4f, 0x76, 0x65, 0x72, 0x72, 0x69, 0x64, 0x65, 0x73, 0x12, 0x2f,
+	0x0a, 0x06, 0x53, 0x63, 0x6f, 0x70, 0x65, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17,
+	0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e,
+	0x74, 0x53, 0x63, 0x6f, 0x70, 0x65, 0x73, 0x52, 0x06, 0x53, 0x63, 0x6f, 0x70, 0x65, 0x73, 0x12,
+	0x16, 0x0a, 0x06, 0x57, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x06, 0x57, 0x65, 0x69, 0x67, 0x68, 0x74, 0x12, 0x3b, 0x0a, 0x0a, 0x43, 0x6f, 0x6e, 0x64, 0x69,
+	0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x6c, 0x6f,
+	0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x43, 0x6f,
+	0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x52, 0x0a, 0x43, 0x6f, 0x6e, 0x64, 0x69, 0x74,
+	0x69, 0x6f, 0x6e, 0x73, 0x22, 0x66, 0x0a, 0x12, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74,
+	0x43, 0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x52, 0x65,
+	0x61, 0x64, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x52, 0x65, 0x61, 0x64, 0x79,
+	0x12, 0x18, 0x0a, 0x07, 0x53, 0x65, 0x72, 0x76, 0x69, 0x6e, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x07, 0x53, 0x65, 0x72, 0x76, 0x69, 0x6e, 0x67, 0x12, 0x20, 0x0a, 0x0b, 0x54, 0x65,
+	0x72, 0x6d, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x0b, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x22, 0x48, 0x0a, 0x0e,
+	0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x53, 0x63, 0x6f, 0x70, 0x65, 0x73, 0x12, 0x1a,
+	0x0a, 0x08, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x08, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x12, 0x1a, 0x0a, 0x08, 0x45, 0x78,
+	0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x45, 0x78,
+	0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x22, 0x27, 0x0a, 0x05, 0x49, 0x50, 0x53, 0x65, 0x74, 0x12,
+	0x0e, 0x0a, 0x02, 0x56, 0x34, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x02, 0x56, 0x34, 0x12,
+	0x0e, 0x0a, 0x02, 0x56, 0x36, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x02, 0x56, 0x36, 0x22,
+	0x32, 0x0a, 0x08, 0x50, 0x6f, 0x72, 0x74, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x4e,
+	0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x4e, 0x61, 0x6d, 0x65, 0x12,
+	0x12, 0x0a, 0x04, 0x50, 0x6f, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x50,
+	0x6f, 0x72, 0x74, 0x22, 0xc8, 0x01, 0x0a, 0x0b, 0x50, 0x6f, 0x72, 0x74, 0x4d, 0x61, 0x70, 0x70,
+	0x69, 0x6e, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x2d, 0x0a, 0x08, 0x50, 0x72, 0x6f, 0x74, 0x6f,
+	0x63, 0x6f, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x11, 0x2e, 0x6c, 0x6f, 0x63, 0x61,
+	0x6c, 0x76, 0x31, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x52, 0x08, 0x50, 0x72,
+	0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x12, 0x12, 0x0a, 0x04, 0x50, 0x6f, 0x72, 0x74, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x50, 0x6f, 0x72, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x4e, 0x6f,
+	0x64, 0x65, 0x50, 0x6f, 0x72, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x4e, 0x6f,
+	0x64, 0x65, 0x50, 0x6f, 0x72, 0x74, 0x12, 0x1e, 0x0a, 0x0a, 0x54, 0x61, 0x72, 0x67, 0x65, 0x74,
+	0x50, 0x6f, 0x72, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a, 0x54, 0x61, 0x72, 0x67,
+	0x65, 0x74, 0x50, 0x6f, 0x72, 0x74, 0x12, 0x26, 0x0a, 0x0e, 0x54, 0x61, 0x72, 0x67, 0x65, 0x74,
+	0x50, 0x6f, 0x72, 0x74, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e,
+	0x54, 0x61, 0x72, 0x67, 0x65, 0x74, 0x50, 0x6f, 0x72, 0x74, 0x4e, 0x61, 0x6d, 0x65, 0x22, 0x3a,
+	0x0a, 0x10, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x50, 0x41, 0x66, 0x66, 0x69, 0x6e, 0x69,
+	0x74, 0x79, 0x12, 0x26, 0x0a, 0x0e, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x53, 0x65, 0x63,
+	0x6f, 0x6e, 0x64, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0e, 0x54, 0x69, 0x6d, 0x65,
+	0x6f, 0x75, 0x74, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x2a, 0x94, 0x01, 0x0a, 0x03, 0x53,
+	0x65, 0x74, 0x12, 0x0e, 0x0a, 0x0a, 0x55, 0x6e, 0x6b, 0x6e, 0x6f, 0x77, 0x6e, 0x53, 0x65, 0x74,
+	0x10, 0x00, 0x12, 0x0f, 0x0a, 0x0b, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x53, 0x65,
+	0x74, 0x10, 0x01, 0x12, 0x10, 0x0a, 0x0c, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73,
+	0x53, 0x65, 0x74, 0x10, 0x02, 0x12, 0x14, 0x0a, 0x10, 0x45, 0x78, 0x74, 0x65, 0x72, 0x6e, 0x61,
+	0x6c, 0x4e, 0x61, 0x6d, 0x65, 0x73, 0x53, 0x65, 0x74, 0x10, 0x03, 0x12, 0x16, 0x0a, 0x12, 0x47,
+	0x6c, 0x6f, 0x62, 0x61, 0x6c, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x49, 0x6e, 0x66, 0x6f,
+	0x73, 0x10, 0x0a, 0x12, 0x17, 0x0a, 0x13, 0x47, 0x6c, 0x6f, 0x62, 0x61, 0x6c, 0x45, 0x6e, 0x64,
+	0x70, 0x6f, 0x69, 0x6e, 0x74, 0x49, 0x6e, 0x66, 0x6f, 0x73, 0x10, 0x0b, 0x12, 0x13, 0x0a, 0x0f,
+	0x47, 0x6c, 0x6f, 0x62, 0x61, 0x6c, 0x4e, 0x6f, 0x64, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x73, 0x10,
+	0x0c, 0x2a, 0x3b, 0x0a, 0x08, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x12, 0x13, 0x0a,
+	0x0f, 0x55, 0x6e, 0x6b, 0x6e, 0x6f, 0x77, 0x6e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c,
+	0x10, 0x00, 0x12, 0x07, 0x0a, 0x03, 0x54, 0x43, 0x50, 0x10, 0x01, 0x12, 0x07, 0x0a, 0x03, 0x55,
+	0x44, 0x50, 0x10, 0x02, 0x12, 0x08, 0x0a, 0x04, 0x53, 0x43, 0x54, 0x50, 0x10, 0x03, 0x2a, 0x57,
+	0x0a, 0x08, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x12, 0x12, 0x0a, 0x0e, 0x4e, 0x6f,
+	0x72, 0x6d, 0x61, 0x6c, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x10, 0x00, 0x12, 0x0f,
+	0x0a, 0x0b, 0x4c, 0x6f, 0x77, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x10, 0x01, 0x12,
+	0x10, 0x0a, 0x0c, 0x48, 0x69, 0x67, 0x68, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x10,
+	0x02, 0x12, 0x14, 0x0a, 0x10, 0x43, 0x72, 0x69, 0x74, 0x69, 0x63, 0x61, 0x6c, 0x50, 0x72, 0x69,
+	0x6f, 0x72, 0x69, 0x74, 0x79, 0x10, 0x03, 0x2a, 0x33, 0x0a, 0x08, 0x49, 0x50, 0x46, 0x61, 0x6d,
+	0x69, 0x6c, 0x79, 0x12, 0x13, 0x0a, 0x0f, 0x55, 0x6e, 0x6b, 0x6e, 0x6f, 0x77, 0x6e, 0x49, 0x50,
+	0x46, 0x61, 0x6d, 0x69, 0x6c, 0x79, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x49, 0x50, 0x76, 0x34,
+	0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x49, 0x50, 0x76, 0x36, 0x10, 0x02, 0x32, 0x37, 0x0a, 0x04,
+	0x53, 0x65, 0x74, 0x73, 0x12, 0x2f, 0x0a, 0x05, 0x57, 0x61, 0x74, 0x63, 0x68, 0x12, 0x11, 0x2e,
+	0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71,
+	0x1a, 0x0f, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x31, 0x2e, 0x4f, 0x70, 0x49, 0x74, 0x65,
+	0x6d, 0x28, 0x01, 0x30, 0x01, 0x42, 0x1e, 0x5a, 0x1c, 0x73, 0x69, 0x67, 0x73, 0x2e, 0x6b, 0x38,
+	0x73, 0x2e, 0x69, 0x6f, 0x2f, 0x6b, 0x70, 0x6e, 0x67, 0x2f, 0x61, 0x70, 0x69, 0x2f, 0x6c, 0x6f,
+	0x63, 0x61, 0x6c, 0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -1278,56 +1611,64 @@ func file_api_localv1_api_proto_rawDescGZIP() []byte {
 	return file_api_localv1_api_proto_rawDescData
 }
 
-var file_api_localv1_api_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_api_localv1_api_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
+var file_api_localv1_api_proto_enumTypes = make([]protoimpl.EnumInfo, 4)
+var file_api_localv1_api_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
 var file_api_localv1_api_proto_goTypes = []interface{}{
-	(Set)(0),                 // 0: localv1.Set
-	(Protocol)(0),            // 1: localv1.Protocol
-	(*WatchReq)(nil),         // 2: localv1.WatchReq
-	(*OpItem)(nil),           // 3: localv1.OpItem
-	(*EmptyOp)(nil),          // 4: localv1.EmptyOp
-	(*Ref)(nil),              // 5: localv1.Ref
-	(*Value)(nil),            // 6: localv1.Value
-	(*Service)(nil),          // 7: localv1.Service
-	(*IPFilter)(nil),         // 8: localv1.IPFilter
-	(*ServiceIPs)(nil),       // 9: localv1.ServiceIPs
-	(*Endpoint)(nil),         // 10: localv1.Endpoint
-	(*EndpointScopes)(nil),   // 11: localv1.EndpointScopes
-	(*IPSet)(nil),            // 12: localv1.IPSet
-	(*PortName)(nil),         // 13: localv1.PortName
-	(*PortMapping)(nil),      // 14: localv1.PortMapping
-	(*ClientIPAffinity)(nil), // 15: localv1.ClientIPAffinity
-	nil,                      // 16: localv1.Service.LabelsEntry
-	nil,                      // 17: localv1.Service.AnnotationsEntry
+	(Set)(0),                   // 0: localv1.Set
+	(Protocol)(0),              // 1: localv1.Protocol
+	(Priority)(0),              // 2: localv1.Priority
+	(IPFamily)(0),              // 3: localv1.IPFamily
+	(*WatchReq)(nil),           // 4: localv1.WatchReq
+	(*OpItem)(nil),             // 5: localv1.OpItem
+	(*EmptyOp)(nil),            // 6: localv1.EmptyOp
+	(*Ref)(nil),                // 7: localv1.Ref
+	(*Value)(nil),              // 8: localv1.Value
+	(*Service)(nil),            // 9: localv1.Service
+	(*IPFilter)(nil),           // 10: localv1.IPFilter
+	(*ServiceIPs)(nil),         // 11: localv1.ServiceIPs
+	(*Endpoint)(nil),           // 12: localv1.Endpoint
+	(*EndpointConditions)(nil), // 13: localv1.EndpointConditions
+	(*EndpointScopes)(nil),     // 14: localv1.EndpointScopes
+	(*IPSet)(nil),              // 15: localv1.IPSet
+	(*PortName)(nil),           // 16: localv1.PortName
+	(*PortMapping)(nil),        // 17: localv1.PortMapping
+	(*ClientIPAffinity)(nil),   // 18: localv1.ClientIPAffinity
+	nil,                        // 19: localv1.Service.LabelsEntry
+	nil,                        // 20: localv1.Service.AnnotationsEntry
 }
 var file_api_localv1_api_proto_depIdxs = []int32{
-	4,  // 0: localv1.OpItem.Sync:type_name -> localv1.EmptyOp
-	4,  // 1: localv1.OpItem.Reset:type_name -> localv1.EmptyOp
-	6,  // 2: localv1.OpItem.Set:type_name -> localv1.Value
-	5,  // 3: localv1.OpItem.Delete:type_name -> localv1.Ref
-	0,  // 4: localv1.Ref.Set:type_name -> localv1.Set
-	5,  // 5: localv1.Value.Ref:type_name -> localv1.Ref
-	16, // 6: localv1.Service.Labels:type_name -> localv1.Service.LabelsEntry
-	17, // 7: localv1.Service.Annotations:type_name -> localv1.Service.AnnotationsEntry
-	9,  // 8: localv1.Service.IPs:type_name -> localv1.ServiceIPs
-	8,  // 9: localv1.Service.IPFilters:type_name -> localv1.IPFilter
-	14, // 10: localv1.Service.Ports:type_name -> localv1.PortMapping
-	15, // 11: localv1.Service.ClientIP:type_name -> localv1.ClientIPAffinity
-	12, // 12: localv1.IPFilter.TargetIPs:type_name -> localv1.IPSet
-	12, // 13: localv1.ServiceIPs.ClusterIPs:type_name -> localv1.IPSet
-	12, // 14: localv1.ServiceIPs.ExternalIPs:type_name -> localv1.IPSet
-	12, // 15: localv1.ServiceIPs.LoadBalancerIPs:type_name -> localv1.IPSet
-	12, // 16: localv1.Endpoint.IPs:type_name -> localv1.IPSet
-	13, // 17: localv1.Endpoint.PortOverrides:type_name -> localv1.PortName
-	11, // 18: localv1.Endpoint.Scopes:type_name -> localv1.EndpointScopes
-	1,  // 19: localv1.PortMapping.Protocol:type_name -> localv1.Protocol
-	2,  // 20: localv1.Sets.Watch:input_type -> localv1.WatchReq
-	3,  // 21: localv1.Sets.Watch:output_type -> localv1.OpItem
-	21, // [21:22] is the sub-list for method output_type
-	20, // [20:21] is the sub-list for method input_type
-	20, // [20:20] is the sub-list for extension type_name
-	20, // [20:20] is the sub-list for extension extendee
-	0,  // [0:20] is the sub-list for field type_name
+	3,  // 0: localv1.WatchReq.IPFamilies:type_name -> localv1.IPFamily
+	6,  // 1: localv1.OpItem.Sync:type_name -> localv1.EmptyOp
+	6,  // 2: localv1.OpItem.Reset:type_name -> localv1.EmptyOp
+	6,  // 3: localv1.OpItem.InitialSync:type_name -> localv1.EmptyOp
+	8,  // 4: localv1.OpItem.Set:type_name -> localv1.Value
+	7,  // 5: localv1.OpItem.Delete:type_name -> localv1.Ref
+	0,  // 6: localv1.Ref.Set:type_name -> localv1.Set
+	7,  // 7: localv1.Value.Ref:type_name -> localv1.Ref
+	19, // 8: localv1.Service.Labels:type_name -> localv1.Service.LabelsEntry
+	20, // 9: localv1.Service.Annotations:type_name -> localv1.Service.AnnotationsEntry
+	11, // 10: localv1.Service.IPs:type_name -> localv1.ServiceIPs
+	10, // 11: localv1.Service.IPFilters:type_name -> localv1.IPFilter
+	17, // 12: localv1.Service.Ports:type_name -> localv1.PortMapping
+	18, // 13: localv1.Service.ClientIP:type_name -> localv1.ClientIPAffinity
+	2,  // 14: localv1.Service.Priority:type_name -> localv1.Priority
+	15, // 15: localv1.IPFilter.TargetIPs:type_name -> localv1.IPSet
+	15, // 16: localv1.ServiceIPs.ClusterIPs:type_name -> localv1.IPSet
+	15, // 17: localv1.ServiceIPs.ExternalIPs:type_name -> localv1.IPSet
+	15, // 18: localv1.ServiceIPs.LoadBalancerIPs:type_name -> localv1.IPSet
+	15, // 19: localv1.ServiceIPs.ProxyLoadBalancerIPs:type_name -> localv1.IPSet
+	15, // 20: localv1.Endpoint.IPs:type_name -> localv1.IPSet
+	16, // 21: localv1.Endpoint.PortOverrides:type_name -> localv1.PortName
+	14, // 22: localv1.Endpoint.Scopes:type_name -> localv1.EndpointScopes
+	13, // 23: localv1.Endpoint.Conditions:type_name -> localv1.EndpointConditions
+	1,  // 24: localv1.PortMapping.Protocol:type_name -> localv1.Protocol
+	4,  // 25: localv1.Sets.Watch:input_type -> localv1.WatchReq
+	5,  // 26: localv1.Sets.Watch:output_type -> localv1.OpItem
+	26, // [26:27] is the sub-list for method output_type
+	25, // [25:26] is the sub-list for method input_type
+	25, // [25:25] is the sub-list for extension type_name
+	25, // [25:25] is the sub-list for extension extendee
+	0,  // [0:25] is the sub-list for field type_name
 }
 
 func init() { file_api_localv1_api_proto_init() }
@@ -1445,7 +1786,7 @@ func file_api_localv1_api_proto_init() {
 			}
 		}
 		file_api_localv1_api_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*EndpointScopes); i {
+			switch v := v.(*EndpointConditions); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1457,7 +1798,7 @@ func file_api_localv1_api_proto_init() {
 			}
 		}
 		file_api_localv1_api_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*IPSet); i {
+			switch v := v.(*EndpointScopes); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1469,7 +1810,7 @@ func file_api_localv1_api_proto_init() {
 			}
 		}
 		file_api_localv1_api_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*PortName); i {
+			switch v := v.(*IPSet); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1481,7 +1822,7 @@ func file_api_localv1_api_proto_init() {
 			}
 		}
 		file_api_localv1_api_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*PortMapping); i {
+			switch v := v.(*PortName); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1493,6 +1834,18 @@ func file_api_localv1_api_proto_init() {
 			}
 		}
 		file_api_localv1_api_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PortMapping); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv1_api_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*ClientIPAffinity); i {
 			case 0:
 				return &v.state
@@ -1508,6 +1861,7 @@ func file_api_localv1_api_proto_init() {
 	file_api_localv1_api_proto_msgTypes[1].OneofWrappers = []interface{}{
 		(*OpItem_Sync)(nil),
 		(*OpItem_Reset_)(nil),
+		(*OpItem_InitialSync)(nil),
 		(*OpItem_Set)(nil),
 		(*OpItem_Delete)(nil),
 	}
@@ -1519,8 +1873,8 @@ func file_api_localv1_api_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_api_localv1_api_proto_rawDesc,
-			NumEnums:      2,
-			NumMessages:   16,
+			NumEnums:      4,
+			NumMessages:   17,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
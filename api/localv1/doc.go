@@ -0,0 +1,22 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package localv1 is the v1 of the local API, the state of a node sent to the backends.
+//
+// It's superseded by localv2, which new consumers should use. The server still serves it for the
+// existing backends, and localv2.OpItemToV1 lets them consume a localv2 stream while they move.
+// It will be deprecated once the backends of this repository are on localv2.
+package localv1
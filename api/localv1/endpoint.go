@@ -29,17 +29,16 @@ func (ep *Endpoint) AddAddress(s string) (ip net.IP) {
 	return ep.IPs.Add(s)
 }
 
+// PortMapping returns the port of the endpoint for a service port: the port of the same name in
+// the endpoint's EndpointSlice, like kube-proxy (the slices of the services without selector are
+// not bound to the target ports), or the service's target port.
 func (ep *Endpoint) PortMapping(port *PortMapping) (target int32) {
-	target = port.TargetPort
-	if port.TargetPortName != "" {
-		for _, override := range ep.PortOverrides {
-			if override.Name == port.Name {
-				target = override.Port
-				break
-			}
+	for _, override := range ep.PortOverrides {
+		if override.Name == port.Name {
+			return override.Port
 		}
 	}
-	return
+	return port.TargetPort
 }
 
 func (ep *Endpoint) PortMappings(ports []*PortMapping) (mapping map[int32]int32) {
@@ -56,3 +55,17 @@ func (ep *Endpoint) PortNameMappings(ports []*PortMapping) (mapping map[string]i
 	}
 	return
 }
+
+// WeightOr returns the weight of this endpoint, or def if it's not set.
+func (ep *Endpoint) WeightOr(def int32) int32 {
+	if ep.GetWeight() > 0 {
+		return ep.Weight
+	}
+	return def
+}
+
+// IsReady returns true if the endpoint is ready: the endpoints without conditions are, as only the
+// ready endpoints are sent unless the terminating ones are requested.
+func (ep *Endpoint) IsReady() bool {
+	return ep.GetConditions() == nil || ep.Conditions.Ready
+}
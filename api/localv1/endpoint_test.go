@@ -23,12 +23,14 @@ func ExampleEndpointPortMapping() {
 		{Name: "http", TargetPortName: "t-http", TargetPort: 8080},
 		{Name: "http2", TargetPortName: "t-http2", TargetPort: 800},
 		{Name: "metrics", TargetPortName: "t-metrics"},
+		{Name: "custom", TargetPort: 8080},
 	}
 
 	ep := &Endpoint{
 		PortOverrides: []*PortName{
 			{Name: "metrics", Port: 1011},
 			{Name: "http2", Port: 888},
+			{Name: "custom", Port: 9090},
 		},
 	}
 
@@ -40,4 +42,12 @@ func ExampleEndpointPortMapping() {
 	// http 8080
 	// http2 888
 	// metrics 1011
+	// custom 9090
+}
+
+func ExampleEndpoint_WeightOr() {
+	fmt.Println((&Endpoint{}).WeightOr(1), (&Endpoint{Weight: 5}).WeightOr(1))
+
+	// Output:
+	// 1 5
 }
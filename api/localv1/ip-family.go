@@ -0,0 +1,137 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package localv1
+
+import (
+	"net"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// IPFamilyOf returns the family of an IP or CIDR, UnknownIPFamily if it can't be parsed.
+func IPFamilyOf(s string) IPFamily {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		var err error
+		if ip, _, err = net.ParseCIDR(s); err != nil {
+			return IPFamily_UnknownIPFamily
+		}
+	}
+
+	if ip.To4() != nil {
+		return IPFamily_IPv4
+	}
+	return IPFamily_IPv6
+}
+
+func hasIPFamily(families []IPFamily, family IPFamily) bool {
+	for _, f := range families {
+		if f == family {
+			return true
+		}
+	}
+	return false
+}
+
+// ForIPFamilies returns the IPs of the set in the given families, or the set itself if they
+// include all its IPs.
+func (set *IPSet) ForIPFamilies(families ...IPFamily) *IPSet {
+	if set == nil {
+		return nil
+	}
+
+	v4 := hasIPFamily(families, IPFamily_IPv4)
+	v6 := hasIPFamily(families, IPFamily_IPv6)
+	if (v4 || len(set.V4) == 0) && (v6 || len(set.V6) == 0) {
+		return set
+	}
+
+	pruned := &IPSet{}
+	if v4 {
+		pruned.V4 = set.V4
+	}
+	if v6 {
+		pruned.V6 = set.V6
+	}
+	return pruned
+}
+
+// ForIPFamilies returns the service with only the IPs and source ranges of the given families (all
+// if none is given), or nil if it has no cluster IP left while it had some. The service is not
+// changed, a copy is returned if needed.
+func (s *Service) ForIPFamilies(families ...IPFamily) *Service {
+	if len(families) == 0 || s.IPs == nil {
+		return s
+	}
+
+	if clusterIPs := s.IPs.ClusterIPs.ForIPFamilies(families...); !s.IPs.ClusterIPs.IsEmpty() && clusterIPs.IsEmpty() {
+		return nil
+	}
+
+	pruned := proto.Clone(s).(*Service)
+	ips := pruned.IPs
+	ips.ClusterIPs = ips.ClusterIPs.ForIPFamilies(families...)
+	ips.ExternalIPs = ips.ExternalIPs.ForIPFamilies(families...)
+	ips.LoadBalancerIPs = ips.LoadBalancerIPs.ForIPFamilies(families...)
+	ips.ProxyLoadBalancerIPs = ips.ProxyLoadBalancerIPs.ForIPFamilies(families...)
+
+	// like kube-proxy, the source ranges of the other families are ignored
+	filters := pruned.IPFilters
+	pruned.IPFilters = nil
+	for _, filter := range filters {
+		targetIPs := filter.TargetIPs.ForIPFamilies(families...)
+		if !filter.TargetIPs.IsEmpty() && targetIPs.IsEmpty() {
+			continue
+		}
+
+		sourceRanges := make([]string, 0, len(filter.SourceRanges))
+		for _, cidr := range filter.SourceRanges {
+			if hasIPFamily(families, IPFamilyOf(cidr)) {
+				sourceRanges = append(sourceRanges, cidr)
+			}
+		}
+		if len(filter.SourceRanges) != 0 && len(sourceRanges) == 0 {
+			continue
+		}
+
+		pruned.IPFilters = append(pruned.IPFilters, &IPFilter{TargetIPs: targetIPs, SourceRanges: sourceRanges})
+	}
+
+	if proto.Equal(s, pruned) {
+		return s
+	}
+	return pruned
+}
+
+// ForIPFamilies returns the endpoint with only the IPs of the given families (all if none is
+// given), or nil if it has none left. The endpoint is not changed, a copy is returned if needed.
+func (ep *Endpoint) ForIPFamilies(families ...IPFamily) *Endpoint {
+	if len(families) == 0 {
+		return ep
+	}
+
+	if ep.IPs.ForIPFamilies(families...) == ep.IPs {
+		return ep
+	}
+
+	pruned := proto.Clone(ep).(*Endpoint)
+	pruned.IPs = pruned.IPs.ForIPFamilies(families...)
+	if pruned.IPs.IsEmpty() {
+		return nil
+	}
+	return pruned
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package localv1
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestServiceForIPFamilies(t *testing.T) {
+	dualStack := &Service{
+		Namespace: "ns",
+		Name:      "svc",
+		IPs: &ServiceIPs{
+			ClusterIPs:      NewIPSet("10.96.0.10", "fd00::10"),
+			ExternalIPs:     NewIPSet("192.0.2.1", "2001:db8::1"),
+			LoadBalancerIPs: NewIPSet("2001:db8::2"),
+		},
+		IPFilters: []*IPFilter{
+			{TargetIPs: NewIPSet("2001:db8::2"), SourceRanges: []string{"2001:db8:1::/48"}},
+			{SourceRanges: []string{"192.0.2.0/24", "2001:db8:1::/48"}},
+		},
+	}
+	before := proto.Clone(dualStack)
+
+	if svc := dualStack.ForIPFamilies(); svc != dualStack {
+		t.Errorf("expected the service itself for all the families, got %v", svc)
+	}
+
+	expected := &Service{
+		Namespace: "ns",
+		Name:      "svc",
+		IPs: &ServiceIPs{
+			ClusterIPs:      NewIPSet("10.96.0.10"),
+			ExternalIPs:     NewIPSet("192.0.2.1"),
+			LoadBalancerIPs: NewIPSet(),
+		},
+		IPFilters: []*IPFilter{
+			{SourceRanges: []string{"192.0.2.0/24"}},
+		},
+	}
+	if svc := dualStack.ForIPFamilies(IPFamily_IPv4); !proto.Equal(svc, expected) {
+		t.Errorf("IPv4: expected %v, got %v", expected, svc)
+	}
+
+	if !proto.Equal(dualStack, before) {
+		t.Errorf("the service was changed: %v", dualStack)
+	}
+
+	v6Only := &Service{Namespace: "ns", Name: "v6", IPs: &ServiceIPs{ClusterIPs: NewIPSet("fd00::11")}}
+	if svc := v6Only.ForIPFamilies(IPFamily_IPv4); svc != nil {
+		t.Errorf("expected an IPv6 service to be withheld from IPv4, got %v", svc)
+	}
+	if svc := v6Only.ForIPFamilies(IPFamily_IPv6); svc != v6Only {
+		t.Errorf("expected the service itself for its family, got %v", svc)
+	}
+
+	headless := &Service{Namespace: "ns", Name: "headless", IPs: &ServiceIPs{ClusterIPs: NewIPSet(), Headless: true}}
+	if svc := headless.ForIPFamilies(IPFamily_IPv4); svc != headless {
+		t.Errorf("expected a headless service to be kept, got %v", svc)
+	}
+}
+
+func TestEndpointForIPFamilies(t *testing.T) {
+	ep := &Endpoint{Hostname: "a", IPs: NewIPSet("10.1.0.1", "fd00:1::1")}
+
+	if pruned := ep.ForIPFamilies(IPFamily_IPv4, IPFamily_IPv6); pruned != ep {
+		t.Errorf("expected the endpoint itself, got %v", pruned)
+	}
+	if pruned := ep.ForIPFamilies(IPFamily_IPv6); !proto.Equal(pruned, &Endpoint{Hostname: "a", IPs: NewIPSet("fd00:1::1")}) {
+		t.Errorf("unexpected IPv6 endpoint: %v", pruned)
+	}
+	if len(ep.IPs.V4) != 1 {
+		t.Errorf("the endpoint was changed: %v", ep)
+	}
+
+	if pruned := (&Endpoint{IPs: NewIPSet("fd00:1::2")}).ForIPFamilies(IPFamily_IPv4); pruned != nil {
+		t.Errorf("expected an IPv6 endpoint to be withheld from IPv4, got %v", pruned)
+	}
+}
@@ -28,7 +28,7 @@ func NewIPSet(ips ...string) (set *IPSet) {
 }
 
 func (set *IPSet) IsEmpty() bool {
-	return len(set.V4) == 0 && len(set.V6) == 0
+	return len(set.GetV4()) == 0 && len(set.GetV6()) == 0
 }
 
 func (set *IPSet) First() string {
@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package localv1
+
+import "strings"
+
+// priorityClasses are the names of the priority classes (ie: in annotations and metric labels).
+var priorityClasses = map[Priority]string{
+	Priority_LowPriority:      "low",
+	Priority_NormalPriority:   "normal",
+	Priority_HighPriority:     "high",
+	Priority_CriticalPriority: "critical",
+}
+
+// ParsePriority returns the priority of a class name, false if unknown.
+func ParsePriority(class string) (Priority, bool) {
+	class = strings.ToLower(strings.TrimSpace(class))
+	for p, name := range priorityClasses {
+		if name == class {
+			return p, true
+		}
+	}
+	return Priority_NormalPriority, false
+}
+
+// Class returns the name of the priority class.
+func (p Priority) Class() string {
+	if class, ok := priorityClasses[p]; ok {
+		return class
+	}
+	return priorityClasses[Priority_NormalPriority]
+}
+
+// Rank orders the priority classes: the services of the highest rank are programmed first.
+func (p Priority) Rank() int {
+	switch p {
+	case Priority_LowPriority:
+		return 0
+	case Priority_HighPriority:
+		return 2
+	case Priority_CriticalPriority:
+		return 3
+	}
+	return 1
+}
+
+// ProgrammedBefore returns true if s is programmed before other: it's of a higher priority class,
+// or of the same one and before it by namespace and name.
+func (s *Service) ProgrammedBefore(other *Service) bool {
+	if r, o := s.GetPriority().Rank(), other.GetPriority().Rank(); r != o {
+		return r > o
+	}
+	return s.NamespacedName() < other.NamespacedName()
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package localv1
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestParsePriority(t *testing.T) {
+	for class, expected := range map[string]Priority{
+		"critical": Priority_CriticalPriority,
+		" High":    Priority_HighPriority,
+		"normal":   Priority_NormalPriority,
+		"low":      Priority_LowPriority,
+	} {
+		p, ok := ParsePriority(class)
+		if !ok || p != expected {
+			t.Errorf("%q: expected %v, got %v (%t)", class, expected, p, ok)
+		}
+		if back, _ := ParsePriority(p.Class()); back != p {
+			t.Errorf("%q: class %q parsed as %v", class, p.Class(), back)
+		}
+	}
+
+	if _, ok := ParsePriority("urgent"); ok {
+		t.Error("unknown class parsed")
+	}
+}
+
+func TestProgrammedBefore(t *testing.T) {
+	services := []*Service{
+		{Namespace: "default", Name: "b"},
+		{Namespace: "default", Name: "low", Priority: Priority_LowPriority},
+		{Namespace: "kube-system", Name: "kube-dns", Priority: Priority_CriticalPriority},
+		{Namespace: "default", Name: "a"},
+		{Namespace: "ingress", Name: "nginx", Priority: Priority_HighPriority},
+	}
+
+	sort.Slice(services, func(i, j int) bool { return services[i].ProgrammedBefore(services[j]) })
+
+	expected := []string{"kube-system/kube-dns", "ingress/nginx", "default/a", "default/b", "default/low"}
+	for i, svc := range services {
+		if svc.NamespacedName() != expected[i] {
+			t.Errorf("%d: expected %s, got %s", i, expected[i], svc.NamespacedName())
+		}
+	}
+}
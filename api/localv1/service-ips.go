@@ -30,3 +30,14 @@ func (s *ServiceIPs) AllIngress() (all *IPSet) {
 	all.AddSet(s.LoadBalancerIPs)
 	return
 }
+
+// LoadBalancerVIPs returns the LoadBalancerIPs the backends write rules for, ie: the ones without
+// the Proxy ipMode.
+func (s *ServiceIPs) LoadBalancerVIPs() *IPSet {
+	if s.GetLoadBalancerIPs() == nil || s.ProxyLoadBalancerIPs == nil || s.ProxyLoadBalancerIPs.IsEmpty() {
+		return s.GetLoadBalancerIPs()
+	}
+
+	vips, _ := s.ProxyLoadBalancerIPs.Diff(s.GetLoadBalancerIPs())
+	return vips
+}
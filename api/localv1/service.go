@@ -16,6 +16,43 @@ limitations under the License.
 
 package localv1
 
+import "google.golang.org/protobuf/proto"
+
 func (s *Service) NamespacedName() string {
 	return s.Namespace + "/" + s.Name
 }
+
+// HasNodePort returns true if the port is exposed on the node ports of a NodePort or LoadBalancer
+// service. The LoadBalancer services with NoLoadBalancerNodePorts only expose the ports with a
+// NodePort set explicitly (the API server keeps them), so the ports without NodePort are never
+// exposed, whatever the type.
+func (s *Service) HasNodePort(port *PortMapping) bool {
+	if port.NodePort == 0 {
+		return false
+	}
+	switch s.Type {
+	case "NodePort", "LoadBalancer":
+		return true
+	}
+	return false
+}
+
+// WithExposedNodePorts returns the service with the NodePort of the ports not exposed on the node
+// ports cleared (see HasNodePort), for the backends reading the NodePort of the ports directly. The
+// service is copied if any is cleared.
+func (s *Service) WithExposedNodePorts() *Service {
+	for i, port := range s.Ports {
+		if port.NodePort == 0 || s.HasNodePort(port) {
+			continue
+		}
+
+		svc := proto.Clone(s).(*Service)
+		for _, port := range svc.Ports[i:] {
+			if !svc.HasNodePort(port) {
+				port.NodePort = 0
+			}
+		}
+		return svc
+	}
+	return s
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package localv1
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/golang/protobuf/proto"
@@ -42,3 +43,58 @@ func TestHashService(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestServiceHasNodePort(t *testing.T) {
+	for _, test := range []struct {
+		svc      *Service
+		nodePort int32
+		expected bool
+	}{
+		{&Service{Type: "ClusterIP"}, 0, false},
+		{&Service{Type: "ClusterIP"}, 30080, false},
+		{&Service{Type: "NodePort"}, 30080, true},
+		{&Service{Type: "LoadBalancer"}, 30080, true},
+		{&Service{Type: "LoadBalancer", NoLoadBalancerNodePorts: true}, 0, false},
+		{&Service{Type: "LoadBalancer", NoLoadBalancerNodePorts: true}, 30080, true},
+	} {
+		if has := test.svc.HasNodePort(&PortMapping{Port: 80, NodePort: test.nodePort}); has != test.expected {
+			t.Errorf("%s service with node port %d: expected %v, got %v", test.svc.Type, test.nodePort, test.expected, has)
+		}
+	}
+}
+
+func TestServiceWithExposedNodePorts(t *testing.T) {
+	svc := &Service{Type: "LoadBalancer", NoLoadBalancerNodePorts: true, Ports: []*PortMapping{{Port: 80, NodePort: 30080}, {Port: 443}}}
+	if svc.WithExposedNodePorts() != svc {
+		t.Error("expected the service with only exposed node ports not to be copied")
+	}
+
+	svc = &Service{Type: "ClusterIP", Ports: []*PortMapping{{Port: 80}, {Port: 443, NodePort: 30443}}}
+	exposed := svc.WithExposedNodePorts()
+	if exposed == svc {
+		t.Fatal("expected the service to be copied")
+	}
+	if exposed.Ports[1].NodePort != 0 {
+		t.Errorf("expected the node port of a ClusterIP service to be cleared, got %d", exposed.Ports[1].NodePort)
+	}
+	if svc.Ports[1].NodePort != 30443 {
+		t.Error("the original service was modified")
+	}
+}
+
+func TestLoadBalancerVIPs(t *testing.T) {
+	for _, test := range []struct {
+		ips      *ServiceIPs
+		expected []string
+	}{
+		{&ServiceIPs{}, nil},
+		{&ServiceIPs{LoadBalancerIPs: NewIPSet("1.2.3.4", "fd00::4")}, []string{"1.2.3.4", "fd00::4"}},
+		{&ServiceIPs{LoadBalancerIPs: NewIPSet("1.2.3.4", "1.2.3.5"), ProxyLoadBalancerIPs: NewIPSet("1.2.3.5")}, []string{"1.2.3.4"}},
+		{&ServiceIPs{LoadBalancerIPs: NewIPSet("1.2.3.4", "fd00::4"), ProxyLoadBalancerIPs: NewIPSet("1.2.3.4", "fd00::4")}, nil},
+		{&ServiceIPs{ProxyLoadBalancerIPs: NewIPSet("1.2.3.4")}, nil},
+	} {
+		if vips := test.ips.LoadBalancerVIPs().All(); !reflect.DeepEqual(vips, test.expected) && len(vips)+len(test.expected) != 0 {
+			t.Errorf("%v: expected VIPs %v, got %v", test.ips, test.expected, vips)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package localv1
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// FieldError is a problem found on a field of a message.
+type FieldError struct {
+	// Field is the path of the field, like "Ports[1].Protocol".
+	Field  string
+	Reason string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Reason
+}
+
+// ValidationError lists the problems found on a message.
+type ValidationError struct {
+	// Kind of the message ("service", "endpoint").
+	Kind string
+	// Name of the message, if known.
+	Name   string
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		fields = append(fields, f.Error())
+	}
+	return fmt.Sprintf("invalid %s %q: %s", e.Kind, e.Name, strings.Join(fields, ", "))
+}
+
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Reason: fmt.Sprintf(format, args...)})
+}
+
+func (e *ValidationError) orNil() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Validate checks the service can be handled by the backends, returning a *ValidationError if not.
+func (s *Service) Validate() error {
+	if s == nil {
+		return &ValidationError{Kind: "service", Fields: []FieldError{{Field: "", Reason: "is nil"}}}
+	}
+
+	e := &ValidationError{Kind: "service", Name: s.NamespacedName()}
+
+	if s.Namespace == "" {
+		e.add("Namespace", "is empty")
+	}
+	if s.Name == "" {
+		e.add("Name", "is empty")
+	}
+
+	if s.IPs == nil {
+		e.add("IPs", "is nil")
+	} else {
+		if s.IPs.ClusterIPs == nil {
+			e.add("IPs.ClusterIPs", "is nil")
+		} else if s.IPs.ClusterIPs.IsEmpty() && !s.IPs.Headless && s.Type != "ExternalName" {
+			e.add("IPs.ClusterIPs", "is empty on a service that is not headless")
+		}
+
+		validateIPSet(e, "IPs.ClusterIPs", s.IPs.ClusterIPs)
+		validateIPSet(e, "IPs.ExternalIPs", s.IPs.ExternalIPs)
+		validateIPSet(e, "IPs.LoadBalancerIPs", s.IPs.LoadBalancerIPs)
+		validateIPSet(e, "IPs.ProxyLoadBalancerIPs", s.IPs.ProxyLoadBalancerIPs)
+	}
+
+	names := make(map[string]bool, len(s.Ports))
+	for i, port := range s.Ports {
+		field := fmt.Sprint("Ports[", i, "]")
+
+		if port == nil {
+			e.add(field, "is nil")
+			continue
+		}
+
+		if names[port.Name] {
+			e.add(field+".Name", "duplicate name %q", port.Name)
+		}
+		names[port.Name] = true
+
+		switch port.Protocol {
+		case Protocol_TCP, Protocol_UDP, Protocol_SCTP:
+		default:
+			e.add(field+".Protocol", "invalid protocol %v", port.Protocol)
+		}
+
+		if port.Port <= 0 || port.Port > 65535 {
+			e.add(field+".Port", "out of range: %d", port.Port)
+		}
+		if port.NodePort < 0 || port.NodePort > 65535 {
+			e.add(field+".NodePort", "out of range: %d", port.NodePort)
+		}
+		if port.TargetPort < 0 || port.TargetPort > 65535 {
+			e.add(field+".TargetPort", "out of range: %d", port.TargetPort)
+		}
+	}
+
+	return e.orNil()
+}
+
+// Validate checks the endpoint can be handled by the backends, returning a *ValidationError if not.
+func (ep *Endpoint) Validate() error {
+	if ep == nil {
+		return &ValidationError{Kind: "endpoint", Fields: []FieldError{{Field: "", Reason: "is nil"}}}
+	}
+
+	e := &ValidationError{Kind: "endpoint", Name: ep.Hostname}
+
+	if ep.IPs == nil || ep.IPs.IsEmpty() {
+		e.add("IPs", "is empty")
+	}
+	validateIPSet(e, "IPs", ep.IPs)
+
+	for i, override := range ep.PortOverrides {
+		if override == nil {
+			e.add(fmt.Sprint("PortOverrides[", i, "]"), "is nil")
+		}
+	}
+
+	return e.orNil()
+}
+
+// validateIPSet checks the addresses of a set are valid and in the right family.
+func validateIPSet(e *ValidationError, field string, set *IPSet) {
+	for i, s := range set.GetV4() {
+		if ip := net.ParseIP(s); ip == nil || ip.To4() == nil {
+			e.add(fmt.Sprint(field, ".V4[", i, "]"), "invalid IPv4 address %q", s)
+		}
+	}
+	for i, s := range set.GetV6() {
+		if ip := net.ParseIP(s); ip == nil || ip.To4() != nil {
+			e.add(fmt.Sprint(field, ".V6[", i, "]"), "invalid IPv6 address %q", s)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package localv1
+
+import (
+	"errors"
+	"testing"
+)
+
+func validService() *Service {
+	return &Service{
+		Namespace: "ns",
+		Name:      "svc",
+		IPs: &ServiceIPs{
+			ClusterIPs: NewIPSet("10.0.0.1", "fd00::1"),
+		},
+		Ports: []*PortMapping{
+			{Name: "http", Protocol: Protocol_TCP, Port: 80, TargetPort: 8080},
+			{Name: "dns", Protocol: Protocol_UDP, Port: 53, TargetPortName: "dns"},
+		},
+	}
+}
+
+func TestValidateService(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		mutate func(s *Service)
+		fields []string
+	}{
+		{"valid", func(s *Service) {}, nil},
+		{"headless", func(s *Service) { s.IPs.ClusterIPs = &IPSet{}; s.IPs.Headless = true }, nil},
+		{"external name", func(s *Service) { s.IPs.ClusterIPs = &IPSet{}; s.Type = "ExternalName" }, nil},
+		{"no name", func(s *Service) { s.Namespace, s.Name = "", "" }, []string{"Namespace", "Name"}},
+		{"nil IPs", func(s *Service) { s.IPs = nil }, []string{"IPs"}},
+		{"nil cluster IPs", func(s *Service) { s.IPs.ClusterIPs = nil }, []string{"IPs.ClusterIPs"}},
+		{"empty cluster IPs", func(s *Service) { s.IPs.ClusterIPs = &IPSet{} }, []string{"IPs.ClusterIPs"}},
+		{"bad IPs", func(s *Service) {
+			s.IPs.ClusterIPs = &IPSet{V4: []string{"fd00::1"}, V6: []string{"bad"}}
+		}, []string{"IPs.ClusterIPs.V4[0]", "IPs.ClusterIPs.V6[0]"}},
+		{"duplicate port name", func(s *Service) { s.Ports[1].Name = "http" }, []string{"Ports[1].Name"}},
+		{"invalid protocol", func(s *Service) { s.Ports[0].Protocol = Protocol_UnknownProtocol }, []string{"Ports[0].Protocol"}},
+		{"port out of range", func(s *Service) { s.Ports[0].Port = 0; s.Ports[1].NodePort = 70000 }, []string{"Ports[0].Port", "Ports[1].NodePort"}},
+		{"nil port", func(s *Service) { s.Ports[1] = nil }, []string{"Ports[1]"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := validService()
+			tc.mutate(svc)
+
+			err := svc.Validate()
+			if tc.fields == nil {
+				if err != nil {
+					t.Fatal("unexpected error: ", err)
+				}
+				return
+			}
+
+			verr := &ValidationError{}
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected a validation error, got %v", err)
+			}
+
+			if len(verr.Fields) != len(tc.fields) {
+				t.Fatalf("expected errors on %v, got %v", tc.fields, err)
+			}
+			for i, field := range tc.fields {
+				if verr.Fields[i].Field != field {
+					t.Errorf("expected an error on %s, got %v", field, verr.Fields[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	ep := &Endpoint{}
+	if ep.Validate() == nil {
+		t.Error("expected an endpoint without IPs to be invalid")
+	}
+
+	ep.AddAddress("10.1.0.1")
+	if err := ep.Validate(); err != nil {
+		t.Error("unexpected error: ", err)
+	}
+}
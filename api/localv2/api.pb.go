@@ -0,0 +1,2385 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.1
+// 	protoc        v3.18.1
+// source: api/localv2/api.proto
+
+package localv2
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Version of the local API.
+type Version int32
+
+const (
+	Version_UnknownVersion Version = 0
+	Version_V1             Version = 1
+	Version_V2             Version = 2
+)
+
+// Enum value maps for Version.
+var (
+	Version_name = map[int32]string{
+		0: "UnknownVersion",
+		1: "V1",
+		2: "V2",
+	}
+	Version_value = map[string]int32{
+		"UnknownVersion": 0,
+		"V1":             1,
+		"V2":             2,
+	}
+)
+
+func (x Version) Enum() *Version {
+	p := new(Version)
+	*p = x
+	return p
+}
+
+func (x Version) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Version) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_localv2_api_proto_enumTypes[0].Descriptor()
+}
+
+func (Version) Type() protoreflect.EnumType {
+	return &file_api_localv2_api_proto_enumTypes[0]
+}
+
+func (x Version) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Version.Descriptor instead.
+func (Version) EnumDescriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{0}
+}
+
+// Capability is an optional feature of the local API, sent only to the clients supporting it.
+type Capability int32
+
+const (
+	Capability_UnknownCapability Capability = 0
+	// Endpoints have their Conditions set.
+	Capability_WithEndpointConditions Capability = 1
+	// Endpoints have their Weight set.
+	Capability_WithEndpointWeights Capability = 2
+)
+
+// Enum value maps for Capability.
+var (
+	Capability_name = map[int32]string{
+		0: "UnknownCapability",
+		1: "WithEndpointConditions",
+		2: "WithEndpointWeights",
+	}
+	Capability_value = map[string]int32{
+		"UnknownCapability":      0,
+		"WithEndpointConditions": 1,
+		"WithEndpointWeights":    2,
+	}
+)
+
+func (x Capability) Enum() *Capability {
+	p := new(Capability)
+	*p = x
+	return p
+}
+
+func (x Capability) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Capability) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_localv2_api_proto_enumTypes[1].Descriptor()
+}
+
+func (Capability) Type() protoreflect.EnumType {
+	return &file_api_localv2_api_proto_enumTypes[1]
+}
+
+func (x Capability) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Capability.Descriptor instead.
+func (Capability) EnumDescriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{1}
+}
+
+type Set int32
+
+const (
+	Set_UnknownSet   Set = 0
+	Set_ServicesSet  Set = 1
+	Set_EndpointsSet Set = 2
+)
+
+// Enum value maps for Set.
+var (
+	Set_name = map[int32]string{
+		0: "UnknownSet",
+		1: "ServicesSet",
+		2: "EndpointsSet",
+	}
+	Set_value = map[string]int32{
+		"UnknownSet":   0,
+		"ServicesSet":  1,
+		"EndpointsSet": 2,
+	}
+)
+
+func (x Set) Enum() *Set {
+	p := new(Set)
+	*p = x
+	return p
+}
+
+func (x Set) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Set) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_localv2_api_proto_enumTypes[2].Descriptor()
+}
+
+func (Set) Type() protoreflect.EnumType {
+	return &file_api_localv2_api_proto_enumTypes[2]
+}
+
+func (x Set) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Set.Descriptor instead.
+func (Set) EnumDescriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{2}
+}
+
+type ServiceType int32
+
+const (
+	ServiceType_UnknownServiceType ServiceType = 0
+	ServiceType_ClusterIP          ServiceType = 1
+	ServiceType_NodePort           ServiceType = 2
+	ServiceType_LoadBalancer       ServiceType = 3
+	ServiceType_ExternalName       ServiceType = 4
+)
+
+// Enum value maps for ServiceType.
+var (
+	ServiceType_name = map[int32]string{
+		0: "UnknownServiceType",
+		1: "ClusterIP",
+		2: "NodePort",
+		3: "LoadBalancer",
+		4: "ExternalName",
+	}
+	ServiceType_value = map[string]int32{
+		"UnknownServiceType": 0,
+		"ClusterIP":          1,
+		"NodePort":           2,
+		"LoadBalancer":       3,
+		"ExternalName":       4,
+	}
+)
+
+func (x ServiceType) Enum() *ServiceType {
+	p := new(ServiceType)
+	*p = x
+	return p
+}
+
+func (x ServiceType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ServiceType) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_localv2_api_proto_enumTypes[3].Descriptor()
+}
+
+func (ServiceType) Type() protoreflect.EnumType {
+	return &file_api_localv2_api_proto_enumTypes[3]
+}
+
+func (x ServiceType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ServiceType.Descriptor instead.
+func (ServiceType) EnumDescriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{3}
+}
+
+type IPFamily int32
+
+const (
+	IPFamily_UnknownIPFamily IPFamily = 0
+	IPFamily_IPv4            IPFamily = 1
+	IPFamily_IPv6            IPFamily = 2
+)
+
+// Enum value maps for IPFamily.
+var (
+	IPFamily_name = map[int32]string{
+		0: "UnknownIPFamily",
+		1: "IPv4",
+		2: "IPv6",
+	}
+	IPFamily_value = map[string]int32{
+		"UnknownIPFamily": 0,
+		"IPv4":            1,
+		"IPv6":            2,
+	}
+)
+
+func (x IPFamily) Enum() *IPFamily {
+	p := new(IPFamily)
+	*p = x
+	return p
+}
+
+func (x IPFamily) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (IPFamily) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_localv2_api_proto_enumTypes[4].Descriptor()
+}
+
+func (IPFamily) Type() protoreflect.EnumType {
+	return &file_api_localv2_api_proto_enumTypes[4]
+}
+
+func (x IPFamily) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use IPFamily.Descriptor instead.
+func (IPFamily) EnumDescriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{4}
+}
+
+type TrafficPolicy int32
+
+const (
+	// Cluster routes the traffic to all the endpoints (the default).
+	TrafficPolicy_Cluster TrafficPolicy = 0
+	// Local routes the traffic to the endpoints of the node only.
+	TrafficPolicy_Local TrafficPolicy = 1
+)
+
+// Enum value maps for TrafficPolicy.
+var (
+	TrafficPolicy_name = map[int32]string{
+		0: "Cluster",
+		1: "Local",
+	}
+	TrafficPolicy_value = map[string]int32{
+		"Cluster": 0,
+		"Local":   1,
+	}
+)
+
+func (x TrafficPolicy) Enum() *TrafficPolicy {
+	p := new(TrafficPolicy)
+	*p = x
+	return p
+}
+
+func (x TrafficPolicy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TrafficPolicy) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_localv2_api_proto_enumTypes[5].Descriptor()
+}
+
+func (TrafficPolicy) Type() protoreflect.EnumType {
+	return &file_api_localv2_api_proto_enumTypes[5]
+}
+
+func (x TrafficPolicy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TrafficPolicy.Descriptor instead.
+func (TrafficPolicy) EnumDescriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{5}
+}
+
+type Protocol int32
+
+const (
+	Protocol_UnknownProtocol Protocol = 0
+	Protocol_TCP             Protocol = 1
+	Protocol_UDP             Protocol = 2
+	Protocol_SCTP            Protocol = 3
+)
+
+// Enum value maps for Protocol.
+var (
+	Protocol_name = map[int32]string{
+		0: "UnknownProtocol",
+		1: "TCP",
+		2: "UDP",
+		3: "SCTP",
+	}
+	Protocol_value = map[string]int32{
+		"UnknownProtocol": 0,
+		"TCP":             1,
+		"UDP":             2,
+		"SCTP":            3,
+	}
+)
+
+func (x Protocol) Enum() *Protocol {
+	p := new(Protocol)
+	*p = x
+	return p
+}
+
+func (x Protocol) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Protocol) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_localv2_api_proto_enumTypes[6].Descriptor()
+}
+
+func (Protocol) Type() protoreflect.EnumType {
+	return &file_api_localv2_api_proto_enumTypes[6]
+}
+
+func (x Protocol) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Protocol.Descriptor instead.
+func (Protocol) EnumDescriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{6}
+}
+
+type Capabilities struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Version of the API, the highest one supported by the client in requests.
+	Version      Version      `protobuf:"varint,1,opt,name=Version,proto3,enum=localv2.Version" json:"Version,omitempty"`
+	Capabilities []Capability `protobuf:"varint,2,rep,packed,name=Capabilities,proto3,enum=localv2.Capability" json:"Capabilities,omitempty"`
+}
+
+func (x *Capabilities) Reset() {
+	*x = Capabilities{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Capabilities) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Capabilities) ProtoMessage() {}
+
+func (x *Capabilities) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Capabilities.ProtoReflect.Descriptor instead.
+func (*Capabilities) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Capabilities) GetVersion() Version {
+	if x != nil {
+		return x.Version
+	}
+	return Version_UnknownVersion
+}
+
+func (x *Capabilities) GetCapabilities() []Capability {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+type WatchReq struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// NodeName of the requester
+	NodeName string `protobuf:"bytes,1,opt,name=NodeName,proto3" json:"NodeName,omitempty"`
+	// Capabilities supported by the requester, only read on the first request of a stream.
+	Capabilities *Capabilities `protobuf:"bytes,2,opt,name=Capabilities,proto3" json:"Capabilities,omitempty"`
+}
+
+func (x *WatchReq) Reset() {
+	*x = WatchReq{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WatchReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchReq) ProtoMessage() {}
+
+func (x *WatchReq) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchReq.ProtoReflect.Descriptor instead.
+func (*WatchReq) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *WatchReq) GetNodeName() string {
+	if x != nil {
+		return x.NodeName
+	}
+	return ""
+}
+
+func (x *WatchReq) GetCapabilities() *Capabilities {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+type SnapshotReq struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// NodeName of the node to get the state of
+	NodeName string `protobuf:"bytes,1,opt,name=NodeName,proto3" json:"NodeName,omitempty"`
+	// Capabilities to get the state with, as negotiated by Watch
+	Capabilities *Capabilities `protobuf:"bytes,2,opt,name=Capabilities,proto3" json:"Capabilities,omitempty"`
+}
+
+func (x *SnapshotReq) Reset() {
+	*x = SnapshotReq{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SnapshotReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SnapshotReq) ProtoMessage() {}
+
+func (x *SnapshotReq) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SnapshotReq.ProtoReflect.Descriptor instead.
+func (*SnapshotReq) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SnapshotReq) GetNodeName() string {
+	if x != nil {
+		return x.NodeName
+	}
+	return ""
+}
+
+func (x *SnapshotReq) GetCapabilities() *Capabilities {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+type Snapshot struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Capabilities the state was built with
+	Capabilities *Capabilities       `protobuf:"bytes,1,opt,name=Capabilities,proto3" json:"Capabilities,omitempty"`
+	Services     []*ServiceEndpoints `protobuf:"bytes,2,rep,name=Services,proto3" json:"Services,omitempty"`
+	// Rejected lists the services and endpoints not sent to the node, with the reason
+	Rejected []*Rejection `protobuf:"bytes,3,rep,name=Rejected,proto3" json:"Rejected,omitempty"`
+}
+
+func (x *Snapshot) Reset() {
+	*x = Snapshot{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Snapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Snapshot) ProtoMessage() {}
+
+func (x *Snapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Snapshot.ProtoReflect.Descriptor instead.
+func (*Snapshot) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Snapshot) GetCapabilities() *Capabilities {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+func (x *Snapshot) GetServices() []*ServiceEndpoints {
+	if x != nil {
+		return x.Services
+	}
+	return nil
+}
+
+func (x *Snapshot) GetRejected() []*Rejection {
+	if x != nil {
+		return x.Rejected
+	}
+	return nil
+}
+
+type ServiceEndpoints struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Service   *Service    `protobuf:"bytes,1,opt,name=Service,proto3" json:"Service,omitempty"`
+	Endpoints []*Endpoint `protobuf:"bytes,2,rep,name=Endpoints,proto3" json:"Endpoints,omitempty"`
+}
+
+func (x *ServiceEndpoints) Reset() {
+	*x = ServiceEndpoints{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServiceEndpoints) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServiceEndpoints) ProtoMessage() {}
+
+func (x *ServiceEndpoints) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServiceEndpoints.ProtoReflect.Descriptor instead.
+func (*ServiceEndpoints) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ServiceEndpoints) GetService() *Service {
+	if x != nil {
+		return x.Service
+	}
+	return nil
+}
+
+func (x *ServiceEndpoints) GetEndpoints() []*Endpoint {
+	if x != nil {
+		return x.Endpoints
+	}
+	return nil
+}
+
+type Rejection struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Kind of the object (service or endpoint)
+	Kind   string `protobuf:"bytes,1,opt,name=Kind,proto3" json:"Kind,omitempty"`
+	Path   string `protobuf:"bytes,2,opt,name=Path,proto3" json:"Path,omitempty"`
+	Reason string `protobuf:"bytes,3,opt,name=Reason,proto3" json:"Reason,omitempty"`
+}
+
+func (x *Rejection) Reset() {
+	*x = Rejection{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Rejection) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Rejection) ProtoMessage() {}
+
+func (x *Rejection) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Rejection.ProtoReflect.Descriptor instead.
+func (*Rejection) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Rejection) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Rejection) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *Rejection) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type OpItem struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Op:
+	//	*OpItem_Sync
+	//	*OpItem_Reset_
+	//	*OpItem_Hello
+	//	*OpItem_Set
+	//	*OpItem_Delete
+	Op isOpItem_Op `protobuf_oneof:"Op"`
+}
+
+func (x *OpItem) Reset() {
+	*x = OpItem{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OpItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpItem) ProtoMessage() {}
+
+func (x *OpItem) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpItem.ProtoReflect.Descriptor instead.
+func (*OpItem) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{6}
+}
+
+func (m *OpItem) GetOp() isOpItem_Op {
+	if m != nil {
+		return m.Op
+	}
+	return nil
+}
+
+func (x *OpItem) GetSync() *EmptyOp {
+	if x, ok := x.GetOp().(*OpItem_Sync); ok {
+		return x.Sync
+	}
+	return nil
+}
+
+func (x *OpItem) GetReset_() *EmptyOp {
+	if x, ok := x.GetOp().(*OpItem_Reset_); ok {
+		return x.Reset_
+	}
+	return nil
+}
+
+func (x *OpItem) GetHello() *Capabilities {
+	if x, ok := x.GetOp().(*OpItem_Hello); ok {
+		return x.Hello
+	}
+	return nil
+}
+
+func (x *OpItem) GetSet() *Value {
+	if x, ok := x.GetOp().(*OpItem_Set); ok {
+		return x.Set
+	}
+	return nil
+}
+
+func (x *OpItem) GetDelete() *Ref {
+	if x, ok := x.GetOp().(*OpItem_Delete); ok {
+		return x.Delete
+	}
+	return nil
+}
+
+type isOpItem_Op interface {
+	isOpItem_Op()
+}
+
+type OpItem_Sync struct {
+	// Sync signals that the change set is complete (especially useful to know when the initial state is complete)
+	Sync *EmptyOp `protobuf:"bytes,1,opt,name=Sync,proto3,oneof"`
+}
+
+type OpItem_Reset_ struct {
+	// Reset signals that the whole data set will be sent next
+	Reset_ *EmptyOp `protobuf:"bytes,4,opt,name=Reset,proto3,oneof"`
+}
+
+type OpItem_Hello struct {
+	// Hello gives the capabilities negotiated for the stream, sent before any other op
+	Hello *Capabilities `protobuf:"bytes,5,opt,name=Hello,proto3,oneof"`
+}
+
+type OpItem_Set struct {
+	// Add/update a value in a set
+	Set *Value `protobuf:"bytes,2,opt,name=Set,proto3,oneof"`
+}
+
+type OpItem_Delete struct {
+	// Delete a value in a set
+	Delete *Ref `protobuf:"bytes,3,opt,name=Delete,proto3,oneof"`
+}
+
+func (*OpItem_Sync) isOpItem_Op() {}
+
+func (*OpItem_Reset_) isOpItem_Op() {}
+
+func (*OpItem_Hello) isOpItem_Op() {}
+
+func (*OpItem_Set) isOpItem_Op() {}
+
+func (*OpItem_Delete) isOpItem_Op() {}
+
+type EmptyOp struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *EmptyOp) Reset() {
+	*x = EmptyOp{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EmptyOp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmptyOp) ProtoMessage() {}
+
+func (x *EmptyOp) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmptyOp.ProtoReflect.Descriptor instead.
+func (*EmptyOp) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{7}
+}
+
+type Ref struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Set  Set    `protobuf:"varint,1,opt,name=Set,proto3,enum=localv2.Set" json:"Set,omitempty"`
+	Path string `protobuf:"bytes,2,opt,name=Path,proto3" json:"Path,omitempty"`
+}
+
+func (x *Ref) Reset() {
+	*x = Ref{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Ref) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Ref) ProtoMessage() {}
+
+func (x *Ref) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Ref.ProtoReflect.Descriptor instead.
+func (*Ref) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Ref) GetSet() Set {
+	if x != nil {
+		return x.Set
+	}
+	return Set_UnknownSet
+}
+
+func (x *Ref) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type Value struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Ref   *Ref   `protobuf:"bytes,1,opt,name=Ref,proto3" json:"Ref,omitempty"`
+	Bytes []byte `protobuf:"bytes,2,opt,name=Bytes,proto3" json:"Bytes,omitempty"`
+}
+
+func (x *Value) Reset() {
+	*x = Value{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Value) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Value) ProtoMessage() {}
+
+func (x *Value) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Value.ProtoReflect.Descriptor instead.
+func (*Value) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Value) GetRef() *Ref {
+	if x != nil {
+		return x.Ref
+	}
+	return nil
+}
+
+func (x *Value) GetBytes() []byte {
+	if x != nil {
+		return x.Bytes
+	}
+	return nil
+}
+
+type Service struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Namespace   string            `protobuf:"bytes,1,opt,name=Namespace,proto3" json:"Namespace,omitempty"`
+	Name        string            `protobuf:"bytes,2,opt,name=Name,proto3" json:"Name,omitempty"`
+	Type        ServiceType       `protobuf:"varint,3,opt,name=Type,proto3,enum=localv2.ServiceType" json:"Type,omitempty"`
+	Labels      map[string]string `protobuf:"bytes,4,rep,name=Labels,proto3" json:"Labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Annotations map[string]string `protobuf:"bytes,5,rep,name=Annotations,proto3" json:"Annotations,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// IPFamilies of the service, the first one being the primary family.
+	IPFamilies []IPFamily  `protobuf:"varint,6,rep,packed,name=IPFamilies,proto3,enum=localv2.IPFamily" json:"IPFamilies,omitempty"`
+	IPs        *ServiceIPs `protobuf:"bytes,7,opt,name=IPs,proto3" json:"IPs,omitempty"`
+	IPFilters  []*IPFilter `protobuf:"bytes,8,rep,name=IPFilters,proto3" json:"IPFilters,omitempty"`
+	// true if the service maps the whole IP, not just individual ports.
+	MapIP bool `protobuf:"varint,9,opt,name=MapIP,proto3" json:"MapIP,omitempty"`
+	// Individual ports mapped for the this service
+	Ports                 []*PortMapping `protobuf:"bytes,10,rep,name=Ports,proto3" json:"Ports,omitempty"`
+	ExternalTrafficPolicy TrafficPolicy  `protobuf:"varint,11,opt,name=ExternalTrafficPolicy,proto3,enum=localv2.TrafficPolicy" json:"ExternalTrafficPolicy,omitempty"`
+	InternalTrafficPolicy TrafficPolicy  `protobuf:"varint,12,opt,name=InternalTrafficPolicy,proto3,enum=localv2.TrafficPolicy" json:"InternalTrafficPolicy,omitempty"`
+	// Types that are assignable to SessionAffinity:
+	//	*Service_ClientIP
+	SessionAffinity isService_SessionAffinity `protobuf_oneof:"SessionAffinity"`
+}
+
+func (x *Service) Reset() {
+	*x = Service{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Service) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Service) ProtoMessage() {}
+
+func (x *Service) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Service.ProtoReflect.Descriptor instead.
+func (*Service) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Service) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *Service) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Service) GetType() ServiceType {
+	if x != nil {
+		return x.Type
+	}
+	return ServiceType_UnknownServiceType
+}
+
+func (x *Service) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Service) GetAnnotations() map[string]string {
+	if x != nil {
+		return x.Annotations
+	}
+	return nil
+}
+
+func (x *Service) GetIPFamilies() []IPFamily {
+	if x != nil {
+		return x.IPFamilies
+	}
+	return nil
+}
+
+func (x *Service) GetIPs() *ServiceIPs {
+	if x != nil {
+		return x.IPs
+	}
+	return nil
+}
+
+func (x *Service) GetIPFilters() []*IPFilter {
+	if x != nil {
+		return x.IPFilters
+	}
+	return nil
+}
+
+func (x *Service) GetMapIP() bool {
+	if x != nil {
+		return x.MapIP
+	}
+	return false
+}
+
+func (x *Service) GetPorts() []*PortMapping {
+	if x != nil {
+		return x.Ports
+	}
+	return nil
+}
+
+func (x *Service) GetExternalTrafficPolicy() TrafficPolicy {
+	if x != nil {
+		return x.ExternalTrafficPolicy
+	}
+	return TrafficPolicy_Cluster
+}
+
+func (x *Service) GetInternalTrafficPolicy() TrafficPolicy {
+	if x != nil {
+		return x.InternalTrafficPolicy
+	}
+	return TrafficPolicy_Cluster
+}
+
+func (m *Service) GetSessionAffinity() isService_SessionAffinity {
+	if m != nil {
+		return m.SessionAffinity
+	}
+	return nil
+}
+
+func (x *Service) GetClientIP() *ClientIPAffinity {
+	if x, ok := x.GetSessionAffinity().(*Service_ClientIP); ok {
+		return x.ClientIP
+	}
+	return nil
+}
+
+type isService_SessionAffinity interface {
+	isService_SessionAffinity()
+}
+
+type Service_ClientIP struct {
+	ClientIP *ClientIPAffinity `protobuf:"bytes,13,opt,name=ClientIP,proto3,oneof"`
+}
+
+func (*Service_ClientIP) isService_SessionAffinity() {}
+
+type IPFilter struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// TargetIPs are the destination IPs to match (before DNAT)
+	TargetIPs *IPSet `protobuf:"bytes,1,opt,name=TargetIPs,proto3" json:"TargetIPs,omitempty"`
+	// SourceRanges are the CIDRs of IPs that are allowed by this filter rule
+	SourceRanges []string `protobuf:"bytes,2,rep,name=SourceRanges,proto3" json:"SourceRanges,omitempty"`
+}
+
+func (x *IPFilter) Reset() {
+	*x = IPFilter{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *IPFilter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IPFilter) ProtoMessage() {}
+
+func (x *IPFilter) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IPFilter.ProtoReflect.Descriptor instead.
+func (*IPFilter) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *IPFilter) GetTargetIPs() *IPSet {
+	if x != nil {
+		return x.TargetIPs
+	}
+	return nil
+}
+
+func (x *IPFilter) GetSourceRanges() []string {
+	if x != nil {
+		return x.SourceRanges
+	}
+	return nil
+}
+
+type ServiceIPs struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ClusterIPs      *IPSet `protobuf:"bytes,1,opt,name=ClusterIPs,proto3" json:"ClusterIPs,omitempty"`
+	ExternalIPs     *IPSet `protobuf:"bytes,2,opt,name=ExternalIPs,proto3" json:"ExternalIPs,omitempty"`
+	LoadBalancerIPs *IPSet `protobuf:"bytes,3,opt,name=LoadBalancerIPs,proto3" json:"LoadBalancerIPs,omitempty"`
+	Headless        bool   `protobuf:"varint,4,opt,name=Headless,proto3" json:"Headless,omitempty"`
+	// the LoadBalancerIPs with the Proxy ipMode (see localv1).
+	ProxyLoadBalancerIPs *IPSet `protobuf:"bytes,5,opt,name=ProxyLoadBalancerIPs,proto3" json:"ProxyLoadBalancerIPs,omitempty"`
+}
+
+func (x *ServiceIPs) Reset() {
+	*x = ServiceIPs{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServiceIPs) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServiceIPs) ProtoMessage() {}
+
+func (x *ServiceIPs) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServiceIPs.ProtoReflect.Descriptor instead.
+func (*ServiceIPs) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ServiceIPs) GetClusterIPs() *IPSet {
+	if x != nil {
+		return x.ClusterIPs
+	}
+	return nil
+}
+
+func (x *ServiceIPs) GetExternalIPs() *IPSet {
+	if x != nil {
+		return x.ExternalIPs
+	}
+	return nil
+}
+
+func (x *ServiceIPs) GetLoadBalancerIPs() *IPSet {
+	if x != nil {
+		return x.LoadBalancerIPs
+	}
+	return nil
+}
+
+func (x *ServiceIPs) GetHeadless() bool {
+	if x != nil {
+		return x.Headless
+	}
+	return false
+}
+
+func (x *ServiceIPs) GetProxyLoadBalancerIPs() *IPSet {
+	if x != nil {
+		return x.ProxyLoadBalancerIPs
+	}
+	return nil
+}
+
+type Endpoint struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Hostname      string          `protobuf:"bytes,1,opt,name=Hostname,proto3" json:"Hostname,omitempty"`
+	IPs           *IPSet          `protobuf:"bytes,2,opt,name=IPs,proto3" json:"IPs,omitempty"`
+	Local         bool            `protobuf:"varint,3,opt,name=Local,proto3" json:"Local,omitempty"`
+	PortOverrides []*PortName     `protobuf:"bytes,4,rep,name=PortOverrides,proto3" json:"PortOverrides,omitempty"`
+	Scopes        *EndpointScopes `protobuf:"bytes,5,opt,name=Scopes,proto3" json:"Scopes,omitempty"`
+	// Conditions of the endpoint (with the WithEndpointConditions capability).
+	Conditions *EndpointConditions `protobuf:"bytes,6,opt,name=Conditions,proto3" json:"Conditions,omitempty"`
+	// Weight of the endpoint relative to the others of the service (with the WithEndpointWeights
+	// capability, 0 if not set).
+	Weight int32 `protobuf:"varint,7,opt,name=Weight,proto3" json:"Weight,omitempty"`
+}
+
+func (x *Endpoint) Reset() {
+	*x = Endpoint{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Endpoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Endpoint) ProtoMessage() {}
+
+func (x *Endpoint) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Endpoint.ProtoReflect.Descriptor instead.
+func (*Endpoint) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Endpoint) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *Endpoint) GetIPs() *IPSet {
+	if x != nil {
+		return x.IPs
+	}
+	return nil
+}
+
+func (x *Endpoint) GetLocal() bool {
+	if x != nil {
+		return x.Local
+	}
+	return false
+}
+
+func (x *Endpoint) GetPortOverrides() []*PortName {
+	if x != nil {
+		return x.PortOverrides
+	}
+	return nil
+}
+
+func (x *Endpoint) GetScopes() *EndpointScopes {
+	if x != nil {
+		return x.Scopes
+	}
+	return nil
+}
+
+func (x *Endpoint) GetConditions() *EndpointConditions {
+	if x != nil {
+		return x.Conditions
+	}
+	return nil
+}
+
+func (x *Endpoint) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+type EndpointScopes struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Internal bool `protobuf:"varint,1,opt,name=Internal,proto3" json:"Internal,omitempty"`
+	External bool `protobuf:"varint,2,opt,name=External,proto3" json:"External,omitempty"`
+}
+
+func (x *EndpointScopes) Reset() {
+	*x = EndpointScopes{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EndpointScopes) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EndpointScopes) ProtoMessage() {}
+
+func (x *EndpointScopes) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EndpointScopes.ProtoReflect.Descriptor instead.
+func (*EndpointScopes) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *EndpointScopes) GetInternal() bool {
+	if x != nil {
+		return x.Internal
+	}
+	return false
+}
+
+func (x *EndpointScopes) GetExternal() bool {
+	if x != nil {
+		return x.External
+	}
+	return false
+}
+
+type EndpointConditions struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Ready       bool `protobuf:"varint,1,opt,name=Ready,proto3" json:"Ready,omitempty"`
+	Serving     bool `protobuf:"varint,2,opt,name=Serving,proto3" json:"Serving,omitempty"`
+	Terminating bool `protobuf:"varint,3,opt,name=Terminating,proto3" json:"Terminating,omitempty"`
+}
+
+func (x *EndpointConditions) Reset() {
+	*x = EndpointConditions{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EndpointConditions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EndpointConditions) ProtoMessage() {}
+
+func (x *EndpointConditions) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EndpointConditions.ProtoReflect.Descriptor instead.
+func (*EndpointConditions) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *EndpointConditions) GetReady() bool {
+	if x != nil {
+		return x.Ready
+	}
+	return false
+}
+
+func (x *EndpointConditions) GetServing() bool {
+	if x != nil {
+		return x.Serving
+	}
+	return false
+}
+
+func (x *EndpointConditions) GetTerminating() bool {
+	if x != nil {
+		return x.Terminating
+	}
+	return false
+}
+
+type IPSet struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	V4 []string `protobuf:"bytes,1,rep,name=V4,proto3" json:"V4,omitempty"`
+	V6 []string `protobuf:"bytes,2,rep,name=V6,proto3" json:"V6,omitempty"`
+}
+
+func (x *IPSet) Reset() {
+	*x = IPSet{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *IPSet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IPSet) ProtoMessage() {}
+
+func (x *IPSet) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IPSet.ProtoReflect.Descriptor instead.
+func (*IPSet) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *IPSet) GetV4() []string {
+	if x != nil {
+		return x.V4
+	}
+	return nil
+}
+
+func (x *IPSet) GetV6() []string {
+	if x != nil {
+		return x.V6
+	}
+	return nil
+}
+
+type PortName struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name string `protobuf:"bytes,1,opt,name=Name,proto3" json:"Name,omitempty"`
+	Port int32  `protobuf:"varint,2,opt,name=Port,proto3" json:"Port,omitempty"`
+}
+
+func (x *PortName) Reset() {
+	*x = PortName{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PortName) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PortName) ProtoMessage() {}
+
+func (x *PortName) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PortName.ProtoReflect.Descriptor instead.
+func (*PortName) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *PortName) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *PortName) GetPort() int32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+type PortMapping struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name           string   `protobuf:"bytes,1,opt,name=Name,proto3" json:"Name,omitempty"`
+	Protocol       Protocol `protobuf:"varint,2,opt,name=Protocol,proto3,enum=localv2.Protocol" json:"Protocol,omitempty"`
+	Port           int32    `protobuf:"varint,3,opt,name=Port,proto3" json:"Port,omitempty"`
+	NodePort       int32    `protobuf:"varint,4,opt,name=NodePort,proto3" json:"NodePort,omitempty"`
+	TargetPort     int32    `protobuf:"varint,5,opt,name=TargetPort,proto3" json:"TargetPort,omitempty"`
+	TargetPortName string   `protobuf:"bytes,6,opt,name=TargetPortName,proto3" json:"TargetPortName,omitempty"`
+}
+
+func (x *PortMapping) Reset() {
+	*x = PortMapping{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PortMapping) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PortMapping) ProtoMessage() {}
+
+func (x *PortMapping) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PortMapping.ProtoReflect.Descriptor instead.
+func (*PortMapping) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *PortMapping) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *PortMapping) GetProtocol() Protocol {
+	if x != nil {
+		return x.Protocol
+	}
+	return Protocol_UnknownProtocol
+}
+
+func (x *PortMapping) GetPort() int32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+func (x *PortMapping) GetNodePort() int32 {
+	if x != nil {
+		return x.NodePort
+	}
+	return 0
+}
+
+func (x *PortMapping) GetTargetPort() int32 {
+	if x != nil {
+		return x.TargetPort
+	}
+	return 0
+}
+
+func (x *PortMapping) GetTargetPortName() string {
+	if x != nil {
+		return x.TargetPortName
+	}
+	return ""
+}
+
+type ClientIPAffinity struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TimeoutSeconds int32 `protobuf:"varint,1,opt,name=TimeoutSeconds,proto3" json:"TimeoutSeconds,omitempty"`
+}
+
+func (x *ClientIPAffinity) Reset() {
+	*x = ClientIPAffinity{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_localv2_api_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientIPAffinity) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientIPAffinity) ProtoMessage() {}
+
+func (x *ClientIPAffinity) ProtoReflect() protoreflect.Message {
+	mi := &file_api_localv2_api_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientIPAffinity.ProtoReflect.Descriptor instead.
+func (*ClientIPAffinity) Descriptor() ([]byte, []int) {
+	return file_api_localv2_api_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ClientIPAffinity) GetTimeoutSeconds() int32 {
+	if x != nil {
+		return x.TimeoutSeconds
+	}
+	return 0
+}
+
+var File_api_localv2_api_proto protoreflect.FileDescriptor
+
+var file_api_localv2_api_proto_rawDesc = []byte{
+	0x0a, 0x15, 0x61, 0x70, 0x69, 0x2f, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2f, 0x61, 0x70,
+	0x69, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x07, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32,
+	0x22, 0x73, 0x0a, 0x0c, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73,
+	0x12, 0x2a, 0x0a, 0x07, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0e, 0x32, 0x10, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x56, 0x65, 0x72, 0x73,
+	0x69, 0x6f, 0x6e, 0x52, 0x07, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x37, 0x0a, 0x0c,
+	0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03,
+	0x28, 0x0e, 0x32, 0x13, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x43, 0x61, 0x70,
+	0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x52, 0x0c, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c,
+	0x69, 0x74, 0x69, 0x65, 0x73, 0x22, 0x61, 0x0a, 0x08, 0x57, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65,
+	0x71, 0x12, 0x1a, 0x0a, 0x08, 0x4e, 0x6f, 0x64, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x4e, 0x6f, 0x64, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x39, 0x0a,
+	0x0c, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x43, 0x61,
+	0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x52, 0x0c, 0x43, 0x61, 0x70, 0x61,
+	0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x22, 0x64, 0x0a, 0x0b, 0x53, 0x6e, 0x61, 0x70,
+	0x73, 0x68, 0x6f, 0x74, 0x52, 0x65, 0x71, 0x12, 0x1a, 0x0a, 0x08, 0x4e, 0x6f, 0x64, 0x65, 0x4e,
+	0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x4e, 0x6f, 0x64, 0x65, 0x4e,
+	0x61, 0x6d, 0x65, 0x12, 0x39, 0x0a, 0x0c, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74,
+	0x69, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x6c, 0x6f, 0x63, 0x61,
+	0x6c, 0x76, 0x32, 0x2e, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73,
+	0x52, 0x0c, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x22, 0xac,
+	0x01, 0x0a, 0x08, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x39, 0x0a, 0x0c, 0x43,
+	0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x15, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x43, 0x61, 0x70, 0x61,
+	0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x52, 0x0c, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69,
+	0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x12, 0x35, 0x0a, 0x08, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c,
+	0x76, 0x32, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69,
+	0x6e, 0x74, 0x73, 0x52, 0x08, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x12, 0x2e, 0x0a,
+	0x08, 0x52, 0x65, 0x6a, 0x65, 0x63, 0x74, 0x65, 0x64, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x12, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x52, 0x65, 0x6a, 0x65, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x52, 0x08, 0x52, 0x65, 0x6a, 0x65, 0x63, 0x74, 0x65, 0x64, 0x22, 0x6f, 0x0a,
+	0x10, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74,
+	0x73, 0x12, 0x2a, 0x0a, 0x07, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x10, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x53, 0x65, 0x72,
+	0x76, 0x69, 0x63, 0x65, 0x52, 0x07, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x2f, 0x0a,
+	0x09, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x11, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x45, 0x6e, 0x64, 0x70, 0x6f,
+	0x69, 0x6e, 0x74, 0x52, 0x09, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x22, 0x4b,
+	0x0a, 0x09, 0x52, 0x65, 0x6a, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x4b,
+	0x69, 0x6e, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x4b, 0x69, 0x6e, 0x64, 0x12,
+	0x12, 0x0a, 0x04, 0x50, 0x61, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x50,
+	0x61, 0x74, 0x68, 0x12, 0x16, 0x0a, 0x06, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x06, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x22, 0xdb, 0x01, 0x0a, 0x06,
+	0x4f, 0x70, 0x49, 0x74, 0x65, 0x6d, 0x12, 0x26, 0x0a, 0x04, 0x53, 0x79, 0x6e, 0x63, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x45,
+	0x6d, 0x70, 0x74, 0x79, 0x4f, 0x70, 0x48, 0x00, 0x52, 0x04, 0x53, 0x79, 0x6e, 0x63, 0x12, 0x28,
+	0x0a, 0x05, 0x52, 0x65, 0x73, 0x65, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e,
+	0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x4f, 0x70, 0x48,
+	0x00, 0x52, 0x05, 0x52, 0x65, 0x73, 0x65, 0x74, 0x12, 0x2d, 0x0a, 0x05, 0x48, 0x65, 0x6c, 0x6c,
+	0x6f, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76,
+	0x32, 0x2e, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x48, 0x00,
+	0x52, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x12, 0x22, 0x0a, 0x03, 0x53, 0x65, 0x74, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x56,
+	0x61, 0x6c, 0x75, 0x65, 0x48, 0x00, 0x52, 0x03, 0x53, 0x65, 0x74, 0x12, 0x26, 0x0a, 0x06, 0x44,
+	0x65, 0x6c, 0x65, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x6c, 0x6f,
+	0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x52, 0x65, 0x66, 0x48, 0x00, 0x52, 0x06, 0x44, 0x65, 0x6c,
+	0x65, 0x74, 0x65, 0x42, 0x04, 0x0a, 0x02, 0x4f, 0x70, 0x22, 0x09, 0x0a, 0x07, 0x45, 0x6d, 0x70,
+	0x74, 0x79, 0x4f, 0x70, 0x22, 0x39, 0x0a, 0x03, 0x52, 0x65, 0x66, 0x12, 0x1e, 0x0a, 0x03, 0x53,
+	0x65, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0c, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c,
+	0x76, 0x32, 0x2e, 0x53, 0x65, 0x74, 0x52, 0x03, 0x53, 0x65, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x50,
+	0x61, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x50, 0x61, 0x74, 0x68, 0x22,
+	0x3d, 0x0a, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x1e, 0x0a, 0x03, 0x52, 0x65, 0x66, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e,
+	0x52, 0x65, 0x66, 0x52, 0x03, 0x52, 0x65, 0x66, 0x12, 0x14, 0x0a, 0x05, 0x42, 0x79, 0x74, 0x65,
+	0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x42, 0x79, 0x74, 0x65, 0x73, 0x22, 0x90,
+	0x06, 0x0a, 0x07, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x4e, 0x61,
+	0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x4e,
+	0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x4e, 0x61, 0x6d, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x28, 0x0a, 0x04,
+	0x54, 0x79, 0x70, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x14, 0x2e, 0x6c, 0x6f, 0x63,
+	0x61, 0x6c, 0x76, 0x32, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x54, 0x79, 0x70, 0x65,
+	0x52, 0x04, 0x54, 0x79, 0x70, 0x65, 0x12, 0x34, 0x0a, 0x06, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73,
+	0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32,
+	0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x45,
+	0x6e, 0x74, 0x72, 0x79, 0x52, 0x06, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x12, 0x43, 0x0a, 0x0b,
+	0x41, 0x6e, 0x6e, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x21, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x53, 0x65, 0x72, 0x76,
+	0x69, 0x63, 0x65, 0x2e, 0x41, 0x6e, 0x6e, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x45,
+	0x6e, 0x74, 0x72, 0x79, 0x52, 0x0b, 0x41, 0x6e, 0x6e, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x73, 0x12, 0x31, 0x0a, 0x0a, 0x49, 0x50, 0x46, 0x61, 0x6d, 0x69, 0x6c, 0x69, 0x65, 0x73, 0x18,
+	0x06, 0x20, 0x03, 0x28, 0x0e, 0x32, 0x11, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e,
+	0x49, 0x50, 0x46, 0x61, 0x6d, 0x69, 0x6c, 0x79, 0x52, 0x0a, 0x49, 0x50, 0x46, 0x61, 0x6d, 0x69,
+	0x6c, 0x69, 0x65, 0x73, 0x12, 0x25, 0x0a, 0x03, 0x49, 0x50, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x13, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x53, 0x65, 0x72, 0x76,
+	0x69, 0x63, 0x65, 0x49, 0x50, 0x73, 0x52, 0x03, 0x49, 0x50, 0x73, 0x12, 0x2f, 0x0a, 0x09, 0x49,
+	0x50, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x11,
+	0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x49, 0x50, 0x46, 0x69, 0x6c, 0x74, 0x65,
+	0x72, 0x52, 0x09, 0x49, 0x50, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x73, 0x12, 0x14, 0x0a, 0x05,
+	0x4d, 0x61, 0x70, 0x49, 0x50, 0x18, 0x09, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x4d, 0x61, 0x70,
+	0x49, 0x50, 0x12, 0x2a, 0x0a, 0x05, 0x50, 0x6f, 0x72, 0x74, 0x73, 0x18, 0x0a, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x14, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x50, 0x6f, 0x72, 0x74,
+	0x4d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x52, 0x05, 0x50, 0x6f, 0x72, 0x74, 0x73, 0x12, 0x4c,
+	0x0a, 0x15, 0x45, 0x78, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x54, 0x72, 0x61, 0x66, 0x66, 0x69,
+	0x63, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x16, 0x2e,
+	0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x54, 0x72, 0x61, 0x66, 0x66, 0x69, 0x63, 0x50,
+	0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x15, 0x45, 0x78, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x54,
+	0x72, 0x61, 0x66, 0x66, 0x69, 0x63, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x4c, 0x0a, 0x15,
+	0x49, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x54, 0x72, 0x61, 0x66, 0x66, 0x69, 0x63, 0x50,
+	0x6f, 0x6c, 0x69, 0x63, 0x79, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x16, 0x2e, 0x6c, 0x6f,
+	0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x54, 0x72, 0x61, 0x66, 0x66, 0x69, 0x63, 0x50, 0x6f, 0x6c,
+	0x69, 0x63, 0x79, 0x52, 0x15, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x54, 0x72, 0x61,
+	0x66, 0x66, 0x69, 0x63, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x37, 0x0a, 0x08, 0x43, 0x6c,
+	0x69, 0x65, 0x6e, 0x74, 0x49, 0x50, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x6c,
+	0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x50, 0x41,
+	0x66, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x79, 0x48, 0x00, 0x52, 0x08, 0x43, 0x6c, 0x69, 0x65, 0x6e,
+	0x74, 0x49, 0x50, 0x1a, 0x39, 0x0a, 0x0b, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x3e,
+	0x0a, 0x10, 0x41, 0x6e, 0x6e, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x42, 0x11,
+	0x0a, 0x0f, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x41, 0x66, 0x66, 0x69, 0x6e, 0x69, 0x74,
+	0x79, 0x22, 0x5c, 0x0a, 0x08, 0x49, 0x50, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x12, 0x2c, 0x0a,
+	0x09, 0x54, 0x61, 0x72, 0x67, 0x65, 0x74, 0x49, 0x50, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x0e, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x49, 0x50, 0x53, 0x65, 0x74,
+	0x52, 0x09, 0x54, 0x61, 0x72, 0x67, 0x65, 0x74, 0x49, 0x50, 0x73, 0x12, 0x22, 0x0a, 0x0c, 0x53,
+	0x6f, 0x75, 0x72, 0x63, 0x65, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x0c, 0x53, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x22,
+	0x88, 0x02, 0x0a, 0x0a, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x49, 0x50, 0x73, 0x12, 0x2e,
+	0x0a, 0x0a, 0x43, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x49, 0x50, 0x73, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x49, 0x50, 0x53,
+	0x65, 0x74, 0x52, 0x0a, 0x43, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x49, 0x50, 0x73, 0x12, 0x30,
+	0x0a, 0x0b, 0x45, 0x78, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x49, 0x50, 0x73, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x49, 0x50,
+	0x53, 0x65, 0x74, 0x52, 0x0b, 0x45, 0x78, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x49, 0x50, 0x73,
+	0x12, 0x38, 0x0a, 0x0f, 0x4c, 0x6f, 0x61, 0x64, 0x42, 0x61, 0x6c, 0x61, 0x6e, 0x63, 0x65, 0x72,
+	0x49, 0x50, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x6c, 0x6f, 0x63, 0x61,
+	0x6c, 0x76, 0x32, 0x2e, 0x49, 0x50, 0x53, 0x65, 0x74, 0x52, 0x0f, 0x4c, 0x6f, 0x61, 0x64, 0x42,
+	0x61, 0x6c, 0x61, 0x6e, 0x63, 0x65, 0x72, 0x49, 0x50, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x48, 0x65,
+	0x61, 0x64, 0x6c, 0x65, 0x73, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x48, 0x65,
+	0x61, 0x64, 0x6c, 0x65, 0x73, 0x73, 0x12, 0x42, 0x0a, 0x14, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x4c,
+	0x6f, 0x61, 0x64, 0x42, 0x61, 0x6c, 0x61, 0x6e, 0x63, 0x65, 0x72, 0x49, 0x50, 0x73, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x49,
+	0x50, 0x53, 0x65, 0x74, 0x52, 0x14, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x4c, 0x6f, 0x61, 0x64, 0x42,
+	0x61, 0x6c, 0x61, 0x6e, 0x63, 0x65, 0x72, 0x49, 0x50, 0x73, 0x22, 0x9d, 0x02, 0x0a, 0x08, 0x45,
+	0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x48, 0x6f, 0x73, 0x74, 0x6e,
+	0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x48, 0x6f, 0x73, 0x74, 0x6e,
+	0x61, 0x6d, 0x65, 0x12, 0x20, 0x0a, 0x03, 0x49, 0x50, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x0e, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x49, 0x50, 0x53, 0x65, 0x74,
+	0x52, 0x03, 0x49, 0x50, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x12, 0x37, 0x0a, 0x0d, 0x50,
+	0x6f, 0x72, 0x74, 0x4f, 0x76, 0x65, 0x72, 0x72, 0x69, 0x64, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x11, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x50, 0x6f, 0x72,
+	0x74, 0x4e, 0x61, 0x6d, 0x65, 0x52, 0x0d, 0x50, 0x6f, 0x72, 0x74, 0x4f, 0x76, 0x65, 0x72, 0x72,
+	0x69, 0x64, 0x65, 0x73, 0x12, 0x2f, 0x0a, 0x06, 0x53, 0x63, 0x6f, 0x70, 0x65, 0x73, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x45,
+	0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x53, 0x63, 0x6f, 0x70, 0x65, 0x73, 0x52, 0x06, 0x53,
+	0x63, 0x6f, 0x70, 0x65, 0x73, 0x12, 0x3b, 0x0a, 0x0a, 0x43, 0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69,
+	0x6f, 0x6e, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x6c, 0x6f, 0x63, 0x61,
+	0x6c, 0x76, 0x32, 0x2e, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x43, 0x6f, 0x6e, 0x64,
+	0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x52, 0x0a, 0x43, 0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f,
+	0x6e, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x57, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x07, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x06, 0x57, 0x65, 0x69, 0x67, 0x68, 0x74, 0x22, 0x48, 0x0a, 0x0e, 0x45, 0x6e,
+	0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x53, 0x63, 0x6f, 0x70, 0x65, 0x73, 0x12, 0x1a, 0x0a, 0x08,
+	0x49, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08,
+	0x49, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x12, 0x1a, 0x0a, 0x08, 0x45, 0x78, 0x74, 0x65,
+	0x72, 0x6e, 0x61, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x45, 0x78, 0x74, 0x65,
+	0x72, 0x6e, 0x61, 0x6c, 0x22, 0x66, 0x0a, 0x12, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74,
+	0x43, 0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x52, 0x65,
+	0x61, 0x64, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x52, 0x65, 0x61, 0x64, 0x79,
+	0x12, 0x18, 0x0a, 0x07, 0x53, 0x65, 0x72, 0x76, 0x69, 0x6e, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x07, 0x53, 0x65, 0x72, 0x76, 0x69, 0x6e, 0x67, 0x12, 0x20, 0x0a, 0x0b, 0x54, 0x65,
+	0x72, 0x6d, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x0b, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x22, 0x27, 0x0a, 0x05,
+	0x49, 0x50, 0x53, 0x65, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x56, 0x34, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x02, 0x56, 0x34, 0x12, 0x0e, 0x0a, 0x02, 0x56, 0x36, 0x18, 0x02, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x02, 0x56, 0x36, 0x22, 0x32, 0x0a, 0x08, 0x50, 0x6f, 0x72, 0x74, 0x4e, 0x61, 0x6d,
+	0x65, 0x12, 0x12, 0x0a, 0x04, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x50, 0x6f, 0x72, 0x74, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x04, 0x50, 0x6f, 0x72, 0x74, 0x22, 0xc8, 0x01, 0x0a, 0x0b, 0x50, 0x6f,
+	0x72, 0x74, 0x4d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x4e, 0x61, 0x6d,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x2d, 0x0a,
+	0x08, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32,
+	0x11, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63,
+	0x6f, 0x6c, 0x52, 0x08, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x12, 0x12, 0x0a, 0x04,
+	0x50, 0x6f, 0x72, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x50, 0x6f, 0x72, 0x74,
+	0x12, 0x1a, 0x0a, 0x08, 0x4e, 0x6f, 0x64, 0x65, 0x50, 0x6f, 0x72, 0x74, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x08, 0x4e, 0x6f, 0x64, 0x65, 0x50, 0x6f, 0x72, 0x74, 0x12, 0x1e, 0x0a, 0x0a,
+	0x54, 0x61, 0x72, 0x67, 0x65, 0x74, 0x50, 0x6f, 0x72, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x0a, 0x54, 0x61, 0x72, 0x67, 0x65, 0x74, 0x50, 0x6f, 0x72, 0x74, 0x12, 0x26, 0x0a, 0x0e,
+	0x54, 0x61, 0x72, 0x67, 0x65, 0x74, 0x50, 0x6f, 0x72, 0x74, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x54, 0x61, 0x72, 0x67, 0x65, 0x74, 0x50, 0x6f, 0x72, 0x74,
+	0x4e, 0x61, 0x6d, 0x65, 0x22, 0x3a, 0x0a, 0x10, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x50,
+	0x41, 0x66, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x79, 0x12, 0x26, 0x0a, 0x0e, 0x54, 0x69, 0x6d, 0x65,
+	0x6f, 0x75, 0x74, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x0e, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73,
+	0x2a, 0x2d, 0x0a, 0x07, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x0e, 0x55,
+	0x6e, 0x6b, 0x6e, 0x6f, 0x77, 0x6e, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x10, 0x00, 0x12,
+	0x06, 0x0a, 0x02, 0x56, 0x31, 0x10, 0x01, 0x12, 0x06, 0x0a, 0x02, 0x56, 0x32, 0x10, 0x02, 0x2a,
+	0x58, 0x0a, 0x0a, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x12, 0x15, 0x0a,
+	0x11, 0x55, 0x6e, 0x6b, 0x6e, 0x6f, 0x77, 0x6e, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69,
+	0x74, 0x79, 0x10, 0x00, 0x12, 0x1a, 0x0a, 0x16, 0x57, 0x69, 0x74, 0x68, 0x45, 0x6e, 0x64, 0x70,
+	0x6f, 0x69, 0x6e, 0x74, 0x43, 0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x10, 0x01,
+	0x12, 0x17, 0x0a, 0x13, 0x57, 0x69, 0x74, 0x68, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74,
+	0x57, 0x65, 0x69, 0x67, 0x68, 0x74, 0x73, 0x10, 0x02, 0x2a, 0x38, 0x0a, 0x03, 0x53, 0x65, 0x74,
+	0x12, 0x0e, 0x0a, 0x0a, 0x55, 0x6e, 0x6b, 0x6e, 0x6f, 0x77, 0x6e, 0x53, 0x65, 0x74, 0x10, 0x00,
+	0x12, 0x0f, 0x0a, 0x0b, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x53, 0x65, 0x74, 0x10,
+	0x01, 0x12, 0x10, 0x0a, 0x0c, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x53, 0x65,
+	0x74, 0x10, 0x02, 0x2a, 0x66, 0x0a, 0x0b, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x54, 0x79,
+	0x70, 0x65, 0x12, 0x16, 0x0a, 0x12, 0x55, 0x6e, 0x6b, 0x6e, 0x6f, 0x77, 0x6e, 0x53, 0x65, 0x72,
+	0x76, 0x69, 0x63, 0x65, 0x54, 0x79, 0x70, 0x65, 0x10, 0x00, 0x12, 0x0d, 0x0a, 0x09, 0x43, 0x6c,
+	0x75, 0x73, 0x74, 0x65, 0x72, 0x49, 0x50, 0x10, 0x01, 0x12, 0x0c, 0x0a, 0x08, 0x4e, 0x6f, 0x64,
+	0x65, 0x50, 0x6f, 0x72, 0x74, 0x10, 0x02, 0x12, 0x10, 0x0a, 0x0c, 0x4c, 0x6f, 0x61, 0x64, 0x42,
+	0x61, 0x6c, 0x61, 0x6e, 0x63, 0x65, 0x72, 0x10, 0x03, 0x12, 0x10, 0x0a, 0x0c, 0x45, 0x78, 0x74,
+	0x65, 0x72, 0x6e, 0x61, 0x6c, 0x4e, 0x61, 0x6d, 0x65, 0x10, 0x04, 0x2a, 0x33, 0x0a, 0x08, 0x49,
+	0x50, 0x46, 0x61, 0x6d, 0x69, 0x6c, 0x79, 0x12, 0x13, 0x0a, 0x0f, 0x55, 0x6e, 0x6b, 0x6e, 0x6f,
+	0x77, 0x6e, 0x49, 0x50, 0x46, 0x61, 0x6d, 0x69, 0x6c, 0x79, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04,
+	0x49, 0x50, 0x76, 0x34, 0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x49, 0x50, 0x76, 0x36, 0x10, 0x02,
+	0x2a, 0x27, 0x0a, 0x0d, 0x54, 0x72, 0x61, 0x66, 0x66, 0x69, 0x63, 0x50, 0x6f, 0x6c, 0x69, 0x63,
+	0x79, 0x12, 0x0b, 0x0a, 0x07, 0x43, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x10, 0x00, 0x12, 0x09,
+	0x0a, 0x05, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x10, 0x01, 0x2a, 0x3b, 0x0a, 0x08, 0x50, 0x72, 0x6f,
+	0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x12, 0x13, 0x0a, 0x0f, 0x55, 0x6e, 0x6b, 0x6e, 0x6f, 0x77, 0x6e,
+	0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x10, 0x00, 0x12, 0x07, 0x0a, 0x03, 0x54, 0x43,
+	0x50, 0x10, 0x01, 0x12, 0x07, 0x0a, 0x03, 0x55, 0x44, 0x50, 0x10, 0x02, 0x12, 0x08, 0x0a, 0x04,
+	0x53, 0x43, 0x54, 0x50, 0x10, 0x03, 0x32, 0x6f, 0x0a, 0x04, 0x53, 0x65, 0x74, 0x73, 0x12, 0x2f,
+	0x0a, 0x05, 0x57, 0x61, 0x74, 0x63, 0x68, 0x12, 0x11, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76,
+	0x32, 0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71, 0x1a, 0x0f, 0x2e, 0x6c, 0x6f, 0x63,
+	0x61, 0x6c, 0x76, 0x32, 0x2e, 0x4f, 0x70, 0x49, 0x74, 0x65, 0x6d, 0x28, 0x01, 0x30, 0x01, 0x12,
+	0x36, 0x0a, 0x0b, 0x47, 0x65, 0x74, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x14,
+	0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f,
+	0x74, 0x52, 0x65, 0x71, 0x1a, 0x11, 0x2e, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x2e, 0x53,
+	0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x42, 0x1e, 0x5a, 0x1c, 0x73, 0x69, 0x67, 0x73, 0x2e,
+	0x6b, 0x38, 0x73, 0x2e, 0x69, 0x6f, 0x2f, 0x6b, 0x70, 0x6e, 0x67, 0x2f, 0x61, 0x70, 0x69, 0x2f,
+	0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x76, 0x32, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_api_localv2_api_proto_rawDescOnce sync.Once
+	file_api_localv2_api_proto_rawDescData = file_api_localv2_api_proto_rawDesc
+)
+
+func file_api_localv2_api_proto_rawDescGZIP() []byte {
+	file_api_localv2_api_proto_rawDescOnce.Do(func() {
+		file_api_localv2_api_proto_rawDescData = protoimpl.X.CompressGZIP(file_api_localv2_api_proto_rawDescData)
+	})
+	return file_api_localv2_api_proto_rawDescData
+}
+
+var file_api_localv2_api_proto_enumTypes = make([]protoimpl.EnumInfo, 7)
+var file_api_localv2_api_proto_msgTypes = make([]protoimpl.MessageInfo, 22)
+var file_api_localv2_api_proto_goTypes = []interface{}{
+	(Version)(0),               // 0: localv2.Version
+	(Capability)(0),            // 1: localv2.Capability
+	(Set)(0),                   // 2: localv2.Set
+	(ServiceType)(0),           // 3: localv2.ServiceType
+	(IPFamily)(0),              // 4: localv2.IPFamily
+	(TrafficPolicy)(0),         // 5: localv2.TrafficPolicy
+	(Protocol)(0),              // 6: localv2.Protocol
+	(*Capabilities)(nil),       // 7: localv2.Capabilities
+	(*WatchReq)(nil),           // 8: localv2.WatchReq
+	(*SnapshotReq)(nil),        // 9: localv2.SnapshotReq
+	(*Snapshot)(nil),           // 10: localv2.Snapshot
+	(*ServiceEndpoints)(nil),   // 11: localv2.ServiceEndpoints
+	(*Rejection)(nil),          // 12: localv2.Rejection
+	(*OpItem)(nil),             // 13: localv2.OpItem
+	(*EmptyOp)(nil),            // 14: localv2.EmptyOp
+	(*Ref)(nil),                // 15: localv2.Ref
+	(*Value)(nil),              // 16: localv2.Value
+	(*Service)(nil),            // 17: localv2.Service
+	(*IPFilter)(nil),           // 18: localv2.IPFilter
+	(*ServiceIPs)(nil),         // 19: localv2.ServiceIPs
+	(*Endpoint)(nil),           // 20: localv2.Endpoint
+	(*EndpointScopes)(nil),     // 21: localv2.EndpointScopes
+	(*EndpointConditions)(nil), // 22: localv2.EndpointConditions
+	(*IPSet)(nil),              // 23: localv2.IPSet
+	(*PortName)(nil),           // 24: localv2.PortName
+	(*PortMapping)(nil),        // 25: localv2.PortMapping
+	(*ClientIPAffinity)(nil),   // 26: localv2.ClientIPAffinity
+	nil,                        // 27: localv2.Service.LabelsEntry
+	nil,                        // 28: localv2.Service.AnnotationsEntry
+}
+var file_api_localv2_api_proto_depIdxs = []int32{
+	0,  // 0: localv2.Capabilities.Version:type_name -> localv2.Version
+	1,  // 1: localv2.Capabilities.Capabilities:type_name -> localv2.Capability
+	7,  // 2: localv2.WatchReq.Capabilities:type_name -> localv2.Capabilities
+	7,  // 3: localv2.SnapshotReq.Capabilities:type_name -> localv2.Capabilities
+	7,  // 4: localv2.Snapshot.Capabilities:type_name -> localv2.Capabilities
+	11, // 5: localv2.Snapshot.Services:type_name -> localv2.ServiceEndpoints
+	12, // 6: localv2.Snapshot.Rejected:type_name -> localv2.Rejection
+	17, // 7: localv2.ServiceEndpoints.Service:type_name -> localv2.Service
+	20, // 8: localv2.ServiceEndpoints.Endpoints:type_name -> localv2.Endpoint
+	14, // 9: localv2.OpItem.Sync:type_name -> localv2.EmptyOp
+	14, // 10: localv2.OpItem.Reset:type_name -> localv2.EmptyOp
+	7,  // 11: localv2.OpItem.Hello:type_name -> localv2.Capabilities
+	16, // 12: localv2.OpItem.Set:type_name -> localv2.Value
+	15, // 13: localv2.OpItem.Delete:type_name -> localv2.Ref
+	2,  // 14: localv2.Ref.Set:type_name -> localv2.Set
+	15, // 15: localv2.Value.Ref:type_name -> localv2.Ref
+	3,  // 16: localv2.Service.Type:type_name -> localv2.ServiceType
+	27, // 17: localv2.Service.Labels:type_name -> localv2.Service.LabelsEntry
+	28, // 18: localv2.Service.Annotations:type_name -> localv2.Service.AnnotationsEntry
+	4,  // 19: localv2.Service.IPFamilies:type_name -> localv2.IPFamily
+	19, // 20: localv2.Service.IPs:type_name -> localv2.ServiceIPs
+	18, // 21: localv2.Service.IPFilters:type_name -> localv2.IPFilter
+	25, // 22: localv2.Service.Ports:type_name -> localv2.PortMapping
+	5,  // 23: localv2.Service.ExternalTrafficPolicy:type_name -> localv2.TrafficPolicy
+	5,  // 24: localv2.Service.InternalTrafficPolicy:type_name -> localv2.TrafficPolicy
+	26, // 25: localv2.Service.ClientIP:type_name -> localv2.ClientIPAffinity
+	23, // 26: localv2.IPFilter.TargetIPs:type_name -> localv2.IPSet
+	23, // 27: localv2.ServiceIPs.ClusterIPs:type_name -> localv2.IPSet
+	23, // 28: localv2.ServiceIPs.ExternalIPs:type_name -> localv2.IPSet
+	23, // 29: localv2.ServiceIPs.LoadBalancerIPs:type_name -> localv2.IPSet
+	23, // 30: localv2.ServiceIPs.ProxyLoadBalancerIPs:type_name -> localv2.IPSet
+	23, // 31: localv2.Endpoint.IPs:type_name -> localv2.IPSet
+	24, // 32: localv2.Endpoint.PortOverrides:type_name -> localv2.PortName
+	21, // 33: localv2.Endpoint.Scopes:type_name -> localv2.EndpointScopes
+	22, // 34: localv2.Endpoint.Conditions:type_name -> localv2.EndpointConditions
+	6,  // 35: localv2.PortMapping.Protocol:type_name -> localv2.Protocol
+	8,  // 36: localv2.Sets.Watch:input_type -> localv2.WatchReq
+	9,  // 37: localv2.Sets.GetSnapshot:input_type -> localv2.SnapshotReq
+	13, // 38: localv2.Sets.Watch:output_type -> localv2.OpItem
+	10, // 39: localv2.Sets.GetSnapshot:output_type -> localv2.Snapshot
+	38, // [38:40] is the sub-list for method output_type
+	36, // [36:38] is the sub-list for method input_type
+	36, // [36:36] is the sub-list for extension type_name
+	36, // [36:36] is the sub-list for extension extendee
+	0,  // [0:36] is the sub-list for field type_name
+}
+
+func init() { file_api_localv2_api_proto_init() }
+func file_api_localv2_api_proto_init() {
+	if File_api_localv2_api_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_api_localv2_api_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Capabilities); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WatchReq); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SnapshotReq); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Snapshot); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ServiceEndpoints); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Rejection); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*OpItem); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*EmptyOp); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Ref); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Value); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Service); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*IPFilter); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ServiceIPs); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Endpoint); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*EndpointScopes); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*EndpointConditions); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*IPSet); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PortName); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PortMapping); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_localv2_api_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientIPAffinity); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_api_localv2_api_proto_msgTypes[6].OneofWrappers = []interface{}{
+		(*OpItem_Sync)(nil),
+		(*OpItem_Reset_)(nil),
+		(*OpItem_Hello)(nil),
+		(*OpItem_Set)(nil),
+		(*OpItem_Delete)(nil),
+	}
+	file_api_localv2_api_proto_msgTypes[10].OneofWrappers = []interface{}{
+		(*Service_ClientIP)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_api_localv2_api_proto_rawDesc,
+			NumEnums:      7,
+			NumMessages:   22,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_localv2_api_proto_goTypes,
+		DependencyIndexes: file_api_localv2_api_proto_depIdxs,
+		EnumInfos:         file_api_localv2_api_proto_enumTypes,
+		MessageInfos:      file_api_localv2_api_proto_msgTypes,
+	}.Build()
+	File_api_localv2_api_proto = out.File
+	file_api_localv2_api_proto_rawDesc = nil
+	file_api_localv2_api_proto_goTypes = nil
+	file_api_localv2_api_proto_depIdxs = nil
+}
@@ -0,0 +1,178 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.18.1
+// source: api/localv2/api.proto
+
+package localv2
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// SetsClient is the client API for Sets service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SetsClient interface {
+	// Returns all the endpoints for this node.
+	Watch(ctx context.Context, opts ...grpc.CallOption) (Sets_WatchClient, error)
+	// GetSnapshot returns the current state of a node, for debugging (ie: with grpcurl).
+	GetSnapshot(ctx context.Context, in *SnapshotReq, opts ...grpc.CallOption) (*Snapshot, error)
+}
+
+type setsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSetsClient(cc grpc.ClientConnInterface) SetsClient {
+	return &setsClient{cc}
+}
+
+func (c *setsClient) Watch(ctx context.Context, opts ...grpc.CallOption) (Sets_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Sets_ServiceDesc.Streams[0], "/localv2.Sets/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &setsWatchClient{stream}
+	return x, nil
+}
+
+type Sets_WatchClient interface {
+	Send(*WatchReq) error
+	Recv() (*OpItem, error)
+	grpc.ClientStream
+}
+
+type setsWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *setsWatchClient) Send(m *WatchReq) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *setsWatchClient) Recv() (*OpItem, error) {
+	m := new(OpItem)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *setsClient) GetSnapshot(ctx context.Context, in *SnapshotReq, opts ...grpc.CallOption) (*Snapshot, error) {
+	out := new(Snapshot)
+	err := c.cc.Invoke(ctx, "/localv2.Sets/GetSnapshot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SetsServer is the server API for Sets service.
+// All implementations must embed UnimplementedSetsServer
+// for forward compatibility
+type SetsServer interface {
+	// Returns all the endpoints for this node.
+	Watch(Sets_WatchServer) error
+	// GetSnapshot returns the current state of a node, for debugging (ie: with grpcurl).
+	GetSnapshot(context.Context, *SnapshotReq) (*Snapshot, error)
+	mustEmbedUnimplementedSetsServer()
+}
+
+// UnimplementedSetsServer must be embedded to have forward compatible implementations.
+type UnimplementedSetsServer struct {
+}
+
+func (UnimplementedSetsServer) Watch(Sets_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedSetsServer) GetSnapshot(context.Context, *SnapshotReq) (*Snapshot, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSnapshot not implemented")
+}
+func (UnimplementedSetsServer) mustEmbedUnimplementedSetsServer() {}
+
+// UnsafeSetsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SetsServer will
+// result in compilation errors.
+type UnsafeSetsServer interface {
+	mustEmbedUnimplementedSetsServer()
+}
+
+func RegisterSetsServer(s grpc.ServiceRegistrar, srv SetsServer) {
+	s.RegisterService(&Sets_ServiceDesc, srv)
+}
+
+func _Sets_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(SetsServer).Watch(&setsWatchServer{stream})
+}
+
+type Sets_WatchServer interface {
+	Send(*OpItem) error
+	Recv() (*WatchReq, error)
+	grpc.ServerStream
+}
+
+type setsWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *setsWatchServer) Send(m *OpItem) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *setsWatchServer) Recv() (*WatchReq, error) {
+	m := new(WatchReq)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Sets_GetSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SetsServer).GetSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/localv2.Sets/GetSnapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SetsServer).GetSnapshot(ctx, req.(*SnapshotReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Sets_ServiceDesc is the grpc.ServiceDesc for Sets service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Sets_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "localv2.Sets",
+	HandlerType: (*SetsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetSnapshot",
+			Handler:    _Sets_GetSnapshot_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _Sets_Watch_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "api/localv2/api.proto",
+}
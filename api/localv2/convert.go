@@ -0,0 +1,365 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package localv2
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+
+	"sigs.k8s.io/kpng/api/localv1"
+)
+
+func ipSetFromV1(set *localv1.IPSet) *IPSet {
+	if set == nil {
+		return nil
+	}
+	return &IPSet{
+		V4: append([]string(nil), set.V4...),
+		V6: append([]string(nil), set.V6...),
+	}
+}
+
+func ipSetToV1(set *IPSet) *localv1.IPSet {
+	if set == nil {
+		return nil
+	}
+	return &localv1.IPSet{
+		V4: append([]string(nil), set.V4...),
+		V6: append([]string(nil), set.V6...),
+	}
+}
+
+func trafficPolicy(local bool) TrafficPolicy {
+	if local {
+		return TrafficPolicy_Local
+	}
+	return TrafficPolicy_Cluster
+}
+
+// ServiceFromV1 converts a localv1 service. localv1 doesn't tell the primary IP family, so IPv4
+// is assumed to be the primary one of dual-stack services.
+func ServiceFromV1(s *localv1.Service) *Service {
+	svc := &Service{
+		Namespace:             s.Namespace,
+		Name:                  s.Name,
+		Type:                  ServiceType(ServiceType_value[s.Type]),
+		Labels:                s.Labels,
+		Annotations:           s.Annotations,
+		MapIP:                 s.MapIP,
+		ExternalTrafficPolicy: trafficPolicy(s.ExternalTrafficToLocal),
+		InternalTrafficPolicy: trafficPolicy(s.InternalTrafficToLocal),
+	}
+
+	if s.IPs != nil {
+		svc.IPs = &ServiceIPs{
+			ClusterIPs:           ipSetFromV1(s.IPs.ClusterIPs),
+			ExternalIPs:          ipSetFromV1(s.IPs.ExternalIPs),
+			LoadBalancerIPs:      ipSetFromV1(s.IPs.LoadBalancerIPs),
+			Headless:             s.IPs.Headless,
+			ProxyLoadBalancerIPs: ipSetFromV1(s.IPs.ProxyLoadBalancerIPs),
+		}
+
+		if len(s.IPs.ClusterIPs.GetV4()) != 0 {
+			svc.IPFamilies = append(svc.IPFamilies, IPFamily_IPv4)
+		}
+		if len(s.IPs.ClusterIPs.GetV6()) != 0 {
+			svc.IPFamilies = append(svc.IPFamilies, IPFamily_IPv6)
+		}
+	}
+
+	for _, filter := range s.IPFilters {
+		svc.IPFilters = append(svc.IPFilters, &IPFilter{
+			TargetIPs:    ipSetFromV1(filter.TargetIPs),
+			SourceRanges: filter.SourceRanges,
+		})
+	}
+
+	for _, port := range s.Ports {
+		svc.Ports = append(svc.Ports, &PortMapping{
+			Name:           port.Name,
+			Protocol:       Protocol(port.Protocol),
+			Port:           port.Port,
+			NodePort:       port.NodePort,
+			TargetPort:     port.TargetPort,
+			TargetPortName: port.TargetPortName,
+		})
+	}
+
+	if clientIP := s.GetClientIP(); clientIP != nil {
+		svc.SessionAffinity = &Service_ClientIP{
+			ClientIP: &ClientIPAffinity{TimeoutSeconds: clientIP.TimeoutSeconds},
+		}
+	}
+
+	return svc
+}
+
+// ServiceToV1 converts a service for the localv1 consumers.
+func ServiceToV1(s *Service) *localv1.Service {
+	svc := &localv1.Service{
+		Namespace:              s.Namespace,
+		Name:                   s.Name,
+		Labels:                 s.Labels,
+		Annotations:            s.Annotations,
+		MapIP:                  s.MapIP,
+		ExternalTrafficToLocal: s.ExternalTrafficPolicy == TrafficPolicy_Local,
+		InternalTrafficToLocal: s.InternalTrafficPolicy == TrafficPolicy_Local,
+	}
+
+	if s.Type != ServiceType_UnknownServiceType {
+		svc.Type = s.Type.String()
+	}
+
+	if s.IPs != nil {
+		svc.IPs = &localv1.ServiceIPs{
+			ClusterIPs:           ipSetToV1(s.IPs.ClusterIPs),
+			ExternalIPs:          ipSetToV1(s.IPs.ExternalIPs),
+			LoadBalancerIPs:      ipSetToV1(s.IPs.LoadBalancerIPs),
+			Headless:             s.IPs.Headless,
+			ProxyLoadBalancerIPs: ipSetToV1(s.IPs.ProxyLoadBalancerIPs),
+		}
+	}
+
+	for _, filter := range s.IPFilters {
+		svc.IPFilters = append(svc.IPFilters, &localv1.IPFilter{
+			TargetIPs:    ipSetToV1(filter.TargetIPs),
+			SourceRanges: filter.SourceRanges,
+		})
+	}
+
+	for _, port := range s.Ports {
+		svc.Ports = append(svc.Ports, &localv1.PortMapping{
+			Name:           port.Name,
+			Protocol:       localv1.Protocol(port.Protocol),
+			Port:           port.Port,
+			NodePort:       port.NodePort,
+			TargetPort:     port.TargetPort,
+			TargetPortName: port.TargetPortName,
+		})
+	}
+
+	if clientIP := s.GetClientIP(); clientIP != nil {
+		svc.SessionAffinity = &localv1.Service_ClientIP{
+			ClientIP: &localv1.ClientIPAffinity{TimeoutSeconds: clientIP.TimeoutSeconds},
+		}
+	}
+
+	return svc
+}
+
+// EndpointFromV1 converts a localv1 endpoint. The localv1 endpoints without conditions are the
+// ready ones.
+func EndpointFromV1(ep *localv1.Endpoint) *Endpoint {
+	endpoint := &Endpoint{
+		Hostname:   ep.Hostname,
+		IPs:        ipSetFromV1(ep.IPs),
+		Local:      ep.Local,
+		Conditions: &EndpointConditions{Ready: true, Serving: true},
+		Weight:     ep.Weight,
+	}
+
+	if c := ep.Conditions; c != nil {
+		endpoint.Conditions = &EndpointConditions{Ready: c.Ready, Serving: c.Serving, Terminating: c.Terminating}
+	}
+
+	for _, override := range ep.PortOverrides {
+		endpoint.PortOverrides = append(endpoint.PortOverrides, &PortName{Name: override.Name, Port: override.Port})
+	}
+
+	if ep.Scopes != nil {
+		endpoint.Scopes = &EndpointScopes{Internal: ep.Scopes.Internal, External: ep.Scopes.External}
+	}
+
+	return endpoint
+}
+
+// Restrict clears the fields of the capabilities not negotiated.
+func (ep *Endpoint) Restrict(negotiated *Capabilities) {
+	if !negotiated.Has(Capability_WithEndpointConditions) {
+		ep.Conditions = nil
+	}
+	if !negotiated.Has(Capability_WithEndpointWeights) {
+		ep.Weight = 0
+	}
+}
+
+// EndpointToV1 converts an endpoint for the localv1 consumers, returning nil if it's not ready
+// since they only expect ready endpoints. Endpoints without conditions are considered ready.
+func EndpointToV1(ep *Endpoint) *localv1.Endpoint {
+	if ep.Conditions != nil && !ep.Conditions.Ready {
+		return nil
+	}
+
+	endpoint := &localv1.Endpoint{
+		Hostname: ep.Hostname,
+		IPs:      ipSetToV1(ep.IPs),
+		Local:    ep.Local,
+		Weight:   ep.Weight,
+	}
+
+	for _, override := range ep.PortOverrides {
+		endpoint.PortOverrides = append(endpoint.PortOverrides, &localv1.PortName{Name: override.Name, Port: override.Port})
+	}
+
+	if ep.Scopes != nil {
+		endpoint.Scopes = &localv1.EndpointScopes{Internal: ep.Scopes.Internal, External: ep.Scopes.External}
+	}
+
+	return endpoint
+}
+
+// OpItemFromV1 converts an op of a localv1 stream, leaving out the fields of the capabilities
+// not negotiated. It returns nil for the ops of the ExternalName services, not in localv2.
+func OpItemFromV1(op *localv1.OpItem, negotiated *Capabilities) (*OpItem, error) {
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Sync:
+		return &OpItem{Op: &OpItem_Sync{Sync: &EmptyOp{}}}, nil
+
+	case *localv1.OpItem_Reset_:
+		return &OpItem{Op: &OpItem_Reset_{Reset_: &EmptyOp{}}}, nil
+
+	case *localv1.OpItem_Delete:
+		if v.Delete.Set == localv1.Set_ExternalNamesSet {
+			return nil, nil
+		}
+
+		set, err := setFromV1(v.Delete.Set)
+		if err != nil {
+			return nil, err
+		}
+		return &OpItem{Op: &OpItem_Delete{Delete: &Ref{Set: set, Path: v.Delete.Path}}}, nil
+
+	case *localv1.OpItem_Set:
+		ref := v.Set.Ref
+		if ref.Set == localv1.Set_ExternalNamesSet {
+			return nil, nil
+		}
+
+		set, err := setFromV1(ref.Set)
+		if err != nil {
+			return nil, err
+		}
+
+		var value proto.Message
+
+		switch set {
+		case Set_ServicesSet:
+			svc := &localv1.Service{}
+			if err := proto.Unmarshal(v.Set.Bytes, svc); err != nil {
+				return nil, err
+			}
+			value = ServiceFromV1(svc)
+
+		case Set_EndpointsSet:
+			ep := &localv1.Endpoint{}
+			if err := proto.Unmarshal(v.Set.Bytes, ep); err != nil {
+				return nil, err
+			}
+
+			endpoint := EndpointFromV1(ep)
+			endpoint.Restrict(negotiated)
+			value = endpoint
+		}
+
+		ba, err := proto.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return &OpItem{Op: &OpItem_Set{Set: &Value{Ref: &Ref{Set: set, Path: ref.Path}, Bytes: ba}}}, nil
+	}
+
+	return nil, fmt.Errorf("unknown op %T", op.Op)
+}
+
+// OpItemToV1 converts an op of a localv2 stream for the localv1 consumers. It returns nil for
+// the ops they don't know (Hello), and turns the endpoints that are not ready into deletes.
+func OpItemToV1(op *OpItem) (*localv1.OpItem, error) {
+	switch v := op.Op.(type) {
+	case *OpItem_Hello:
+		return nil, nil
+
+	case *OpItem_Sync:
+		return &localv1.OpItem{Op: &localv1.OpItem_Sync{Sync: &localv1.EmptyOp{}}}, nil
+
+	case *OpItem_Reset_:
+		return &localv1.OpItem{Op: &localv1.OpItem_Reset_{Reset_: &localv1.EmptyOp{}}}, nil
+
+	case *OpItem_Delete:
+		return &localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: refToV1(v.Delete)}}, nil
+
+	case *OpItem_Set:
+		ref := refToV1(v.Set.Ref)
+
+		var value proto.Message
+
+		switch v.Set.Ref.Set {
+		case Set_ServicesSet:
+			svc := &Service{}
+			if err := proto.Unmarshal(v.Set.Bytes, svc); err != nil {
+				return nil, err
+			}
+			value = ServiceToV1(svc)
+
+		case Set_EndpointsSet:
+			ep := &Endpoint{}
+			if err := proto.Unmarshal(v.Set.Bytes, ep); err != nil {
+				return nil, err
+			}
+
+			endpoint := EndpointToV1(ep)
+			if endpoint == nil {
+				return &localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: ref}}, nil
+			}
+			value = endpoint
+
+		default:
+			return nil, fmt.Errorf("unknown set %v", v.Set.Ref.Set)
+		}
+
+		ba, err := proto.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return &localv1.OpItem{Op: &localv1.OpItem_Set{Set: &localv1.Value{Ref: ref, Bytes: ba}}}, nil
+	}
+
+	return nil, fmt.Errorf("unknown op %T", op.Op)
+}
+
+func setFromV1(set localv1.Set) (Set, error) {
+	switch set {
+	case localv1.Set_ServicesSet:
+		return Set_ServicesSet, nil
+	case localv1.Set_EndpointsSet:
+		return Set_EndpointsSet, nil
+	}
+	return Set_UnknownSet, fmt.Errorf("set %v is not in localv2", set)
+}
+
+func refToV1(ref *Ref) *localv1.Ref {
+	set := localv1.Set_UnknownSet
+	switch ref.Set {
+	case Set_ServicesSet:
+		set = localv1.Set_ServicesSet
+	case Set_EndpointsSet:
+		set = localv1.Set_EndpointsSet
+	}
+	return &localv1.Ref{Set: set, Path: ref.Path}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package localv2
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"sigs.k8s.io/kpng/api/localv1"
+)
+
+func TestServiceRoundTrip(t *testing.T) {
+	v1 := &localv1.Service{
+		Namespace: "ns",
+		Name:      "svc",
+		Type:      "LoadBalancer",
+		Labels:    map[string]string{"app": "web"},
+		IPs: &localv1.ServiceIPs{
+			ClusterIPs:           localv1.NewIPSet("10.0.0.1", "fd00::1"),
+			ExternalIPs:          localv1.NewIPSet("192.168.1.1"),
+			LoadBalancerIPs:      localv1.NewIPSet("1.2.3.4", "1.2.3.5"),
+			ProxyLoadBalancerIPs: localv1.NewIPSet("1.2.3.5"),
+		},
+		IPFilters: []*localv1.IPFilter{{SourceRanges: []string{"1.0.0.0/8"}}},
+		Ports: []*localv1.PortMapping{
+			{Name: "http", Protocol: localv1.Protocol_TCP, Port: 80, NodePort: 30080, TargetPortName: "web"},
+		},
+		ExternalTrafficToLocal: true,
+		SessionAffinity: &localv1.Service_ClientIP{
+			ClientIP: &localv1.ClientIPAffinity{TimeoutSeconds: 10},
+		},
+	}
+
+	v2 := ServiceFromV1(v1)
+
+	if v2.Type != ServiceType_LoadBalancer {
+		t.Errorf("expected a LoadBalancer, got %v", v2.Type)
+	}
+	if len(v2.IPFamilies) != 2 || v2.IPFamilies[0] != IPFamily_IPv4 || v2.IPFamilies[1] != IPFamily_IPv6 {
+		t.Errorf("expected IPv4 and IPv6 families, got %v", v2.IPFamilies)
+	}
+	if v2.ExternalTrafficPolicy != TrafficPolicy_Local || v2.InternalTrafficPolicy != TrafficPolicy_Cluster {
+		t.Errorf("unexpected traffic policies: %v/%v", v2.ExternalTrafficPolicy, v2.InternalTrafficPolicy)
+	}
+
+	if back := ServiceToV1(v2); !proto.Equal(v1, back) {
+		t.Errorf("round trip changed the service:\n%v\n%v", v1, back)
+	}
+}
+
+func TestEndpointToV1(t *testing.T) {
+	ep := &Endpoint{IPs: &IPSet{V4: []string{"10.1.0.1"}}, Conditions: &EndpointConditions{Serving: true, Terminating: true}}
+	if EndpointToV1(ep) != nil {
+		t.Error("expected a terminating endpoint not to be sent to localv1 consumers")
+	}
+
+	ep.Conditions = nil
+	if v1 := EndpointToV1(ep); v1 == nil || v1.IPs.First() != "10.1.0.1" {
+		t.Errorf("expected an endpoint without conditions to be converted, got %v", v1)
+	}
+}
+
+func TestEndpointFromV1(t *testing.T) {
+	ep := &localv1.Endpoint{IPs: &localv1.IPSet{V4: []string{"10.1.0.1"}}}
+	if c := EndpointFromV1(ep).Conditions; !c.Ready || !c.Serving || c.Terminating {
+		t.Errorf("expected an endpoint without conditions to be ready, got %v", c)
+	}
+
+	ep.Conditions = &localv1.EndpointConditions{Serving: true, Terminating: true}
+	if c := EndpointFromV1(ep).Conditions; c.Ready || !c.Serving || !c.Terminating {
+		t.Errorf("expected the conditions of the endpoint, got %v", c)
+	}
+}
+
+func TestOpItemFromV1(t *testing.T) {
+	ba, _ := proto.Marshal(&localv1.Endpoint{IPs: localv1.NewIPSet("10.1.0.1"), Weight: 5})
+	op := &localv1.OpItem{Op: &localv1.OpItem_Set{Set: &localv1.Value{
+		Ref:   &localv1.Ref{Set: localv1.Set_EndpointsSet, Path: "ns/svc/ep"},
+		Bytes: ba,
+	}}}
+
+	for _, caps := range []*Capabilities{
+		{Version: Version_V2},
+		{Version: Version_V2, Capabilities: []Capability{Capability_WithEndpointConditions, Capability_WithEndpointWeights}},
+	} {
+		v2, err := OpItemFromV1(op, caps)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ep := &Endpoint{}
+		if err := proto.Unmarshal(v2.GetSet().Bytes, ep); err != nil {
+			t.Fatal(err)
+		}
+
+		if (ep.Conditions != nil) != caps.Has(Capability_WithEndpointConditions) {
+			t.Errorf("with %v, unexpected conditions %v", caps, ep.Conditions)
+		}
+		if (ep.Weight != 0) != caps.Has(Capability_WithEndpointWeights) {
+			t.Errorf("with %v, unexpected weight %d", caps, ep.Weight)
+		}
+
+		back, err := OpItemToV1(v2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if back.GetSet().Ref.Path != "ns/svc/ep" || back.GetSet().Ref.Set != localv1.Set_EndpointsSet {
+			t.Errorf("unexpected ref %v", back.GetSet().Ref)
+		}
+	}
+
+	if _, err := OpItemFromV1(&localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: &localv1.Ref{Set: localv1.Set_GlobalNodeInfos}}}, Supported); err == nil {
+		t.Error("expected an error on a set not in localv2")
+	}
+
+	if v2, err := OpItemFromV1(&localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: &localv1.Ref{Set: localv1.Set_ExternalNamesSet}}}, Supported); v2 != nil || err != nil {
+		t.Errorf("expected the ExternalName services to be left out, got %v, %v", v2, err)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	for _, tc := range []struct {
+		requested *Capabilities
+		version   Version
+		caps      []Capability
+	}{
+		{nil, Version_V2, nil},
+		{&Capabilities{Version: Version_V1}, Version_V1, nil},
+		{&Capabilities{Version: 42, Capabilities: []Capability{42, Capability_WithEndpointWeights, Capability_WithEndpointWeights}}, Version_V2, []Capability{Capability_WithEndpointWeights}},
+	} {
+		negotiated := Negotiate(tc.requested, Supported)
+
+		if negotiated.Version != tc.version {
+			t.Errorf("%v: expected version %v, got %v", tc.requested, tc.version, negotiated.Version)
+		}
+		if len(negotiated.Capabilities) != len(tc.caps) {
+			t.Errorf("%v: expected capabilities %v, got %v", tc.requested, tc.caps, negotiated.Capabilities)
+			continue
+		}
+		for i, c := range tc.caps {
+			if negotiated.Capabilities[i] != c {
+				t.Errorf("%v: expected capabilities %v, got %v", tc.requested, tc.caps, negotiated.Capabilities)
+			}
+		}
+	}
+}
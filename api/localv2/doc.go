@@ -0,0 +1,28 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package localv2 is the v2 of the local API, the state of a node sent to the backends.
+//
+// Compared to localv1, the IP families, service types and traffic policies are enums, the
+// endpoints have conditions, and the stream starts with a negotiation: the first WatchReq gives
+// the version and capabilities supported by the client, and the server answers with a Hello op
+// giving the ones used for the stream (see Negotiate). Fields tied to a capability are only set
+// when it was negotiated, so a server can add capabilities without breaking older clients.
+//
+// The server keeps serving localv1 along localv2, so the existing backends keep working. The
+// localv1 consumers can move to localv2 one at a time: OpItemToV1 turns a localv2 stream into
+// the localv1 one they expect, and the *FromV1/*ToV1 functions convert the messages.
+package localv2
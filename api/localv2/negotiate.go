@@ -0,0 +1,58 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package localv2
+
+// CurrentVersion is the highest version of the API implemented by this package.
+const CurrentVersion = Version_V2
+
+// Supported are the capabilities implemented by this package.
+var Supported = &Capabilities{
+	Version: CurrentVersion,
+	Capabilities: []Capability{
+		Capability_WithEndpointConditions,
+		Capability_WithEndpointWeights,
+	},
+}
+
+// Has returns true if the capability is in the set.
+func (c *Capabilities) Has(capability Capability) bool {
+	for _, have := range c.GetCapabilities() {
+		if have == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// Negotiate returns the capabilities to use with a client requesting the given ones: the lowest
+// version of both sides (the current one if the client didn't give any), and the capabilities
+// supported by both sides.
+func Negotiate(requested, supported *Capabilities) *Capabilities {
+	negotiated := &Capabilities{Version: supported.GetVersion()}
+
+	if v := requested.GetVersion(); v != Version_UnknownVersion && v < negotiated.Version {
+		negotiated.Version = v
+	}
+
+	for _, capability := range requested.GetCapabilities() {
+		if supported.Has(capability) && !negotiated.Has(capability) {
+			negotiated.Capabilities = append(negotiated.Capabilities, capability)
+		}
+	}
+
+	return negotiated
+}
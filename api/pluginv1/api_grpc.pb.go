@@ -0,0 +1,144 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.18.1
+// source: api/pluginv1/api.proto
+
+package pluginv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// SinkClient is the client API for Sink service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SinkClient interface {
+	// Apply receives the operations computed by kpng, exactly as a local
+	// sink would get them. The plugin must send one Ack after each Sync
+	// operation, once the state up to that point has been programmed.
+	Apply(ctx context.Context, opts ...grpc.CallOption) (Sink_ApplyClient, error)
+}
+
+type sinkClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSinkClient(cc grpc.ClientConnInterface) SinkClient {
+	return &sinkClient{cc}
+}
+
+func (c *sinkClient) Apply(ctx context.Context, opts ...grpc.CallOption) (Sink_ApplyClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Sink_ServiceDesc.Streams[0], "/pluginv1.Sink/Apply", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &sinkApplyClient{stream}
+	return x, nil
+}
+
+type Sink_ApplyClient interface {
+	Send(*localv1.OpItem) error
+	Recv() (*Ack, error)
+	grpc.ClientStream
+}
+
+type sinkApplyClient struct {
+	grpc.ClientStream
+}
+
+func (x *sinkApplyClient) Send(m *localv1.OpItem) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *sinkApplyClient) Recv() (*Ack, error) {
+	m := new(Ack)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SinkServer is the server API for Sink service.
+// All implementations must embed UnimplementedSinkServer
+// for forward compatibility
+type SinkServer interface {
+	// Apply receives the operations computed by kpng, exactly as a local
+	// sink would get them. The plugin must send one Ack after each Sync
+	// operation, once the state up to that point has been programmed.
+	Apply(Sink_ApplyServer) error
+	mustEmbedUnimplementedSinkServer()
+}
+
+// UnimplementedSinkServer must be embedded to have forward compatible implementations.
+type UnimplementedSinkServer struct {
+}
+
+func (UnimplementedSinkServer) Apply(Sink_ApplyServer) error {
+	return status.Errorf(codes.Unimplemented, "method Apply not implemented")
+}
+func (UnimplementedSinkServer) mustEmbedUnimplementedSinkServer() {}
+
+// UnsafeSinkServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SinkServer will
+// result in compilation errors.
+type UnsafeSinkServer interface {
+	mustEmbedUnimplementedSinkServer()
+}
+
+func RegisterSinkServer(s grpc.ServiceRegistrar, srv SinkServer) {
+	s.RegisterService(&Sink_ServiceDesc, srv)
+}
+
+func _Sink_Apply_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(SinkServer).Apply(&sinkApplyServer{stream})
+}
+
+type Sink_ApplyServer interface {
+	Send(*Ack) error
+	Recv() (*localv1.OpItem, error)
+	grpc.ServerStream
+}
+
+type sinkApplyServer struct {
+	grpc.ServerStream
+}
+
+func (x *sinkApplyServer) Send(m *Ack) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *sinkApplyServer) Recv() (*localv1.OpItem, error) {
+	m := new(localv1.OpItem)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Sink_ServiceDesc is the grpc.ServiceDesc for Sink service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Sink_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pluginv1.Sink",
+	HandlerType: (*SinkServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Apply",
+			Handler:       _Sink_Apply_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "api/pluginv1/api.proto",
+}
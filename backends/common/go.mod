@@ -0,0 +1,18 @@
+module sigs.k8s.io/kpng/backends/common
+
+go 1.19
+
+require (
+	k8s.io/apimachinery v0.25.2
+	sigs.k8s.io/kpng/api v0.0.0-20220824013548-88b8a1d9bc62
+)
+
+require (
+	github.com/golang/protobuf v1.5.2 // indirect
+	golang.org/x/net v0.0.0-20221004154528-8021a29435af // indirect
+	golang.org/x/sys v0.0.0-20221010170243-090e33056c14 // indirect
+	golang.org/x/text v0.3.7 // indirect
+	google.golang.org/genproto v0.0.0-20221010155953-15ba04fc1c0e // indirect
+	google.golang.org/grpc v1.50.0 // indirect
+	google.golang.org/protobuf v1.28.1 // indirect
+)
@@ -0,0 +1,51 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package common holds the types shared by the backends, so they don't depend on each other.
+package common
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+// ServicePortName carries a namespace + name + portname. This is the unique
+// identifier for a load-balanced service.
+type ServicePortName struct {
+	types.NamespacedName
+	Port     string
+	Protocol localv1.Protocol
+}
+
+func (spn ServicePortName) String() string {
+	return fmt.Sprintf("%s%s", spn.NamespacedName.String(), fmtPortName(spn.Port))
+}
+
+func fmtPortName(in string) string {
+	if in == "" {
+		return ""
+	}
+	return fmt.Sprintf(":%s", in)
+}
+
+// IsServiceIPSet returns true if the service has a cluster IP.
+func IsServiceIPSet(service *localv1.Service) bool {
+	clusterIPs := service.GetIPs().GetClusterIPs()
+	return len(clusterIPs.GetV4()) > 0 || len(clusterIPs.GetV6()) > 0
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+func TestServicePortNameString(t *testing.T) {
+	name := types.NamespacedName{Namespace: "ns", Name: "svc"}
+
+	for _, tc := range []struct {
+		spn      ServicePortName
+		expected string
+	}{
+		{ServicePortName{NamespacedName: name}, "ns/svc"},
+		{ServicePortName{NamespacedName: name, Port: "http", Protocol: localv1.Protocol_TCP}, "ns/svc:http"},
+	} {
+		if s := tc.spn.String(); s != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, s)
+		}
+	}
+}
+
+func TestIsServiceIPSet(t *testing.T) {
+	for _, tc := range []struct {
+		svc      *localv1.Service
+		expected bool
+	}{
+		{&localv1.Service{}, false},
+		{&localv1.Service{IPs: &localv1.ServiceIPs{ClusterIPs: &localv1.IPSet{}, Headless: true}}, false},
+		{&localv1.Service{IPs: &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.0.0.1")}}, true},
+		{&localv1.Service{IPs: &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("fd00::1")}}, true},
+	} {
+		if set := IsServiceIPSet(tc.svc); set != tc.expected {
+			t.Errorf("%v: expected %v, got %v", tc.svc, tc.expected, set)
+		}
+	}
+}
@@ -56,6 +56,22 @@ struct {
   __uint(max_entries, DEFAULT_MAX_EBPF_MAP_ENTRIES);
 } v4_backend_map SEC(".maps");
 
+/* Per-service counters, by service frontend (backend_slot 0), read by the
+ * userspace agent.
+ */
+struct svc_stats {
+  __u64 hits;         /* connections translated to a backend */
+  __u64 misses;       /* backend slot or backend lookups failed */
+  __u64 no_endpoints; /* connections to a service without backends */
+};
+
+struct {
+  __uint(type, BPF_MAP_TYPE_PERCPU_HASH);
+  __type(key, struct V4_key);
+  __type(value, struct svc_stats);
+  __uint(max_entries, DEFAULT_MAX_EBPF_MAP_ENTRIES);
+} v4_svc_stats_map SEC(".maps");
+
 static __always_inline struct lb4_service *
 lb4_lookup_service(struct V4_key *key) {
   struct lb4_service *svc;
@@ -79,6 +95,20 @@ static __always_inline __u64 sock_select_slot(struct bpf_sock_addr *ctx) {
   return ctx->protocol == IPPROTO_TCP ? bpf_get_prandom_u32() : 0;
 }
 
+static __always_inline struct svc_stats *
+lb4_lookup_stats(const struct V4_key *frontend) {
+  struct svc_stats *stats;
+  struct svc_stats zero = {};
+
+  stats = bpf_map_lookup_elem(&v4_svc_stats_map, frontend);
+  if (stats) {
+    return stats;
+  }
+
+  bpf_map_update_elem(&v4_svc_stats_map, frontend, &zero, BPF_NOEXIST);
+  return bpf_map_lookup_elem(&v4_svc_stats_map, frontend);
+}
+
 static __always_inline struct lb4_backend *
 __lb4_lookup_backend(__u32 backend_id) {
   return bpf_map_lookup_elem(&v4_backend_map, &backend_id);
@@ -150,6 +180,7 @@ static __always_inline int __sock4_fwd(struct bpf_sock_addr *ctx) {
   struct lb4_service *svc;
   struct lb4_service *backend_slot;
   struct lb4_backend *backend = NULL;
+  struct svc_stats *stats;
 
   __u32 backend_id = 0;
 
@@ -158,6 +189,15 @@ static __always_inline int __sock4_fwd(struct bpf_sock_addr *ctx) {
     return -ENXIO;
   }
 
+  stats = lb4_lookup_stats(&key);
+
+  if (svc->count == 0) {
+    if (stats) {
+      stats->no_endpoints++;
+    }
+    return -ENOENT;
+  }
+
   // Logs are in /sys/kernel/debug/tracing/trace_pipe
 
   const char debug_str[] = "Entering the kpng ebpf backend, caught a\
@@ -169,6 +209,9 @@ static __always_inline int __sock4_fwd(struct bpf_sock_addr *ctx) {
     key.backend_slot = (sock_select_slot(ctx) % svc->count) + 1;
     backend_slot = __lb4_lookup_backend_slot(&key);
     if (!backend_slot) {
+      if (stats) {
+        stats->misses++;
+      }
       return -ENOENT;
     }
 
@@ -177,6 +220,9 @@ static __always_inline int __sock4_fwd(struct bpf_sock_addr *ctx) {
   }
 
   if (!backend) {
+    if (stats) {
+      stats->misses++;
+    }
     return -ENOENT;
   }
 
@@ -187,6 +233,10 @@ static __always_inline int __sock4_fwd(struct bpf_sock_addr *ctx) {
   ctx->user_ip4 = backend->address;
   ctx_set_port(ctx, backend->port);
 
+  if (stats) {
+    stats->hits++;
+  }
+
   return 0;
 }
 
@@ -29,6 +29,12 @@ type bpfLb4Service struct {
 	Pad         [2]uint8
 }
 
+type bpfSvcStats struct {
+	Hits        uint64
+	Misses      uint64
+	NoEndpoints uint64
+}
+
 type bpfV4Key struct {
 	Address     uint32
 	Dport       uint16
@@ -83,8 +89,9 @@ type bpfProgramSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type bpfMapSpecs struct {
-	V4BackendMap *ebpf.MapSpec `ebpf:"v4_backend_map"`
-	V4SvcMap     *ebpf.MapSpec `ebpf:"v4_svc_map"`
+	V4BackendMap  *ebpf.MapSpec `ebpf:"v4_backend_map"`
+	V4SvcMap      *ebpf.MapSpec `ebpf:"v4_svc_map"`
+	V4SvcStatsMap *ebpf.MapSpec `ebpf:"v4_svc_stats_map"`
 }
 
 // bpfObjects contains all objects after they have been loaded into the kernel.
@@ -106,14 +113,16 @@ func (o *bpfObjects) Close() error {
 //
 // It can be passed to loadBpfObjects or ebpf.CollectionSpec.LoadAndAssign.
 type bpfMaps struct {
-	V4BackendMap *ebpf.Map `ebpf:"v4_backend_map"`
-	V4SvcMap     *ebpf.Map `ebpf:"v4_svc_map"`
+	V4BackendMap  *ebpf.Map `ebpf:"v4_backend_map"`
+	V4SvcMap      *ebpf.Map `ebpf:"v4_svc_map"`
+	V4SvcStatsMap *ebpf.Map `ebpf:"v4_svc_stats_map"`
 }
 
 func (m *bpfMaps) Close() error {
 	return _BpfClose(
 		m.V4BackendMap,
 		m.V4SvcMap,
+		m.V4SvcStatsMap,
 	)
 }
 
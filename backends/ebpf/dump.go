@@ -0,0 +1,198 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ebpf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	cebpf "github.com/cilium/ebpf"
+)
+
+// Dump prints the services, their backend slots and counters, and the backends, from the maps
+// pinned under pinPath by a running ebpf backend.
+func Dump(w io.Writer, pinPath string) error {
+	mapsPath := mapsPinPath(pinPath, mapsVersion)
+
+	svcMap, err := cebpf.LoadPinnedMap(filepath.Join(mapsPath, "v4_svc_map"), nil)
+	if err != nil {
+		return fmt.Errorf("failed to load the service map (is the backend running with --pin-path=%s?): %w", pinPath, err)
+	}
+	defer svcMap.Close()
+
+	backendMap, err := cebpf.LoadPinnedMap(filepath.Join(mapsPath, "v4_backend_map"), nil)
+	if err != nil {
+		return fmt.Errorf("failed to load the backend map: %w", err)
+	}
+	defer backendMap.Close()
+
+	// the stats map is missing if the running backend predates it
+	statsMap, err := cebpf.LoadPinnedMap(filepath.Join(mapsPath, statsMapName), nil)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to load the service stats map: %w", err)
+	}
+	if statsMap != nil {
+		defer statsMap.Close()
+	}
+
+	var (
+		svcKey    bpfV4Key
+		svcValue  bpfLb4Service
+		backendID uint32
+		backend   bpfLb4Backend
+		dump      = mapsDump{
+			services: map[bpfV4Key]bpfLb4Service{},
+			backends: map[uint32]bpfLb4Backend{},
+		}
+	)
+
+	iter := svcMap.Iterate()
+	for iter.Next(&svcKey, &svcValue) {
+		dump.services[svcKey] = svcValue
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to iterate the service map: %w", err)
+	}
+
+	iter = backendMap.Iterate()
+	for iter.Next(&backendID, &backend) {
+		dump.backends[backendID] = backend
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to iterate the backend map: %w", err)
+	}
+
+	if statsMap != nil {
+		dump.stats = map[bpfV4Key]bpfSvcStats{}
+		for key := range dump.services {
+			if key.BackendSlot != 0 {
+				continue
+			}
+			total, _, err := lookupStats(statsMap, key)
+			if err != nil {
+				return fmt.Errorf("failed to get the stats of %s: %w", frontendString(key), err)
+			}
+			dump.stats[key] = total
+		}
+	}
+
+	return dump.write(w)
+}
+
+// mapsDump holds the entries of the maps, stats being nil without a stats map.
+type mapsDump struct {
+	services map[bpfV4Key]bpfLb4Service
+	backends map[uint32]bpfLb4Backend
+	stats    map[bpfV4Key]bpfSvcStats
+}
+
+// write prints the services sorted by frontend with their backend slots, and
+// the backends sorted by id.
+func (d mapsDump) write(w io.Writer) error {
+	var (
+		frontends  []bpfV4Key
+		slots      = map[bpfV4Key][]bpfLb4Service{}
+		backendIDs []uint32
+	)
+
+	for key, value := range d.services {
+		frontend := key
+		frontend.BackendSlot = 0
+
+		if key.BackendSlot == 0 {
+			frontends = append(frontends, frontend)
+			continue
+		}
+
+		if len(slots[frontend]) < int(key.BackendSlot) {
+			slots[frontend] = append(slots[frontend], make([]bpfLb4Service, int(key.BackendSlot)-len(slots[frontend]))...)
+		}
+		slots[frontend][key.BackendSlot-1] = value
+	}
+
+	for id := range d.backends {
+		backendIDs = append(backendIDs, id)
+	}
+
+	sort.Slice(frontends, func(i, j int) bool {
+		a, b := frontends[i], frontends[j]
+		if a.Address != b.Address {
+			return bytes.Compare(addressBytes(a.Address), addressBytes(b.Address)) < 0
+		}
+		return networkPort(a.Dport) < networkPort(b.Dport)
+	})
+	sort.Slice(backendIDs, func(i, j int) bool { return backendIDs[i] < backendIDs[j] })
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "SERVICE\tSLOTS\tHITS\tMISSES\tNO-ENDPOINTS")
+	for _, frontend := range frontends {
+		hits, misses, noEndpoints := "-", "-", "-"
+		if d.stats != nil {
+			total := d.stats[frontend]
+			hits, misses, noEndpoints = fmt.Sprint(total.Hits), fmt.Sprint(total.Misses), fmt.Sprint(total.NoEndpoints)
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", frontendString(frontend), d.services[frontend].Count, hits, misses, noEndpoints)
+
+		for i, slot := range slots[frontend] {
+			target := "<missing>"
+			if backend, ok := d.backends[slot.BackendId]; ok {
+				target = backendString(backend)
+			}
+			fmt.Fprintf(tw, "  slot %d\t-> backend %d (%s)\t\t\t\n", i+1, slot.BackendId, target)
+		}
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "BACKEND\tADDRESS")
+	for _, id := range backendIDs {
+		fmt.Fprintf(tw, "%d\t%s\n", id, backendString(d.backends[id]))
+	}
+
+	return tw.Flush()
+}
+
+// addressBytes returns the IPv4 address of a map entry, stored in network order.
+func addressBytes(address uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, address)
+	return b
+}
+
+// networkPort returns the port of a map entry, stored in network order.
+func networkPort(port uint16) uint16 {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, port)
+	return binary.BigEndian.Uint16(b)
+}
+
+func frontendString(key bpfV4Key) string {
+	return net.JoinHostPort(net.IP(addressBytes(key.Address)).String(), fmt.Sprint(networkPort(key.Dport)))
+}
+
+func backendString(backend bpfLb4Backend) string {
+	return net.JoinHostPort(net.IP(addressBytes(backend.Address)).String(), fmt.Sprint(networkPort(backend.Port)))
+}
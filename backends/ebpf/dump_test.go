@@ -0,0 +1,137 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ebpf
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+// testMapping returns the service port ip:port, with endpoints on targetPort.
+func testMapping(name, ip string, port, targetPort int, endpoints ...string) svcEndpointMapping {
+	mapping := svcEndpointMapping{
+		Name: types.NamespacedName{Namespace: "default", Name: name},
+		Svc:  &BaseServiceInfo{clusterIP: net.ParseIP(ip), port: port, protocol: localv1.Protocol_TCP, targetPort: targetPort},
+	}
+	for _, endpoint := range endpoints {
+		mapping.Endpoint = append(mapping.Endpoint, &localv1.Endpoint{IPs: &localv1.IPSet{V4: []string{endpoint}}})
+	}
+	return mapping
+}
+
+func TestByteOrderHelpers(t *testing.T) {
+	svcKeys, _, _, backendValues := makeEbpfMaps(testMapping("web", "10.96.1.2", 8080, 9376, "192.168.3.4"))
+
+	if ip := net.IP(addressBytes(svcKeys[0].Address)).String(); ip != "10.96.1.2" {
+		t.Errorf("expected the service address 10.96.1.2, got %s", ip)
+	}
+	if port := networkPort(svcKeys[0].Dport); port != 8080 {
+		t.Errorf("expected the service port 8080, got %d", port)
+	}
+	if s := backendString(backendValues[0]); s != "192.168.3.4:9376" {
+		t.Errorf("expected the backend 192.168.3.4:9376, got %s", s)
+	}
+
+	// the frontends sort by their address bytes, not by the value of their uint32
+	otherKeys, _, _, _ := makeEbpfMaps(testMapping("other", "9.200.1.2", 80, 80))
+	if bytes.Compare(addressBytes(otherKeys[0].Address), addressBytes(svcKeys[0].Address)) >= 0 {
+		t.Errorf("expected 9.200.1.2 before 10.96.1.2")
+	}
+}
+
+func TestDumpWrite(t *testing.T) {
+	dump := mapsDump{
+		services: map[bpfV4Key]bpfLb4Service{},
+		backends: map[uint32]bpfLb4Backend{},
+	}
+	add := func(mapping svcEndpointMapping) {
+		svcKeys, svcValues, backendKeys, backendValues := makeEbpfMaps(mapping)
+		for i, key := range svcKeys {
+			dump.services[key] = svcValues[i]
+		}
+		for i, id := range backendKeys {
+			dump.backends[id] = backendValues[i]
+		}
+	}
+	add(testMapping("web", "10.96.0.20", 80, 8080, "10.1.0.1", "10.1.0.2"))
+	add(testMapping("dns", "10.96.0.10", 53, 53, "10.1.0.3"))
+	add(testMapping("empty", "10.96.0.10", 9090, 9090))
+
+	// a slot whose backend is gone
+	webKeys, _, _, _ := makeEbpfMaps(testMapping("web", "10.96.0.20", 80, 8080))
+	dump.services[bpfV4Key{Address: webKeys[0].Address, Dport: webKeys[0].Dport, BackendSlot: 3}] = bpfLb4Service{BackendId: 42}
+
+	expected := `SERVICE          SLOTS                                 HITS  MISSES  NO-ENDPOINTS
+10.96.0.10:53    1                                     -     -       -
+  slot 1         -> backend 167837752 (10.1.0.3:53)
+10.96.0.10:9090  0                                     -     -       -
+10.96.0.20:80    2                                     -     -       -
+  slot 1         -> backend 167837777 (10.1.0.1:8080)
+  slot 2         -> backend 167837778 (10.1.0.2:8080)
+  slot 3         -> backend 42 (<missing>)
+
+BACKEND    ADDRESS
+167837752  10.1.0.3:53
+167837777  10.1.0.1:8080
+167837778  10.1.0.2:8080
+`
+
+	var out bytes.Buffer
+	if err := dump.write(&out); err != nil {
+		t.Fatal(err)
+	}
+	if got := trimLines(out.String()); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	// with the counters of the frontends, the ones without connections being zero
+	dump.stats = map[bpfV4Key]bpfSvcStats{
+		webKeys[0]: {Hits: 12, Misses: 1, NoEndpoints: 0},
+	}
+
+	expected = strings.NewReplacer(
+		"10.96.0.10:53    1                                     -     -       -",
+		"10.96.0.10:53    1                                     0     0       0",
+		"10.96.0.10:9090  0                                     -     -       -",
+		"10.96.0.10:9090  0                                     0     0       0",
+		"10.96.0.20:80    2                                     -     -       -",
+		"10.96.0.20:80    2                                     12    1       0",
+	).Replace(expected)
+
+	out.Reset()
+	if err := dump.write(&out); err != nil {
+		t.Fatal(err)
+	}
+	if got := trimLines(out.String()); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+// trimLines removes the padding of the last cells of the lines.
+func trimLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
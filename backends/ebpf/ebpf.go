@@ -29,6 +29,7 @@ import (
 	"strings"
 
 	cebpf "github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/features"
 	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/rlimit"
 
@@ -37,13 +38,14 @@ import (
 	"k8s.io/klog"
 
 	"sigs.k8s.io/kpng/client"
+	"sigs.k8s.io/kpng/client/backendcmd"
 	"sigs.k8s.io/kpng/client/lightdiffstore"
 
 	"github.com/cespare/xxhash"
 )
 
 //go:generate bpf2go -cc $BPF_CLANG -cflags $BPF_CFLAGS bpf ./bpf/cgroup_connect4.c
-func ebpfSetup() ebpfController {
+func ebpfSetup(pinPath string) ebpfController {
 	var err error
 
 	// Allow the current process to lock memory for eBPF resources.
@@ -51,9 +53,11 @@ func ebpfSetup() ebpfController {
 		klog.Fatal(err)
 	}
 
-	// Load pre-compiled programs and maps into the kernel.
+	// Load pre-compiled programs and maps into the kernel, taking over the
+	// pinned maps of the previous kpng if any.
 	objs := bpfObjects{}
-	if err := loadBpfObjects(&objs, &cebpf.CollectionOptions{}); err != nil {
+	reused, err := loadObjects(&objs, pinPath)
+	if err != nil {
 		log.Fatalf("loading objects: %v", err)
 	}
 
@@ -78,18 +82,41 @@ func ebpfSetup() ebpfController {
 	klog.Infof("Cgroup Path is %s", cgroupPath)
 
 	// Link the proxy program to the default cgroup.
-	l, err := link.AttachCgroup(link.CgroupOptions{
-		Path:    cgroupPath,
-		Attach:  cebpf.AttachCGroupInet4Connect,
-		Program: objs.Sock4Connect,
-	})
+	var l link.Link
+	if pinPath == "" {
+		l, err = link.AttachCgroup(link.CgroupOptions{
+			Path:    cgroupPath,
+			Attach:  cebpf.AttachCGroupInet4Connect,
+			Program: objs.Sock4Connect,
+		})
+	} else {
+		l, err = attachPinnedLink(pinPath, cgroupPath, objs.Sock4Connect)
+	}
 	if err != nil {
 		klog.Fatal(err)
 	}
 
+	if pinPath != "" {
+		removePreviousMaps(pinPath)
+	}
+
 	klog.Infof("Proxying packets in kernel...")
 
-	return NewEBPFController(objs, l, v1.IPv4Protocol)
+	return NewEBPFController(objs, l, v1.IPv4Protocol, reused)
+}
+
+// featureChecks checks that the kernel supports the program and maps loaded by ebpfSetup, and
+// that the cgroup2 hierarchy it attaches to is mounted.
+func featureChecks() []backendcmd.Check {
+	cgroupCheck := backendcmd.Check{Name: "cgroup2 mount"}
+	cgroupCheck.Detail, cgroupCheck.Err = detectRootCgroupPath()
+
+	return []backendcmd.Check{
+		{Name: "memlock rlimit", Err: rlimit.RemoveMemlock()},
+		{Name: "cgroup sock_addr programs", Err: features.HaveProgType(cebpf.CGroupSockAddr)},
+		{Name: "hash maps", Err: features.HaveMapType(cebpf.Hash)},
+		cgroupCheck,
+	}
 }
 
 // detectCgroupPath returns the first-found mount point of type cgroup2
@@ -140,7 +167,7 @@ func (ebc *ebpfController) Callback(ch <-chan *client.ServiceEndpoints) {
 			svcKey := fmt.Sprintf("%s/%d/%s", svcUniqueName, servicePort.Port, servicePort.Protocol)
 			baseSvcInfo := ebc.newBaseServiceInfo(servicePort, serviceEndpoints.Service)
 
-			svcEndptRelation := svcEndpointMapping{Svc: baseSvcInfo, Endpoint: serviceEndpoints.Endpoints}
+			svcEndptRelation := svcEndpointMapping{Name: svcUniqueName, Svc: baseSvcInfo, Endpoint: serviceEndpoints.Endpoints}
 			// JSON encoding of our services + EP information
 			svcEndptRelationBytes := new(bytes.Buffer)
 			json.NewEncoder(svcEndptRelationBytes).Encode(svcEndptRelation)
@@ -161,6 +188,111 @@ func (ebc *ebpfController) Callback(ch <-chan *client.ServiceEndpoints) {
 	if len(ebc.svcMap.Updated()) != 0 || len(ebc.svcMap.Deleted()) != 0 {
 		ebc.Sync()
 	}
+
+	if ebc.pruneStale {
+		ebc.prune()
+		ebc.pruneStale = false
+	}
+
+	ebc.setStatsFrontends()
+}
+
+// setStatsFrontends has the service counters exported for the service ports
+// of the cache.
+func (ebc *ebpfController) setStatsFrontends() {
+	frontends := map[bpfV4Key]serviceStatsLabels{}
+	for _, KV := range ebc.svcMap.GetByPrefix([]byte{}) {
+		svcInfo := KV.Value.(svcEndpointMapping)
+		svcKeys, _, _, _ := makeEbpfMaps(svcInfo)
+		frontends[svcKeys[0]] = serviceStatsLabels{
+			namespace: svcInfo.Name.Namespace,
+			service:   svcInfo.Name.Name,
+			port:      fmt.Sprintf("%d/%s", svcInfo.Svc.port, svcInfo.Svc.protocol),
+		}
+	}
+	serviceCounters.setFrontends(ebc.objs.V4SvcStatsMap, frontends)
+}
+
+// prune deletes the entries of the maps taken over from the previous kpng
+// that are not in the synced state, ie: services deleted in the meantime.
+func (ebc *ebpfController) prune() {
+	svcKeys := map[bpfV4Key]bool{}
+	backendKeys := map[uint32]bool{}
+
+	for _, KV := range ebc.svcMap.GetByPrefix([]byte{}) {
+		keys, _, backends, _ := makeEbpfMaps(KV.Value.(svcEndpointMapping))
+		for _, key := range keys {
+			svcKeys[key] = true
+		}
+		for _, key := range backends {
+			backendKeys[key] = true
+		}
+	}
+
+	var (
+		svcKey     bpfV4Key
+		svcValue   bpfLb4Service
+		staleSvcs  []bpfV4Key
+		backendKey uint32
+		backend    bpfLb4Backend
+		staleBacks []uint32
+	)
+
+	iter := ebc.objs.V4SvcMap.Iterate()
+	for iter.Next(&svcKey, &svcValue) {
+		if !svcKeys[svcKey] {
+			staleSvcs = append(staleSvcs, svcKey)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		klog.Errorf("Failed iterating service entries: %v", err)
+		return
+	}
+
+	iter = ebc.objs.V4BackendMap.Iterate()
+	for iter.Next(&backendKey, &backend) {
+		if !backendKeys[backendKey] {
+			staleBacks = append(staleBacks, backendKey)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		klog.Errorf("Failed iterating service backend entries: %v", err)
+		return
+	}
+
+	var (
+		statsKey   bpfV4Key
+		statsValue []bpfSvcStats
+		staleStats []bpfV4Key
+	)
+	iter = ebc.objs.V4SvcStatsMap.Iterate()
+	for iter.Next(&statsKey, &statsValue) {
+		if !svcKeys[statsKey] {
+			staleStats = append(staleStats, statsKey)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		klog.Errorf("Failed iterating service stats: %v", err)
+	}
+	for _, key := range staleStats {
+		if err := ebc.objs.V4SvcStatsMap.Delete(key); err != nil {
+			klog.Errorf("Failed deleting stale service stats %+v: %v", key, err)
+		}
+	}
+
+	// services first, so no service points to a deleted backend
+	for _, key := range staleSvcs {
+		if err := ebc.objs.V4SvcMap.Delete(key); err != nil {
+			klog.Errorf("Failed deleting stale service entry %+v: %v", key, err)
+		}
+	}
+	for _, key := range staleBacks {
+		if err := ebc.objs.V4BackendMap.Delete(key); err != nil {
+			klog.Errorf("Failed deleting stale service backend entry %d: %v", key, err)
+		}
+	}
+
+	klog.Infof("Pruned %d stale service and %d stale backend entries", len(staleSvcs), len(staleBacks))
 }
 
 // Sync will take the new internally cached state and apply it to the bpf maps
@@ -184,6 +316,11 @@ func (ebc *ebpfController) Sync() {
 			ebc.Cleanup()
 		}
 
+		// The counters are only created by the first connection to the service
+		if err := ebc.objs.V4SvcStatsMap.Delete(svcKeys[0]); err != nil && !errors.Is(err, cebpf.ErrKeyNotExist) {
+			klog.Errorf("Failed Deleting service stats: %v", err)
+		}
+
 		// Remove service entry from cache
 		ebc.svcMap.Delete(KV.Key)
 	}
@@ -215,6 +352,7 @@ func makeEbpfMaps(svcMapping svcEndpointMapping) (svcKeys []bpfV4Key, svcValues
 	var ID uint32
 	var err error
 	addresses := []string{}
+	weights := []int32{}
 
 	// Encode Port in LE and then Load in NE to ensure the int value that's loaded
 	// is in fact in Network Endian
@@ -222,9 +360,15 @@ func makeEbpfMaps(svcMapping svcEndpointMapping) (svcKeys []bpfV4Key, svcValues
 	binary.BigEndian.PutUint16(svcPort[:], uint16(svcMapping.Svc.port))
 
 	for _, endpoint := range svcMapping.Endpoint {
-		addresses = append(addresses, endpoint.IPs.V4...)
+		for _, address := range endpoint.IPs.V4 {
+			addresses = append(addresses, address)
+			weights = append(weights, endpoint.WeightOr(1))
+		}
 	}
 
+	// Weighted random selection: each backend gets as many slots as its weight
+	slots := backendSlots(weights)
+
 	// Make root (backendID 0, count != # of backends) key/value for service
 	svcKeys = append(svcKeys, bpfV4Key{
 		// Load to map in network endian
@@ -234,19 +378,13 @@ func makeEbpfMaps(svcMapping svcEndpointMapping) (svcKeys []bpfV4Key, svcValues
 		BackendSlot: 0,
 	})
 
-	svcValues = append(svcValues, bpfLb4Service{Count: uint16(len(addresses))})
+	svcValues = append(svcValues, bpfLb4Service{Count: uint16(len(slots))})
 
-	// Make rest of svc and backend entries for service
+	// Make backend entries for service
+	backendIDs := make([]uint32, len(addresses))
 	for i, address := range addresses {
-		i := i
 		copy(backendAddress[:], net.ParseIP(address).To4())
 
-		svcKeys = append(svcKeys, bpfV4Key{
-			Address:     binary.LittleEndian.Uint32(svcMapping.Svc.clusterIP.To4()),
-			Dport:       binary.LittleEndian.Uint16(svcPort[:]),
-			BackendSlot: uint16(i + 1),
-		})
-
 		// Make backendID the int value of the string version of the address + int protocol value
 		err = binary.Read(bytes.NewBuffer(net.ParseIP(address).To4()), binary.BigEndian, &ID)
 		if err != nil {
@@ -255,11 +393,7 @@ func makeEbpfMaps(svcMapping svcEndpointMapping) (svcKeys []bpfV4Key, svcValues
 		}
 		// Increment by port to have unique backend value for each svcPort
 		ID = ID + uint32(svcMapping.Svc.port)
-
-		svcValues = append(svcValues, bpfLb4Service{
-			Count:     0,
-			BackendId: ID,
-		})
+		backendIDs[i] = ID
 
 		backendKeys = append(backendKeys, uint32(ID))
 
@@ -268,12 +402,70 @@ func makeEbpfMaps(svcMapping svcEndpointMapping) (svcKeys []bpfV4Key, svcValues
 			Port:    binary.LittleEndian.Uint16(targetPort[:]),
 		})
 	}
+
+	// Make rest of svc entries for service, one per slot
+	for i, backend := range slots {
+		svcKeys = append(svcKeys, bpfV4Key{
+			Address:     binary.LittleEndian.Uint32(svcMapping.Svc.clusterIP.To4()),
+			Dport:       binary.LittleEndian.Uint16(svcPort[:]),
+			BackendSlot: uint16(i + 1),
+		})
+
+		svcValues = append(svcValues, bpfLb4Service{
+			Count:     0,
+			BackendId: backendIDs[backend],
+		})
+	}
 	klog.V(5).Infof("Writing svcKeys %+v \nsvcValues %+v \nbackendKeys %+v \nbackendValues %+v",
 		svcKeys, svcValues, backendKeys, backendValues)
 
 	return svcKeys, svcValues, backendKeys, backendValues
 }
 
+// maxWeightedSlots bounds the slots of a service when its backends have weights, as the slots of
+// all the services share the service map.
+const maxWeightedSlots = 1024
+
+// backendSlots returns the backend (index in weights) of each slot of a service, each backend
+// having a number of slots proportional to its weight.
+func backendSlots(weights []int32) (slots []int) {
+	divisor := int32(0)
+	total := 0
+	for _, weight := range weights {
+		divisor = gcd(divisor, weight)
+		total += int(weight)
+	}
+
+	if divisor != 0 {
+		total /= int(divisor)
+	}
+
+	for backend, weight := range weights {
+		count := 1
+		if total > len(weights) && len(weights) < maxWeightedSlots {
+			count = int(weight / divisor)
+			if total > maxWeightedSlots {
+				count = count * maxWeightedSlots / total
+				if count < 1 {
+					count = 1
+				}
+			}
+		}
+
+		for n := 0; n < count; n++ {
+			slots = append(slots, backend)
+		}
+	}
+	return
+}
+
+func gcd(a, b int32) int32 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
 // // mapToEbpfProto takes a proto as defined by KPNG and maps it to those defined by
 // // linux in https://github.com/torvalds/linux/blob/master/include/uapi/linux/in.h#L27
 // func mapToEbpfProto(kpngProto int) U8proto {
@@ -0,0 +1,269 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ebpf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	cebpf "github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+
+	"k8s.io/klog"
+)
+
+// mapsVersion is the version of the layout of the maps, it must be bumped
+// when the keys or values of a map change so the maps pinned by a previous
+// version are migrated instead of reused as is.
+const mapsVersion = 1
+
+// mapConverter converts an entry of a map of a previous version to the layout
+// of the current version.
+type mapConverter func(key, value []byte) (newKey, newValue []byte, err error)
+
+// mapConverters are the converters of the maps of the previous versions, by
+// version and map name. The entries of the maps without a converter are left
+// to the first sync, so bumping mapsVersion should come with the converters of
+// the maps worth migrating.
+var mapConverters = map[int]map[string]mapConverter{}
+
+// DefaultPinPath is the bpffs directory where the maps and the program link
+// are pinned, letting a new kpng take over the state of the previous one.
+const DefaultPinPath = "/sys/fs/bpf/kpng"
+
+// linkPinName is the name of the pinned cgroup link, it isn't versioned as
+// the link is updated in place with the program of the new version.
+const linkPinName = "sock4_connect_link"
+
+// mapsPinPath returns the directory of the maps of the given version.
+func mapsPinPath(pinPath string, version int) string {
+	return filepath.Join(pinPath, "v"+strconv.Itoa(version))
+}
+
+// previousMapsVersions returns the versions of the maps pinned under pinPath,
+// other than the current one.
+func previousMapsVersions(pinPath string) (versions []int, err error) {
+	entries, err := os.ReadDir(pinPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "v") {
+			continue
+		}
+		version, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), "v"))
+		if err != nil || version == mapsVersion {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	return
+}
+
+// loadObjects loads the program and the maps. With a pinPath, it reuses the
+// maps pinned by a kpng of the same maps version and pins them otherwise, the
+// maps of the previous versions being converted into the new ones (see
+// mapConverters). reused tells if the maps may hold entries from a previous
+// run.
+func loadObjects(objs *bpfObjects, pinPath string) (reused bool, err error) {
+	spec, err := loadBpf()
+	if err != nil {
+		return
+	}
+
+	mapOpts := cebpf.MapOptions{}
+
+	if pinPath != "" {
+		mapsPath := mapsPinPath(pinPath, mapsVersion)
+		if _, err := os.Stat(mapsPath); err == nil {
+			reused = true
+		}
+
+		if err = os.MkdirAll(mapsPath, 0700); err != nil {
+			return
+		}
+
+		for _, mapSpec := range spec.Maps {
+			mapSpec.Pinning = cebpf.PinByName
+		}
+		mapOpts.PinPath = mapsPath
+	}
+
+	err = spec.LoadAndAssign(objs, &cebpf.CollectionOptions{Maps: mapOpts})
+	if errors.Is(err, cebpf.ErrMapIncompatible) {
+		err = fmt.Errorf("%w: bump the maps version when changing their layout", err)
+	}
+	if err != nil || pinPath == "" {
+		return
+	}
+
+	versions, err := previousMapsVersions(pinPath)
+	if err != nil {
+		return
+	}
+
+	// the per-CPU stats are not migrated, their counters restart
+	newMaps := map[string]*cebpf.Map{
+		"v4_svc_map":     objs.V4SvcMap,
+		"v4_backend_map": objs.V4BackendMap,
+	}
+
+	for _, version := range versions {
+		for name, newMap := range newMaps {
+			oldPath := filepath.Join(mapsPinPath(pinPath, version), name)
+
+			convert := mapConverters[version][name]
+			if convert == nil {
+				klog.Infof("no converter of map %s from version %d, its entries will be programmed by the first sync", name, version)
+				continue
+			}
+
+			migrated, err := migrateMap(oldPath, newMap, convert)
+			if err != nil {
+				klog.Warningf("failed to migrate %s: %v", oldPath, err)
+				continue
+			}
+			if migrated {
+				reused = true
+				klog.Infof("migrated map %s from version %d to %d", name, version, mapsVersion)
+			}
+		}
+	}
+
+	return
+}
+
+// migrateMap converts the entries of the pinned map at oldPath into newMap.
+func migrateMap(oldPath string, newMap *cebpf.Map, convert mapConverter) (migrated bool, err error) {
+	oldMap, err := cebpf.LoadPinnedMap(oldPath, nil)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return
+	}
+	defer oldMap.Close()
+
+	var key, value []byte
+	iter := oldMap.Iterate()
+	for iter.Next(&key, &value) {
+		newKey, newValue, err := convert(key, value)
+		if err != nil {
+			return false, fmt.Errorf("failed to convert an entry: %w", err)
+		}
+		if err = newMap.Put(newKey, newValue); err != nil {
+			return false, err
+		}
+	}
+	if err = iter.Err(); err != nil {
+		return
+	}
+
+	return true, nil
+}
+
+// attachPinnedLink attaches the program to the cgroup through the pinned link
+// if any, atomically replacing the program of the previous kpng, so no
+// connection is made while no program is attached. The link is pinned so it
+// outlives this process.
+func attachPinnedLink(pinPath, cgroupPath string, prog *cebpf.Program) (link.Link, error) {
+	linkPath := filepath.Join(pinPath, linkPinName)
+
+	l, err := link.LoadPinnedLink(linkPath, nil)
+	if err == nil {
+		if err = l.Update(prog); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("failed to update the pinned link: %w", err)
+		}
+		klog.Infof("replaced the program of the pinned link %s", linkPath)
+		return l, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		klog.Warningf("failed to load the pinned link %s, attaching a new one: %v", linkPath, err)
+		os.Remove(linkPath)
+	}
+
+	l, err = link.AttachCgroup(link.CgroupOptions{
+		Path:    cgroupPath,
+		Attach:  cebpf.AttachCGroupInet4Connect,
+		Program: prog,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = l.Pin(linkPath); err != nil {
+		// links of kernels without bpf_link support can't be pinned
+		klog.Warningf("failed to pin the link, the program will be detached on exit: %v", err)
+	}
+
+	return l, nil
+}
+
+// removePreviousMaps unpins the maps of the previous versions, once the
+// program using the maps of the current version is attached.
+func removePreviousMaps(pinPath string) {
+	versions, err := previousMapsVersions(pinPath)
+	if err != nil {
+		klog.Warningf("failed to list the previous maps: %v", err)
+		return
+	}
+
+	for _, version := range versions {
+		path := mapsPinPath(pinPath, version)
+		if err := os.RemoveAll(path); err != nil {
+			klog.Warningf("failed to remove the previous maps %s: %v", path, err)
+			continue
+		}
+		klog.Infof("removed the maps of version %d", version)
+	}
+}
+
+// Unpin detaches the program attached through the link pinned under pinPath,
+// which keeps it attached after kpng exits, and removes the pinned maps. It
+// removes the backend from the node, so kpng must be stopped first.
+func Unpin(pinPath string) error {
+	linkPath := filepath.Join(pinPath, linkPinName)
+
+	if _, err := os.Stat(linkPath); err == nil {
+		l, err := link.LoadPinnedLink(linkPath, nil)
+		if err != nil {
+			return fmt.Errorf("failed to load the pinned link %s: %w", linkPath, err)
+		}
+
+		// the program is detached once the link is neither pinned nor open
+		err = l.Unpin()
+		l.Close()
+		if err != nil {
+			return fmt.Errorf("failed to unpin the link %s: %w", linkPath, err)
+		}
+		klog.Infof("detached the program of the pinned link %s", linkPath)
+	}
+
+	if err := os.RemoveAll(pinPath); err != nil {
+		return fmt.Errorf("failed to remove the pinned maps: %w", err)
+	}
+	return nil
+}
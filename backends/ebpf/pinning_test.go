@@ -0,0 +1,105 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ebpf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// pinnedDirs creates the given directories and files under a new pin path.
+func pinnedDirs(t *testing.T, dirs []string, files []string) string {
+	t.Helper()
+
+	pinPath := t.TempDir()
+	for _, dir := range dirs {
+		if err := os.MkdirAll(filepath.Join(pinPath, dir), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range files {
+		if err := os.WriteFile(filepath.Join(pinPath, file), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return pinPath
+}
+
+func TestMapsPinPath(t *testing.T) {
+	if path := mapsPinPath("/sys/fs/bpf/kpng", 3); path != "/sys/fs/bpf/kpng/v3" {
+		t.Errorf("unexpected maps pin path %s", path)
+	}
+}
+
+func TestPreviousMapsVersions(t *testing.T) {
+	versions, err := previousMapsVersions(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || versions != nil {
+		t.Errorf("expected no versions for a missing pin path, got %v, %v", versions, err)
+	}
+
+	// the current version, a file, and directories not named after a version are ignored
+	pinPath := pinnedDirs(t, []string{"v0", mapsPinPath("", mapsVersion), "v2", "vx", "other"}, []string{"v3", linkPinName})
+
+	versions, err = previousMapsVersions(pinPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Ints(versions)
+	if expected := []int{0, 2}; !reflect.DeepEqual(versions, expected) {
+		t.Errorf("expected versions %v, got %v", expected, versions)
+	}
+}
+
+func TestRemovePreviousMaps(t *testing.T) {
+	current := mapsPinPath("", mapsVersion)
+	pinPath := pinnedDirs(t, []string{"v0", current, "v2", "other"}, []string{"v0/v4_svc_map", linkPinName})
+
+	removePreviousMaps(pinPath)
+
+	entries, err := os.ReadDir(pinPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	expected := []string{"other", linkPinName, current}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v to remain, got %v", expected, names)
+	}
+}
+
+func TestUnpinWithoutLink(t *testing.T) {
+	pinPath := pinnedDirs(t, []string{"v0", mapsPinPath("", mapsVersion)}, []string{"v0/v4_svc_map"})
+
+	if err := Unpin(pinPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(pinPath); !os.IsNotExist(err) {
+		t.Errorf("expected the pin path to be removed, got %v", err)
+	}
+
+	// nothing pinned
+	if err := Unpin(pinPath); err != nil {
+		t.Error(err)
+	}
+}
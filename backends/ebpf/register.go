@@ -20,8 +20,10 @@ import (
 	"github.com/spf13/pflag"
 
 	"k8s.io/klog"
+	localv1 "sigs.k8s.io/kpng/api/localv1"
 	"sigs.k8s.io/kpng/client/backendcmd"
 	"sigs.k8s.io/kpng/client/localsink"
+	"sigs.k8s.io/kpng/client/localsink/familyfilter"
 	"sigs.k8s.io/kpng/client/localsink/fullstate"
 	"sigs.k8s.io/kpng/client/localsink/fullstate/fullstatepipe"
 )
@@ -30,13 +32,33 @@ var ebc ebpfController
 
 type backend struct {
 	cfg localsink.Config
+
+	pinPath string
 }
 
+var _ familyfilter.Backend = &backend{}
+
 func init() {
 	backendcmd.Register("to-ebpf", func() backendcmd.Cmd { return &backend{} })
 }
 
 func (s *backend) BindFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&s.pinPath, "pin-path", DefaultPinPath, "bpffs directory where the maps and the program link are pinned, so a restarted or upgraded kpng takes them over without detaching the program. Empty to disable pinning")
+}
+
+// Probe checks the eBPF features needed by the backend.
+func (s *backend) Probe() error {
+	return backendcmd.Err(s.Checks())
+}
+
+// Checks checks the eBPF features needed by the backend one by one.
+func (s *backend) Checks() []backendcmd.Check {
+	return featureChecks()
+}
+
+// IPFamilies returns IPv4: the maps of the program only hold IPv4 services and backends.
+func (s *backend) IPFamilies() []localv1.IPFamily {
+	return []localv1.IPFamily{localv1.IPFamily_IPv4}
 }
 
 func (s *backend) Reset() { /* noop */ }
@@ -48,7 +70,8 @@ func (s *backend) Reset() { /* noop */ }
 // }
 
 func (s *backend) Setup() {
-	ebc = ebpfSetup()
+	ebc = ebpfSetup(s.pinPath)
+	RegisterMetrics()
 	klog.Infof("Loading ebpf maps and program %+v", ebc)
 }
 
@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ebpf
+
+import (
+	"errors"
+	"sync"
+
+	cebpf "github.com/cilium/ebpf"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog"
+)
+
+// statsMapName is the per-CPU map of the service counters, by frontend key.
+const statsMapName = "v4_svc_stats_map"
+
+// statsLookuper is the lookup of the stats map, a *cebpf.Map.
+type statsLookuper interface {
+	Lookup(key, valueOut interface{}) error
+}
+
+// lookupStats returns the counters of a frontend summed over the CPUs, and
+// false if no connection was made to it yet.
+func lookupStats(stats statsLookuper, frontend bpfV4Key) (total bpfSvcStats, found bool, err error) {
+	var perCPU []bpfSvcStats
+	if err = stats.Lookup(frontend, &perCPU); err != nil {
+		if errors.Is(err, cebpf.ErrKeyNotExist) {
+			err = nil
+		}
+		return
+	}
+
+	for _, cpu := range perCPU {
+		total.Hits += cpu.Hits
+		total.Misses += cpu.Misses
+		total.NoEndpoints += cpu.NoEndpoints
+	}
+	return total, true, nil
+}
+
+var (
+	serviceHitsDesc = prometheus.NewDesc(
+		"kpng_ebpf_service_hits_total",
+		"The connections to the service port translated to one of its backends by the eBPF program",
+		[]string{"namespace", "service", "port"}, nil)
+
+	serviceMissesDesc = prometheus.NewDesc(
+		"kpng_ebpf_service_lookup_misses_total",
+		"The connections to the service port left untranslated because the lookup of the selected backend slot or backend failed",
+		[]string{"namespace", "service", "port"}, nil)
+
+	serviceNoEndpointsDesc = prometheus.NewDesc(
+		"kpng_ebpf_service_no_endpoint_drops_total",
+		"The connections to the service port left untranslated because the service has no backends",
+		[]string{"namespace", "service", "port"}, nil)
+)
+
+// serviceStatsLabels are the labels of the counters of a service port.
+type serviceStatsLabels struct {
+	namespace, service, port string
+}
+
+// serviceCounters exports the counters of the program for the services of the last sync, read
+// from the stats map at each scrape.
+var serviceCounters = &serviceStatsCollector{}
+
+type serviceStatsCollector struct {
+	mu        sync.Mutex
+	stats     statsLookuper
+	frontends map[bpfV4Key]serviceStatsLabels
+}
+
+var _ prometheus.Collector = &serviceStatsCollector{}
+
+func (c *serviceStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- serviceHitsDesc
+	ch <- serviceMissesDesc
+	ch <- serviceNoEndpointsDesc
+}
+
+func (c *serviceStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	stats, frontends := c.stats, c.frontends
+	c.mu.Unlock()
+
+	if stats == nil {
+		return
+	}
+
+	for frontend, labels := range frontends {
+		total, found, err := lookupStats(stats, frontend)
+		if err != nil {
+			klog.V(4).Infof("Failed to get the stats of %s/%s %s: %v", labels.namespace, labels.service, labels.port, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+
+		counter := func(desc *prometheus.Desc, v uint64) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(v), labels.namespace, labels.service, labels.port)
+		}
+		counter(serviceHitsDesc, total.Hits)
+		counter(serviceMissesDesc, total.Misses)
+		counter(serviceNoEndpointsDesc, total.NoEndpoints)
+	}
+}
+
+// setFrontends records the service ports of a sync, by frontend key.
+func (c *serviceStatsCollector) setFrontends(stats statsLookuper, frontends map[bpfV4Key]serviceStatsLabels) {
+	c.mu.Lock()
+	c.stats = stats
+	c.frontends = frontends
+	c.mu.Unlock()
+}
+
+var registerMetricsOnce sync.Once
+
+// RegisterMetrics registers the counters of the services.
+func RegisterMetrics() {
+	registerMetricsOnce.Do(func() {
+		prometheus.MustRegister(serviceCounters)
+	})
+}
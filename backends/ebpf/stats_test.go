@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ebpf
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	cebpf "github.com/cilium/ebpf"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+// fakeStats is a stats map, holding the counters of each CPU by frontend.
+type fakeStats struct {
+	perCPU map[bpfV4Key][]bpfSvcStats
+	err    error
+}
+
+func (f fakeStats) Lookup(key, valueOut interface{}) error {
+	if f.err != nil {
+		return f.err
+	}
+	perCPU, ok := f.perCPU[key.(bpfV4Key)]
+	if !ok {
+		return cebpf.ErrKeyNotExist
+	}
+	*valueOut.(*[]bpfSvcStats) = append([]bpfSvcStats(nil), perCPU...)
+	return nil
+}
+
+func TestBytecodeStatsMap(t *testing.T) {
+	spec, err := loadBpf()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, ok := spec.Maps[statsMapName]
+	if !ok {
+		t.Fatalf("the bytecode has no %s, regenerate it with make bytecode", statsMapName)
+	}
+	if stats.Type != cebpf.PerCPUHash || stats.KeySize != 8 || stats.ValueSize != 24 {
+		t.Errorf("unexpected %s: %v", statsMapName, stats)
+	}
+}
+
+func TestLookupStats(t *testing.T) {
+	frontend := bpfV4Key{Address: 1, Dport: 2}
+	otherErr := errors.New("lookup failed")
+
+	for _, tc := range []struct {
+		name      string
+		stats     fakeStats
+		total     bpfSvcStats
+		found     bool
+		expectErr error
+	}{
+		{
+			name: "sums the CPUs",
+			stats: fakeStats{perCPU: map[bpfV4Key][]bpfSvcStats{
+				frontend:               {{Hits: 1, Misses: 2, NoEndpoints: 3}, {}, {Hits: 10, Misses: 20, NoEndpoints: 30}},
+				{Address: 1, Dport: 3}: {{Hits: 100}},
+			}},
+			total: bpfSvcStats{Hits: 11, Misses: 22, NoEndpoints: 33},
+			found: true,
+		},
+		{
+			name:  "no connection yet",
+			stats: fakeStats{perCPU: map[bpfV4Key][]bpfSvcStats{}},
+		},
+		{
+			name:      "lookup error",
+			stats:     fakeStats{err: otherErr},
+			expectErr: otherErr,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			total, found, err := lookupStats(tc.stats, frontend)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectErr, err)
+			}
+			if total != tc.total || found != tc.found {
+				t.Errorf("expected %+v (found: %v), got %+v (found: %v)", tc.total, tc.found, total, found)
+			}
+		})
+	}
+}
+
+func TestSetStatsFrontends(t *testing.T) {
+	ebc := NewEBPFController(bpfObjects{}, nil, v1.IPv4Protocol, false)
+
+	for _, svc := range []struct {
+		name  string
+		ip    string
+		port  int
+		proto localv1.Protocol
+	}{
+		{"dns", "10.96.0.10", 53, localv1.Protocol_TCP},
+		{"web", "10.96.0.20", 8080, localv1.Protocol_TCP},
+	} {
+		mapping := svcEndpointMapping{
+			Name: types.NamespacedName{Namespace: "default", Name: svc.name},
+			Svc:  &BaseServiceInfo{clusterIP: net.ParseIP(svc.ip), port: svc.port, protocol: svc.proto, targetPort: svc.port},
+			Endpoint: []*localv1.Endpoint{
+				{IPs: &localv1.IPSet{V4: []string{"10.1.0.1"}}},
+			},
+		}
+		ebc.svcMap.Set([]byte(svc.name+"/"+svc.proto.String()), 1, mapping)
+	}
+
+	ebc.setStatsFrontends()
+
+	expected := map[string]serviceStatsLabels{
+		"10.96.0.10:53":   {namespace: "default", service: "dns", port: "53/TCP"},
+		"10.96.0.20:8080": {namespace: "default", service: "web", port: "8080/TCP"},
+	}
+
+	frontends := map[string]serviceStatsLabels{}
+	for key, labels := range serviceCounters.frontends {
+		if key.BackendSlot != 0 {
+			t.Errorf("frontend %s has backend slot %d", frontendString(key), key.BackendSlot)
+		}
+		frontends[frontendString(key)] = labels
+	}
+
+	if !reflect.DeepEqual(frontends, expected) {
+		t.Errorf("expected frontends %v, got %v", expected, frontends)
+	}
+}
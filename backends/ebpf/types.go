@@ -31,6 +31,8 @@ import (
 
 // Userspace Types
 type svcEndpointMapping struct {
+	Name types.NamespacedName
+
 	Svc *BaseServiceInfo
 
 	Endpoint []*localv1.Endpoint
@@ -48,16 +50,21 @@ type ebpfController struct {
 
 	ipFamily v1.IPFamily
 
+	// pruneStale is set when the maps were taken over from a previous kpng,
+	// their entries not in the first synced state have to be deleted.
+	pruneStale bool
+
 	// <namespacedName>/<port>/<protocol> -> serviceEndpoints
 	svcMap *lightdiffstore.DiffStore
 }
 
-func NewEBPFController(objs bpfObjects, bpfProgLink cebpflink.Link, ipFamily v1.IPFamily) ebpfController {
+func NewEBPFController(objs bpfObjects, bpfProgLink cebpflink.Link, ipFamily v1.IPFamily, pruneStale bool) ebpfController {
 	return ebpfController{
-		objs:     objs,
-		bpfLink:  bpfProgLink,
-		ipFamily: ipFamily,
-		svcMap:   lightdiffstore.New(),
+		objs:       objs,
+		bpfLink:    bpfProgLink,
+		ipFamily:   ipFamily,
+		pruneStale: pruneStale,
+		svcMap:     lightdiffstore.New(),
 	}
 }
 
@@ -246,6 +253,11 @@ func (sct *ebpfController) newBaseServiceInfo(port *localv1.PortMapping, service
 	// }
 
 	clusterIP := GetClusterIPByFamily(sct.ipFamily, service)
+	nodePort := 0
+	if service.HasNodePort(port) {
+		nodePort = int(port.NodePort)
+	}
+
 	info := &BaseServiceInfo{
 		clusterIP:         net.ParseIP(clusterIP),
 		port:              int(port.Port),
@@ -253,13 +265,13 @@ func (sct *ebpfController) newBaseServiceInfo(port *localv1.PortMapping, service
 		targetPort:        int(port.TargetPort),
 		targetPortName:    port.TargetPortName,
 		protocol:          port.Protocol,
-		nodePort:          int(port.NodePort),
+		nodePort:          nodePort,
 		nodeLocalExternal: nodeLocalExternal,
 		nodeLocalInternal: nodeLocalInternal,
 		// internalTrafficPolicy: service.Spec.InternalTrafficPolicy, //TODO : CHECK InternalTrafficPolicy
 		hintsAnnotation:          service.Annotations[v1.AnnotationTopologyAwareHints],
 		loadBalancerSourceRanges: getLoadbalancerSourceRanges(service.IPFilters),
-		loadBalancerIPs:          getLoadBalancerIPs(service.IPs.LoadBalancerIPs, sct.ipFamily),
+		loadBalancerIPs:          getLoadBalancerIPs(service.IPs.LoadBalancerVIPs(), sct.ipFamily),
 		sessionAffinity:          getSessionAffinity(service.SessionAffinity),
 	}
 
@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/kpng/client/auditlog"
+	"sigs.k8s.io/kpng/client/rulecomment"
+)
+
+var ruleCommentRE = regexp.MustCompile(`--comment ("[^"]*"|[^ ]+)`)
+
+// auditRules records the rules added and removed by a restore, compared to the previous one.
+func (t *iptables) auditRules(data []byte) {
+	rules := make(map[string]bool, len(t.appliedRules))
+
+	table := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, "*"):
+			table = line[1:]
+
+		case strings.HasPrefix(line, "-A "):
+			rules[table+" "+line[3:]] = true
+
+		case strings.HasPrefix(line, "-X "):
+			auditlog.Record("iptables", auditlog.Delete, "chain", table+" "+line[3:], "")
+		}
+	}
+
+	for rule := range rules {
+		if !t.appliedRules[rule] {
+			auditlog.Record("iptables", auditlog.Add, "rule", rule, ruleService(rule))
+		}
+	}
+	for rule := range t.appliedRules {
+		if !rules[rule] {
+			auditlog.Record("iptables", auditlog.Delete, "rule", rule, ruleService(rule))
+		}
+	}
+
+	t.appliedRules = rules
+}
+
+// ruleService returns the service port of a rule, from its comment.
+func ruleService(rule string) string {
+	m := ruleCommentRE.FindStringSubmatch(rule)
+	if m == nil {
+		return ""
+	}
+	c, ok := rulecomment.Parse(m[1])
+	if !ok {
+		return ""
+	}
+	return c.ServicePort()
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kpng/backends/iptables/util"
+)
+
+// writeBalancingRules writes the rules of chain spreading the packets evenly between the
+// targets. A packet goes through half of the rules on average, so above balancingChainSize
+// targets they are split in buckets of at most this size, each in its own KUBE-SVB- chain
+// balanced from chain in proportion of its size.
+func (t *iptables) writeBalancingRules(svcInfo *serviceInfo, chain util.Chain, targets []util.Chain,
+	existingNATChains map[util.Chain][]byte, activeNATChains map[util.Chain]bool, args []string) {
+	if t.balancingChainSize <= 0 || len(targets) <= t.balancingChainSize {
+		t.writeBalancingChain(svcInfo, chain, targets, nil, args)
+		return
+	}
+
+	n := len(targets)
+	buckets := (n + t.balancingChainSize - 1) / t.balancingChainSize
+	bucketChains := make([]util.Chain, buckets)
+	sizes := make([]int, buckets)
+
+	for i := range bucketChains {
+		// the first buckets get the remainder
+		size := n / buckets
+		if i < n%buckets {
+			size++
+		}
+
+		bucketChain := serviceBucketChainName(chain, i)
+		t.copyExistingChains([]util.Chain{bucketChain}, existingNATChains, &t.natChains)
+		activeNATChains[bucketChain] = true
+
+		t.writeBalancingChain(svcInfo, bucketChain, targets[:size], nil, args)
+		targets = targets[size:]
+
+		bucketChains[i], sizes[i] = bucketChain, size
+	}
+
+	t.writeBalancingChain(svcInfo, chain, bucketChains, sizes, args)
+}
+
+// writeBalancingChain appends the rules jumping from chain to the targets, each one chosen in
+// proportion of its weight (the same for all if weights is nil).
+func (t *iptables) writeBalancingChain(svcInfo *serviceInfo, chain util.Chain, targets []util.Chain, weights []int, args []string) {
+	remaining := len(targets)
+	if weights != nil {
+		remaining = 0
+		for _, weight := range weights {
+			remaining += weight
+		}
+	}
+
+	for i, target := range targets {
+		weight := 1
+		if weights != nil {
+			weight = weights[i]
+		}
+
+		args = append(args[:0], "-A", string(chain))
+		args = t.appendServiceCommentLocked(args, svcInfo, "balancing")
+		if i < len(targets)-1 {
+			// Each rule is a probabilistic match.
+			args = append(args,
+				"-m", "statistic",
+				"--mode", "random",
+				"--probability", t.ratio(weight, remaining))
+		}
+		// The final (or only if n == 1) rule is a guaranteed match.
+		args = append(args, "-j", string(target))
+		t.natRules.Write(args)
+
+		remaining -= weight
+	}
+}
+
+// ratio returns the probability n/total.
+func (t *iptables) ratio(n, total int) string {
+	if n == 1 {
+		return t.probability(total)
+	}
+	return fmt.Sprintf("%0.10f", float64(n)/float64(total))
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/iptables/util"
+	"sigs.k8s.io/kpng/client/servicechains"
+)
+
+func TestBalancingChains(t *testing.T) {
+	prevImpl := IptablesImpl
+	defer func() { IptablesImpl = prevImpl }()
+
+	const endpoints = 5000
+
+	for _, tc := range []struct {
+		chainSize     int
+		svcRules      int
+		bucketsChains int
+	}{
+		{chainSize: 0, svcRules: endpoints},
+		{chainSize: 256, svcRules: 20, bucketsChains: 20},
+		{chainSize: 5000, svcRules: endpoints},
+	} {
+		t.Run(strconv.Itoa(tc.chainSize), func(t *testing.T) {
+			kernel := newFakeKernel(util.ProtocolIPv4)
+
+			impl := NewIptables()
+			impl.iptInterface = kernel
+			impl.balancingChainSize = tc.chainSize
+			impl.serviceChanges = NewServiceChangeTracker(newServiceInfo, v1.IPv4Protocol, nil)
+			impl.endpointsChanges = NewEndpointChangeTracker("node", v1.IPv4Protocol, nil)
+			IptablesImpl = map[v1.IPFamily]*iptables{v1.IPv4Protocol: impl}
+
+			backend := New()
+			backend.SetService(&localv1.Service{
+				Namespace: "ns",
+				Name:      "big",
+				Type:      "ClusterIP",
+				IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.96.0.10"), ExternalIPs: localv1.NewIPSet()},
+				Ports:     []*localv1.PortMapping{{Name: "http", Protocol: localv1.Protocol_TCP, Port: 80, TargetPort: 8080}},
+			})
+			for i := 0; i < endpoints; i++ {
+				ip := fmt.Sprintf("10.1.%d.%d", i/250, i%250+1)
+				backend.SetEndpoint("ns", "big", ip, &localv1.Endpoint{IPs: localv1.NewIPSet(ip)})
+			}
+
+			backend.Sync()
+			if err := backend.SyncErr(); err != nil {
+				t.Fatal(err)
+			}
+
+			nat := kernel.Tables[util.TableNAT]
+			svcChain := servicePortChainName("ns/big:http", localv1.Protocol_TCP)
+
+			if n := len(nat[svcChain]); n != tc.svcRules {
+				t.Errorf("expected %d rules in the service chain, got %d", tc.svcRules, n)
+			}
+
+			bucketChains := 0
+			for chain := range nat {
+				if strings.HasPrefix(string(chain), servicechains.BucketPrefix) {
+					bucketChains++
+					if n := len(nat[chain]); n > tc.chainSize {
+						t.Errorf("%s: %d rules above the chain size", chain, n)
+					}
+				}
+			}
+			if bucketChains != tc.bucketsChains {
+				t.Errorf("expected %d bucket chains, got %d", tc.bucketsChains, bucketChains)
+			}
+
+			// each endpoint is reached with the same probability
+			reached := map[util.Chain]float64{}
+			var walk func(chain util.Chain, p float64)
+			walk = func(chain util.Chain, p float64) {
+				for _, rule := range nat[chain] {
+					target := util.Chain(rule[len(rule)-1])
+					match := 1.0
+					for i, arg := range rule {
+						if arg == "--probability" {
+							match, _ = strconv.ParseFloat(rule[i+1], 64)
+						}
+					}
+					if strings.HasPrefix(string(target), servicechains.EndpointPrefix) {
+						reached[target] += p * match
+					} else {
+						walk(target, p*match)
+					}
+					p *= 1 - match
+				}
+			}
+			walk(svcChain, 1)
+
+			if len(reached) != endpoints {
+				t.Fatalf("expected %d endpoints reached, got %d", endpoints, len(reached))
+			}
+			for chain, p := range reached {
+				if math.Abs(p-1.0/endpoints) > 1e-6 {
+					t.Fatalf("%s reached with the probability %g", chain, p)
+				}
+			}
+
+			// the buckets are removed once the service has fewer endpoints
+			for i := 10; i < endpoints; i++ {
+				backend.DeleteEndpoint("ns", "big", fmt.Sprintf("10.1.%d.%d", i/250, i%250+1))
+			}
+			backend.Sync()
+			if err := backend.SyncErr(); err != nil {
+				t.Fatal(err)
+			}
+			for chain := range kernel.Tables[util.TableNAT] {
+				if strings.HasPrefix(string(chain), servicechains.BucketPrefix) {
+					t.Errorf("bucket chain %s not removed", chain)
+				}
+			}
+		})
+	}
+}
@@ -18,33 +18,23 @@ limitations under the License.
 package iptables
 
 import (
-	"crypto/sha256"
-	"encoding/base32"
+	"sigs.k8s.io/kpng/api/localv1"
 	"sigs.k8s.io/kpng/backends/iptables/util"
+	"sigs.k8s.io/kpng/client/servicechains"
 )
 
-// portProtoHash takes the ServicePortName and protocol for a service
-// returns the associated 16 character hash. This is computed by hashing (sha256)
-// then encoding to base32 and truncating to 16 chars. We do this because IPTables
-// Chain Names must be <= 28 chars long, and the longer they are the harder they are to read.
-func portProtoHash(servicePortName string, protocol string) string {
-	hash := sha256.Sum256([]byte(servicePortName + protocol))
-	encoded := base32.StdEncoding.EncodeToString(hash[:])
-	return encoded[:16]
-}
-
 // servicePortChainName takes the ServicePortName for a service and
 // returns the associated iptables chain.  This is computed by hashing (sha256)
 // then encoding to base32 and truncating with the prefix "KUBE-SVC-".
-func servicePortChainName(servicePortName string, protocol string) util.Chain {
-	return util.Chain("KUBE-SVC-" + portProtoHash(servicePortName, protocol))
+func servicePortChainName(servicePortName string, protocol localv1.Protocol) util.Chain {
+	return util.Chain(servicechains.ServicePrefix + servicechains.Hash(servicePortName, protocol))
 }
 
 // serviceFirewallChainName takes the ServicePortName for a service and
 // returns the associated iptables chain.  This is computed by hashing (sha256)
 // then encoding to base32 and truncating with the prefix "KUBE-FW-".
-func serviceFirewallChainName(servicePortName string, protocol string) util.Chain {
-	return util.Chain("KUBE-FW-" + portProtoHash(servicePortName, protocol))
+func serviceFirewallChainName(servicePortName string, protocol localv1.Protocol) util.Chain {
+	return util.Chain(servicechains.FirewallPrefix + servicechains.Hash(servicePortName, protocol))
 }
 
 // serviceLBPortChainName takes the ServicePortName for a service and
@@ -52,13 +42,17 @@ func serviceFirewallChainName(servicePortName string, protocol string) util.Chai
 // then encoding to base32 and truncating with the prefix "KUBE-XLB-".  We do
 // this because IPTables Chain Names must be <= 28 chars long, and the longer
 // they are the harder they are to read.
-func serviceLBChainName(servicePortName string, protocol string) util.Chain {
-	return util.Chain("KUBE-XLB-" + portProtoHash(servicePortName, protocol))
+func serviceLBChainName(servicePortName string, protocol localv1.Protocol) util.Chain {
+	return util.Chain(servicechains.LocalPrefix + servicechains.Hash(servicePortName, protocol))
+}
+
+// serviceBucketChainName returns the chain of a bucket of the endpoints
+// balanced from chain, with the prefix "KUBE-SVB-".
+func serviceBucketChainName(chain util.Chain, bucket int) util.Chain {
+	return util.Chain(servicechains.BucketPrefix + servicechains.BucketHash(string(chain), bucket))
 }
 
 // This is the same as servicePortChainName but with the endpoint included.
-func servicePortEndpointChainName(servicePortName string, protocol string, endpoint string) util.Chain {
-	hash := sha256.Sum256([]byte(servicePortName + protocol + endpoint))
-	encoded := base32.StdEncoding.EncodeToString(hash[:])
-	return util.Chain("KUBE-SEP-" + encoded[:16])
+func servicePortEndpointChainName(servicePortName string, protocol localv1.Protocol, endpoint string) util.Chain {
+	return util.Chain(servicechains.EndpointPrefix + servicechains.EndpointHash(servicePortName, protocol, endpoint))
 }
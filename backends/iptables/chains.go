@@ -27,6 +27,12 @@ type iptablesJumpChain struct {
 	extraArgs []string
 }
 
+// args returns the arguments of the rule jumping to dstChain from srcChain.
+func (jump iptablesJumpChain) args() []string {
+	args := append([]string{}, jump.extraArgs...)
+	return append(args, "-m", "comment", "--comment", jump.comment, "-j", string(jump.dstChain))
+}
+
 const (
 	// the services chain
 	kubeServicesChain util.Chain = "KUBE-SERVICES"
@@ -42,6 +48,14 @@ const (
 	KubeMarkDropChain util.Chain = "KUBE-MARK-DROP"
 	// the kubernetes forward chain
 	kubeForwardChain util.Chain = "KUBE-FORWARD"
+	// the firewall chain, dropping the packets marked by KUBE-MARK-DROP
+	kubeFirewallChain util.Chain = "KUBE-FIREWALL"
+	// the raw chain bypassing conntrack for the node-local DNS cache
+	kubeNodeLocalDNSChain util.Chain = "KUBE-NODE-LOCAL-DNS"
+	// the raw chain bypassing conntrack for the endpoints of the NoTrack services
+	kubeNoTrackChain util.Chain = "KUBE-NOTRACK"
+	// the mangle chain setting the DSCP of the packets sent to the services
+	kubeDSCPChain util.Chain = "KUBE-DSCP"
 	// kube proxy canary chain is used for monitoring rule reload
 	kubeProxyCanaryChain util.Chain = "KUBE-PROXY-CANARY"
 )
@@ -58,6 +72,31 @@ var iptablesJumpChains = []iptablesJumpChain{
 	{util.TableNAT, kubePostroutingChain, util.ChainPostrouting, "kubernetes postrouting rules", nil},
 }
 
+// markDropJumpChains are only linked if kpng writes the KUBE-MARK-DROP rules
+// (usually written by the kubelet).
+var markDropJumpChains = []iptablesJumpChain{
+	{util.TableFilter, kubeFirewallChain, util.ChainInput, "kubernetes firewall for dropping marked packets", nil},
+	{util.TableFilter, kubeFirewallChain, util.ChainOutput, "kubernetes firewall for dropping marked packets", nil},
+}
+
+// nodeLocalDNSJumpChains are only linked with --node-local-dns-notrack.
+var nodeLocalDNSJumpChains = []iptablesJumpChain{
+	{util.TableRaw, kubeNodeLocalDNSChain, util.ChainPrerouting, "node-local DNS cache", nil},
+	{util.TableRaw, kubeNodeLocalDNSChain, util.ChainOutput, "node-local DNS cache", nil},
+}
+
+// noTrackJumpChains are linked once a service asks to bypass conntrack.
+var noTrackJumpChains = []iptablesJumpChain{
+	{util.TableRaw, kubeNoTrackChain, util.ChainPrerouting, "kubernetes conntrack bypass", nil},
+	{util.TableRaw, kubeNoTrackChain, util.ChainOutput, "kubernetes conntrack bypass", nil},
+}
+
+// dscpJumpChains are linked once a service sets a DSCP value, before the DNAT of the services.
+var dscpJumpChains = []iptablesJumpChain{
+	{util.TableMangle, kubeDSCPChain, util.ChainPrerouting, "kubernetes service DSCP", nil},
+	{util.TableMangle, kubeDSCPChain, util.ChainOutput, "kubernetes service DSCP", nil},
+}
+
 var iptablesEnsureChains = []struct {
 	table util.Table
 	chain util.Chain
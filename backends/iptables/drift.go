@@ -0,0 +1,317 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/kpng/backends/iptables/util"
+	"sigs.k8s.io/kpng/client/drift"
+	"sigs.k8s.io/kpng/client/servicechains"
+)
+
+var _ drift.Reporter = &Backend{}
+
+// Drift compares the rules of the last sync of each IP family, and the jumps to the kube chains,
+// with the ones listed by iptables-save.
+func (s *Backend) Drift() (report drift.Report, err error) {
+	for _, protocol := range []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol} {
+		impl, ok := IptablesImpl[protocol]
+		if !ok {
+			continue
+		}
+
+		r, err := impl.drift()
+		if err != nil {
+			return report, fmt.Errorf("%s: %w", protocol, err)
+		}
+		report.Add(string(protocol)+" ", r)
+	}
+	return
+}
+
+// savedRules are the chains and rules of the tables of an iptables-restore input or
+// iptables-save output.
+type savedRules struct {
+	tables []util.Table
+	chains map[util.Table]map[util.Chain]bool
+	// deleted are the chains deleted by table ("-X <chain>")
+	deleted map[util.Table]map[util.Chain]bool
+	// rules are the rules by table, as "-A <chain> <args>"
+	rules map[util.Table][][]string
+}
+
+func parseRules(data []byte) savedRules {
+	saved := savedRules{
+		chains:  map[util.Table]map[util.Chain]bool{},
+		deleted: map[util.Table]map[util.Chain]bool{},
+		rules:   map[util.Table][][]string{},
+	}
+
+	table := util.Table("")
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, "*"):
+			table = util.Table(line[1:])
+			saved.tables = append(saved.tables, table)
+			saved.chains[table] = map[util.Chain]bool{}
+			saved.deleted[table] = map[util.Chain]bool{}
+
+		case strings.HasPrefix(line, ":"):
+			saved.chains[table][util.Chain(strings.Fields(line[1:])[0])] = true
+
+		case strings.HasPrefix(line, "-X "):
+			chain := util.Chain(strings.TrimSpace(line[3:]))
+			delete(saved.chains[table], chain)
+			saved.deleted[table][chain] = true
+
+		case strings.HasPrefix(line, "-A "):
+			saved.rules[table] = append(saved.rules[table], util.SplitRestoreLine(line))
+		}
+	}
+	return saved
+}
+
+// drift compares the rules of the last sync with the installed ones.
+func (t *iptables) drift() (report drift.Report, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.iptablesData.Len() == 0 {
+		return report, errors.New("no rules written yet")
+	}
+	written := parseRules(t.iptablesData.Bytes())
+
+	tables := written.tables
+	jumps := map[util.Table][]iptablesJumpChain{}
+	for _, jump := range t.jumpChains() {
+		if _, ok := written.chains[jump.table]; !ok {
+			tables = append(tables, jump.table)
+			written.chains[jump.table] = map[util.Chain]bool{}
+		}
+		jumps[jump.table] = append(jumps[jump.table], jump)
+	}
+
+	buffer := &bytes.Buffer{}
+	for _, table := range tables {
+		buffer.Reset()
+		if err = t.iptInterface.SaveInto(table, buffer); err != nil {
+			return
+		}
+		installed := parseRules(buffer.Bytes())
+
+		report.Add(string(table)+" ", t.tableDrift(written.chains[table], written.rules[table], jumps[table],
+			installed.chains[table], installed.rules[table]))
+	}
+	return
+}
+
+// tableDrift compares the written chains and rules of a table, and its jumps, with the installed
+// ones. Only the rules of the written chains are compared, the other chains of the table being
+// owned by someone else, except the service chains.
+func (t *iptables) tableDrift(chains map[util.Chain]bool, rules [][]string, jumps []iptablesJumpChain,
+	installedChains map[util.Chain]bool, installedRules [][]string) (report drift.Report) {
+
+	for chain := range chains {
+		if !installedChains[chain] {
+			report.Missing = append(report.Missing, "chain "+string(chain))
+		}
+	}
+	for chain := range installedChains {
+		if !chains[chain] && isServiceChain(string(chain)) && t.staleChains[chain].IsZero() {
+			report.Unexpected = append(report.Unexpected, "chain "+string(chain))
+		}
+	}
+
+	// the rules are compared by their keys, and reported as written or installed
+	writtenKeys, installedKeys := []string{}, []string{}
+	lines := map[string]string{}
+
+	for _, args := range rules {
+		key := args[1] + " " + ruleKey(args[2:])
+		writtenKeys = append(writtenKeys, key)
+		lines[key] = ruleLine(args)
+	}
+
+	installedJumps := map[string]bool{}
+	for _, args := range installedRules {
+		key := args[1] + " " + ruleKey(args[2:])
+		if !chains[util.Chain(args[1])] {
+			// the other chains are only checked for the jumps to the kube chains
+			installedJumps[key] = true
+			continue
+		}
+
+		installedKeys = append(installedKeys, key)
+		if _, ok := lines[key]; !ok {
+			lines[key] = ruleLine(args)
+		}
+	}
+
+	rulesReport := drift.Compare(writtenKeys, installedKeys)
+	for _, key := range rulesReport.Missing {
+		report.Missing = append(report.Missing, lines[key])
+	}
+	for _, key := range rulesReport.Unexpected {
+		report.Unexpected = append(report.Unexpected, lines[key])
+	}
+
+	for _, jump := range jumps {
+		args := jump.args()
+		if !installedJumps[string(jump.srcChain)+" "+ruleKey(args)] {
+			report.Missing = append(report.Missing, ruleLine(append([]string{"-A", string(jump.srcChain)}, args...)))
+		}
+	}
+
+	sort.Strings(report.Missing)
+	sort.Strings(report.Unexpected)
+	return
+}
+
+// ruleLine returns the iptables-save line of the rule args.
+func ruleLine(args []string) string {
+	line := strings.Builder{}
+	for i, arg := range args {
+		if i != 0 {
+			line.WriteByte(' ')
+		}
+		if strings.ContainsAny(arg, " \t") {
+			arg = strconv.Quote(arg)
+		}
+		line.WriteString(arg)
+	}
+	return line.String()
+}
+
+// isServiceChain returns true for the chains of the services and endpoints.
+func isServiceChain(chain string) bool {
+	for _, prefix := range []string{servicechains.ServicePrefix, servicechains.EndpointPrefix, servicechains.FirewallPrefix, servicechains.LocalPrefix, servicechains.BucketPrefix} {
+		if strings.HasPrefix(chain, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultOptions are the options iptables-save adds to the rules of the backend.
+var defaultOptions = map[string]bool{
+	"--reject-with icmp-port-unreachable":            true,
+	"--reject-with icmp6-port-unreachable":           true,
+	"--mask 255.255.255.255":                         true,
+	"--mask ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff": true,
+	"--rsource": true,
+}
+
+// ruleKey returns a key of the arguments of a rule (after its chain), the same for the rule
+// written by the backend and listed by iptables-save: the options are sorted, the modules
+// omitted, and the values iptables-save rewrites are canonicalized.
+func ruleKey(args []string) string {
+	options := []string{}
+	negate := false
+
+	for i := 0; i < len(args); i++ {
+		if args[i] == "!" {
+			negate = true
+			continue
+		}
+
+		option := args[i]
+		values := []string{}
+		for i+1 < len(args) && !isOption(args[i+1]) {
+			i++
+			values = append(values, args[i])
+		}
+
+		if option != "-m" {
+			if key := optionKey(option, values); !defaultOptions[key] {
+				if negate {
+					key = "! " + key
+				}
+				options = append(options, key)
+			}
+		}
+		negate = false
+	}
+
+	sort.Strings(options)
+	return strings.Join(options, " ")
+}
+
+func isOption(arg string) bool {
+	return arg == "!" || len(arg) > 1 && arg[0] == '-' && (arg[1] < '0' || arg[1] > '9')
+}
+
+// optionKey returns the canonical option and values.
+func optionKey(option string, values []string) string {
+	value := strings.Join(values, " ")
+
+	switch option {
+	case "-s", "-d":
+		value = strings.TrimSuffix(strings.TrimSuffix(value, "/32"), "/128")
+
+	case "--ctstate":
+		states := strings.Split(value, ",")
+		sort.Strings(states)
+		value = strings.Join(states, ",")
+
+	case "--probability":
+		// iptables-save prints the probability rounded to its precision
+		if p, err := strconv.ParseFloat(value, 64); err == nil {
+			value = strconv.FormatFloat(p, 'f', 6, 64)
+		}
+
+	case "--mark", "--set-xmark":
+		value = markKey(value)
+
+	case "--or-mark":
+		// listed as --set-xmark <mark>/<mark>
+		option, value = "--set-xmark", markKey(value+"/"+value)
+
+	case "--xor-mark":
+		// listed as --set-xmark <mark>/0x0
+		option, value = "--set-xmark", markKey(value+"/0")
+	}
+
+	if value == "" {
+		return option
+	}
+	return option + " " + value
+}
+
+// markKey returns the canonical form of a mark with an optional mask.
+func markKey(value string) string {
+	parts := strings.Split(value, "/")
+	for i, part := range parts {
+		if n, err := strconv.ParseUint(part, 0, 32); err == nil {
+			parts[i] = fmt.Sprintf("%#x", n)
+		}
+	}
+	return strings.Join(parts, "/")
+}
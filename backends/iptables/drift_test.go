@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/iptables/util"
+)
+
+func TestDrift(t *testing.T) {
+	prevImpl := IptablesImpl
+	defer func() { IptablesImpl = prevImpl }()
+
+	kernel := newFakeKernel(util.ProtocolIPv4)
+
+	impl := NewIptables()
+	impl.iptInterface = kernel
+	impl.serviceChanges = NewServiceChangeTracker(newServiceInfo, v1.IPv4Protocol, nil)
+	impl.endpointsChanges = NewEndpointChangeTracker("node", v1.IPv4Protocol, nil)
+	IptablesImpl = map[v1.IPFamily]*iptables{v1.IPv4Protocol: impl}
+
+	backend := New()
+	if _, err := backend.Drift(); err == nil {
+		t.Error("expected the drift to fail before the first sync")
+	}
+
+	backend.SetService(&localv1.Service{
+		Namespace: "ns",
+		Name:      "web",
+		IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.0.0.1"), ExternalIPs: &localv1.IPSet{}},
+		Ports:     []*localv1.PortMapping{{Name: "http", Protocol: localv1.Protocol_TCP, Port: 80, TargetPort: 8080}},
+	})
+	backend.SetEndpoint("ns", "web", "a", &localv1.Endpoint{IPs: localv1.NewIPSet("10.244.0.2")})
+	backend.Sync()
+	if err := backend.SyncErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := backend.Drift()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Drifted() {
+		t.Fatalf("expected no drift after a sync, got %s", report)
+	}
+
+	// someone flushes a service chain, removes a jump and adds a service chain
+	svcChain := servicePortChainName("ns/web:http", localv1.Protocol_TCP)
+	kernel.Tables[util.TableNAT][svcChain] = nil
+	kernel.Tables[util.TableNAT][util.ChainPrerouting] = nil
+	kernel.Tables[util.TableNAT]["KUBE-SVC-OTHER"] = [][]string{{"-j", "RETURN"}}
+
+	report, err = backend.Drift()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	missing := strings.Join(report.Missing, "\n")
+	if !strings.Contains(missing, "IPv4 nat -A "+string(svcChain)+" ") {
+		t.Errorf("expected the rules of %s to be missing, got\n%s", svcChain, missing)
+	}
+	if !strings.Contains(missing, `IPv4 nat -A PREROUTING -m comment --comment "kubernetes service portals" -j KUBE-SERVICES`) {
+		t.Errorf("expected the jump from PREROUTING to be missing, got\n%s", missing)
+	}
+	if len(report.Unexpected) != 1 || report.Unexpected[0] != "IPv4 nat chain KUBE-SVC-OTHER" {
+		t.Errorf("expected the unexpected chain, got %q", report.Unexpected)
+	}
+}
+
+func TestRuleKey(t *testing.T) {
+	// rules written by the backend, and as listed by iptables-save
+	for _, tc := range [][2]string{
+		{
+			`-m comment --comment "ns/web:http cluster IP" -m tcp -p tcp -d 10.0.0.1/32 --dport 80 -j KUBE-SVC-X`,
+			`-d 10.0.0.1/32 -p tcp -m comment --comment "ns/web:http cluster IP" -m tcp --dport 80 -j KUBE-SVC-X`,
+		},
+		{"-j MARK --or-mark 0x004000", "-j MARK --set-xmark 0x4000/0x4000"},
+		{"-j MARK --xor-mark 0x004000", "-j MARK --set-xmark 0x4000/0x0"},
+		{"-m mark ! --mark 0x004000/0x004000 -j RETURN", "-m mark ! --mark 0x4000/0x4000 -j RETURN"},
+		{"-m statistic --mode random --probability 0.3333333333 -j KUBE-SEP-A", "-m statistic --mode random --probability 0.33333333349 -j KUBE-SEP-A"},
+		{"-m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT", "-m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT"},
+		{"-d 10.0.0.1 -j REJECT", "-d 10.0.0.1/32 -j REJECT --reject-with icmp-port-unreachable"},
+		{
+			"-m recent --name KUBE-SEP-A --rcheck --seconds 10800 --reap -j KUBE-SEP-A",
+			"-m recent --rcheck --seconds 10800 --reap --name KUBE-SEP-A --mask 255.255.255.255 --rsource -j KUBE-SEP-A",
+		},
+	} {
+		written, saved := ruleKey(util.SplitRestoreLine(tc[0])), ruleKey(util.SplitRestoreLine(tc[1]))
+		if written != saved {
+			t.Errorf("expected the same key for %q and %q, got %q and %q", tc[0], tc[1], written, saved)
+		}
+	}
+
+	if ruleKey([]string{"-s", "10.0.0.1", "-j", "DROP"}) == ruleKey([]string{"!", "-s", "10.0.0.1", "-j", "DROP"}) {
+		t.Error("expected the negation to change the key")
+	}
+}
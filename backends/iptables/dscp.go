@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"strconv"
+	"strings"
+)
+
+// hasDSCPServices returns true if a service port sets a DSCP value.
+func (t *iptables) hasDSCPServices() bool {
+	for _, svcPortMap := range t.serviceMap {
+		for _, svc := range svcPortMap {
+			if svcInfo, ok := svc.(*serviceInfo); ok && svcInfo.DSCP() != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeDSCPRules sets the DSCP of the packets sent to the IPs and node port of a service port.
+// The mangle chains see the packets before their DNAT, and so the service addresses.
+func (t *iptables) writeDSCPRules(svcInfo *serviceInfo, args []string) {
+	protocol := strings.ToLower(svcInfo.Protocol().String())
+	port := strconv.Itoa(svcInfo.Port())
+
+	appendTo := []string{"-A", string(kubeDSCPChain), "-m", "comment", "--comment", svcInfo.comment("dscp")}
+	setDSCP := []string{"-j", "DSCP", "--set-dscp", strconv.Itoa(int(svcInfo.DSCP()))}
+
+	destinations := append(append([]string{}, svcInfo.ExternalIPStrings()...), svcInfo.LoadBalancerIPStrings()...)
+	if svcInfo.ClusterIP() != nil {
+		destinations = append([]string{svcInfo.ClusterIP().String()}, destinations...)
+	}
+	for _, ip := range destinations {
+		args = append(args[:0], "-d", ip, "-p", protocol, "-m", protocol, "--dport", port)
+		t.mangleRules.Write(appendTo, args, setDSCP)
+	}
+
+	if svcInfo.NodePort() != 0 {
+		args = append(args[:0], "-m", "addrtype", "--dst-type", "LOCAL",
+			"-p", protocol, "-m", protocol, "--dport", strconv.Itoa(svcInfo.NodePort()))
+		t.mangleRules.Write(appendTo, args, setDSCP)
+	}
+}
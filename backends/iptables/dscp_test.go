@@ -0,0 +1,103 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/iptables/util"
+)
+
+func TestDSCP(t *testing.T) {
+	prevImpl := IptablesImpl
+	defer func() { IptablesImpl = prevImpl }()
+
+	kernel := newFakeKernel(util.ProtocolIPv4)
+
+	impl := NewIptables()
+	impl.iptInterface = kernel
+	impl.serviceChanges = NewServiceChangeTracker(newServiceInfo, v1.IPv4Protocol, nil)
+	impl.endpointsChanges = NewEndpointChangeTracker("node", v1.IPv4Protocol, nil)
+	IptablesImpl = map[v1.IPFamily]*iptables{v1.IPv4Protocol: impl}
+
+	rules := func(chain util.Chain) (rules []string) {
+		for _, rule := range kernel.Tables[util.TableMangle][chain] {
+			rules = append(rules, strings.Join(rule, " "))
+		}
+		return
+	}
+
+	backend := New()
+
+	voice := &localv1.Service{
+		Namespace: "ns",
+		Name:      "voice",
+		Type:      "NodePort",
+		IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.96.0.30"), ExternalIPs: localv1.NewIPSet("192.0.2.1")},
+		Ports:     []*localv1.PortMapping{{Name: "rtp", Protocol: localv1.Protocol_UDP, Port: 5004, NodePort: 30004, TargetPort: 5004}},
+	}
+	backend.SetService(voice)
+	backend.Sync()
+	if err := backend.SyncErr(); err != nil {
+		t.Fatal(err)
+	}
+	if got := rules(util.ChainPrerouting); len(got) != 0 {
+		t.Errorf("expected no mangle jump, got %q", got)
+	}
+
+	voice.DSCP = 46
+	backend.SetService(voice)
+	backend.Sync()
+	if err := backend.SyncErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := strings.Join(rules(kubeDSCPChain), "\n")
+	for _, expected := range []string{
+		"-d 10.96.0.30 -p udp -m udp --dport 5004 -j DSCP --set-dscp 46",
+		"-d 192.0.2.1 -p udp -m udp --dport 5004 -j DSCP --set-dscp 46",
+		"-m addrtype --dst-type LOCAL -p udp -m udp --dport 30004 -j DSCP --set-dscp 46",
+	} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("missing rule %q in:\n%s", expected, got)
+		}
+	}
+	if n := len(rules(kubeDSCPChain)); n != 3 {
+		t.Errorf("expected 3 rules, got %d:\n%s", n, got)
+	}
+
+	for _, chain := range []util.Chain{util.ChainPrerouting, util.ChainOutput} {
+		if got := rules(chain); len(got) != 1 || !strings.HasSuffix(got[0], "-j "+string(kubeDSCPChain)) {
+			t.Errorf("mangle %s: expected a jump to %s, got %q", chain, kubeDSCPChain, got)
+		}
+	}
+
+	// the chain is flushed when the service doesn't set a DSCP anymore
+	voice.DSCP = 0
+	backend.SetService(voice)
+	backend.Sync()
+	if err := backend.SyncErr(); err != nil {
+		t.Fatal(err)
+	}
+	if got := rules(kubeDSCPChain); len(got) != 0 {
+		t.Errorf("expected the chain to be flushed, got %q", got)
+	}
+}
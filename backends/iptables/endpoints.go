@@ -196,6 +196,9 @@ func (em EndpointsMap) merge(other EndpointsMap) {
 			if endpointEntry == nil {
 				//TODO : if servicemap contains UDP port , then save the namespace, name ,protocol and epip
 				//  in cache as stale
+				if em[service] == nil {
+					continue
+				}
 				delete(*(em[service]), hash)
 				if len(*em[service]) <= 0 {
 					delete(em, service)
@@ -59,10 +59,26 @@ func (cache *EndpointsCache) updatePending(svcKey types.NamespacedName, key stri
 		esInfoMap = &endpointsInfoByName{}
 		cache.trackerByServiceMap[svcKey] = esInfoMap
 	}
+	if endpoint != nil && !cache.hasIPFamily(endpoint) {
+		// an endpoint without an address of this family is handled as a
+		// deletion, so it does not count as a backend of the service.
+		endpoint = nil
+	}
 	(*esInfoMap)[key] = endpoint
 	return true
 }
 
+// hasIPFamily returns true if the endpoint has an address of the cache's family.
+func (cache *EndpointsCache) hasIPFamily(endpoint *localv1.Endpoint) bool {
+	if endpoint.IPs == nil {
+		return false
+	}
+	if cache.ipFamily == v1.IPv6Protocol {
+		return len(endpoint.IPs.V6) > 0
+	}
+	return len(endpoint.IPs.V4) > 0
+}
+
 func (cache *EndpointsCache) isLocal(hostname string) bool {
 	return len(cache.hostname) > 0 && hostname == cache.hostname
 }
@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"strconv"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/kpng/backends/iptables/util"
+	"sigs.k8s.io/kpng/client/fuzzstate"
+)
+
+func FuzzSync(f *testing.F) {
+	fuzzstate.Seeds(f)
+
+	f.Fuzz(fuzzSync)
+}
+
+// fuzzSync syncs the state with fake kernels, then removes it.
+func fuzzSync(t *testing.T, seed uint8, data []byte) {
+	prevImpl := IptablesImpl
+	defer func() { IptablesImpl = prevImpl }()
+
+	kernels := map[v1.IPFamily]*fakeKernel{}
+	IptablesImpl = map[v1.IPFamily]*iptables{}
+
+	for _, protocol := range []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol} {
+		kernels[protocol] = newFakeKernel(util.Protocol(protocol))
+
+		impl := NewIptables()
+		impl.iptInterface = kernels[protocol]
+		impl.staleChainsGracePeriod = 0
+		impl.serviceChanges = NewServiceChangeTracker(newServiceInfo, protocol, nil)
+		impl.endpointsChanges = NewEndpointChangeTracker("fuzz-node", protocol, nil)
+		IptablesImpl[protocol] = impl
+	}
+
+	backend := New()
+	state := fuzzstate.State(seed, data)
+
+	for _, seps := range state {
+		backend.SetService(seps.Service)
+		for i, ep := range seps.Endpoints {
+			backend.SetEndpoint(seps.Service.Namespace, seps.Service.Name, strconv.Itoa(i), ep)
+		}
+	}
+
+	// the second sync starts from the chains of the first one
+	for i := 0; i < 2; i++ {
+		backend.Sync()
+		if err := backend.SyncErr(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// removing all the services must remove all their chains
+	for _, seps := range state {
+		for i := range seps.Endpoints {
+			backend.DeleteEndpoint(seps.Service.Namespace, seps.Service.Name, strconv.Itoa(i))
+		}
+		backend.DeleteService(seps.Service.Namespace, seps.Service.Name)
+	}
+
+	backend.Sync()
+	if err := backend.SyncErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	for protocol, kernel := range kernels {
+		for table, chains := range kernel.Tables {
+			for chain := range chains {
+				if isServiceChain(string(chain)) {
+					t.Errorf("%s %s chain %s left after removing all the services", protocol, table, chain)
+				}
+			}
+		}
+	}
+}
+
+// fakeKernel is the in-memory kernel of the tests.
+type fakeKernel = util.Memory
+
+func newFakeKernel(protocol util.Protocol) *fakeKernel {
+	return util.NewMemory(protocol)
+}
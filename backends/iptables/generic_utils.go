@@ -29,6 +29,8 @@ import (
 	"k8s.io/client-go/tools/events"
 	"k8s.io/klog/v2"
 	utilnet "k8s.io/utils/net"
+
+	"sigs.k8s.io/kpng/backends/iptables/util"
 )
 
 const (
@@ -99,25 +101,10 @@ func RevertPorts(replacementPortsMap, originalPortsMap map[utilnet.LocalPort]uti
 	}
 }
 
-// GetLocalAddrs returns a list of all network addresses on the local system
+// GetLocalAddrs returns a list of all network addresses on the local system, cached until
+// netlink notifies an address change or the --local-addresses-ttl expires.
 func GetLocalAddrs() ([]net.IP, error) {
-	var localAddrs []net.IP
-
-	addrs, err := net.InterfaceAddrs()
-	if err != nil {
-		return nil, err
-	}
-
-	for _, addr := range addrs {
-		ip, _, err := net.ParseCIDR(addr.String())
-		if err != nil {
-			return nil, err
-		}
-
-		localAddrs = append(localAddrs, ip)
-	}
-
-	return localAddrs, nil
+	return util.LocalAddrs()
 }
 
 // GetLocalAddrSet return a local IPSet.
@@ -219,6 +206,24 @@ func GetNodeAddresses(cidrs []string, nw NetworkInterfacer) (sets.String, error)
 	return uniqueAddressList, nil
 }
 
+// FilterNodeAddressesByFamily returns the node addresses (IPs or zero CIDRs,
+// as returned by GetNodeAddresses) that belong to the given family.
+func FilterNodeAddressesByFamily(addresses sets.String, isIPv6 bool) sets.String {
+	filtered := sets.NewString()
+	for address := range addresses {
+		if IsZeroCIDR(address) {
+			if (address == IPv6ZeroCIDR) == isIPv6 {
+				filtered.Insert(address)
+			}
+			continue
+		}
+		if utilnet.IsIPv6String(address) == isIPv6 {
+			filtered.Insert(address)
+		}
+	}
+	return filtered
+}
+
 // GetClusterIPByFamily returns a service clusterip by family
 func GetClusterIPByFamily(ipFamily v1.IPFamily, service *localv1.Service) string {
 	if ipFamily == v1.IPv4Protocol {
@@ -3,6 +3,7 @@ module sigs.k8s.io/kpng/backends/iptables
 go 1.19
 
 require (
+	github.com/google/nftables v0.1.0
 	github.com/spf13/pflag v1.0.5
 	golang.org/x/net v0.0.0-20221004154528-8021a29435af // indirect
 	golang.org/x/sys v0.0.0-20221010170243-090e33056c14
@@ -13,7 +14,16 @@ require (
 	k8s.io/utils v0.0.0-20221011040102-427025108f67
 )
 
-require sigs.k8s.io/kpng/client v0.0.0-20221011133104-469299451522
+require (
+	sigs.k8s.io/kpng/backends/common v0.0.0-00010101000000-000000000000
+	sigs.k8s.io/kpng/client v0.0.0-20221011133104-469299451522
+)
+
+require (
+	github.com/josharian/native v0.0.0-20200817173448-b6b71def0850 // indirect
+	github.com/mdlayher/netlink v1.4.2 // indirect
+	github.com/mdlayher/socket v0.0.0-20211102153432-57e3fa563ecb // indirect
+)
 
 require (
 	github.com/beorn7/perks v1.0.1 // indirect
@@ -42,7 +52,7 @@ require (
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
-	github.com/prometheus/client_golang v1.12.1 // indirect
+	github.com/prometheus/client_golang v1.12.1
 	github.com/prometheus/client_model v0.2.0 // indirect
 	github.com/prometheus/common v0.32.1 // indirect
 	github.com/prometheus/procfs v0.7.3 // indirect
@@ -69,3 +79,5 @@ require (
 	google.golang.org/appengine v1.6.7 // indirect
 	k8s.io/component-base v0.25.2
 )
+
+replace sigs.k8s.io/kpng/backends/common => ../common
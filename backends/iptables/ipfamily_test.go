@@ -0,0 +1,203 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/iptables/util"
+)
+
+// familyAddresses are the addresses of one family used by the matrix.
+type familyAddresses struct {
+	clusterIP, v4BackedClusterIP, externalIP, loadBalancerIP, sourceRange, endpointIP string
+}
+
+var testFamilyAddresses = map[v1.IPFamily]familyAddresses{
+	v1.IPv4Protocol: {
+		clusterIP:         "10.96.0.10",
+		v4BackedClusterIP: "10.96.0.11",
+		externalIP:        "192.0.2.10",
+		loadBalancerIP:    "198.51.100.10",
+		sourceRange:       "203.0.113.0/24",
+		endpointIP:        "10.1.0.1",
+	},
+	v1.IPv6Protocol: {
+		clusterIP:         "fd00:96::10",
+		v4BackedClusterIP: "fd00:96::11",
+		externalIP:        "2001:db8::10",
+		loadBalancerIP:    "2001:db8:1::10",
+		sourceRange:       "2001:db8:2::/64",
+		endpointIP:        "fd00:1::1",
+	},
+}
+
+// noNetwork is a NetworkInterfacer without interfaces.
+type noNetwork struct{}
+
+func (noNetwork) Addrs(intf *net.Interface) ([]net.Addr, error) { return nil, nil }
+func (noNetwork) Interfaces() ([]net.Interface, error)          { return nil, nil }
+
+func TestIPFamilies(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		families          []v1.IPFamily
+		nodePortAddresses []string
+		// noNodePorts are the families without a jump to KUBE-NODEPORTS
+		noNodePorts []v1.IPFamily
+	}{
+		{name: "v4-only", families: []v1.IPFamily{v1.IPv4Protocol}},
+		{name: "v6-only", families: []v1.IPFamily{v1.IPv6Protocol}},
+		{name: "dual", families: []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol}},
+		{
+			name:              "dual with v4 nodeport addresses",
+			families:          []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol},
+			nodePortAddresses: []string{IPv4ZeroCIDR},
+			noNodePorts:       []v1.IPFamily{v1.IPv6Protocol},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rules := syncIPFamilies(t, tc.families, tc.nodePortAddresses)
+
+			for _, family := range tc.families {
+				addrs := testFamilyAddresses[family]
+				out := rules[family]
+
+				for _, expected := range []string{
+					"-d " + ToCIDR(net.ParseIP(addrs.clusterIP)),
+					"-d " + ToCIDR(net.ParseIP(addrs.externalIP)),
+					"-d " + ToCIDR(net.ParseIP(addrs.loadBalancerIP)),
+					"-s " + addrs.sourceRange,
+					"--to-destination " + net.JoinHostPort(addrs.endpointIP, "8080"),
+				} {
+					if !strings.Contains(out, expected) {
+						t.Errorf("%s: no %q rule:\n%s", family, expected, out)
+					}
+				}
+
+				// the v4 endpoint must not count as a backend in ip6tables
+				rejected := strings.Contains(out, "-d "+addrs.v4BackedClusterIP+" --dport 80 -j REJECT")
+				if rejected != (family == v1.IPv6Protocol) {
+					t.Errorf("%s: reject rule for the v4-backed service is %t:\n%s", family, rejected, out)
+				}
+
+				other := testFamilyAddresses[OtherIPFamily(family)]
+				for _, ip := range []string{other.clusterIP, other.externalIP, other.loadBalancerIP, other.sourceRange, other.endpointIP} {
+					if strings.Contains(out, ip) {
+						t.Errorf("%s: rules reference %s:\n%s", family, ip, out)
+					}
+				}
+
+				expectNodePorts := true
+				for _, f := range tc.noNodePorts {
+					if f == family {
+						expectNodePorts = false
+					}
+				}
+				for _, rule := range []string{
+					"--dport 30080 -j KUBE-MARK-MASQ",
+					"-A KUBE-SERVICES -m comment --comment \"kubernetes service nodeports;",
+				} {
+					if found := strings.Contains(out, rule); found != expectNodePorts {
+						t.Errorf("%s: %q rule is %t, expected %t:\n%s", family, rule, found, expectNodePorts, out)
+					}
+				}
+			}
+		})
+	}
+}
+
+// syncIPFamilies syncs a dual-stack load-balancer service and a dual-stack
+// service with only a v4 endpoint on fake kernels of the given families, and returns their
+// nat and filter rules.
+func syncIPFamilies(t *testing.T, families []v1.IPFamily, nodePortAddresses []string) map[v1.IPFamily]string {
+	prevImpl := IptablesImpl
+	defer func() { IptablesImpl = prevImpl }()
+
+	kernels := map[v1.IPFamily]*fakeKernel{}
+	IptablesImpl = map[v1.IPFamily]*iptables{}
+
+	for _, family := range families {
+		kernels[family] = newFakeKernel(util.Protocol(family))
+
+		impl := NewIptables()
+		impl.iptInterface = kernels[family]
+		impl.nodePortAddresses = nodePortAddresses
+		impl.networkInterfacer = noNetwork{}
+		impl.serviceChanges = NewServiceChangeTracker(newServiceInfo, family, nil)
+		impl.endpointsChanges = NewEndpointChangeTracker("node", family, nil)
+		IptablesImpl[family] = impl
+	}
+
+	v4, v6 := testFamilyAddresses[v1.IPv4Protocol], testFamilyAddresses[v1.IPv6Protocol]
+	ports := []*localv1.PortMapping{
+		{Name: "http", Protocol: localv1.Protocol_TCP, Port: 80, NodePort: 30080, TargetPort: 8080},
+	}
+
+	backend := New()
+	backend.SetService(&localv1.Service{
+		Namespace: "ns",
+		Name:      "lb",
+		Type:      "LoadBalancer",
+		IPs: &localv1.ServiceIPs{
+			ClusterIPs:      localv1.NewIPSet(v4.clusterIP, v6.clusterIP),
+			ExternalIPs:     localv1.NewIPSet(v4.externalIP, v6.externalIP),
+			LoadBalancerIPs: localv1.NewIPSet(v4.loadBalancerIP, v6.loadBalancerIP),
+		},
+		IPFilters: []*localv1.IPFilter{
+			{SourceRanges: []string{v4.sourceRange, v6.sourceRange}},
+		},
+		Ports: ports,
+	})
+	backend.SetEndpoint("ns", "lb", "v4", &localv1.Endpoint{IPs: localv1.NewIPSet(v4.endpointIP)})
+	backend.SetEndpoint("ns", "lb", "v6", &localv1.Endpoint{IPs: localv1.NewIPSet(v6.endpointIP)})
+
+	backend.SetService(&localv1.Service{
+		Namespace: "ns",
+		Name:      "v4-backed",
+		Type:      "ClusterIP",
+		IPs: &localv1.ServiceIPs{
+			ClusterIPs:  localv1.NewIPSet(v4.v4BackedClusterIP, v6.v4BackedClusterIP),
+			ExternalIPs: localv1.NewIPSet(),
+		},
+		Ports: []*localv1.PortMapping{
+			{Name: "http", Protocol: localv1.Protocol_TCP, Port: 80, TargetPort: 8080},
+		},
+	})
+	backend.SetEndpoint("ns", "v4-backed", "v4", &localv1.Endpoint{IPs: localv1.NewIPSet("10.1.0.2")})
+
+	backend.Sync()
+	if err := backend.SyncErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := map[v1.IPFamily]string{}
+	for family, kernel := range kernels {
+		buf := new(bytes.Buffer)
+		for _, table := range []util.Table{util.TableNAT, util.TableFilter} {
+			kernel.SaveInto(table, buf)
+		}
+		rules[family] = buf.String()
+	}
+	return rules
+}
@@ -35,6 +35,8 @@ import (
 	"k8s.io/klog/v2"
 	localv1 "sigs.k8s.io/kpng/api/localv1"
 	"sigs.k8s.io/kpng/backends/iptables/util"
+	"sigs.k8s.io/kpng/client/auditlog"
+	"sigs.k8s.io/kpng/client/nodelocaldns"
 
 	utilnet "k8s.io/utils/net"
 )
@@ -42,6 +44,14 @@ import (
 var (
 	onlyOutput    bool
 	masqueradeAll bool
+
+	staleChainsGracePeriod time.Duration
+
+	balancingChainSize int
+
+	dropInvalid bool
+	markDrop    bool
+	dropBit     int
 )
 
 func BindFlags(flags *pflag.FlagSet) {
@@ -67,6 +77,24 @@ type iptables struct {
 	masqueradeAll  bool
 	masqueradeMark string
 
+	// dropInvalid drops the forwarded packets in INVALID conntrack state.
+	dropInvalid bool
+	// markDrop writes the KUBE-MARK-DROP and KUBE-FIREWALL rules, dropping
+	// the packets marked with dropMark.
+	markDrop bool
+	dropMark string
+
+	// nodeLocalDNS are the addresses exempted from the service rules, and
+	// bypassing conntrack if required.
+	nodeLocalDNS *nodelocaldns.Cache
+
+	// noTrack is set once a service bypassed conntrack, and the KUBE-NOTRACK
+	// chain is kept from then on (so it's flushed when the services go away).
+	noTrack bool
+
+	// dscp is set once a service set a DSCP value, like noTrack for KUBE-DSCP.
+	dscp bool
+
 	nodeIP       net.IP
 	recorder     events.EventRecorder
 	serviceMap   ServicesSnapshot
@@ -85,6 +113,10 @@ type iptables struct {
 	filterRules              util.LineBuffer
 	natChains                util.LineBuffer
 	natRules                 util.LineBuffer
+	rawChains                util.LineBuffer
+	rawRules                 util.LineBuffer
+	mangleChains             util.LineBuffer
+	mangleRules              util.LineBuffer
 
 	// endpointChainsNumber is the total amount of endpointChains across all
 	// services that we will generate (it is computed at the beginning of
@@ -95,6 +127,35 @@ type iptables struct {
 	// Values are as a parameter to select the interfaces where nodeport works.
 	nodePortAddresses []string
 
+	// staleChainsGracePeriod is how long the chains of deleted services and
+	// endpoints are kept before being removed, letting existing flows finish.
+	// staleChains is the deletion queue, with the time each chain became unused.
+	// staleChainsTimer resyncs when the first grace period expires, as the
+	// chains are only deleted by a sync.
+	staleChainsGracePeriod time.Duration
+	staleChains            map[util.Chain]time.Time
+	staleChainsTimer       *time.Timer
+
+	// balancingChainSize is the number of endpoints above which the balancing
+	// rules of a service are split in nested chains (0 to disable).
+	balancingChainSize int
+
+	// reconcileMode tells if the rules others added to the kube chains are kept.
+	// ownedRules are the keys of the rules of kpng in the last restore
+	// ("<table> <chain> <key>"), in owner-only mode.
+	reconcileMode ReconcileMode
+	ownedRules    map[string]bool
+
+	// appliedRules are the rules of the last restore ("<table> <rule>"), to
+	// record the changes in the audit log.
+	appliedRules map[string]bool
+
+	// syncErr is the error of the last sync, reported to the client by Backend.SyncErr.
+	syncErr error
+	// onSyncErr is called with the error of a failed resync of the stale chains, so the client
+	// re-delivers the state (see Backend.OnSyncErr).
+	onSyncErr func(error)
+
 	// Inject for test purpose.
 	networkInterfacer NetworkInterfacer
 	serviceChanges    *ServiceChangeTracker
@@ -119,15 +180,30 @@ func NewIptables() *iptables {
 		filterRules:              util.LineBuffer{},
 		natChains:                util.LineBuffer{},
 		natRules:                 util.LineBuffer{},
+		rawChains:                util.LineBuffer{},
+		rawRules:                 util.LineBuffer{},
+		mangleChains:             util.LineBuffer{},
+		mangleRules:              util.LineBuffer{},
 		portsMap:                 make(map[utilnet.LocalPort]utilnet.Closeable),
 		masqueradeAll:            masqueradeAll,
 		masqueradeMark:           fmt.Sprintf("%#08x", masqueradeValue),
+		dropInvalid:              dropInvalid,
+		markDrop:                 markDrop,
+		dropMark:                 fmt.Sprintf("%#08x", 1<<uint(dropBit)),
+		nodeLocalDNS:             nodelocaldns.Default(),
 		localDetector:            NewNoOpLocalDetector(),
+		staleChainsGracePeriod:   staleChainsGracePeriod,
+		staleChains:              make(map[util.Chain]time.Time),
+		balancingChainSize:       balancingChainSize,
+		reconcileMode:            ReconcileMode(reconcileMode),
 	}
 }
 
 func (t *iptables) sync() {
 	defer wg.Done()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	// This is where the actual kube-proxy legacy logic takes over...
 
 	// We assume that if this was called, we really want to sync them,
@@ -136,8 +212,43 @@ func (t *iptables) sync() {
 	t.serviceMap.Update(t.serviceChanges)
 	endpointUpdateResult := t.endpointsMap.Update(t.endpointsChanges)
 
+	t.syncRules(endpointUpdateResult)
+}
+
+// resyncStaleChains writes the rules of the last synced state again, without
+// the changes received since, so the stale chains whose grace period expired
+// are deleted.
+func (t *iptables) resyncStaleChains() {
+	t.mu.Lock()
+	klog.V(2).InfoS("Resyncing to delete the expired stale chains")
+	t.syncRules(UpdateEndpointMapResult{})
+	err := t.syncErr
+	t.mu.Unlock()
+
+	// without t.mu held, the client syncs again on failure
+	if err != nil && t.onSyncErr != nil {
+		t.onSyncErr(err)
+	}
+}
+
+// lastSyncErr returns the error of the last sync.
+func (t *iptables) lastSyncErr() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.syncErr
+}
+
+// syncRules writes the rules of the services and endpoints maps.
+func (t *iptables) syncRules(endpointUpdateResult UpdateEndpointMapResult) {
 	klog.InfoS("Syncing iptables rules")
 
+	if !t.noTrack {
+		t.noTrack = t.hasNoTrackServices()
+	}
+	if !t.dscp {
+		t.dscp = t.hasDSCPServices()
+	}
+
 	// success := false
 	// defer func() {
 	// 	if !success {
@@ -161,15 +272,21 @@ func (t *iptables) sync() {
 	// Write iptables header lines to specific chain indicies...
 	t.filterChains.Write("*filter")
 	t.natChains.Write("*nat")
+	t.rawChains.Write("*raw")
+	t.mangleChains.Write("*mangle")
 
 	// Make sure we keep stats for the top-level chains, if they existed
 	// (which most should have because we created them above).
 	t.createTopLevelChains(existingFilterChains, existingNATChains)
 
+	// The node-local DNS cache exemptions must come first in their chains.
+	t.writeNodeLocalDNSRules()
+
 	// Install the kubernetes-specific postrouting rules. We use a whole chain for
 	// this so that it is easier to flush and change, for example if the mark
 	// value should ever change.
 	t.writePostRoutingMasqRules()
+	t.writeMarkDropRules()
 
 	// Accumulate NAT chains to keep.
 	activeNATChains := map[util.Chain]bool{} // use a map as a set
@@ -196,9 +313,12 @@ func (t *iptables) sync() {
 	if err != nil {
 		klog.ErrorS(err, "Failed to get node ip address matching nodeport cidrs, services with nodeport may not work as intended", "CIDRs", t.nodePortAddresses)
 	}
+	// Only keep the addresses (and the zero CIDR) of this table's family.
+	nodeAddresses = FilterNodeAddressesByFamily(nodeAddresses, t.iptInterface.IsIPv6())
 
-	// Build rules for each service.
-	for svcName, svcPortMap := range t.serviceMap {
+	// Build rules for each service, the ones of the higher priority classes first.
+	for _, svcName := range t.serviceMap.byPriority() {
+		svcPortMap := t.serviceMap[svcName]
 		for _, svc := range svcPortMap {
 			svcInfo, ok := svc.(*serviceInfo)
 			if !ok {
@@ -224,11 +344,18 @@ func (t *iptables) sync() {
 			t.writeLoadBalancerRules(svcInfo, svcName, args[:0])
 			t.writeNodePortsRules(svcInfo, nodeAddresses, svcName, localAddrSet, replacementPortsMap, args[:0])
 
+			if svcInfo.NoTrack() {
+				t.writeNoTrackRules(svcInfo, allEndpoints, args[:0])
+			}
+			if svcInfo.DSCP() != 0 {
+				t.writeDSCPRules(svcInfo, args[:0])
+			}
+
 			if !hasEndpoints {
 				continue
 			}
 
-			t.writeEndpointRules(svcInfo, svcName, endpointChains, endpoints, &args, endpointPortMap)
+			t.writeEndpointRules(svcInfo, svcName, endpointChains, endpoints, &args, endpointPortMap, existingNATChains, activeNATChains)
 
 			// The logic below this applies only if this service is marked as OnlyLocal
 			if svcInfo.NodeLocalExternal() {
@@ -244,6 +371,7 @@ func (t *iptables) sync() {
 	t.writeNodePortJumpRule(nodeAddresses, args[:0])
 	t.writeMiscFilterRules()
 	err = t.applyAllRules()
+	t.syncErr = err
 	if err != nil {
 		klog.ErrorS(err, "Failed to execute iptables-restore")
 		IptablesRestoreFailuresTotal.Inc()
@@ -300,6 +428,26 @@ func (t *iptables) createTopLevelChains(existingFilterChains map[util.Chain][]by
 		existingFilterChains, &t.filterChains)
 	t.copyExistingChains([]util.Chain{kubeServicesChain, kubeNodePortsChain, kubePostroutingChain, KubeMarkMasqChain},
 		existingNATChains, &t.natChains)
+
+	if t.markDrop {
+		t.copyExistingChains([]util.Chain{kubeFirewallChain}, existingFilterChains, &t.filterChains)
+		t.copyExistingChains([]util.Chain{KubeMarkDropChain}, existingNATChains, &t.natChains)
+	}
+
+	if t.nodeLocalDNS.NoTrack() {
+		t.rawChains.Write(util.MakeChainLine(kubeNodeLocalDNSChain))
+	}
+	if t.noTrack {
+		t.rawChains.Write(util.MakeChainLine(kubeNoTrackChain))
+	}
+	if t.dscp {
+		t.mangleChains.Write(util.MakeChainLine(kubeDSCPChain))
+	}
+}
+
+// writesRawTable tells if the sync writes rules in the raw table.
+func (t *iptables) writesRawTable() bool {
+	return t.nodeLocalDNS.NoTrack() || t.noTrack
 }
 
 func (t *iptables) writePostRoutingMasqRules() {
@@ -339,15 +487,99 @@ func (t *iptables) writePostRoutingMasqRules() {
 	)
 }
 
+// writeMarkDropRules writes the rules of the kubelet dropping the packets
+// marked by KUBE-MARK-DROP (ie: rejected by the load-balancer source ranges)
+// and the martian packets to the IPv4 localnet.
+// NB: THIS MUST MATCH the corresponding code in the kubelet
+func (t *iptables) writeMarkDropRules() {
+	if !t.markDrop {
+		return
+	}
+
+	t.natRules.Write(
+		"-A", string(KubeMarkDropChain),
+		"-j", "MARK", "--or-mark", t.dropMark,
+	)
+	t.filterRules.Write(
+		"-A", string(kubeFirewallChain),
+		"-m", "comment", "--comment", `"kubernetes firewall for dropping marked packets"`,
+		"-m", "mark", "--mark", fmt.Sprintf("%s/%s", t.dropMark, t.dropMark),
+		"-j", "DROP",
+	)
+
+	if !t.iptInterface.IsIPv6() {
+		// The nodeports are reachable on localhost (route_localnet), so the
+		// packets from other hosts to 127.0.0.0/8 must not be accepted.
+		// https://github.com/kubernetes/kubernetes/issues/90259
+		t.filterRules.Write(
+			"-A", string(kubeFirewallChain),
+			"-m", "comment", "--comment", `"block incoming localnet connections"`,
+			"-d", "127.0.0.0/8",
+			"!", "-s", "127.0.0.0/8",
+			"-m", "conntrack", "!", "--ctstate", "RELATED,ESTABLISHED,DNAT",
+			"-j", "DROP",
+		)
+	}
+}
+
+// writeNodeLocalDNSRules keeps the traffic to the node-local DNS cache
+// addresses out of the service portals and masquerading (they may be the
+// cluster IP of kube-dns), and bypasses conntrack for their DNS traffic with
+// --node-local-dns-notrack, like the cache's own rules.
+func (t *iptables) writeNodeLocalDNSRules() {
+	const comment = `"node-local DNS cache"`
+
+	for _, ip := range t.nodeLocalDNS.IPs(t.iptInterface.IsIPv6()) {
+		t.natRules.Write("-A", string(kubeServicesChain), "-m", "comment", "--comment", comment, "-d", ip, "-j", "RETURN")
+		t.natRules.Write("-A", string(kubePostroutingChain), "-m", "comment", "--comment", comment, "-d", ip, "-j", "RETURN")
+		t.filterRules.Write("-A", string(kubeServicesChain), "-m", "comment", "--comment", comment, "-d", ip, "-j", "RETURN")
+
+		if !t.nodeLocalDNS.NoTrack() {
+			continue
+		}
+
+		port := strconv.Itoa(nodelocaldns.Port)
+		for _, proto := range []string{"udp", "tcp"} {
+			t.rawRules.Write("-A", string(kubeNodeLocalDNSChain), "-m", "comment", "--comment", comment,
+				"-d", ip, "-p", proto, "-m", proto, "--dport", port, "-j", "NOTRACK")
+			t.rawRules.Write("-A", string(kubeNodeLocalDNSChain), "-m", "comment", "--comment", comment,
+				"-s", ip, "-p", proto, "-m", proto, "--sport", port, "-j", "NOTRACK")
+		}
+	}
+}
+
 func (t *iptables) deleteStaleChains(existingNATChains map[util.Chain][]byte, activeNATChains map[util.Chain]bool) {
+	now := time.Now()
+
+	// Forget queued chains that are used again or were removed by someone else.
+	for chain := range t.staleChains {
+		if _, exists := existingNATChains[chain]; !exists || activeNATChains[chain] {
+			delete(t.staleChains, chain)
+		}
+	}
+
 	// Delete chains no longer in use.
 	for chain := range existingNATChains {
 		if !activeNATChains[chain] {
 			chainString := string(chain)
-			if !strings.HasPrefix(chainString, "KUBE-SVC-") && !strings.HasPrefix(chainString, "KUBE-SEP-") && !strings.HasPrefix(chainString, "KUBE-FW-") && !strings.HasPrefix(chainString, "KUBE-XLB-") {
+			if !isServiceChain(chainString) {
 				// Ignore chains that aren't ours.
 				continue
 			}
+
+			if t.staleChainsGracePeriod > 0 {
+				since, queued := t.staleChains[chain]
+				if !queued {
+					t.staleChains[chain] = now
+					continue
+				}
+				if now.Sub(since) < t.staleChainsGracePeriod {
+					// Not declaring the chain leaves it untouched by iptables-restore.
+					continue
+				}
+				delete(t.staleChains, chain)
+			}
+
 			// We must (as per iptables) write a chain-line for it, which has
 			// the nice effect of flushing the chain.  Then we can remove the
 			// chain.
@@ -356,6 +588,22 @@ func (t *iptables) deleteStaleChains(existingNATChains map[util.Chain][]byte, ac
 		}
 	}
 
+	if t.staleChainsTimer != nil {
+		t.staleChainsTimer.Stop()
+		t.staleChainsTimer = nil
+	}
+
+	if len(t.staleChains) != 0 {
+		klog.V(2).InfoS("Keeping stale chains until their grace period expires", "count", len(t.staleChains), "gracePeriod", t.staleChainsGracePeriod)
+
+		first := now
+		for _, since := range t.staleChains {
+			if since.Before(first) {
+				first = since
+			}
+		}
+		t.staleChainsTimer = time.AfterFunc(first.Add(t.staleChainsGracePeriod).Sub(now), t.resyncStaleChains)
+	}
 }
 
 func (t *iptables) copyExistingChains(chains []util.Chain, existingChainData map[util.Chain][]byte, newChainData *util.LineBuffer) {
@@ -376,7 +624,7 @@ func (t *iptables) writeClusterIPRules(svcInfo *serviceInfo, svcName types.Names
 	protocol := strings.ToLower(svcInfo.Protocol().String())
 	if val, ok := t.endpointsMap[svcName]; ok && len(*val) > 0 {
 		args = append(args[:0],
-			"-m", "comment", "--comment", fmt.Sprintf(`"%s cluster IP"`, svcInfo.serviceNameString),
+			"-m", "comment", "--comment", svcInfo.comment("cluster-ip"),
 			"-m", protocol, "-p", protocol,
 			"-d", ToCIDR(svcInfo.ClusterIP()),
 			"--dport", strconv.Itoa(svcInfo.Port()),
@@ -396,7 +644,7 @@ func (t *iptables) writeClusterIPRules(svcInfo *serviceInfo, svcName types.Names
 		// No endpoints.
 		t.filterRules.Write(
 			"-A", string(kubeServicesChain),
-			"-m", "comment", "--comment", fmt.Sprintf(`"%s has no endpoints"`, svcInfo.serviceNameString),
+			"-m", "comment", "--comment", svcInfo.comment("no-endpoints"),
 			"-m", protocol, "-p", protocol,
 			"-d", svcInfo.ClusterIP().String(),
 			"--dport", strconv.Itoa(svcInfo.Port()),
@@ -424,7 +672,7 @@ func (t *iptables) writeExternalIPRules(svcInfo *serviceInfo, svcName types.Name
 
 		if val, ok := t.endpointsMap[svcName]; ok && len(*val) > 0 {
 			args = append(args[:0],
-				"-m", "comment", "--comment", fmt.Sprintf(`"%s external IP"`, svcInfo.serviceNameString),
+				"-m", "comment", "--comment", svcInfo.comment("external-ip"),
 				"-m", protocol, "-p", protocol,
 				"-d", ToCIDR(net.ParseIP(externalIP)),
 				"--dport", strconv.Itoa(svcInfo.Port()),
@@ -452,7 +700,7 @@ func (t *iptables) writeExternalIPRules(svcInfo *serviceInfo, svcName types.Name
 			// No endpoints.
 			t.filterRules.Write(
 				"-A", string(kubeExternalServicesChain),
-				"-m", "comment", "--comment", fmt.Sprintf(`"%s has no endpoints"`, svcInfo.serviceNameString),
+				"-m", "comment", "--comment", svcInfo.comment("no-endpoints"),
 				"-m", protocol, "-p", protocol,
 				"-d", ToCIDR(net.ParseIP(externalIP)),
 				"--dport", strconv.Itoa(svcInfo.Port()),
@@ -479,7 +727,7 @@ func (t *iptables) writeLoadBalancerRules(svcInfo *serviceInfo, svcName types.Na
 
 				args = append(args[:0],
 					"-A", string(kubeServicesChain),
-					"-m", "comment", "--comment", fmt.Sprintf(`"%s loadbalancer IP"`, svcInfo.serviceNameString),
+					"-m", "comment", "--comment", svcInfo.comment("loadbalancer-ip"),
 					"-m", protocol, "-p", protocol,
 					"-d", ToCIDR(net.ParseIP(ingress)),
 					"--dport", strconv.Itoa(svcInfo.Port()),
@@ -489,7 +737,7 @@ func (t *iptables) writeLoadBalancerRules(svcInfo *serviceInfo, svcName types.Na
 
 				args = append(args[:0],
 					"-A", string(fwChain),
-					"-m", "comment", "--comment", fmt.Sprintf(`"%s loadbalancer IP"`, svcInfo.serviceNameString),
+					"-m", "comment", "--comment", svcInfo.comment("loadbalancer-ip"),
 				)
 
 				// Each source match rule in the FW chain may jump to either the SVC or the XLB chain
@@ -531,7 +779,7 @@ func (t *iptables) writeLoadBalancerRules(svcInfo *serviceInfo, svcName types.Na
 				// No endpoints.
 				t.filterRules.Write(
 					"-A", string(kubeExternalServicesChain),
-					"-m", "comment", "--comment", fmt.Sprintf(`"%s has no endpoints"`, svcInfo.serviceNameString),
+					"-m", "comment", "--comment", svcInfo.comment("no-endpoints"),
 					"-m", protocol, "-p", protocol,
 					"-d", ToCIDR(net.ParseIP(ingress)),
 					"--dport", strconv.Itoa(svcInfo.Port()),
@@ -567,7 +815,7 @@ func (t *iptables) writeNodePortsRules(svcInfo *serviceInfo, nodeAddresses sets.
 
 		if val, ok := t.endpointsMap[svcName]; ok && len(*val) > 0 {
 			args = append(args[:0],
-				"-m", "comment", "--comment", svcInfo.serviceNameString,
+				"-m", "comment", "--comment", svcInfo.comment("nodeport"),
 				"-m", protocol, "-p", protocol,
 				"--dport", strconv.Itoa(svcInfo.NodePort()),
 			)
@@ -593,7 +841,7 @@ func (t *iptables) writeNodePortsRules(svcInfo *serviceInfo, nodeAddresses sets.
 			// No endpoints.
 			t.filterRules.Write(
 				"-A", string(kubeExternalServicesChain),
-				"-m", "comment", "--comment", fmt.Sprintf(`"%s has no endpoints"`, svcInfo.serviceNameString),
+				"-m", "comment", "--comment", svcInfo.comment("no-endpoints"),
 				"-m", "addrtype", "--dst-type", "LOCAL",
 				"-m", protocol, "-p", protocol,
 				"--dport", strconv.Itoa(svcInfo.NodePort()),
@@ -607,7 +855,7 @@ func (t *iptables) writeNodePortsRules(svcInfo *serviceInfo, nodeAddresses sets.
 		// need to add a rule to accept the incoming connection
 		t.filterRules.Write(
 			"-A", string(kubeNodePortsChain),
-			"-m", "comment", "--comment", fmt.Sprintf(`"%s health check node port"`, svcInfo.serviceNameString),
+			"-m", "comment", "--comment", svcInfo.comment("health-check"),
 			"-m", "tcp", "-p", "tcp",
 			"--dport", strconv.Itoa(svcInfo.HealthCheckNodePort()),
 			"-j", "ACCEPT",
@@ -621,7 +869,6 @@ func (t *iptables) createEndpointsChain(svcInfo *serviceInfo, allEndpoints *endp
 	endpoints := make([]*string, 0)
 	localEndpointChains := make([]util.Chain, 0)
 	endpointChains := make([]util.Chain, 0)
-	protocol := strings.ToLower(svcInfo.Protocol().String())
 	endpointPortMap := make(map[string]int32)
 	var endpointChain util.Chain
 	if allEndpoints == nil {
@@ -654,7 +901,7 @@ func (t *iptables) createEndpointsChain(svcInfo *serviceInfo, allEndpoints *endp
 		endpointPortMap[ep] = targetPort
 		endpoints = append(endpoints, &ep)
 
-		endpointChain = servicePortEndpointChainName(svcInfo.serviceNameString, protocol, ep)
+		endpointChain = servicePortEndpointChainName(svcInfo.serviceNameString, svcInfo.Protocol(), ep)
 		endpointChains = append(endpointChains, endpointChain)
 		if epInfo.Local {
 			localEndpointChains = append(localEndpointChains, endpointChain)
@@ -669,11 +916,12 @@ func (t *iptables) createEndpointsChain(svcInfo *serviceInfo, allEndpoints *endp
 
 // writeEndpointRules writes rules to svc to jump to sep and rules to sep to dnat and loadbalance to actual ep ip
 func (t *iptables) writeEndpointRules(svcInfo *serviceInfo, svcName types.NamespacedName, endpointChains *[]util.Chain,
-	endpoints []*string, args *[]string, endpointPortMap map[string]int32) {
+	endpoints []*string, args *[]string, endpointPortMap map[string]int32,
+	existingNATChains map[util.Chain][]byte, activeNATChains map[util.Chain]bool) {
 	// First write session affinity rules, if applicable.
 	t.writeSessionAffinityRules(svcInfo, (*args)[:0], endpointChains, svcName)
 	// Now write loadbalancing & DNAT rules.
-	t.writeEndpointLBRules(svcInfo, svcName, endpointChains, endpoints, (*args)[:0])
+	t.writeEndpointLBRules(svcInfo, svcName, endpointChains, endpoints, (*args)[:0], existingNATChains, activeNATChains)
 	t.writeDNATRules(svcInfo, svcName, endpoints, endpointChains, (*args)[:0], endpointPortMap)
 }
 
@@ -685,7 +933,7 @@ func (t *iptables) writeSessionAffinityRules(svcInfo *serviceInfo, args []string
 			args = append(args[:0],
 				"-A", string(svcChain),
 			)
-			args = t.appendServiceCommentLocked(args, svcInfo.serviceNameString)
+			args = t.appendServiceCommentLocked(args, svcInfo, "affinity")
 			args = append(args,
 				"-m", "recent", "--name", string(endpointChain),
 				"--rcheck", "--seconds", strconv.Itoa(int(svcInfo.SessionAffinity().ClientIP.ClientIP.TimeoutSeconds)), "--reap",
@@ -697,32 +945,19 @@ func (t *iptables) writeSessionAffinityRules(svcInfo *serviceInfo, args []string
 }
 
 func (t *iptables) writeEndpointLBRules(svcInfo *serviceInfo, svcName types.NamespacedName,
-	readyEndpointChains *[]util.Chain, readyEndpoints []*string, args []string) {
-	// Now write loadbalancing & DNAT rules.
-	numReadyEndpoints := len(*readyEndpointChains)
-	svcChain := svcInfo.servicePortChainName
+	readyEndpointChains *[]util.Chain, readyEndpoints []*string, args []string,
+	existingNATChains map[util.Chain][]byte, activeNATChains map[util.Chain]bool) {
+	targets := make([]util.Chain, 0, len(*readyEndpointChains))
 	for i, endpointChain := range *readyEndpointChains {
-
-		epIP := readyEndpoints[i]
-		if *epIP == "" {
+		if *readyEndpoints[i] == "" {
 			// Error parsing this endpoint has been logged. Skip to next endpoint.
 			continue
 		}
-
-		// Balancing rules in the per-service chain.
-		args = append(args[:0], "-A", string(svcChain))
-		args = t.appendServiceCommentLocked(args, svcInfo.serviceNameString)
-		if i < (numReadyEndpoints - 1) {
-			// Each rule is a probabilistic match.
-			args = append(args,
-				"-m", "statistic",
-				"--mode", "random",
-				"--probability", t.probability(numReadyEndpoints-i))
-		}
-		// The final (or only if n == 1) rule is a guaranteed match.
-		args = append(args, "-j", string(endpointChain))
-		t.natRules.Write(args)
+		targets = append(targets, endpointChain)
 	}
+
+	// Now write loadbalancing & DNAT rules.
+	t.writeBalancingRules(svcInfo, svcInfo.servicePortChainName, targets, existingNATChains, activeNATChains, args)
 }
 
 func (t *iptables) writeDNATRules(svcInfo *serviceInfo, svcName types.NamespacedName,
@@ -736,7 +971,7 @@ func (t *iptables) writeDNATRules(svcInfo *serviceInfo, svcName types.Namespaced
 		}
 		// Rules in the per-endpoint chain.
 		args = append(args[:0], "-A", string(endpointChain))
-		args = t.appendServiceCommentLocked(args, svcInfo.serviceNameString)
+		args = t.appendServiceCommentLocked(args, svcInfo, "endpoint")
 		// Handle traffic that loops back to the originator with SNAT.
 		t.natRules.Write(args,
 			"-s", ToCIDR(net.ParseIP(*epIP)),
@@ -781,7 +1016,7 @@ func (t *iptables) writeLocalExtTrafficPolicyRules(svcInfo *serviceInfo, svcName
 		args = append(args[:0],
 			"-A", string(svcXlbChain),
 			"-m", "comment", "--comment",
-			`"Redirect pods trying to reach external loadbalancer VIP to clusterIP"`,
+			svcInfo.comment("local-lb-redirect"),
 		)
 		t.natRules.Write(t.localDetector.JumpIfLocal(args, string(svcChain)))
 	}
@@ -791,10 +1026,10 @@ func (t *iptables) writeLocalExtTrafficPolicyRules(svcInfo *serviceInfo, svcName
 	// otherwise traffic to LB IPs are dropped if there are no local endpoints.
 	args = append(args[:0], "-A", string(svcXlbChain))
 	t.natRules.Write(args,
-		"-m", "comment", "--comment", fmt.Sprintf(`"masquerade LOCAL traffic for %s LB IP"`, svcInfo.serviceNameString),
+		"-m", "comment", "--comment", svcInfo.comment("local-lb-masquerade"),
 		"-m", "addrtype", "--src-type", "LOCAL", "-j", string(KubeMarkMasqChain))
 	t.natRules.Write(args,
-		"-m", "comment", "--comment", fmt.Sprintf(`"route LOCAL traffic for %s LB IP to service chain"`, svcInfo.serviceNameString),
+		"-m", "comment", "--comment", svcInfo.comment("local-lb-route"),
 		"-m", "addrtype", "--src-type", "LOCAL", "-j", string(svcChain))
 
 	// Prefer local ready endpoint chains, but fall back to ready terminating if none exist
@@ -810,7 +1045,7 @@ func (t *iptables) writeLocalExtTrafficPolicyRules(svcInfo *serviceInfo, svcName
 		args = append(args[:0],
 			"-A", string(svcXlbChain),
 			"-m", "comment", "--comment",
-			fmt.Sprintf(`"%s has no local endpoints"`, svcInfo.serviceNameString),
+			svcInfo.comment("no-local-endpoints"),
 			"-j",
 			string(KubeMarkDropChain),
 		)
@@ -821,7 +1056,7 @@ func (t *iptables) writeLocalExtTrafficPolicyRules(svcInfo *serviceInfo, svcName
 			for _, endpointChain := range *localEndpointChains {
 				t.natRules.Write(
 					"-A", string(svcXlbChain),
-					"-m", "comment", "--comment", svcInfo.serviceNameString,
+					"-m", "comment", "--comment", svcInfo.comment("affinity"),
 					"-m", "recent", "--name", string(endpointChain),
 					"--rcheck", "--seconds", strconv.Itoa(int(svcInfo.SessionAffinity().ClientIP.ClientIP.TimeoutSeconds)), "--reap",
 					"-j", string(endpointChain))
@@ -834,7 +1069,7 @@ func (t *iptables) writeLocalExtTrafficPolicyRules(svcInfo *serviceInfo, svcName
 			args = append(args[:0],
 				"-A", string(svcXlbChain),
 				"-m", "comment", "--comment",
-				fmt.Sprintf(`"Balancing rule %d for %s"`, i, svcInfo.serviceNameString),
+				svcInfo.comment("local-balancing"),
 			)
 			if i < (numLocalEndpoints - 1) {
 				// Each rule is a probabilistic match.
@@ -854,7 +1089,7 @@ func (t *iptables) writeLocalExtTrafficPolicyRules(svcInfo *serviceInfo, svcName
 func (t *iptables) writeNodePortJumpRule(nodeAddresses sets.String, args []string) {
 	isIPv6 := t.iptInterface.IsIPv6()
 	for address := range nodeAddresses {
-		// TODO(thockin, m1093782566): If/when we have dual-stack support we will want to distinguish v4 from v6 zero-CIDRs.
+		// nodeAddresses only holds the zero CIDR of this table's family.
 		if IsZeroCIDR(address) {
 			args = append(args[:0],
 				"-A", string(kubeServicesChain),
@@ -884,12 +1119,14 @@ func (t *iptables) writeMiscFilterRules() {
 	// Drop the packets in INVALID state, which would potentially cause
 	// unexpected connection reset.
 	// https://github.com/kubernetes/kubernetes/issues/74839
-	t.filterRules.Write(
-		"-A", string(kubeForwardChain),
-		"-m", "conntrack",
-		"--ctstate", "INVALID",
-		"-j", "DROP",
-	)
+	if t.dropInvalid {
+		t.filterRules.Write(
+			"-A", string(kubeForwardChain),
+			"-m", "conntrack",
+			"--ctstate", "INVALID",
+			"-j", "DROP",
+		)
+	}
 
 	// If the masqueradeMark has been added then we want to forward that same
 	// traffic, this allows NodePort traffic to be forwarded even if the default
@@ -953,6 +1190,11 @@ func (t *iptables) openPortLocally(protocol string, localAddrSet utilnet.IPSet,
 }
 
 func (t *iptables) applyAllRules() error {
+	var owned map[string]bool
+	if t.reconcileMode == ReconcileOwnerOnly {
+		owned = t.keepForeignRules()
+	}
+
 	// Write the end-of-table markers.
 	t.filterRules.Write("COMMIT")
 	t.natRules.Write("COMMIT")
@@ -962,15 +1204,41 @@ func (t *iptables) applyAllRules() error {
 	t.iptablesData.Write(t.filterRules.Bytes())
 	t.iptablesData.Write(t.natChains.Bytes())
 	t.iptablesData.Write(t.natRules.Bytes())
+	if t.writesRawTable() {
+		t.rawRules.Write("COMMIT")
+		t.iptablesData.Write(t.rawChains.Bytes())
+		t.iptablesData.Write(t.rawRules.Bytes())
+	}
+	if t.dscp {
+		t.mangleRules.Write("COMMIT")
+		t.iptablesData.Write(t.mangleChains.Bytes())
+		t.iptablesData.Write(t.mangleRules.Bytes())
+	}
 
 	numberFilterIptablesRules := CountBytesLines(t.filterRules.Bytes())
 	IptablesRulesTotal.WithLabelValues(string(util.TableFilter)).Set(float64(numberFilterIptablesRules))
 	numberNatIptablesRules := CountBytesLines(t.natRules.Bytes())
 	IptablesRulesTotal.WithLabelValues(string(util.TableNAT)).Set(float64(numberNatIptablesRules))
+	if t.writesRawTable() {
+		IptablesRulesTotal.WithLabelValues(string(util.TableRaw)).Set(float64(CountBytesLines(t.rawRules.Bytes())))
+	}
+	if t.dscp {
+		IptablesRulesTotal.WithLabelValues(string(util.TableMangle)).Set(float64(CountBytesLines(t.mangleRules.Bytes())))
+	}
 
 	klog.InfoS("Restoring iptables", "rules", string(t.iptablesData.Bytes()))
 	err := t.iptInterface.RestoreAll(t.iptablesData.Bytes(), util.NoFlushTables, util.RestoreCounters)
-	return err
+	if err != nil {
+		return err
+	}
+
+	if owned != nil {
+		t.ownedRules = owned
+	}
+	if auditlog.Enabled() {
+		t.auditRules(t.iptablesData.Bytes())
+	}
+	return nil
 }
 
 func (t *iptables) resetAllChains() {
@@ -978,6 +1246,10 @@ func (t *iptables) resetAllChains() {
 	t.filterRules.Reset()
 	t.natChains.Reset()
 	t.natRules.Reset()
+	t.rawChains.Reset()
+	t.rawRules.Reset()
+	t.mangleChains.Reset()
+	t.mangleRules.Reset()
 }
 
 func (t *iptables) getExistingChains(tableType util.Table, buffer *bytes.Buffer) map[util.Chain][]byte {
@@ -992,18 +1264,32 @@ func (t *iptables) getExistingChains(tableType util.Table, buffer *bytes.Buffer)
 	return preexistingChains
 }
 
+// jumpChains returns the kube chains linked from the builtin chains.
+func (t *iptables) jumpChains() []iptablesJumpChain {
+	jumpChains := iptablesJumpChains
+	if t.markDrop {
+		jumpChains = append(append([]iptablesJumpChain{}, jumpChains...), markDropJumpChains...)
+	}
+	if t.nodeLocalDNS.NoTrack() {
+		jumpChains = append(append([]iptablesJumpChain{}, jumpChains...), nodeLocalDNSJumpChains...)
+	}
+	if t.noTrack {
+		jumpChains = append(append([]iptablesJumpChain{}, jumpChains...), noTrackJumpChains...)
+	}
+	if t.dscp {
+		jumpChains = append(append([]iptablesJumpChain{}, jumpChains...), dscpJumpChains...)
+	}
+	return jumpChains
+}
+
 func (t *iptables) ensureTopLevelChains() {
 	// Create and link the kube chains.  Note that "EnsureChain" will actually call iptables to make a chain if non-existent.
-	for _, jump := range iptablesJumpChains {
+	for _, jump := range t.jumpChains() {
 		if _, err := t.iptInterface.EnsureChain(jump.table, jump.dstChain); err != nil {
 			klog.ErrorS(err, "Failed to ensure chain exists", "table", jump.table, "chain", jump.dstChain)
 			return
 		}
-		args := append(jump.extraArgs,
-			"-m", "comment", "--comment", jump.comment,
-			"-j", string(jump.dstChain),
-		)
-		if _, err := t.iptInterface.EnsureRule(util.Prepend, jump.table, jump.srcChain, args...); err != nil {
+		if _, err := t.iptInterface.EnsureRule(util.Prepend, jump.table, jump.srcChain, jump.args()...); err != nil {
 			klog.ErrorS(err, "Failed to ensure chain jumps", "table", jump.table, "srcChain", jump.srcChain, "dstChain", jump.dstChain)
 			return
 		}
@@ -1039,14 +1325,14 @@ func (t *iptables) cleanUp() {
 const endpointChainsNumberThreshold = 1000
 
 // Assumes proxier.mu is held.
-func (t *iptables) appendServiceCommentLocked(args []string, svcName string) []string {
+func (t *iptables) appendServiceCommentLocked(args []string, svcInfo *serviceInfo, rule string) []string {
 	// Not printing these comments, can reduce size of iptables (in case of large
 	// number of endpoints) even by 40%+. So if total number of endpoint chains
 	// is large enough, we simply drop those comments.
 	if t.endpointChainsNumber > endpointChainsNumberThreshold {
 		return args
 	}
-	return append(args, "-m", "comment", "--comment", svcName)
+	return append(args, "-m", "comment", "--comment", svcInfo.comment(rule))
 }
 
 // This assumes proxier.mu is held
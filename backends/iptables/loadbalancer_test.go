@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/iptables/util"
+)
+
+func TestProxyLoadBalancerIPs(t *testing.T) {
+	prevImpl := IptablesImpl
+	defer func() { IptablesImpl = prevImpl }()
+
+	kernel := newFakeKernel(util.ProtocolIPv4)
+
+	impl := NewIptables()
+	impl.iptInterface = kernel
+	impl.serviceChanges = NewServiceChangeTracker(newServiceInfo, v1.IPv4Protocol, nil)
+	impl.endpointsChanges = NewEndpointChangeTracker("node", v1.IPv4Protocol, nil)
+	IptablesImpl = map[v1.IPFamily]*iptables{v1.IPv4Protocol: impl}
+
+	backend := New()
+	backend.SetService(&localv1.Service{
+		Namespace: "ns",
+		Name:      "lb",
+		Type:      "LoadBalancer",
+		IPs: &localv1.ServiceIPs{
+			ClusterIPs:           localv1.NewIPSet("10.96.0.10"),
+			ExternalIPs:          localv1.NewIPSet(),
+			LoadBalancerIPs:      localv1.NewIPSet("198.51.100.10", "198.51.100.11"),
+			ProxyLoadBalancerIPs: localv1.NewIPSet("198.51.100.11"),
+		},
+		Ports: []*localv1.PortMapping{
+			{Name: "http", Protocol: localv1.Protocol_TCP, Port: 80, NodePort: 30080, TargetPort: 8080},
+		},
+	})
+	backend.SetEndpoint("ns", "lb", "a", &localv1.Endpoint{IPs: localv1.NewIPSet("10.1.0.1")})
+
+	backend.Sync()
+	if err := backend.SyncErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := new(bytes.Buffer)
+	for _, table := range []util.Table{util.TableNAT, util.TableFilter} {
+		kernel.SaveInto(table, rules)
+	}
+
+	if !strings.Contains(rules.String(), "-d 198.51.100.10/32") {
+		t.Errorf("no rule for the VIP load-balancer IP:\n%s", rules)
+	}
+	if strings.Contains(rules.String(), "198.51.100.11") {
+		t.Errorf("rules written for the Proxy load-balancer IP:\n%s", rules)
+	}
+}
+
+func TestNoLoadBalancerNodePorts(t *testing.T) {
+	sct := NewServiceChangeTracker(newServiceInfo, v1.IPv4Protocol, nil)
+
+	svc := &localv1.Service{
+		Namespace:               "ns",
+		Name:                    "lb",
+		Type:                    "LoadBalancer",
+		NoLoadBalancerNodePorts: true,
+		IPs:                     &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.96.0.10"), ExternalIPs: localv1.NewIPSet()},
+		Ports: []*localv1.PortMapping{
+			{Name: "http", Protocol: localv1.Protocol_TCP, Port: 80, TargetPort: 8080},
+			{Name: "https", Protocol: localv1.Protocol_TCP, Port: 443, NodePort: 30443, TargetPort: 8443},
+		},
+	}
+	if nodePort := sct.newBaseServiceInfo(svc.Ports[0], svc).NodePort(); nodePort != 0 {
+		t.Errorf("expected no node port, got %d", nodePort)
+	}
+	if nodePort := sct.newBaseServiceInfo(svc.Ports[1], svc).NodePort(); nodePort != 30443 {
+		t.Errorf("expected the node port set explicitly, got %d", nodePort)
+	}
+
+	svc.Type = "ClusterIP"
+	if nodePort := sct.newBaseServiceInfo(svc.Ports[1], svc).NodePort(); nodePort != 0 {
+		t.Errorf("expected no node port for a ClusterIP service, got %d", nodePort)
+	}
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/kpng/backends/iptables/util"
+)
+
+// UseMemoryKernel makes the backend write its rules to an in-memory kernel per IP family.
+func (s *Backend) UseMemoryKernel() {
+	s.memory = true
+}
+
+// WriteKernelState writes the tables of the in-memory kernels like iptables-save, IPv4 first.
+func (s *Backend) WriteKernelState(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	for _, protocol := range []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol} {
+		impl, ok := IptablesImpl[protocol]
+		if !ok {
+			continue
+		}
+
+		fmt.Fprintf(buf, "# %s\n", protocol)
+		for _, table := range []util.Table{util.TableFilter, util.TableNAT, util.TableMangle, util.TableRaw} {
+			if err := impl.iptInterface.SaveInto(table, buf); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
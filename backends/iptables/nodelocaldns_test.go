@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kpng/backends/iptables/util"
+	"sigs.k8s.io/kpng/client/nodelocaldns"
+)
+
+func TestNodeLocalDNS(t *testing.T) {
+	cache, err := nodelocaldns.New(nodelocaldns.Config{IPs: []string{"169.254.20.10", "fd00::a"}, NoTrack: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kernel := newFakeKernel(util.ProtocolIPv4)
+
+	impl := NewIptables()
+	impl.iptInterface = kernel
+	impl.nodeLocalDNS = cache
+	impl.serviceChanges = NewServiceChangeTracker(newServiceInfo, "IPv4", nil)
+	impl.endpointsChanges = NewEndpointChangeTracker("node", "IPv4", nil)
+
+	wg.Add(1)
+	impl.sync()
+	if impl.syncErr != nil {
+		t.Fatal(impl.syncErr)
+	}
+
+	rules := func(table util.Table, chain util.Chain) (rules []string) {
+		for _, rule := range kernel.Tables[table][chain] {
+			rules = append(rules, strings.Join(rule, " "))
+		}
+		return
+	}
+
+	exempt := "-m comment --comment node-local DNS cache -d 169.254.20.10 -j RETURN"
+	for _, c := range []struct {
+		table util.Table
+		chain util.Chain
+	}{
+		{util.TableNAT, kubeServicesChain},
+		{util.TableNAT, kubePostroutingChain},
+		{util.TableFilter, kubeServicesChain},
+	} {
+		if got := rules(c.table, c.chain); len(got) == 0 || got[0] != exempt {
+			t.Errorf("%s %s: expected the exemption first, got %q", c.table, c.chain, got)
+		}
+	}
+
+	if got := rules(util.TableRaw, kubeNodeLocalDNSChain); len(got) != 4 || strings.Contains(strings.Join(got, "\n"), "fd00::a") {
+		t.Errorf("expected 4 IPv4 NOTRACK rules, got %q", got)
+	}
+	for _, chain := range []util.Chain{util.ChainPrerouting, util.ChainOutput} {
+		if got := rules(util.TableRaw, chain); len(got) != 1 || !strings.HasSuffix(got[0], "-j "+string(kubeNodeLocalDNSChain)) {
+			t.Errorf("raw %s: expected a jump to %s, got %q", chain, kubeNodeLocalDNSChain, got)
+		}
+	}
+}
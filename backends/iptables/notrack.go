@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"sort"
+	"strconv"
+
+	"sigs.k8s.io/kpng/api/localv1"
+)
+
+const (
+	// noTrackClientsList is the "-m recent" list of the clients sending to the NoTrack
+	// services through this node: their flows are DNATed here, so the replies of the
+	// local endpoints to them must stay tracked.
+	noTrackClientsList = "KUBE-NOTRACK-CLIENTS"
+	// noTrackClientsSeconds outlives the conntrack entries of the UDP streams.
+	noTrackClientsSeconds = "180"
+)
+
+// hasNoTrackServices returns true if a service port bypasses conntrack.
+func (t *iptables) hasNoTrackServices() bool {
+	for _, svcPortMap := range t.serviceMap {
+		for _, svc := range svcPortMap {
+			if svcInfo, ok := svc.(*serviceInfo); ok && svcInfo.NoTrack() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeNoTrackRules bypasses conntrack for the UDP traffic to and from the local
+// endpoints of a NoTrack service port. NOTRACK can't be applied to the flows DNATed
+// by this node (their replies would not be un-NATed), so the replies to the local
+// addresses and to the clients recently seen sending to the service are tracked.
+func (t *iptables) writeNoTrackRules(svcInfo *serviceInfo, allEndpoints *endpointsInfoByName, args []string) {
+	if allEndpoints == nil {
+		return
+	}
+
+	endpoints := make(map[string]string)
+	for _, epInfo := range *allEndpoints {
+		if !epInfo.Local {
+			continue
+		}
+
+		ips := epInfo.IPs.V4
+		if t.iptInterface.IsIPv6() {
+			ips = epInfo.IPs.V6
+		}
+		if len(ips) == 0 {
+			continue
+		}
+
+		targetPort := epInfo.PortMapping(&localv1.PortMapping{
+			TargetPortName: svcInfo.targetPortName,
+			TargetPort:     int32(svcInfo.targetPort),
+		})
+		endpoints[ips[0]] = strconv.Itoa(int(targetPort))
+	}
+
+	if len(endpoints) == 0 {
+		return
+	}
+
+	appendTo := []string{"-A", string(kubeNoTrackChain), "-m", "comment", "--comment", svcInfo.comment("notrack")}
+	record := []string{"-m", "recent", "--name", noTrackClientsList, "--set", "-j", "RETURN"}
+
+	// record the clients of the service
+	port := strconv.Itoa(svcInfo.Port())
+	destinations := append(append([]string{}, svcInfo.ExternalIPStrings()...), svcInfo.LoadBalancerIPStrings()...)
+	if svcInfo.ClusterIP() != nil {
+		destinations = append([]string{svcInfo.ClusterIP().String()}, destinations...)
+	}
+	for _, ip := range destinations {
+		args = append(args[:0], "-d", ip, "-p", "udp", "-m", "udp", "--dport", port)
+		t.rawRules.Write(appendTo, args, record)
+	}
+	if svcInfo.NodePort() != 0 {
+		args = append(args[:0], "-m", "addrtype", "--dst-type", "LOCAL",
+			"-p", "udp", "-m", "udp", "--dport", strconv.Itoa(svcInfo.NodePort()))
+		t.rawRules.Write(appendTo, args, record)
+	}
+
+	ips := make([]string, 0, len(endpoints))
+	for ep := range endpoints {
+		ips = append(ips, ep)
+	}
+	sort.Strings(ips)
+
+	for _, ep := range ips {
+		targetPort := endpoints[ep]
+		t.rawRules.Write(appendTo, "-d", ep, "-p", "udp", "-m", "udp", "--dport", targetPort, "-j", "NOTRACK")
+
+		args = append(args[:0], "-s", ep, "-p", "udp", "-m", "udp", "--sport", targetPort)
+		t.rawRules.Write(appendTo, args, "-m", "addrtype", "--dst-type", "LOCAL", "-j", "RETURN")
+		t.rawRules.Write(appendTo, args, "-m", "recent", "--name", noTrackClientsList, "--rdest",
+			"--rcheck", "--seconds", noTrackClientsSeconds, "-j", "RETURN")
+		t.rawRules.Write(appendTo, args, "-j", "NOTRACK")
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/iptables/util"
+)
+
+func TestNoTrack(t *testing.T) {
+	prevImpl := IptablesImpl
+	defer func() { IptablesImpl = prevImpl }()
+
+	kernel := newFakeKernel(util.ProtocolIPv4)
+
+	impl := NewIptables()
+	impl.iptInterface = kernel
+	impl.serviceChanges = NewServiceChangeTracker(newServiceInfo, v1.IPv4Protocol, nil)
+	impl.endpointsChanges = NewEndpointChangeTracker("node", v1.IPv4Protocol, nil)
+	IptablesImpl = map[v1.IPFamily]*iptables{v1.IPv4Protocol: impl}
+
+	rules := func(chain util.Chain) (rules []string) {
+		for _, rule := range kernel.Tables[util.TableRaw][chain] {
+			rules = append(rules, strings.Join(rule, " "))
+		}
+		return
+	}
+
+	backend := New()
+
+	// no raw rules without NoTrack services
+	backend.SetService(&localv1.Service{
+		Namespace: "ns",
+		Name:      "web",
+		Type:      "ClusterIP",
+		IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.96.0.20"), ExternalIPs: localv1.NewIPSet()},
+		Ports:     []*localv1.PortMapping{{Name: "http", Protocol: localv1.Protocol_TCP, Port: 80, TargetPort: 8080}},
+	})
+	backend.Sync()
+	if err := backend.SyncErr(); err != nil {
+		t.Fatal(err)
+	}
+	if got := rules(util.ChainPrerouting); len(got) != 0 {
+		t.Errorf("expected no raw jump, got %q", got)
+	}
+
+	dns := &localv1.Service{
+		Namespace: "ns",
+		Name:      "dns",
+		Type:      "NodePort",
+		IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.96.0.10"), ExternalIPs: localv1.NewIPSet()},
+		Ports: []*localv1.PortMapping{
+			{Name: "dns", Protocol: localv1.Protocol_UDP, Port: 53, NodePort: 30053, TargetPort: 5353},
+			{Name: "dns-tcp", Protocol: localv1.Protocol_TCP, Port: 53, TargetPort: 5353},
+		},
+		NoTrack: true,
+	}
+	backend.SetService(dns)
+	backend.SetEndpoint("ns", "dns", "local", &localv1.Endpoint{IPs: localv1.NewIPSet("10.1.0.1"), Local: true})
+	backend.SetEndpoint("ns", "dns", "remote", &localv1.Endpoint{IPs: localv1.NewIPSet("10.2.0.1")})
+	backend.Sync()
+	if err := backend.SyncErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := strings.Join(rules(kubeNoTrackChain), "\n")
+	for _, expected := range []string{
+		"-d 10.96.0.10 -p udp -m udp --dport 53 -m recent --name KUBE-NOTRACK-CLIENTS --set -j RETURN",
+		"-m addrtype --dst-type LOCAL -p udp -m udp --dport 30053 -m recent --name KUBE-NOTRACK-CLIENTS --set -j RETURN",
+		"-d 10.1.0.1 -p udp -m udp --dport 5353 -j NOTRACK",
+		"-s 10.1.0.1 -p udp -m udp --sport 5353 -m addrtype --dst-type LOCAL -j RETURN",
+		"-s 10.1.0.1 -p udp -m udp --sport 5353 -m recent --name KUBE-NOTRACK-CLIENTS --rdest --rcheck --seconds 180 -j RETURN",
+		"-s 10.1.0.1 -p udp -m udp --sport 5353 -j NOTRACK",
+	} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("missing rule %q in:\n%s", expected, got)
+		}
+	}
+	if strings.Contains(got, "10.2.0.1") || strings.Contains(got, "tcp") {
+		t.Errorf("unexpected rules for the remote endpoint or the TCP port:\n%s", got)
+	}
+	if n := len(rules(kubeNoTrackChain)); n != 6 {
+		t.Errorf("expected 6 rules, got %d:\n%s", n, got)
+	}
+
+	for _, chain := range []util.Chain{util.ChainPrerouting, util.ChainOutput} {
+		if got := rules(chain); len(got) != 1 || !strings.HasSuffix(got[0], "-j "+string(kubeNoTrackChain)) {
+			t.Errorf("raw %s: expected a jump to %s, got %q", chain, kubeNoTrackChain, got)
+		}
+	}
+
+	// the chain is flushed when the service doesn't bypass conntrack anymore
+	dns.NoTrack = false
+	backend.SetService(dns)
+	backend.Sync()
+	if err := backend.SyncErr(); err != nil {
+		t.Fatal(err)
+	}
+	if got := rules(kubeNoTrackChain); len(got) != 0 {
+		t.Errorf("expected the chain to be flushed, got %q", got)
+	}
+}
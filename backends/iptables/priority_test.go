@@ -0,0 +1,92 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/iptables/util"
+)
+
+func TestServicesPriority(t *testing.T) {
+	prevImpl := IptablesImpl
+	defer func() { IptablesImpl = prevImpl }()
+
+	kernel := newFakeKernel(util.ProtocolIPv4)
+
+	impl := NewIptables()
+	impl.iptInterface = kernel
+	impl.serviceChanges = NewServiceChangeTracker(newServiceInfo, v1.IPv4Protocol, nil)
+	impl.endpointsChanges = NewEndpointChangeTracker("node", v1.IPv4Protocol, nil)
+	IptablesImpl = map[v1.IPFamily]*iptables{v1.IPv4Protocol: impl}
+
+	backend := New()
+	for i, svc := range []struct {
+		namespace, name string
+		priority        localv1.Priority
+	}{
+		{"default", "a", localv1.Priority_LowPriority},
+		{"default", "b", localv1.Priority_NormalPriority},
+		{"kube-system", "kube-dns", localv1.Priority_CriticalPriority},
+	} {
+		ip := "10.96.0." + strconv.Itoa(i+1)
+		backend.SetService(&localv1.Service{
+			Namespace: svc.namespace,
+			Name:      svc.name,
+			Type:      "ClusterIP",
+			Priority:  svc.priority,
+			IPs: &localv1.ServiceIPs{
+				ClusterIPs:  localv1.NewIPSet(ip),
+				ExternalIPs: localv1.NewIPSet(),
+			},
+			Ports: []*localv1.PortMapping{
+				{Name: "p", Protocol: localv1.Protocol_TCP, Port: 80, TargetPort: 8080},
+			},
+		})
+		backend.SetEndpoint(svc.namespace, svc.name, "a", &localv1.Endpoint{IPs: localv1.NewIPSet("10.1.0." + strconv.Itoa(i+1))})
+	}
+
+	backend.Sync()
+	if err := backend.SyncErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := new(bytes.Buffer)
+	kernel.SaveInto(util.TableNAT, rules)
+
+	// the services are matched in KUBE-SERVICES by priority class
+	order := []string{}
+	for _, line := range strings.Split(rules.String(), "\n") {
+		if strings.HasPrefix(line, "-A KUBE-SERVICES") && strings.Contains(line, "rule=cluster-ip") {
+			for _, ip := range []string{"10.96.0.3", "10.96.0.2", "10.96.0.1"} {
+				if strings.Contains(line, "-d "+ip+"/32") {
+					order = append(order, ip)
+				}
+			}
+		}
+	}
+
+	if expected := "10.96.0.3,10.96.0.2,10.96.0.1"; strings.Join(order, ",") != expected {
+		t.Errorf("expected the cluster IPs in the order %s, got %v:\n%s", expected, order, rules)
+	}
+}
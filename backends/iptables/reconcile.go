@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"bytes"
+	"fmt"
+
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/kpng/backends/iptables/util"
+	"sigs.k8s.io/kpng/client/rulecomment"
+)
+
+// ReconcileMode tells which rules of the kube chains a sync replaces.
+type ReconcileMode string
+
+const (
+	// ReconcileStrict replaces all the rules of the kube chains with the ones of the sync.
+	ReconcileStrict ReconcileMode = "strict"
+	// ReconcileOwnerOnly only replaces the rules of kpng, keeping the ones others added to the
+	// kube chains (ie: emergency rules of an operator).
+	ReconcileOwnerOnly ReconcileMode = "owner-only"
+)
+
+var reconcileMode = string(ReconcileStrict)
+
+func checkReconcileMode(mode string) error {
+	switch ReconcileMode(mode) {
+	case ReconcileStrict, ReconcileOwnerOnly:
+		return nil
+	}
+	return fmt.Errorf("invalid --reconcile-mode %q, must be %q or %q", mode, ReconcileStrict, ReconcileOwnerOnly)
+}
+
+// keepForeignRules writes the rules others added to the written kube chains, so the restore
+// doesn't remove them. A rule is kpng's if it carries kpng's comment marker, or is written by this
+// sync or was by the previous one (the comments are dropped on large clusters). The foreign rules
+// installed before the first rule of kpng in their chain are kept first, the others last.
+//
+// It returns the keys of the rules written by kpng, to recognize them at the next sync.
+func (t *iptables) keepForeignRules() map[string]bool {
+	type tableBuffers struct {
+		table         util.Table
+		chains, rules *util.LineBuffer
+	}
+
+	tables := []tableBuffers{
+		{util.TableFilter, &t.filterChains, &t.filterRules},
+		{util.TableNAT, &t.natChains, &t.natRules},
+	}
+	if t.writesRawTable() {
+		tables = append(tables, tableBuffers{util.TableRaw, &t.rawChains, &t.rawRules})
+	}
+	if t.dscp {
+		tables = append(tables, tableBuffers{util.TableMangle, &t.mangleChains, &t.mangleRules})
+	}
+
+	owned := map[string]bool{}
+	buffer := &bytes.Buffer{}
+
+	for _, tb := range tables {
+		written := parseRules(append(append([]byte{}, tb.chains.Bytes()...), tb.rules.Bytes()...))
+		for _, args := range written.rules[tb.table] {
+			owned[string(tb.table)+" "+args[1]+" "+ruleKey(args[2:])] = true
+		}
+
+		buffer.Reset()
+		if err := t.iptInterface.SaveInto(tb.table, buffer); err != nil {
+			klog.ErrorS(err, "Failed to list the rules not written by kpng, removing them", "table", tb.table)
+			continue
+		}
+		installed := parseRules(buffer.Bytes())
+
+		isOwned := func(args []string) bool {
+			key := string(tb.table) + " " + args[1] + " " + ruleKey(args[2:])
+			return owned[key] || t.ownedRules[key] || hasOwnerComment(args)
+		}
+
+		head, tail := foreignRules(written.chains[tb.table], written.deleted[tb.table], installed.rules[tb.table], isOwned)
+		for _, args := range head {
+			tb.chains.Write(ruleLine(args))
+		}
+		for _, args := range tail {
+			tb.rules.Write(ruleLine(args))
+		}
+
+		if n := len(head) + len(tail); n != 0 {
+			klog.V(2).InfoS("Keeping the rules not written by kpng", "table", tb.table, "count", n)
+		}
+	}
+
+	return owned
+}
+
+// foreignRules returns the installed rules ("-A <chain> <args>") of the written chains not owned
+// by kpng, before and after its first rule in their chain. The rules jumping to the deleted
+// chains are dropped, the restore would fail.
+func foreignRules(chains, deleted map[util.Chain]bool, installed [][]string, isOwned func(args []string) bool) (head, tail [][]string) {
+	ownedSeen := map[string]bool{} // by chain
+
+	for _, args := range installed {
+		chain := args[1]
+		if !chains[util.Chain(chain)] {
+			continue
+		}
+
+		if isOwned(args) {
+			ownedSeen[chain] = true
+			continue
+		}
+
+		if target := ruleTarget(args); deleted[util.Chain(target)] {
+			klog.V(2).InfoS("Dropping a rule not written by kpng jumping to a deleted chain", "rule", ruleLine(args))
+			continue
+		}
+
+		if ownedSeen[chain] {
+			tail = append(tail, args)
+		} else {
+			head = append(head, args)
+		}
+	}
+	return
+}
+
+// hasOwnerComment returns true if the rule carries kpng's comment marker.
+func hasOwnerComment(args []string) bool {
+	for i, arg := range args[:len(args)-1] {
+		if arg != "--comment" {
+			continue
+		}
+		if _, ok := rulecomment.Parse(args[i+1]); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleTarget returns the chain or target a rule jumps or goes to.
+func ruleTarget(args []string) string {
+	for i, arg := range args[:len(args)-1] {
+		if arg == "-j" || arg == "-g" {
+			return args[i+1]
+		}
+	}
+	return ""
+}
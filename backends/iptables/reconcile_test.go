@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/iptables/util"
+)
+
+func TestReconcileOwnerOnly(t *testing.T) {
+	prevImpl := IptablesImpl
+	defer func() { IptablesImpl = prevImpl }()
+
+	kernel := newFakeKernel(util.ProtocolIPv4)
+
+	impl := NewIptables()
+	impl.iptInterface = kernel
+	impl.serviceChanges = NewServiceChangeTracker(newServiceInfo, v1.IPv4Protocol, nil)
+	impl.endpointsChanges = NewEndpointChangeTracker("node", v1.IPv4Protocol, nil)
+	impl.reconcileMode = ReconcileOwnerOnly
+	IptablesImpl = map[v1.IPFamily]*iptables{v1.IPv4Protocol: impl}
+
+	backend := New()
+	sync := func() {
+		t.Helper()
+		backend.Sync()
+		if err := backend.SyncErr(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backend.SetService(&localv1.Service{
+		Namespace: "ns",
+		Name:      "web",
+		IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.0.0.1"), ExternalIPs: &localv1.IPSet{}},
+		Ports:     []*localv1.PortMapping{{Name: "http", Protocol: localv1.Protocol_TCP, Port: 80, TargetPort: 8080}},
+	})
+	backend.SetEndpoint("ns", "web", "a", &localv1.Endpoint{IPs: localv1.NewIPSet("10.244.0.2")})
+	sync()
+
+	// an operator adds emergency rules to the kube chains
+	svcChain := servicePortChainName("ns/web:http", localv1.Protocol_TCP)
+	first := []string{"-s", "192.0.2.1", "-j", "DROP"}
+	last := []string{"-d", "192.0.2.2", "-j", "REJECT"}
+	jump := []string{"-d", "192.0.2.3", "-j", string(svcChain)}
+
+	services := kernel.Tables[util.TableNAT][kubeServicesChain]
+	kernel.Tables[util.TableNAT][kubeServicesChain] = append(append([][]string{first}, services...), last, jump)
+
+	rules := func() (rules []string) {
+		for _, args := range kernel.Tables[util.TableNAT][kubeServicesChain] {
+			rules = append(rules, strings.Join(args, " "))
+		}
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		sync()
+
+		rules := rules()
+		if n := len(rules); n != len(services)+3 {
+			t.Fatalf("sync %d: expected %d rules, got\n%s", i, len(services)+3, strings.Join(rules, "\n"))
+		}
+		if rules[0] != strings.Join(first, " ") || rules[len(rules)-2] != strings.Join(last, " ") || rules[len(rules)-1] != strings.Join(jump, " ") {
+			t.Errorf("sync %d: expected the rules at their places, got\n%s", i, strings.Join(rules, "\n"))
+		}
+	}
+
+	// the rules jumping to the deleted chains are dropped
+	backend.DeleteEndpoint("ns", "web", "a")
+	backend.DeleteService("ns", "web")
+	sync()
+
+	if rules := rules(); len(rules) == 0 || rules[0] != strings.Join(first, " ") || strings.Contains(strings.Join(rules, "\n"), string(svcChain)) {
+		t.Errorf("expected only the jump to %s to be dropped, got\n%s", svcChain, strings.Join(rules, "\n"))
+	}
+
+	// strict mode removes them
+	impl.reconcileMode = ReconcileStrict
+	sync()
+
+	if rules := strings.Join(rules(), "\n"); strings.Contains(rules, "192.0.2.") {
+		t.Errorf("expected the rules not written by kpng to be removed, got\n%s", rules)
+	}
+}
@@ -19,6 +19,7 @@ package iptables
 import (
 	"fmt"
 	"net"
+	"sort"
 	"strings"
 
 	"sigs.k8s.io/kpng/backends/iptables/util"
@@ -32,6 +33,8 @@ import (
 	//"k8s.io/kubernetes/pkg/proxy/metrics"
 
 	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/changetracker"
+	"sigs.k8s.io/kpng/client/rulecomment"
 )
 
 // BaseServiceInfo contains base information that defines a service.
@@ -56,6 +59,9 @@ type BaseServiceInfo struct {
 	targetPort               int
 	targetPortName           string
 	portName                 string
+	noTrack                  bool
+	dscp                     uint8
+	priority                 localv1.Priority
 }
 
 // SessionAffinity contains data about assinged session affinity
@@ -153,6 +159,21 @@ func (info *BaseServiceInfo) HintsAnnotation() string {
 	return info.hintsAnnotation
 }
 
+// DSCP returns the DSCP value set on the packets sent to the service, 0 if none.
+func (info *BaseServiceInfo) DSCP() uint8 {
+	return info.dscp
+}
+
+// Priority returns the programming priority class of the service.
+func (info *BaseServiceInfo) Priority() localv1.Priority {
+	return info.priority
+}
+
+// NoTrack returns true if the UDP traffic of the local endpoints bypasses conntrack.
+func (info *BaseServiceInfo) NoTrack() bool {
+	return info.noTrack && info.protocol == localv1.Protocol_UDP
+}
+
 func (sct *ServiceChangeTracker) newBaseServiceInfo(port *localv1.PortMapping, service *localv1.Service) *BaseServiceInfo {
 	nodeLocalExternal := false
 	if RequestsOnlyLocalTraffic(service) {
@@ -165,6 +186,11 @@ func (sct *ServiceChangeTracker) newBaseServiceInfo(port *localv1.PortMapping, s
 	// }
 
 	clusterIP := GetClusterIPByFamily(sct.ipFamily, service)
+	nodePort := 0
+	if service.HasNodePort(port) {
+		nodePort = int(port.NodePort)
+	}
+
 	info := &BaseServiceInfo{
 		clusterIP:         net.ParseIP(clusterIP),
 		port:              int(port.Port),
@@ -172,14 +198,16 @@ func (sct *ServiceChangeTracker) newBaseServiceInfo(port *localv1.PortMapping, s
 		targetPort:        int(port.TargetPort),
 		targetPortName:    port.TargetPortName,
 		protocol:          port.Protocol,
-		nodePort:          int(port.NodePort),
+		nodePort:          nodePort,
 		nodeLocalExternal: nodeLocalExternal,
 		nodeLocalInternal: nodeLocalInternal,
 		// internalTrafficPolicy: service.Spec.InternalTrafficPolicy, //TODO : CHECK InternalTrafficPolicy
-		hintsAnnotation:          service.Annotations[v1.AnnotationTopologyAwareHints],
-		loadBalancerSourceRanges: getLoadbalancerSourceRanges(service.IPFilters),
-		loadBalancerIPs:          getLoadBalancerIPs(service.IPs.LoadBalancerIPs, sct.ipFamily),
-		sessionAffinity:          getSessionAffinity(service.SessionAffinity),
+		hintsAnnotation: service.Annotations[v1.AnnotationTopologyAwareHints],
+		loadBalancerIPs: getLoadBalancerIPs(service.IPs.LoadBalancerVIPs(), sct.ipFamily),
+		sessionAffinity: getSessionAffinity(service.SessionAffinity),
+		noTrack:         service.NoTrack,
+		dscp:            uint8(service.DSCP & 0x3f),
+		priority:        service.Priority,
 	}
 
 	// filter external ips, source ranges and ingress ips
@@ -195,6 +223,14 @@ func (sct *ServiceChangeTracker) newBaseServiceInfo(port *localv1.PortMapping, s
 		klog.V(4).Infof("service change tracker(%v) ignored the following external IPs(%s) for service %v/%v as they don't match IPFamily", sct.ipFamily, strings.Join(ips, ","), service.Namespace, service.Name)
 	}
 
+	cidrFamilyMap := MapCIDRsByIPFamily(getLoadbalancerSourceRanges(service.IPFilters))
+	info.loadBalancerSourceRanges = cidrFamilyMap[sct.ipFamily]
+
+	// Log the source ranges not matching the ipFamily
+	if cidrs, ok := cidrFamilyMap[OtherIPFamily(sct.ipFamily)]; ok && len(cidrs) > 0 {
+		klog.V(4).Infof("service change tracker(%v) ignored the following load balancer source ranges(%s) for service %v/%v as they don't match IPFamily", sct.ipFamily, strings.Join(cidrs, ","), service.Namespace, service.Name)
+	}
+
 	//TODO : CHECK service.Spec.HealthCheckNodePort
 	// if apiservice.NeedsHealthCheck(service) {
 	// 	p := service.Spec.HealthCheckNodePort
@@ -248,19 +284,25 @@ func newServiceInfo(port *localv1.PortMapping, service *localv1.Service, baseInf
 	// Store the following for performance reasons.
 	svcName := types.NamespacedName{Namespace: service.Namespace, Name: service.Name}
 	svcPortName := ServicePortName{
-		svcName,
-		port.Name,
-		info.protocol,
+		NamespacedName: svcName,
+		Port:           port.Name,
+		Protocol:       info.protocol,
 	}
-	protocol := strings.ToLower(string(info.Protocol()))
+	protocol := info.Protocol()
 	info.serviceNameString = svcPortName.String()
 	info.servicePortChainName = servicePortChainName(info.serviceNameString, protocol)
 	info.serviceFirewallChainName = serviceFirewallChainName(info.serviceNameString, protocol)
 	info.serviceLBChainName = serviceLBChainName(info.serviceNameString, protocol)
+	info.ruleComment = rulecomment.For("iptables", service, port.Name)
 
 	return info
 }
 
+// comment returns the quoted comment of a rule of the service port.
+func (info *serviceInfo) comment(rule string) string {
+	return `"` + info.ruleComment.WithRule(rule).String() + `"`
+}
+
 type makeServicePortFunc func(*localv1.PortMapping, *localv1.Service, *BaseServiceInfo) ServicePort
 
 // This handler is invoked by the apply function on every change. This function should not modify the
@@ -275,8 +317,8 @@ type makeServicePortFunc func(*localv1.PortMapping, *localv1.Service, *BaseServi
 // ServiceChangeTracker carries state about uncommitted changes to an arbitrary number of
 // Services, keyed by their namespace and name.
 type ServiceChangeTracker struct {
-	// items maps a service to its serviceChange.
-	items map[types.NamespacedName]*serviceChange
+	// changes maps a service to its serviceChange (nil if deleted).
+	changes *changetracker.Tracker[types.NamespacedName, serviceChange]
 	// makeServiceInfo allows proxier to inject customized information when processing service.
 	makeServiceInfo makeServicePortFunc
 	// processServiceMapChange processServiceMapChangeFunc
@@ -287,8 +329,12 @@ type ServiceChangeTracker struct {
 
 // NewServiceChangeTracker initializes a ServiceChangeTracker
 func NewServiceChangeTracker(makeServiceInfo makeServicePortFunc, ipFamily v1.IPFamily, recorder events.EventRecorder) *ServiceChangeTracker {
+	changes := changetracker.New[types.NamespacedName, serviceChange](nil)
+	changes.Pending = ServiceChangesPending
+	changes.Total = ServiceChangesTotal
+
 	return &ServiceChangeTracker{
-		items:           make(map[types.NamespacedName]*serviceChange),
+		changes:         changes,
 		makeServiceInfo: makeServiceInfo,
 		recorder:        recorder,
 		ipFamily:        ipFamily,
@@ -311,27 +357,18 @@ func (sct *ServiceChangeTracker) Update(current *localv1.Service) bool {
 	if svc == nil {
 		return false
 	}
-	//metrics.ServiceChangesTotal.Inc()
 	namespacedName := types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}
-	var change *serviceChange
-	var ok bool
-	if change, ok = sct.items[namespacedName]; !ok {
-		change = &serviceChange{}
-		sct.items[namespacedName] = change
-	}
-	*change = sct.serviceToServiceMap(current)
-	klog.V(2).Infof("Service %s updated: %d ports", namespacedName, len(*change))
-	//metrics.ServiceChangesPending.Set(float64(len(sct.items)))
-	return len(sct.items) > 0
+	change := sct.serviceToServiceMap(current)
+	sct.changes.Record(namespacedName, nil, change)
+	klog.V(2).Infof("Service %s updated: %d ports", namespacedName, len(change))
+	return sct.changes.Len() > 0
 }
 
 func (sct *ServiceChangeTracker) Delete(namespace, name string) bool {
-	//metrics.ServiceChangesTotal.Inc()
 	namespacedName := types.NamespacedName{Namespace: namespace, Name: name}
-	sct.items[namespacedName] = nil
+	sct.changes.Record(namespacedName, nil, nil)
 	klog.V(2).Infof("Service %s updated for delete", namespacedName)
-	//metrics.ServiceChangesPending.Set(float64(len(sct.items)))
-	return len(sct.items) > 0
+	return sct.changes.Len() > 0
 }
 
 // UpdateServiceMapResult is the updated results after applying service changes.
@@ -370,16 +407,38 @@ func (svcSnap *ServicesSnapshot) Update(changes *ServiceChangeTracker) (result U
 	return result
 }
 
+// byPriority returns the names of the services, the ones of the higher priority classes first,
+// then by namespace and name.
+func (svcSnap ServicesSnapshot) byPriority() []types.NamespacedName {
+	names := make([]types.NamespacedName, 0, len(svcSnap))
+	ranks := make(map[types.NamespacedName]int, len(svcSnap))
+	for svcName, svcPortMap := range svcSnap {
+		names = append(names, svcName)
+		for _, svc := range svcPortMap {
+			if svcInfo, ok := svc.(*serviceInfo); ok {
+				ranks[svcName] = svcInfo.Priority().Rank()
+			}
+			break
+		}
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if ri, rj := ranks[names[i]], ranks[names[j]]; ri != rj {
+			return ri > rj
+		}
+		return names[i].String() < names[j].String()
+	})
+	return names
+}
+
 func (svcSnap *ServicesSnapshot) apply(changes *ServiceChangeTracker, UDPStaleClusterIP sets.String) {
-	for svcName, change := range changes.items {
-		svcSnap.merge(svcName, change, UDPStaleClusterIP)
+	// taking the changes clears them
+	for svcName, change := range changes.changes.Take() {
+		svcSnap.merge(svcName, change.Current, UDPStaleClusterIP)
 	}
-	// clear changes after applying them to ServiceMap.
-	changes.items = make(map[types.NamespacedName]*serviceChange)
-	//metrics.ServiceChangesPending.Set(0)
 }
 
-func (svcSnap *ServicesSnapshot) merge(svcName types.NamespacedName, other *serviceChange, UDPStaleClusterIP sets.String) {
+func (svcSnap *ServicesSnapshot) merge(svcName types.NamespacedName, other serviceChange, UDPStaleClusterIP sets.String) {
 	// existingPorts is going to store all identifiers of all services in `other` ServiceMap.
 	if other == nil {
 		for _, svcInfo := range (*svcSnap)[svcName] {
@@ -391,7 +450,7 @@ func (svcSnap *ServicesSnapshot) merge(svcName types.NamespacedName, other *serv
 		delete(*svcSnap, svcName)
 		return
 	}
-	(*svcSnap)[svcName] = *other
+	(*svcSnap)[svcName] = other
 }
 
 // internal struct for string service information
@@ -402,6 +461,7 @@ type serviceInfo struct {
 	servicePortChainName     util.Chain
 	serviceFirewallChainName util.Chain
 	serviceLBChainName       util.Chain
+	ruleComment              rulecomment.Comment
 }
 
 // serviceToServiceMap translates a single Service object to a ServiceMap.
@@ -429,7 +489,3 @@ func (sct *ServiceChangeTracker) serviceToServiceMap(service *localv1.Service) s
 	}
 	return serviceMap
 }
-
-func IsServiceIPSet(service *localv1.Service) bool {
-	return len(service.IPs.ClusterIPs.V4) > 0 || len(service.IPs.ClusterIPs.V6) > 0
-}
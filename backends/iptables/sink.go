@@ -17,47 +17,125 @@ limitations under the License.
 package iptables
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/spf13/pflag"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/utils/exec"
+	"k8s.io/klog/v2"
 
 	localv1 "sigs.k8s.io/kpng/api/localv1"
 	"sigs.k8s.io/kpng/backends/iptables/util"
+	"sigs.k8s.io/kpng/client/backendcmd"
+	"sigs.k8s.io/kpng/client/faultinject"
 	"sigs.k8s.io/kpng/client/localsink"
 	"sigs.k8s.io/kpng/client/localsink/decoder"
 	"sigs.k8s.io/kpng/client/localsink/filterreset"
 	"sigs.k8s.io/kpng/client/localsink/filterreset/pipe"
 	"sigs.k8s.io/kpng/client/plugins/conntrack"
+	"sigs.k8s.io/kpng/client/privhelper"
 )
 
 type Backend struct {
 	localsink.Config
+
+	// memory is set by UseMemoryKernel
+	memory bool
+	// onSyncErr is set by OnSyncErr
+	onSyncErr func(error)
 }
 
 var wg = sync.WaitGroup{}
 var IptablesImpl map[v1.IPFamily]*iptables
 var hostname string
 var _ decoder.Interface = &Backend{}
+var _ decoder.FailingSyncer = &Backend{}
+var _ decoder.BackgroundSyncer = &Backend{}
+var _ backendcmd.Checker = &Backend{}
+var _ backendcmd.MemoryKernel = &Backend{}
 
 func New() *Backend {
 	return &Backend{}
 }
 
 func (s *Backend) Sink() localsink.Sink {
+	if s.memory {
+		// no conntrack entries to clear
+		return filterreset.New(decoder.New(s))
+	}
 	return filterreset.New(pipe.New(decoder.New(s), decoder.New(conntrack.NewSink())))
 }
 
 func (s *Backend) BindFlags(flags *pflag.FlagSet) {
+	flags.DurationVar(&staleChainsGracePeriod, "stale-chains-grace-period", 30*time.Second,
+		"how long the chains of deleted services and endpoints are kept before being deleted, so existing connections can finish (0 deletes them on the next sync)")
+	flags.IntVar(&balancingChainSize, "balancing-chain-size", 256,
+		"split the balancing rules of the services having more endpoints in nested chains of at most this size, so fewer rules are evaluated per packet (0 to disable)")
+	flags.BoolVar(&dropInvalid, "drop-invalid", true,
+		"drop the forwarded packets in INVALID conntrack state, which can be reset by the node on asymmetric routes")
+	flags.BoolVar(&markDrop, "mark-drop", false,
+		"write the KUBE-MARK-DROP and KUBE-FIREWALL rules dropping the marked and the martian packets (usually written by the kubelet)")
+	flags.IntVar(&dropBit, "iptables-drop-bit", 15, "the bit of the fwmark space to mark packets for dropping (with --mark-drop, must match the kubelet's)")
+	flags.StringVar(&reconcileMode, "reconcile-mode", string(ReconcileStrict),
+		"which rules of the kube chains a sync replaces: strict replaces all of them, owner-only only the ones of kpng, keeping the rules added by others")
+	util.BindFlags(flags)
+}
+
+// Probe checks that the rules can be written in one of the iptables modes.
+func (s *Backend) Probe() error {
+	return backendcmd.Err(s.Checks())
+}
+
+// Checks detects the iptables mode and runs its commands for each IP family.
+func (s *Backend) Checks() []backendcmd.Check {
+	mode := util.DetectMode(privhelper.Exec())
+	if mode == util.ModeMissing {
+		return []backendcmd.Check{{Name: "iptables mode", Err: errors.New("neither iptables nor nft can be run on this host")}}
+	}
+
+	checks := []backendcmd.Check{{Name: "iptables mode", Detail: string(mode)}}
+	for _, protocol := range []util.Protocol{util.ProtocolIPv4, util.ProtocolIPv6} {
+		cmds, err := util.CheckCommands(privhelper.Exec(), protocol, mode)
+		checks = append(checks, backendcmd.Check{Name: string(protocol) + " commands", Detail: strings.Join(cmds, ", "), Err: err})
+	}
+	return checks
 }
 
 func (s *Backend) Setup() {
 	hostname = s.NodeName
+	util.RegisterMetrics()
+
+	if dropBit < 0 || dropBit > 31 {
+		klog.Fatalf("invalid --iptables-drop-bit %d, must be within [0, 31]", dropBit)
+	}
+	if err := checkReconcileMode(reconcileMode); err != nil {
+		klog.Fatal(err)
+	}
+
+	mode := util.ModeMissing
+	if !s.memory {
+		mode = util.DetectMode(privhelper.Exec())
+		if mode == util.ModeMissing {
+			klog.Fatal("neither iptables nor nft can be run on this host")
+		}
+		klog.Info("writing the rules in iptables mode ", mode)
+	}
+
 	IptablesImpl = make(map[v1.IPFamily]*iptables)
 	for _, protocol := range []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol} {
 		iptable := NewIptables()
-		iptable.iptInterface = util.NewIPTableExec(exec.New(), util.Protocol(protocol))
+		if s.memory {
+			iptable.iptInterface = util.NewMemory(util.Protocol(protocol))
+		} else {
+			iptable.iptInterface = util.NewForMode(privhelper.Exec(), util.Protocol(protocol), mode)
+		}
+		if faultinject.Enabled() {
+			iptable.iptInterface = util.WithFaults(iptable.iptInterface, faultinject.Default())
+		}
+		iptable.onSyncErr = s.onSyncErr
 		iptable.serviceChanges = NewServiceChangeTracker(newServiceInfo, protocol, iptable.recorder)
 		iptable.endpointsChanges = NewEndpointChangeTracker(hostname, protocol, iptable.recorder)
 		IptablesImpl[protocol] = iptable
@@ -66,6 +144,9 @@ func (s *Backend) Setup() {
 
 func (s *Backend) Reset() { /* noop, we're wrapped in filterreset */ }
 
+// RecordsChanges is true: the rules added and removed by each restore are recorded.
+func (s *Backend) RecordsChanges() bool { return true }
+
 func (s *Backend) Sync() {
 	for _, impl := range IptablesImpl {
 		wg.Add(1)
@@ -74,6 +155,21 @@ func (s *Backend) Sync() {
 	wg.Wait()
 }
 
+// SyncErr returns the error of the last sync, if it failed for any IP family.
+func (s *Backend) SyncErr() error {
+	for protocol, impl := range IptablesImpl {
+		if err := impl.lastSyncErr(); err != nil {
+			return fmt.Errorf("%s sync failed: %w", protocol, err)
+		}
+	}
+	return nil
+}
+
+// OnSyncErr sets the func called when the resync deleting the expired stale chains fails.
+func (s *Backend) OnSyncErr(f func(error)) {
+	s.onSyncErr = f
+}
+
 func (s *Backend) SetService(svc *localv1.Service) {
 	for _, impl := range IptablesImpl {
 		impl.serviceChanges.Update(svc)
@@ -0,0 +1,164 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/iptables/util"
+)
+
+func TestStaleChainsResync(t *testing.T) {
+	prevImpl := IptablesImpl
+	defer func() { IptablesImpl = prevImpl }()
+
+	kernel := newFakeKernel(util.ProtocolIPv4)
+
+	impl := NewIptables()
+	impl.iptInterface = kernel
+	impl.serviceChanges = NewServiceChangeTracker(newServiceInfo, v1.IPv4Protocol, nil)
+	impl.endpointsChanges = NewEndpointChangeTracker("node", v1.IPv4Protocol, nil)
+	impl.staleChainsGracePeriod = 100 * time.Millisecond
+	IptablesImpl = map[v1.IPFamily]*iptables{v1.IPv4Protocol: impl}
+
+	natRules := func() string {
+		impl.mu.Lock()
+		defer impl.mu.Unlock()
+
+		rules := new(bytes.Buffer)
+		kernel.SaveInto(util.TableNAT, rules)
+		return rules.String()
+	}
+
+	backend := New()
+	backend.SetService(&localv1.Service{
+		Namespace: "ns",
+		Name:      "svc",
+		Type:      "ClusterIP",
+		IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.96.0.10"), ExternalIPs: localv1.NewIPSet()},
+		Ports:     []*localv1.PortMapping{{Name: "http", Protocol: localv1.Protocol_TCP, Port: 80, TargetPort: 8080}},
+	})
+	backend.SetEndpoint("ns", "svc", "a", &localv1.Endpoint{IPs: localv1.NewIPSet("10.1.0.1")})
+	backend.Sync()
+	if err := backend.SyncErr(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(natRules(), ":KUBE-SVC-") {
+		t.Fatalf("no service chain:\n%s", natRules())
+	}
+
+	backend.DeleteEndpoint("ns", "svc", "a")
+	backend.DeleteService("ns", "svc")
+	backend.Sync()
+	if err := backend.SyncErr(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(natRules(), ":KUBE-SVC-") {
+		t.Fatalf("service chain deleted before its grace period:\n%s", natRules())
+	}
+
+	// no sync is received, the chains are deleted once the grace period expires
+	deadline := time.Now().Add(5 * time.Second)
+	for strings.Contains(natRules(), ":KUBE-SVC-") {
+		if time.Now().After(deadline) {
+			t.Fatalf("stale service chain not deleted:\n%s", natRules())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+	if len(impl.staleChains) != 0 {
+		t.Errorf("stale chains still queued: %v", impl.staleChains)
+	}
+}
+
+// failingRestore fails the restores once fail is set.
+type failingRestore struct {
+	util.Interface
+	fail int32
+}
+
+func (f *failingRestore) RestoreAll(data []byte, flush util.FlushFlag, counters util.RestoreCountersFlag) error {
+	if atomic.LoadInt32(&f.fail) != 0 {
+		return errors.New("restore failed")
+	}
+	return f.Interface.RestoreAll(data, flush, counters)
+}
+
+func TestStaleChainsResyncFailure(t *testing.T) {
+	prevImpl := IptablesImpl
+	defer func() { IptablesImpl = prevImpl }()
+
+	kernel := &failingRestore{Interface: newFakeKernel(util.ProtocolIPv4)}
+	failed := make(chan error, 1)
+
+	impl := NewIptables()
+	impl.iptInterface = kernel
+	impl.serviceChanges = NewServiceChangeTracker(newServiceInfo, v1.IPv4Protocol, nil)
+	impl.endpointsChanges = NewEndpointChangeTracker("node", v1.IPv4Protocol, nil)
+	impl.staleChainsGracePeriod = 100 * time.Millisecond
+	impl.onSyncErr = func(err error) {
+		select {
+		case failed <- err:
+		default:
+		}
+	}
+	IptablesImpl = map[v1.IPFamily]*iptables{v1.IPv4Protocol: impl}
+
+	backend := New()
+	backend.SetService(&localv1.Service{
+		Namespace: "ns",
+		Name:      "svc",
+		Type:      "ClusterIP",
+		IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.96.0.10"), ExternalIPs: localv1.NewIPSet()},
+		Ports:     []*localv1.PortMapping{{Name: "http", Protocol: localv1.Protocol_TCP, Port: 80, TargetPort: 8080}},
+	})
+	backend.SetEndpoint("ns", "svc", "a", &localv1.Endpoint{IPs: localv1.NewIPSet("10.1.0.1")})
+	backend.Sync()
+
+	backend.DeleteEndpoint("ns", "svc", "a")
+	backend.DeleteService("ns", "svc")
+	backend.Sync()
+	if err := backend.SyncErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the resync of the expired stale chains fails, the client is told to sync again
+	atomic.StoreInt32(&kernel.fail, 1)
+
+	select {
+	case err := <-failed:
+		if err == nil {
+			t.Error("expected the resync error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the failed resync was not reported")
+	}
+
+	if err := backend.SyncErr(); err == nil {
+		t.Error("expected SyncErr to report the failed resync")
+	}
+}
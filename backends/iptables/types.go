@@ -17,34 +17,18 @@ limitations under the License.
 package iptables
 
 import (
-	"fmt"
 	"net"
 
 	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/common"
 
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 // ServicePortName carries a namespace + name + portname.  This is the unique
 // identifier for a load-balanced service.
-type ServicePortName struct {
-	types.NamespacedName
-	Port     string
-	Protocol localv1.Protocol
-}
-
-func (spn ServicePortName) String() string {
-	return fmt.Sprintf("%s%s", spn.NamespacedName.String(), fmtPortName(spn.Port))
-}
-
-func fmtPortName(in string) string {
-	if in == "" {
-		return ""
-	}
-	return fmt.Sprintf(":%s", in)
-}
+type ServicePortName = common.ServicePortName
 
 // ServicePort is an interface which abstracts information about a service.
 type ServicePort interface {
@@ -0,0 +1,120 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+
+	utilexec "k8s.io/utils/exec"
+)
+
+var (
+	// ErrNotFound is matched (with errors.Is) by the errors of the commands on a chain or rule that
+	// does not exist. The calls removing something can usually ignore them.
+	ErrNotFound = errors.New("chain or rule not found")
+
+	// ErrResource is matched by the errors of the commands iptables was unable to attempt, notably
+	// when the xtables lock could not be grabbed in time. They are worth retrying.
+	ErrResource = errors.New("iptables resource problem")
+)
+
+// Error is the error of a failed iptables, iptables-restore or nft command.
+type Error struct {
+	// Op describes the failed operation (ie: `error flushing chain "KUBE-SERVICES"`), if any.
+	Op string
+	// Err is the error of the command, usually an utilexec.ExitError.
+	Err error
+	// Output is the output of the command.
+	Output []byte
+}
+
+func (e *Error) Error() string {
+	switch {
+	case len(e.Output) == 0 && e.Op == "":
+		return e.Err.Error()
+	case len(e.Output) == 0:
+		return fmt.Sprintf("%s: %v", e.Op, e.Err)
+	case e.Op == "":
+		return fmt.Sprintf("%v (%s)", e.Err, e.Output)
+	}
+	return fmt.Sprintf("%s: %v: %s", e.Op, e.Err, e.Output)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		// the netlink requests fail with ENOENT
+		if errors.Is(e.Err, syscall.ENOENT) {
+			return true
+		}
+
+		// iptables only tells it in its output
+		out := string(e.Output)
+		for _, str := range iptablesNotFoundStrings {
+			if strings.Contains(out, str) {
+				return true
+			}
+		}
+
+	case ErrResource:
+		var ee utilexec.ExitError
+		return errors.As(e.Err, &ee) && ee.ExitStatus() == iptablesStatusResourceProblem
+	}
+	return false
+}
+
+var iptablesNotFoundStrings = []string{
+	// iptables-legacy [-A|-I] BAD-CHAIN [...]
+	// iptables-legacy [-C|-D] GOOD-CHAIN [...non-matching rule...]
+	// iptables-legacy [-X|-F|-Z] BAD-CHAIN
+	// iptables-nft -X BAD-CHAIN
+	// NB: iptables-nft [-F|-Z] BAD-CHAIN exits with no error
+	"No chain/target/match by that name",
+
+	// iptables-legacy [...] -j BAD-CHAIN
+	// iptables-nft-1.8.0 [-A|-I] BAD-CHAIN [...]
+	// iptables-nft-1.8.0 [-A|-I] GOOD-CHAIN -j BAD-CHAIN
+	// NB: also matches some other things like "-m BAD-MODULE"
+	// nft list chain|table [...] BAD-NAME
+	"No such file or directory",
+
+	// iptables-legacy [-C|-D] BAD-CHAIN [...]
+	// iptables-nft [-C|-D] GOOD-CHAIN [...non-matching rule...]
+	"does a matching rule exist",
+
+	// iptables-nft-1.8.2 [-A|-C|-D|-I] BAD-CHAIN [...]
+	// iptables-nft-1.8.2 [...] -j BAD-CHAIN
+	"does not exist",
+}
+
+// IsNotFoundError returns true if the error indicates "not found" (see ErrNotFound).
+func IsNotFoundError(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+const iptablesStatusResourceProblem = 4
+
+// isResourceError returns true if the error indicates that iptables ran into a "resource
+// problem" and was unable to attempt the request (see ErrResource).
+func isResourceError(err error) bool {
+	return errors.Is(err, ErrResource)
+}
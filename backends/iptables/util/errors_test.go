@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	exectesting "k8s.io/utils/exec/testing"
+)
+
+func TestErrorIs(t *testing.T) {
+	for _, tc := range []struct {
+		err                error
+		notFound, resource bool
+	}{
+		{err: errors.New("No chain/target/match by that name")},
+		{
+			err:      &Error{Op: "error deleting rule", Err: exectesting.FakeExitError{Status: 1}, Output: []byte("iptables: Bad rule (does a matching rule exist in that chain?).")},
+			notFound: true,
+		},
+		{
+			err:      fmt.Errorf("cleanup: %w", &Error{Err: exectesting.FakeExitError{Status: 1}, Output: []byte("Error: No such file or directory")}),
+			notFound: true,
+		},
+		{
+			err:      &Error{Op: "error listing chain", Err: exectesting.FakeExitError{Status: 4}, Output: []byte("Another app is currently holding the xtables lock.")},
+			resource: true,
+		},
+		{err: &Error{Op: "error appending rule", Err: exectesting.FakeExitError{Status: 2}, Output: []byte("Bad argument")}},
+		{err: &Error{Op: "netlink: chain KUBE-SERVICES", Err: syscall.ENOENT}, notFound: true},
+	} {
+		if got := IsNotFoundError(tc.err); got != tc.notFound {
+			t.Errorf("IsNotFoundError(%q) = %v, expected %v", tc.err, got, tc.notFound)
+		}
+		if got := isResourceError(tc.err); got != tc.resource {
+			t.Errorf("isResourceError(%q) = %v, expected %v", tc.err, got, tc.resource)
+		}
+	}
+
+	err := &Error{Op: `error flushing chain "KUBE-SERVICES"`, Err: exectesting.FakeExitError{Status: 1}, Output: []byte("out")}
+	if expected := `error flushing chain "KUBE-SERVICES": exit 1: out`; err.Error() != expected {
+		t.Errorf("message is %q, expected %q", err.Error(), expected)
+	}
+
+	err = &Error{Op: "netlink", Err: syscall.EPERM}
+	if expected := "netlink: operation not permitted"; err.Error() != expected {
+		t.Errorf("message is %q, expected %q", err.Error(), expected)
+	}
+}
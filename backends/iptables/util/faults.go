@@ -0,0 +1,88 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"sigs.k8s.io/kpng/client/faultinject"
+)
+
+// faultyRunner injects faults in the calls changing the rules.
+type faultyRunner struct {
+	Interface
+	injector *faultinject.Injector
+}
+
+// WithFaults wraps iptables with the given fault injector. Reads are left untouched.
+func WithFaults(iptables Interface, injector *faultinject.Injector) Interface {
+	return &faultyRunner{Interface: iptables, injector: injector}
+}
+
+func (r *faultyRunner) EnsureChain(table Table, chain Chain) (bool, error) {
+	if err := r.injector.Inject("iptables ensure chain"); err != nil {
+		return false, err
+	}
+	return r.Interface.EnsureChain(table, chain)
+}
+
+func (r *faultyRunner) FlushChain(table Table, chain Chain) error {
+	if err := r.injector.Inject("iptables flush chain"); err != nil {
+		return err
+	}
+	return r.Interface.FlushChain(table, chain)
+}
+
+func (r *faultyRunner) DeleteChain(table Table, chain Chain) error {
+	if err := r.injector.Inject("iptables delete chain"); err != nil {
+		return err
+	}
+	return r.Interface.DeleteChain(table, chain)
+}
+
+func (r *faultyRunner) EnsureRule(position RulePosition, table Table, chain Chain, args ...string) (bool, error) {
+	if err := r.injector.Inject("iptables ensure rule"); err != nil {
+		return false, err
+	}
+	return r.Interface.EnsureRule(position, table, chain, args...)
+}
+
+func (r *faultyRunner) EnsureRules(rules []RuleSpec) (int, error) {
+	if err := r.injector.Inject("iptables ensure rules"); err != nil {
+		return 0, err
+	}
+	return r.Interface.EnsureRules(rules)
+}
+
+func (r *faultyRunner) DeleteRule(table Table, chain Chain, args ...string) error {
+	if err := r.injector.Inject("iptables delete rule"); err != nil {
+		return err
+	}
+	return r.Interface.DeleteRule(table, chain, args...)
+}
+
+func (r *faultyRunner) Restore(table Table, data []byte, flush FlushFlag, counters RestoreCountersFlag) error {
+	if err := r.injector.Inject("iptables restore"); err != nil {
+		return err
+	}
+	return r.Interface.Restore(table, data, flush, counters)
+}
+
+func (r *faultyRunner) RestoreAll(data []byte, flush FlushFlag, counters RestoreCountersFlag) error {
+	if err := r.injector.Inject("iptables restore"); err != nil {
+		return err
+	}
+	return r.Interface.RestoreAll(data, flush, counters)
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"errors"
+	"testing"
+
+	"sigs.k8s.io/kpng/client/faultinject"
+)
+
+// countingRunner counts the restores reaching iptables
+type countingRunner struct {
+	Interface
+	restores int
+}
+
+func (r *countingRunner) RestoreAll(data []byte, flush FlushFlag, counters RestoreCountersFlag) error {
+	r.restores++
+	return nil
+}
+
+func TestWithFaults(t *testing.T) {
+	for _, tc := range []struct {
+		rate         float64
+		minOK, maxOK int
+	}{
+		{0, 100, 100},
+		{0.3, 55, 85},
+		{1, 0, 0},
+	} {
+		runner := &countingRunner{}
+		ipt := WithFaults(runner, faultinject.New(faultinject.Config{ErrorRate: tc.rate, Seed: 1}))
+
+		injected := 0
+		for n := 0; n < 100; n++ {
+			err := ipt.RestoreAll(nil, NoFlushTables, NoRestoreCounters)
+			if err == nil {
+				continue
+			}
+			if !errors.As(err, &faultinject.Error{}) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			injected++
+		}
+
+		if runner.restores < tc.minOK || runner.restores > tc.maxOK {
+			t.Errorf("rate %v: %d restores reached iptables", tc.rate, runner.restores)
+		}
+		if runner.restores+injected != 100 {
+			t.Errorf("rate %v: failed restores must not reach iptables", tc.rate)
+		}
+	}
+}
@@ -19,6 +19,7 @@ package util
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -43,6 +44,14 @@ const (
 	Append RulePosition = "-A"
 )
 
+// RuleSpec is a rule appended by EnsureRules.
+type RuleSpec struct {
+	Table Table
+	Chain Chain
+	// Args are the arguments of the rule, written like iptables-save prints them.
+	Args []string
+}
+
 // Interface is an injectable interface for running iptables commands.  Implementations must be goroutine-safe.
 type Interface interface {
 	// EnsureChain checks if the specified chain exists and, if not, creates it.  If the chain existed, return true.
@@ -56,6 +65,10 @@ type Interface interface {
 	ChainExists(table Table, chain Chain) (bool, error)
 	// EnsureRule checks if the specified rule is present and, if not, creates it.  If the rule existed, return true.
 	EnsureRule(position RulePosition, table Table, chain Chain, args ...string) (bool, error)
+	// EnsureRules appends the rules missing from their chains in one transaction, which writes
+	// nothing if it fails, and returns how many were appended. The rules already present are
+	// skipped, so a failed batch can be submitted again. The chains must exist.
+	EnsureRules(rules []RuleSpec) (int, error)
 	// DeleteRule checks if the specified rule is present and, if so, deletes it.
 	DeleteRule(table Table, chain Chain, args ...string) error
 	// IsIPv6 returns true if this is managing ipv6 tables.
@@ -114,6 +127,8 @@ const (
 	TableFilter Table = "filter"
 	// TableMangle represents the built-in mangle table
 	TableMangle Table = "mangle"
+	// TableRaw represents the built-in raw table
+	TableRaw Table = "raw"
 )
 
 // Chain represents the different rules
@@ -203,6 +218,7 @@ type runner struct {
 	mu              sync.Mutex
 	exec            utilexec.Interface
 	protocol        Protocol
+	bins            binaries
 	hasCheck        bool
 	hasRandomFully  bool
 	waitFlag        []string
@@ -211,10 +227,10 @@ type runner struct {
 	lockfilePath16x string
 }
 
-// newInternal returns a new Interface which will exec iptables, and allows the
-// caller to change the iptables-restore lockfile path
-func newInternal(exec utilexec.Interface, protocol Protocol, lockfilePath14x, lockfilePath16x string) Interface {
-	version, err := getIPTablesVersion(exec, protocol)
+// newInternal returns a new Interface which will exec the given iptables commands, and allows
+// the caller to change the iptables-restore lockfile path
+func newInternal(exec utilexec.Interface, protocol Protocol, bins binaries, lockfilePath14x, lockfilePath16x string) Interface {
+	version, err := getIPTablesVersion(exec, bins)
 	if err != nil {
 		klog.Warningf("Error checking iptables version, assuming version at least %s: %v", MinCheckVersion, err)
 		version = MinCheckVersion
@@ -230,10 +246,11 @@ func newInternal(exec utilexec.Interface, protocol Protocol, lockfilePath14x, lo
 	runner := &runner{
 		exec:            exec,
 		protocol:        protocol,
+		bins:            bins,
 		hasCheck:        version.AtLeast(MinCheckVersion),
 		hasRandomFully:  version.AtLeast(RandomFullyMinVersion),
 		waitFlag:        getIPTablesWaitFlag(version),
-		restoreWaitFlag: getIPTablesRestoreWaitFlag(version, exec, protocol),
+		restoreWaitFlag: getIPTablesRestoreWaitFlag(version, exec, bins),
 		lockfilePath14x: lockfilePath14x,
 		lockfilePath16x: lockfilePath16x,
 	}
@@ -242,12 +259,12 @@ func newInternal(exec utilexec.Interface, protocol Protocol, lockfilePath14x, lo
 
 // NewIPTableExec returns a new Interface which will exec iptables.
 func NewIPTableExec(exec utilexec.Interface, protocol Protocol) Interface {
-	return newInternal(exec, protocol, "", "")
+	return newInternal(exec, protocol, binariesFor(protocol, ""), "", "")
 }
 
 // New returns a new Interface which will exec iptables.
 func New(exec utilexec.Interface, protocol Protocol) Interface {
-	return newInternal(exec, protocol, "", "")
+	return newInternal(exec, protocol, binariesFor(protocol, ""), "", "")
 }
 
 // EnsureChain is part of Interface.
@@ -264,7 +281,7 @@ func (runner *runner) EnsureChain(table Table, chain Chain) (bool, error) {
 				return true, nil
 			}
 		}
-		return false, fmt.Errorf("error creating chain %q: %v: %s", chain, err, out)
+		return false, &Error{Op: fmt.Sprintf("error creating chain %q", chain), Err: err, Output: out}
 	}
 	return false, nil
 }
@@ -278,7 +295,7 @@ func (runner *runner) FlushChain(table Table, chain Chain) error {
 
 	out, err := runner.run(opFlushChain, fullArgs)
 	if err != nil {
-		return fmt.Errorf("error flushing chain %q: %v: %s", chain, err, out)
+		return &Error{Op: fmt.Sprintf("error flushing chain %q", chain), Err: err, Output: out}
 	}
 	return nil
 }
@@ -293,7 +310,7 @@ func (runner *runner) DeleteChain(table Table, chain Chain) error {
 	// TODO: we could call iptables -S first, ignore the output and check for non-zero return (more like DeleteRule)
 	out, err := runner.run(opDeleteChain, fullArgs)
 	if err != nil {
-		return fmt.Errorf("error deleting chain %q: %v: %s", chain, err, out)
+		return &Error{Op: fmt.Sprintf("error deleting chain %q", chain), Err: err, Output: out}
 	}
 	return nil
 }
@@ -314,11 +331,106 @@ func (runner *runner) EnsureRule(position RulePosition, table Table, chain Chain
 	}
 	out, err := runner.run(operation(position), fullArgs)
 	if err != nil {
-		return false, fmt.Errorf("error appending rule: %v: %s", err, out)
+		return false, &Error{Op: "error appending rule", Err: err, Output: out}
 	}
 	return false, nil
 }
 
+// EnsureRules is part of Interface. The rules present are found in the output of one
+// iptables-save, and the missing ones are appended with one iptables-restore.
+func (runner *runner) EnsureRules(rules []RuleSpec) (int, error) {
+	if len(rules) == 0 {
+		return 0, nil
+	}
+
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+
+	tables := rulesTables(rules)
+	saved, err := runner.saveTables(tables)
+	if err != nil {
+		return 0, &Error{Op: "error checking rules", Err: err}
+	}
+
+	buf := LineBuffer{}
+	missing := 0
+	for _, table := range tables {
+		buf.Write("*" + string(table))
+		for _, rule := range rules {
+			if rule.Table != table || hasSavedRule(saved[table], rule.Chain, rule.Args...) {
+				continue
+			}
+			buf.Write("-A", string(rule.Chain), restoreArgs(rule.Args))
+			missing++
+		}
+		buf.Write("COMMIT")
+	}
+
+	if missing == 0 {
+		return 0, nil
+	}
+
+	klog.V(4).Infof("appending %d of %d rules", missing, len(rules))
+	if err := runner.restoreLocked(nil, buf.Bytes(), NoFlushTables, NoRestoreCounters); err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			e.Op = "error appending rules"
+		}
+		return 0, err
+	}
+	return missing, nil
+}
+
+// rulesTables returns the tables of rules, in order of appearance.
+func rulesTables(rules []RuleSpec) (tables []Table) {
+	seen := map[Table]bool{}
+	for _, rule := range rules {
+		if !seen[rule.Table] {
+			seen[rule.Table] = true
+			tables = append(tables, rule.Table)
+		}
+	}
+	return
+}
+
+// saveTables returns the iptables-save output of each table, saving all of them at once if needed.
+func (runner *runner) saveTables(tables []Table) (map[Table][]byte, error) {
+	args := []string{}
+	if len(tables) == 1 {
+		args = []string{"-t", string(tables[0])}
+	}
+
+	klog.V(4).Infof("running %s %v", runner.bins.save, args)
+	out, err := runner.exec.Command(runner.bins.save, args...).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	saved := map[Table][]byte{}
+	var table Table
+	for _, line := range bytes.SplitAfter(out, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("*")) {
+			table = Table(bytes.TrimSpace(line[1:]))
+		}
+		if table != "" {
+			saved[table] = append(saved[table], line...)
+		}
+	}
+	return saved, nil
+}
+
+// restoreArgs quotes the args of a rule for iptables-restore.
+func restoreArgs(args []string) []string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " \t\"") {
+			arg = `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+		}
+		quoted[i] = arg
+	}
+	return quoted
+}
+
 // DeleteRule is part of Interface.
 func (runner *runner) DeleteRule(table Table, chain Chain, args ...string) error {
 	fullArgs := makeFullArgs(table, chain, args...)
@@ -335,7 +447,7 @@ func (runner *runner) DeleteRule(table Table, chain Chain, args ...string) error
 	}
 	out, err := runner.run(opDeleteRule, fullArgs)
 	if err != nil {
-		return fmt.Errorf("error deleting rule: %v: %s", err, out)
+		return &Error{Op: "error deleting rule", Err: err, Output: out}
 	}
 	return nil
 }
@@ -357,7 +469,7 @@ func (runner *runner) SaveInto(table Table, buffer *bytes.Buffer) error {
 	defer trace.LogIfLong(2 * time.Second)
 
 	// run and return
-	iptablesSaveCmd := iptablesSaveCommand(runner.protocol)
+	iptablesSaveCmd := runner.bins.save
 	args := []string{"-t", string(table)}
 	klog.V(4).Infof("running %s %v", iptablesSaveCmd, args)
 	cmd := runner.exec.Command(iptablesSaveCmd, args...)
@@ -395,6 +507,11 @@ func (runner *runner) restoreInternal(args []string, data []byte, flush FlushFla
 	runner.mu.Lock()
 	defer runner.mu.Unlock()
 
+	return runner.restoreLocked(args, data, flush, counters)
+}
+
+// restoreLocked runs iptables-restore, runner.mu must be held.
+func (runner *runner) restoreLocked(args []string, data []byte, flush FlushFlag, counters RestoreCountersFlag) error {
 	trace := utiltrace.New("iptables restore")
 	defer trace.LogIfLong(2 * time.Second)
 
@@ -405,6 +522,10 @@ func (runner *runner) restoreInternal(args []string, data []byte, flush FlushFla
 		args = append(args, "--counters")
 	}
 
+	unlock := xtablesLock.lock()
+	defer unlock()
+	trace.Step("kpng lock grabbed")
+
 	// Grab the iptables lock to prevent iptables-restore and iptables
 	// from stepping on each other.  iptables-restore 1.6.2 will have
 	// a --wait option like iptables itself, but that's not widely deployed.
@@ -423,52 +544,40 @@ func (runner *runner) restoreInternal(args []string, data []byte, flush FlushFla
 
 	// run the command and return the output or an error including the output and error
 	fullArgs := append(runner.restoreWaitFlag, args...)
-	iptablesRestoreCmd := iptablesRestoreCommand(runner.protocol)
+	iptablesRestoreCmd := runner.bins.restore
 	klog.V(4).Infof("running %s %v", iptablesRestoreCmd, fullArgs)
 	cmd := runner.exec.Command(iptablesRestoreCmd, fullArgs...)
 	cmd.SetStdin(bytes.NewBuffer(data))
 	b, err := cmd.CombinedOutput()
+	recordLockContention(b, err)
 	if err != nil {
-		return fmt.Errorf("%v (%s)", err, b)
+		return &Error{Err: err, Output: b}
 	}
 	return nil
 }
 
-func iptablesSaveCommand(protocol Protocol) string {
-	if protocol == ProtocolIPv6 {
-		return cmdIP6TablesSave
-	}
-	return cmdIPTablesSave
-}
-
-func iptablesRestoreCommand(protocol Protocol) string {
-	if protocol == ProtocolIPv6 {
-		return cmdIP6TablesRestore
-	}
-	return cmdIPTablesRestore
-
-}
-
-func iptablesCommand(protocol Protocol) string {
-	if protocol == ProtocolIPv6 {
-		return cmdIP6Tables
-	}
-	return cmdIPTables
-}
-
 func (runner *runner) run(op operation, args []string) ([]byte, error) {
 	return runner.runContext(context.TODO(), op, args)
 }
 
 func (runner *runner) runContext(ctx context.Context, op operation, args []string) ([]byte, error) {
-	iptablesCmd := iptablesCommand(runner.protocol)
+	iptablesCmd := runner.bins.iptables
 	fullArgs := append(runner.waitFlag, string(op))
 	fullArgs = append(fullArgs, args...)
 	klog.V(5).Infof("running iptables: %s %v", iptablesCmd, fullArgs)
+
+	unlock := xtablesLock.lock()
+	defer unlock()
+
+	var cmd utilexec.Cmd
 	if ctx == nil {
-		return runner.exec.Command(iptablesCmd, fullArgs...).CombinedOutput()
+		cmd = runner.exec.Command(iptablesCmd, fullArgs...)
+	} else {
+		cmd = runner.exec.CommandContext(ctx, iptablesCmd, fullArgs...)
 	}
-	return runner.exec.CommandContext(ctx, iptablesCmd, fullArgs...).CombinedOutput()
+	out, err := cmd.CombinedOutput()
+	recordLockContention(out, err)
+	return out, err
 	// Don't log err here - callers might not think it is an error.
 }
 
@@ -491,13 +600,18 @@ func trimhex(s string) string {
 // Present for compatibility with <1.4.11 versions of iptables.  This is full
 // of hack and half-measures.  We should nix this ASAP.
 func (runner *runner) checkRuleWithoutCheck(table Table, chain Chain, args ...string) (bool, error) {
-	iptablesSaveCmd := iptablesSaveCommand(runner.protocol)
+	iptablesSaveCmd := runner.bins.save
 	klog.V(1).Infof("running %s -t %s", iptablesSaveCmd, string(table))
 	out, err := runner.exec.Command(iptablesSaveCmd, "-t", string(table)).CombinedOutput()
 	if err != nil {
 		return false, fmt.Errorf("error checking rule: %v", err)
 	}
 
+	return hasSavedRule(out, chain, args...), nil
+}
+
+// hasSavedRule returns true if the iptables-save output contains the rule.
+func hasSavedRule(save []byte, chain Chain, args ...string) bool {
 	// Sadly, iptables has inconsistent quoting rules for comments. Just remove all quotes.
 	// Also, quoted multi-word comments (which are counted as a single arg)
 	// will be unpacked into multiple args,
@@ -511,7 +625,7 @@ func (runner *runner) checkRuleWithoutCheck(table Table, chain Chain, args ...st
 	}
 	argset := sets.NewString(argsCopy...)
 
-	for _, line := range strings.Split(string(out), "\n") {
+	for _, line := range strings.Split(string(save), "\n") {
 		var fields = strings.Fields(line)
 
 		// Check that this is a rule for the correct chain, and that it has
@@ -529,12 +643,12 @@ func (runner *runner) checkRuleWithoutCheck(table Table, chain Chain, args ...st
 
 		// TODO: This misses reorderings e.g. "-x foo ! -y bar" will match "! -x foo -y bar"
 		if sets.NewString(fields...).IsSuperset(argset) {
-			return true, nil
+			return true
 		}
 		klog.V(5).Infof("DBG: fields is not a superset of args: fields=%v  args=%v", fields, args)
 	}
 
-	return false, nil
+	return false
 }
 
 // Executes the rule check using the "-C" flag
@@ -556,7 +670,7 @@ func (runner *runner) checkRuleUsingCheck(args []string) (bool, error) {
 			return false, nil
 		}
 	}
-	return false, fmt.Errorf("error checking rule: %v: %s", err, out)
+	return false, &Error{Op: "error checking rule", Err: err, Output: out}
 }
 
 const (
@@ -568,6 +682,11 @@ const (
 
 // Monitor is part of Interface
 func (runner *runner) Monitor(canary Chain, tables []Table, reloadFunc func(), interval time.Duration, stopCh <-chan struct{}) {
+	monitor(runner, canary, tables, reloadFunc, interval, stopCh)
+}
+
+// monitor implements Monitor with the chain operations of runner.
+func monitor(runner Interface, canary Chain, tables []Table, reloadFunc func(), interval time.Duration, stopCh <-chan struct{}) {
 	for {
 		_ = utilwait.PollImmediateUntil(interval, func() (bool, error) {
 			for _, table := range tables {
@@ -628,8 +747,11 @@ func (runner *runner) ChainExists(table Table, chain Chain) (bool, error) {
 	trace := utiltrace.New("iptables ChainExists")
 	defer trace.LogIfLong(2 * time.Second)
 
-	_, err := runner.run(opListChain, fullArgs)
-	return err == nil, err
+	out, err := runner.run(opListChain, fullArgs)
+	if err != nil {
+		return false, &Error{Op: fmt.Sprintf("error listing chain %q", chain), Err: err, Output: out}
+	}
+	return true, nil
 }
 
 type operation string
@@ -650,9 +772,9 @@ func makeFullArgs(table Table, chain Chain, args ...string) []string {
 const iptablesVersionPattern = `v([0-9]+(\.[0-9]+)+)`
 
 // getIPTablesVersion runs "iptables --version" and parses the returned version
-func getIPTablesVersion(exec utilexec.Interface, protocol Protocol) (*utilversion.Version, error) {
+func getIPTablesVersion(exec utilexec.Interface, bins binaries) (*utilversion.Version, error) {
 	// this doesn't access mutable state so we don't need to use the interface / runner
-	iptablesCmd := iptablesCommand(protocol)
+	iptablesCmd := bins.iptables
 	bytes, err := exec.Command(iptablesCmd, "--version").CombinedOutput()
 	if err != nil {
 		return nil, err
@@ -674,9 +796,9 @@ func getIPTablesVersion(exec utilexec.Interface, protocol Protocol) (*utilversio
 func getIPTablesWaitFlag(version *utilversion.Version) []string {
 	switch {
 	case version.AtLeast(WaitIntervalMinVersion):
-		return []string{WaitString, WaitSecondsValue, WaitIntervalString, WaitIntervalUsecondsValue}
+		return []string{WaitString, waitSecondsValue(), WaitIntervalString, waitIntervalValue()}
 	case version.AtLeast(WaitSecondsMinVersion):
-		return []string{WaitString, WaitSecondsValue}
+		return []string{WaitString, waitSecondsValue()}
 	case version.AtLeast(WaitMinVersion):
 		return []string{WaitString}
 	default:
@@ -685,14 +807,14 @@ func getIPTablesWaitFlag(version *utilversion.Version) []string {
 }
 
 // Checks if iptables-restore has a "wait" flag
-func getIPTablesRestoreWaitFlag(version *utilversion.Version, exec utilexec.Interface, protocol Protocol) []string {
+func getIPTablesRestoreWaitFlag(version *utilversion.Version, exec utilexec.Interface, bins binaries) []string {
 	if version.AtLeast(WaitRestoreMinVersion) {
-		return []string{WaitString, WaitSecondsValue, WaitIntervalString, WaitIntervalUsecondsValue}
+		return []string{WaitString, waitSecondsValue(), WaitIntervalString, waitIntervalValue()}
 	}
 
 	// Older versions may have backported features; if iptables-restore supports
 	// --version, assume it also supports --wait
-	vstring, err := getIPTablesRestoreVersionString(exec, protocol)
+	vstring, err := getIPTablesRestoreVersionString(exec, bins)
 	if err != nil || vstring == "" {
 		klog.V(3).Infof("couldn't get iptables-restore version; assuming it doesn't support --wait")
 		return nil
@@ -706,13 +828,13 @@ func getIPTablesRestoreWaitFlag(version *utilversion.Version, exec utilexec.Inte
 
 // getIPTablesRestoreVersionString runs "iptables-restore --version" to get the version string
 // in the form "X.X.X"
-func getIPTablesRestoreVersionString(exec utilexec.Interface, protocol Protocol) (string, error) {
+func getIPTablesRestoreVersionString(exec utilexec.Interface, bins binaries) (string, error) {
 	// this doesn't access mutable state so we don't need to use the interface / runner
 
 	// iptables-restore hasn't always had --version, and worse complains
 	// about unrecognized commands but doesn't exit when it gets them.
 	// Work around that by setting stdin to nothing so it exits immediately.
-	iptablesRestoreCmd := iptablesRestoreCommand(protocol)
+	iptablesRestoreCmd := bins.restore
 	cmd := exec.Command(iptablesRestoreCmd, "--version")
 	cmd.SetStdin(bytes.NewReader([]byte{}))
 	bytes, err := cmd.CombinedOutput()
@@ -741,51 +863,3 @@ func (runner *runner) Present() bool {
 	return true
 }
 
-var iptablesNotFoundStrings = []string{
-	// iptables-legacy [-A|-I] BAD-CHAIN [...]
-	// iptables-legacy [-C|-D] GOOD-CHAIN [...non-matching rule...]
-	// iptables-legacy [-X|-F|-Z] BAD-CHAIN
-	// iptables-nft -X BAD-CHAIN
-	// NB: iptables-nft [-F|-Z] BAD-CHAIN exits with no error
-	"No chain/target/match by that name",
-
-	// iptables-legacy [...] -j BAD-CHAIN
-	// iptables-nft-1.8.0 [-A|-I] BAD-CHAIN [...]
-	// iptables-nft-1.8.0 [-A|-I] GOOD-CHAIN -j BAD-CHAIN
-	// NB: also matches some other things like "-m BAD-MODULE"
-	"No such file or directory",
-
-	// iptables-legacy [-C|-D] BAD-CHAIN [...]
-	// iptables-nft [-C|-D] GOOD-CHAIN [...non-matching rule...]
-	"does a matching rule exist",
-
-	// iptables-nft-1.8.2 [-A|-C|-D|-I] BAD-CHAIN [...]
-	// iptables-nft-1.8.2 [...] -j BAD-CHAIN
-	"does not exist",
-}
-
-// IsNotFoundError returns true if the error indicates "not found".  It parses
-// the error string looking for known values, which is imperfect; beware using
-// this function for anything beyond deciding between logging or ignoring an
-// error.
-func IsNotFoundError(err error) bool {
-	es := err.Error()
-	for _, str := range iptablesNotFoundStrings {
-		if strings.Contains(es, str) {
-			return true
-		}
-	}
-	return false
-}
-
-const iptablesStatusResourceProblem = 4
-
-// isResourceError returns true if the error indicates that iptables ran into a "resource
-// problem" and was unable to attempt the request. In particular, this will be true if it
-// times out trying to get the iptables lock.
-func isResourceError(err error) bool {
-	if ee, isExitError := err.(utilexec.ExitError); isExitError {
-		return ee.ExitStatus() == iptablesStatusResourceProblem
-	}
-	return false
-}
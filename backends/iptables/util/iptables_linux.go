@@ -72,26 +72,37 @@ func grabIptablesLocks(lockfilePath14x, lockfilePath16x string) (iptablesLocker,
 		return nil, fmt.Errorf("failed to open iptables lock %s: %v", lockfilePath16x, err)
 	}
 
-	if err := wait.PollImmediate(200*time.Millisecond, 2*time.Second, func() (bool, error) {
+	timeout := time.Duration(WaitSeconds) * time.Second
+	contended := false
+
+	if err := wait.PollImmediate(WaitInterval, timeout, func() (bool, error) {
 		if err := grabIptablesFileLock(l.lock16); err != nil {
+			contended = true
 			return false, nil
 		}
 		return true, nil
 	}); err != nil {
+		LockContentionTotal.WithLabelValues(lockTimeout).Inc()
 		return nil, fmt.Errorf("failed to acquire new iptables lock: %v", err)
 	}
 
 	// Roughly duplicate iptables 1.4.x xtables_lock() function.
-	if err := wait.PollImmediate(200*time.Millisecond, 2*time.Second, func() (bool, error) {
+	if err := wait.PollImmediate(WaitInterval, timeout, func() (bool, error) {
 		l.lock14, err = net.ListenUnix("unix", &net.UnixAddr{Name: lockfilePath14x, Net: "unix"})
 		if err != nil {
+			contended = true
 			return false, nil
 		}
 		return true, nil
 	}); err != nil {
+		LockContentionTotal.WithLabelValues(lockTimeout).Inc()
 		return nil, fmt.Errorf("failed to acquire old iptables lock: %v", err)
 	}
 
+	if contended {
+		LockContentionTotal.WithLabelValues(lockWaited).Inc()
+	}
+
 	success = true
 	return l, nil
 }
@@ -99,3 +110,8 @@ func grabIptablesLocks(lockfilePath14x, lockfilePath16x string) (iptablesLocker,
 func grabIptablesFileLock(f *os.File) error {
 	return unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
 }
+
+// lockFile waits for an exclusive lock on f.
+func lockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_EX)
+}
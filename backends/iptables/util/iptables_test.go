@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"k8s.io/utils/exec"
+	exectesting "k8s.io/utils/exec/testing"
+)
+
+func TestEnsureRules(t *testing.T) {
+	const saved = `*nat
+:KUBE-PORTALS-CONTAINER - [0:0]
+-A KUBE-PORTALS-CONTAINER -m comment --comment ns/svc:p -p tcp -m tcp --dport 80 -d 10.0.0.1/32 -j REDIRECT --to-ports 40000
+COMMIT
+*filter
+:KUBE-NODEPORT-NON-LOCAL - [0:0]
+COMMIT
+`
+
+	rules := []RuleSpec{
+		{TableNAT, "KUBE-PORTALS-CONTAINER", []string{"-m", "comment", "--comment", "ns/svc:p", "-p", "tcp", "-m", "tcp", "--dport", "80", "-d", "10.0.0.1/32", "-j", "REDIRECT", "--to-ports", "40000"}},
+		{TableNAT, "KUBE-NODEPORT-CONTAINER", []string{"-m", "comment", "--comment", "ns/svc:p", "-p", "tcp", "-m", "tcp", "--dport", "30080", "-j", "REDIRECT", "--to-ports", "40000"}},
+		{TableFilter, "KUBE-NODEPORT-NON-LOCAL", []string{"-m", "comment", "--comment", "my service", "-p", "tcp", "-m", "tcp", "--dport", "40000", "-j", "ACCEPT"}},
+	}
+
+	fexec := &exectesting.FakeExec{}
+	var restored string
+
+	fexec.CommandScript = append(fexec.CommandScript,
+		func(cmd string, args ...string) exec.Cmd {
+			if cmd != "iptables-save" || len(args) != 0 {
+				t.Errorf("expected one iptables-save of all the tables, got %s %v", cmd, args)
+			}
+			return exectesting.InitFakeCmd(&exectesting.FakeCmd{
+				OutputScript: []exectesting.FakeAction{func() ([]byte, []byte, error) { return []byte(saved), nil, nil }},
+			}, cmd, args...)
+		},
+		func(cmd string, args ...string) exec.Cmd {
+			if cmd != "iptables-restore" {
+				t.Errorf("expected iptables-restore, got %s", cmd)
+			}
+			fcmd := &exectesting.FakeCmd{}
+			fcmd.CombinedOutputScript = []exectesting.FakeAction{func() ([]byte, []byte, error) {
+				data, _ := io.ReadAll(fcmd.Stdin)
+				restored = string(data)
+				return nil, nil, nil
+			}}
+			return exectesting.InitFakeCmd(fcmd, cmd, args...)
+		},
+	)
+
+	ipt := &runner{exec: fexec, protocol: ProtocolIPv4, bins: binariesFor(ProtocolIPv4, ""), restoreWaitFlag: []string{WaitString}}
+
+	added, err := ipt.EnsureRules(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 2 {
+		t.Errorf("expected 2 rules to be appended, got %d", added)
+	}
+
+	expected := strings.Join([]string{
+		"*nat",
+		"-A KUBE-NODEPORT-CONTAINER -m comment --comment ns/svc:p -p tcp -m tcp --dport 30080 -j REDIRECT --to-ports 40000",
+		"COMMIT",
+		"*filter",
+		`-A KUBE-NODEPORT-NON-LOCAL -m comment --comment "my service" -p tcp -m tcp --dport 40000 -j ACCEPT`,
+		"COMMIT",
+		"",
+	}, "\n")
+	if restored != expected {
+		t.Errorf("expected the missing rules to be restored:\n%s\ngot:\n%s", expected, restored)
+	}
+	if fexec.CommandCalls != 2 {
+		t.Errorf("expected 2 commands, got %d", fexec.CommandCalls)
+	}
+}
+
+func TestEnsureRulesPresent(t *testing.T) {
+	fexec := &exectesting.FakeExec{}
+	fexec.CommandScript = append(fexec.CommandScript, func(cmd string, args ...string) exec.Cmd {
+		if cmd != "iptables-save" || strings.Join(args, " ") != "-t nat" {
+			t.Errorf("expected an iptables-save of the nat table, got %s %v", cmd, args)
+		}
+		return exectesting.InitFakeCmd(&exectesting.FakeCmd{
+			OutputScript: []exectesting.FakeAction{func() ([]byte, []byte, error) {
+				return []byte("*nat\n-A KUBE-PORTALS-HOST -d 10.0.0.1/32 -j DNAT --to-destination 10.1.0.1:40000\nCOMMIT\n"), nil, nil
+			}},
+		}, cmd, args...)
+	})
+
+	ipt := &runner{exec: fexec, protocol: ProtocolIPv4, bins: binariesFor(ProtocolIPv4, "")}
+
+	added, err := ipt.EnsureRules([]RuleSpec{{TableNAT, "KUBE-PORTALS-HOST", []string{"-d", "10.0.0.1/32", "-j", "DNAT", "--to-destination", "10.1.0.1:40000"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 0 || fexec.CommandCalls != 1 {
+		t.Errorf("expected no restore, got %d commands", fexec.CommandCalls)
+	}
+}
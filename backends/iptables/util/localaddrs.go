@@ -0,0 +1,113 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// LocalAddrsTTL is how long the local addresses are cached. The cache is also invalidated by the
+// address changes notified by netlink, the TTL only bounds the staleness if they are missed.
+var LocalAddrsTTL = time.Minute
+
+// localAddrCache caches the addresses of the node, listing all the interfaces being expensive
+// on hosts with many addresses.
+type localAddrCache struct {
+	list func() ([]net.IP, error)
+	now  func() time.Time
+
+	watchOnce sync.Once
+	watch     func(invalidate func()) error
+
+	mu      sync.Mutex
+	addrs   []net.IP
+	expires time.Time
+}
+
+var localAddrs = &localAddrCache{
+	list:  interfaceAddrs,
+	now:   time.Now,
+	watch: watchLocalAddrs,
+}
+
+// LocalAddrs returns the addresses of the node.
+func LocalAddrs() ([]net.IP, error) {
+	return localAddrs.get()
+}
+
+// InvalidateLocalAddrs forces the next LocalAddrs call to list the addresses again.
+func InvalidateLocalAddrs() {
+	localAddrs.invalidate()
+}
+
+func (c *localAddrCache) get() ([]net.IP, error) {
+	c.watchOnce.Do(func() {
+		if err := c.watch(c.invalidate); err != nil {
+			klog.ErrorS(err, "Failed to watch the local addresses, they will be listed again every TTL", "ttl", LocalAddrsTTL)
+		}
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.addrs != nil && c.now().Before(c.expires) {
+		return c.addrs, nil
+	}
+
+	addrs, err := c.list()
+	if err != nil {
+		return nil, err
+	}
+	if addrs == nil {
+		addrs = []net.IP{}
+	}
+
+	c.addrs = addrs
+	c.expires = c.now().Add(LocalAddrsTTL)
+	LocalAddresses.Set(float64(len(addrs)))
+
+	return addrs, nil
+}
+
+func (c *localAddrCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.addrs = nil
+}
+
+// interfaceAddrs lists the addresses of all the interfaces.
+func interfaceAddrs() ([]net.IP, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ip, _, err := net.ParseCIDR(addr.String())
+		if err != nil {
+			return nil, err
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestLocalAddrCache(t *testing.T) {
+	now := time.Unix(0, 0)
+	lists := 0
+	var invalidate func()
+
+	c := &localAddrCache{
+		list: func() ([]net.IP, error) {
+			lists++
+			return []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("fd00::1")}, nil
+		},
+		now:   func() time.Time { return now },
+		watch: func(f func()) error { invalidate = f; return nil },
+	}
+
+	for i := 0; i < 3; i++ {
+		if addrs, err := c.get(); err != nil || len(addrs) != 2 {
+			t.Fatalf("expected 2 addresses, got %v, %v", addrs, err)
+		}
+	}
+	if lists != 1 {
+		t.Errorf("expected the addresses to be listed once, got %d", lists)
+	}
+	if got := testutil.ToFloat64(LocalAddresses); got != 2 {
+		t.Errorf("expected the gauge to be 2, got %v", got)
+	}
+
+	invalidate()
+	c.get()
+	if lists != 2 {
+		t.Errorf("expected the addresses to be listed again after an address event, got %d lists", lists)
+	}
+
+	now = now.Add(LocalAddrsTTL)
+	c.get()
+	if lists != 3 {
+		t.Errorf("expected the addresses to be listed again after the TTL, got %d lists", lists)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+)
+
+var (
+	// WaitSeconds is how long iptables waits for the xtables lock before failing.
+	WaitSeconds = 5
+	// WaitInterval is how often iptables tries to grab the xtables lock while waiting.
+	WaitInterval = 100 * time.Millisecond
+	// LockFile is locked around each call when set, to serialize the calls of several kpng processes
+	// (ie userspacelin and iptables running side by side).
+	LockFile = ""
+)
+
+// BindFlags binds the xtables lock settings to flags.
+func BindFlags(flags *pflag.FlagSet) {
+	flags.IntVar(&WaitSeconds, "iptables-wait", WaitSeconds, "Seconds to wait for the xtables lock (iptables -w)")
+	flags.DurationVar(&WaitInterval, "iptables-wait-interval", WaitInterval, "Interval between attempts to grab the xtables lock (iptables -W)")
+	flags.StringVar(&LockFile, "iptables-lock-file", LockFile, "File locked around iptables calls to serialize them between kpng processes")
+	flags.DurationVar(&LocalAddrsTTL, "local-addresses-ttl", LocalAddrsTTL, "How long the addresses of the node are cached (they are also listed again when netlink notifies a change)")
+	flags.Var(&RequestedMode, "iptables-mode", "How the rules are written: auto, legacy (iptables-legacy), nft (iptables-nft), nft-json (nft alone, when the iptables commands are missing) or netlink (like nft-json, without running nft)")
+}
+
+func waitSecondsValue() string {
+	return strconv.Itoa(WaitSeconds)
+}
+
+func waitIntervalValue() string {
+	return strconv.FormatInt(WaitInterval.Microseconds(), 10)
+}
+
+// lockManager serializes the iptables calls made by kpng, between the runners of a process
+// (IPv4 and IPv6, or several backends) and between processes sharing the LockFile.
+type lockManager struct {
+	mu sync.Mutex
+}
+
+var xtablesLock = &lockManager{}
+
+// lock waits for the other calls of kpng to finish. The returned function releases the lock.
+func (l *lockManager) lock() (unlock func()) {
+	start := time.Now()
+	l.mu.Lock()
+
+	var f *os.File
+	if LockFile != "" {
+		var err error
+		f, err = os.OpenFile(LockFile, os.O_CREATE, 0600)
+		if err == nil {
+			err = lockFile(f)
+		}
+		if err != nil {
+			klog.Warningf("failed to lock %s, calls of other kpng processes are not serialized: %v", LockFile, err)
+			if f != nil {
+				f.Close()
+				f = nil
+			}
+		}
+	}
+
+	LockWaitDuration.Observe(time.Since(start).Seconds())
+
+	return func() {
+		if f != nil {
+			f.Close() // releases the file lock
+		}
+		l.mu.Unlock()
+	}
+}
+
+const lockHeldMessage = "holding the xtables lock"
+
+// recordLockContention counts the calls that had to wait for a lock held by another process,
+// detected from the messages of iptables.
+func recordLockContention(out []byte, err error) {
+	if !strings.Contains(string(out), lockHeldMessage) {
+		return
+	}
+
+	if err != nil {
+		klog.Warningf("timed out waiting for the xtables lock: %s", out)
+		LockContentionTotal.WithLabelValues(lockTimeout).Inc()
+	} else {
+		LockContentionTotal.WithLabelValues(lockWaited).Inc()
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+)
+
+func TestWaitFlag(t *testing.T) {
+	defer func(s int, i time.Duration) { WaitSeconds, WaitInterval = s, i }(WaitSeconds, WaitInterval)
+	WaitSeconds, WaitInterval = 10, 50*time.Millisecond
+
+	flag := getIPTablesWaitFlag(utilversion.MustParseGeneric("1.8.7"))
+	if expected := []string{"-w", "10", "-W", "50000"}; len(flag) != 4 || flag[1] != expected[1] || flag[3] != expected[3] {
+		t.Errorf("expected %v, got %v", expected, flag)
+	}
+}
+
+func TestRecordLockContention(t *testing.T) {
+	waited := testutil.ToFloat64(LockContentionTotal.WithLabelValues(lockWaited))
+	timeout := testutil.ToFloat64(LockContentionTotal.WithLabelValues(lockTimeout))
+
+	recordLockContention([]byte("ok"), nil)
+	recordLockContention([]byte("Another app is currently holding the xtables lock; still 4s 900000us time ahead to have a chance to grab the lock..."), nil)
+	recordLockContention([]byte("Another app is currently holding the xtables lock. Stopped waiting after 5s."), errors.New("exit status 4"))
+
+	if got := testutil.ToFloat64(LockContentionTotal.WithLabelValues(lockWaited)) - waited; got != 1 {
+		t.Errorf("expected 1 wait, got %v", got)
+	}
+	if got := testutil.ToFloat64(LockContentionTotal.WithLabelValues(lockTimeout)) - timeout; got != 1 {
+		t.Errorf("expected 1 timeout, got %v", got)
+	}
+}
+
+func TestLockManager(t *testing.T) {
+	defer func(f string) { LockFile = f }(LockFile)
+	LockFile = filepath.Join(t.TempDir(), "kpng.lock")
+
+	l := &lockManager{}
+
+	wg := sync.WaitGroup{}
+	mu := sync.Mutex{}
+	running := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := l.lock()
+			defer unlock()
+
+			mu.Lock()
+			running++
+			if running != 1 {
+				t.Error("concurrent calls")
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+}
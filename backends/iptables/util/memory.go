@@ -0,0 +1,270 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// builtinTargets are the targets of the rules that are not chains.
+var builtinTargets = map[string]bool{
+	"ACCEPT": true, "DROP": true, "REJECT": true, "RETURN": true,
+	"MARK": true, "MASQUERADE": true, "DNAT": true, "SNAT": true,
+	"NOTRACK": true, "DSCP": true,
+}
+
+// Memory is an in-memory Interface, failing like iptables-restore on invalid
+// chains and jumps. It's the kernel of the tests, and of kpng dev.
+type Memory struct {
+	protocol Protocol
+	// Tables are the rules of the chains, without their "-A chain".
+	Tables map[Table]MemoryTable
+}
+
+// MemoryTable are the rules of the chains of a table.
+type MemoryTable map[Chain][][]string
+
+var _ Interface = &Memory{}
+
+// NewMemory returns a Memory with the builtin chains of the filter, nat, mangle and raw tables.
+func NewMemory(protocol Protocol) *Memory {
+	return &Memory{
+		protocol: protocol,
+		Tables: map[Table]MemoryTable{
+			TableFilter: {ChainInput: nil, ChainForward: nil, ChainOutput: nil},
+			TableNAT:    {ChainPrerouting: nil, ChainInput: nil, ChainOutput: nil, ChainPostrouting: nil},
+			TableMangle: {ChainPrerouting: nil, ChainInput: nil, ChainForward: nil, ChainOutput: nil, ChainPostrouting: nil},
+			TableRaw:    {ChainPrerouting: nil, ChainOutput: nil},
+		},
+	}
+}
+
+func (k *Memory) EnsureChain(table Table, chain Chain) (bool, error) {
+	if _, ok := k.Tables[table][chain]; ok {
+		return true, nil
+	}
+	k.Tables[table][chain] = nil
+	return false, nil
+}
+
+func (k *Memory) FlushChain(table Table, chain Chain) error {
+	if _, ok := k.Tables[table][chain]; !ok {
+		return fmt.Errorf("no chain %s in table %s", chain, table)
+	}
+	k.Tables[table][chain] = nil
+	return nil
+}
+
+func (k *Memory) DeleteChain(table Table, chain Chain) error {
+	if _, ok := k.Tables[table][chain]; !ok {
+		return fmt.Errorf("no chain %s in table %s", chain, table)
+	}
+	delete(k.Tables[table], chain)
+	return nil
+}
+
+func (k *Memory) ChainExists(table Table, chain Chain) (bool, error) {
+	_, ok := k.Tables[table][chain]
+	return ok, nil
+}
+
+func (k *Memory) EnsureRule(position RulePosition, table Table, chain Chain, args ...string) (bool, error) {
+	rules, ok := k.Tables[table][chain]
+	if !ok {
+		return false, fmt.Errorf("no chain %s in table %s", chain, table)
+	}
+
+	for _, rule := range rules {
+		if strings.Join(rule, " ") == strings.Join(args, " ") {
+			return true, nil
+		}
+	}
+
+	if position == Prepend {
+		k.Tables[table][chain] = append([][]string{args}, rules...)
+	} else {
+		k.Tables[table][chain] = append(rules, args)
+	}
+	return false, nil
+}
+
+func (k *Memory) EnsureRules(rules []RuleSpec) (added int, err error) {
+	for _, rule := range rules {
+		if _, ok := k.Tables[rule.Table][rule.Chain]; !ok {
+			return 0, fmt.Errorf("no chain %s in table %s", rule.Chain, rule.Table)
+		}
+	}
+	for _, rule := range rules {
+		if existed, _ := k.EnsureRule(Append, rule.Table, rule.Chain, rule.Args...); !existed {
+			added++
+		}
+	}
+	return
+}
+
+func (k *Memory) DeleteRule(table Table, chain Chain, args ...string) error {
+	rules := k.Tables[table][chain]
+	for i, rule := range rules {
+		if strings.Join(rule, " ") == strings.Join(args, " ") {
+			k.Tables[table][chain] = append(rules[:i:i], rules[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (k *Memory) IsIPv6() bool { return k.protocol == ProtocolIPv6 }
+
+func (k *Memory) Protocol() Protocol { return k.protocol }
+
+// SaveInto writes the table like iptables-save, the chains sorted by name.
+func (k *Memory) SaveInto(table Table, buffer *bytes.Buffer) error {
+	chains := make([]string, 0, len(k.Tables[table]))
+	for chain := range k.Tables[table] {
+		chains = append(chains, string(chain))
+	}
+	sort.Strings(chains)
+
+	fmt.Fprintf(buffer, "*%s\n", table)
+	for _, chain := range chains {
+		fmt.Fprintln(buffer, MakeChainLine(Chain(chain)))
+	}
+	for _, chain := range chains {
+		for _, rule := range k.Tables[table][Chain(chain)] {
+			fmt.Fprintf(buffer, "-A %s", chain)
+			for _, arg := range rule {
+				if strings.Contains(arg, " ") {
+					arg = strconv.Quote(arg)
+				}
+				fmt.Fprint(buffer, " ", arg)
+			}
+			fmt.Fprintln(buffer)
+		}
+	}
+	fmt.Fprintln(buffer, "COMMIT")
+	return nil
+}
+
+func (k *Memory) Restore(table Table, data []byte, flush FlushFlag, counters RestoreCountersFlag) error {
+	return k.RestoreAll(append([]byte("*"+string(table)+"\n"), data...), flush, counters)
+}
+
+// RestoreAll applies the data like iptables-restore --noflush: each table is
+// committed atomically, and the declared chains are flushed.
+func (k *Memory) RestoreAll(data []byte, _ FlushFlag, _ RestoreCountersFlag) error {
+	var (
+		tableName Table
+		table     MemoryTable
+		declared  map[Chain]bool
+	)
+
+	for n, line := range strings.Split(string(data), "\n") {
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("line %d: %s", n+1, fmt.Sprintf(format, args...))
+		}
+
+		args := SplitRestoreLine(line)
+		if len(args) == 0 || strings.HasPrefix(args[0], "#") {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(args[0], "*"):
+			tableName = Table(args[0][1:])
+			if _, ok := k.Tables[tableName]; !ok {
+				return fail("no table %s", tableName)
+			}
+
+			table = MemoryTable{}
+			for chain, rules := range k.Tables[tableName] {
+				table[chain] = rules
+			}
+			declared = map[Chain]bool{}
+
+		case table == nil:
+			return fail("%q out of a table", line)
+
+		case strings.HasPrefix(args[0], ":"):
+			chain := Chain(args[0][1:])
+			if declared[chain] {
+				return fail("chain %s declared twice", chain)
+			}
+			declared[chain] = true
+			table[chain] = nil
+
+		case args[0] == "-A" && len(args) > 1:
+			chain := Chain(args[1])
+			if _, ok := table[chain]; !ok {
+				return fail("no chain %s", chain)
+			}
+			table[chain] = append(table[chain], args[2:])
+
+		case args[0] == "-X" && len(args) > 1:
+			chain := Chain(args[1])
+			if _, ok := table[chain]; !ok {
+				return fail("no chain %s", chain)
+			}
+			delete(table, chain)
+
+		case args[0] == "COMMIT":
+			if err := table.check(); err != nil {
+				return fail("%s: %v", tableName, err)
+			}
+			k.Tables[tableName] = table
+			table = nil
+
+		default:
+			return fail("unexpected %q", line)
+		}
+	}
+
+	if table != nil {
+		return fmt.Errorf("table %s not committed", tableName)
+	}
+	return nil
+}
+
+// check checks the targets of the rules are builtin or existing chains.
+func (t MemoryTable) check() error {
+	for chain, rules := range t {
+		for _, rule := range rules {
+			for i, arg := range rule {
+				if arg != "-j" || i+1 == len(rule) {
+					continue
+				}
+
+				target := rule[i+1]
+				if _, ok := t[Chain(target)]; !ok && !builtinTargets[target] {
+					return fmt.Errorf("chain %s jumps to the missing chain %s", chain, target)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (k *Memory) Monitor(canary Chain, tables []Table, reloadFunc func(), interval time.Duration, stopCh <-chan struct{}) {
+}
+
+func (k *Memory) HasRandomFully() bool { return true }
+
+func (k *Memory) Present() bool { return true }
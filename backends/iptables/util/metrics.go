@@ -0,0 +1,61 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	lockWaited  = "waited"
+	lockTimeout = "timeout"
+)
+
+var (
+	// LockWaitDuration is the time spent waiting for the other iptables calls of kpng.
+	LockWaitDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "kpng_iptables_lock_wait_seconds",
+		Help:    "Time spent waiting for the other iptables calls of kpng to finish",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 15),
+	})
+
+	// LockContentionTotal is the number of iptables calls that found the xtables lock held by
+	// another process, by result (waited or timeout).
+	LockContentionTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kpng_iptables_lock_contention_total",
+		Help: "The total number of iptables calls that found the xtables lock held by another process",
+	}, []string{"result"})
+
+	// LocalAddresses is the number of addresses of the node, as last listed.
+	LocalAddresses = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kpng_local_addresses",
+		Help: "The number of addresses of the node's interfaces, as last listed",
+	})
+)
+
+var registerMetricsOnce sync.Once
+
+// RegisterMetrics registers the xtables lock and local addresses metrics.
+func RegisterMetrics() {
+	registerMetricsOnce.Do(func() {
+		prometheus.MustRegister(LockWaitDuration)
+		prometheus.MustRegister(LockContentionTotal)
+		prometheus.MustRegister(LocalAddresses)
+	})
+}
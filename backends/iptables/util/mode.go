@@ -0,0 +1,181 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/klog/v2"
+	utilexec "k8s.io/utils/exec"
+)
+
+// Mode is the way the rules are written to the kernel.
+type Mode string
+
+const (
+	// ModeAuto detects the mode from the commands available on the host.
+	ModeAuto Mode = "auto"
+	// ModeLegacy uses iptables-legacy (x_tables).
+	ModeLegacy Mode = "legacy"
+	// ModeNFT uses iptables-nft (nf_tables through the xtables compatibility layer).
+	ModeNFT Mode = "nft"
+	// ModeNFTJSON translates the rules for the JSON API of nft, on hosts without the xtables
+	// tooling (some ARM64 and s390x distributions only ship nft).
+	ModeNFTJSON Mode = "nft-json"
+	// ModeNetlink writes the rules translated like ModeNFTJSON through netlink, without running
+	// any command (the process needs CAP_NET_ADMIN).
+	ModeNetlink Mode = "netlink"
+	// ModeMissing is detected when neither iptables nor nft can be run.
+	ModeMissing Mode = "missing"
+)
+
+// RequestedMode is the mode forced by the --iptables-mode flag, ModeAuto to detect it.
+var RequestedMode = ModeAuto
+
+func (m *Mode) String() string { return string(*m) }
+func (m *Mode) Type() string   { return "mode" }
+
+func (m *Mode) Set(s string) error {
+	switch mode := Mode(s); mode {
+	case ModeAuto, ModeLegacy, ModeNFT, ModeNFTJSON, ModeNetlink:
+		*m = mode
+		return nil
+	default:
+		return fmt.Errorf("invalid mode %q (expected %s, %s, %s, %s or %s)", s, ModeAuto, ModeLegacy, ModeNFT, ModeNFTJSON, ModeNetlink)
+	}
+}
+
+const cmdNFT = "nft"
+
+// DetectMode returns the RequestedMode, or detects it if it's ModeAuto: the mode of the default
+// iptables command is used if present, then iptables-nft or iptables-legacy, then nft alone.
+func DetectMode(exec utilexec.Interface) Mode {
+	if RequestedMode != ModeAuto {
+		return RequestedMode
+	}
+
+	if out, ok := probe(exec, cmdIPTables); ok {
+		// iptables v1.8.7 (nf_tables) or iptables v1.8.7 (legacy), older versions don't tell
+		if strings.Contains(out, "nf_tables") {
+			return ModeNFT
+		}
+		return ModeLegacy
+	}
+
+	for _, mode := range []Mode{ModeNFT, ModeLegacy} {
+		if _, ok := probe(exec, cmdIPTables+"-"+string(mode)); ok {
+			return mode
+		}
+	}
+
+	if _, ok := probe(exec, cmdNFT); ok {
+		return ModeNFTJSON
+	}
+
+	return ModeMissing
+}
+
+// probe runs "<name> --version", returning its output and whether it succeeded. The command is
+// run instead of looked up in the PATH, since the lookup always succeeds through the privileged
+// helper.
+func probe(exec utilexec.Interface, name string) (string, bool) {
+	out, err := exec.Command(name, "--version").CombinedOutput()
+	if err != nil {
+		klog.V(2).Infof("%s is not usable: %v", name, err)
+		return "", false
+	}
+	return string(out), true
+}
+
+// binaries are the iptables commands run for a protocol.
+type binaries struct {
+	iptables string
+	save     string
+	restore  string
+}
+
+// binariesFor returns the commands of protocol, suffixed by variant ("", "-legacy" or "-nft").
+func binariesFor(protocol Protocol, variant string) binaries {
+	bins := binaries{iptables: cmdIPTables, save: cmdIPTablesSave, restore: cmdIPTablesRestore}
+	if protocol == ProtocolIPv6 {
+		bins = binaries{iptables: cmdIP6Tables, save: cmdIP6TablesSave, restore: cmdIP6TablesRestore}
+	}
+
+	if variant != "" {
+		// iptables-save becomes iptables-nft-save
+		bins.save = strings.Replace(bins.save, "-", variant+"-", 1)
+		bins.restore = strings.Replace(bins.restore, "-", variant+"-", 1)
+		bins.iptables += variant
+	}
+	return bins
+}
+
+// binariesForMode returns the commands of protocol in the given mode (not ModeNFTJSON or
+// ModeNetlink). The commands of the mode (like iptables-nft) are preferred to the default ones
+// when present.
+func binariesForMode(exec utilexec.Interface, protocol Protocol, mode Mode) binaries {
+	switch mode {
+	case ModeLegacy, ModeNFT:
+		variant := "-" + string(mode)
+		if _, ok := probe(exec, binariesFor(protocol, variant).iptables); ok {
+			return binariesFor(protocol, variant)
+		}
+	}
+
+	return binariesFor(protocol, "")
+}
+
+// NewForMode returns an Interface writing the rules of protocol in the given mode.
+func NewForMode(exec utilexec.Interface, protocol Protocol, mode Mode) Interface {
+	switch mode {
+	case ModeNFTJSON:
+		return newNFTRunner(exec, protocol)
+	case ModeNetlink:
+		return newNFTNetlinkRunner(protocol)
+	}
+
+	return newInternal(exec, protocol, binariesForMode(exec, protocol, mode), "", "")
+}
+
+// CheckCommands returns the commands writing the rules of protocol in the given mode, and an
+// error if any of them can't be run. ModeNetlink runs no command, the error tells whether the
+// tables can be listed.
+func CheckCommands(exec utilexec.Interface, protocol Protocol, mode Mode) (cmds []string, err error) {
+	if mode == ModeNetlink {
+		_, err = newNFTNetlink(protocol).list("tables")
+		return
+	}
+
+	if mode == ModeNFTJSON {
+		cmds = []string{cmdNFT}
+	} else {
+		bins := binariesForMode(exec, protocol, mode)
+		cmds = []string{bins.iptables, bins.save, bins.restore}
+	}
+
+	failed := []string{}
+	for _, cmd := range cmds {
+		if _, ok := probe(exec, cmd); !ok {
+			failed = append(failed, cmd)
+		}
+	}
+	if len(failed) != 0 {
+		err = fmt.Errorf("can't run %s", strings.Join(failed, ", "))
+	}
+	return
+}
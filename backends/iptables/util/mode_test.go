@@ -0,0 +1,125 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+
+	"k8s.io/utils/exec"
+	exectesting "k8s.io/utils/exec/testing"
+)
+
+// hostExec fakes a host where only the commands in versions can be run.
+func hostExec(versions map[string]string) *exectesting.FakeExec {
+	fexec := &exectesting.FakeExec{}
+	for i := 0; i < 10; i++ {
+		fexec.CommandScript = append(fexec.CommandScript, func(cmd string, args ...string) exec.Cmd {
+			out, ok := versions[cmd]
+			return exectesting.InitFakeCmd(&exectesting.FakeCmd{
+				CombinedOutputScript: []exectesting.FakeAction{func() ([]byte, []byte, error) {
+					if !ok {
+						return nil, nil, exectesting.FakeExitError{Status: 127}
+					}
+					return []byte(out), nil, nil
+				}},
+			}, cmd, args...)
+		})
+	}
+	return fexec
+}
+
+func TestDetectMode(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		versions map[string]string
+		expected Mode
+	}{
+		{"iptables-nft", map[string]string{"iptables": "iptables v1.8.7 (nf_tables)"}, ModeNFT},
+		{"iptables-legacy", map[string]string{"iptables": "iptables v1.8.7 (legacy)"}, ModeLegacy},
+		{"old iptables", map[string]string{"iptables": "iptables v1.6.1"}, ModeLegacy},
+		{"no default", map[string]string{"iptables-legacy": "iptables v1.8.7 (legacy)", "nft": "nftables v1.0.2"}, ModeLegacy},
+		{"nft only", map[string]string{"nft": "nftables v1.0.2"}, ModeNFTJSON},
+		{"nothing", map[string]string{}, ModeMissing},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if mode := DetectMode(hostExec(tc.versions)); mode != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, mode)
+			}
+		})
+	}
+}
+
+func TestRequestedMode(t *testing.T) {
+	defer func(m Mode) { RequestedMode = m }(RequestedMode)
+
+	if err := RequestedMode.Set("xtables"); err == nil {
+		t.Error("expected an invalid mode to be rejected")
+	}
+	if err := RequestedMode.Set("nft-json"); err != nil {
+		t.Fatal(err)
+	}
+
+	if mode := DetectMode(hostExec(map[string]string{"iptables": "iptables v1.8.7 (legacy)"})); mode != ModeNFTJSON {
+		t.Errorf("expected the requested mode, got %s", mode)
+	}
+}
+
+func TestNewForMode(t *testing.T) {
+	fexec := hostExec(map[string]string{
+		"iptables":      "iptables v1.8.7 (legacy)",
+		"ip6tables":     "ip6tables v1.8.7 (legacy)",
+		"ip6tables-nft": "ip6tables v1.8.7 (nf_tables)",
+	})
+
+	ipt := NewForMode(fexec, ProtocolIPv6, ModeNFT).(*runner)
+	if ipt.bins.iptables != "ip6tables-nft" || ipt.bins.save != "ip6tables-nft-save" || ipt.bins.restore != "ip6tables-nft-restore" {
+		t.Errorf("expected the ip6tables-nft commands, got %+v", ipt.bins)
+	}
+
+	ipt = NewForMode(fexec, ProtocolIPv4, ModeLegacy).(*runner)
+	if ipt.bins.iptables != "iptables" || ipt.bins.save != "iptables-save" {
+		t.Errorf("expected the default commands, got %+v", ipt.bins)
+	}
+
+	if _, ok := NewForMode(fexec, ProtocolIPv4, ModeNFTJSON).(*nftRunner); !ok {
+		t.Error("expected the nft runner")
+	}
+
+	if nft, ok := NewForMode(fexec, ProtocolIPv6, ModeNetlink).(*nftRunner); !ok {
+		t.Error("expected the nft runner")
+	} else if _, ok := nft.transport.(*nftNetlink); !ok {
+		t.Errorf("expected the netlink transport, got %T", nft.transport)
+	}
+}
+
+func TestCheckCommands(t *testing.T) {
+	fexec := hostExec(map[string]string{
+		"iptables-nft":         "iptables v1.8.7 (nf_tables)",
+		"iptables-nft-save":    "iptables-save v1.8.7 (nf_tables)",
+		"iptables-nft-restore": "iptables-restore v1.8.7 (nf_tables)",
+		"ip6tables-nft":        "ip6tables v1.8.7 (nf_tables)",
+	})
+
+	cmds, err := CheckCommands(fexec, ProtocolIPv4, ModeNFT)
+	if err != nil || len(cmds) != 3 || cmds[2] != "iptables-nft-restore" {
+		t.Errorf("expected the iptables-nft commands, got %v (err: %v)", cmds, err)
+	}
+
+	if _, err := CheckCommands(fexec, ProtocolIPv6, ModeNFT); err == nil || err.Error() != "can't run ip6tables-nft-save, ip6tables-nft-restore" {
+		t.Errorf("expected the missing ip6tables commands, got %v", err)
+	}
+}
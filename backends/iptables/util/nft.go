@@ -0,0 +1,389 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+	utilexec "k8s.io/utils/exec"
+	utiltrace "k8s.io/utils/trace"
+)
+
+// nftRunner implements Interface with the JSON API of nft, for hosts without the xtables
+// commands. The rules are translated to nft expressions in tables named like the iptables ones,
+// in the ip or ip6 family. The "-m recent" lists become dynamic sets, which are never deleted.
+type nftRunner struct {
+	mu         sync.Mutex
+	protocol   Protocol
+	translator *nftTranslator
+	transport  nftTransport
+}
+
+// nftTransport sends the commands of nftRunner to the kernel.
+type nftTransport interface {
+	// run runs the commands in one transaction.
+	run(cmds []any) error
+	// list returns the objects listed by "nft -j list <args>".
+	list(args ...string) ([]nftListed, error)
+}
+
+func newNFTRunner(exec utilexec.Interface, protocol Protocol) Interface {
+	return &nftRunner{protocol: protocol, translator: newNFTTranslator(protocol), transport: &nftExec{exec: exec}}
+}
+
+func newNFTNetlinkRunner(protocol Protocol) Interface {
+	return &nftRunner{protocol: protocol, translator: newNFTTranslator(protocol), transport: newNFTNetlink(protocol)}
+}
+
+// nftListed is an object listed by nft -j list.
+type nftListed struct {
+	Chain *nftListedChain `json:"chain"`
+	Rule  *nftListedRule  `json:"rule"`
+}
+
+type nftListedChain struct {
+	Name string `json:"name"`
+}
+
+type nftListedRule struct {
+	Handle  int    `json:"handle"`
+	Comment string `json:"comment"`
+}
+
+// run runs the commands in one transaction.
+func (r *nftRunner) run(cmds ...any) error {
+	unlock := xtablesLock.lock()
+	defer unlock()
+
+	return r.transport.run(cmds)
+}
+
+// list returns the objects listed by "nft -j list <args>".
+func (r *nftRunner) list(args ...string) ([]nftListed, error) {
+	return r.transport.list(args...)
+}
+
+// nftExec runs the nft command.
+type nftExec struct {
+	exec utilexec.Interface
+}
+
+func (n *nftExec) run(cmds []any) error {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false) // keep the & and < operators readable in the logs
+	if err := enc.Encode(nftObj{"nftables": cmds}); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+
+	klog.V(5).Infof("running nft: %s", data)
+	cmd := n.exec.Command(cmdNFT, "-j", "-f", "-")
+	cmd.SetStdin(bytes.NewReader(data))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return &Error{Err: err, Output: out}
+	}
+	return nil
+}
+
+func (n *nftExec) list(args ...string) ([]nftListed, error) {
+	out, err := n.exec.Command(cmdNFT, append([]string{"-j", "list"}, args...)...).CombinedOutput()
+	if err != nil {
+		return nil, &Error{Err: err, Output: out}
+	}
+
+	res := struct {
+		Nftables []nftListed `json:"nftables"`
+	}{}
+	if err := json.Unmarshal(out, &res); err != nil {
+		return nil, fmt.Errorf("invalid nft output: %v", err)
+	}
+	return res.Nftables, nil
+}
+
+// chainObjects returns the objects listed in chain, or none if the chain doesn't exist.
+func (r *nftRunner) chainObjects(table Table, chain Chain) ([]nftListed, error) {
+	objs, err := r.list("chain", r.translator.family, string(table), string(chain))
+	if err != nil && IsNotFoundError(err) {
+		return nil, nil
+	}
+	return objs, err
+}
+
+// findRule returns the handle of the rule translated from args in chain, or 0 if absent.
+func (r *nftRunner) findRule(table Table, chain Chain, args []string) (int, error) {
+	objs, err := r.chainObjects(table, chain)
+	if err != nil {
+		return 0, err
+	}
+	return findTagged(objs, nftRuleTag(args)), nil
+}
+
+// findTagged returns the handle of the rule tagged with tag in objs, or 0 if absent.
+func findTagged(objs []nftListed, tag string) int {
+	for _, obj := range objs {
+		if obj.Rule != nil && strings.HasSuffix(obj.Rule.Comment, tag) {
+			return obj.Rule.Handle
+		}
+	}
+	return 0
+}
+
+// EnsureChain is part of Interface.
+func (r *nftRunner) EnsureChain(table Table, chain Chain) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	exists, _ := r.chainExists(table, chain)
+	if exists {
+		return true, nil
+	}
+	return false, r.run(r.translator.chainCommands(table, chain, "")...)
+}
+
+// FlushChain is part of Interface.
+func (r *nftRunner) FlushChain(table Table, chain Chain) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.run(nftObj{"flush": r.translator.chainRef(table, chain)})
+}
+
+// DeleteChain is part of Interface.
+func (r *nftRunner) DeleteChain(table Table, chain Chain) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.run(nftObj{"delete": r.translator.chainRef(table, chain)})
+}
+
+// ChainExists is part of Interface.
+func (r *nftRunner) ChainExists(table Table, chain Chain) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.chainExists(table, chain)
+}
+
+func (r *nftRunner) chainExists(table Table, chain Chain) (bool, error) {
+	_, err := r.list("chain", r.translator.family, string(table), string(chain))
+	return err == nil, err
+}
+
+// EnsureRule is part of Interface.
+func (r *nftRunner) EnsureRule(position RulePosition, table Table, chain Chain, args ...string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rule, err := r.translator.rule(args)
+	if err != nil {
+		return false, err
+	}
+	sets := r.translator.setCommands(table)
+
+	handle, err := r.findRule(table, chain, args)
+	if err != nil {
+		return false, err
+	}
+	if handle != 0 {
+		return true, nil
+	}
+
+	verb := "add"
+	if position == Prepend {
+		verb = "insert"
+	}
+
+	cmds := r.translator.chainCommands(table, chain, "")
+	cmds = append(cmds, sets...)
+	cmds = append(cmds, nftObj{verb: rule.object(r.translator.family, table, chain)})
+
+	if err := r.run(cmds...); err != nil {
+		return false, fmt.Errorf("error appending rule: %v", err)
+	}
+	return false, nil
+}
+
+// EnsureRules is part of Interface. Each chain is listed once, and the missing rules are added
+// in one transaction.
+func (r *nftRunner) EnsureRules(rules []RuleSpec) (int, error) {
+	if len(rules) == 0 {
+		return 0, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	type tableChain struct {
+		table Table
+		chain Chain
+	}
+
+	listed := map[tableChain][]nftListed{}
+	added := map[tableChain]map[string]bool{}
+	chainCmds := []any{}
+	addCmds := []any{}
+	tables := map[Table]bool{}
+
+	for _, spec := range rules {
+		rule, err := r.translator.rule(spec.Args)
+		if err != nil {
+			return 0, err
+		}
+
+		key := tableChain{spec.Table, spec.Chain}
+		objs, ok := listed[key]
+		if !ok {
+			objs, err = r.chainObjects(spec.Table, spec.Chain)
+			if err != nil {
+				return 0, err
+			}
+			listed[key] = objs
+			chainCmds = append(chainCmds, r.translator.chainCommands(spec.Table, spec.Chain, "")...)
+		}
+
+		tag := nftRuleTag(spec.Args)
+		if findTagged(objs, tag) != 0 || added[key][tag] {
+			continue
+		}
+
+		if added[key] == nil {
+			added[key] = map[string]bool{}
+		}
+		added[key][tag] = true
+
+		tables[spec.Table] = true
+		addCmds = append(addCmds, nftObj{"add": rule.object(r.translator.family, spec.Table, spec.Chain)})
+	}
+
+	if len(addCmds) == 0 {
+		return 0, nil
+	}
+
+	cmds := chainCmds
+	for _, table := range rulesTables(rules) {
+		if tables[table] {
+			cmds = append(cmds, r.translator.setCommands(table)...)
+		}
+	}
+	cmds = append(cmds, addCmds...)
+
+	if err := r.run(cmds...); err != nil {
+		return 0, fmt.Errorf("error appending rules: %v", err)
+	}
+	return len(addCmds), nil
+}
+
+// DeleteRule is part of Interface.
+func (r *nftRunner) DeleteRule(table Table, chain Chain, args ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	handle, err := r.findRule(table, chain, args)
+	if err != nil {
+		return err
+	}
+	if handle == 0 {
+		return nil
+	}
+
+	rule := nftObj{"family": r.translator.family, "table": string(table), "chain": string(chain), "handle": handle}
+	if err := r.run(nftObj{"delete": nftObj{"rule": rule}}); err != nil {
+		return fmt.Errorf("error deleting rule: %v", err)
+	}
+	return nil
+}
+
+func (r *nftRunner) IsIPv6() bool {
+	return r.protocol == ProtocolIPv6
+}
+
+func (r *nftRunner) Protocol() Protocol {
+	return r.protocol
+}
+
+// SaveInto is part of Interface. Only the chains are written, as the rules can't be translated
+// back to iptables.
+func (r *nftRunner) SaveInto(table Table, buffer *bytes.Buffer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	objs, err := r.list("table", r.translator.family, string(table))
+	if err != nil && !IsNotFoundError(err) {
+		return err
+	}
+
+	fmt.Fprintf(buffer, "*%s\n", table)
+	for _, obj := range objs {
+		if obj.Chain != nil {
+			fmt.Fprintln(buffer, MakeChainLine(Chain(obj.Chain.Name)))
+		}
+	}
+	fmt.Fprintln(buffer, "COMMIT")
+
+	return nil
+}
+
+// Restore is part of Interface.
+func (r *nftRunner) Restore(table Table, data []byte, flush FlushFlag, counters RestoreCountersFlag) error {
+	return r.restore(table, data, flush)
+}
+
+// RestoreAll is part of Interface.
+func (r *nftRunner) RestoreAll(data []byte, flush FlushFlag, counters RestoreCountersFlag) error {
+	return r.restore("", data, flush)
+}
+
+// restore is the shared part of Restore/RestoreAll. The counters are not supported.
+func (r *nftRunner) restore(table Table, data []byte, flush FlushFlag) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	trace := utiltrace.New("nft restore")
+	defer trace.LogIfLong(2 * time.Second)
+
+	cmds, err := r.translator.restore(data, flush, table)
+	if err != nil {
+		return err
+	}
+	trace.Step("rules translated")
+
+	return r.run(cmds...)
+}
+
+// Monitor is part of Interface
+func (r *nftRunner) Monitor(canary Chain, tables []Table, reloadFunc func(), interval time.Duration, stopCh <-chan struct{}) {
+	monitor(r, canary, tables, reloadFunc, interval, stopCh)
+}
+
+// HasRandomFully is part of Interface. nft always supports it.
+func (r *nftRunner) HasRandomFully() bool {
+	return true
+}
+
+// Present is part of Interface.
+func (r *nftRunner) Present() bool {
+	_, err := r.list("tables")
+	return err == nil
+}
@@ -0,0 +1,830 @@
+//go:build linux
+// +build linux
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+)
+
+// nftNetlink sends the commands of nftRunner to the kernel through netlink, compiling the JSON
+// objects of the translator to nftables expressions like nft does, so the nft command is not
+// needed.
+type nftNetlink struct {
+	family nftables.TableFamily
+}
+
+func newNFTNetlink(protocol Protocol) nftTransport {
+	if protocol == ProtocolIPv6 {
+		return &nftNetlink{family: nftables.TableFamilyIPv6}
+	}
+	return &nftNetlink{family: nftables.TableFamilyIPv4}
+}
+
+// nftNetlinkRegister holds the loaded keys, nftNetlinkDataRegister the port of dnat.
+const (
+	nftNetlinkRegister     = 1
+	nftNetlinkDataRegister = 2
+)
+
+const (
+	// nftCommentType is the type of the comment in the user data of a rule
+	// (NFTNL_UDATA_RULE_COMMENT).
+	nftCommentType = 0
+	// nftPortUnreachable and nftPortUnreachable6 are the icmp codes of REJECT.
+	nftPortUnreachable  = 3
+	nftPortUnreachable6 = 4
+)
+
+var (
+	nftHooks = map[string]*nftables.ChainHook{
+		"prerouting":  nftables.ChainHookPrerouting,
+		"input":       nftables.ChainHookInput,
+		"forward":     nftables.ChainHookForward,
+		"output":      nftables.ChainHookOutput,
+		"postrouting": nftables.ChainHookPostrouting,
+	}
+
+	nftL4Protos = map[string]byte{
+		"tcp": unix.IPPROTO_TCP, "udp": unix.IPPROTO_UDP, "sctp": unix.IPPROTO_SCTP,
+		"icmp": unix.IPPROTO_ICMP, "icmpv6": unix.IPPROTO_ICMPV6,
+	}
+
+	// nftCtStates are the bits of the ct state key.
+	nftCtStates = map[string]uint32{
+		"invalid": 1, "established": 2, "related": 4, "new": 8, "untracked": 64,
+	}
+
+	// nftCtStatuses are the bits of the ct status key (IPS_SRC_NAT and IPS_DST_NAT).
+	nftCtStatuses = map[string]uint32{"snat": 0x10, "dnat": 0x20}
+
+	nftAddrTypes = map[string]uint32{
+		"unspec": unix.RTN_UNSPEC, "unicast": unix.RTN_UNICAST, "local": unix.RTN_LOCAL,
+		"broadcast": unix.RTN_BROADCAST, "anycast": unix.RTN_ANYCAST, "multicast": unix.RTN_MULTICAST,
+		"blackhole": unix.RTN_BLACKHOLE, "unreachable": unix.RTN_UNREACHABLE, "prohibit": unix.RTN_PROHIBIT,
+	}
+
+	nftCmpOps = map[string]expr.CmpOp{"==": expr.CmpOpEq, "!=": expr.CmpOpNeq, "<": expr.CmpOpLt}
+)
+
+func (n *nftNetlink) run(cmds []any) error {
+	conn, err := nftables.New()
+	if err != nil {
+		return &Error{Op: "netlink", Err: err}
+	}
+
+	for _, cmd := range cmds {
+		if err := n.queue(conn, cmd); err != nil {
+			return err
+		}
+	}
+
+	if err := conn.Flush(); err != nil {
+		return &Error{Op: "netlink", Err: err}
+	}
+	return nil
+}
+
+// queue adds a command to the transaction of conn.
+func (n *nftNetlink) queue(conn *nftables.Conn, cmd any) error {
+	verb, obj, err := nftSingle(cmd)
+	if err != nil {
+		return err
+	}
+	kind, fields, err := nftSingle(obj)
+	if err != nil {
+		return err
+	}
+	f, ok := fields.(nftObj)
+	if !ok {
+		return fmt.Errorf("invalid %s %s: %v", verb, kind, fields)
+	}
+
+	table := &nftables.Table{Family: n.family, Name: nftString(f["table"])}
+
+	switch verb + " " + kind {
+	case "add table":
+		conn.AddTable(&nftables.Table{Family: n.family, Name: nftString(f["name"])})
+	case "flush table":
+		conn.FlushTable(&nftables.Table{Family: n.family, Name: nftString(f["name"])})
+
+	case "add chain":
+		chain, err := n.chain(table, f)
+		if err != nil {
+			return err
+		}
+		conn.AddChain(chain)
+	case "flush chain":
+		conn.FlushChain(&nftables.Chain{Table: table, Name: nftString(f["name"])})
+	case "delete chain":
+		conn.DelChain(&nftables.Chain{Table: table, Name: nftString(f["name"])})
+
+	case "add set":
+		keyType := nftables.TypeIPAddr
+		if n.family == nftables.TableFamilyIPv6 {
+			keyType = nftables.TypeIP6Addr
+		}
+		set := &nftables.Set{Table: table, Name: nftString(f["name"]), KeyType: keyType, Dynamic: true, HasTimeout: true}
+		if err := conn.AddSet(set, nil); err != nil {
+			return &Error{Op: "netlink", Err: err}
+		}
+
+	case "add rule", "insert rule":
+		rule, err := n.rule(table, f)
+		if err != nil {
+			return err
+		}
+		if verb == "insert" {
+			conn.InsertRule(rule)
+		} else {
+			conn.AddRule(rule)
+		}
+	case "delete rule":
+		handle, err := nftUint(f["handle"])
+		if err != nil {
+			return err
+		}
+		chain := &nftables.Chain{Table: table, Name: nftString(f["chain"])}
+		if err := conn.DelRule(&nftables.Rule{Table: table, Chain: chain, Handle: handle}); err != nil {
+			return &Error{Op: "netlink", Err: err}
+		}
+
+	default:
+		return fmt.Errorf("command %s %s is not supported by netlink", verb, kind)
+	}
+	return nil
+}
+
+// chain returns the chain object f, with its hook if it's a base chain.
+func (n *nftNetlink) chain(table *nftables.Table, f nftObj) (*nftables.Chain, error) {
+	chain := &nftables.Chain{Table: table, Name: nftString(f["name"])}
+	if _, ok := f["hook"]; !ok {
+		return chain, nil
+	}
+
+	hook, ok := nftHooks[nftString(f["hook"])]
+	if !ok {
+		return nil, fmt.Errorf("unknown hook %v", f["hook"])
+	}
+	prio, ok := f["prio"].(int)
+	if !ok {
+		return nil, fmt.Errorf("invalid priority %v", f["prio"])
+	}
+	policy := nftables.ChainPolicyAccept
+	if f["policy"] == "drop" {
+		policy = nftables.ChainPolicyDrop
+	}
+
+	chain.Type = nftables.ChainType(nftString(f["type"]))
+	chain.Hooknum = hook
+	chain.Priority = nftables.ChainPriorityRef(nftables.ChainPriority(prio))
+	chain.Policy = &policy
+	return chain, nil
+}
+
+// rule compiles the rule object f.
+func (n *nftNetlink) rule(table *nftables.Table, f nftObj) (*nftables.Rule, error) {
+	rule := &nftables.Rule{
+		Table: table,
+		Chain: &nftables.Chain{Table: table, Name: nftString(f["chain"])},
+	}
+
+	stmts, _ := f["expr"].([]any)
+	for _, stmt := range stmts {
+		exprs, err := n.statement(stmt)
+		if err != nil {
+			return nil, fmt.Errorf("rule in chain %s: %v", rule.Chain.Name, err)
+		}
+		rule.Exprs = append(rule.Exprs, exprs...)
+	}
+
+	if comment := nftString(f["comment"]); comment != "" {
+		rule.UserData = nftUserDataComment(comment)
+	}
+	return rule, nil
+}
+
+// statement compiles a statement of a rule.
+func (n *nftNetlink) statement(stmt any) ([]expr.Any, error) {
+	kind, v, err := nftSingle(stmt)
+	if err != nil {
+		return nil, err
+	}
+	f, _ := v.(nftObj)
+
+	switch kind {
+	case "match":
+		return n.match(nftString(f["op"]), f["left"], f["right"])
+
+	case "accept":
+		return []expr.Any{&expr.Verdict{Kind: expr.VerdictAccept}}, nil
+	case "drop":
+		return []expr.Any{&expr.Verdict{Kind: expr.VerdictDrop}}, nil
+	case "return":
+		return []expr.Any{&expr.Verdict{Kind: expr.VerdictReturn}}, nil
+	case "jump":
+		return []expr.Any{&expr.Verdict{Kind: expr.VerdictJump, Chain: nftString(f["target"])}}, nil
+
+	case "reject":
+		// the icmp port unreachable of iptables' REJECT
+		code := uint8(nftPortUnreachable)
+		if n.family == nftables.TableFamilyIPv6 {
+			code = nftPortUnreachable6
+		}
+		return []expr.Any{&expr.Reject{Type: unix.NFT_REJECT_ICMP_UNREACH, Code: code}}, nil
+
+	case "notrack":
+		return []expr.Any{&expr.Notrack{}}, nil
+
+	case "masquerade":
+		flags, _ := f["flags"].([]string)
+		return []expr.Any{&expr.Masq{FullyRandom: len(flags) != 0 && flags[0] == "fully-random"}}, nil
+
+	case "mangle":
+		if _, ok := f["key"].(nftObj)["payload"]; ok {
+			return n.mangleDSCP(f["value"])
+		}
+		return n.mangleMark(f["value"])
+
+	case "dnat":
+		return n.dnat(f)
+
+	case "set":
+		return n.setUpdate(f)
+
+	default:
+		return nil, fmt.Errorf("statement %s is not supported by netlink", kind)
+	}
+}
+
+// nftKey is a key loaded in nftNetlinkRegister.
+type nftKey struct {
+	load []expr.Any
+	// encode returns the value compared to the key
+	encode func(v any) ([]byte, error)
+	// bitmask keys match the values having any of their bits set
+	bitmask bool
+	// bigEndian keys are converted to network order for the "<" comparisons
+	bigEndian bool
+}
+
+// match compiles a match of left with right.
+func (n *nftNetlink) match(op string, left, right any) ([]expr.Any, error) {
+	key, err := n.key(left)
+	if err != nil {
+		return nil, err
+	}
+	exprs := key.load
+
+	// lookup in a set
+	if name, ok := right.(string); ok && strings.HasPrefix(name, "@") {
+		lookup := &expr.Lookup{SourceRegister: nftNetlinkRegister, SetName: name[1:], Invert: op == "!="}
+		return append(exprs, lookup), nil
+	}
+
+	if key.bitmask {
+		mask, err := key.encode(right)
+		if err != nil {
+			return nil, err
+		}
+		cmpOp := expr.CmpOpNeq
+		if op == "!=" {
+			cmpOp = expr.CmpOpEq
+		}
+		return append(exprs,
+			&expr.Bitwise{SourceRegister: nftNetlinkRegister, DestRegister: nftNetlinkRegister, Len: uint32(len(mask)), Mask: mask, Xor: make([]byte, len(mask))},
+			&expr.Cmp{Op: cmpOp, Register: nftNetlinkRegister, Data: make([]byte, len(mask))},
+		), nil
+	}
+
+	cmpOp, ok := nftCmpOps[op]
+	if !ok {
+		return nil, fmt.Errorf("operator %s is not supported by netlink", op)
+	}
+
+	if obj, ok := right.(nftObj); ok {
+		prefix, ok := obj["prefix"].(nftObj)
+		if !ok {
+			return nil, fmt.Errorf("value %v is not supported by netlink", right)
+		}
+		length, _ := prefix["len"].(int)
+		addr, err := key.encode(prefix["addr"])
+		if err != nil {
+			return nil, err
+		}
+		mask := net.CIDRMask(length, len(addr)*8)
+		return append(exprs,
+			&expr.Bitwise{SourceRegister: nftNetlinkRegister, DestRegister: nftNetlinkRegister, Len: uint32(len(addr)), Mask: mask, Xor: make([]byte, len(addr))},
+			&expr.Cmp{Op: cmpOp, Register: nftNetlinkRegister, Data: addr},
+		), nil
+	}
+
+	data, err := key.encode(right)
+	if err != nil {
+		return nil, err
+	}
+	if cmpOp == expr.CmpOpLt && !key.bigEndian {
+		// the registers are compared as bytes
+		exprs = append(exprs, &expr.Byteorder{SourceRegister: nftNetlinkRegister, DestRegister: nftNetlinkRegister, Op: expr.ByteorderHton, Len: uint32(len(data)), Size: uint32(len(data))})
+		data, err = nftBigEndian(right, len(data))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return append(exprs, &expr.Cmp{Op: cmpOp, Register: nftNetlinkRegister, Data: data}), nil
+}
+
+// key returns the expressions loading left.
+func (n *nftNetlink) key(left any) (nftKey, error) {
+	kind, v, err := nftSingle(left)
+	if err != nil {
+		return nftKey{}, err
+	}
+
+	switch kind {
+	case "meta":
+		return n.metaKey(nftString(v.(nftObj)["key"]))
+
+	case "payload":
+		return n.payloadKey(v.(nftObj))
+
+	case "ct":
+		if key := nftString(v.(nftObj)["key"]); key != "state" {
+			return nftKey{}, fmt.Errorf("ct %s is not supported by netlink", key)
+		}
+		return nftKey{
+			load:    []expr.Any{&expr.Ct{Register: nftNetlinkRegister, Key: expr.CtKeySTATE}},
+			encode:  nftBits(nftCtStates),
+			bitmask: true,
+		}, nil
+
+	case "&":
+		return n.maskedKey(v)
+
+	case "fib":
+		fib := &expr.Fib{Register: nftNetlinkRegister, ResultADDRTYPE: true}
+		flags, _ := v.(nftObj)["flags"].([]string)
+		for _, flag := range flags {
+			switch flag {
+			case "saddr":
+				fib.FlagSADDR = true
+			case "daddr":
+				fib.FlagDADDR = true
+			}
+		}
+		return nftKey{load: []expr.Any{fib}, encode: func(v any) ([]byte, error) {
+			typ, ok := nftAddrTypes[nftString(v)]
+			if !ok {
+				return nil, fmt.Errorf("unknown address type %v", v)
+			}
+			return binaryutil.NativeEndian.PutUint32(typ), nil
+		}}, nil
+
+	case "numgen":
+		mod, err := nftUint(v.(nftObj)["mod"])
+		if err != nil {
+			return nftKey{}, err
+		}
+		numgen := &expr.Numgen{Register: nftNetlinkRegister, Modulus: uint32(mod), Type: unix.NFT_NG_RANDOM}
+		return nftKey{load: []expr.Any{numgen}, encode: nftNative32}, nil
+
+	default:
+		return nftKey{}, fmt.Errorf("expression %s is not supported by netlink", kind)
+	}
+}
+
+func (n *nftNetlink) metaKey(key string) (nftKey, error) {
+	load := func(key expr.MetaKey) []expr.Any {
+		return []expr.Any{&expr.Meta{Key: key, Register: nftNetlinkRegister}}
+	}
+
+	switch key {
+	case "l4proto":
+		return nftKey{load: load(expr.MetaKeyL4PROTO), encode: func(v any) ([]byte, error) {
+			proto, ok := nftL4Protos[nftString(v)]
+			if !ok {
+				return nil, fmt.Errorf("unknown protocol %v", v)
+			}
+			return []byte{proto}, nil
+		}}, nil
+
+	case "iifname", "oifname":
+		metaKey := expr.MetaKeyIIFNAME
+		if key == "oifname" {
+			metaKey = expr.MetaKeyOIFNAME
+		}
+		return nftKey{load: load(metaKey), encode: func(v any) ([]byte, error) {
+			name := nftString(v)
+			if strings.HasSuffix(name, "+") {
+				// the interfaces prefixed by name
+				return []byte(strings.TrimSuffix(name, "+")), nil
+			}
+			return append([]byte(name), 0), nil
+		}}, nil
+
+	case "mark":
+		return nftKey{load: load(expr.MetaKeyMARK), encode: nftNative32}, nil
+
+	default:
+		return nftKey{}, fmt.Errorf("meta %s is not supported by netlink", key)
+	}
+}
+
+func (n *nftNetlink) payloadKey(f nftObj) (nftKey, error) {
+	var (
+		base   = expr.PayloadBaseNetworkHeader
+		offset uint32
+		length uint32
+		encode func(v any) ([]byte, error)
+	)
+
+	switch protocol, field := nftString(f["protocol"]), nftString(f["field"]); protocol + " " + field {
+	case "ip saddr", "ip daddr":
+		offset, length, encode = 12, 4, nftIPv4
+		if field == "daddr" {
+			offset = 16
+		}
+	case "ip6 saddr", "ip6 daddr":
+		offset, length, encode = 8, 16, nftIPv6
+		if field == "daddr" {
+			offset = 24
+		}
+	case "tcp sport", "udp sport", "sctp sport", "tcp dport", "udp dport", "sctp dport":
+		base, length, encode = expr.PayloadBaseTransportHeader, 2, nftPort
+		if field == "dport" {
+			offset = 2
+		}
+	default:
+		return nftKey{}, fmt.Errorf("payload %s %s is not supported by netlink", protocol, field)
+	}
+
+	payload := &expr.Payload{DestRegister: nftNetlinkRegister, Base: base, Offset: offset, Len: length}
+	return nftKey{load: []expr.Any{payload}, encode: encode, bigEndian: true}, nil
+}
+
+// maskedKey returns the key of {"&": [key, mask]}, a ct status or a meta mark.
+func (n *nftNetlink) maskedKey(v any) (nftKey, error) {
+	operands, ok := v.([]any)
+	if !ok || len(operands) != 2 {
+		return nftKey{}, fmt.Errorf("invalid & operands %v", v)
+	}
+
+	var mask []byte
+	load := []expr.Any{}
+
+	kind, key, err := nftSingle(operands[0])
+	if err != nil {
+		return nftKey{}, err
+	}
+	switch kind + " " + nftString(key.(nftObj)["key"]) {
+	case "ct status":
+		status, ok := nftCtStatuses[nftString(operands[1])]
+		if !ok {
+			return nftKey{}, fmt.Errorf("unknown ct status %v", operands[1])
+		}
+		load = append(load, &expr.Ct{Register: nftNetlinkRegister, Key: expr.CtKeySTATUS})
+		mask = binaryutil.NativeEndian.PutUint32(status)
+	case "meta mark":
+		load = append(load, &expr.Meta{Key: expr.MetaKeyMARK, Register: nftNetlinkRegister})
+		if mask, err = nftNative32(operands[1]); err != nil {
+			return nftKey{}, err
+		}
+	default:
+		return nftKey{}, fmt.Errorf("& of %s is not supported by netlink", kind)
+	}
+
+	load = append(load, &expr.Bitwise{SourceRegister: nftNetlinkRegister, DestRegister: nftNetlinkRegister, Len: 4, Mask: mask, Xor: make([]byte, 4)})
+	return nftKey{load: load, encode: nftNative32}, nil
+}
+
+// mangleDSCP compiles the value of "ip dscp set" (or "ip6 dscp set"), rewriting the DSCP bits of
+// the header: the second byte of IPv4 (and its checksum), the bits 4 to 9 of IPv6.
+func (n *nftNetlink) mangleDSCP(value any) ([]expr.Any, error) {
+	dscp, err := nftUint(value)
+	if err != nil {
+		return nil, err
+	}
+	if dscp > 63 {
+		return nil, fmt.Errorf("invalid DSCP %d", dscp)
+	}
+
+	load := &expr.Payload{DestRegister: nftNetlinkRegister, Base: expr.PayloadBaseNetworkHeader}
+	bitwise := &expr.Bitwise{SourceRegister: nftNetlinkRegister, DestRegister: nftNetlinkRegister}
+	write := &expr.Payload{OperationType: expr.PayloadWrite, SourceRegister: nftNetlinkRegister, Base: expr.PayloadBaseNetworkHeader}
+
+	if n.family == nftables.TableFamilyIPv6 {
+		load.Len, write.Len, bitwise.Len = 2, 2, 2
+		bitwise.Mask = binaryutil.BigEndian.PutUint16(0xf03f)
+		bitwise.Xor = binaryutil.BigEndian.PutUint16(uint16(dscp) << 6)
+	} else {
+		load.Offset, write.Offset = 1, 1
+		load.Len, write.Len, bitwise.Len = 1, 1, 1
+		bitwise.Mask = []byte{0x03}
+		bitwise.Xor = []byte{byte(dscp) << 2}
+		write.CsumType, write.CsumOffset = expr.CsumTypeInet, 10
+	}
+
+	return []expr.Any{load, bitwise, write}, nil
+}
+
+// mangleMark compiles the value of "meta mark set", {"|": [meta mark, v]} or {"^": [meta mark, v]}.
+func (n *nftNetlink) mangleMark(value any) ([]expr.Any, error) {
+	op, v, err := nftSingle(value)
+	if err != nil {
+		return nil, err
+	}
+	operands, ok := v.([]any)
+	if !ok || len(operands) != 2 {
+		return nil, fmt.Errorf("invalid %s operands %v", op, v)
+	}
+	mark, err := nftUint(operands[1])
+	if err != nil {
+		return nil, err
+	}
+
+	// the bitwise expression computes (reg & mask) ^ xor
+	mask := uint32(0xffffffff)
+	switch op {
+	case "|":
+		mask = ^uint32(mark)
+	case "^":
+	default:
+		return nil, fmt.Errorf("mark operator %s is not supported by netlink", op)
+	}
+
+	return []expr.Any{
+		&expr.Meta{Key: expr.MetaKeyMARK, Register: nftNetlinkRegister},
+		&expr.Bitwise{
+			SourceRegister: nftNetlinkRegister,
+			DestRegister:   nftNetlinkRegister,
+			Len:            4,
+			Mask:           binaryutil.NativeEndian.PutUint32(mask),
+			Xor:            binaryutil.NativeEndian.PutUint32(uint32(mark)),
+		},
+		&expr.Meta{Key: expr.MetaKeyMARK, SourceRegister: true, Register: nftNetlinkRegister},
+	}, nil
+}
+
+func (n *nftNetlink) dnat(f nftObj) ([]expr.Any, error) {
+	encodeAddr := nftIPv4
+	if n.family == nftables.TableFamilyIPv6 {
+		encodeAddr = nftIPv6
+	}
+	addr, err := encodeAddr(f["addr"])
+	if err != nil {
+		return nil, err
+	}
+
+	exprs := []expr.Any{&expr.Immediate{Register: nftNetlinkRegister, Data: addr}}
+	nat := &expr.NAT{Type: expr.NATTypeDestNAT, Family: uint32(n.family), RegAddrMin: nftNetlinkRegister}
+
+	if port, ok := f["port"]; ok {
+		data, err := nftPort(port)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, &expr.Immediate{Register: nftNetlinkDataRegister, Data: data})
+		nat.RegProtoMin = nftNetlinkDataRegister
+	}
+
+	return append(exprs, nat), nil
+}
+
+// setUpdate compiles the update of a set with the source address, written for "-m recent --set".
+func (n *nftNetlink) setUpdate(f nftObj) ([]expr.Any, error) {
+	if op := nftString(f["op"]); op != "update" {
+		return nil, fmt.Errorf("set %s is not supported by netlink", op)
+	}
+	elem, _ := f["elem"].(nftObj)
+	elem, _ = elem["elem"].(nftObj)
+	timeout, err := nftUint(elem["timeout"])
+	if err != nil {
+		return nil, err
+	}
+	key, err := n.key(elem["val"])
+	if err != nil {
+		return nil, err
+	}
+
+	return append(key.load, &expr.Dynset{
+		SrcRegKey: nftNetlinkRegister,
+		SetName:   strings.TrimPrefix(nftString(f["set"]), "@"),
+		Operation: unix.NFT_DYNSET_OP_UPDATE,
+		Timeout:   time.Duration(timeout) * time.Second,
+	}), nil
+}
+
+// list returns the objects like "nft -j list", only the chains and the handle and comment of the
+// rules being set. The missing tables and chains fail with ENOENT, like in nft.
+func (n *nftNetlink) list(args ...string) ([]nftListed, error) {
+	conn, err := nftables.New()
+	if err != nil {
+		return nil, &Error{Op: "netlink", Err: err}
+	}
+
+	tables, err := conn.ListTablesOfFamily(n.family)
+	if err != nil {
+		return nil, &Error{Op: "netlink", Err: err}
+	}
+	if args[0] == "tables" {
+		return nil, nil
+	}
+
+	if len(args) < 3 {
+		return nil, fmt.Errorf("invalid list %s", strings.Join(args, " "))
+	}
+	table := nftLookupTable(tables, args[2])
+	if table == nil {
+		return nil, &Error{Op: "netlink: table " + args[2], Err: syscall.ENOENT}
+	}
+
+	allChains, err := conn.ListChainsOfTableFamily(n.family)
+	if err != nil {
+		return nil, &Error{Op: "netlink", Err: err}
+	}
+	chains := []*nftables.Chain{}
+	for _, chain := range allChains {
+		if chain.Table.Name == table.Name {
+			chains = append(chains, chain)
+		}
+	}
+	sort.Slice(chains, func(i, j int) bool { return chains[i].Name < chains[j].Name })
+
+	if args[0] == "table" {
+		objs := make([]nftListed, 0, len(chains))
+		for _, chain := range chains {
+			objs = append(objs, nftListed{Chain: &nftListedChain{Name: chain.Name}})
+		}
+		return objs, nil
+	}
+
+	if len(args) < 4 {
+		return nil, fmt.Errorf("invalid list %s", strings.Join(args, " "))
+	}
+	var chain *nftables.Chain
+	for _, c := range chains {
+		if c.Name == args[3] {
+			chain = c
+		}
+	}
+	if chain == nil {
+		return nil, &Error{Op: "netlink: chain " + args[3], Err: syscall.ENOENT}
+	}
+
+	rules, err := conn.GetRules(table, chain)
+	if err != nil {
+		return nil, &Error{Op: "netlink", Err: err}
+	}
+	objs := []nftListed{{Chain: &nftListedChain{Name: chain.Name}}}
+	for _, rule := range rules {
+		objs = append(objs, nftListed{Rule: &nftListedRule{Handle: int(rule.Handle), Comment: nftUserDataRuleComment(rule.UserData)}})
+	}
+	return objs, nil
+}
+
+func nftLookupTable(tables []*nftables.Table, name string) *nftables.Table {
+	for _, table := range tables {
+		if table.Name == name {
+			return table
+		}
+	}
+	return nil
+}
+
+// nftUserDataComment returns the user data of a rule with comment, a type-length-value
+// attribute of libnftnl.
+func nftUserDataComment(comment string) []byte {
+	if len(comment) > nftCommentMaxLen-1 {
+		comment = comment[:nftCommentMaxLen-1]
+	}
+	data := []byte{nftCommentType, byte(len(comment) + 1)}
+	data = append(data, comment...)
+	return append(data, 0)
+}
+
+// nftUserDataRuleComment returns the comment in the user data of a rule.
+func nftUserDataRuleComment(data []byte) string {
+	for len(data) >= 2 {
+		typ, length := data[0], int(data[1])
+		if len(data) < 2+length {
+			break
+		}
+		if typ == nftCommentType {
+			return strings.TrimRight(string(data[2:2+length]), "\x00")
+		}
+		data = data[2+length:]
+	}
+	return ""
+}
+
+// nftSingle returns the key and value of an object with a single key, like {"accept": null}.
+func nftSingle(v any) (string, any, error) {
+	obj, ok := v.(nftObj)
+	if !ok || len(obj) != 1 {
+		return "", nil, fmt.Errorf("invalid object %v", v)
+	}
+	for key, value := range obj {
+		return key, value, nil
+	}
+	panic("unreachable")
+}
+
+func nftString(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+func nftUint(v any) (uint64, error) {
+	switch n := v.(type) {
+	case int:
+		return uint64(n), nil
+	case uint64:
+		return n, nil
+	default:
+		return 0, fmt.Errorf("invalid number %v", v)
+	}
+}
+
+// nftBits returns the encoder of the bitmask of the names in bits, from a name or a list.
+func nftBits(bits map[string]uint32) func(v any) ([]byte, error) {
+	return func(v any) ([]byte, error) {
+		names, ok := v.([]string)
+		if !ok {
+			names = []string{nftString(v)}
+		}
+		mask := uint32(0)
+		for _, name := range names {
+			bit, ok := bits[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown flag %q", name)
+			}
+			mask |= bit
+		}
+		return binaryutil.NativeEndian.PutUint32(mask), nil
+	}
+}
+
+func nftNative32(v any) ([]byte, error) {
+	n, err := nftUint(v)
+	if err != nil {
+		return nil, err
+	}
+	return binaryutil.NativeEndian.PutUint32(uint32(n)), nil
+}
+
+func nftBigEndian(v any, size int) ([]byte, error) {
+	n, err := nftUint(v)
+	if err != nil {
+		return nil, err
+	}
+	if size == 2 {
+		return binaryutil.BigEndian.PutUint16(uint16(n)), nil
+	}
+	return binaryutil.BigEndian.PutUint32(uint32(n)), nil
+}
+
+func nftPort(v any) ([]byte, error) {
+	return nftBigEndian(v, 2)
+}
+
+func nftIPv4(v any) ([]byte, error) {
+	ip := net.ParseIP(nftString(v)).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IPv4 address %v", v)
+	}
+	return ip, nil
+}
+
+func nftIPv6(v any) ([]byte, error) {
+	ip := net.ParseIP(nftString(v))
+	if ip == nil || ip.To4() != nil {
+		return nil, fmt.Errorf("invalid IPv6 address %v", v)
+	}
+	return ip.To16(), nil
+}
@@ -0,0 +1,158 @@
+//go:build linux
+// +build linux
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+)
+
+func TestNFTNetlinkRule(t *testing.T) {
+	tr := newNFTTranslator(ProtocolIPv4)
+	n := newNFTNetlink(ProtocolIPv4).(*nftNetlink)
+	table := &nftables.Table{Family: n.family, Name: "nat"}
+
+	for _, tc := range []struct {
+		args     string
+		expected string
+	}{
+		{"-m tcp -p tcp -d 10.0.0.1/32 --dport 80 -j KUBE-SVC-X", "meta cmp payload cmp payload cmp verdict"},
+		{"! -s 10.244.0.0/16 -j KUBE-MARK-MASQ", "payload bitwise cmp verdict"},
+		{"-m mark ! --mark 0x4000/0x4000 -j RETURN", "meta bitwise cmp verdict"},
+		{"-j MARK --xor-mark 0x4000", "meta bitwise meta"},
+		{"-m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT", "ct bitwise cmp verdict"},
+		{"-m conntrack ! --ctstate RELATED,ESTABLISHED,DNAT -j DROP", "ct bitwise cmp ct bitwise cmp verdict"},
+		{"-m addrtype --dst-type LOCAL -j REJECT", "fib cmp reject"},
+		{"-m statistic --mode random --probability 0.5000000000 -j KUBE-SEP-A", "numgen byteorder cmp verdict"},
+		{"-m tcp -p tcp -j DNAT --to-destination 10.244.1.2:8080", "meta cmp immediate immediate nat"},
+		{"-s 169.254.20.10 -p udp -m udp --sport 53 -j NOTRACK", "payload cmp meta cmp payload cmp notrack"},
+		{"-i cni+ -j MASQUERADE --random-fully", "meta cmp masq"},
+		{"-m recent --name KUBE-SEP-A --rcheck --seconds 300 --reap -j KUBE-SEP-A", "payload lookup verdict"},
+		{"-m recent --name KUBE-SEP-A --set -j KUBE-SEP-A", "payload dynset verdict"},
+		{"-m recent --name KUBE-NOTRACK-CLIENTS --rdest --rcheck --seconds 180 -j RETURN", "payload lookup verdict"},
+		{"-d 10.96.0.10/32 -p udp -m udp --dport 53 -j DSCP --set-dscp 46", "payload cmp meta cmp payload cmp payload bitwise payload"},
+	} {
+		t.Run(tc.args, func(t *testing.T) {
+			translated, err := tr.rule(strings.Fields(tc.args))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rule, err := n.rule(table, translated.object("ip", TableNAT, "KUBE-SERVICES")["rule"].(nftObj))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			kinds := []string{}
+			for _, e := range rule.Exprs {
+				kinds = append(kinds, strings.ToLower(strings.TrimPrefix(fmt.Sprintf("%T", e), "*expr.")))
+			}
+			if got := strings.Join(kinds, " "); got != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, got)
+			}
+
+			if comment := nftUserDataRuleComment(rule.UserData); comment != translated.comment {
+				t.Errorf("expected the comment %q, got %q", translated.comment, comment)
+			}
+		})
+	}
+}
+
+func TestNFTNetlinkMatchValues(t *testing.T) {
+	n := newNFTNetlink(ProtocolIPv4).(*nftNetlink)
+
+	// the 10.244.0.0/16 prefix masks the address before comparing the network
+	exprs, err := n.match("==", nftPayload("ip", "saddr"), nftObj{"prefix": nftObj{"addr": "10.244.0.0", "len": 16}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mask := exprs[1].(*expr.Bitwise).Mask; fmt.Sprint(mask) != "[255 255 0 0]" {
+		t.Errorf("unexpected mask %v", mask)
+	}
+	if data := exprs[2].(*expr.Cmp).Data; fmt.Sprint(data) != "[10 244 0 0]" {
+		t.Errorf("unexpected network %v", data)
+	}
+
+	// the interfaces prefixed by cni
+	exprs, err = n.match("==", nftMeta("iifname"), "cni+")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := exprs[1].(*expr.Cmp).Data; string(data) != "cni" {
+		t.Errorf("expected the cni prefix, got %q", data)
+	}
+
+	// the ports are in network order
+	exprs, err = n.match("!=", nftPayload("tcp", "dport"), 8080)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmp := exprs[1].(*expr.Cmp); cmp.Op != expr.CmpOpNeq || fmt.Sprint(cmp.Data) != "[31 144]" {
+		t.Errorf("unexpected comparison %+v", cmp)
+	}
+
+	if _, err := n.match("==", nftMeta("skuid"), 0); err == nil {
+		t.Error("expected an unsupported key to fail")
+	}
+}
+
+func TestNFTNetlinkRestore(t *testing.T) {
+	tr := newNFTTranslator(ProtocolIPv6)
+	n := newNFTNetlink(ProtocolIPv6).(*nftNetlink)
+
+	data := `*nat
+:KUBE-SVC-X - [0:0]
+:KUBE-SEP-A - [0:0]
+-A KUBE-SVC-X -m recent --name KUBE-SEP-A --rcheck --seconds 300 --reap -j KUBE-SEP-A
+-A KUBE-SEP-A -m recent --name KUBE-SEP-A --set -m tcp -p tcp -j DNAT --to-destination [fd00::1]:8080
+COMMIT
+`
+
+	cmds, err := tr.restore([]byte(data), NoFlushTables, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cmd := range cmds {
+		for verb, obj := range cmd.(nftObj) {
+			rule, ok := obj.(nftObj)["rule"].(nftObj)
+			if verb != "add" || !ok {
+				continue
+			}
+			if _, err := n.rule(&nftables.Table{Family: n.family, Name: "nat"}, rule); err != nil {
+				t.Errorf("%s: %v", toJSON(t, rule), err)
+			}
+		}
+	}
+}
+
+func TestNFTUserDataComment(t *testing.T) {
+	comment := strings.Repeat("x", 200)
+	if got := nftUserDataRuleComment(nftUserDataComment(comment)); got != comment[:nftCommentMaxLen-1] {
+		t.Errorf("expected the truncated comment, got %q", got)
+	}
+
+	if got := nftUserDataRuleComment([]byte{1, 2, 'a', 0, nftCommentType, 3, 'a', 'b', 0}); got != "ab" {
+		t.Errorf("expected the comment after another attribute, got %q", got)
+	}
+}
@@ -0,0 +1,39 @@
+//go:build !linux
+// +build !linux
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import "errors"
+
+var errNFTNetlinkUnsupported = errors.New("the netlink iptables mode is only supported on Linux")
+
+// nftNetlinkUnsupported fails all the calls of the netlink mode.
+type nftNetlinkUnsupported struct{}
+
+func newNFTNetlink(_ Protocol) nftTransport {
+	return nftNetlinkUnsupported{}
+}
+
+func (nftNetlinkUnsupported) run(_ []any) error {
+	return errNFTNetlinkUnsupported
+}
+
+func (nftNetlinkUnsupported) list(_ ...string) ([]nftListed, error) {
+	return nil, errNFTNetlinkUnsupported
+}
@@ -0,0 +1,664 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// nftObj is an object of the nft JSON API (see libnftables-json(5)).
+type nftObj = map[string]any
+
+// nftHook is the hook of a base chain.
+type nftHook struct {
+	typ  string
+	hook string
+	prio int
+}
+
+// nftBaseChains are the hooks of the builtin iptables chains, created as base chains with the
+// priorities used by iptables-nft.
+var nftBaseChains = map[Table]map[Chain]nftHook{
+	TableFilter: {
+		ChainInput:   {"filter", "input", 0},
+		ChainForward: {"filter", "forward", 0},
+		ChainOutput:  {"filter", "output", 0},
+	},
+	TableNAT: {
+		ChainPrerouting:  {"nat", "prerouting", -100},
+		ChainInput:       {"nat", "input", 100},
+		ChainOutput:      {"nat", "output", -100},
+		ChainPostrouting: {"nat", "postrouting", 100},
+	},
+	TableMangle: {
+		ChainPrerouting:  {"filter", "prerouting", -150},
+		ChainInput:       {"filter", "input", -150},
+		ChainForward:     {"filter", "forward", -150},
+		ChainOutput:      {"route", "output", -150},
+		ChainPostrouting: {"filter", "postrouting", -150},
+	},
+	TableRaw: {
+		ChainPrerouting: {"filter", "prerouting", -300},
+		ChainOutput:     {"filter", "output", -300},
+	},
+}
+
+const (
+	// nftCommentMaxLen is the maximum length of a rule comment in nft.
+	nftCommentMaxLen = 128
+	// nftNumgenMod is the modulus of the random numbers replacing "-m statistic --mode random".
+	nftNumgenMod = 1 << 31
+	// nftRecentDefaultTimeout is the timeout of the "-m recent --set" entries when no
+	// "--rcheck --seconds" is found for the list (the default session affinity timeout).
+	nftRecentDefaultTimeout = 10800
+)
+
+// nftModules are the iptables match modules known by the translator.
+var nftModules = map[string]bool{
+	"comment": true, "conntrack": true, "mark": true, "addrtype": true, "statistic": true,
+	"recent": true, "tcp": true, "udp": true, "sctp": true,
+}
+
+// nftTranslator translates iptables rules to nft expressions for a protocol.
+type nftTranslator struct {
+	family string
+	// recentTimeouts are the "--seconds" of the "-m recent" lists, by name
+	recentTimeouts map[string]int
+	// sets are the "-m recent" lists used by the rules translated since the last setCommands
+	sets map[string]bool
+}
+
+func newNFTTranslator(protocol Protocol) *nftTranslator {
+	family := "ip"
+	if protocol == ProtocolIPv6 {
+		family = "ip6"
+	}
+	return &nftTranslator{family: family, recentTimeouts: map[string]int{}, sets: map[string]bool{}}
+}
+
+// nftRule is a translated rule.
+type nftRule struct {
+	expr    []any
+	comment string
+}
+
+// object returns the nft rule object in the given chain.
+func (r nftRule) object(family string, table Table, chain Chain) nftObj {
+	return nftObj{"rule": nftObj{
+		"family":  family,
+		"table":   string(table),
+		"chain":   string(chain),
+		"expr":    r.expr,
+		"comment": r.comment,
+	}}
+}
+
+// nftRuleTag identifies the rule translated from args in its comment, allowing EnsureRule and
+// DeleteRule to find it back since nft doesn't list the expressions as they were written.
+func nftRuleTag(args []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(args, " ")))
+	return fmt.Sprintf("[kpng:%x]", sum[:8])
+}
+
+func nftMatch(op string, left, right any) nftObj {
+	return nftObj{"match": nftObj{"op": op, "left": left, "right": right}}
+}
+
+func nftPayload(protocol, field string) nftObj {
+	return nftObj{"payload": nftObj{"protocol": protocol, "field": field}}
+}
+
+func nftMeta(key string) nftObj {
+	return nftObj{"meta": nftObj{"key": key}}
+}
+
+// nftAddr returns an address or a prefix.
+func nftAddr(s string) (any, error) {
+	if !strings.Contains(s, "/") {
+		if net.ParseIP(s) == nil {
+			return nil, fmt.Errorf("invalid address %q", s)
+		}
+		return s, nil
+	}
+
+	ip, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, err
+	}
+	ones, bits := ipNet.Mask.Size()
+	if ones == bits {
+		return ip.String(), nil
+	}
+	return nftObj{"prefix": nftObj{"addr": ipNet.IP.String(), "len": ones}}, nil
+}
+
+// nftMark parses a mark with an optional mask ("0x4000/0x4000").
+func nftMark(s string) (value, mask uint64, hasMask bool, err error) {
+	v, m, hasMask := strings.Cut(s, "/")
+	if value, err = strconv.ParseUint(v, 0, 32); err != nil {
+		return
+	}
+	if hasMask {
+		mask, err = strconv.ParseUint(m, 0, 32)
+	}
+	return
+}
+
+// collectRecentTimeouts records the "--seconds" of the "-m recent" lists of the given rules, so
+// the entries added by "--set" get the timeout checked by "--rcheck".
+func (t *nftTranslator) collectRecentTimeouts(args []string) {
+	name := ""
+	for i := 0; i+1 < len(args); i++ {
+		switch args[i] {
+		case "--name":
+			name = args[i+1]
+		case "--seconds":
+			if seconds, err := strconv.Atoi(args[i+1]); err == nil && name != "" {
+				t.recentTimeouts[name] = seconds
+			}
+		}
+	}
+}
+
+// setCommands returns the commands declaring the sets used by the rules translated since the
+// last call.
+func (t *nftTranslator) setCommands(table Table) (cmds []any) {
+	typ := "ipv4_addr"
+	if t.family == "ip6" {
+		typ = "ipv6_addr"
+	}
+
+	names := make([]string, 0, len(t.sets))
+	for name := range t.sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		cmds = append(cmds, nftObj{"add": nftObj{"set": nftObj{
+			"family": t.family,
+			"table":  string(table),
+			"name":   name,
+			"type":   typ,
+			"flags":  []string{"dynamic", "timeout"},
+		}}})
+	}
+
+	t.sets = map[string]bool{}
+	return
+}
+
+// rule translates the arguments of an iptables rule (without the chain). Only the matches and
+// targets written by the iptables backend are supported.
+func (t *nftTranslator) rule(args []string) (rule nftRule, err error) {
+	var (
+		negate      bool
+		l4proto     string
+		recent      string
+		comment     string
+		target      string
+		targetArgs  = map[string]string{}
+		randomFully bool
+	)
+
+	// the address of the "-m recent" lists, the source unless "--rdest" is given before
+	// "--set" or "--rcheck"
+	recentAddr := nftPayload(t.family, "saddr")
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "!" {
+			negate = true
+			continue
+		}
+
+		op := "=="
+		if negate {
+			op = "!="
+		}
+		negate = false
+
+		// options without value
+		switch arg {
+		case "--set":
+			timeout, ok := t.recentTimeouts[recent]
+			if !ok {
+				timeout = nftRecentDefaultTimeout
+			}
+			t.sets[recent] = true
+			rule.expr = append(rule.expr, nftObj{"set": nftObj{
+				"op":   "update",
+				"elem": nftObj{"elem": nftObj{"val": recentAddr, "timeout": timeout}},
+				"set":  "@" + recent,
+			}})
+			continue
+		case "--rcheck":
+			t.sets[recent] = true
+			rule.expr = append(rule.expr, nftMatch(op, recentAddr, "@"+recent))
+			continue
+		case "--rsource":
+			recentAddr = nftPayload(t.family, "saddr")
+			continue
+		case "--rdest":
+			recentAddr = nftPayload(t.family, "daddr")
+			continue
+		case "--reap":
+			// the set entries expire by themselves
+			continue
+		case "--random-fully":
+			randomFully = true
+			continue
+		}
+
+		if i+1 >= len(args) {
+			return rule, fmt.Errorf("missing value for %s", arg)
+		}
+		i++
+		value := args[i]
+
+		if target != "" {
+			targetArgs[arg] = value
+			continue
+		}
+
+		switch arg {
+		case "-m":
+			if !nftModules[value] {
+				return rule, fmt.Errorf("match %q is not supported by the nft JSON fallback", value)
+			}
+
+		case "--comment":
+			comment = strings.Trim(value, `"`)
+
+		case "-p":
+			l4proto = value
+			rule.expr = append(rule.expr, nftMatch(op, nftMeta("l4proto"), value))
+
+		case "--dport", "--sport":
+			if l4proto == "" {
+				return rule, fmt.Errorf("%s without protocol", arg)
+			}
+			port, err := strconv.Atoi(value)
+			if err != nil {
+				return rule, fmt.Errorf("invalid port %q", value)
+			}
+			rule.expr = append(rule.expr, nftMatch(op, nftPayload(l4proto, strings.TrimPrefix(arg, "--")), port))
+
+		case "-s", "-d":
+			addr, err := nftAddr(value)
+			if err != nil {
+				return rule, err
+			}
+			field := "saddr"
+			if arg == "-d" {
+				field = "daddr"
+			}
+			rule.expr = append(rule.expr, nftMatch(op, nftPayload(t.family, field), addr))
+
+		case "-i", "-o":
+			key := "iifname"
+			if arg == "-o" {
+				key = "oifname"
+			}
+			rule.expr = append(rule.expr, nftMatch(op, nftMeta(key), value))
+
+		case "--ctstate":
+			// DNAT and SNAT are conntrack status flags in nft
+			var states, statuses []string
+			for _, state := range strings.Split(strings.ToLower(value), ",") {
+				if state == "dnat" || state == "snat" {
+					statuses = append(statuses, state)
+				} else {
+					states = append(states, state)
+				}
+			}
+			if len(states) != 0 && len(statuses) != 0 && op == "==" {
+				return rule, fmt.Errorf("--ctstate %s mixing states and statuses is not supported by the nft JSON fallback", value)
+			}
+
+			if len(states) != 0 {
+				var right any = states
+				if len(states) == 1 {
+					right = states[0]
+				} else if op == "==" {
+					op = "in"
+				}
+				rule.expr = append(rule.expr, nftMatch(op, nftObj{"ct": nftObj{"key": "state"}}, right))
+			}
+
+			// ! --ctstate A,DNAT is not A and not DNAT
+			for _, status := range statuses {
+				statusOp := "!="
+				if op != "==" && op != "in" {
+					statusOp = "=="
+				}
+				rule.expr = append(rule.expr, nftMatch(statusOp, nftObj{"&": []any{nftObj{"ct": nftObj{"key": "status"}}, status}}, 0))
+			}
+
+		case "--mark":
+			v, mask, hasMask, err := nftMark(value)
+			if err != nil {
+				return rule, fmt.Errorf("invalid mark %q: %v", value, err)
+			}
+			var left any = nftMeta("mark")
+			if hasMask {
+				left = nftObj{"&": []any{left, mask}}
+			}
+			rule.expr = append(rule.expr, nftMatch(op, left, v))
+
+		case "--src-type", "--dst-type":
+			flag := "saddr"
+			if arg == "--dst-type" {
+				flag = "daddr"
+			}
+			fib := nftObj{"fib": nftObj{"result": "type", "flags": []string{flag}}}
+			rule.expr = append(rule.expr, nftMatch(op, fib, strings.ToLower(value)))
+
+		case "--mode":
+			if value != "random" {
+				return rule, fmt.Errorf("statistic mode %q is not supported by the nft JSON fallback", value)
+			}
+
+		case "--probability":
+			p, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return rule, fmt.Errorf("invalid probability %q", value)
+			}
+			numgen := nftObj{"numgen": nftObj{"mode": "random", "mod": nftNumgenMod}}
+			rule.expr = append(rule.expr, nftMatch("<", numgen, uint64(p*nftNumgenMod)))
+
+		case "--name":
+			recent = value
+
+		case "--seconds":
+			if _, ok := t.recentTimeouts[recent]; !ok {
+				seconds, err := strconv.Atoi(value)
+				if err != nil {
+					return rule, fmt.Errorf("invalid seconds %q", value)
+				}
+				t.recentTimeouts[recent] = seconds
+			}
+
+		case "-j":
+			target = value
+
+		default:
+			return rule, fmt.Errorf("option %s is not supported by the nft JSON fallback", arg)
+		}
+	}
+
+	verdict, err := t.target(target, targetArgs, randomFully)
+	if err != nil {
+		return
+	}
+	if verdict != nil {
+		rule.expr = append(rule.expr, verdict)
+	}
+
+	rule.comment = nftRuleTag(args)
+	if comment != "" {
+		if max := nftCommentMaxLen - len(rule.comment) - 1; len(comment) > max {
+			comment = comment[:max]
+		}
+		rule.comment = comment + " " + rule.comment
+	}
+
+	return
+}
+
+// target translates the target of a rule.
+func (t *nftTranslator) target(target string, args map[string]string, randomFully bool) (any, error) {
+	switch target {
+	case "":
+		return nil, nil
+	case "ACCEPT":
+		return nftObj{"accept": nil}, nil
+	case "DROP":
+		return nftObj{"drop": nil}, nil
+	case "RETURN":
+		return nftObj{"return": nil}, nil
+	case "REJECT":
+		return nftObj{"reject": nil}, nil
+	case "NOTRACK":
+		return nftObj{"notrack": nil}, nil
+
+	case "MASQUERADE":
+		if randomFully {
+			return nftObj{"masquerade": nftObj{"flags": []string{"fully-random"}}}, nil
+		}
+		return nftObj{"masquerade": nil}, nil
+
+	case "MARK":
+		for arg, op := range map[string]string{"--or-mark": "|", "--xor-mark": "^"} {
+			value, ok := args[arg]
+			if !ok {
+				continue
+			}
+			mark, err := strconv.ParseUint(value, 0, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid mark %q", value)
+			}
+			return nftObj{"mangle": nftObj{
+				"key":   nftMeta("mark"),
+				"value": nftObj{op: []any{nftMeta("mark"), mark}},
+			}}, nil
+		}
+		return nil, fmt.Errorf("MARK target without --or-mark or --xor-mark")
+
+	case "DSCP":
+		value, ok := args["--set-dscp"]
+		if !ok {
+			return nil, fmt.Errorf("DSCP target without --set-dscp")
+		}
+		dscp, err := strconv.ParseUint(value, 0, 6)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DSCP %q", value)
+		}
+		return nftObj{"mangle": nftObj{"key": nftPayload(t.family, "dscp"), "value": dscp}}, nil
+
+	case "DNAT":
+		dest, ok := args["--to-destination"]
+		if !ok {
+			return nil, fmt.Errorf("DNAT target without --to-destination")
+		}
+		host, port, err := net.SplitHostPort(dest)
+		if err != nil {
+			// no port
+			return nftObj{"dnat": nftObj{"addr": strings.Trim(dest, "[]")}}, nil
+		}
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port in %q", dest)
+		}
+		return nftObj{"dnat": nftObj{"addr": host, "port": p}}, nil
+
+	default:
+		if _, builtin := nftBaseChains[TableNAT][Chain(target)]; builtin {
+			return nil, fmt.Errorf("target %q is not supported by the nft JSON fallback", target)
+		}
+		return nftObj{"jump": nftObj{"target": target}}, nil
+	}
+}
+
+// chainCommands returns the commands creating table and chain, as a base chain if it's a
+// builtin chain of iptables.
+func (t *nftTranslator) chainCommands(table Table, chain Chain, policy string) []any {
+	obj := nftObj{"family": t.family, "table": string(table), "name": string(chain)}
+	if hook, ok := nftBaseChains[table][chain]; ok {
+		obj["type"], obj["hook"], obj["prio"] = hook.typ, hook.hook, hook.prio
+		obj["policy"] = "accept"
+		if policy == "DROP" {
+			obj["policy"] = "drop"
+		}
+	}
+
+	return []any{
+		nftObj{"add": nftObj{"table": nftObj{"family": t.family, "name": string(table)}}},
+		nftObj{"add": nftObj{"chain": obj}},
+	}
+}
+
+func (t *nftTranslator) chainRef(table Table, chain Chain) nftObj {
+	return nftObj{"chain": nftObj{"family": t.family, "table": string(table), "name": string(chain)}}
+}
+
+// SplitRestoreLine splits a line of iptables-restore input (or iptables-save output), the double
+// quoted arguments (like comments) being kept whole and unquoted.
+func SplitRestoreLine(line string) (args []string) {
+	arg := strings.Builder{}
+	inArg, quoted := false, false
+
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inArg = true
+		case (r == ' ' || r == '\t') && !quoted:
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return
+}
+
+// restore translates iptables-restore input to nft commands. Only the tables in onlyTable are
+// restored, if not empty. If flush is set, the rules of the restored tables are flushed first.
+func (t *nftTranslator) restore(data []byte, flush FlushFlag, onlyTable Table) ([]any, error) {
+	type line struct {
+		num  int
+		args []string
+	}
+
+	// first pass: split the lines by table, and collect the recent lists
+	tables := []Table{}
+	lines := map[Table][]line{}
+
+	table := Table("")
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1024*1024)
+	for num := 1; scanner.Scan(); num++ {
+		text := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case text == "" || text[0] == '#':
+			continue
+
+		case text[0] == '*':
+			if table != "" {
+				return nil, fmt.Errorf("line %d: table %s started before the COMMIT of %s", num, text[1:], table)
+			}
+			table = Table(text[1:])
+			tables = append(tables, table)
+
+		case text == "COMMIT":
+			if table == "" {
+				return nil, fmt.Errorf("line %d: COMMIT outside of a table", num)
+			}
+			table = ""
+
+		case table == "":
+			return nil, fmt.Errorf("line %d: rule outside of a table", num)
+
+		default:
+			args := SplitRestoreLine(text)
+			t.collectRecentTimeouts(args)
+			lines[table] = append(lines[table], line{num, args})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if table != "" {
+		return nil, fmt.Errorf("missing COMMIT of table %s", table)
+	}
+
+	// second pass: translate
+	cmds := []any{}
+	for _, table := range tables {
+		if onlyTable != "" && table != onlyTable {
+			continue
+		}
+
+		tableCmds := []any{}
+		for _, l := range lines[table] {
+			args := l.args
+
+			if args[0][0] == ':' {
+				chain, policy := Chain(args[0][1:]), ""
+				if len(args) > 1 {
+					policy = args[1]
+				}
+				tableCmds = append(tableCmds, t.chainCommands(table, chain, policy)...)
+				tableCmds = append(tableCmds, nftObj{"flush": t.chainRef(table, chain)})
+				continue
+			}
+
+			if len(args) < 2 {
+				return nil, fmt.Errorf("line %d: missing chain", l.num)
+			}
+			chain := Chain(args[1])
+
+			switch args[0] {
+			case "-N":
+				tableCmds = append(tableCmds, t.chainCommands(table, chain, "")...)
+			case "-F":
+				tableCmds = append(tableCmds, nftObj{"flush": t.chainRef(table, chain)})
+			case "-X":
+				tableCmds = append(tableCmds, nftObj{"delete": t.chainRef(table, chain)})
+			case "-A", "-I":
+				rule, err := t.rule(args[2:])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", l.num, err)
+				}
+				verb := "add"
+				if args[0] == "-I" {
+					verb = "insert"
+				}
+				tableCmds = append(tableCmds, nftObj{verb: rule.object(t.family, table, chain)})
+			default:
+				return nil, fmt.Errorf("line %d: command %s is not supported by the nft JSON fallback", l.num, args[0])
+			}
+		}
+
+		cmds = append(cmds, nftObj{"add": nftObj{"table": nftObj{"family": t.family, "name": string(table)}}})
+		if flush {
+			cmds = append(cmds, nftObj{"flush": nftObj{"table": nftObj{"family": t.family, "name": string(table)}}})
+		}
+		cmds = append(cmds, t.setCommands(table)...)
+		cmds = append(cmds, tableCmds...)
+	}
+
+	return cmds, nil
+}
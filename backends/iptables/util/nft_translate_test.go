@@ -0,0 +1,201 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func toJSON(t *testing.T, v any) string {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+func TestSplitRestoreLine(t *testing.T) {
+	args := SplitRestoreLine(`-A KUBE-SERVICES -m comment --comment "default/web:http cluster IP" -j KUBE-SVC-X`)
+	expected := []string{"-A", "KUBE-SERVICES", "-m", "comment", "--comment", "default/web:http cluster IP", "-j", "KUBE-SVC-X"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %q, got %q", expected, args)
+	}
+}
+
+func TestNFTRule(t *testing.T) {
+	tr := newNFTTranslator(ProtocolIPv4)
+
+	for _, tc := range []struct {
+		args     string
+		expected string
+	}{
+		{
+			"-m tcp -p tcp -d 10.0.0.1/32 --dport 80 -j KUBE-SVC-X",
+			`[{"match":{"left":{"meta":{"key":"l4proto"}},"op":"==","right":"tcp"}},` +
+				`{"match":{"left":{"payload":{"field":"daddr","protocol":"ip"}},"op":"==","right":"10.0.0.1"}},` +
+				`{"match":{"left":{"payload":{"field":"dport","protocol":"tcp"}},"op":"==","right":80}},` +
+				`{"jump":{"target":"KUBE-SVC-X"}}]`,
+		},
+		{
+			"! -s 10.244.0.0/16 -j KUBE-MARK-MASQ",
+			`[{"match":{"left":{"payload":{"field":"saddr","protocol":"ip"}},"op":"!=","right":{"prefix":{"addr":"10.244.0.0","len":16}}}},` +
+				`{"jump":{"target":"KUBE-MARK-MASQ"}}]`,
+		},
+		{
+			"-m mark ! --mark 0x4000/0x4000 -j RETURN",
+			`[{"match":{"left":{"&":[{"meta":{"key":"mark"}},16384]},"op":"!=","right":16384}},{"return":null}]`,
+		},
+		{
+			"-j MARK --xor-mark 0x4000",
+			`[{"mangle":{"key":{"meta":{"key":"mark"}},"value":{"^":[{"meta":{"key":"mark"}},16384]}}}]`,
+		},
+		{
+			"-m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT",
+			`[{"match":{"left":{"ct":{"key":"state"}},"op":"in","right":["related","established"]}},{"accept":null}]`,
+		},
+		{
+			"-d 127.0.0.0/8 ! -s 127.0.0.0/8 -m conntrack ! --ctstate RELATED,ESTABLISHED,DNAT -j DROP",
+			`[{"match":{"left":{"payload":{"field":"daddr","protocol":"ip"}},"op":"==","right":{"prefix":{"addr":"127.0.0.0","len":8}}}},` +
+				`{"match":{"left":{"payload":{"field":"saddr","protocol":"ip"}},"op":"!=","right":{"prefix":{"addr":"127.0.0.0","len":8}}}},` +
+				`{"match":{"left":{"ct":{"key":"state"}},"op":"!=","right":["related","established"]}},` +
+				`{"match":{"left":{"&":[{"ct":{"key":"status"}},"dnat"]},"op":"==","right":0}},` +
+				`{"drop":null}]`,
+		},
+		{
+			"-m addrtype --dst-type LOCAL -j REJECT",
+			`[{"match":{"left":{"fib":{"flags":["daddr"],"result":"type"}},"op":"==","right":"local"}},{"reject":null}]`,
+		},
+		{
+			"-m statistic --mode random --probability 0.5000000000 -j KUBE-SEP-A",
+			`[{"match":{"left":{"numgen":{"mod":2147483648,"mode":"random"}},"op":"<","right":1073741824}},{"jump":{"target":"KUBE-SEP-A"}}]`,
+		},
+		{
+			"-m tcp -p tcp -j DNAT --to-destination 10.244.1.2:8080",
+			`[{"match":{"left":{"meta":{"key":"l4proto"}},"op":"==","right":"tcp"}},{"dnat":{"addr":"10.244.1.2","port":8080}}]`,
+		},
+		{
+			"-s 169.254.20.10 -p udp -m udp --sport 53 -j NOTRACK",
+			`[{"match":{"left":{"payload":{"field":"saddr","protocol":"ip"}},"op":"==","right":"169.254.20.10"}},` +
+				`{"match":{"left":{"meta":{"key":"l4proto"}},"op":"==","right":"udp"}},` +
+				`{"match":{"left":{"payload":{"field":"sport","protocol":"udp"}},"op":"==","right":53}},` +
+				`{"notrack":null}]`,
+		},
+		{
+			"-m recent --name KUBE-NOTRACK-CLIENTS --rdest --rcheck --seconds 180 -j RETURN",
+			`[{"match":{"left":{"payload":{"field":"daddr","protocol":"ip"}},"op":"==","right":"@KUBE-NOTRACK-CLIENTS"}},{"return":null}]`,
+		},
+		{
+			"-j DSCP --set-dscp 0x2e",
+			`[{"mangle":{"key":{"payload":{"field":"dscp","protocol":"ip"}},"value":46}}]`,
+		},
+		{
+			"-j MASQUERADE --random-fully",
+			`[{"masquerade":{"flags":["fully-random"]}}]`,
+		},
+	} {
+		t.Run(tc.args, func(t *testing.T) {
+			rule, err := tr.rule(strings.Fields(tc.args))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := toJSON(t, rule.expr); got != tc.expected {
+				t.Errorf("expected\n%s\ngot\n%s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestNFTRuleComment(t *testing.T) {
+	tr := newNFTTranslator(ProtocolIPv4)
+
+	args := []string{"-m", "comment", "--comment", strings.Repeat("x", 200), "-j", "ACCEPT"}
+	rule, err := tr.rule(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rule.comment) != nftCommentMaxLen || !strings.HasSuffix(rule.comment, nftRuleTag(args)) {
+		t.Errorf("expected a truncated comment ending with the tag, got %q", rule.comment)
+	}
+
+	if _, err := tr.rule([]string{"-m", "string", "--string", "x", "-j", "DROP"}); err == nil {
+		t.Error("expected an unsupported match to fail")
+	}
+}
+
+func TestNFTRestore(t *testing.T) {
+	tr := newNFTTranslator(ProtocolIPv6)
+
+	data := `*nat
+:KUBE-SVC-X - [0:0]
+:KUBE-SEP-A - [0:0]
+:KUBE-SEP-OLD - [0:0]
+-A KUBE-SVC-X -m recent --name KUBE-SEP-A --rcheck --seconds 300 --reap -j KUBE-SEP-A
+-A KUBE-SEP-A -m recent --name KUBE-SEP-A --set -m tcp -p tcp -j DNAT --to-destination [fd00::1]:8080
+-X KUBE-SEP-OLD
+COMMIT
+*filter
+:KUBE-FORWARD - [0:0]
+-A KUBE-FORWARD -m conntrack --ctstate INVALID -j DROP
+COMMIT
+`
+
+	cmds, err := tr.restore([]byte(data), NoFlushTables, TableNAT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, cmd := range cmds {
+		for verb, obj := range cmd.(nftObj) {
+			for kind, v := range obj.(nftObj) {
+				got = append(got, verb+" "+kind+" "+toJSON(t, v.(nftObj)["name"]))
+			}
+		}
+	}
+
+	expected := []string{
+		`add table "nat"`,
+		`add set "KUBE-SEP-A"`,
+		`add table "nat"`, `add chain "KUBE-SVC-X"`, `flush chain "KUBE-SVC-X"`,
+		`add table "nat"`, `add chain "KUBE-SEP-A"`, `flush chain "KUBE-SEP-A"`,
+		`add table "nat"`, `add chain "KUBE-SEP-OLD"`, `flush chain "KUBE-SEP-OLD"`,
+		`add rule null`,
+		`add rule null`,
+		`delete chain "KUBE-SEP-OLD"`,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	// the entries set in KUBE-SEP-A expire after the --seconds checked in KUBE-SVC-X
+	setRule := toJSON(t, cmds[len(cmds)-2])
+	if !strings.Contains(setRule, `"timeout":300`) || !strings.Contains(setRule, `"addr":"fd00::1","port":8080`) {
+		t.Errorf("unexpected rule %s", setRule)
+	}
+
+	if _, err := tr.restore([]byte("*nat\n-A KUBE-SERVICES -j ACCEPT\n"), NoFlushTables, ""); err == nil {
+		t.Error("expected a missing COMMIT to fail")
+	}
+}
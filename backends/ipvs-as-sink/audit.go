@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipvssink
+
+import (
+	"net"
+	"strconv"
+	"syscall"
+
+	"github.com/google/seesaw/ipvs"
+
+	"sigs.k8s.io/kpng/client/auditlog"
+)
+
+// The virtual and real servers are changed through these funcs, recording the changes in the
+// audit log with the same objects as the ipvsfullstate backend.
+
+func addService(svc ipvs.Service) error {
+	err := ipvs.AddService(svc)
+	if err == nil {
+		auditlog.Record("ipvs", auditlog.Add, "virtual-server", virtualServerKey(svc), "")
+	}
+	return err
+}
+
+func updateService(svc ipvs.Service) error {
+	err := ipvs.UpdateService(svc)
+	if err == nil {
+		auditlog.Record("ipvs", auditlog.Update, "virtual-server", virtualServerKey(svc), "")
+	}
+	return err
+}
+
+func deleteService(svc ipvs.Service) error {
+	err := ipvs.DeleteService(svc)
+	if err == nil {
+		auditlog.Record("ipvs", auditlog.Delete, "virtual-server", virtualServerKey(svc), "")
+	}
+	return err
+}
+
+func addDestination(svc ipvs.Service, dst ipvs.Destination) error {
+	err := ipvs.AddDestination(svc, dst)
+	if err == nil {
+		auditlog.Record("ipvs", auditlog.Add, "real-server", realServerKey(svc, dst), "")
+	}
+	return err
+}
+
+func updateDestination(svc ipvs.Service, dst ipvs.Destination) error {
+	err := ipvs.UpdateDestination(svc, dst)
+	if err == nil {
+		auditlog.Record("ipvs", auditlog.Update, "real-server", realServerKey(svc, dst), "")
+	}
+	return err
+}
+
+func deleteDestination(svc ipvs.Service, dst ipvs.Destination) error {
+	err := ipvs.DeleteDestination(svc, dst)
+	if err == nil {
+		auditlog.Record("ipvs", auditlog.Delete, "real-server", realServerKey(svc, dst), "")
+	}
+	return err
+}
+
+// virtualServerKey formats the virtual server like TCP://10.96.1.10:80
+func virtualServerKey(svc ipvs.Service) string {
+	protocol := "IP(" + strconv.Itoa(int(svc.Protocol)) + ")"
+	switch svc.Protocol {
+	case syscall.IPPROTO_TCP:
+		protocol = "TCP"
+	case syscall.IPPROTO_UDP:
+		protocol = "UDP"
+	case syscall.IPPROTO_SCTP:
+		protocol = "SCTP"
+	}
+	return protocol + "://" + ipPort(svc.Address, svc.Port)
+}
+
+// realServerKey formats the real server like TCP://10.96.1.10:80/10.1.1.2:8000
+func realServerKey(svc ipvs.Service, dst ipvs.Destination) string {
+	return virtualServerKey(svc) + "/" + ipPort(dst.Address, dst.Port)
+}
+
+func ipPort(ip net.IP, port uint16) string {
+	return ip.String() + ":" + strconv.Itoa(int(port))
+}
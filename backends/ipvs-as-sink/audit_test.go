@@ -0,0 +1,37 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipvssink
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/google/seesaw/ipvs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditKeys(t *testing.T) {
+	svc := ipvs.Service{Address: net.ParseIP("10.96.1.10"), Protocol: syscall.IPPROTO_TCP, Port: 80}
+	dst := ipvs.Destination{Address: net.ParseIP("10.1.1.2"), Port: 8000}
+
+	assert.Equal(t, "TCP://10.96.1.10:80", virtualServerKey(svc))
+	assert.Equal(t, "TCP://10.96.1.10:80/10.1.1.2:8000", realServerKey(svc, dst))
+
+	svc = ipvs.Service{Address: net.ParseIP("fd00::10"), Protocol: syscall.IPPROTO_SCTP, Port: 9}
+	assert.Equal(t, "SCTP://fd00::10:9", virtualServerKey(svc))
+}
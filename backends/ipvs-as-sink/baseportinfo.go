@@ -31,6 +31,7 @@ type BaseServicePortInfo struct {
 	nodePort              int32
 	protocol              localv1.Protocol
 	schedulingMethod      string
+	forwardingMethod      string
 	weight                int32
 	sessionAffinity       serviceevents.SessionAffinity
 	stickyMaxAgeSeconds   int
@@ -39,11 +40,13 @@ type BaseServicePortInfo struct {
 	nodeLocalInternal     bool
 	internalTrafficPolicy *v1.ServiceInternalTrafficPolicyType
 	hintsAnnotation       string
+	// public is true for the node ports and the externally routed IPs (drained with the node)
+	public bool
 }
 
 func NewBaseServicePortInfo(svc *localv1.Service, port *localv1.PortMapping,
 	serviceIP, serviceType,
-	schedulingMethod string,
+	schedulingMethod, forwardingMethod string,
 	weight int32) *BaseServicePortInfo {
 	return &BaseServicePortInfo{
 		serviceIP:        serviceIP,
@@ -53,12 +56,23 @@ func NewBaseServicePortInfo(svc *localv1.Service, port *localv1.PortMapping,
 		nodePort:         port.NodePort,
 		protocol:         port.Protocol,
 		schedulingMethod: schedulingMethod,
+		forwardingMethod: forwardingMethodOf(svc, forwardingMethod),
 		weight:           weight,
 		serviceType:      serviceType,
 		sessionAffinity:  serviceevents.GetSessionAffinity(svc.SessionAffinity),
+		public:           !isClusterIP(svc, serviceIP),
 	}
 }
 
+func isClusterIP(svc *localv1.Service, ip string) bool {
+	for _, clusterIP := range svc.GetIPs().GetClusterIPs().All() {
+		if clusterIP == ip {
+			return true
+		}
+	}
+	return false
+}
+
 func (b *BaseServicePortInfo) ServiceIP() string {
 	return b.serviceIP
 }
@@ -86,7 +86,7 @@ func (p *proxier) handleNewClusterIPService(serviceKey, clusterIP string, svc *l
 	}
 
 	spKey := getServicePortKey(serviceKey, clusterIP, port)
-	portInfo := NewBaseServicePortInfo(svc, port, clusterIP, ClusterIPService, p.schedulingMethod, p.weight)
+	portInfo := NewBaseServicePortInfo(svc, port, clusterIP, ClusterIPService, p.schedulingMethod, p.forwardingMethod, p.weight)
 	p.servicePorts.Set([]byte(spKey), 0, *portInfo)
 
 	portMapKey := getPortKey(serviceKey, port)
@@ -105,7 +105,7 @@ func (p *proxier) handleUpdatedClusterIPService(serviceKey, clusterIP string, sv
 	}
 
 	spKey := getServicePortKey(serviceKey, clusterIP, port)
-	portInfo := NewBaseServicePortInfo(svc, port, clusterIP, ClusterIPService, p.schedulingMethod, p.weight)
+	portInfo := NewBaseServicePortInfo(svc, port, clusterIP, ClusterIPService, p.schedulingMethod, p.forwardingMethod, p.weight)
 	p.servicePorts.Set([]byte(spKey), 0, *portInfo)
 
 	portMapKey := getPortKey(serviceKey, port)
@@ -17,13 +17,12 @@ limitations under the License.
 package ipvssink
 
 import (
-	"errors"
 	"strconv"
 	"strings"
 
-	"github.com/google/seesaw/ipvs"
 	"sigs.k8s.io/kpng/client/lightdiffstore"
 	"sigs.k8s.io/kpng/client/serviceevents"
+	"sigs.k8s.io/kpng/client/slowstart"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
@@ -65,6 +64,7 @@ type endPointInfo struct {
 	endPointIP      string
 	isLocalEndPoint bool
 	portMap         map[string]int32
+	weight          int32
 }
 
 func asDummyIPs(ip string, ipFamily v1.IPFamily) string {
@@ -195,10 +195,13 @@ func (p *proxier) addRealServerForPort(serviceKey string, portList []*BaseServic
 				Svc: port.GetVirtualServer().ToService(),
 				Dst: ipvsDestination(epInfo, port),
 			}
+			p.drainDestination(port, &destination)
+			p.rampDestination(string(epKV.Key), &destination)
 			klog.V(2).Infof("adding destination ep (%v)", epInfo.endPointIP)
-			if err := ipvs.AddDestination(destination.Svc, destination.Dst); err != nil && !strings.HasSuffix(err.Error(), "object exists") {
+			if err := addDestination(destination.Svc, destination.Dst); err != nil && !strings.HasSuffix(err.Error(), "object exists") {
 				klog.Error("failed to add destination ", serviceKey, ": ", err)
 			}
+			p.prepareTunnel(epInfo, port)
 		}
 	}
 	return epList
@@ -216,7 +219,8 @@ func (p *proxier) deleteRealServerForPort(serviceKey string, portList []*BaseSer
 				Dst: ipvsDestination(epInfo, port),
 			}
 			klog.V(2).Infof("deleting destination ep (%v)", epInfo.endPointIP)
-			if err := ipvs.DeleteDestination(destination.Svc, destination.Dst); err != nil && !strings.HasSuffix(err.Error(), "object exists") {
+			p.forgetDrainable(destination)
+			if err := deleteDestination(destination.Svc, destination.Dst); err != nil && !strings.HasSuffix(err.Error(), "object exists") {
 				klog.Error("failed to delete destination ", serviceKey, ": ", err)
 			}
 		}
@@ -253,19 +257,16 @@ func getIPFamiliesOfService(svc *localv1.Service) []v1.IPFamily {
 	return ipFamilies
 }
 
-func (p *proxier) getLbIPForIPFamily(svc *localv1.Service) (error, string) {
-	var svcIP string
-	if svc.IPs.LoadBalancerIPs == nil {
-		return errors.New("LB IPs are not configured"), svcIP
-	}
-
-	if p.ipFamily == v1.IPv4Protocol && len(svc.IPs.LoadBalancerIPs.V4) > 0 {
-		svcIP = svc.IPs.LoadBalancerIPs.V4[0]
-	}
-	if p.ipFamily == v1.IPv6Protocol && len(svc.IPs.LoadBalancerIPs.V6) > 0 {
-		svcIP = svc.IPs.LoadBalancerIPs.V6[0]
+// addressesOfFamily returns the addresses of the given IP family, each
+// family's proxier only programming virtual servers of its own family.
+func addressesOfFamily(addresses []string, ipFamily v1.IPFamily) []string {
+	var ips []string
+	for _, ip := range addresses {
+		if getIPFamily(ip) == ipFamily {
+			ips = append(ips, ip)
+		}
 	}
-	return nil, svcIP
+	return ips
 }
 
 func (p *proxier) addVirtualServer(portInfo *BaseServicePortInfo) {
@@ -274,7 +275,7 @@ func (p *proxier) addVirtualServer(portInfo *BaseServicePortInfo) {
 	klog.V(2).Infof("adding AddVirtualServer: port: %v", portInfo)
 	// Programme virtual-server directly
 	ipvsSvc := vs.ToService()
-	err := ipvs.AddService(ipvsSvc)
+	err := addService(ipvsSvc)
 	if err != nil && !strings.HasSuffix(err.Error(), "object exists") {
 		klog.Error("failed to add service in IPVS", ": ", err)
 	}
@@ -282,7 +283,7 @@ func (p *proxier) addVirtualServer(portInfo *BaseServicePortInfo) {
 
 func (p *proxier) deleteVirtualServer(portInfo *BaseServicePortInfo) {
 	klog.V(2).Infof("deleting service , serviceIP (%v) , port (%v)", portInfo.serviceIP, portInfo.Port())
-	err := ipvs.DeleteService(portInfo.GetVirtualServer().ToService())
+	err := deleteService(portInfo.GetVirtualServer().ToService())
 	if err != nil {
 		klog.Error("failed to delete service from IPVS", portInfo.serviceIP, ": ", err)
 	}
@@ -385,7 +386,7 @@ func (p *proxier) enableSessionAffinityForServiceIP(serviceKey string, sa servic
 		vs := portInfo.GetVirtualServer()
 		// Programme virtual-server directly
 		ipvsSvc := vs.ToService()
-		err := ipvs.UpdateService(ipvsSvc)
+		err := updateService(ipvsSvc)
 		if err != nil && !strings.HasSuffix(err.Error(), "object exists") {
 			klog.Error("failed to add service in IPVS", serviceKey, ": ", err)
 		}
@@ -402,7 +403,7 @@ func (p *proxier) disableSessionAffinityForServiceIP(serviceKey string) {
 
 		// Programme virtual-server directly
 		ipvsSvc := vs.ToService()
-		err := ipvs.UpdateService(ipvsSvc)
+		err := updateService(ipvsSvc)
 		if err != nil && !strings.HasSuffix(err.Error(), "object exists") {
 			klog.Error("failed to add service in IPVS", serviceKey, ": ", err)
 		}
@@ -416,12 +417,15 @@ func (p *proxier) addRealServer(serviceKey, prefix, endPointIP string, endpoint
 		endPointIP:      endPointIP,
 		isLocalEndPoint: endpoint.Local,
 		portMap:         make(map[string]int32),
+		weight:          endpoint.WeightOr(p.weight),
 	}
 
 	for _, port := range endpoint.PortOverrides {
 		epInfo.portMap[port.Name] = port.Port
 	}
 	p.endpoints.Set([]byte(prefix), 0, epInfo)
+	slowstart.Default().Add(prefix)
+
 	for _, sp := range p.servicePorts.GetByPrefix([]byte(serviceKey)) {
 		portInfo := sp.Value.(BaseServicePortInfo)
 		klog.V(2).Infof("addRealServer, portInfo : %v", portInfo)
@@ -430,10 +434,18 @@ func (p *proxier) addRealServer(serviceKey, prefix, endPointIP string, endpoint
 			Svc: vs.ToService(),
 			Dst: ipvsDestination(epInfo, &portInfo),
 		}
+		p.drainDestination(&portInfo, &dest)
+		p.rampDestination(prefix, &dest)
 		klog.V(2).Infof("adding destination ep (%v)", endPointIP)
-		if err := ipvs.AddDestination(dest.Svc, dest.Dst); err != nil && !strings.HasSuffix(err.Error(), "object exists") {
+		err := addDestination(dest.Svc, dest.Dst)
+		if err != nil && strings.HasSuffix(err.Error(), "object exists") {
+			// the endpoint changed, ie: its weight
+			err = updateDestination(dest.Svc, dest.Dst)
+		}
+		if err != nil {
 			klog.Error("failed to add destination ", dest, ": ", err)
 		}
+		p.prepareTunnel(epInfo, &portInfo)
 	}
 	portList := p.portMap[serviceKey]
 	klog.V(2).Infof("addRealServer, portList : %v", portList)
@@ -445,6 +457,8 @@ func (p *proxier) addRealServer(serviceKey, prefix, endPointIP string, endpoint
 func (p *proxier) deleteRealServer(serviceKey, prefix string) {
 	for _, kv := range p.endpoints.GetByPrefix([]byte(prefix)) {
 		epInfo := kv.Value.(endPointInfo)
+		p.stopRamp(string(kv.Key))
+
 		for _, sp := range p.servicePorts.GetByPrefix([]byte(serviceKey)) {
 			portInfo := sp.Value.(BaseServicePortInfo)
 			vs := portInfo.GetVirtualServer()
@@ -455,7 +469,8 @@ func (p *proxier) deleteRealServer(serviceKey, prefix string) {
 			}
 
 			klog.V(2).Infof("deleting destination : %v", dest)
-			if err := ipvs.DeleteDestination(dest.Svc, dest.Dst); err != nil {
+			p.forgetDrainable(dest)
+			if err := deleteDestination(dest.Svc, dest.Dst); err != nil {
 				klog.Error("failed to delete destination ", dest, ": ", err)
 			}
 		}
@@ -0,0 +1,32 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipvssink
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestAddressesOfFamily(t *testing.T) {
+	addresses := []string{"10.0.0.1", "fd00::1", "192.168.1.1", "2001:db8::1"}
+
+	assert.Equal(t, []string{"10.0.0.1", "192.168.1.1"}, addressesOfFamily(addresses, v1.IPv4Protocol))
+	assert.Equal(t, []string{"fd00::1", "2001:db8::1"}, addressesOfFamily(addresses, v1.IPv6Protocol))
+	assert.Nil(t, addressesOfFamily([]string{"10.0.0.1"}, v1.IPv6Protocol))
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipvssink
+
+import (
+	"fmt"
+
+	"k8s.io/klog/v2"
+)
+
+// destinationKey identifies a destination of a virtual server.
+func destinationKey(dest ipvsSvcDst) string {
+	return fmt.Sprintf("%s/%d/%d>%s/%d", dest.Svc.Address, dest.Svc.Protocol, dest.Svc.Port, dest.Dst.Address, dest.Dst.Port)
+}
+
+// drainDestination keeps track of the destinations of the node ports and externally routed IPs,
+// and sets their weight to 0 while the node is draining: IPVS then sends them no new connection,
+// but keeps the established ones.
+func (p *proxier) drainDestination(port *BaseServicePortInfo, dest *ipvsSvcDst) {
+	if !port.public {
+		return
+	}
+
+	p.drainMu.Lock()
+	defer p.drainMu.Unlock()
+
+	if p.drainable == nil {
+		p.drainable = map[string]ipvsSvcDst{}
+	}
+	p.drainable[destinationKey(*dest)] = *dest
+
+	if p.draining {
+		dest.Dst.Weight = 0
+	}
+}
+
+// forgetDrainable forgets a deleted destination.
+func (p *proxier) forgetDrainable(dest ipvsSvcDst) {
+	p.drainMu.Lock()
+	defer p.drainMu.Unlock()
+
+	delete(p.drainable, destinationKey(dest))
+}
+
+// isDrained returns true if the destination must not receive new connections.
+func (p *proxier) isDrained(dest ipvsSvcDst) bool {
+	p.drainMu.Lock()
+	defer p.drainMu.Unlock()
+
+	_, drainable := p.drainable[destinationKey(dest)]
+	return p.draining && drainable
+}
+
+// setDraining updates the weights of the drainable destinations.
+func (p *proxier) setDraining(draining bool) {
+	p.drainMu.Lock()
+	defer p.drainMu.Unlock()
+
+	p.draining = draining
+
+	for key, dest := range p.drainable {
+		dst := dest.Dst
+		if draining {
+			dst.Weight = 0
+		}
+
+		if err := updateDestination(dest.Svc, dst); err != nil {
+			// the virtual server is gone
+			klog.V(1).Info("failed to update the weight of destination ", dest, ": ", err)
+			delete(p.drainable, key)
+		}
+	}
+}
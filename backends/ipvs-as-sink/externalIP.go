@@ -23,7 +23,7 @@ import (
 
 func (p *proxier) handleNewExternalIP(serviceKey, externalIP, svcType string, svc *localv1.Service, port *localv1.PortMapping) {
 	spKey := getServicePortKey(serviceKey, externalIP, port)
-	portInfo := NewBaseServicePortInfo(svc, port, externalIP, svcType, p.schedulingMethod, p.weight)
+	portInfo := NewBaseServicePortInfo(svc, port, externalIP, svcType, p.schedulingMethod, p.forwardingMethod, p.weight)
 	p.servicePorts.Set([]byte(spKey), 0, *portInfo)
 
 	p.addVirtualServer(portInfo)
@@ -34,7 +34,7 @@ func (p *proxier) handleNewExternalIP(serviceKey, externalIP, svcType string, sv
 
 func (p *proxier) handleUpdatedExternalIP(serviceKey, externalIP, svcType string, svc *localv1.Service, port *localv1.PortMapping) {
 	spKey := getServicePortKey(serviceKey, externalIP, port)
-	portInfo := NewBaseServicePortInfo(svc, port, externalIP, svcType, p.schedulingMethod, p.weight)
+	portInfo := NewBaseServicePortInfo(svc, port, externalIP, svcType, p.schedulingMethod, p.forwardingMethod, p.weight)
 	p.servicePorts.Set([]byte(spKey), 0, *portInfo)
 
 	//Update the service with added ports into LB tree
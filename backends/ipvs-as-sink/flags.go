@@ -29,8 +29,11 @@ func (s *Backend) BindFlags(flags *pflag.FlagSet) {
 	flags.BoolVar(&s.dryRun, "dry-run", false, "dry run (print instead of applying)")
 	flags.StringSliceVar(&s.nodeAddresses, "node-address", interfaceAddresses(), "A comma-separated list of IPs to associate when using NodePort type. Defaults to all the Node addresses")
 	flags.StringVar(&s.schedulingMethod, "scheduling-method", "rr", "Algorithm for allocating TCP conn & UDP datagrams to real servers. Values: rr,wrr,lc,wlc,lblc,lblcr,dh,sh,seq,nq")
-	flags.Int32Var(&s.weight, "weight", 1, "An integer specifying the capacity of server relative to others in the pool")
-	//flags.Int32Var(s.masqueradeBit, "iptables-masquerade-bit", Int32PtrDerefOr(s.masqueradeBit, 14), "If using the pure iptables proxy, the bit of the fwmark space to mark packets requiring SNAT with.  Must be within the range [0, 31].")
+	flags.StringVar(&s.forwardingMethod, "forwarding-method", ForwardingMasquerade, "How IPVS forwards the packets to the endpoints: masq (NAT) or tunnel (IP-in-IP, the endpoints answering the clients directly), overridden by the "+AnnotationForwardingMethod+" service annotation")
+	flags.Int32Var(&s.weight, "weight", 1, "An integer specifying the capacity of server relative to others in the pool, for the endpoints without a weight of their own. Weights are only honored by the weighted scheduling methods (ie: wrr)")
+	flags.IntVar(&s.masqueradeBit, "masquerade-bit", 14, "The bit of the fwmark space to mark packets requiring SNAT with. Must be within the range [0, 31].")
+	flags.StringToIntVar(&s.ipv4MasqueradeBits, "ipv4-masquerade-bits", nil, "Bits of the fwmark space per IPv4 traffic class (clusterip, nodeport, external) overriding --masquerade-bit, ie: nodeport=15,external=16")
+	flags.StringToIntVar(&s.ipv6MasqueradeBits, "ipv6-masquerade-bits", nil, "Bits of the fwmark space per IPv6 traffic class (clusterip, nodeport, external) overriding --masquerade-bit")
 	flags.BoolVar(&s.masqueradeAll, "masquerade-all", s.masqueradeAll, "If using the pure iptables proxy, SNAT all traffic sent via Service cluster IPs (this not commonly needed)")
 }
 
@@ -47,8 +50,9 @@ func interfaceAddresses() []string {
 		if err != nil {
 			panic(err)
 		}
-		// I want to deal only with IPv4 right now...
-		if ipv4 := ip.To4(); ipv4 == nil {
+		// IPv6 link-local addresses need a zone to be reachable, they can't
+		// be nodeport virtual servers.
+		if ip.To4() == nil && ip.IsLinkLocalUnicast() {
 			continue
 		}
 
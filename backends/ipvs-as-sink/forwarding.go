@@ -0,0 +1,187 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipvssink
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/google/seesaw/ipvs"
+	"github.com/vishvananda/netlink"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/ipvs-as-sink/util"
+)
+
+const (
+	// AnnotationForwardingMethod overrides --forwarding-method for a service (the annotation must be
+	// included with --with-service-annotations on the server).
+	AnnotationForwardingMethod = "kpng.sigs.k8s.io/ipvs-forwarding-method"
+
+	// ForwardingMasquerade translates the destination of the packets to the endpoints (NAT).
+	ForwardingMasquerade = "masq"
+	// ForwardingTunnel encapsulates the packets to the endpoints (IP-in-IP), which answer the
+	// clients directly (DSR) and must accept the packets of the service IPs.
+	ForwardingTunnel = "tunnel"
+)
+
+func checkForwardingMethod(method string) error {
+	switch method {
+	case ForwardingMasquerade, ForwardingTunnel:
+		return nil
+	}
+	return fmt.Errorf("invalid forwarding method %q, must be %s or %s", method, ForwardingMasquerade, ForwardingTunnel)
+}
+
+// forwardingMethodOf returns the forwarding method of a service, with its annotation applied.
+func forwardingMethodOf(svc *localv1.Service, defaultMethod string) string {
+	method, ok := svc.GetAnnotations()[AnnotationForwardingMethod]
+	if !ok {
+		return defaultMethod
+	}
+	if err := checkForwardingMethod(method); err != nil {
+		klog.V(1).InfoS("Ignoring invalid annotation", "service", svc.NamespacedName(), "annotation", AnnotationForwardingMethod, "value", method)
+		return defaultMethod
+	}
+	return method
+}
+
+// destinationFlags returns the flags of the destinations forwarded with method.
+func destinationFlags(method string) ipvs.DestinationFlags {
+	if method == ForwardingTunnel {
+		return ipvs.DFForwardTunnel
+	}
+	return ipvs.DFForwardMasq
+}
+
+// updateForwardingMethod reprograms the destinations of a known service when its forwarding
+// method changed; the ports of a new service get it when added.
+func (s *Backend) updateForwardingMethod(svc *localv1.Service) {
+	if _, known := s.svcs[getServiceKey(svc)]; !known {
+		return
+	}
+	method := forwardingMethodOf(svc, s.forwardingMethod)
+	for _, ipFamily := range getIPFamiliesOfService(svc) {
+		s.proxiers[ipFamily].updateForwardingMethod(getServiceKey(svc), method)
+	}
+}
+
+func (p *proxier) updateForwardingMethod(serviceKey, method string) {
+	prefix := []byte(serviceKey + "/")
+	for _, sp := range p.servicePorts.GetByPrefix(prefix) {
+		portInfo := sp.Value.(BaseServicePortInfo)
+		if portInfo.forwardingMethod == method {
+			continue
+		}
+		klog.V(2).Infof("forwarding %s %v with %s", serviceKey, portInfo.ServiceIP(), method)
+		portInfo.forwardingMethod = method
+		p.servicePorts.Set(sp.Key, 0, portInfo)
+
+		vs := portInfo.GetVirtualServer()
+		for _, epKV := range p.endpoints.GetByPrefix(prefix) {
+			epInfo := epKV.Value.(endPointInfo)
+			dest := ipvsSvcDst{
+				Svc: vs.ToService(),
+				Dst: ipvsDestination(epInfo, &portInfo),
+			}
+			p.drainDestination(&portInfo, &dest)
+			p.rampDestination(string(epKV.Key), &dest)
+			if err := updateDestination(dest.Svc, dest.Dst); err != nil {
+				klog.Error("failed to update destination ", dest, ": ", err)
+			}
+			p.prepareTunnel(epInfo, &portInfo)
+		}
+	}
+}
+
+// prepareTunnel sets the tunnel interface up if the packets of the port are tunneled to an
+// endpoint of the host network of this node. The other endpoints must decapsulate them.
+func (p *proxier) prepareTunnel(epInfo endPointInfo, port *BaseServicePortInfo) {
+	if port.forwardingMethod != ForwardingTunnel || !epInfo.isLocalEndPoint || !p.isNodeAddress(epInfo.endPointIP) {
+		return
+	}
+	if p.tunnelReady {
+		return
+	}
+	if err := setupTunnelInterface(p.ipFamily); err != nil {
+		klog.Error("failed to set the tunnel interface up: ", err)
+		return
+	}
+	p.tunnelReady = true
+}
+
+func (p *proxier) isNodeAddress(ip string) bool {
+	for _, address := range p.nodeAddresses {
+		if address == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// tunnelInterfaces are the fallback tunnel devices of the kernel, receiving the encapsulated
+// packets of any remote address, created with the first tunnel of their type.
+var tunnelInterfaces = map[v1.IPFamily]netlink.Link{
+	v1.IPv4Protocol: &netlink.Iptun{LinkAttrs: netlink.LinkAttrs{Name: "tunl0"}},
+	v1.IPv6Protocol: &netlink.Ip6tnl{LinkAttrs: netlink.LinkAttrs{Name: "ip6tnl0"}},
+}
+
+// setupTunnelInterface sets the tunnel interface of the family up, so the node decapsulates the
+// packets tunneled to it. The service IPs are already local (on the dummy interface).
+func setupTunnelInterface(ipFamily v1.IPFamily) error {
+	tunnel := tunnelInterfaces[ipFamily]
+	name := tunnel.Attrs().Name
+
+	link, err := netlink.LinkByName(name)
+	if _, ok := err.(netlink.LinkNotFoundError); ok {
+		// loading the tunnel module creates the fallback device, hence EEXIST
+		klog.Info("creating tunnel interface ", name)
+		if err = netlink.LinkAdd(tunnel); err != nil && !errors.Is(err, syscall.EEXIST) {
+			return err
+		}
+		link, err = netlink.LinkByName(name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if link.Attrs().Flags&net.FlagUp == 0 {
+		klog.Info("setting tunnel interface ", name, " up")
+		if err = netlink.LinkSetUp(link); err != nil {
+			return err
+		}
+	}
+
+	if ipFamily == v1.IPv6Protocol {
+		return nil
+	}
+
+	// the decapsulated packets come from the clients, routed through other interfaces
+	sysctl := util.NewSysInterface()
+	if err := util.EnsureSysctl(sysctl, "net/ipv4/conf/"+name+"/rp_filter", 0); err != nil {
+		return err
+	}
+	if val, err := sysctl.GetSysctl(sysctlRPFilterAll); err == nil && val == 1 {
+		klog.Warningf("strict reverse path filtering (%s=1) drops the packets tunneled to the node", strings.ReplaceAll(sysctlRPFilterAll, "/", "."))
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipvssink
+
+import (
+	"testing"
+
+	"github.com/google/seesaw/ipvs"
+	"github.com/stretchr/testify/assert"
+
+	"sigs.k8s.io/kpng/api/localv1"
+)
+
+func TestForwardingMethod(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		annotations map[string]string
+		defaultFwd  string
+		method      string
+		flags       ipvs.DestinationFlags
+	}{
+		{
+			name:       "default",
+			defaultFwd: ForwardingMasquerade,
+			method:     ForwardingMasquerade,
+			flags:      ipvs.DFForwardMasq,
+		},
+		{
+			name:       "tunnel by default",
+			defaultFwd: ForwardingTunnel,
+			method:     ForwardingTunnel,
+			flags:      ipvs.DFForwardTunnel,
+		},
+		{
+			name:        "annotated",
+			annotations: map[string]string{AnnotationForwardingMethod: ForwardingTunnel},
+			defaultFwd:  ForwardingMasquerade,
+			method:      ForwardingTunnel,
+			flags:       ipvs.DFForwardTunnel,
+		},
+		{
+			name:        "invalid annotation",
+			annotations: map[string]string{AnnotationForwardingMethod: "route"},
+			defaultFwd:  ForwardingMasquerade,
+			method:      ForwardingMasquerade,
+			flags:       ipvs.DFForwardMasq,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &localv1.Service{Namespace: "ns", Name: "svc", Annotations: tc.annotations}
+			port := NewBaseServicePortInfo(svc, &localv1.PortMapping{Port: 80, TargetPort: 8080}, "10.96.0.1", ClusterIPService, "rr", tc.defaultFwd, 1)
+			assert.Equal(t, tc.method, port.forwardingMethod)
+
+			dst := ipvsDestination(endPointInfo{endPointIP: "10.1.0.1"}, port)
+			assert.Equal(t, tc.flags, dst.Flags)
+			assert.Equal(t, uint16(8080), dst.Port)
+		})
+	}
+
+	assert.Error(t, checkForwardingMethod("route"))
+}
+
+func TestNamedTargetPortDestination(t *testing.T) {
+	// service imports only name their target port
+	svc := &localv1.Service{Namespace: "ns", Name: "web-clusterset"}
+	port := NewBaseServicePortInfo(svc, &localv1.PortMapping{Name: "http", Port: 80, TargetPortName: "http"}, "10.42.0.10", ClusterIPService, "rr", ForwardingMasquerade, 1)
+
+	dst := ipvsDestination(endPointInfo{endPointIP: "10.1.0.1", portMap: map[string]int32{"http": 8080}}, port)
+	assert.Equal(t, uint16(8080), dst.Port)
+}
@@ -18,7 +18,6 @@ package ipvssink
 
 import (
 	"bytes"
-	"fmt"
 
 	"k8s.io/klog/v2"
 
@@ -68,6 +67,9 @@ var iptablesChains = []struct {
 	{util.TableNAT, KubeNodePortChain},
 	{util.TableNAT, KubeLoadBalancerChain},
 	{util.TableNAT, KubeMarkMasqChain},
+	{util.TableNAT, KubeMarkMasqClusterIPChain},
+	{util.TableNAT, KubeMarkMasqNodePortChain},
+	{util.TableNAT, KubeMarkMasqExternalChain},
 	{util.TableFilter, KubeForwardChain},
 	{util.TableFilter, KubeNodePortChain},
 }
@@ -107,11 +109,11 @@ var ipsetWithIptablesChain = []struct {
 	{kubeLoadBalancerSourceIPSet, string(KubeFireWallChain), "RETURN", "dst,dst,src", ""},
 	{kubeLoadBalancerLocalSet, string(KubeLoadBalancerChain), "RETURN", "dst,dst", ""},
 	{kubeNodePortLocalSetTCP, string(KubeNodePortChain), "RETURN", "dst", util.ProtocolTCP},
-	{kubeNodePortSetTCP, string(KubeNodePortChain), string(KubeMarkMasqChain), "dst", util.ProtocolTCP},
+	{kubeNodePortSetTCP, string(KubeNodePortChain), string(KubeMarkMasqNodePortChain), "dst", util.ProtocolTCP},
 	{kubeNodePortLocalSetUDP, string(KubeNodePortChain), "RETURN", "dst", util.ProtocolUDP},
-	{kubeNodePortSetUDP, string(KubeNodePortChain), string(KubeMarkMasqChain), "dst", util.ProtocolUDP},
+	{kubeNodePortSetUDP, string(KubeNodePortChain), string(KubeMarkMasqNodePortChain), "dst", util.ProtocolUDP},
 	{kubeNodePortLocalSetSCTP, string(KubeNodePortChain), "RETURN", "dst,dst", util.ProtocolSCTP},
-	{kubeNodePortSetSCTP, string(KubeNodePortChain), string(KubeMarkMasqChain), "dst,dst", util.ProtocolSCTP},
+	{kubeNodePortSetSCTP, string(KubeNodePortChain), string(KubeMarkMasqNodePortChain), "dst,dst", util.ProtocolSCTP},
 }
 
 // createAndLinkKubeChain create all kube chains that ipvs proxier need and write basic link.
@@ -208,7 +210,7 @@ func (p *proxier) writeIptablesRules() {
 			"-m", "set", "--match-set", p.ipsetList[kubeClusterIPSet].Name,
 		)
 		if p.masqueradeAll {
-			p.natRules.Write(args, "dst,dst", "-j", string(KubeMarkMasqChain))
+			p.natRules.Write(args, "dst,dst", "-j", string(KubeMarkMasqClusterIPChain))
 			//TODO: localDetector code needs to be added later
 			//} else if p.localDetector.IsImplemented() {
 			//	// This masquerades off-cluster traffic to a service VIP.  The idea
@@ -226,7 +228,7 @@ func (p *proxier) writeIptablesRules() {
 			// VIP:<service port>.
 			// Always masquerading OUTPUT (node-originating) traffic with a VIP
 			// source ip and service port destination fixes the outgoing connections.
-			p.natRules.Write(args, "src,dst", "-j", string(KubeMarkMasqChain))
+			p.natRules.Write(args, "src,dst", "-j", string(KubeMarkMasqClusterIPChain))
 		}
 	}
 
@@ -254,7 +256,7 @@ func (p *proxier) writeIptablesRules() {
 			"-m", "set", "--match-set", p.ipsetList[kubeExternalIPSet].Name,
 			"dst,dst",
 		)
-		p.natRules.Write(args, "-j", string(KubeMarkMasqChain))
+		p.natRules.Write(args, "-j", string(KubeMarkMasqExternalChain))
 		externalIPRules(args)
 	}
 
@@ -278,7 +280,7 @@ func (p *proxier) writeIptablesRules() {
 	// mark drop for KUBE-LOAD-BALANCER
 	p.natRules.Write(
 		"-A", string(KubeLoadBalancerChain),
-		"-j", string(KubeMarkMasqChain),
+		"-j", string(KubeMarkMasqExternalChain),
 	)
 	// mark drop for KUBE-FIRE-WALL
 	p.natRules.Write(
@@ -291,13 +293,14 @@ func (p *proxier) writeIptablesRules() {
 	// Those rules must be in the end of KUBE-SERVICE chain
 	p.acceptIPVSTraffic()
 
-	// If the masqueradeMark has been added then we want to forward that same
+	// If any of the masquerade marks has been added then we want to forward that same
 	// traffic, this allows NodePort traffic to be forwarded even if the default
 	// FORWARD policy is not accept.
+	masqMask := formatMark(p.masqueradeMarks.mask())
 	p.filterRules.Write(
 		"-A", string(KubeForwardChain),
 		"-m", "comment", "--comment", `"kubernetes forwarding rules"`,
-		"-m", "mark", "--mark", fmt.Sprintf("%s/%s", p.masqueradeMark, p.masqueradeMark),
+		"-m", "mark", "!", "--mark", "0/"+masqMask,
 		"-j", "ACCEPT",
 	)
 
@@ -325,15 +328,15 @@ func (p *proxier) writeIptablesRules() {
 	// NB: THIS MUST MATCH the corresponding code in the kubelet
 	p.natRules.Write(
 		"-A", string(kubePostroutingChain),
-		"-m", "mark", "!", "--mark", fmt.Sprintf("%s/%s", p.masqueradeMark, p.masqueradeMark),
+		"-m", "mark", "--mark", "0/"+masqMask,
 		"-j", "RETURN",
 	)
 
-	// Clear the mark to avoid re-masquerading if the packet re-traverses the network stack.
+	// Clear the marks to avoid re-masquerading if the packet re-traverses the network stack.
+	// Only some of them may be set, so the bits are cleared instead of XOR-ed.
 	p.natRules.Write(
 		"-A", string(kubePostroutingChain),
-		// XOR proxier.masqueradeMark to unset it
-		"-j", "MARK", "--xor-mark", p.masqueradeMark,
+		"-j", "MARK", "--and-mark", formatMark(^p.masqueradeMarks.mask()),
 	)
 
 	masqRule := []string{
@@ -351,9 +354,17 @@ func (p *proxier) writeIptablesRules() {
 	// value should ever change.
 	p.natRules.Write(
 		"-A", string(KubeMarkMasqChain),
-		"-j", "MARK", "--or-mark", p.masqueradeMark,
+		"-j", "MARK", "--or-mark", formatMark(p.masqueradeMarks.base),
 	)
 
+	// Each traffic class has its own chain, so its mark can be set without touching the others.
+	for _, class := range trafficClasses {
+		p.natRules.Write(
+			"-A", string(markMasqChains[class]),
+			"-j", "MARK", "--or-mark", p.masqueradeMarks.classMark(class),
+		)
+	}
+
 	// Write the end-of-table markers.
 	p.filterRules.Write("COMMIT")
 	p.natRules.Write("COMMIT")
@@ -21,6 +21,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strings"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/version"
@@ -38,10 +39,15 @@ import (
 	"github.com/vishvananda/netlink"
 
 	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/auditlog"
 	"sigs.k8s.io/kpng/client/backendcmd"
+	"sigs.k8s.io/kpng/client/drain"
 	"sigs.k8s.io/kpng/client/localsink"
 	"sigs.k8s.io/kpng/client/localsink/decoder"
 	"sigs.k8s.io/kpng/client/localsink/filterreset"
+	"sigs.k8s.io/kpng/client/localsink/filterreset/pipe"
+	"sigs.k8s.io/kpng/client/plugins/conntrack"
+	"sigs.k8s.io/kpng/client/slowstart"
 )
 
 // In IPVS proxy mode, the following flags need to be set
@@ -54,6 +60,7 @@ const (
 	sysctlForward                      = "net/ipv4/ip_forward"
 	sysctlArpIgnore                    = "net/ipv4/conf/all/arp_ignore"
 	sysctlArpAnnounce                  = "net/ipv4/conf/all/arp_announce"
+	sysctlRPFilterAll                  = "net/ipv4/conf/all/rp_filter"
 	connReuseMinSupportedKernelVersion = "4.1"
 	// https://github.com/torvalds/linux/commit/35dfb013149f74c2be1ff9c78f14e6a3cd1539d1
 	connReuseFixedKernelVersion = "5.9"
@@ -73,14 +80,20 @@ type Backend struct {
 	dryRun           bool
 	nodeAddresses    []string
 	schedulingMethod string
+	forwardingMethod string
 	weight           int32
 
 	dummy netlink.Link
 
-	masqueradeAll bool
+	masqueradeAll      bool
+	masqueradeBit      int
+	ipv4MasqueradeBits map[string]int
+	ipv6MasqueradeBits map[string]int
 }
 
 var _ decoder.Interface = &Backend{}
+var _ drain.Backend = &Backend{}
+var _ auditlog.Backend = &Backend{}
 
 func New() *Backend {
 	return &Backend{
@@ -90,8 +103,18 @@ func New() *Backend {
 	}
 }
 
+// SupportsDrain is true: the node ports and externally routed IPs get no new connection while the
+// node is draining.
+func (s *Backend) SupportsDrain() bool { return true }
+
+// SupportsSlowStart is true: the weights of the new destinations ramp up.
+func (s *Backend) SupportsSlowStart() bool { return true }
+
+// RecordsChanges is true: the changes of the virtual and real servers are recorded.
+func (s *Backend) RecordsChanges() bool { return true }
+
 func (s *Backend) Sink() localsink.Sink {
-	return filterreset.New(decoder.New(serviceevents.Wrap(s)))
+	return filterreset.New(pipe.New(decoder.New(serviceevents.Wrap(s)), decoder.New(conntrack.NewSink())))
 }
 
 // ------------------------------------------------------------------------
@@ -169,7 +192,9 @@ func (s *Backend) DisableTrafficPolicy(svc *localv1.Service, policyKind servicee
 
 // SetService ------------------------------------------------------
 // Service
-func (s *Backend) SetService(svc *localv1.Service) {}
+func (s *Backend) SetService(svc *localv1.Service) {
+	s.updateForwardingMethod(svc)
+}
 
 func (s *Backend) DeleteService(namespace, name string) {}
 
@@ -220,6 +245,52 @@ func (s *Backend) DeleteEndpoint(namespace, serviceName, key string) {
 	}
 }
 
+// Probe checks that the kernel modules required by IPVS are loaded or built-in.
+func (s *Backend) Probe() error {
+	return backendcmd.Err(s.Checks())
+}
+
+// Checks checks the kernel version and the IPVS kernel modules it requires.
+func (s *Backend) Checks() []backendcmd.Check {
+	return kernelChecks(util.NewLinuxKernelHandler())
+}
+
+func kernelChecks(kernelHandler util.KernelHandler) []backendcmd.Check {
+	kernelVersionStr, err := kernelHandler.GetKernelVersion()
+	if err != nil {
+		return []backendcmd.Check{{Name: "kernel version", Err: fmt.Errorf("error determining kernel version: %w", err)}}
+	}
+	kernelVersion, err := version.ParseGeneric(kernelVersionStr)
+	if err != nil {
+		return []backendcmd.Check{{Name: "kernel version", Err: fmt.Errorf("error parsing kernel version %q: %w", kernelVersionStr, err)}}
+	}
+
+	checks := []backendcmd.Check{{Name: "kernel version", Detail: kernelVersionStr}}
+
+	modules, err := kernelHandler.GetModules()
+	if err != nil {
+		return append(checks, backendcmd.Check{Name: "IPVS kernel modules", Err: fmt.Errorf("error listing kernel modules: %w", err)})
+	}
+	loaded := make(map[string]bool, len(modules))
+	for _, module := range modules {
+		loaded[module] = true
+	}
+
+	required := util.GetRequiredIPVSModules(kernelVersion)
+	missing := []string{}
+	for _, module := range required {
+		if !loaded[module] {
+			missing = append(missing, module)
+		}
+	}
+
+	check := backendcmd.Check{Name: "IPVS kernel modules", Detail: strings.Join(required, ", ")}
+	if len(missing) != 0 {
+		check.Err = fmt.Errorf("missing IPVS kernel modules %v", missing)
+	}
+	return append(checks, check)
+}
+
 func (s *Backend) Setup() {
 	kernelHandler := util.NewLinuxKernelHandler()
 	err := s.initializeKernelConfig(kernelHandler)
@@ -228,28 +299,30 @@ func (s *Backend) Setup() {
 		return
 	}
 
+	if err := checkForwardingMethod(s.forwardingMethod); err != nil {
+		klog.Fatal(err)
+	}
+
 	ipvs.Init()
 
 	s.createIPVSDummyInterface()
 
-	// Generate the masquerade mark to use for SNAT rules.
-	//TODO fetch masqueradeBit from config
-	masqueradeBit := 14
-	masqueradeValue := 1 << uint(masqueradeBit)
-	masqueradeMark := fmt.Sprintf("%#08x", masqueradeValue)
-
 	// Create a ipset utils.
 	execer := exec.New()
 	ipsetInterface := util.New(execer)
 
 	for _, ipFamily := range []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol} {
-		var nodeIPs []string
-
-		for _, nodeIP := range s.nodeAddresses {
-			if ipFamily == getIPFamily(nodeIP) {
-				nodeIPs = append(nodeIPs, nodeIP)
-			}
+		// Generate the masquerade marks to use for SNAT rules.
+		masqueradeBits := s.ipv4MasqueradeBits
+		if ipFamily == v1.IPv6Protocol {
+			masqueradeBits = s.ipv6MasqueradeBits
 		}
+		masqueradeMarks, err := newMasqueradeMarks(s.masqueradeBit, masqueradeBits)
+		if err != nil {
+			klog.Fatalf("invalid %s masquerade bits: %v", ipFamily, err)
+		}
+
+		nodeIPs := addressesOfFamily(s.nodeAddresses, ipFamily)
 
 		iptInterface := util.NewIPTableInterface(execer, util.Protocol(ipFamily))
 
@@ -260,7 +333,8 @@ func (s *Backend) Setup() {
 			iptInterface,
 			nodeIPs,
 			s.schedulingMethod,
-			masqueradeMark,
+			s.forwardingMethod,
+			masqueradeMarks,
 			s.masqueradeAll,
 			s.weight,
 		)
@@ -268,6 +342,19 @@ func (s *Backend) Setup() {
 		s.proxiers[ipFamily].initializeIPSets()
 	}
 
+	if slowstart.Enabled() {
+		go s.rampUpLoop()
+	}
+
+	drain.OnChange(func(draining bool) {
+		for _, proxier := range s.proxiers {
+			proxier.setDraining(draining)
+		}
+	})
+	for _, proxier := range s.proxiers {
+		proxier.draining = drain.Draining()
+	}
+
 	go func() {
 		err := s.SetUpHttpListen()
 		if err != nil {
@@ -386,6 +473,8 @@ func (s *Backend) Sync() {
 	for _, proxier := range s.proxiers {
 		proxier.sync()
 	}
+
+	slowstart.Default().Synced()
 }
 
 func (s *Backend) addServiceIPToKubeIPVSIntf(serviceIP string) {
@@ -462,12 +551,15 @@ func (s *Backend) initializeKernelConfig(kernelHandler util.KernelHandler) error
 		}
 	}
 
-	// Set the expire_nodest_conn sysctl we need for
+	// Set the expire_nodest_conn sysctl we need for expiring the connections of deleted
+	// destinations
 	if err := util.EnsureSysctl(sysctl, sysctlExpireNoDestConn, 1); err != nil {
 		return err
 	}
 
-	// Set the expire_quiescent_template sysctl we need for
+	// Set the expire_quiescent_template sysctl we need for expiring the persistence templates
+	// (session affinity) of the drained destinations: with expire_nodest_conn, the clients of a
+	// removed endpoint are scheduled again on their next packet, no affinity reset is needed
 	if err := util.EnsureSysctl(sysctl, sysctlExpireQuiescentTemplate, 1); err != nil {
 		return err
 	}
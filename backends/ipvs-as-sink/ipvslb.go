@@ -87,6 +87,7 @@ func ipvsDestination(epInfo endPointInfo, port *BaseServicePortInfo) ipvs.Destin
 	return ipvs.Destination{
 		Address: net.ParseIP(epInfo.endPointIP),
 		Port:    uint16(targetPort),
-		Weight:  port.weight,
+		Weight:  epInfo.weight,
+		Flags:   destinationFlags(port.forwardingMethod),
 	}
 }
@@ -59,17 +59,19 @@ func (s *Backend) deleteLbService(svc *localv1.Service, serviceIP string, IPKind
 		//---------------------------------------------------------------------------
 
 		// --------------------------------------------------------------------------
-		// NodeIPs needs to be removed from IPVS
-		for _, nodeIP := range p.nodeAddresses {
-			spKey = getServicePortKey(serviceKey, nodeIP, port)
-			kv := p.servicePorts.GetByPrefix([]byte(spKey))
-			portInfo := kv[0].Value.(BaseServicePortInfo)
-			portList = append(portList, &portInfo)
-			p.servicePorts.DeleteByPrefix([]byte(spKey))
-
-			p.deleteVirtualServer(&portInfo)
+		// NodeIPs needs to be removed from IPVS, unless the service has no node port
+		if svc.HasNodePort(port) {
+			for _, nodeIP := range p.nodeAddresses {
+				spKey = getServicePortKey(serviceKey, nodeIP, port)
+				kv := p.servicePorts.GetByPrefix([]byte(spKey))
+				portInfo := kv[0].Value.(BaseServicePortInfo)
+				portList = append(portList, &portInfo)
+				p.servicePorts.DeleteByPrefix([]byte(spKey))
+
+				p.deleteVirtualServer(&portInfo)
+			}
+			p.AddOrDelNodePortInIPSet(port, DeleteService)
 		}
-		p.AddOrDelNodePortInIPSet(port, DeleteService)
 		// --------------------------------------------------------------------------
 	}
 
@@ -110,7 +112,7 @@ func (p *proxier) handleNewLBService(serviceKey, serviceIP string,
 		// --------------------------------------------------------------------------
 		// ClusterIP needs to be programmed in IPVS
 		spKey := getServicePortKey(serviceKey, serviceIP, port)
-		portInfo := NewBaseServicePortInfo(svc, port, serviceIP, ClusterIPService, p.schedulingMethod, p.weight)
+		portInfo := NewBaseServicePortInfo(svc, port, serviceIP, ClusterIPService, p.schedulingMethod, p.forwardingMethod, p.weight)
 		p.servicePorts.Set([]byte(spKey), 0, *portInfo)
 
 		p.addVirtualServer(portInfo)
@@ -120,21 +122,23 @@ func (p *proxier) handleNewLBService(serviceKey, serviceIP string,
 		//---------------------------------------------------------------------------
 
 		// --------------------------------------------------------------------------
-		// NodeIPs needs to be programmed in IPVS
-		for _, nodeIP := range p.nodeAddresses {
-			spKey := getServicePortKey(serviceKey, nodeIP, port)
-			portInfo = NewBaseServicePortInfo(svc, port, nodeIP, NodePortService, p.schedulingMethod, p.weight)
-			p.servicePorts.Set([]byte(spKey), 0, *portInfo)
-
-			p.addVirtualServer(portInfo)
+		// NodeIPs needs to be programmed in IPVS, unless the service has no node port
+		if svc.HasNodePort(port) {
+			for _, nodeIP := range p.nodeAddresses {
+				spKey := getServicePortKey(serviceKey, nodeIP, port)
+				portInfo = NewBaseServicePortInfo(svc, port, nodeIP, NodePortService, p.schedulingMethod, p.forwardingMethod, p.weight)
+				p.servicePorts.Set([]byte(spKey), 0, *portInfo)
+
+				p.addVirtualServer(portInfo)
+			}
+			p.AddOrDelNodePortInIPSet(port, AddService)
 		}
-		p.AddOrDelNodePortInIPSet(port, AddService)
 		// --------------------------------------------------------------------------
 	}
 
 	if IPKind == serviceevents.LoadBalancerIP {
 		spKey := getServicePortKey(serviceKey, serviceIP, port)
-		portInfo := NewBaseServicePortInfo(svc, port, serviceIP, LoadBalancerService, p.schedulingMethod, p.weight)
+		portInfo := NewBaseServicePortInfo(svc, port, serviceIP, LoadBalancerService, p.schedulingMethod, p.forwardingMethod, p.weight)
 		p.servicePorts.Set([]byte(spKey), 0, *portInfo)
 
 		p.addVirtualServer(portInfo)
@@ -148,10 +152,6 @@ func (p *proxier) handleUpdatedLBService(serviceKey, serviceIP string,
 	svc *localv1.Service,
 	port *localv1.PortMapping,
 ) {
-	err, lbIP := p.getLbIPForIPFamily(svc)
-	if err != nil {
-		klog.Info(err)
-	}
 	portMapKey := getPortKey(serviceKey, port)
 	p.portMap[serviceKey][portMapKey] = *port
 
@@ -161,7 +161,7 @@ func (p *proxier) handleUpdatedLBService(serviceKey, serviceIP string,
 		// --------------------------------------------------------------------------
 		// ClusterIP needs to be programmed in IPVS
 		spKey = getServicePortKey(serviceKey, serviceIP, port)
-		portInfo := NewBaseServicePortInfo(svc, port, serviceIP, ClusterIPService, p.schedulingMethod, p.weight)
+		portInfo := NewBaseServicePortInfo(svc, port, serviceIP, ClusterIPService, p.schedulingMethod, p.forwardingMethod, p.weight)
 		p.servicePorts.Set([]byte(spKey), 0, *portInfo)
 		portList = append(portList, portInfo)
 
@@ -172,23 +172,26 @@ func (p *proxier) handleUpdatedLBService(serviceKey, serviceIP string,
 		//---------------------------------------------------------------------------
 
 		// --------------------------------------------------------------------------
-		// NodeIPs needs to be programmed in IPVS
-		for _, nodeIP := range p.nodeAddresses {
-			spKey := getServicePortKey(serviceKey, nodeIP, port)
-			portInfo = NewBaseServicePortInfo(svc, port, nodeIP, NodePortService, p.schedulingMethod, p.weight)
-			p.servicePorts.Set([]byte(spKey), 0, *portInfo)
-			portList = append(portList, portInfo)
-
-			p.addVirtualServer(portInfo)
+		// NodeIPs needs to be programmed in IPVS, unless the service has no node port
+		if svc.HasNodePort(port) {
+			for _, nodeIP := range p.nodeAddresses {
+				spKey := getServicePortKey(serviceKey, nodeIP, port)
+				portInfo = NewBaseServicePortInfo(svc, port, nodeIP, NodePortService, p.schedulingMethod, p.forwardingMethod, p.weight)
+				p.servicePorts.Set([]byte(spKey), 0, *portInfo)
+				portList = append(portList, portInfo)
+
+				p.addVirtualServer(portInfo)
+			}
+			p.AddOrDelNodePortInIPSet(port, AddService)
 		}
-		p.AddOrDelNodePortInIPSet(port, AddService)
 		// --------------------------------------------------------------------------
 	}
 
 	if IPKind == serviceevents.LoadBalancerIP {
-		// LbIP needs to be programmed in IPVS
-		spKey = getServicePortKey(serviceKey, lbIP, port)
-		portInfo := NewBaseServicePortInfo(svc, port, lbIP, LoadBalancerService, p.schedulingMethod, p.weight)
+		// LbIP needs to be programmed in IPVS, each of the service's
+		// load balancer IPs being notified on its own.
+		spKey = getServicePortKey(serviceKey, serviceIP, port)
+		portInfo := NewBaseServicePortInfo(svc, port, serviceIP, LoadBalancerService, p.schedulingMethod, p.forwardingMethod, p.weight)
 		p.servicePorts.Set([]byte(spKey), 0, *portInfo)
 		portList = append(portList, portInfo)
 
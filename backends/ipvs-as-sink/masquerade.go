@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipvssink
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kpng/backends/ipvs-as-sink/util"
+)
+
+// trafficClass is a kind of service traffic requiring SNAT. Each class is marked by its own chain,
+// so its fwmark bit can be moved away from the bits used by the CNI.
+type trafficClass string
+
+const (
+	clusterIPTraffic trafficClass = "clusterip"
+	nodePortTraffic  trafficClass = "nodeport"
+	externalTraffic  trafficClass = "external"
+)
+
+var trafficClasses = []trafficClass{clusterIPTraffic, nodePortTraffic, externalTraffic}
+
+const (
+	// KubeMarkMasqClusterIPChain marks the cluster IP traffic for masquerade
+	KubeMarkMasqClusterIPChain util.Chain = "KUBE-MARK-MASQ-CLUSTERIP"
+
+	// KubeMarkMasqNodePortChain marks the node port traffic for masquerade
+	KubeMarkMasqNodePortChain util.Chain = "KUBE-MARK-MASQ-NODEPORT"
+
+	// KubeMarkMasqExternalChain marks the external IP and load balancer traffic for masquerade
+	KubeMarkMasqExternalChain util.Chain = "KUBE-MARK-MASQ-EXTERNAL"
+)
+
+var markMasqChains = map[trafficClass]util.Chain{
+	clusterIPTraffic: KubeMarkMasqClusterIPChain,
+	nodePortTraffic:  KubeMarkMasqNodePortChain,
+	externalTraffic:  KubeMarkMasqExternalChain,
+}
+
+// masqueradeMarks are the fwmarks of an IP family.
+type masqueradeMarks struct {
+	// base is set by KUBE-MARK-MASQ, still used by other components (ie: the kubelet).
+	base    uint32
+	classes map[trafficClass]uint32
+}
+
+// newMasqueradeMarks returns the marks given the default bit and the bits of the classes
+// overriding it (class name -> bit).
+func newMasqueradeMarks(defaultBit int, bits map[string]int) (masqueradeMarks, error) {
+	if err := checkMasqueradeBit(defaultBit); err != nil {
+		return masqueradeMarks{}, err
+	}
+
+	m := masqueradeMarks{
+		base:    1 << uint(defaultBit),
+		classes: make(map[trafficClass]uint32, len(trafficClasses)),
+	}
+	for _, class := range trafficClasses {
+		m.classes[class] = m.base
+	}
+
+	for name, bit := range bits {
+		class := trafficClass(name)
+		if _, ok := m.classes[class]; !ok {
+			return masqueradeMarks{}, fmt.Errorf("unknown traffic class %q (expected one of %v)", name, trafficClasses)
+		}
+		if err := checkMasqueradeBit(bit); err != nil {
+			return masqueradeMarks{}, fmt.Errorf("%s: %w", name, err)
+		}
+		m.classes[class] = 1 << uint(bit)
+	}
+
+	return m, nil
+}
+
+func checkMasqueradeBit(bit int) error {
+	if bit < 0 || bit > 31 {
+		return fmt.Errorf("invalid masquerade bit %d, must be within the range [0, 31]", bit)
+	}
+	return nil
+}
+
+// mask returns the union of the marks.
+func (m masqueradeMarks) mask() uint32 {
+	mask := m.base
+	for _, mark := range m.classes {
+		mask |= mark
+	}
+	return mask
+}
+
+func (m masqueradeMarks) classMark(class trafficClass) string {
+	return formatMark(m.classes[class])
+}
+
+func formatMark(mark uint32) string {
+	return fmt.Sprintf("%#08x", mark)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipvssink
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMasqueradeMarks(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		defaultBit int
+		bits       map[string]int
+		marks      map[trafficClass]string
+		mask       uint32
+		error      bool
+	}{
+		{
+			name:       "default",
+			defaultBit: 14,
+			marks: map[trafficClass]string{
+				clusterIPTraffic: "0x00004000",
+				nodePortTraffic:  "0x00004000",
+				externalTraffic:  "0x00004000",
+			},
+			mask: 0x4000,
+		},
+		{
+			name:       "per class",
+			defaultBit: 14,
+			bits:       map[string]int{"nodeport": 15, "external": 16},
+			marks: map[trafficClass]string{
+				clusterIPTraffic: "0x00004000",
+				nodePortTraffic:  "0x00008000",
+				externalTraffic:  "0x00010000",
+			},
+			mask: 0x1c000,
+		},
+		{
+			name:       "unknown class",
+			defaultBit: 14,
+			bits:       map[string]int{"loadbalancer": 15},
+			error:      true,
+		},
+		{
+			name:       "invalid bit",
+			defaultBit: 14,
+			bits:       map[string]int{"nodeport": 32},
+			error:      true,
+		},
+		{
+			name:       "invalid default bit",
+			defaultBit: -1,
+			error:      true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := newMasqueradeMarks(tc.defaultBit, tc.bits)
+			if tc.error {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			for class, mark := range tc.marks {
+				assert.Equal(t, mark, m.classMark(class), class)
+			}
+			assert.Equal(t, tc.mask, m.mask())
+		})
+	}
+}
@@ -84,7 +84,7 @@ func (p *proxier) handleNewNodePortService(serviceKey, clusterIP string, svc *lo
 	// All Node Addresses need to be added as virtual servers in IPVS.
 	for _, nodeIP := range p.nodeAddresses {
 		spKey := getServicePortKey(serviceKey, nodeIP, port)
-		portInfo := NewBaseServicePortInfo(svc, port, nodeIP, NodePortService, p.schedulingMethod, p.weight)
+		portInfo := NewBaseServicePortInfo(svc, port, nodeIP, NodePortService, p.schedulingMethod, p.forwardingMethod, p.weight)
 		p.servicePorts.Set([]byte(spKey), 0, *portInfo)
 
 		p.addVirtualServer(portInfo)
@@ -94,7 +94,7 @@ func (p *proxier) handleNewNodePortService(serviceKey, clusterIP string, svc *lo
 
 	// ClusterIP of nodePort service needs to be added as virtual servers in IPVS.
 	spKey := getServicePortKey(serviceKey, clusterIP, port)
-	portInfo := NewBaseServicePortInfo(svc, port, clusterIP, ClusterIPService, p.schedulingMethod, p.weight)
+	portInfo := NewBaseServicePortInfo(svc, port, clusterIP, ClusterIPService, p.schedulingMethod, p.forwardingMethod, p.weight)
 	p.servicePorts.Set([]byte(spKey), 0, *portInfo)
 
 	p.addVirtualServer(portInfo)
@@ -116,7 +116,7 @@ func (p *proxier) handleUpdatedNodePortService(serviceKey, clusterIP string, svc
 	var portList []*BaseServicePortInfo
 	for _, nodeIP := range p.nodeAddresses {
 		spKey := getServicePortKey(serviceKey, nodeIP, port)
-		portInfo := NewBaseServicePortInfo(svc, port, nodeIP, NodePortService, p.schedulingMethod, p.weight)
+		portInfo := NewBaseServicePortInfo(svc, port, nodeIP, NodePortService, p.schedulingMethod, p.forwardingMethod, p.weight)
 		p.servicePorts.Set([]byte(spKey), 0, *portInfo)
 		portList = append(portList, portInfo)
 
@@ -128,7 +128,7 @@ func (p *proxier) handleUpdatedNodePortService(serviceKey, clusterIP string, svc
 	// --------------------------------------------------------------------------
 	// ClusterIP of nodePort service needs to be updated with new port as virtual servers in IPVS.
 	spKey := getServicePortKey(serviceKey, clusterIP, port)
-	portInfo := NewBaseServicePortInfo(svc, port, clusterIP, ClusterIPService, p.schedulingMethod, p.weight)
+	portInfo := NewBaseServicePortInfo(svc, port, clusterIP, ClusterIPService, p.schedulingMethod, p.forwardingMethod, p.weight)
 	p.servicePorts.Set([]byte(spKey), 0, *portInfo)
 
 	portList = append(portList, portInfo)
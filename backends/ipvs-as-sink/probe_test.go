@@ -0,0 +1,48 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipvssink
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeKernelHandler struct {
+	version string
+	modules []string
+}
+
+func (h fakeKernelHandler) GetModules() ([]string, error)     { return h.modules, nil }
+func (h fakeKernelHandler) GetKernelVersion() (string, error) { return h.version, nil }
+
+func TestKernelChecks(t *testing.T) {
+	checks := kernelChecks(fakeKernelHandler{
+		version: "5.15.0-56-generic",
+		modules: []string{"ip_vs", "ip_vs_rr", "ip_vs_wrr", "ip_vs_sh", "nf_conntrack"},
+	})
+	assert.Len(t, checks, 2)
+	assert.Equal(t, "5.15.0-56-generic", checks[0].Detail)
+	assert.NoError(t, checks[1].Err)
+
+	checks = kernelChecks(fakeKernelHandler{version: "4.14.0", modules: []string{"ip_vs", "ip_vs_rr", "ip_vs_wrr", "ip_vs_sh"}})
+	assert.EqualError(t, checks[1].Err, "missing IPVS kernel modules [nf_conntrack_ipv4]")
+
+	checks = kernelChecks(fakeKernelHandler{version: "not a version"})
+	assert.Len(t, checks, 1)
+	assert.Error(t, checks[0].Err)
+}
@@ -18,6 +18,7 @@ package ipvssink
 
 import (
 	"bytes"
+	"sync"
 
 	"github.com/vishvananda/netlink"
 	v1 "k8s.io/api/core/v1"
@@ -35,11 +36,14 @@ type proxier struct {
 	dryRun           bool
 	nodeAddresses    []string
 	schedulingMethod string
+	forwardingMethod string
 	weight           int32
-	masqueradeMark   string
+	masqueradeMarks  masqueradeMarks
 	masqueradeAll    bool
 
 	dummy netlink.Link
+	// tunnelReady is true once the tunnel interface is up (see prepareTunnel)
+	tunnelReady bool
 
 	iptables util.IPTableInterface
 	ipset    util.Interface
@@ -67,6 +71,15 @@ type proxier struct {
 	filterChains     iptablesutil.LineBuffer
 	natRules         iptablesutil.LineBuffer
 	filterRules      iptablesutil.LineBuffer
+
+	// endpoint key -> destinations ramping up, with their full weight (see slowstart)
+	rampingMu sync.Mutex
+	ramping   map[string][]ipvsSvcDst
+
+	// destinations of the node ports and externally routed IPs, with their full weight (see drain)
+	drainMu   sync.Mutex
+	draining  bool
+	drainable map[string]ipvsSvcDst
 }
 
 func NewProxier(ipFamily v1.IPFamily,
@@ -74,7 +87,9 @@ func NewProxier(ipFamily v1.IPFamily,
 	ipsetInterface util.Interface,
 	iptInterface util.IPTableInterface,
 	nodeIPs []string,
-	schedulingMethod, masqueradeMark string,
+	schedulingMethod string,
+	forwardingMethod string,
+	masqueradeMarks masqueradeMarks,
 	masqueradeAll bool,
 	weight int32) *proxier {
 	return &proxier{
@@ -82,10 +97,11 @@ func NewProxier(ipFamily v1.IPFamily,
 		dummy:            dummy,
 		nodeAddresses:    nodeIPs,
 		schedulingMethod: schedulingMethod,
+		forwardingMethod: forwardingMethod,
 		weight:           weight,
 		ipset:            ipsetInterface,
 		iptables:         iptInterface,
-		masqueradeMark:   masqueradeMark,
+		masqueradeMarks:  masqueradeMarks,
 		masqueradeAll:    masqueradeAll,
 		ipsetList:        make(map[string]*IPSet),
 		portMap:          make(map[string]map[string]localv1.PortMapping),
@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipvssink
+
+import (
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/kpng/client/slowstart"
+)
+
+// rampDestination lowers the weight of the destination of an endpoint ramping up (see slowstart),
+// and keeps track of it to raise its weight progressively. Drained destinations don't ramp up.
+func (p *proxier) rampDestination(endpointKey string, dest *ipvsSvcDst) {
+	ramp := slowstart.Default()
+	if !ramp.Ramping(endpointKey) || dest.Dst.Weight == 0 {
+		return
+	}
+
+	p.rampingMu.Lock()
+	defer p.rampingMu.Unlock()
+
+	if p.ramping == nil {
+		p.ramping = map[string][]ipvsSvcDst{}
+	}
+	p.ramping[endpointKey] = append(p.ramping[endpointKey], *dest)
+
+	dest.Dst.Weight = ramp.Weight(endpointKey, dest.Dst.Weight)
+}
+
+// stopRamp forgets the destinations of a deleted endpoint.
+func (p *proxier) stopRamp(endpointKey string) {
+	slowstart.Default().Delete(endpointKey)
+
+	p.rampingMu.Lock()
+	defer p.rampingMu.Unlock()
+
+	delete(p.ramping, endpointKey)
+}
+
+// updateRampingWeights raises the weights of the destinations ramping up.
+func (p *proxier) updateRampingWeights() {
+	ramp := slowstart.Default()
+
+	p.rampingMu.Lock()
+	defer p.rampingMu.Unlock()
+
+	for endpointKey, dests := range p.ramping {
+		done := !ramp.Ramping(endpointKey)
+
+		for _, dest := range dests {
+			if p.isDrained(dest) {
+				continue
+			}
+
+			dst := dest.Dst
+			dst.Weight = ramp.Weight(endpointKey, dst.Weight)
+
+			if err := updateDestination(dest.Svc, dst); err != nil {
+				// the service may be gone
+				klog.V(1).Info("failed to update the weight of destination ", dest, ": ", err)
+			}
+		}
+
+		if done {
+			delete(p.ramping, endpointKey)
+		}
+	}
+}
+
+func (s *Backend) rampUpLoop() {
+	ticker := time.NewTicker(slowstart.Default().Step())
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, proxier := range s.proxiers {
+			proxier.updateRampingWeights()
+		}
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipvssink
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/kpng/client/localsink/fullstate"
+	"sigs.k8s.io/kpng/client/localsink/validate"
+)
+
+// loopBackMaxElem is the maxelem of the KUBE-LOOP-BACK ipsets (the ipset default).
+var loopBackMaxElem = 65536
+
+var _ validate.Validator = &Backend{}
+
+// ValidateState rejects the services whose local endpoints would overflow the KUBE-LOOP-BACK ipset
+// of their IP family, holding an entry per local endpoint IP and target port of all the services.
+// The services are accepted in order until the set is full.
+func (s *Backend) ValidateState(state []*fullstate.ServiceEndpoints) (rejected []validate.Rejection) {
+	entries := map[v1.IPFamily]map[string]bool{
+		v1.IPv4Protocol: {},
+		v1.IPv6Protocol: {},
+	}
+
+	for _, seps := range state {
+		added := map[v1.IPFamily]map[string]bool{}
+		for _, ep := range seps.Endpoints {
+			if !ep.Local {
+				continue
+			}
+			for _, ip := range ep.IPs.All() {
+				family := getIPFamily(ip)
+				for _, port := range seps.Service.Ports {
+					entry := getEndPointEntry(ip, port.Protocol.String(), port.TargetPort).String()
+					if entries[family][entry] {
+						continue
+					}
+					if added[family] == nil {
+						added[family] = map[string]bool{}
+					}
+					added[family][entry] = true
+				}
+			}
+		}
+
+		overflow := false
+		for family, familyEntries := range added {
+			if len(entries[family])+len(familyEntries) > loopBackMaxElem {
+				rejected = append(rejected, validate.Rejection{
+					Namespace: seps.Service.Namespace,
+					Name:      seps.Service.Name,
+					Reason: fmt.Sprintf("its %d local %s endpoint ports would overflow the %d entries of the %s ipset",
+						len(familyEntries), family, loopBackMaxElem, kubeLoopBackIPSet),
+				})
+				overflow = true
+				break
+			}
+		}
+		if overflow {
+			continue
+		}
+
+		for family, familyEntries := range added {
+			for entry := range familyEntries {
+				entries[family][entry] = true
+			}
+		}
+	}
+	return
+}
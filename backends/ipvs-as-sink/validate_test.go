@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipvssink
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink/fullstate"
+)
+
+func TestValidateState(t *testing.T) {
+	prevMaxElem := loopBackMaxElem
+	defer func() { loopBackMaxElem = prevMaxElem }()
+	loopBackMaxElem = 3
+
+	service := func(name string, ports int, endpoints ...*localv1.Endpoint) *fullstate.ServiceEndpoints {
+		svc := &localv1.Service{Namespace: "ns", Name: name}
+		for i := 0; i < ports; i++ {
+			svc.Ports = append(svc.Ports, &localv1.PortMapping{Protocol: localv1.Protocol_TCP, Port: int32(80 + i), TargetPort: int32(8080 + i)})
+		}
+		return &fullstate.ServiceEndpoints{Service: svc, Endpoints: endpoints}
+	}
+	local := func(ips ...string) *localv1.Endpoint {
+		return &localv1.Endpoint{IPs: localv1.NewIPSet(ips...), Local: true}
+	}
+	remote := &localv1.Endpoint{IPs: localv1.NewIPSet("10.2.0.1", "10.2.0.2", "10.2.0.3", "10.2.0.4")}
+
+	rejected := (&Backend{}).ValidateState([]*fullstate.ServiceEndpoints{
+		// 2 entries
+		service("a", 2, local("10.1.0.1"), remote),
+		// the same entry as a
+		service("b", 1, local("10.1.0.1")),
+		// 2 more entries, rejected
+		service("c", 1, local("10.1.0.2"), local("10.1.0.3")),
+		// 1 IPv4 and 1 IPv6 entry, accepted
+		service("d", 1, local("10.1.0.4", "fd00::1")),
+		// IPv4 full
+		service("e", 1, local("10.1.0.5")),
+	})
+
+	names := []string{}
+	for _, r := range rejected {
+		names = append(names, r.Name)
+	}
+	assert.Equal(t, []string{"c", "e"}, names)
+	assert.Equal(t, "ns/c: its 2 local IPv4 endpoint ports would overflow the 3 entries of the KUBE-LOOP-BACK ipset", rejected[0].String())
+}
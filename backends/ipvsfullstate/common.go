@@ -28,11 +28,12 @@ func getNodeIPs() []string {
 	return *NodeAddresses
 }
 
-// getLoadBalancerIPs safely returns LoadBalancerIPs associated with the service.
+// getLoadBalancerIPs safely returns LoadBalancerIPs associated with the service, without the ones
+// in Proxy ipMode.
 func getLoadBalancerIPs(service *localv1.Service) []string {
 	IPs := make([]string, 0)
-	if service.IPs.LoadBalancerIPs != nil {
-		return service.IPs.LoadBalancerIPs.V4
+	if vips := service.IPs.LoadBalancerVIPs(); vips != nil {
+		return vips.V4
 	}
 	return IPs
 }
@@ -21,6 +21,10 @@ func (c *Controller) addServiceEndpointsForNodePort(serviceEndpoints *client.Ser
 
 	// iterate over service ports
 	for _, portMapping := range service.Ports {
+		// LoadBalancer services may not have node ports (allocateLoadBalancerNodePorts=false)
+		if !service.HasNodePort(portMapping) {
+			continue
+		}
 
 		// iterate over NodeIPs
 		for _, nodeIP := range getNodeIPs() {
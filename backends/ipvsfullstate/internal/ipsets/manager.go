@@ -2,8 +2,8 @@ package ipsets
 
 import (
 	"k8s.io/klog/v2"
-	"k8s.io/utils/exec"
 	"sigs.k8s.io/kpng/client/diffstore"
+	"sigs.k8s.io/kpng/client/privhelper"
 )
 
 // Manager acts as a proxy between backend and IPSET operations, leverages diffstore to maintain
@@ -38,7 +38,7 @@ func (m *Manager) Reset() {
 
 // CreateSet doesn't use diffstore, straightaway creates the set and add it to ipsetMap.
 func (m *Manager) CreateSet(name string, setType SetType, comment string) (*Set, error) {
-	set := newIPSet(New(exec.New()), name, setType, ProtocolFamilyIPV4, comment)
+	set := newIPSet(New(privhelper.Exec()), name, setType, ProtocolFamilyIPV4, comment)
 	m.ipsetMap[name] = set
 	return set, ensureIPSet(set)
 }
@@ -3,7 +3,7 @@ package iptables
 import (
 	"io"
 	"k8s.io/klog/v2"
-	"k8s.io/utils/exec"
+	"sigs.k8s.io/kpng/client/privhelper"
 	"text/template"
 )
 
@@ -87,7 +87,7 @@ func (m *Manager) Apply() {
 
 	//########################################################################
 
-	runner := exec.New()
+	runner := privhelper.Exec()
 	cmd := runner.Command(iptablesRestoreCmd)
 	cmd.SetStdin(reader)
 
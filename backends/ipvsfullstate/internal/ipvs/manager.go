@@ -7,7 +7,9 @@ import (
 	"github.com/vishvananda/netlink"
 	"k8s.io/klog/v2"
 	"net"
+	"sigs.k8s.io/kpng/client/auditlog"
 	"sigs.k8s.io/kpng/client/diffstore"
+	"sigs.k8s.io/kpng/client/faultinject"
 )
 
 // Manager acts as a proxy between backend and IPVS operations, leverages diffstore to maintain
@@ -129,6 +131,8 @@ func (m *Manager) Apply() {
 		if err != nil {
 			klog.V(2).ErrorS(err, "failed to remove destination from server",
 				"server", virtualServer.IPPort(), "destination", destination.IPPort())
+		} else {
+			auditlog.Record("ipvs", auditlog.Delete, "real-server", destination.Key(), "")
 		}
 	}
 
@@ -141,6 +145,8 @@ func (m *Manager) Apply() {
 
 		if err != nil {
 			klog.V(2).ErrorS(err, "failed to delete server", "server", virtualServer.IPPort())
+		} else {
+			auditlog.Record("ipvs", auditlog.Delete, "virtual-server", virtualServer.Key(), "")
 		}
 	}
 
@@ -156,6 +162,8 @@ func (m *Manager) Apply() {
 
 			if err != nil {
 				klog.V(2).ErrorS(err, "failed to create server", "server", virtualServer.IPPort())
+			} else {
+				auditlog.Record("ipvs", auditlog.Add, "virtual-server", virtualServer.Key(), "")
 			}
 		} else if item.Updated() {
 
@@ -166,6 +174,8 @@ func (m *Manager) Apply() {
 
 			if err != nil {
 				klog.V(2).ErrorS(err, "failed to update server", "server", virtualServer.IPPort())
+			} else {
+				auditlog.Record("ipvs", auditlog.Update, "virtual-server", virtualServer.Key(), "")
 			}
 
 		}
@@ -189,6 +199,8 @@ func (m *Manager) Apply() {
 			if err != nil {
 				klog.V(2).ErrorS(err, "failed to add destination to server",
 					"server", virtualServer.IPPort(), "destination", destination.IPPort())
+			} else {
+				auditlog.Record("ipvs", auditlog.Add, "real-server", destination.Key(), "")
 			}
 		} else if item.Updated() {
 			// update destination of virtual server
@@ -203,6 +215,8 @@ func (m *Manager) Apply() {
 			if err != nil {
 				klog.V(2).ErrorS(err, "failed to update destination of server",
 					"server", virtualServer.IPPort(), "destination", destination.IPPort())
+			} else {
+				auditlog.Record("ipvs", auditlog.Update, "real-server", destination.Key(), "")
 			}
 
 		}
@@ -228,6 +242,10 @@ func asDummyIP(ip string) string {
 
 // bindIpToInterface adds IP address to the network interface.
 func (m *Manager) bindIpToInterface(ip string) error {
+	if err := faultinject.Inject("netlink addr add"); err != nil {
+		return err
+	}
+
 	_, ipNet, _ := net.ParseCIDR(asDummyIP(ip))
 
 	ipInterface, err := netlink.LinkByName(m.ipInterface)
@@ -245,6 +263,10 @@ func (m *Manager) bindIpToInterface(ip string) error {
 
 // bindIpToInterface removes IP address from the network interface.
 func (m *Manager) unbindIpFromInterface(ip string) error {
+	if err := faultinject.Inject("netlink addr del"); err != nil {
+		return err
+	}
+
 	_, ipNet, _ := net.ParseCIDR(asDummyIP(ip))
 
 	ipInterface, err := netlink.LinkByName(m.ipInterface)
@@ -55,6 +55,9 @@ func (b *backend) BindFlags(flags *pflag.FlagSet) {
 
 func (b *backend) Reset() { /* noop */ }
 
+// RecordsChanges is true: the changes of the virtual and real servers are recorded.
+func (b *backend) RecordsChanges() bool { return true }
+
 func (b *backend) Sync() { /* no-op */ }
 
 func (b *backend) Sink() localsink.Sink {
@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nft
+
+import (
+	"strings"
+
+	"sigs.k8s.io/kpng/client/auditlog"
+)
+
+// auditChanges records the maps, sets and chains written by the last sync. On a full resync,
+// the tables are replaced with all their elements.
+func auditChanges() {
+	if !auditlog.Enabled() {
+		return
+	}
+
+	for _, table := range allTables {
+		name := table.Family + " " + table.Name
+
+		if fullResync {
+			auditlog.Record("nft", auditlog.Add, "table", name, "")
+		}
+
+		for _, ks := range table.KindStores() {
+			if fullResync {
+				for _, item := range ks.Store.List() {
+					auditlog.Record("nft", auditlog.Add, ks.Kind, name+" "+item.Key(), elementService(item.Key()))
+				}
+				continue
+			}
+
+			for _, item := range ks.Store.Deleted() {
+				auditlog.Record("nft", auditlog.Delete, ks.Kind, name+" "+item.Key(), elementService(item.Key()))
+			}
+
+			for _, item := range ks.Store.Changed() {
+				op := auditlog.Update
+				if item.Created() {
+					op = auditlog.Add
+				}
+				auditlog.Record("nft", op, ks.Kind, name+" "+item.Key(), elementService(item.Key()))
+			}
+		}
+	}
+}
+
+// elementService returns the service of the elements named like svc_<namespace>_<name>_...
+func elementService(key string) string {
+	parts := strings.SplitN(key, "_", 4)
+	if len(parts) < 3 || parts[0] != "svc" {
+		return ""
+	}
+	return parts[1] + "/" + parts[2]
+}
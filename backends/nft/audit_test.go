@@ -0,0 +1,33 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nft
+
+import "testing"
+
+func TestElementService(t *testing.T) {
+	for key, expected := range map[string]string{
+		"svc_default_kubernetes_dnat":        "default/kubernetes",
+		"svc_default_kubernetes_ep_ac120002": "default/kubernetes",
+		"svc_ns_web_eps":                     "ns/web",
+		"z_dnat_all":                         "",
+		"svc_ns":                             "",
+	} {
+		if service := elementService(key); service != expected {
+			t.Errorf("%s: expected %q, got %q", key, expected, service)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nft
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/kpng/client/privhelper"
+)
+
+// tableComment marks the tables managed by kpng in coexistence mode.
+const tableComment = "managed by kpng"
+
+// hookPriorities are the priorities of the base chains, by "<type> <hook>", when adjusted to run
+// before the chains of other tables.
+var hookPriorities = map[string]int{}
+
+// hookPriority returns the priority of the base chain of type chainType on the given hook.
+func hookPriority(chainType, hook string) int {
+	if prio, ok := hookPriorities[chainType+" "+hook]; ok {
+		return prio
+	}
+	return *hookPrio
+}
+
+// baseChains are the hooks used by kpng's base chains.
+var baseChains = []string{"nat prerouting", "nat output", "nat postrouting", "filter forward", "filter output"}
+
+type nftTable struct {
+	Family  string `json:"family"`
+	Name    string `json:"name"`
+	Comment string `json:"comment"`
+}
+
+func (t nftTable) String() string {
+	return t.Family + " " + t.Name
+}
+
+type nftChain struct {
+	Family string `json:"family"`
+	Table  string `json:"table"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Hook   string `json:"hook"`
+	Prio   int    `json:"prio"`
+}
+
+// parseNftJSON parses the output of `nft -j list ...`.
+func parseNftJSON(data []byte) (tables []nftTable, chains []nftChain, err error) {
+	out := struct {
+		Nftables []struct {
+			Table *nftTable
+			Chain *json.RawMessage
+		}
+	}{}
+
+	if err = json.Unmarshal(data, &out); err != nil {
+		return
+	}
+
+	for _, obj := range out.Nftables {
+		if obj.Table != nil {
+			tables = append(tables, *obj.Table)
+		}
+		if obj.Chain != nil {
+			chain := nftChain{}
+			if err := json.Unmarshal(*obj.Chain, &chain); err != nil {
+				// priorities may not be numbers in some nft versions; we can't compare them anyway
+				klog.V(1).Infof("ignoring chain %s: %v", string(*obj.Chain), err)
+				continue
+			}
+			chains = append(chains, chain)
+		}
+	}
+
+	return
+}
+
+// coexistReport describes how kpng's tables would interact with the other tables.
+type coexistReport struct {
+	// Foreign are the tables with kpng's names but not managed by kpng.
+	Foreign []nftTable
+	// Conflicts are the base chains of other tables on the hooks used by kpng.
+	Conflicts []nftChain
+}
+
+func (r coexistReport) String() string {
+	if len(r.Foreign) == 0 && len(r.Conflicts) == 0 {
+		return "no conflict with other nft tables"
+	}
+
+	b := &strings.Builder{}
+
+	for _, t := range r.Foreign {
+		fmt.Fprintf(b, "\n- table %s is not managed by kpng (comment: %q)", t, t.Comment)
+	}
+	for _, c := range r.Conflicts {
+		fmt.Fprintf(b, "\n- chain %s of table %s %s hooks %s %s with priority %d", c.Name, c.Family, c.Table, c.Type, c.Hook, c.Prio)
+	}
+
+	return "nft conflicts:" + b.String()
+}
+
+// seesKpngTraffic returns true if the tables of the given family see the packets handled by kpng.
+func seesKpngTraffic(family string) bool {
+	if family == "inet" {
+		return true
+	}
+	for _, t := range allTables {
+		if t.Family == family {
+			return true
+		}
+	}
+	return false
+}
+
+func usesHook(hook string) bool {
+	for _, h := range baseChains {
+		if h == hook {
+			return true
+		}
+	}
+	return false
+}
+
+func isKpngTable(family, name string) bool {
+	for _, t := range allTables {
+		if t.Family == family && t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCoexistence builds the report of the existing tables and chains.
+func checkCoexistence(tables []nftTable, chains []nftChain) (report coexistReport) {
+	for _, t := range tables {
+		if isKpngTable(t.Family, t.Name) && t.Comment != tableComment {
+			report.Foreign = append(report.Foreign, t)
+		}
+	}
+
+	for _, c := range chains {
+		if c.Hook == "" || isKpngTable(c.Family, c.Table) {
+			continue
+		}
+
+		if usesHook(c.Type+" "+c.Hook) && seesKpngTraffic(c.Family) {
+			report.Conflicts = append(report.Conflicts, c)
+		}
+	}
+
+	sort.Slice(report.Conflicts, func(i, j int) bool {
+		a, b := report.Conflicts[i], report.Conflicts[j]
+		if a.Family+a.Table != b.Family+b.Table {
+			return a.Family+a.Table < b.Family+b.Table
+		}
+		return a.Name < b.Name
+	})
+
+	return
+}
+
+// adjustedPriorities returns the priorities making kpng's base chains run before the conflicting ones.
+func (r coexistReport) adjustedPriorities() map[string]int {
+	prios := map[string]int{}
+	for _, c := range r.Conflicts {
+		hook := c.Type + " " + c.Hook
+
+		prio := c.Prio - 1
+		if current, ok := prios[hook]; ok && current < prio {
+			continue
+		}
+		if prio >= *hookPrio {
+			// already before the other chain
+			continue
+		}
+		prios[hook] = prio
+	}
+	return prios
+}
+
+// setupCoexistence checks the existing tables and refuses to go on if kpng's tables are owned by
+// something else.
+func setupCoexistence() {
+	var tables []nftTable
+	var chains []nftChain
+
+	for _, what := range []string{"tables", "chains"} {
+		output, err := privhelper.Exec().Command("nft", "-j", "list", what).Output()
+		if err != nil {
+			klog.Fatalf("failed to list nft %s: %v", what, err)
+		}
+
+		t, c, err := parseNftJSON(output)
+		if err != nil {
+			klog.Fatalf("failed to parse nft %s: %v", what, err)
+		}
+
+		tables = append(tables, t...)
+		chains = append(chains, c...)
+	}
+
+	report := checkCoexistence(tables, chains)
+	klog.Info(report)
+
+	if len(report.Foreign) != 0 {
+		klog.Fatal("refusing to manage nft tables not created by kpng")
+	}
+
+	if *adjustPriorities {
+		hookPriorities = report.adjustedPriorities()
+		for hook, prio := range hookPriorities {
+			klog.Infof("using priority %d for the %s hook", prio, hook)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nft
+
+import (
+	"testing"
+)
+
+const firewalldTables = `{"nftables": [
+{"metainfo": {"version": "1.0.1", "release_name": "Fearless Fosdick #3", "json_schema_version": 1}},
+{"table": {"family": "inet", "name": "firewalld", "handle": 1}},
+{"table": {"family": "ip", "name": "k8s_svc", "handle": 2, "comment": "managed by kpng"}},
+{"table": {"family": "ip6", "name": "k8s_svc6", "handle": 3}}
+]}`
+
+const firewalldChains = `{"nftables": [
+{"metainfo": {"version": "1.0.1", "release_name": "Fearless Fosdick #3", "json_schema_version": 1}},
+{"chain": {"family": "inet", "table": "firewalld", "name": "nat_PREROUTING", "handle": 1, "type": "nat", "hook": "prerouting", "prio": -90, "policy": "accept"}},
+{"chain": {"family": "inet", "table": "firewalld", "name": "filter_FORWARD", "handle": 2, "type": "filter", "hook": "forward", "prio": 10, "policy": "accept"}},
+{"chain": {"family": "inet", "table": "firewalld", "name": "filter_INPUT", "handle": 3, "type": "filter", "hook": "input", "prio": 10, "policy": "accept"}},
+{"chain": {"family": "inet", "table": "firewalld", "name": "filter_IN_public", "handle": 4}},
+{"chain": {"family": "ip", "table": "k8s_svc", "name": "z_hook_nat_prerouting", "handle": 5, "type": "nat", "hook": "prerouting", "prio": 0}},
+{"chain": {"family": "arp", "table": "other", "name": "output", "handle": 6, "type": "filter", "hook": "output", "prio": 0}}
+]}`
+
+func TestCheckCoexistence(t *testing.T) {
+	tables, _, err := parseNftJSON([]byte(firewalldTables))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, chains, err := parseNftJSON([]byte(firewalldChains))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := checkCoexistence(tables, chains)
+
+	if len(report.Foreign) != 1 || report.Foreign[0].Name != "k8s_svc6" {
+		t.Errorf("expected k8s_svc6 to be foreign, got %v", report.Foreign)
+	}
+
+	if len(report.Conflicts) != 2 || report.Conflicts[0].Name != "filter_FORWARD" || report.Conflicts[1].Name != "nat_PREROUTING" {
+		t.Errorf("unexpected conflicts: %v", report.Conflicts)
+	}
+
+	prios := report.adjustedPriorities()
+	if len(prios) != 1 || prios["nat prerouting"] != -91 {
+		t.Errorf("unexpected priorities: %v", prios)
+	}
+}
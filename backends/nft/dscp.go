@@ -0,0 +1,74 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nft
+
+import (
+	"fmt"
+	"strconv"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+// dscpChain sets the DSCP of the packets sent to the services, jumped to from the mangle hooks.
+const dscpChain = "z_dscp"
+
+// addDSCPRules sets the DSCP of the packets sent to the IPs and node ports of a service.
+func (ctx *renderContext) addDSCPRules(svc *localv1.Service) {
+	if svc.DSCP == 0 {
+		return
+	}
+
+	family := ctx.table.Family
+
+	svcIPs := &localv1.IPSet{}
+	if svc.IPs.ClusterIPs != nil {
+		svcIPs.AddSet(svc.IPs.ClusterIPs)
+	}
+	svcIPs.AddSet(svc.IPs.ExternalIPs)
+	ips := ctx.table.IPsFromSet(svcIPs)
+
+	chain := ctx.table.Chains.Get(dscpChain)
+	set := " " + family + " dscp set " + strconv.Itoa(int(svc.DSCP&0x3f)) + "\n"
+
+	for _, port := range svc.Ports {
+		dport := protoMatch(port.Protocol)
+		if dport == "" {
+			continue
+		}
+
+		for _, ip := range ips {
+			chain.WriteString("  " + family + " daddr " + ip + " " + dport + " " + strconv.Itoa(int(port.Port)) + set)
+		}
+		if port.NodePort != 0 {
+			chain.WriteString("  " + mDAddrLocal + dport + " " + strconv.Itoa(int(port.NodePort)) + set)
+		}
+	}
+}
+
+// addDSCPChains jumps to the dscpChain from the mangle hooks, which run before the DNAT of the
+// services (the mangle priority, -150, or before the nat hooks if they run earlier).
+func addDSCPChains(table *nftable) {
+	for _, hook := range []struct{ typ, name string }{{"filter", "prerouting"}, {"route", "output"}} {
+		prio := -150
+		if natPrio := hookPriority("nat", hook.name); natPrio <= prio {
+			prio = natPrio - 1
+		}
+
+		fmt.Fprintf(table.Chains.Get("z_hook_mangle_"+hook.name),
+			"  type %s hook %s priority %d;\n  jump %s\n", hook.typ, hook.name, prio, dscpChain)
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nft
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kpng/client/fuzzstate"
+	"sigs.k8s.io/kpng/client/localsink/fullstate"
+)
+
+var (
+	// objects declared in the table blocks of a script
+	declRE = regexp.MustCompile(`^ (chain|map|set) (\S+) \{$`)
+	// chains and maps/sets referenced by the rules
+	jumpRE = regexp.MustCompile(`(?:jump|goto) ([^\s,;}]+)`)
+	refRE  = regexp.MustCompile(`@([^\s,;}]+)`)
+)
+
+func FuzzRender(f *testing.F) {
+	fuzzstate.Seeds(f)
+
+	f.Fuzz(func(t *testing.T, seed uint8, data []byte) {
+		state := fuzzstate.State(seed, data)
+
+		baseline := fuzzTables()
+		fuzzRender(baseline, nil)
+
+		tables := fuzzTables()
+		fuzzRender(tables, state)
+
+		checkDeclarations(t, fuzzScript(tables, true))
+
+		for _, table := range tables {
+			checkReferences(t, table)
+		}
+
+		// removing all the services must not leave any of their objects
+		for _, table := range tables {
+			table.Reset()
+		}
+		fuzzRender(tables, nil)
+
+		script := fuzzScript(tables, false)
+
+		for i, table := range tables {
+			for j, ks := range table.KindStores() {
+				if got, want := keys(ks.Store.List()), keys(baseline[i].KindStores()[j].Store.List()); got != want {
+					t.Errorf("%s %ss left after removing all the services: %s, expected %s", table.Family, ks.Kind, got, want)
+				}
+
+				for _, item := range ks.Store.Deleted() {
+					flush := "flush " + ks.Kind + " " + table.Family + " " + table.Name + " " + item.Key() + "\n"
+					if !strings.Contains(script, flush) {
+						t.Errorf("%s %s %s not flushed after removing all the services", table.Family, ks.Kind, item.Key())
+					}
+				}
+			}
+		}
+	})
+}
+
+func fuzzTables() []*nftable {
+	return []*nftable{newNftable("ip", "k8s_svc"), newNftable("ip6", "k8s_svc6")}
+}
+
+// fuzzRender renders the state like Callback does.
+func fuzzRender(tables []*nftable, state []*fullstate.ServiceEndpoints) {
+	ctxs := []*renderContext{
+		newRenderContext(tables[0], []string{"10.244.0.0/16"}, nil, net.CIDRMask(24, 32)),
+		newRenderContext(tables[1], []string{"fd00:10:244::/56"}, nil, net.CIDRMask(120, 128)),
+	}
+
+	for _, seps := range state {
+		// types we don't handle
+		if seps.Service.Type == "ExternalName" {
+			continue
+		}
+
+		for _, ctx := range ctxs {
+			ctx.addServiceEndpoints(seps)
+		}
+	}
+
+	for _, ctx := range ctxs {
+		ctx.Finalize()
+	}
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// fuzzScript returns the nft script applying the changes of the tables.
+func fuzzScript(tables []*nftable, full bool) string {
+	prevTables, prevFullResync := allTables, fullResync
+	defer func() { allTables, fullResync = prevTables, prevFullResync }()
+
+	allTables, fullResync = tables, full
+
+	out := new(bytes.Buffer)
+	renderNftables(nopCloser{out}, io.Discard)
+	return out.String()
+}
+
+func keys(items []*Item) string {
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		keys = append(keys, item.Key())
+	}
+	return strings.Join(keys, " ")
+}
+
+// checkDeclarations checks each object is declared once in each table of the script.
+func checkDeclarations(t *testing.T, script string) {
+	table := ""
+	declared := map[string]bool{}
+
+	for _, line := range strings.Split(script, "\n") {
+		if strings.HasPrefix(line, "table ") && strings.HasSuffix(line, " {") {
+			table = line
+			continue
+		}
+
+		m := declRE.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		key := table + " " + m[1] + " " + m[2]
+		if declared[key] {
+			t.Errorf("%s declared twice", key)
+		}
+		declared[key] = true
+	}
+}
+
+// checkReferences checks the rules and verdict maps only reference existing objects of the table.
+func checkReferences(t *testing.T, table *nftable) {
+	for _, item := range append(table.Chains.List(), table.Maps.List()...) {
+		rules := item.Value().String()
+
+		for _, m := range jumpRE.FindAllStringSubmatch(rules, -1) {
+			if !table.Chains.Has(m[1]) {
+				t.Errorf("%s %s jumps to the missing chain %s", table.Family, item.Key(), m[1])
+			}
+		}
+
+		for _, m := range refRE.FindAllStringSubmatch(rules, -1) {
+			if !table.Maps.Has(m[1]) && !table.Sets.Has(m[1]) {
+				t.Errorf("%s %s references the missing map or set %s", table.Family, item.Key(), m[1])
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nft
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"sigs.k8s.io/kpng/client/backendcmd"
+)
+
+// memoryRuleset is the ruleset of the last sync when the backend writes to memory instead of nft
+// (see UseMemoryKernel), nil otherwise.
+var memoryRuleset *bytes.Buffer
+
+var _ backendcmd.MemoryKernel = &backend{}
+
+// UseMemoryKernel makes the backend keep the whole ruleset of each sync in memory instead of
+// running nft.
+func (b *backend) UseMemoryKernel() {
+	memoryRuleset = &bytes.Buffer{}
+}
+
+// WriteKernelState writes the ruleset of the last sync like nft list ruleset.
+func (b *backend) WriteKernelState(w io.Writer) error {
+	_, err := w.Write(memoryRuleset.Bytes())
+	return err
+}
+
+// renderRuleset writes all the objects of the tables, whether they changed or not.
+func renderRuleset(out io.Writer) {
+	for _, table := range allTables {
+		fmt.Fprintf(out, "table %s %s {\n", table.Family, table.Name)
+		for _, ki := range table.OrderedChanges(true) {
+			fmt.Fprintf(out, " %s %s {\n", ki.Kind, ki.Item.Key())
+			out.Write(ki.Item.Value().Bytes())
+			fmt.Fprintln(out, " }")
+		}
+		fmt.Fprintln(out, "}")
+	}
+}
@@ -24,7 +24,6 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
-	"os/exec"
 	"strings"
 	"time"
 
@@ -32,6 +31,9 @@ import (
 	"k8s.io/klog/v2"
 
 	"sigs.k8s.io/kpng/client"
+	"sigs.k8s.io/kpng/client/faultinject"
+	"sigs.k8s.io/kpng/client/nodelocaldns"
+	"sigs.k8s.io/kpng/client/privhelper"
 )
 
 var (
@@ -45,11 +47,23 @@ var (
 	mapsCount       = flag.Uint64("maps-count", 0x100, "number of endpoints maps to use")
 	forceNFTHashBug = flag.Bool("force-nft-hash-workaround", false, "bypass auto-detection of NFT hash bug (necessary when nft is blind)")
 	withTrace       = flag.Bool("trace", false, "enable nft trace")
+	dropInvalid     = flag.Bool("drop-invalid", true, "drop the forwarded packets in invalid conntrack state, which can be reset by the node on asymmetric routes")
+	dropMark        = flag.Uint32("drop-mark", 0, "drop the packets having this fwmark set (ie: 0x8000 for the KUBE-MARK-DROP of the kubelet; 0 to disable)")
+
+	coexist          = flag.Bool("coexist", false, "coexistence mode: mark kpng's tables and refuse to touch tables created by others (requires nft >= 0.9.7)")
+	adjustPriorities = flag.Bool("coexist-adjust-priorities", false, "in coexistence mode, lower the hooks priority to run before the base chains of other tables")
 
 	clusterCIDRsFlag = flag.StringSlice("cluster-cidrs", []string{"0.0.0.0/0"}, "cluster IPs CIDR that should not be masqueraded")
 	clusterCIDRsV4   []string
 	clusterCIDRsV6   []string
 
+	serviceCIDRsFlag = flag.StringSlice("service-cluster-ip-range", nil, "service cluster IPs CIDRs; when set, only the packets to these CIDRs go through the cluster IPs dispatch")
+	serviceCIDRsV4   []*net.IPNet
+	serviceCIDRsV6   []*net.IPNet
+
+	// nodeLocalDNS are the addresses of the node-local DNS cache (see PreRun)
+	nodeLocalDNS *nodelocaldns.Cache
+
 	fullResync = true
 
 	hasNFTHashBug = false
@@ -66,8 +80,15 @@ const deferDelete = true
 const canDeleteChains = false
 
 func PreRun() {
-	checkIPTableVersion()
-	checkMapIndexBug()
+	// the in-memory kernel has no version nor bug to check
+	if memoryRuleset == nil {
+		checkIPTableVersion()
+		checkMapIndexBug()
+
+		if *coexist {
+			setupCoexistence()
+		}
+	}
 
 	// parse cluster CIDRs
 	clusterCIDRsV4 = make([]string, 0)
@@ -87,6 +108,28 @@ func PreRun() {
 
 	klog.Info("cluster CIDRs V4: ", clusterCIDRsV4)
 	klog.Info("cluster CIDRs V6: ", clusterCIDRsV6)
+
+	// parse service CIDRs
+	serviceCIDRsV4, serviceCIDRsV6 = nil, nil
+	for _, cidr := range *serviceCIDRsFlag {
+		ip, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			klog.Fatalf("bad service CIDR given: %q: %v", cidr, err)
+		}
+
+		if ip.To4() == nil {
+			serviceCIDRsV6 = append(serviceCIDRsV6, ipNet)
+		} else {
+			serviceCIDRsV4 = append(serviceCIDRsV4, ipNet)
+		}
+	}
+
+	if len(*serviceCIDRsFlag) != 0 {
+		klog.Info("service CIDRs V4: ", serviceCIDRsV4)
+		klog.Info("service CIDRs V6: ", serviceCIDRsV6)
+	}
+
+	nodeLocalDNS = nodelocaldns.Default()
 }
 
 func Callback(ch <-chan *client.ServiceEndpoints) {
@@ -101,9 +144,11 @@ func Callback(ch <-chan *client.ServiceEndpoints) {
 	defer table4.Reset()
 	defer table6.Reset()
 
+	syncErr = nil
+
 	renderContexts := []*renderContext{
-		newRenderContext(table4, clusterCIDRsV4, net.CIDRMask(*splitBits, 32)),
-		newRenderContext(table6, clusterCIDRsV6, net.CIDRMask(*splitBits6, 128)),
+		newRenderContext(table4, clusterCIDRsV4, serviceCIDRsV4, net.CIDRMask(*splitBits, 32)),
+		newRenderContext(table6, clusterCIDRsV6, serviceCIDRsV6, net.CIDRMask(*splitBits6, 128)),
 	}
 
 	for serviceEndpoints := range ch {
@@ -124,6 +169,7 @@ func Callback(ch <-chan *client.ServiceEndpoints) {
 	for _, ctx := range renderContexts {
 		ctx.Finalize()
 	}
+	serviceRevisions.Done()
 
 	// check if we have changes to apply
 	if !fullResync && !table4.Changed() && !table6.Changed() {
@@ -133,6 +179,14 @@ func Callback(ch <-chan *client.ServiceEndpoints) {
 
 	klog.V(1).Infof("nft rules generated (%s)", time.Since(start))
 
+	if memoryRuleset != nil {
+		memoryRuleset.Reset()
+		renderRuleset(memoryRuleset)
+		auditChanges()
+		fullResync = false
+		return
+	}
+
 	// render the rule set
 	//retry:
 	cmdIn, pipeOut := io.Pipe()
@@ -144,21 +198,29 @@ func Callback(ch <-chan *client.ServiceEndpoints) {
 		io.Copy(ioutil.Discard, cmdIn)
 		klog.Info("not running nft (dry run mode)")
 	} else {
-		cmd := exec.Command("nft", "-f", "-")
-		cmd.Stdin = cmdIn
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		index := &scriptIndex{}
+		stderr := new(bytes.Buffer)
+
+		cmd := privhelper.Exec().Command("nft", "-f", "-")
+		cmd.SetStdin(io.TeeReader(cmdIn, index))
+		cmd.SetStdout(os.Stdout)
+		cmd.SetStderr(io.MultiWriter(os.Stderr, stderr))
 
 		start := time.Now()
-		err := cmd.Run()
+		err := faultinject.Inject("nft")
+		if err == nil {
+			err = cmd.Run()
+		}
 		elapsed := time.Since(start)
 
 		if err != nil {
-			klog.Errorf("nft failed: %v (%s)", err, elapsed)
-
 			// ensure render is finished
 			io.Copy(ioutil.Discard, cmdIn)
 
+			// the transaction was rolled back, the previous ruleset is still in place
+			syncErr = scriptError(err, stderr.Bytes(), index)
+			klog.Errorf("%v (%s)", syncErr, elapsed)
+
 			if !fullResync {
 				// failsafe: rebuild everything
 				klog.Infof("doing a full resync after nft failure")
@@ -169,6 +231,7 @@ func Callback(ch <-chan *client.ServiceEndpoints) {
 		}
 
 		klog.V(1).Infof("nft ok (%s)", elapsed)
+		auditChanges()
 
 		if deferred.Len() != 0 {
 			klog.V(1).Infof("running deferred nft actions")
@@ -180,10 +243,10 @@ func Callback(ch <-chan *client.ServiceEndpoints) {
 				os.Stdout.Write(deferred.Bytes())
 			}
 
-			cmd := exec.Command("nft", "-f", "-")
-			cmd.Stdin = deferred
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
+			cmd := privhelper.Exec().Command("nft", "-f", "-")
+			cmd.SetStdin(deferred)
+			cmd.SetStdout(os.Stdout)
+			cmd.SetStderr(os.Stderr)
 
 			err = cmd.Run()
 			if err != nil {
@@ -198,13 +261,23 @@ func Callback(ch <-chan *client.ServiceEndpoints) {
 	}
 }
 
-func addDispatchChains(table *nftable) {
+func addDispatchChains(table *nftable, serviceCIDRs []*net.IPNet) {
 	dnatAll := table.Chains.Get("z_dnat_all")
 	if *withTrace {
 		dnatAll.WriteString("  meta nftrace set 1\n")
 	}
 
+	// the node-local DNS cache may listen on the cluster IP of kube-dns
+	localDNS := nodeLocalDNS.IPs(table.Family == "ip6")
+	if len(localDNS) != 0 {
+		fmt.Fprint(dnatAll, "  ", table.Family, " daddr { ", strings.Join(localDNS, ", "), " } return\n")
+	}
+
 	// DNAT
+	if table.Chains.Has("z_dispatch_cluster_dnat") {
+		fmt.Fprint(dnatAll, "  ", table.Family, " daddr { ", cidrsString(serviceCIDRs), " } jump z_dispatch_cluster_dnat\n")
+	}
+
 	if table.Chains.Has("z_dispatch_svc_dnat") {
 		fmt.Fprint(dnatAll, "  jump z_dispatch_svc_dnat\n")
 	}
@@ -220,13 +293,27 @@ func addDispatchChains(table *nftable) {
 	if dnatAll.Len() != 0 {
 		for _, hook := range []string{"prerouting", "output"} {
 			fmt.Fprintf(table.Chains.Get("z_hook_nat_"+hook),
-				"  type nat hook "+hook+" priority %d;\n  jump z_dnat_all\n", *hookPrio)
+				"  type nat hook "+hook+" priority %d;\n  jump z_dnat_all\n", hookPriority("nat", hook))
 		}
 	}
 
 	// filtering
 	filterAll := table.Chains.Get("z_filter_all")
-	fmt.Fprint(filterAll, "  ct state invalid drop\n")
+	if *dropInvalid {
+		fmt.Fprint(filterAll, "  ct state invalid drop\n")
+	}
+
+	if *dropMark != 0 {
+		fmt.Fprintf(filterAll, "  meta mark & %#08x == %#08x drop\n", *dropMark, *dropMark)
+	}
+
+	if len(localDNS) != 0 {
+		fmt.Fprint(filterAll, "  ", table.Family, " daddr { ", strings.Join(localDNS, ", "), " } return\n")
+	}
+
+	if table.Chains.Has("z_dispatch_cluster_filter") {
+		fmt.Fprint(filterAll, "  ", table.Family, " daddr { ", cidrsString(serviceCIDRs), " } jump z_dispatch_cluster_filter\n")
+	}
 
 	if table.Chains.Has("z_dispatch_svc_filter") {
 		fmt.Fprint(filterAll, "  jump z_dispatch_svc_filter\n")
@@ -241,9 +328,48 @@ func addDispatchChains(table *nftable) {
 	}
 
 	fmt.Fprintf(table.Chains.Get("z_hook_filter_forward"),
-		"  type filter hook forward priority %d;\n  jump z_filter_all\n", *hookPrio)
+		"  type filter hook forward priority %d;\n  jump z_filter_all\n", hookPriority("filter", "forward"))
 	fmt.Fprintf(table.Chains.Get("z_hook_filter_output"),
-		"  type filter hook output priority %d;\n  jump z_filter_all\n", *hookPrio)
+		"  type filter hook output priority %d;\n  jump z_filter_all\n", hookPriority("filter", "output"))
+
+	if !nodeLocalDNS.NoTrack() {
+		localDNS = nil
+	}
+	if len(localDNS) != 0 || table.Chains.Has(noTrackChain) {
+		addNoTrackChains(table, localDNS)
+	}
+
+	if table.Chains.Has(dscpChain) {
+		addDSCPChains(table)
+	}
+}
+
+// addNoTrackChains bypasses conntrack for the DNS traffic of the node-local DNS cache and the
+// NoTrack services, before conntrack's hooks (the raw priority, -300).
+func addNoTrackChains(table *nftable, localDNS []string) {
+	set := "{ " + strings.Join(localDNS, ", ") + " }"
+
+	for _, hook := range []string{"prerouting", "output"} {
+		chain := table.Chains.Get("z_hook_raw_" + hook)
+		fmt.Fprintf(chain, "  type filter hook %s priority -300;\n", hook)
+		if len(localDNS) != 0 {
+			for _, proto := range []string{"udp", "tcp"} {
+				fmt.Fprintf(chain, "  %s daddr %s %s dport %d notrack\n", table.Family, set, proto, nodelocaldns.Port)
+				fmt.Fprintf(chain, "  %s saddr %s %s sport %d notrack\n", table.Family, set, proto, nodelocaldns.Port)
+			}
+		}
+		if table.Chains.Has(noTrackChain) {
+			chain.WriteString("  jump " + noTrackChain + "\n")
+		}
+	}
+}
+
+func cidrsString(cidrs []*net.IPNet) string {
+	s := make([]string, len(cidrs))
+	for i, cidr := range cidrs {
+		s[i] = cidr.String()
+	}
+	return strings.Join(s, ", ")
 }
 
 func addPostroutingChain(table *nftable, clusterCIDRs []string, localEndpointIPs []string) {
@@ -255,7 +381,10 @@ func addPostroutingChain(table *nftable, clusterCIDRs []string, localEndpointIPs
 	}
 
 	chain := table.Chains.Get("zz_hook_nat_postrouting")
-	fmt.Fprintf(chain, "  type nat hook postrouting priority %d;\n", *hookPrio)
+	fmt.Fprintf(chain, "  type nat hook postrouting priority %d;\n", hookPriority("nat", "postrouting"))
+	if localDNS := nodeLocalDNS.IPs(table.Family == "ip6"); len(localDNS) != 0 {
+		fmt.Fprint(chain, "  ", table.Family, " daddr { ", strings.Join(localDNS, ", "), " } return\n")
+	}
 	if hasCIDRs {
 		chain.Writeln()
 		if !*skipComments {
@@ -326,6 +455,9 @@ func renderNftables(output io.WriteCloser, deferred io.Writer) {
 
 		// create/update changed elements
 		fmt.Fprintf(out, "table %s %s {\n", table.Family, table.Name)
+		if *coexist && fullResync {
+			fmt.Fprintf(out, " comment %q\n", tableComment)
+		}
 		for _, ki := range table.OrderedChanges(fullResync) {
 			fmt.Fprintf(out, " %s %s {\n", ki.Kind, ki.Item.Key())
 			io.Copy(out, ki.Item.Value())
@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nft
+
+import (
+	"strconv"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+const (
+	// noTrackChain holds the conntrack bypass rules of the NoTrack services, jumped to from
+	// the raw hooks.
+	noTrackChain = "z_notrack"
+
+	// noTrackClientsSet holds the clients sending to the NoTrack services through this node:
+	// their flows are DNATed here, so the replies of the local endpoints to them must stay
+	// tracked. The entries outlive the conntrack entries of the UDP streams.
+	noTrackClientsSet     = "notrack_clients"
+	noTrackClientsTimeout = "180s"
+)
+
+// addNoTrackRules bypasses conntrack for the UDP traffic to and from the local endpoints of a
+// NoTrack service, except for the replies to the local addresses and to the recent clients of
+// the service, which were DNATed by this node.
+func (ctx *renderContext) addNoTrackRules(svc *localv1.Service, endpointIPs []EpIP) {
+	if !svc.NoTrack {
+		return
+	}
+
+	family := ctx.table.Family
+
+	svcIPs := &localv1.IPSet{}
+	if svc.IPs.ClusterIPs != nil {
+		svcIPs.AddSet(svc.IPs.ClusterIPs)
+	}
+	svcIPs.AddSet(svc.IPs.ExternalIPs)
+	ips := ctx.table.IPsFromSet(svcIPs)
+
+	for _, port := range svc.Ports {
+		if port.Protocol != localv1.Protocol_UDP {
+			continue
+		}
+
+		chain := (*Leaf)(nil)
+		for _, epIP := range endpointIPs {
+			if !epIP.Endpoint.Local {
+				continue
+			}
+			targetPort := epIP.Endpoint.PortMapping(port)
+			if targetPort == 0 {
+				continue
+			}
+
+			if chain == nil {
+				chain = ctx.addNoTrackClients(svc, port, ips)
+			}
+
+			target := strconv.Itoa(int(targetPort))
+			match := "  " + family + " saddr " + epIP.IP + " udp sport " + target
+			chain.WriteString("  " + family + " daddr " + epIP.IP + " udp dport " + target + " notrack\n")
+			chain.WriteString(match + " " + mDAddrLocal + "return\n")
+			chain.WriteString(match + " " + family + " daddr @" + noTrackClientsSet + " return\n")
+			chain.WriteString(match + " notrack\n")
+		}
+	}
+}
+
+// addNoTrackClients records the clients of the service port in the noTrackClientsSet, and returns
+// the noTrackChain.
+func (ctx *renderContext) addNoTrackClients(svc *localv1.Service, port *localv1.PortMapping, ips []string) *Leaf {
+	family := ctx.table.Family
+
+	if set := ctx.table.Sets.Get(noTrackClientsSet); set.Len() == 0 {
+		set.WriteString("  type " + ctx.table.nftIPType() + "; flags timeout;\n")
+	}
+
+	chain := ctx.table.Chains.Get(noTrackChain)
+	record := " update @" + noTrackClientsSet + " { " + family + " saddr timeout " + noTrackClientsTimeout + " } return\n"
+
+	dport := "udp dport " + strconv.Itoa(int(port.Port))
+	for _, ip := range ips {
+		chain.WriteString("  " + family + " daddr " + ip + " " + dport + record)
+	}
+	if port.NodePort != 0 {
+		chain.WriteString("  " + mDAddrLocal + "udp dport " + strconv.Itoa(int(port.NodePort)) + record)
+	}
+
+	return chain
+}
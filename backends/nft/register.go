@@ -34,16 +34,44 @@ func init() {
 	backendcmd.Register("to-nft", func() backendcmd.Cmd { return &backend{} })
 }
 
+// RecordsChanges is true: the maps, sets and chains written by each sync are recorded.
+func (b *backend) RecordsChanges() bool { return true }
+
 func (b *backend) BindFlags(flags *pflag.FlagSet) {
 	b.cfg.BindFlags(flags)
 	BindFlags(flags)
 }
 
+// Probe checks that nft can be run on this host.
+func (b *backend) Probe() error {
+	return backendcmd.Err(b.Checks())
+}
+
+// Checks runs the nft command, then lists the tables to check the kernel support.
+func (b *backend) Checks() []backendcmd.Check {
+	version, err := nftVersion()
+	if err != nil {
+		return []backendcmd.Check{{Name: "nft command", Err: err}}
+	}
+
+	return []backendcmd.Check{
+		{Name: "nft command", Detail: version},
+		{Name: "nf_tables", Err: checkNFT()},
+	}
+}
+
 func (b *backend) Sink() localsink.Sink {
 	sink := fullstate.New(&b.cfg)
+	sink.SyncErr = SyncErr
 
 	PreRun()
 
+	if memoryRuleset != nil {
+		// no conntrack entries to clear
+		sink.Callback = Callback
+		return sink
+	}
+
 	ct := conntrack.New()
 	sink.Callback = fullstatepipe.New(fullstatepipe.ParallelSendSequenceClose,
 		Callback,
@@ -35,6 +35,7 @@ type renderContext struct {
 	table        *nftable
 	ipMask       net.IPMask
 	clusterCIDRs []string
+	serviceCIDRs []*net.IPNet
 
 	// buffer for misc rendering to avoid multiple allocations
 	buf              *bytes.Buffer
@@ -45,11 +46,12 @@ type renderContext struct {
 	localEndpointIPs []string
 }
 
-func newRenderContext(table *nftable, clusterCIDRs []string, ipMask net.IPMask) *renderContext {
+func newRenderContext(table *nftable, clusterCIDRs []string, serviceCIDRs []*net.IPNet, ipMask net.IPMask) *renderContext {
 	return &renderContext{
 		table:        table,
 		ipMask:       ipMask,
 		clusterCIDRs: clusterCIDRs,
+		serviceCIDRs: serviceCIDRs,
 
 		buf:              new(bytes.Buffer),
 		epSeen:           make(map[string]bool),
@@ -70,7 +72,7 @@ func (ctx *renderContext) addServiceEndpoints(serviceEndpoints *fullstate.Servic
 	table := ctx.table
 	//iptype := table.nftIPType()
 
-	svc := serviceEndpoints.Service
+	svc := serviceEndpoints.Service.WithExposedNodePorts()
 	endpoints := serviceEndpoints.Endpoints
 
 	// write endpoint chains
@@ -87,21 +89,24 @@ func (ctx *renderContext) addServiceEndpoints(serviceEndpoints *fullstate.Servic
 	// write service chain(s)
 	ctx.addSvcChain(svc, endpointIPs)
 
-	// add the service IPs to the dispatch
-	clusterIPs := &localv1.IPSet{}
-	allSvcIPs := &localv1.IPSet{}
+	ctx.addNoTrackRules(svc, endpointIPs)
+	ctx.addDSCPRules(svc)
 
+	// add the service IPs to the dispatch; the cluster IPs in the service CIDRs have their own
+	// dispatch, only reached by the packets to these CIDRs
+	allSvcIPs := &localv1.IPSet{}
 	if svc.IPs.ClusterIPs != nil {
-		clusterIPs.AddSet(svc.IPs.ClusterIPs)
 		allSvcIPs.AddSet(svc.IPs.ClusterIPs)
 	}
 	allSvcIPs.AddSet(svc.IPs.ExternalIPs)
 
-	ips := table.IPsFromSet(allSvcIPs)
-
-	if len(ips) == 0 {
-		// nothing to contribute to the dispatch vmaps
-		return
+	var clusterIPs, otherIPs []string
+	for _, ip := range table.IPsFromSet(allSvcIPs) {
+		if ctx.inServiceCIDRs(ip) {
+			clusterIPs = append(clusterIPs, ip)
+		} else {
+			otherIPs = append(otherIPs, ip)
+		}
 	}
 
 	for _, i := range []struct {
@@ -114,38 +119,58 @@ func (ctx *renderContext) addServiceEndpoints(serviceEndpoints *fullstate.Servic
 			continue
 		}
 
-		vmapItem := ctx.table.Chains.GetItem("z_dispatch_svc" + i.suffix)
-		vmap := vmapItem.Value()
-
-		first := false
-		if vmap.Len() == 0 {
-			// first time here
-			vmap.WriteString("  " + ctx.table.Family + " daddr vmap {\n    ")
-			vmapItem.Defer(func(vmap *Leaf) {
-				vmap.WriteString(" }\n")
-			})
-			first = true
+		ctx.addToDispatch("z_dispatch_cluster"+i.suffix, clusterIPs, i.target)
+		ctx.addToDispatch("z_dispatch_svc"+i.suffix, otherIPs, i.target)
+	}
+}
+
+func (ctx *renderContext) inServiceCIDRs(ip string) bool {
+	parsed := net.ParseIP(ip)
+	for _, cidr := range ctx.serviceCIDRs {
+		if cidr.Contains(parsed) {
+			return true
 		}
+	}
+	return false
+}
 
-		for idx, ip := range ips {
-			if first {
-				first = false
-			} else if idx%5 == 0 {
-				vmap.WriteString(",\n    ")
-			} else {
-				vmap.WriteString(", ")
-			}
+// addToDispatch adds the ips to the vmap of the dispatch chain, jumping to target.
+func (ctx *renderContext) addToDispatch(chain string, ips []string, target string) {
+	if len(ips) == 0 {
+		return
+	}
 
-			vmap.WriteString(ip)
-			vmap.WriteString(": jump ")
-			vmap.WriteString(i.target)
+	vmapItem := ctx.table.Chains.GetItem(chain)
+	vmap := vmapItem.Value()
+
+	first := false
+	if vmap.Len() == 0 {
+		// first time here
+		vmap.WriteString("  " + ctx.table.Family + " daddr vmap {\n    ")
+		vmapItem.Defer(func(vmap *Leaf) {
+			vmap.WriteString(" }\n")
+		})
+		first = true
+	}
+
+	for idx, ip := range ips {
+		if first {
+			first = false
+		} else if idx%5 == 0 {
+			vmap.WriteString(",\n    ")
+		} else {
+			vmap.WriteString(", ")
 		}
+
+		vmap.WriteString(ip)
+		vmap.WriteString(": jump ")
+		vmap.WriteString(target)
 	}
 }
 
 func (ctx *renderContext) Finalize() {
 	ctx.table.RunDeferred()
-	addDispatchChains(ctx.table)
+	addDispatchChains(ctx.table, ctx.serviceCIDRs)
 	addPostroutingChain(ctx.table, ctx.clusterCIDRs, ctx.localEndpointIPs)
 	ctx.table.Done()
 }
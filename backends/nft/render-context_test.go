@@ -21,19 +21,22 @@ import (
 	"io"
 	"net"
 	"os"
+	"strings"
+	"testing"
 
 	v1 "sigs.k8s.io/kpng/api/localv1"
 	"sigs.k8s.io/kpng/client/localsink/fullstate"
+	"sigs.k8s.io/kpng/client/nodelocaldns"
 )
 
 func testValues() (ctx *renderContext, seps *fullstate.ServiceEndpoints) {
 	table4 := newNftable("ip", "k8s_svc") // one table per test
-	ctx = newRenderContext(table4, []string{"10.1.0.0/16"}, net.CIDRMask(24, 32))
+	ctx = newRenderContext(table4, []string{"10.1.0.0/16"}, nil, net.CIDRMask(24, 32))
 
 	svc := &v1.Service{
 		Namespace: "my-ns",
 		Name:      "my-svc",
-		Type:      "ClusterIP",
+		Type:      "NodePort",
 		IPs: &v1.ServiceIPs{
 			ClusterIPs: v1.NewIPSet("10.0.0.1"),
 		},
@@ -76,9 +79,9 @@ func ExampleRenderBasicService() {
 	//   tcp dport 58081 jump svc_my-ns_my-svc_filter
 	//  }
 	//  chain svc_my-ns_my-svc_dnat {
-	//   tcp dport 80 jump svc_my-ns_my-svc_eps
-	//   fib daddr type local tcp dport 58080 jump svc_my-ns_my-svc_eps
-	//   tcp dport 81 jump svc_my-ns_my-svc_eps_metrics
+	//   tcp dport 80 jump svc_my-ns_my-svc_eps comment "kpng:nft svc=my-ns/my-svc port=http"
+	//   fib daddr type local tcp dport 58080 jump svc_my-ns_my-svc_eps comment "kpng:nft svc=my-ns/my-svc port=http"
+	//   tcp dport 81 jump svc_my-ns_my-svc_eps_metrics comment "kpng:nft svc=my-ns/my-svc port=metrics"
 	//  }
 	//  chain svc_my-ns_my-svc_ep_0a010001 {
 	//   tcp dport 80 dnat to 10.1.0.1:8080
@@ -103,8 +106,8 @@ func ExampleRenderBasicService() {
 	//     0: jump svc_my-ns_my-svc_ep_0a010002, 1: jump svc_my-ns_my-svc_ep_0a010101 }
 	//  }
 	//  chain svc_my-ns_my-svc_filter {
-	//   tcp dport 82 reject
-	//   fib daddr type local tcp dport 58081 reject
+	//   tcp dport 82 reject comment "kpng:nft svc=my-ns/my-svc port=nowhere"
+	//   fib daddr type local tcp dport 58081 reject comment "kpng:nft svc=my-ns/my-svc port=nowhere"
 	//  }
 	//  chain z_dispatch_svc_dnat {
 	//   ip daddr vmap {
@@ -188,9 +191,9 @@ func ExampleRenderServiceWithClientIPAffinity() {
 	//   ip saddr @svc_my-ns_my-svc_ep_0a010001_recent jump svc_my-ns_my-svc_ep_0a010001
 	//   ip saddr @svc_my-ns_my-svc_ep_0a010002_recent jump svc_my-ns_my-svc_ep_0a010002
 	//   ip saddr @svc_my-ns_my-svc_ep_0a010101_recent jump svc_my-ns_my-svc_ep_0a010101
-	//   tcp dport 80 jump svc_my-ns_my-svc_eps
-	//   fib daddr type local tcp dport 58080 jump svc_my-ns_my-svc_eps
-	//   tcp dport 81 jump svc_my-ns_my-svc_eps_metrics
+	//   tcp dport 80 jump svc_my-ns_my-svc_eps comment "kpng:nft svc=my-ns/my-svc port=http"
+	//   fib daddr type local tcp dport 58080 jump svc_my-ns_my-svc_eps comment "kpng:nft svc=my-ns/my-svc port=http"
+	//   tcp dport 81 jump svc_my-ns_my-svc_eps_metrics comment "kpng:nft svc=my-ns/my-svc port=metrics"
 	//  }
 	//  chain svc_my-ns_my-svc_ep_0a010001 {
 	//   update @svc_my-ns_my-svc_ep_0a010001_recent { ip saddr timeout 30s }
@@ -218,8 +221,8 @@ func ExampleRenderServiceWithClientIPAffinity() {
 	//     0: jump svc_my-ns_my-svc_ep_0a010002, 1: jump svc_my-ns_my-svc_ep_0a010101 }
 	//  }
 	//  chain svc_my-ns_my-svc_filter {
-	//   tcp dport 82 reject
-	//   fib daddr type local tcp dport 58081 reject
+	//   tcp dport 82 reject comment "kpng:nft svc=my-ns/my-svc port=nowhere"
+	//   fib daddr type local tcp dport 58081 reject comment "kpng:nft svc=my-ns/my-svc port=nowhere"
 	//  }
 	//  chain z_dispatch_svc_dnat {
 	//   ip daddr vmap {
@@ -293,3 +296,239 @@ func printTable(out io.Writer, ctx *renderContext) {
 	}
 	fmt.Fprintln(out, "}")
 }
+
+func Example_renderServiceWithServiceCIDR() {
+	ctx, seps := testValues()
+
+	_, serviceCIDR, _ := net.ParseCIDR("10.0.0.0/16")
+	ctx.serviceCIDRs = []*net.IPNet{serviceCIDR}
+
+	seps.Service.IPs.ExternalIPs = v1.NewIPSet("192.0.2.1")
+
+	ctx.addServiceEndpoints(seps)
+
+	finalizeAndPrintTable(os.Stdout, ctx)
+
+	// Output:
+	// table ip k8s_svc {
+	//  chain nodeports_dnat {
+	//   tcp dport 58080 jump svc_my-ns_my-svc_dnat
+	//  }
+	//  chain nodeports_filter {
+	//   tcp dport 58081 jump svc_my-ns_my-svc_filter
+	//  }
+	//  chain svc_my-ns_my-svc_dnat {
+	//   tcp dport 80 jump svc_my-ns_my-svc_eps comment "kpng:nft svc=my-ns/my-svc port=http"
+	//   fib daddr type local tcp dport 58080 jump svc_my-ns_my-svc_eps comment "kpng:nft svc=my-ns/my-svc port=http"
+	//   tcp dport 81 jump svc_my-ns_my-svc_eps_metrics comment "kpng:nft svc=my-ns/my-svc port=metrics"
+	//  }
+	//  chain svc_my-ns_my-svc_ep_0a010001 {
+	//   tcp dport 80 dnat to 10.1.0.1:8080
+	//   fib daddr type local tcp dport 58080 dnat to 10.1.0.1:8080
+	//  }
+	//  chain svc_my-ns_my-svc_ep_0a010002 {
+	//   tcp dport 80 dnat to 10.1.0.2:8080
+	//   tcp dport 81 dnat to 10.1.0.2:1011
+	//   fib daddr type local tcp dport 58080 dnat to 10.1.0.2:8080
+	//  }
+	//  chain svc_my-ns_my-svc_ep_0a010101 {
+	//   tcp dport 80 dnat to 10.1.1.1:8080
+	//   tcp dport 81 dnat to 10.1.1.1:1042
+	//   fib daddr type local tcp dport 58080 dnat to 10.1.1.1:8080
+	//  }
+	//  chain svc_my-ns_my-svc_eps {
+	//   numgen random mod 3 vmap {
+	//     0: jump svc_my-ns_my-svc_ep_0a010001, 1: jump svc_my-ns_my-svc_ep_0a010002, 2: jump svc_my-ns_my-svc_ep_0a010101 }
+	//  }
+	//  chain svc_my-ns_my-svc_eps_metrics {
+	//   numgen random mod 2 vmap {
+	//     0: jump svc_my-ns_my-svc_ep_0a010002, 1: jump svc_my-ns_my-svc_ep_0a010101 }
+	//  }
+	//  chain svc_my-ns_my-svc_filter {
+	//   tcp dport 82 reject comment "kpng:nft svc=my-ns/my-svc port=nowhere"
+	//   fib daddr type local tcp dport 58081 reject comment "kpng:nft svc=my-ns/my-svc port=nowhere"
+	//  }
+	//  chain z_dispatch_cluster_dnat {
+	//   ip daddr vmap {
+	//     10.0.0.1: jump svc_my-ns_my-svc_dnat }
+	//  }
+	//  chain z_dispatch_cluster_filter {
+	//   ip daddr vmap {
+	//     10.0.0.1: jump svc_my-ns_my-svc_filter }
+	//  }
+	//  chain z_dispatch_svc_dnat {
+	//   ip daddr vmap {
+	//     192.0.2.1: jump svc_my-ns_my-svc_dnat }
+	//  }
+	//  chain z_dispatch_svc_filter {
+	//   ip daddr vmap {
+	//     192.0.2.1: jump svc_my-ns_my-svc_filter }
+	//  }
+	//  chain z_dnat_all {
+	//   ip daddr { 10.0.0.0/16 } jump z_dispatch_cluster_dnat
+	//   jump z_dispatch_svc_dnat
+	//   fib daddr type local jump nodeports_dnat
+	//  }
+	//  chain z_filter_all {
+	//   ct state invalid drop
+	//   ip daddr { 10.0.0.0/16 } jump z_dispatch_cluster_filter
+	//   jump z_dispatch_svc_filter
+	//   fib daddr type local jump nodeports_filter
+	//  }
+	//  chain z_hook_filter_forward {
+	//   type filter hook forward priority 0;
+	//   jump z_filter_all
+	//  }
+	//  chain z_hook_filter_output {
+	//   type filter hook output priority 0;
+	//   jump z_filter_all
+	//  }
+	//  chain z_hook_nat_output {
+	//   type nat hook output priority 0;
+	//   jump z_dnat_all
+	//  }
+	//  chain z_hook_nat_prerouting {
+	//   type nat hook prerouting priority 0;
+	//   jump z_dnat_all
+	//  }
+	//  chain zz_hook_nat_postrouting {
+	//   type nat hook postrouting priority 0;
+	//
+	//   # masquerade non-cluster traffic to non-local endpoints
+	//   ip saddr != { 10.1.0.0/16 } \
+	//   ip daddr != { 10.1.0.1, 10.1.0.2 } \
+	//   fib daddr type != local \
+	//   masquerade
+	//
+	//   # masquerade hairpin traffic
+	//   ip saddr . ip daddr { 10.1.0.1 . 10.1.0.1, 10.1.0.2 . 10.1.0.2 } masquerade
+	//  }
+	// }
+}
+
+func Example_renderDropRules() {
+	*dropInvalid, *dropMark = false, 0x8000
+	defer func() { *dropInvalid, *dropMark = true, 0 }()
+
+	table4 := newNftable("ip", "k8s_svc")
+	addDispatchChains(table4, nil)
+
+	fmt.Print(table4.Chains.Get("z_filter_all").String())
+
+	// Output:
+	//   meta mark & 0x00008000 == 0x00008000 drop
+}
+
+func Example_renderNodeLocalDNS() {
+	nodeLocalDNS, _ = nodelocaldns.New(nodelocaldns.Config{IPs: []string{"169.254.20.10", "10.96.0.10"}, NoTrack: true})
+	defer func() { nodeLocalDNS = nil }()
+
+	table4 := newNftable("ip", "k8s_svc")
+	addDispatchChains(table4, nil)
+
+	for _, chain := range []string{"z_dnat_all", "z_filter_all", "z_hook_raw_prerouting"} {
+		fmt.Print(table4.Chains.Get(chain).String())
+	}
+
+	// Output:
+	//   ip daddr { 169.254.20.10, 10.96.0.10 } return
+	//   ct state invalid drop
+	//   ip daddr { 169.254.20.10, 10.96.0.10 } return
+	//   type filter hook prerouting priority -300;
+	//   ip daddr { 169.254.20.10, 10.96.0.10 } udp dport 53 notrack
+	//   ip saddr { 169.254.20.10, 10.96.0.10 } udp sport 53 notrack
+	//   ip daddr { 169.254.20.10, 10.96.0.10 } tcp dport 53 notrack
+	//   ip saddr { 169.254.20.10, 10.96.0.10 } tcp sport 53 notrack
+}
+
+func Example_renderNoTrack() {
+	table4 := newNftable("ip", "k8s_svc")
+	ctx := newRenderContext(table4, nil, nil, net.CIDRMask(24, 32))
+
+	ctx.addServiceEndpoints(&fullstate.ServiceEndpoints{
+		Service: &v1.Service{
+			Namespace: "kube-system",
+			Name:      "dns",
+			Type:      "NodePort",
+			IPs:       &v1.ServiceIPs{ClusterIPs: v1.NewIPSet("10.96.0.10")},
+			Ports: []*v1.PortMapping{
+				{Name: "dns", Protocol: v1.Protocol_UDP, Port: 53, NodePort: 30053, TargetPort: 5353},
+				{Name: "dns-tcp", Protocol: v1.Protocol_TCP, Port: 53, TargetPort: 5353},
+			},
+			NoTrack: true,
+		},
+		Endpoints: []*v1.Endpoint{
+			{IPs: v1.NewIPSet("10.1.0.1"), Local: true},
+			{IPs: v1.NewIPSet("10.1.1.1")},
+		},
+	})
+	ctx.Finalize()
+
+	for _, chain := range []string{"z_hook_raw_prerouting", "z_notrack"} {
+		fmt.Print(table4.Chains.Get(chain).String())
+	}
+	fmt.Print(table4.Sets.Get("notrack_clients").String())
+
+	// Output:
+	//   type filter hook prerouting priority -300;
+	//   jump z_notrack
+	//   ip daddr 10.96.0.10 udp dport 53 update @notrack_clients { ip saddr timeout 180s } return
+	//   fib daddr type local udp dport 30053 update @notrack_clients { ip saddr timeout 180s } return
+	//   ip daddr 10.1.0.1 udp dport 5353 notrack
+	//   ip saddr 10.1.0.1 udp sport 5353 fib daddr type local return
+	//   ip saddr 10.1.0.1 udp sport 5353 ip daddr @notrack_clients return
+	//   ip saddr 10.1.0.1 udp sport 5353 notrack
+	//   type ipv4_addr; flags timeout;
+}
+
+func Example_renderDSCP() {
+	table4 := newNftable("ip", "k8s_svc")
+	ctx := newRenderContext(table4, nil, nil, net.CIDRMask(24, 32))
+
+	ctx.addServiceEndpoints(&fullstate.ServiceEndpoints{
+		Service: &v1.Service{
+			Namespace: "default",
+			Name:      "voice",
+			Type:      "NodePort",
+			IPs:       &v1.ServiceIPs{ClusterIPs: v1.NewIPSet("10.96.0.30"), ExternalIPs: v1.NewIPSet("192.0.2.1")},
+			Ports: []*v1.PortMapping{
+				{Name: "rtp", Protocol: v1.Protocol_UDP, Port: 5004, NodePort: 30004, TargetPort: 5004},
+			},
+			DSCP: 46,
+		},
+		Endpoints: []*v1.Endpoint{
+			{IPs: v1.NewIPSet("10.1.0.1")},
+		},
+	})
+	ctx.Finalize()
+
+	for _, chain := range []string{"z_hook_mangle_prerouting", "z_hook_mangle_output", "z_dscp"} {
+		fmt.Print(table4.Chains.Get(chain).String())
+	}
+
+	// Output:
+	//   type filter hook prerouting priority -150;
+	//   jump z_dscp
+	//   type route hook output priority -150;
+	//   jump z_dscp
+	//   ip daddr 10.96.0.30 udp dport 5004 ip dscp set 46
+	//   ip daddr 192.0.2.1 udp dport 5004 ip dscp set 46
+	//   fib daddr type local udp dport 30004 ip dscp set 46
+}
+
+func TestRenderUnexposedNodePorts(t *testing.T) {
+	ctx, seps := testValues()
+	seps.Service.Type = "ClusterIP"
+
+	ctx.addServiceEndpoints(seps)
+
+	out := new(strings.Builder)
+	finalizeAndPrintTable(out, ctx)
+
+	if strings.Contains(out.String(), "5808") {
+		t.Errorf("node ports rendered for a ClusterIP service:\n%s", out)
+	}
+	if seps.Service.Ports[0].NodePort != 58080 {
+		t.Error("the service was modified")
+	}
+}
@@ -20,8 +20,15 @@ import (
 	"strconv"
 
 	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/rulecomment"
 )
 
+// nftCommentMaxLen is the maximum length of a rule comment in nftables.
+const nftCommentMaxLen = 128
+
+// serviceRevisions gives the revision of the rule comments.
+var serviceRevisions = &rulecomment.Revisions{}
+
 func (ctx *renderContext) svcNftName(svc *localv1.Service) string {
 	return "svc_" + svc.Namespace + "_" + svc.Name
 }
@@ -77,6 +84,8 @@ func (ctx *renderContext) addSvcChain(svc *localv1.Service, epIPs []EpIP) {
 			ctx.addSvcVmap(vmapName, svc, subset)
 		}
 
+		comment := ctx.ruleComment(svc, port)
+
 		// write the rules
 		for _, srcPort := range port.SrcPorts() {
 			chain.WriteString("  ")
@@ -91,16 +100,37 @@ func (ctx *renderContext) addSvcChain(svc *localv1.Service, epIPs []EpIP) {
 			chain.WriteString(strconv.Itoa(int(srcPort)))
 
 			if len(subset) == 0 {
-				chain.WriteString(" reject\n")
+				chain.WriteString(" reject")
 			} else {
 				chain.WriteString(" jump ")
 				chain.WriteString(vmapName)
-				chain.WriteByte('\n')
 			}
+			chain.WriteString(comment)
+			chain.WriteByte('\n')
 		}
 	}
 }
 
+// ruleComment returns the comment statement of the rules of a service port, if enabled. The
+// comments too long for nftables are skipped, `kpng explain` then uses the chain name.
+func (ctx *renderContext) ruleComment(svc *localv1.Service, port *localv1.PortMapping) string {
+	if *skipComments {
+		return ""
+	}
+
+	comment := rulecomment.Comment{
+		Backend:  "nft",
+		Service:  svc.Namespace + "/" + svc.Name,
+		Port:     port.Name,
+		Revision: serviceRevisions.Of(svc),
+	}.String()
+
+	if len(comment) > nftCommentMaxLen {
+		return ""
+	}
+	return " comment \"" + comment + "\""
+}
+
 func (ctx *renderContext) writeEndpointsVmap(w writer, svc *localv1.Service, epIPs []EpIP) {
 	w.WriteString("numgen random mod ")
 	w.WriteString(strconv.Itoa(len(epIPs)))
@@ -46,9 +46,9 @@ func ExampleSvcChain() {
 	//   tcp dport 58081 jump svc_my-ns_my-svc_filter
 	//  }
 	//  chain svc_my-ns_my-svc_dnat {
-	//   tcp dport 80 jump svc_my-ns_my-svc_eps
-	//   fib daddr type local tcp dport 58080 jump svc_my-ns_my-svc_eps
-	//   tcp dport 81 jump svc_my-ns_my-svc_eps_metrics
+	//   tcp dport 80 jump svc_my-ns_my-svc_eps comment "kpng:nft svc=my-ns/my-svc port=http"
+	//   fib daddr type local tcp dport 58080 jump svc_my-ns_my-svc_eps comment "kpng:nft svc=my-ns/my-svc port=http"
+	//   tcp dport 81 jump svc_my-ns_my-svc_eps_metrics comment "kpng:nft svc=my-ns/my-svc port=metrics"
 	//  }
 	//  chain svc_my-ns_my-svc_eps {
 	//   numgen random mod 3 vmap {
@@ -59,8 +59,8 @@ func ExampleSvcChain() {
 	//     0: jump svc_my-ns_my-svc_ep_0a010002, 1: jump svc_my-ns_my-svc_ep_0a010101 }
 	//  }
 	//  chain svc_my-ns_my-svc_filter {
-	//   tcp dport 82 reject
-	//   fib daddr type local tcp dport 58081 reject
+	//   tcp dport 82 reject comment "kpng:nft svc=my-ns/my-svc port=nowhere"
+	//   fib daddr type local tcp dport 58081 reject comment "kpng:nft svc=my-ns/my-svc port=nowhere"
 	//  }
 	// }
 
@@ -19,12 +19,33 @@ package nft
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 
 	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/kpng/client/privhelper"
 )
 
+// nftVersion returns the version of the nft command.
+func nftVersion() (string, error) {
+	out, err := privhelper.Exec().Command("nft", "--version").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("nft --version failed: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return string(bytes.TrimSpace(out)), nil
+}
+
+// checkNFT fails if nft can't list the tables, due to a missing binary or kernel support.
+func checkNFT() error {
+	out, err := privhelper.Exec().Command("nft", "list", "tables").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("nft list tables failed: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
 func checkMapIndexBug() {
 	if *forceNFTHashBug {
 		hasNFTHashBug = true
@@ -34,8 +55,8 @@ func checkMapIndexBug() {
 	klog.Info("checking for NFT hash bug")
 
 	// check the nft vmap bug (0.9.5 but protect against the whole class)
-	nft := exec.Command("nft", "-f", "-")
-	nft.Stdin = bytes.NewBuffer([]byte(`
+	nft := privhelper.Exec().Command("nft", "-f", "-")
+	nft.SetStdin(bytes.NewBuffer([]byte(`
 table ip k8s_test_vmap_bug
 delete table ip k8s_test_vmap_bug
 table ip k8s_test_vmap_bug {
@@ -44,19 +65,19 @@ table ip k8s_test_vmap_bug {
     elements = { 1 : 10.0.0.1, 2 : 10.0.0.2 }
   }
 }
-`))
+`)))
 	if err := nft.Run(); err != nil {
 		klog.Warning("failed to test nft bugs: ", err)
 	}
 
 	// cleanup on return
 	defer func() {
-		nft = exec.Command("nft", "-f", "-")
-		nft.Stdin = bytes.NewBuffer([]byte(`
+		nft = privhelper.Exec().Command("nft", "-f", "-")
+		nft.SetStdin(bytes.NewBuffer([]byte(`
 delete table ip k8s_test_vmap_bug
-`))
-		nft.Stdout = os.Stdout
-		nft.Stderr = os.Stderr
+`)))
+		nft.SetStdout(os.Stdout)
+		nft.SetStderr(os.Stderr)
 		err := nft.Run()
 		if err != nil {
 			klog.Warning("failed to delete test table k8s_test_vmap_bug: ", err)
@@ -64,7 +85,7 @@ delete table ip k8s_test_vmap_bug
 	}()
 
 	// get the recorded map
-	nft = exec.Command("nft", "list", "map", "ip", "k8s_test_vmap_bug", "m1")
+	nft = privhelper.Exec().Command("nft", "list", "map", "ip", "k8s_test_vmap_bug", "m1")
 	output, err := nft.Output()
 	if err != nil {
 		klog.Warning("failed to test nft bugs: ", err)
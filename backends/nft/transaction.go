@@ -0,0 +1,126 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nft
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Each sync is a single nft script, run by nft as one transaction: if any of its objects fails,
+// none is applied and the kernel keeps the ruleset of the previous sync. The script is indexed
+// while it's written to nft, so the error can name the object of the failed line.
+
+// syncErr is the error of the last sync, nil if it succeeded.
+var syncErr error
+
+// SyncErr returns the error of the last sync, so it can be retried (see the requeue package).
+func SyncErr() error {
+	return syncErr
+}
+
+// scriptObject is an object (ie: "chain ip k8s_svc svc_xyz_dnat") of a script, or a top-level
+// statement (ie: "flush chain ip k8s_svc svc_xyz_dnat"), starting at line.
+type scriptObject struct {
+	line int
+	name string
+}
+
+// scriptIndex indexes the objects of an nft script by line while it's written.
+type scriptIndex struct {
+	lines   int
+	partial []byte // start of the current line
+	table   string // current table block
+	objects []scriptObject
+}
+
+func (x *scriptIndex) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) != 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			x.partial = append(x.partial, p...)
+			break
+		}
+
+		line := p[:i]
+		if len(x.partial) != 0 {
+			line = append(x.partial, line...)
+			x.partial = x.partial[:0]
+		}
+		x.addLine(string(line))
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+func (x *scriptIndex) addLine(line string) {
+	x.lines++
+
+	switch {
+	case line == "" || line == "}":
+		return
+
+	case !strings.HasPrefix(line, " "):
+		// a table block or a top-level statement
+		name := strings.TrimSuffix(line, " {")
+		if name != line {
+			x.table = strings.TrimPrefix(name, "table ")
+		}
+		x.objects = append(x.objects, scriptObject{x.lines, name})
+
+	case !strings.HasPrefix(line, "  ") && strings.HasSuffix(line, " {"):
+		// an object of the table block: kind name {
+		kind, name, _ := strings.Cut(strings.TrimSuffix(strings.TrimSpace(line), " {"), " ")
+		x.objects = append(x.objects, scriptObject{x.lines, kind + " " + x.table + " " + name})
+	}
+}
+
+// objectAt returns the object of a line of the script, empty if unknown.
+func (x *scriptIndex) objectAt(line int) string {
+	object := ""
+	for _, o := range x.objects {
+		if o.line > line {
+			break
+		}
+		object = o.name
+	}
+	return object
+}
+
+// nftErrorLine matches the errors of nft on a line of the script read from stdin, like
+// "/dev/stdin:12:3-40: Error: Could not process rule: No such file or directory".
+var nftErrorLine = regexp.MustCompile(`(?m)^(?:/dev/stdin|-):(\d+):[0-9-]+: Error: (.*)$`)
+
+// scriptError returns the error of a failed script run, naming the object of the first failed line
+// reported by nft on stderr.
+func scriptError(err error, stderr []byte, index *scriptIndex) error {
+	m := nftErrorLine.FindSubmatch(stderr)
+	if m == nil {
+		return fmt.Errorf("nft failed: %w", err)
+	}
+
+	line, _ := strconv.Atoi(string(m[1]))
+	object := index.objectAt(line)
+	if object == "" {
+		return fmt.Errorf("nft failed at line %d: %s: %w", line, m[2], err)
+	}
+	return fmt.Errorf("nft failed on %s (line %d): %s: %w", object, line, m[2], err)
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nft
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const failedScript = `flush chain ip k8s_svc svc_ns_web_dnat
+table ip k8s_svc {
+ chain svc_ns_web_dnat {
+  ip daddr 10.0.0.1 tcp dport 80 dnat to 10.1.0.1:8080
+ }
+ map endpoints_3 {
+  type ipv4_addr : verdict
+ }
+}
+table ip6 k8s_svc6 {
+ chain dispatch {
+  jump nothing
+ }
+}
+`
+
+func TestScriptError(t *testing.T) {
+	index := &scriptIndex{}
+
+	// written in pieces, like through the pipe to nft
+	for script := failedScript; script != ""; {
+		n := 7
+		if n > len(script) {
+			n = len(script)
+		}
+		index.Write([]byte(script[:n]))
+		script = script[n:]
+	}
+
+	runErr := errors.New("exit status 1")
+
+	for _, tc := range []struct {
+		stderr   string
+		expected string
+	}{
+		{
+			"/dev/stdin:12:3-14: Error: Could not process rule: No such file or directory\n  jump nothing\n  ^^^^^^^^^^^^\n",
+			"nft failed on chain ip6 k8s_svc6 dispatch (line 12): Could not process rule: No such file or directory: exit status 1",
+		},
+		{
+			"/dev/stdin:4:3-50: Error: Could not process rule: Invalid argument\n",
+			"nft failed on chain ip k8s_svc svc_ns_web_dnat (line 4): Could not process rule: Invalid argument: exit status 1",
+		},
+		{
+			"/dev/stdin:7:3-30: Error: syntax error\n",
+			"nft failed on map ip k8s_svc endpoints_3 (line 7): syntax error: exit status 1",
+		},
+		{
+			"/dev/stdin:1:1-40: Error: No such file or directory\n",
+			"nft failed on flush chain ip k8s_svc svc_ns_web_dnat (line 1): No such file or directory: exit status 1",
+		},
+		{
+			"netlink: Error: cache initialization failed\n",
+			"nft failed: exit status 1",
+		},
+	} {
+		err := scriptError(runErr, []byte(tc.stderr), index)
+		if err.Error() != tc.expected {
+			t.Errorf("%q:\nexpected %s\ngot      %s", strings.SplitN(tc.stderr, "\n", 2)[0], tc.expected, err)
+		}
+		if !errors.Is(err, runErr) {
+			t.Errorf("%v does not wrap the run error", err)
+		}
+	}
+}
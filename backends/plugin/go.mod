@@ -0,0 +1,28 @@
+module sigs.k8s.io/kpng/backends/plugin
+
+go 1.19
+
+require (
+	github.com/spf13/pflag v1.0.5
+	google.golang.org/grpc v1.50.0
+	k8s.io/klog/v2 v2.80.1
+	sigs.k8s.io/kpng/api v0.0.0-20220824013548-88b8a1d9bc62
+	sigs.k8s.io/kpng/client v0.0.0-20221011133104-469299451522
+)
+
+require (
+	github.com/cespare/xxhash v1.1.0 // indirect
+	github.com/go-logr/logr v1.2.3 // indirect
+	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/google/btree v1.1.2 // indirect
+	github.com/google/go-cmp v0.5.9 // indirect
+	github.com/inconshreveable/mousetrap v1.0.0 // indirect
+	github.com/spf13/cobra v1.4.0 // indirect
+	golang.org/x/exp v0.0.0-20220317015231-48e79f11773a // indirect
+	golang.org/x/net v0.0.0-20221004154528-8021a29435af // indirect
+	golang.org/x/sys v0.0.0-20221010170243-090e33056c14 // indirect
+	golang.org/x/text v0.3.7 // indirect
+	google.golang.org/genproto v0.0.0-20221010155953-15ba04fc1c0e // indirect
+	google.golang.org/protobuf v1.28.1 // indirect
+	k8s.io/utils v0.0.0-20221011040102-427025108f67 // indirect
+)
@@ -0,0 +1,238 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/spf13/pflag"
+	"google.golang.org/grpc"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/api/pluginv1"
+	"sigs.k8s.io/kpng/client/localsink"
+)
+
+// Backend forwards the local state to an out-of-tree dataplane implementing
+// the pluginv1.Sink service.
+//
+// The plugin is only (re)connected on sync, and gets a Reset followed by the
+// full known state each time it connects, so it can be restarted
+// independently of kpng.
+type Backend struct {
+	cfg localsink.Config
+
+	Target     string
+	AckTimeout time.Duration
+
+	conn   *grpc.ClientConn
+	cancel func()
+	stream pluginv1.Sink_ApplyClient
+
+	state   map[stateKey]*localv1.OpItem
+	initial *localv1.OpItem // the initial sync marker, replayed once the state is complete
+}
+
+type stateKey struct {
+	set  localv1.Set
+	path string
+}
+
+var _ localsink.Sink = &Backend{}
+
+var resetOp = &localv1.OpItem{Op: &localv1.OpItem_Reset_{Reset_: &localv1.EmptyOp{}}}
+
+func (b *Backend) BindFlags(flags *pflag.FlagSet) {
+	b.cfg.BindFlags(flags)
+
+	flags.StringVar(&b.Target, "plugin-target", "unix:///var/run/kpng/plugin.sock", "gRPC target of the plugin implementing the pluginv1.Sink service")
+	flags.DurationVar(&b.AckTimeout, "plugin-ack-timeout", 30*time.Second, "max time to wait for the plugin to acknowledge a sync")
+}
+
+func (b *Backend) Sink() localsink.Sink {
+	return b
+}
+
+func (b *Backend) Setup() {
+	b.state = map[stateKey]*localv1.OpItem{}
+}
+
+func (b *Backend) WaitRequest() (nodeName string, err error) {
+	return b.cfg.WaitRequest()
+}
+
+func (b *Backend) Reset() {
+	b.Send(resetOp)
+}
+
+func (b *Backend) Send(op *localv1.OpItem) (err error) {
+	b.record(op)
+
+	_, isSync := op.Op.(*localv1.OpItem_Sync)
+
+	if b.stream == nil {
+		if !isSync {
+			// the plugin will get it with the state replayed on the next sync
+			return
+		}
+
+		if err = b.connect(); err != nil {
+			klog.Error("failed to connect to plugin: ", err)
+			return
+		}
+	}
+
+	if err = b.stream.Send(op); err != nil {
+		klog.Error("failed to send to plugin: ", err)
+		b.disconnect()
+		return
+	}
+
+	if !isSync {
+		return
+	}
+
+	if err = b.waitAck(); err != nil {
+		klog.Error("plugin sync failed: ", err)
+	}
+	return
+}
+
+func (b *Backend) record(op *localv1.OpItem) {
+	if b.state == nil {
+		b.state = map[stateKey]*localv1.OpItem{}
+	}
+
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Set:
+		ref := v.Set.Ref
+		b.state[stateKey{ref.Set, ref.Path}] = op
+
+	case *localv1.OpItem_Delete:
+		ref := v.Delete
+		delete(b.state, stateKey{ref.Set, ref.Path})
+
+	case *localv1.OpItem_Reset_:
+		b.state = map[stateKey]*localv1.OpItem{}
+
+	case *localv1.OpItem_InitialSync:
+		b.initial = op
+	}
+}
+
+// connect opens a new stream to the plugin and replays the known state.
+func (b *Backend) connect() (err error) {
+	klog.Info("connecting to plugin at ", b.Target)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	conn, err := grpc.DialContext(ctx, b.Target, grpc.WithInsecure())
+	if err != nil {
+		cancel()
+		return
+	}
+
+	stream, err := pluginv1.NewSinkClient(conn).Apply(ctx)
+	if err != nil {
+		conn.Close()
+		cancel()
+		return
+	}
+
+	b.conn, b.cancel, b.stream = conn, cancel, stream
+
+	if err = b.replay(); err != nil {
+		b.disconnect()
+	}
+	return
+}
+
+func (b *Backend) replay() (err error) {
+	if err = b.stream.Send(resetOp); err != nil {
+		return
+	}
+
+	keys := make([]stateKey, 0, len(b.state))
+	for k := range b.state {
+		keys = append(keys, k)
+	}
+
+	// services first, then endpoints
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].set != keys[j].set {
+			return keys[i].set < keys[j].set
+		}
+		return keys[i].path < keys[j].path
+	})
+
+	for _, k := range keys {
+		if err = b.stream.Send(b.state[k]); err != nil {
+			return
+		}
+	}
+
+	if b.initial != nil {
+		err = b.stream.Send(b.initial)
+	}
+	return
+}
+
+func (b *Backend) waitAck() error {
+	type result struct {
+		ack *pluginv1.Ack
+		err error
+	}
+
+	stream := b.stream
+	ch := make(chan result, 1)
+	go func() {
+		ack, err := stream.Recv()
+		ch <- result{ack, err}
+	}()
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			b.disconnect()
+			return r.err
+		}
+		if r.ack.Error != "" {
+			return errors.New(r.ack.Error)
+		}
+		return nil
+
+	case <-time.After(b.AckTimeout):
+		b.disconnect()
+		return fmt.Errorf("no ack from plugin after %v", b.AckTimeout)
+	}
+}
+
+func (b *Backend) disconnect() {
+	if b.stream == nil {
+		return
+	}
+
+	b.cancel()
+	b.conn.Close()
+
+	b.conn, b.cancel, b.stream = nil, nil, nil
+}
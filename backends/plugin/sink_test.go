@@ -0,0 +1,176 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/api/pluginv1"
+)
+
+var (
+	syncOp        = &localv1.OpItem{Op: &localv1.OpItem_Sync{Sync: &localv1.EmptyOp{}}}
+	initialSyncOp = &localv1.OpItem{Op: &localv1.OpItem_InitialSync{InitialSync: &localv1.EmptyOp{}}}
+)
+
+type testPlugin struct {
+	pluginv1.UnimplementedSinkServer
+
+	mu  sync.Mutex
+	ops []string
+	err string
+}
+
+func (p *testPlugin) Apply(stream pluginv1.Sink_ApplyServer) error {
+	for {
+		op, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		p.mu.Lock()
+		s := ""
+		switch v := op.Op.(type) {
+		case *localv1.OpItem_Set:
+			s = "set " + v.Set.Ref.Path
+		case *localv1.OpItem_Delete:
+			s = "del " + v.Delete.Path
+		case *localv1.OpItem_Reset_:
+			s = "reset"
+		case *localv1.OpItem_InitialSync:
+			s = "initial"
+		case *localv1.OpItem_Sync:
+			s = "sync"
+		}
+		p.ops = append(p.ops, s)
+		ackErr := p.err
+		p.mu.Unlock()
+
+		if s == "sync" {
+			if err := stream.Send(&pluginv1.Ack{Error: ackErr}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *testPlugin) takeOps() (ops []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ops, p.ops = p.ops, nil
+	return
+}
+
+func startPlugin(t *testing.T, sock string) (*testPlugin, *grpc.Server) {
+	lis, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &testPlugin{}
+	srv := grpc.NewServer()
+	pluginv1.RegisterSinkServer(srv, p)
+	go srv.Serve(lis)
+
+	return p, srv
+}
+
+func setOp(set localv1.Set, path string) *localv1.OpItem {
+	return &localv1.OpItem{Op: &localv1.OpItem_Set{Set: &localv1.Value{
+		Ref: &localv1.Ref{Set: set, Path: path},
+	}}}
+}
+
+func assertOps(t *testing.T, got []string, expected ...string) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("expected ops %q, got %q", expected, got)
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Fatalf("expected ops %q, got %q", expected, got)
+		}
+	}
+}
+
+func TestSendAndReplay(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "plugin.sock")
+
+	b := &Backend{Target: "unix://" + sock, AckTimeout: 5 * time.Second}
+	b.Setup()
+	defer b.disconnect()
+
+	p, srv := startPlugin(t, sock)
+
+	b.Send(setOp(localv1.Set_EndpointsSet, "ns/svc/ep1"))
+	b.Send(setOp(localv1.Set_ServicesSet, "ns/svc"))
+	b.Send(initialSyncOp)
+	if err := b.Send(syncOp); err != nil {
+		t.Fatal(err)
+	}
+
+	assertOps(t, p.takeOps(), "reset", "set ns/svc", "set ns/svc/ep1", "initial", "sync")
+
+	// once connected, ops are forwarded as they come
+	b.Send(&localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: &localv1.Ref{Set: localv1.Set_EndpointsSet, Path: "ns/svc/ep1"}}})
+	if err := b.Send(syncOp); err != nil {
+		t.Fatal(err)
+	}
+
+	assertOps(t, p.takeOps(), "del ns/svc/ep1", "sync")
+
+	// a restarted plugin gets the whole state again, marked complete
+	srv.Stop()
+
+	p, srv = startPlugin(t, sock)
+	defer srv.Stop()
+
+	b.Send(setOp(localv1.Set_EndpointsSet, "ns/svc/ep2"))
+
+	for i := 0; i < 3; i++ {
+		if err := b.Send(syncOp); err == nil {
+			break
+		}
+	}
+
+	assertOps(t, p.takeOps(), "reset", "set ns/svc", "set ns/svc/ep2", "initial", "sync")
+}
+
+func TestAckError(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "plugin.sock")
+
+	b := &Backend{Target: "unix://" + sock, AckTimeout: 5 * time.Second}
+	b.Setup()
+	defer b.disconnect()
+
+	p, srv := startPlugin(t, sock)
+	defer srv.Stop()
+
+	p.err = "no space left on the NIC"
+
+	err := b.Send(syncOp)
+	if err == nil || err.Error() != p.err {
+		t.Fatalf("expected the plugin error, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+//go:build linux
+// +build linux
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/klog/v2"
+)
+
+// acceptBatch accepts up to max pending connections of the listener, waiting for the first one.
+// The next ones are accepted without blocking (see acceptQueued).
+func acceptBatch(listener net.Listener, max int) ([]net.Conn, error) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	conns := []net.Conn{conn}
+
+	if max <= 1 {
+		return conns, nil
+	}
+	return append(conns, acceptQueued(listener, max-1)...), nil
+}
+
+// acceptQueued accepts up to max connections queued in the backlog of the listener, without
+// blocking (the socket of the listener is non-blocking), until accept returns EAGAIN.
+func acceptQueued(listener net.Listener, max int) (conns []net.Conn) {
+	tcpListener, ok := listener.(*net.TCPListener)
+	if !ok {
+		return nil
+	}
+	raw, err := tcpListener.SyscallConn()
+	if err != nil {
+		return nil
+	}
+
+	var fds []int
+	raw.Control(func(fd uintptr) {
+		for len(fds) < max {
+			nfd, _, err := unix.Accept4(int(fd), unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC)
+			switch err {
+			case nil:
+				fds = append(fds, nfd)
+			case unix.EINTR, unix.ECONNABORTED:
+				// try again, the aborted connection is gone
+			default:
+				// none left (EAGAIN), or the error is returned by the next blocking accept
+				return
+			}
+		}
+	})
+
+	for _, nfd := range fds {
+		file := os.NewFile(uintptr(nfd), "")
+		conn, err := net.FileConn(file)
+		file.Close()
+		if err != nil {
+			klog.Errorf("Accepted connection failed: %v", err)
+			continue
+		}
+		conns = append(conns, conn)
+	}
+	return conns
+}
@@ -0,0 +1,145 @@
+//go:build linux
+// +build linux
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/common"
+)
+
+func TestAcceptBatch(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// the connections are queued once dialed
+	for i := 0; i < 3; i++ {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+	}
+
+	for _, expected := range []int{2, 1} {
+		conns, err := acceptBatch(listener, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(conns) != expected {
+			t.Fatalf("%d connections accepted, expected %d", len(conns), expected)
+		}
+		for _, conn := range conns {
+			if _, ok := conn.(*net.TCPConn); !ok {
+				t.Errorf("accepted a %T", conn)
+			}
+			conn.Close()
+		}
+	}
+
+	listener.Close()
+	if _, err := acceptBatch(listener, 2); !isClosedError(err) {
+		t.Errorf("accept on a closed listener returned %v", err)
+	}
+}
+
+// staticLoadBalancer sends all the connections to one endpoint.
+type staticLoadBalancer struct {
+	LoadBalancer
+	endpoint string
+}
+
+func (lb staticLoadBalancer) NextEndpoint(common.ServicePortName, net.Addr, bool) (string, error) {
+	return lb.endpoint, nil
+}
+
+func (lb staticLoadBalancer) ReportResult(common.ServicePortName, string, error) {}
+
+func TestDrainBacklog(t *testing.T) {
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	go func() {
+		for {
+			conn, err := backend.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	sock, err := newProxySocket(localv1.Protocol_TCP, net.IPv4(127, 0, 0, 1), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sock.Close()
+	previous := sock.(*tcpProxySocket)
+
+	// the connections are queued in the backlog of the previous socket, nothing accepts them
+	var clients []net.Conn
+	for i := 0; i < 3; i++ {
+		client, err := net.Dial("tcp", previous.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+		clients = append(clients, client)
+	}
+
+	// the port is handed over
+	port := previous.Addr().(*net.TCPAddr).Port
+	current, err := newProxySocket(localv1.Protocol_TCP, net.IPv4(127, 0, 0, 1), port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer current.Close()
+
+	service := common.ServicePortName{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "svc"}, Port: "http", Protocol: localv1.Protocol_TCP}
+	info := &ServiceInfo{protocol: localv1.Protocol_TCP, tcpFlows: &flowSet{flows: map[*flow]struct{}{}}}
+
+	if n := previous.drainBacklog(service, info, staticLoadBalancer{endpoint: backend.Addr().String()}); n != len(clients) {
+		t.Errorf("%d connections drained, expected %d", n, len(clients))
+	}
+	previous.Close()
+
+	for _, client := range clients {
+		if _, err := client.Write([]byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadFull(client, make([]byte, 5)); err != nil {
+			t.Errorf("queued connection not proxied: %v", err)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+//go:build !linux
+// +build !linux
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import "net"
+
+// acceptBatch accepts one connection of the listener: non-blocking accepts are only done on Linux.
+func acceptBatch(listener net.Listener, _ int) ([]net.Conn, error) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return []net.Conn{conn}, nil
+}
+
+// acceptQueued doesn't accept without blocking on this platform.
+func acceptQueued(_ net.Listener, _ int) []net.Conn {
+	return nil
+}
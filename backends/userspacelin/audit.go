@@ -0,0 +1,125 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"bytes"
+	"strings"
+
+	iptablesutil "sigs.k8s.io/kpng/backends/iptables/util"
+	"sigs.k8s.io/kpng/client/auditlog"
+)
+
+// auditedIPTables records the chains and rules changed through it in the audit log. A flushed
+// chain is recorded as updated.
+type auditedIPTables struct {
+	iptablesutil.Interface
+}
+
+func (a auditedIPTables) EnsureChain(table iptablesutil.Table, chain iptablesutil.Chain) (bool, error) {
+	existed, err := a.Interface.EnsureChain(table, chain)
+	if err == nil && !existed {
+		auditlog.Record("userspace", auditlog.Add, "chain", string(table)+" "+string(chain), "")
+	}
+	return existed, err
+}
+
+func (a auditedIPTables) FlushChain(table iptablesutil.Table, chain iptablesutil.Chain) error {
+	err := a.Interface.FlushChain(table, chain)
+	if err == nil {
+		auditlog.Record("userspace", auditlog.Update, "chain", string(table)+" "+string(chain), "")
+	}
+	return err
+}
+
+func (a auditedIPTables) DeleteChain(table iptablesutil.Table, chain iptablesutil.Chain) error {
+	err := a.Interface.DeleteChain(table, chain)
+	if err == nil {
+		auditlog.Record("userspace", auditlog.Delete, "chain", string(table)+" "+string(chain), "")
+	}
+	return err
+}
+
+func (a auditedIPTables) EnsureRule(position iptablesutil.RulePosition, table iptablesutil.Table, chain iptablesutil.Chain, args ...string) (bool, error) {
+	existed, err := a.Interface.EnsureRule(position, table, chain, args...)
+	if err == nil && !existed {
+		recordRule(auditlog.Add, table, chain, args)
+	}
+	return existed, err
+}
+
+func (a auditedIPTables) EnsureRules(rules []iptablesutil.RuleSpec) (int, error) {
+	if !auditlog.Enabled() {
+		return a.Interface.EnsureRules(rules)
+	}
+
+	missing := a.missingRules(rules)
+
+	added, err := a.Interface.EnsureRules(rules)
+	if err == nil {
+		for _, rule := range missing {
+			recordRule(auditlog.Add, rule.Table, rule.Chain, rule.Args)
+		}
+	}
+	return added, err
+}
+
+func (a auditedIPTables) DeleteRule(table iptablesutil.Table, chain iptablesutil.Chain, args ...string) error {
+	err := a.Interface.DeleteRule(table, chain, args...)
+	if err == nil {
+		recordRule(auditlog.Delete, table, chain, args)
+	}
+	return err
+}
+
+// missingRules returns the rules not in their chains yet, the ones EnsureRules appends. If the
+// rules can't be read, they are all returned.
+func (a auditedIPTables) missingRules(rules []iptablesutil.RuleSpec) (missing []iptablesutil.RuleSpec) {
+	saved := map[string]bool{}
+	buf := &bytes.Buffer{}
+
+	read := map[iptablesutil.Table]bool{}
+	for _, rule := range rules {
+		if read[rule.Table] {
+			continue
+		}
+		read[rule.Table] = true
+
+		buf.Reset()
+		if err := a.SaveInto(rule.Table, buf); err != nil {
+			return rules
+		}
+
+		for _, line := range strings.Split(buf.String(), "\n") {
+			if fields := splitRule(line); len(fields) >= 2 && fields[0] == "-A" {
+				saved[string(rule.Table)+" "+ruleKey(iptablesutil.Chain(fields[1]), fields[2:])] = true
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		if !saved[string(rule.Table)+" "+ruleKey(rule.Chain, rule.Args)] {
+			missing = append(missing, rule)
+		}
+	}
+	return
+}
+
+func recordRule(op string, table iptablesutil.Table, chain iptablesutil.Chain, args []string) {
+	service, _ := savedRule{table: table, chain: chain, args: args}.proxyPort()
+	auditlog.Record("userspace", op, "rule", string(table)+" "+string(chain)+" "+strings.Join(args, " "), service)
+}
@@ -0,0 +1,38 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"reflect"
+	"testing"
+
+	iptablesutil "sigs.k8s.io/kpng/backends/iptables/util"
+)
+
+func TestMissingRules(t *testing.T) {
+	saved := &savingRules{fakeRules: &fakeRules{rules: map[string]bool{}}}
+
+	present := iptablesutil.RuleSpec{Table: iptablesutil.TableNAT, Chain: iptablesHostPortalChain, Args: []string{"-m", "comment", "--comment", "ns/svc:http", "-p", "tcp"}}
+	saved.EnsureRules([]iptablesutil.RuleSpec{present})
+
+	added := iptablesutil.RuleSpec{Table: iptablesutil.TableNAT, Chain: iptablesHostPortalChain, Args: []string{"-m", "comment", "--comment", "ns/svc:dns", "-p", "udp"}}
+
+	missing := auditedIPTables{saved}.missingRules([]iptablesutil.RuleSpec{present, added})
+	if expected := []iptablesutil.RuleSpec{added}; !reflect.DeepEqual(missing, expected) {
+		t.Errorf("expected the missing rules %v, got %v", expected, missing)
+	}
+}
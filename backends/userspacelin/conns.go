@@ -0,0 +1,155 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Connection is a TCP connection or UDP client being proxied, as listed by `kpng userspace conns`.
+type Connection struct {
+	Service  string `json:"service"`
+	Protocol string `json:"protocol"`
+	Client   string `json:"client"`
+	Endpoint string `json:"endpoint"`
+	// Age is the time since the connection was accepted, or since the first datagram of the client.
+	Age string `json:"age"`
+	// Sent and Received are the bytes sent to and received from the endpoint.
+	Sent     int64 `json:"sent"`
+	Received int64 `json:"received"`
+}
+
+// Connections is the response of the connections listing of the status socket.
+type Connections struct {
+	Connections []Connection `json:"connections"`
+}
+
+// flow is the accounting of a proxied connection.
+type flow struct {
+	client   string // TCP only, the UDP clients are the keys of ClientCache.Clients
+	endpoint string
+	started  time.Time
+
+	sent, received int64 // Only access these with atomic ops
+}
+
+// addSent and addReceived count the bytes of the flow, which may be nil.
+func (f *flow) addSent(n int) {
+	if f != nil {
+		atomic.AddInt64(&f.sent, int64(n))
+	}
+}
+
+func (f *flow) addReceived(n int) {
+	if f != nil {
+		atomic.AddInt64(&f.received, int64(n))
+	}
+}
+
+// flowSet holds the TCP connections of a service.
+type flowSet struct {
+	mu    sync.Mutex
+	flows map[*flow]struct{}
+}
+
+func (s *flowSet) add(f *flow) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flows[f] = struct{}{}
+}
+
+func (s *flowSet) remove(f *flow) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.flows, f)
+}
+
+// countingWriter counts the bytes written to an io.Writer.
+type countingWriter struct {
+	io.Writer
+	count func(n int)
+}
+
+func (w countingWriter) Write(b []byte) (int, error) {
+	n, err := w.Writer.Write(b)
+	w.count(n)
+	return n, err
+}
+
+// listConnections returns the connections proxied for the given service ("namespace/name"), or for
+// all services if empty.
+func (proxier *UserspaceLinux) listConnections(service string) []Connection {
+	now := time.Now()
+	conns := []Connection{}
+
+	connection := func(name, protocol, client string, f *flow) Connection {
+		return Connection{
+			Service:  name,
+			Protocol: protocol,
+			Client:   client,
+			Endpoint: f.endpoint,
+			Age:      now.Sub(f.started).Round(time.Second).String(),
+			Sent:     atomic.LoadInt64(&f.sent),
+			Received: atomic.LoadInt64(&f.received),
+		}
+	}
+
+	proxier.mu.Lock()
+	for name, info := range proxier.serviceMap {
+		if service != "" && name.NamespacedName.String() != service {
+			continue
+		}
+
+		protocol := info.protocol.String()
+
+		if info.tcpFlows != nil {
+			info.tcpFlows.mu.Lock()
+			for f := range info.tcpFlows.flows {
+				conns = append(conns, connection(name.String(), protocol, f.client, f))
+			}
+			info.tcpFlows.mu.Unlock()
+		}
+
+		if info.ActiveClients != nil {
+			info.ActiveClients.Mu.Lock()
+			for client, svrConn := range info.ActiveClients.Clients {
+				if f := info.ActiveClients.flows[svrConn]; f != nil {
+					conns = append(conns, connection(name.String(), protocol, client, f))
+				}
+			}
+			info.ActiveClients.Mu.Unlock()
+		}
+	}
+	proxier.mu.Unlock()
+
+	sort.Slice(conns, func(i, j int) bool {
+		a, b := conns[i], conns[j]
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.Client < b.Client
+	})
+
+	return conns
+}
@@ -0,0 +1,49 @@
+//go:build !windows
+// +build !windows
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// dscpControl returns the net.Dialer.Control setting the DSCP of the packets sent to the
+// endpoints (the IPv4 TOS or IPv6 traffic class, without the ECN bits), nil if dscp is 0.
+func dscpControl(dscp uint8) func(network, address string, c syscall.RawConn) error {
+	if dscp == 0 {
+		return nil
+	}
+
+	return func(network, _ string, c syscall.RawConn) (err error) {
+		ctrlErr := c.Control(func(fd uintptr) {
+			if strings.HasSuffix(network, "6") {
+				err = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS, int(dscp)<<2)
+			} else {
+				err = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, int(dscp)<<2)
+			}
+		})
+		if ctrlErr != nil {
+			return ctrlErr
+		}
+		return
+	}
+}
@@ -20,22 +20,26 @@ import (
 	"net"
 
 	"sigs.k8s.io/kpng/api/localv1"
-	"sigs.k8s.io/kpng/backends/iptables"
+	"sigs.k8s.io/kpng/backends/common"
 )
 
 // LoadBalancer is an interface for distributing incoming requests to service endpoints.
 type LoadBalancer interface {
 	// NextEndpoint returns the endpoint to handle a request for the given
 	// service-port and source address.
-	NextEndpoint(service iptables.ServicePortName, srcAddr net.Addr, sessionAffinityReset bool) (string, error)
-	NewService(service iptables.ServicePortName, affinityClientIP *localv1.ClientIPAffinity, stickyMaxAgeSeconds int) error
-	DeleteService(service iptables.ServicePortName)
-	CleanupStaleStickySessions(service iptables.ServicePortName)
-	ServiceHasEndpoints(service iptables.ServicePortName) bool
+	NextEndpoint(service common.ServicePortName, srcAddr net.Addr, sessionAffinityReset bool) (string, error)
+	NewService(service common.ServicePortName, affinityClientIP *localv1.ClientIPAffinity, stickyMaxAgeSeconds int) error
+	DeleteService(service common.ServicePortName)
+	CleanupStaleStickySessions(service common.ServicePortName)
+	ServiceHasEndpoints(service common.ServicePortName) bool
+	// ReportResult reports the result of a connection to an endpoint (err is nil on success).
+	ReportResult(service common.ServicePortName, endpoint string, err error)
 
 	// For userspace because we dont have an EndpointChangeTracker which can auto lookup services behind the scenes,
 	// we need to send this explicitly.
 	OnEndpointsAdd(ep *localv1.Endpoint, svc *localv1.Service)
 	OnEndpointsDelete(ep *localv1.Endpoint, svc *localv1.Service)
+	// ResetAffinity drops the session affinities of the clients to the endpoint.
+	ResetAffinity(ep *localv1.Endpoint, svc *localv1.Service)
 	OnEndpointsSynced()
 }
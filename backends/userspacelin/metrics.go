@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MaxOpenFiles is the effective limit of open files of the proxy.
+var MaxOpenFiles = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "kpng_userspace_max_open_files",
+	Help: "The effective limit of open files of the userspace proxy",
+})
+
+// EndpointEjections counts the ejections of endpoints by the outlier detection.
+var EndpointEjections = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "kpng_userspace_endpoint_ejections_total",
+	Help: "The number of endpoint ejections by the outlier detection of the userspace proxy",
+})
+
+// EjectedEndpoints is the number of endpoints currently ejected by the outlier detection.
+var EjectedEndpoints = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "kpng_userspace_ejected_endpoints",
+	Help: "The number of endpoints currently ejected by the outlier detection of the userspace proxy",
+})
+
+// ServiceChangesPending is the number of services with changes not synced yet.
+var ServiceChangesPending = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "kpng_userspace_service_changes_pending",
+	Help: "The number of services with changes not synced yet by the userspace proxy",
+})
+
+// ServiceChangesTotal counts the service changes received.
+var ServiceChangesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "kpng_userspace_service_changes_total",
+	Help: "The number of service changes received by the userspace proxy",
+})
+
+// UDPTruncatedDatagrams counts the datagrams filling the read buffer, likely truncated.
+var UDPTruncatedDatagrams = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "kpng_userspace_udp_truncated_datagrams_total",
+	Help: "The number of UDP datagrams filling the read buffer of the userspace proxy, likely truncated (see --udp-max-datagram-size)",
+})
+
+// ProxyPortsUsed is the number of ports of --proxy-port-range allocated to proxies.
+var ProxyPortsUsed = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "kpng_userspace_proxy_ports_used",
+	Help: "The number of ports of --proxy-port-range allocated to the proxies of the userspace proxy",
+})
+
+// ProxyPortAllocationFailures counts the proxy port allocations failed for lack of free ports.
+var ProxyPortAllocationFailures = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "kpng_userspace_proxy_port_allocation_failures_total",
+	Help: "The number of proxy port allocations of the userspace proxy that failed because --proxy-port-range was exhausted",
+})
+
+var registerMetricsOnce sync.Once
+
+// RegisterMetrics registers the userspace proxy metrics.
+func RegisterMetrics() {
+	registerMetricsOnce.Do(func() {
+		prometheus.MustRegister(MaxOpenFiles, EndpointEjections, EjectedEndpoints, ServiceChangesPending, ServiceChangesTotal, UDPTruncatedDatagrams,
+			ProxyPortsUsed, ProxyPortAllocationFailures)
+	})
+}
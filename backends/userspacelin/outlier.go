@@ -0,0 +1,218 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	klog "k8s.io/klog/v2"
+
+	"sigs.k8s.io/kpng/backends/common"
+)
+
+// OutlierConfig configures the passive health checking of the endpoints: the endpoints failing
+// too many connections are ejected for a while, then probed with a single connection (half-open)
+// before being used again.
+type OutlierConfig struct {
+	// FailureRate is the ratio of failed connections ejecting an endpoint (0 disables the detection).
+	FailureRate float64
+	// MinConnections is the number of connections in an Interval required to evaluate the failure rate.
+	MinConnections int
+	// Interval is the duration over which the failure rate is evaluated.
+	Interval time.Duration
+	// EjectionTime is the duration of the first ejection, multiplied by the number of consecutive ejections.
+	EjectionTime time.Duration
+	// MaxEjectionPercent is the maximum share of the endpoints of a service ejected at the same time.
+	MaxEjectionPercent int
+}
+
+// OutlierDetection is the outlier detection configuration of the load balancers.
+var OutlierDetection = OutlierConfig{
+	MinConnections:     5,
+	Interval:           10 * time.Second,
+	EjectionTime:       30 * time.Second,
+	MaxEjectionPercent: 50,
+}
+
+func (c *OutlierConfig) BindFlags(flags *pflag.FlagSet) {
+	flags.Float64Var(&c.FailureRate, "outlier-failure-rate", c.FailureRate, "Ratio of failed connections ejecting an endpoint, between 0 and 1 (0 to disable the outlier detection)")
+	flags.IntVar(&c.MinConnections, "outlier-min-connections", c.MinConnections, "Connections to an endpoint in an interval required to evaluate its failure rate")
+	flags.DurationVar(&c.Interval, "outlier-interval", c.Interval, "Interval over which the failure rate of the endpoints is evaluated")
+	flags.DurationVar(&c.EjectionTime, "outlier-ejection-time", c.EjectionTime, "Duration of the ejection of an endpoint, multiplied by its number of consecutive ejections")
+	flags.IntVar(&c.MaxEjectionPercent, "outlier-max-ejection-percent", c.MaxEjectionPercent, "Maximum percentage of the endpoints of a service ejected at the same time")
+}
+
+type outlierStats struct {
+	windowStart         time.Time
+	connections         int
+	failures            int
+	ejectedUntil        time.Time
+	consecutiveEjection int
+	// probing is true when a connection is tested after an ejection (half-open).
+	probing bool
+}
+
+// outlierDetector tracks the connection failures of the endpoints. A nil detector never ejects.
+type outlierDetector struct {
+	cfg OutlierConfig
+	now func() time.Time
+
+	mu    sync.Mutex
+	stats map[common.ServicePortName]map[string]*outlierStats
+}
+
+func newOutlierDetector(cfg OutlierConfig) *outlierDetector {
+	if cfg.FailureRate <= 0 {
+		return nil
+	}
+
+	return &outlierDetector{
+		cfg:   cfg,
+		now:   time.Now,
+		stats: map[common.ServicePortName]map[string]*outlierStats{},
+	}
+}
+
+// available returns false if the endpoint is ejected. Once the ejection expires, a single
+// connection is let through to probe the endpoint.
+func (d *outlierDetector) available(svcPort common.ServicePortName, endpoint string) bool {
+	if d == nil {
+		return true
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stats := d.stats[svcPort][endpoint]
+	if stats == nil || stats.ejectedUntil.IsZero() {
+		return true
+	}
+
+	if stats.probing || d.now().Before(stats.ejectedUntil) {
+		return false
+	}
+
+	klog.V(2).Infof("probing ejected endpoint %s of %s", endpoint, svcPort)
+	stats.probing = true
+	return true
+}
+
+// report records the result of a connection to an endpoint, ejecting or restoring it.
+// endpointCount is the number of endpoints of the service.
+func (d *outlierDetector) report(svcPort common.ServicePortName, endpoint string, endpointCount int, failed bool) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	endpoints := d.stats[svcPort]
+	if endpoints == nil {
+		endpoints = map[string]*outlierStats{}
+		d.stats[svcPort] = endpoints
+	}
+
+	stats := endpoints[endpoint]
+	if stats == nil {
+		stats = &outlierStats{}
+		endpoints[endpoint] = stats
+	}
+
+	now := d.now()
+
+	if stats.probing {
+		stats.probing = false
+		if failed {
+			d.eject(svcPort, endpoint, stats, now)
+		} else {
+			klog.Infof("restoring endpoint %s of %s", endpoint, svcPort)
+			*stats = outlierStats{}
+			EjectedEndpoints.Dec()
+		}
+		return
+	}
+
+	if !stats.ejectedUntil.IsZero() {
+		// connection started before the ejection
+		return
+	}
+
+	if now.Sub(stats.windowStart) > d.cfg.Interval {
+		stats.windowStart = now
+		stats.connections, stats.failures = 0, 0
+	}
+
+	stats.connections++
+	if failed {
+		stats.failures++
+	}
+
+	if stats.connections < d.cfg.MinConnections ||
+		float64(stats.failures)/float64(stats.connections) < d.cfg.FailureRate {
+		return
+	}
+
+	ejected := 0
+	for _, s := range endpoints {
+		if !s.ejectedUntil.IsZero() {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > endpointCount*d.cfg.MaxEjectionPercent {
+		klog.V(1).Infof("not ejecting endpoint %s of %s: too many endpoints ejected", endpoint, svcPort)
+		return
+	}
+
+	EjectedEndpoints.Inc()
+	d.eject(svcPort, endpoint, stats, now)
+}
+
+func (d *outlierDetector) eject(svcPort common.ServicePortName, endpoint string, stats *outlierStats, now time.Time) {
+	stats.consecutiveEjection++
+	stats.ejectedUntil = now.Add(time.Duration(stats.consecutiveEjection) * d.cfg.EjectionTime)
+	stats.connections, stats.failures = 0, 0
+
+	klog.Infof("ejecting endpoint %s of %s until %v", endpoint, svcPort, stats.ejectedUntil)
+	EndpointEjections.Inc()
+}
+
+// forget drops the stats of a deleted endpoint.
+func (d *outlierDetector) forget(svcPort common.ServicePortName, endpoint string) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stats := d.stats[svcPort][endpoint]
+	if stats == nil {
+		return
+	}
+
+	if !stats.ejectedUntil.IsZero() {
+		EjectedEndpoints.Dec()
+	}
+
+	delete(d.stats[svcPort], endpoint)
+	if len(d.stats[svcPort]) == 0 {
+		delete(d.stats, svcPort)
+	}
+}
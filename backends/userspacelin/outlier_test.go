@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	testingclock "k8s.io/utils/clock/testing"
+
+	"sigs.k8s.io/kpng/backends/common"
+)
+
+func newTestOutlierDetector() (*outlierDetector, *testingclock.FakeClock) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+
+	d := newOutlierDetector(OutlierConfig{
+		FailureRate:        0.5,
+		MinConnections:     4,
+		Interval:           10 * time.Second,
+		EjectionTime:       30 * time.Second,
+		MaxEjectionPercent: 50,
+	})
+	d.now = fakeClock.Now
+	return d, fakeClock
+}
+
+var outlierSvcPort = common.ServicePortName{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "svc"}, Port: "http"}
+
+func TestOutlierDisabled(t *testing.T) {
+	d := newOutlierDetector(OutlierConfig{})
+	if d != nil {
+		t.Fatal("expected no detector without a failure rate")
+	}
+
+	for i := 0; i < 10; i++ {
+		d.report(outlierSvcPort, "10.1.0.1:80", 2, true)
+	}
+	if !d.available(outlierSvcPort, "10.1.0.1:80") {
+		t.Error("expected a nil detector to never eject")
+	}
+}
+
+func TestOutlierEjection(t *testing.T) {
+	d, clock := newTestOutlierDetector()
+	const ep = "10.1.0.1:80"
+
+	report := func(failed ...bool) {
+		for _, f := range failed {
+			d.report(outlierSvcPort, ep, 2, f)
+		}
+	}
+	expectAvailable := func(expected bool) {
+		t.Helper()
+		if available := d.available(outlierSvcPort, ep); available != expected {
+			t.Fatalf("expected available=%v, got %v", expected, available)
+		}
+	}
+
+	// not enough connections to evaluate the failure rate
+	report(true, true, true)
+	expectAvailable(true)
+
+	// the failures of the previous interval are forgotten
+	clock.Step(11 * time.Second)
+	report(true, false, false, false)
+	expectAvailable(true)
+
+	report(true, true, true, true)
+	expectAvailable(false)
+
+	// ejected for the ejection time
+	clock.Step(29 * time.Second)
+	expectAvailable(false)
+
+	// then a single connection probes the endpoint
+	clock.Step(2 * time.Second)
+	expectAvailable(true)
+	expectAvailable(false)
+
+	// a failed probe ejects it for longer
+	report(true)
+	clock.Step(31 * time.Second)
+	expectAvailable(false)
+	clock.Step(30 * time.Second)
+	expectAvailable(true)
+
+	// a successful probe restores it
+	report(false)
+	expectAvailable(true)
+	expectAvailable(true)
+
+	// and the ejections are counted again from the start
+	report(true, true, true, true)
+	expectAvailable(false)
+	clock.Step(31 * time.Second)
+	expectAvailable(true)
+}
+
+func TestOutlierMaxEjectionPercent(t *testing.T) {
+	d, _ := newTestOutlierDetector()
+
+	for _, ep := range []string{"10.1.0.1:80", "10.1.0.2:80"} {
+		for i := 0; i < 4; i++ {
+			d.report(outlierSvcPort, ep, 2, true)
+		}
+	}
+
+	if d.available(outlierSvcPort, "10.1.0.1:80") {
+		t.Error("expected the first failing endpoint to be ejected")
+	}
+	if !d.available(outlierSvcPort, "10.1.0.2:80") {
+		t.Error("expected at most half of the endpoints to be ejected")
+	}
+
+	// a deleted endpoint no longer counts
+	d.forget(outlierSvcPort, "10.1.0.1:80")
+	for i := 0; i < 4; i++ {
+		d.report(outlierSvcPort, "10.1.0.2:80", 2, true)
+	}
+	if d.available(outlierSvcPort, "10.1.0.2:80") {
+		t.Error("expected the second endpoint to be ejected")
+	}
+	if !d.available(outlierSvcPort, "10.1.0.1:80") {
+		t.Error("expected the forgotten endpoint to be available")
+	}
+}
@@ -19,6 +19,7 @@ package userspacelin
 import (
 	"crypto/rand"
 	"errors"
+	"hash/fnv"
 	"math/big"
 	"sync"
 	"time"
@@ -28,11 +29,16 @@ import (
 )
 
 var (
-	errPortRangeNoPortsRemaining = errors.New("port allocation failed; there are no remaining ports left to allocate in the accepted range")
+	// ErrPortRangeNoPortsRemaining is returned by the AllocateNext of a port range allocator with
+	// all its ports in use. Allocating again may succeed once ports are released.
+	ErrPortRangeNoPortsRemaining = errors.New("port allocation failed; there are no remaining ports left to allocate in the accepted range")
 )
 
 type PortAllocator interface {
 	AllocateNext() (int, error)
+	// AllocateFor allocates a port for the given key (ie: a service port name), trying the same
+	// port for the same key first, so the proxy port of a service is stable across restarts.
+	AllocateFor(key string) (int, error)
 	Release(int)
 }
 
@@ -45,6 +51,11 @@ func (r *randomAllocator) AllocateNext() (int, error) {
 	return 0, nil
 }
 
+// AllocateFor always returns 0
+func (r *randomAllocator) AllocateFor(_ string) (int, error) {
+	return 0, nil
+}
+
 // Release is a noop
 func (r *randomAllocator) Release(_ int) {
 	// noop
@@ -52,12 +63,12 @@ func (r *randomAllocator) Release(_ int) {
 
 // newPortAllocator builds PortAllocator for a given PortRange. If the PortRange is empty
 // then a random port allocator is returned; otherwise, a new range-based allocator
-// is returned.
-func newPortAllocator(r net.PortRange) PortAllocator {
+// is returned, never allocating the reserved ports.
+func newPortAllocator(r net.PortRange, reserved ...net.PortRange) PortAllocator {
 	if r.Base == 0 {
 		return &randomAllocator{}
 	}
-	return newPortRangeAllocator(r, true)
+	return newPortRangeAllocator(r, true, reserved...)
 }
 
 const (
@@ -68,12 +79,13 @@ const (
 
 type rangeAllocator struct {
 	net.PortRange
-	ports chan int
-	used  big.Int
-	lock  sync.Mutex
+	ports    chan int
+	used     big.Int
+	reserved big.Int
+	lock     sync.Mutex
 }
 
-func newPortRangeAllocator(r net.PortRange, autoFill bool) PortAllocator {
+func newPortRangeAllocator(r net.PortRange, autoFill bool, reserved ...net.PortRange) PortAllocator {
 	if r.Base == 0 || r.Size == 0 {
 		panic("illegal argument: may not specify an empty port range")
 	}
@@ -81,6 +93,15 @@ func newPortRangeAllocator(r net.PortRange, autoFill bool) PortAllocator {
 		PortRange: r,
 		ports:     make(chan int, portsBufSize),
 	}
+	for _, res := range reserved {
+		for port := res.Base; port < res.Base+res.Size; port++ {
+			if r.Contains(port) {
+				// reserved ports are marked used forever
+				ra.reserved.SetBit(&ra.reserved, port-r.Base, 1)
+				ra.used.SetBit(&ra.used, port-r.Base, 1)
+			}
+		}
+	}
 	if autoFill {
 		go wait.Forever(func() { ra.fillPorts() }, nextFreePortCooldown)
 	}
@@ -144,12 +165,33 @@ func (r *rangeAllocator) nextFreePort() int {
 func (r *rangeAllocator) AllocateNext() (port int, err error) {
 	select {
 	case port = <-r.ports:
+		ProxyPortsUsed.Inc()
 	case <-time.After(allocateNextTimeout):
-		err = errPortRangeNoPortsRemaining
+		ProxyPortAllocationFailures.Inc()
+		err = ErrPortRangeNoPortsRemaining
 	}
 	return
 }
 
+// AllocateFor allocates the port at the hash of the key in the range if it is free, the next free
+// port otherwise.
+func (r *rangeAllocator) AllocateFor(key string) (int, error) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	i := int(h.Sum32() % uint32(r.Size))
+
+	r.lock.Lock()
+	if r.used.Bit(i) == 0 {
+		r.used.SetBit(&r.used, i, 1)
+		r.lock.Unlock()
+		ProxyPortsUsed.Inc()
+		return i + r.Base, nil
+	}
+	r.lock.Unlock()
+
+	return r.AllocateNext()
+}
+
 func (r *rangeAllocator) Release(port int) {
 	port -= r.Base
 	if port < 0 || port >= r.Size {
@@ -157,5 +199,9 @@ func (r *rangeAllocator) Release(port int) {
 	}
 	r.lock.Lock()
 	defer r.lock.Unlock()
+	if r.reserved.Bit(port) == 1 || r.used.Bit(port) == 0 {
+		return
+	}
 	r.used.SetBit(&r.used, port, 0)
+	ProxyPortsUsed.Dec()
 }
@@ -0,0 +1,127 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/util/net"
+)
+
+func TestPortAllocatorStress(t *testing.T) {
+	// 55 free ports, the reserved ones out of the range are ignored
+	r := net.PortRange{Base: 20000, Size: 64}
+	a := newPortRangeAllocator(r, true,
+		net.PortRange{Base: 19990, Size: 18},
+		net.PortRange{Base: 20063, Size: 1},
+		net.PortRange{Base: 30000, Size: 10})
+
+	usedBefore := testutil.ToFloat64(ProxyPortsUsed)
+
+	// allocate and release concurrently, a port must never be held twice
+	var (
+		mu   sync.Mutex
+		held = map[int]bool{}
+		wg   sync.WaitGroup
+	)
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				port, err := a.AllocateNext()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				mu.Lock()
+				if held[port] {
+					t.Errorf("port %d allocated twice", port)
+				}
+				if port < 20008 || port >= 20063 {
+					t.Errorf("port %d is reserved or out of the range", port)
+				}
+				held[port] = true
+				mu.Unlock()
+
+				mu.Lock()
+				delete(held, port)
+				mu.Unlock()
+				a.Release(port)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if used := testutil.ToFloat64(ProxyPortsUsed) - usedBefore; used != 0 {
+		t.Errorf("%v ports used after releasing all of them", used)
+	}
+
+	// exhaust the 55 free ports
+	ports := make([]int, 0, 55)
+	for i := 0; i < 55; i++ {
+		port, err := a.AllocateNext()
+		if err != nil {
+			t.Fatalf("allocation %d failed: %v", i, err)
+		}
+		ports = append(ports, port)
+	}
+
+	failuresBefore := testutil.ToFloat64(ProxyPortAllocationFailures)
+	if _, err := a.AllocateNext(); !errors.Is(err, ErrPortRangeNoPortsRemaining) {
+		t.Fatalf("expected ErrPortRangeNoPortsRemaining, got %v", err)
+	}
+	if failures := testutil.ToFloat64(ProxyPortAllocationFailures) - failuresBefore; failures != 1 {
+		t.Errorf("%v failures counted, expected 1", failures)
+	}
+
+	// releasing a reserved port doesn't make it available
+	a.Release(20000)
+	if _, err := a.AllocateNext(); !errors.Is(err, ErrPortRangeNoPortsRemaining) {
+		t.Fatalf("expected ErrPortRangeNoPortsRemaining after releasing a reserved port, got %v", err)
+	}
+
+	a.Release(ports[10])
+	if port, err := a.AllocateNext(); err != nil || port != ports[10] {
+		t.Errorf("expected the released port %d, got %d, %v", ports[10], port, err)
+	}
+}
+
+func TestPortAllocatorAllocateFor(t *testing.T) {
+	r := net.PortRange{Base: 20000, Size: 1000}
+
+	a := newPortRangeAllocator(r, false)
+	port, err := a.AllocateFor("default/web:http")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same port after a restart
+	if other, _ := newPortRangeAllocator(r, false).AllocateFor("default/web:http"); other != port {
+		t.Errorf("allocated %d after a restart, expected %d", other, port)
+	}
+
+	// the same port once released
+	a.Release(port)
+	if again, _ := a.AllocateFor("default/web:http"); again != port {
+		t.Errorf("allocated %d after a release, expected %d", again, port)
+	}
+}
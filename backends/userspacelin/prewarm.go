@@ -0,0 +1,226 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	klog "k8s.io/klog/v2"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/common"
+	iptablesutil "sigs.k8s.io/kpng/backends/iptables/util"
+	"sigs.k8s.io/kpng/client/rulecomment"
+)
+
+// The rules of a previous run are kept on restart, instead of being flushed: the services are
+// started on the proxy ports the rules point to, so they keep working, and the rules that were
+// not re-created by the first sync are removed.
+
+// savedRule is a rule of the portal chains, read at startup.
+type savedRule struct {
+	table iptablesutil.Table
+	chain iptablesutil.Chain
+	args  []string
+}
+
+var portalChains = map[iptablesutil.Table][]iptablesutil.Chain{
+	iptablesutil.TableNAT:    {iptablesContainerClusterIPChain, iptablesHostClusterIPChain, iptablesContainerPortalChain, iptablesHostPortalChain, iptablesContainerNodePortChain, iptablesHostNodePortChain},
+	iptablesutil.TableFilter: {iptablesNonLocalNodePortChain},
+}
+
+// readSavedRules returns the rules of the portal chains.
+func readSavedRules(ipt iptablesutil.Interface) (rules []savedRule, err error) {
+	buf := &bytes.Buffer{}
+
+	for table, chains := range portalChains {
+		buf.Reset()
+		if err = ipt.SaveInto(table, buf); err != nil {
+			return nil, err
+		}
+
+		for _, line := range strings.Split(buf.String(), "\n") {
+			fields := splitRule(line)
+			if len(fields) < 2 || fields[0] != "-A" {
+				continue
+			}
+
+			for _, chain := range chains {
+				if fields[1] == string(chain) {
+					rules = append(rules, savedRule{table: table, chain: chain, args: fields[2:]})
+				}
+			}
+		}
+	}
+
+	return
+}
+
+// splitRule splits an iptables-save rule in arguments, unquoting them.
+func splitRule(line string) (args []string) {
+	arg := strings.Builder{}
+	inArg, quoted, escaped := false, false, false
+
+	for _, c := range line {
+		switch {
+		case escaped:
+			arg.WriteRune(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+			inArg = true
+		case c == ' ' && !quoted:
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return
+}
+
+// ruleKey identifies a rule whatever the order of its arguments (iptables-save reorders them).
+func ruleKey(chain iptablesutil.Chain, args []string) string {
+	sorted := append([]string(nil), args...)
+	sort.Strings(sorted)
+	return string(chain) + " " + strings.Join(sorted, " ")
+}
+
+// proxyPort returns the service port (from the rule comment) and the proxy port of a rule.
+func (r savedRule) proxyPort() (service string, port int) {
+	for i := 0; i+1 < len(r.args); i++ {
+		value := r.args[i+1]
+
+		switch r.args[i] {
+		case "--comment":
+			if c, ok := rulecomment.Parse(value); ok {
+				service = c.ServicePort()
+			} else {
+				// written by a previous version
+				service = value
+			}
+		case "--to-ports":
+			port, _ = strconv.Atoi(value)
+		case "--to-destination":
+			if _, portStr, err := net.SplitHostPort(value); err == nil {
+				port, _ = strconv.Atoi(portStr)
+			}
+		case "--dport":
+			// the non-local node port rules accept the traffic to the proxy port
+			if r.chain == iptablesNonLocalNodePortChain {
+				port, _ = strconv.Atoi(value)
+			}
+		}
+	}
+	return
+}
+
+// prewarm reads the rules of a previous run, and the proxy ports of its services.
+func (proxier *UserspaceLinux) prewarm() error {
+	rules, err := readSavedRules(proxier.iptables)
+	if err != nil {
+		return err
+	}
+
+	proxier.staleRules = rules
+	proxier.ensuredRules = map[string]bool{}
+	proxier.previousProxyPorts = map[string]int{}
+
+	for _, rule := range rules {
+		if service, port := rule.proxyPort(); service != "" && port != 0 {
+			proxier.previousProxyPorts[service] = port
+		}
+	}
+
+	klog.V(1).InfoS("Keeping the rules of the previous run until the first sync", "rules", len(rules), "services", len(proxier.previousProxyPorts))
+	return nil
+}
+
+// ensureRules appends the rules of a service to the portal chains, in one batch, keeping track of
+// them until the first sync.
+func (proxier *UserspaceLinux) ensureRules(name common.ServicePortName, rules []iptablesutil.RuleSpec) error {
+	if proxier.ensuredRules != nil {
+		for _, rule := range rules {
+			proxier.ensuredRules[ruleKey(rule.Chain, rule.Args)] = true
+		}
+	}
+
+	added, err := proxier.iptables.EnsureRules(rules)
+	if err != nil {
+		klog.ErrorS(err, "Failed to install the iptables rules of service", "servicePortName", name, "rules", len(rules))
+		return err
+	}
+	if added != 0 {
+		klog.V(3).InfoS("Opened iptables portals for service", "servicePortName", name, "rules", added)
+	}
+	return nil
+}
+
+// addServiceOnPreviousPort starts a service on the proxy port of the previous run, so its rules
+// keep working. It returns nil if there's no such port, or if it's taken.
+func (proxier *UserspaceLinux) addServiceOnPreviousPort(service common.ServicePortName, protocol localv1.Protocol, udp UDPConfig) *ServiceInfo {
+	port, ok := proxier.previousProxyPorts[service.String()]
+	if !ok {
+		return nil
+	}
+	delete(proxier.previousProxyPorts, service.String())
+
+	info, err := proxier.addServiceOnPortInternal(service, protocol, port, proxier.udpIdleTimeout, udp)
+	if err != nil {
+		klog.V(1).InfoS("Failed to reuse the proxy port of the previous run", "serviceName", service, "port", port, "err", err)
+		return nil
+	}
+	return info
+}
+
+// removeStaleRules removes the rules of the previous run that were not re-created. It runs after
+// the portals are ensured, and only deletes the rules they don't match, so the rules that are
+// kept are never missing.
+func (proxier *UserspaceLinux) removeStaleRules() {
+	removed := 0
+	for _, rule := range proxier.staleRules {
+		if proxier.ensuredRules[ruleKey(rule.chain, rule.args)] {
+			continue
+		}
+
+		if err := proxier.iptables.DeleteRule(rule.table, rule.chain, rule.args...); err != nil {
+			klog.ErrorS(err, "Failed to remove a rule of the previous run", "chain", rule.chain, "args", rule.args)
+			continue
+		}
+		removed++
+	}
+
+	klog.V(1).InfoS("Removed the stale rules of the previous run", "rules", removed)
+
+	proxier.staleRules = nil
+	proxier.ensuredRules = nil
+	proxier.previousProxyPorts = nil
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/common"
+	iptablesutil "sigs.k8s.io/kpng/backends/iptables/util"
+)
+
+func TestSplitRule(t *testing.T) {
+	for _, tc := range []struct {
+		line     string
+		expected []string
+	}{
+		{"", nil},
+		{"-A KUBE-PORTALS-HOST -p tcp", []string{"-A", "KUBE-PORTALS-HOST", "-p", "tcp"}},
+		{"  -p   tcp  ", []string{"-p", "tcp"}},
+		{`-m comment --comment "ns/svc:http cluster IP"`, []string{"-m", "comment", "--comment", "ns/svc:http cluster IP"}},
+		{`--comment ""`, []string{"--comment", ""}},
+		{`--comment "say \"hi\"" -j ACCEPT`, []string{"--comment", `say "hi"`, "-j", "ACCEPT"}},
+		{`--comment a\ b`, []string{"--comment", "a b"}},
+	} {
+		if args := splitRule(tc.line); !reflect.DeepEqual(args, tc.expected) {
+			t.Errorf("%q: expected %q, got %q", tc.line, tc.expected, args)
+		}
+	}
+}
+
+func TestRuleKey(t *testing.T) {
+	args := []string{"-m", "comment", "--comment", "ns/svc:http", "-p", "tcp", "--dport", "80"}
+	reordered := []string{"-p", "tcp", "--dport", "80", "-m", "comment", "--comment", "ns/svc:http"}
+
+	if ruleKey(iptablesHostPortalChain, args) != ruleKey(iptablesHostPortalChain, reordered) {
+		t.Error("expected the key not to depend on the order of the arguments")
+	}
+	if ruleKey(iptablesHostPortalChain, args) == ruleKey(iptablesContainerPortalChain, args) {
+		t.Error("expected the key to depend on the chain")
+	}
+	if ruleKey(iptablesHostPortalChain, args[:6]) == ruleKey(iptablesHostPortalChain, args) {
+		t.Error("expected the key to depend on all the arguments")
+	}
+	if args[0] != "-m" {
+		t.Error("expected the arguments not to be modified")
+	}
+}
+
+// savingRules is a fakeRules printing its rules like iptables-save, and recording the deletions.
+type savingRules struct {
+	*fakeRules
+	specs   []iptablesutil.RuleSpec
+	deleted []string
+}
+
+func (f *savingRules) EnsureRules(rules []iptablesutil.RuleSpec) (added int, err error) {
+	f.specs = append(f.specs, rules...)
+	return f.fakeRules.EnsureRules(rules)
+}
+
+func (f *savingRules) DeleteRule(table iptablesutil.Table, chain iptablesutil.Chain, args ...string) error {
+	f.deleted = append(f.deleted, string(chain)+" "+strings.Join(args, " "))
+	return f.fakeRules.DeleteRule(table, chain, args...)
+}
+
+func (f *savingRules) SaveInto(table iptablesutil.Table, buf *bytes.Buffer) error {
+	for _, spec := range f.specs {
+		if spec.Table != table || !f.rules[string(spec.Chain)+" "+strings.Join(spec.Args, " ")] {
+			continue
+		}
+
+		buf.WriteString("-A " + string(spec.Chain))
+		for _, arg := range spec.Args {
+			if strings.ContainsAny(arg, ` "`) {
+				arg = `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+			}
+			buf.WriteString(" " + arg)
+		}
+		buf.WriteString("\n")
+	}
+	return nil
+}
+
+func TestStaleRules(t *testing.T) {
+	// the previous run
+	previous, previousRules := newFakeProxier()
+	saved := &savingRules{fakeRules: previousRules}
+	previous.iptables = saved
+
+	kept := servicePortsOf(&localv1.PortMapping{Name: "http", Protocol: localv1.Protocol_TCP, Port: 80, NodePort: 30080})
+	update(previous, nil, kept)
+
+	removed := servicePortsOf(&localv1.PortMapping{Name: "dns", Protocol: localv1.Protocol_UDP, Port: 53})
+	removed.Name = "old"
+	update(previous, nil, removed)
+
+	keptName := common.ServicePortName{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "svc"}, Port: "http"}
+	previousPort := previous.serviceMap[keptName].proxyPort
+
+	// the restart, keeping the rules
+	proxier, _ := newFakeProxier()
+	proxier.iptables = saved
+	proxier.makeProxySocket = func(_ localv1.Protocol, _ net.IP, port int) (ProxySocket, error) {
+		if port == 0 {
+			port = 50000
+		}
+		return &fakeSocket{port: port}, nil
+	}
+
+	if err := proxier.prewarm(); err != nil {
+		t.Fatal(err)
+	}
+	if len(proxier.staleRules) == 0 || len(proxier.staleRules) != len(saved.rules) {
+		t.Fatalf("expected the %d rules to be read, got %d", len(saved.rules), len(proxier.staleRules))
+	}
+
+	update(proxier, nil, kept)
+
+	if port := proxier.serviceMap[keptName].proxyPort; port != previousPort {
+		t.Errorf("expected the proxy port %d of the previous run, got %d", previousPort, port)
+	}
+
+	keptRules := saved.matching("port=http ")
+	if len(keptRules) == 0 {
+		t.Fatal("no rules for ns/svc:http")
+	}
+
+	proxier.ensurePortals()
+	ensured := len(saved.specs)
+	proxier.removeStaleRules()
+
+	// the portals were ensured before, they are not deleted and re-created
+	if len(saved.specs) != ensured {
+		t.Errorf("expected no rules to be ensured again, got %v", saved.specs[ensured:])
+	}
+
+	for _, deleted := range saved.deleted {
+		if !strings.Contains(deleted, "svc=ns/old ") {
+			t.Errorf("expected only the rules of ns/old to be deleted, got %q", deleted)
+		}
+	}
+	if left := saved.matching("svc=ns/old "); len(left) != 0 {
+		t.Errorf("stale rules left: %q", left)
+	}
+	if rules := saved.matching("port=http "); !reflect.DeepEqual(rules, keptRules) {
+		t.Errorf("expected the rules %q to be kept, got %q", keptRules, rules)
+	}
+	if proxier.ensuredRules != nil || proxier.staleRules != nil {
+		t.Error("expected the previous run to be forgotten after the first sync")
+	}
+}
@@ -17,19 +17,23 @@ limitations under the License.
 package userspacelin
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"sigs.k8s.io/kpng/backends/iptables"
+	"sigs.k8s.io/kpng/backends/common"
 
-	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
 	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink/supervise"
 )
 
 // Abstraction over TCP/UDP sockets which are proxied.
@@ -41,7 +45,7 @@ type ProxySocket interface {
 	// while sessions are active.
 	Close() error
 	// ProxyLoop proxies incoming connections for the specified service to the service endpoints.
-	ProxyLoop(service iptables.ServicePortName, info *ServiceInfo, loadBalancer LoadBalancer)
+	ProxyLoop(service common.ServicePortName, info *ServiceInfo, loadBalancer LoadBalancer)
 	// ListenPort returns the host port that the ProxySocket is listening on
 	ListenPort() int
 }
@@ -54,23 +58,22 @@ func newProxySocket(protocol localv1.Protocol, ip net.IP, port int) (ProxySocket
 		host = ip.String()
 	}
 
+	// SO_REUSEPORT lets a new socket take over the port before the old one is closed
+	lc := net.ListenConfig{Control: reusePort}
+
 	switch strings.ToUpper(protocol.String()) {
 	case "TCP":
-		listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		listener, err := lc.Listen(context.Background(), "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
 		if err != nil {
 			return nil, err
 		}
 		return &tcpProxySocket{Listener: listener, port: port}, nil
 	case "UDP":
-		addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, strconv.Itoa(port)))
-		if err != nil {
-			return nil, err
-		}
-		conn, err := net.ListenUDP("udp", addr)
+		conn, err := lc.ListenPacket(context.Background(), "udp", net.JoinHostPort(host, strconv.Itoa(port)))
 		if err != nil {
 			return nil, err
 		}
-		return &udpProxySocket{UDPConn: conn, port: port}, nil
+		return &udpProxySocket{UDPConn: conn.(*net.UDPConn), port: port, clock: clock.RealClock{}}, nil
 	case "SCTP":
 		return nil, fmt.Errorf("SCTP is not supported for user space proxy")
 	}
@@ -93,7 +96,9 @@ func (tcp *tcpProxySocket) ListenPort() int {
 
 // TryConnectEndpoints attempts to connect to the next available endpoint for the given service, cycling
 // through until it is able to successfully connect, or it has tried with all timeouts in EndpointDialTimeouts.
-func TryConnectEndpoints(service iptables.ServicePortName, srcAddr net.Addr, protocol string, loadBalancer LoadBalancer) (out net.Conn, err error) {
+// The packets sent to the endpoint are marked with dscp, if not 0.
+func TryConnectEndpoints(service common.ServicePortName, srcAddr net.Addr, protocol string, dscp uint8, loadBalancer LoadBalancer) (out net.Conn, err error) {
+	control := dscpControl(dscp)
 	sessionAffinityReset := false
 	for _, dialTimeout := range EndpointDialTimeouts {
 		endpoint, err := loadBalancer.NextEndpoint(service, srcAddr, sessionAffinityReset)
@@ -104,7 +109,8 @@ func TryConnectEndpoints(service iptables.ServicePortName, srcAddr net.Addr, pro
 		klog.V(3).Infof("Mapped service %q to endpoint %s", service, endpoint)
 		// TODO: This could spin up a new goroutine to make the outbound connection,
 		// and keep accepting inbound traffic.
-		outConn, err := net.DialTimeout(protocol, endpoint, dialTimeout)
+		outConn, err := (&net.Dialer{Timeout: dialTimeout, Control: control}).Dial(protocol, endpoint)
+		loadBalancer.ReportResult(service, endpoint, err)
 		if err != nil {
 			if isTooManyFDsError(err) {
 				panic("Dial failed: " + err.Error())
@@ -118,14 +124,31 @@ func TryConnectEndpoints(service iptables.ServicePortName, srcAddr net.Addr, pro
 	return nil, fmt.Errorf("failed to connect to an endpoint.")
 }
 
-func (tcp *tcpProxySocket) ProxyLoop(service iptables.ServicePortName, myInfo *ServiceInfo, loadBalancer LoadBalancer) {
+func (tcp *tcpProxySocket) ProxyLoop(service common.ServicePortName, myInfo *ServiceInfo, loadBalancer LoadBalancer) {
+	workers := TCP.acceptWorkers()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			supervise.Run("TCP accept worker of "+service.String(), func() {
+				tcp.acceptLoop(service, myInfo, loadBalancer)
+			})
+		}()
+	}
+	wg.Wait()
+}
+
+// acceptLoop accepts the connections of the socket and proxies them, until the service is closed.
+func (tcp *tcpProxySocket) acceptLoop(service common.ServicePortName, myInfo *ServiceInfo, loadBalancer LoadBalancer) {
 	for {
 		if !myInfo.IsAlive() {
 			// The service port was closed or replaced.
 			return
 		}
 		// Block until a connection is made.
-		inConn, err := tcp.Accept()
+		inConns, err := acceptBatch(tcp.Listener, TCP.AcceptBatch)
 		if err != nil {
 			if isTooManyFDsError(err) {
 				panic("Accept failed: " + err.Error())
@@ -141,35 +164,72 @@ func (tcp *tcpProxySocket) ProxyLoop(service iptables.ServicePortName, myInfo *S
 			klog.Errorf("Accept failed: %v", err)
 			continue
 		}
-		klog.V(3).Infof("Accepted TCP connection from %v to %v", inConn.RemoteAddr(), inConn.LocalAddr())
-		outConn, err := TryConnectEndpoints(service, inConn.(*net.TCPConn).RemoteAddr(), "tcp", loadBalancer)
-		if err != nil {
-			klog.Errorf("Failed to connect to balancer: %v", err)
-			inConn.Close()
-			continue
+		for _, inConn := range inConns {
+			tcp.proxyConn(inConn, service, myInfo, loadBalancer)
+		}
+	}
+}
+
+// drainBacklog accepts the connections queued in the backlog of the socket, which closing it would
+// reset, and proxies them asynchronously. It is called before closing a socket whose port was handed
+// over (see retireService): the connections queued after the drain are still reset, so the socket
+// must be closed right after.
+func (tcp *tcpProxySocket) drainBacklog(service common.ServicePortName, myInfo *ServiceInfo, loadBalancer LoadBalancer) int {
+	conns := acceptQueued(tcp.Listener, math.MaxInt)
+	if len(conns) == 0 {
+		return 0
+	}
+
+	go supervise.Run("TCP backlog drain of "+service.String(), func() {
+		for _, inConn := range conns {
+			tcp.proxyConn(inConn, service, myInfo, loadBalancer)
 		}
-		// Spin up an async copy loop.
-		go ProxyTCP(inConn.(*net.TCPConn), outConn.(*net.TCPConn))
+	})
+	return len(conns)
+}
+
+// proxyConn connects an accepted connection to an endpoint, and copies its bytes asynchronously.
+func (tcp *tcpProxySocket) proxyConn(inConn net.Conn, service common.ServicePortName, myInfo *ServiceInfo, loadBalancer LoadBalancer) {
+	klog.V(3).Infof("Accepted TCP connection from %v to %v", inConn.RemoteAddr(), inConn.LocalAddr())
+	outConn, err := TryConnectEndpoints(service, inConn.(*net.TCPConn).RemoteAddr(), "tcp", myInfo.DSCP(), loadBalancer)
+	if err != nil {
+		klog.Errorf("Failed to connect to balancer: %v", err)
+		inConn.Close()
+		return
 	}
+	f := &flow{client: inConn.RemoteAddr().String(), endpoint: outConn.RemoteAddr().String(), started: time.Now()}
+	myInfo.tcpFlows.add(f)
+	// Spin up an async copy loop.
+	atomic.AddInt64(&myInfo.activeConnsAtomic, 1)
+	go func() {
+		defer atomic.AddInt64(&myInfo.activeConnsAtomic, -1)
+		defer myInfo.tcpFlows.remove(f)
+		proxyTCP(inConn.(*net.TCPConn), outConn.(*net.TCPConn), f)
+	}()
 }
 
 // ProxyTCP proxies data bi-directionally between in and out.
 func ProxyTCP(in, out *net.TCPConn) {
+	proxyTCP(in, out, nil)
+}
+
+// proxyTCP proxies data bi-directionally between in and out, counting the bytes in f (if not nil).
+func proxyTCP(in, out *net.TCPConn, f *flow) {
 	var wg sync.WaitGroup
 	wg.Add(2)
 	klog.V(4).Infof("Creating proxy between %v <-> %v <-> %v <-> %v",
 		in.RemoteAddr(), in.LocalAddr(), out.LocalAddr(), out.RemoteAddr())
-	go copyBytes("from backend", in, out, &wg)
-	go copyBytes("to backend", out, in, &wg)
+	go copyBytes("from backend", in, out, f.addReceived, &wg)
+	go copyBytes("to backend", out, in, f.addSent, &wg)
 	wg.Wait()
 }
 
 // copyBytes is used every time we get a connection, it copys the bytes from the
 // incoming port into the socket...
-func copyBytes(direction string, dest, src *net.TCPConn, wg *sync.WaitGroup) {
+func copyBytes(direction string, dest, src *net.TCPConn, count func(n int), wg *sync.WaitGroup) {
 	defer wg.Done()
 	klog.V(4).Infof("Copying %s: %s -> %s", direction, src.RemoteAddr(), dest.RemoteAddr())
-	n, err := io.Copy(dest, src)
+	n, err := io.Copy(countingWriter{Writer: dest, count: count}, src)
 	if err != nil {
 		if !isClosedError(err) {
 			klog.Errorf("I/O error: %v", err)
@@ -186,6 +246,9 @@ func copyBytes(direction string, dest, src *net.TCPConn, wg *sync.WaitGroup) {
 type udpProxySocket struct {
 	*net.UDPConn
 	port int
+
+	// clock sets the idle deadlines of the clients.
+	clock clock.PassiveClock
 }
 
 func (udp *udpProxySocket) ListenPort() int {
@@ -200,14 +263,24 @@ func (udp *udpProxySocket) Addr() net.Addr {
 type ClientCache struct {
 	Mu      sync.Mutex
 	Clients map[string]net.Conn // addr string -> connection
+
+	// flows accounts the traffic of the connections, for the connections listing
+	flows map[net.Conn]*flow
+
+	// quic tracks the clients by QUIC connection ID, nil unless enabled (see UDPConfig.FlowTracking)
+	quic *quicFlows
 }
 
-func newClientCache() *ClientCache {
-	return &ClientCache{Clients: map[string]net.Conn{}}
+func newClientCache(flowTracking string) *ClientCache {
+	cache := &ClientCache{Clients: map[string]net.Conn{}}
+	if flowTracking == FlowTrackingQUIC {
+		cache.quic = newQUICFlows()
+	}
+	return cache
 }
 
-func (udp *udpProxySocket) ProxyLoop(service iptables.ServicePortName, myInfo *ServiceInfo, loadBalancer LoadBalancer) {
-	var buffer [4096]byte // 4KiB should be enough for most whole-packets
+func (udp *udpProxySocket) ProxyLoop(service common.ServicePortName, myInfo *ServiceInfo, loadBalancer LoadBalancer) {
+	buffer := make([]byte, myInfo.udpDatagramSize())
 	for {
 		if !myInfo.IsAlive() {
 			// The service port was closed or replaced.
@@ -227,14 +300,20 @@ func (udp *udpProxySocket) ProxyLoop(service iptables.ServicePortName, myInfo *S
 			klog.Errorf("ReadFrom failed, exiting ProxyLoop: %v", err)
 			break
 		}
+		if n == len(buffer) {
+			// a full buffer is likely a truncated datagram
+			UDPTruncatedDatagrams.Inc()
+			klog.V(2).Infof("Datagram from %v to %s may be truncated to %d bytes", cliAddr, service, n)
+		}
 		// If this is a client we know already, reuse the connection and goroutine.
-		svrConn, err := udp.getBackendConn(myInfo.ActiveClients, cliAddr, loadBalancer, service, myInfo.Timeout)
+		svrConn, f, err := udp.getBackendConn(myInfo, cliAddr, buffer[0:n], loadBalancer, service)
 		if err != nil {
 			continue
 		}
 		// TODO: It would be nice to let the goroutine handle this write, but we don't
 		// really want to copy the buffer.  We could do a pool of buffers or something.
-		_, err = svrConn.Write(buffer[0:n])
+		written, err := svrConn.Write(buffer[0:n])
+		f.addSent(written)
 		if err != nil {
 			if !logTimeout(err) {
 				klog.Errorf("Write failed: %v", err)
@@ -242,7 +321,7 @@ func (udp *udpProxySocket) ProxyLoop(service iptables.ServicePortName, myInfo *S
 			}
 			continue
 		}
-		err = svrConn.SetDeadline(time.Now().Add(myInfo.Timeout))
+		err = svrConn.SetDeadline(udp.clock.Now().Add(myInfo.Timeout))
 		if err != nil {
 			klog.Errorf("SetDeadline failed: %v", err)
 			continue
@@ -250,38 +329,66 @@ func (udp *udpProxySocket) ProxyLoop(service iptables.ServicePortName, myInfo *S
 	}
 }
 
-func (udp *udpProxySocket) getBackendConn(activeClients *ClientCache, cliAddr net.Addr, loadBalancer LoadBalancer, service iptables.ServicePortName, timeout time.Duration) (net.Conn, error) {
+func (udp *udpProxySocket) getBackendConn(myInfo *ServiceInfo, cliAddr net.Addr, datagram []byte, loadBalancer LoadBalancer, service common.ServicePortName) (net.Conn, *flow, error) {
+	activeClients, timeout := myInfo.ActiveClients, myInfo.Timeout
 	activeClients.Mu.Lock()
 	defer activeClients.Mu.Unlock()
 
 	svrConn, found := activeClients.Clients[cliAddr.String()]
+	if !found && activeClients.quic != nil {
+		// a known QUIC connection from a new address keeps its backend connection
+		var prevAddr net.Addr
+		if svrConn, prevAddr = activeClients.quic.lookup(datagram, cliAddr); svrConn != nil {
+			klog.V(3).Infof("QUIC connection moved from %s to %s", prevAddr, cliAddr)
+			delete(activeClients.Clients, prevAddr.String())
+			activeClients.Clients[cliAddr.String()] = svrConn
+			activeClients.quic.add(cliAddr, svrConn)
+			found = true
+		}
+	}
 	if !found {
 		// TODO: This could spin up a new goroutine to make the outbound connection,
 		// and keep accepting inbound traffic.
 		klog.V(3).Infof("New UDP connection from %s", cliAddr)
 		var err error
-		svrConn, err = TryConnectEndpoints(service, cliAddr, "udp", loadBalancer)
+		svrConn, err = TryConnectEndpoints(service, cliAddr, "udp", myInfo.DSCP(), loadBalancer)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		if err = svrConn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		if err = svrConn.SetDeadline(udp.clock.Now().Add(timeout)); err != nil {
 			klog.Errorf("SetDeadline failed: %v", err)
-			return nil, err
+			return nil, nil, err
+		}
+		if udpConn, ok := svrConn.(*net.UDPConn); ok && myInfo.udp.ReadBuffer != 0 {
+			if err := udpConn.SetReadBuffer(myInfo.udp.ReadBuffer); err != nil {
+				klog.Errorf("SetReadBuffer failed: %v", err)
+			}
 		}
 		activeClients.Clients[cliAddr.String()] = svrConn
-		go func(cliAddr net.Addr, svrConn net.Conn, activeClients *ClientCache, timeout time.Duration) {
-			defer runtime.HandleCrash()
-			udp.proxyClient(cliAddr, svrConn, activeClients, timeout)
-		}(cliAddr, svrConn, activeClients, timeout)
+		if activeClients.quic != nil {
+			activeClients.quic.add(cliAddr, svrConn)
+		}
+		if activeClients.flows == nil {
+			activeClients.flows = map[net.Conn]*flow{}
+		}
+		activeClients.flows[svrConn] = &flow{endpoint: svrConn.RemoteAddr().String(), started: udp.clock.Now()}
+		go func(cliAddr net.Addr, svrConn net.Conn, activeClients *ClientCache, timeout time.Duration, size int) {
+			defer supervise.Recover("UDP proxy of " + cliAddr.String())
+			udp.proxyClient(cliAddr, svrConn, activeClients, timeout, size)
+		}(cliAddr, svrConn, activeClients, timeout, myInfo.udpDatagramSize())
 	}
-	return svrConn, nil
+	return svrConn, activeClients.flows[svrConn], nil
 }
 
 // This function is expected to be called as a goroutine.
 // TODO: Track and log bytes copied, like TCP
-func (udp *udpProxySocket) proxyClient(cliAddr net.Addr, svrConn net.Conn, activeClients *ClientCache, timeout time.Duration) {
+func (udp *udpProxySocket) proxyClient(cliAddr net.Addr, svrConn net.Conn, activeClients *ClientCache, timeout time.Duration, size int) {
 	defer svrConn.Close()
-	var buffer [4096]byte
+	activeClients.Mu.Lock()
+	f := activeClients.flows[svrConn]
+	activeClients.Mu.Unlock()
+
+	buffer := make([]byte, size)
 	for {
 		n, err := svrConn.Read(buffer[0:])
 		if err != nil {
@@ -290,11 +397,19 @@ func (udp *udpProxySocket) proxyClient(cliAddr net.Addr, svrConn net.Conn, activ
 			}
 			break
 		}
-		err = svrConn.SetDeadline(time.Now().Add(timeout))
+		f.addReceived(n)
+		err = svrConn.SetDeadline(udp.clock.Now().Add(timeout))
 		if err != nil {
 			klog.Errorf("SetDeadline failed: %v", err)
 			break
 		}
+		if activeClients.quic != nil {
+			// the client may have moved
+			activeClients.Mu.Lock()
+			activeClients.quic.learn(svrConn, buffer[0:n])
+			cliAddr = activeClients.quic.peer(svrConn)
+			activeClients.Mu.Unlock()
+		}
 		_, err = udp.WriteTo(buffer[0:n], cliAddr)
 		if err != nil {
 			if !logTimeout(err) {
@@ -304,6 +419,11 @@ func (udp *udpProxySocket) proxyClient(cliAddr net.Addr, svrConn net.Conn, activ
 		}
 	}
 	activeClients.Mu.Lock()
+	if activeClients.quic != nil {
+		cliAddr = activeClients.quic.peer(svrConn)
+		activeClients.quic.remove(svrConn)
+	}
 	delete(activeClients.Clients, cliAddr.String())
+	delete(activeClients.flows, svrConn)
 	activeClients.Mu.Unlock()
 }
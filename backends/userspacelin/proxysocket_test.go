@@ -0,0 +1,170 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	testingclock "k8s.io/utils/clock/testing"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/common"
+)
+
+// deadlineConn is a backend connection returning the given datagrams and recording its deadlines.
+type deadlineConn struct {
+	net.Conn
+	datagrams [][]byte
+	deadlines []time.Time
+}
+
+func (c *deadlineConn) Read(b []byte) (int, error) {
+	if len(c.datagrams) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, c.datagrams[0])
+	c.datagrams = c.datagrams[1:]
+	return n, nil
+}
+
+func (c *deadlineConn) SetDeadline(t time.Time) error {
+	c.deadlines = append(c.deadlines, t)
+	return nil
+}
+
+func (c *deadlineConn) Close() error { return nil }
+
+func TestUDPIdleDeadline(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	udp := &udpProxySocket{UDPConn: conn, clock: fakeClock}
+
+	cliAddr := conn.LocalAddr()
+	svrConn := &deadlineConn{datagrams: [][]byte{[]byte("a"), []byte("b")}}
+	activeClients := &ClientCache{Clients: map[string]net.Conn{cliAddr.String(): svrConn}}
+
+	start := fakeClock.Now()
+	timeout := 250 * time.Millisecond
+	udp.proxyClient(cliAddr, svrConn, activeClients, timeout, 16)
+
+	if len(svrConn.deadlines) != 2 {
+		t.Fatalf("%d deadlines set, expected 2", len(svrConn.deadlines))
+	}
+	for _, deadline := range svrConn.deadlines {
+		if expected := start.Add(timeout); !deadline.Equal(expected) {
+			t.Errorf("deadline %v, expected %v", deadline, expected)
+		}
+	}
+	if _, ok := activeClients.Clients[cliAddr.String()]; ok {
+		t.Error("client not removed once idle")
+	}
+}
+
+func TestListConnections(t *testing.T) {
+	backend, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	go func() {
+		conn, err := backend.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	// in is the proxy side of the client connection
+	client, in := tcpPipe(t)
+	defer client.Close()
+
+	out, err := net.DialTCP("tcp", nil, backend.Addr().(*net.TCPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxier, _ := newFakeProxier()
+	service := common.ServicePortName{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "svc"}, Port: "dns", Protocol: localv1.Protocol_TCP}
+	info := &ServiceInfo{protocol: localv1.Protocol_TCP, tcpFlows: &flowSet{flows: map[*flow]struct{}{}}}
+	proxier.serviceMap[service] = info
+
+	f := &flow{client: client.LocalAddr().String(), endpoint: backend.Addr().String(), started: time.Now()}
+	info.tcpFlows.add(f)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		proxyTCP(in, out, f)
+	}()
+
+	if _, err := client.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(client, make([]byte, 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the bytes are counted once written
+	var conns []Connection
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		conns = proxier.listConnections("ns/svc")
+		if len(conns) != 1 || conns[0].Received == 5 {
+			break
+		}
+	}
+	if len(conns) != 1 {
+		t.Fatalf("%d connections listed, expected 1", len(conns))
+	}
+	if c := conns[0]; c.Client != client.LocalAddr().String() || c.Endpoint != backend.Addr().String() || c.Sent != 5 || c.Received != 5 {
+		t.Errorf("unexpected connection %+v", c)
+	}
+	if conns := proxier.listConnections("ns/other"); len(conns) != 0 {
+		t.Errorf("connections of other services listed: %+v", conns)
+	}
+
+	client.Close()
+	<-done
+}
+
+// tcpPipe returns both ends of a TCP connection over the loopback.
+func tcpPipe(t *testing.T) (client, server *net.TCPConn) {
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	client, err = net.DialTCP("tcp", nil, l.Addr().(*net.TCPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err = l.AcceptTCP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
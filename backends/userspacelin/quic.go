@@ -0,0 +1,179 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"net"
+)
+
+// maxQUICConnIDLen is the maximum length of a QUIC (v1) connection ID.
+const maxQUICConnIDLen = 20
+
+// QUICMigrationCIDRs are the networks a QUIC connection may move to from another client IP. The
+// proxy can't validate the new path (the PATH_CHALLENGE frames are encrypted) and the connection IDs
+// are sent in the clear, so it only follows the port changes of a client (NAT rebinding) and the
+// moves to these trusted networks: otherwise, a spoofed datagram would divert the replies of a
+// connection to any address.
+var QUICMigrationCIDRs []*net.IPNet
+
+// quicFlows tracks the QUIC connections of the clients of a UDP service, so a client whose
+// address changes (NAT rebinding, or migration reusing its connection ID) keeps its backend
+// connection, and so its endpoint. The connection IDs are learnt from the long headers sent by the
+// endpoints; the ones issued later (NEW_CONNECTION_ID frames) are encrypted and can't be followed.
+// All accesses are protected by the mutex of the ClientCache.
+type quicFlows struct {
+	// backends are the backend connections by connection ID chosen by the endpoints
+	backends map[string]net.Conn
+	// connIDLens are the lengths of the known connection IDs (short headers don't carry it)
+	connIDLens map[int]bool
+	// peers are the current client addresses of the backend connections
+	peers map[net.Conn]net.Addr
+	// connIDs are the connection IDs of the backend connections
+	connIDs map[net.Conn][]string
+}
+
+func newQUICFlows() *quicFlows {
+	return &quicFlows{
+		backends:   map[string]net.Conn{},
+		connIDLens: map[int]bool{},
+		peers:      map[net.Conn]net.Addr{},
+		connIDs:    map[net.Conn][]string{},
+	}
+}
+
+// quicLongHeaderConnIDs returns the destination and source connection IDs of a QUIC long header
+// packet.
+func quicLongHeaderConnIDs(b []byte) (dcid, scid []byte, ok bool) {
+	// flags (1), version (4), DCID length (1)
+	if len(b) < 6 || b[0]&0x80 == 0 {
+		return nil, nil, false
+	}
+
+	// the fixed bit is set, except in the version negotiation packets (version 0)
+	if b[0]&0x40 == 0 && (b[1]|b[2]|b[3]|b[4]) != 0 {
+		return nil, nil, false
+	}
+
+	dcidLen := int(b[5])
+	if dcidLen > maxQUICConnIDLen || len(b) < 6+dcidLen+1 {
+		return nil, nil, false
+	}
+	dcid = b[6 : 6+dcidLen]
+
+	scidLen := int(b[6+dcidLen])
+	start := 6 + dcidLen + 1
+	if scidLen > maxQUICConnIDLen || len(b) < start+scidLen {
+		return nil, nil, false
+	}
+	scid = b[start : start+scidLen]
+
+	return dcid, scid, true
+}
+
+// isQUICShortHeader returns true if b looks like a QUIC short header packet (fixed bit set).
+func isQUICShortHeader(b []byte) bool {
+	return len(b) > 1 && b[0]&0xc0 == 0x40
+}
+
+// add starts tracking the backend connection of a client.
+func (f *quicFlows) add(cliAddr net.Addr, svrConn net.Conn) {
+	f.peers[svrConn] = cliAddr
+}
+
+// remove stops tracking a backend connection.
+func (f *quicFlows) remove(svrConn net.Conn) {
+	for _, id := range f.connIDs[svrConn] {
+		delete(f.backends, id)
+	}
+	delete(f.connIDs, svrConn)
+	delete(f.peers, svrConn)
+}
+
+// peer returns the current client address of a backend connection.
+func (f *quicFlows) peer(svrConn net.Conn) net.Addr {
+	return f.peers[svrConn]
+}
+
+// learn records the source connection ID of a packet sent by an endpoint.
+func (f *quicFlows) learn(svrConn net.Conn, datagram []byte) {
+	_, scid, ok := quicLongHeaderConnIDs(datagram)
+	if !ok || len(scid) == 0 {
+		return
+	}
+
+	id := string(scid)
+	if _, known := f.backends[id]; known {
+		return
+	}
+
+	f.backends[id] = svrConn
+	f.connIDLens[len(scid)] = true
+	f.connIDs[svrConn] = append(f.connIDs[svrConn], id)
+}
+
+// lookup returns the backend connection of the QUIC connection of a datagram from a new client
+// address, and its previous client address, if the connection may move to the new address (see
+// QUICMigrationCIDRs).
+func (f *quicFlows) lookup(datagram []byte, cliAddr net.Addr) (svrConn net.Conn, prevAddr net.Addr) {
+	if dcid, _, ok := quicLongHeaderConnIDs(datagram); ok {
+		svrConn = f.backends[string(dcid)]
+	} else if isQUICShortHeader(datagram) {
+		for l := range f.connIDLens {
+			if len(datagram) < 1+l {
+				continue
+			}
+			if svrConn = f.backends[string(datagram[1:1+l])]; svrConn != nil {
+				break
+			}
+		}
+	}
+
+	if svrConn == nil {
+		return nil, nil
+	}
+
+	prevAddr = f.peers[svrConn]
+	if !quicMigrationAllowed(prevAddr, cliAddr) {
+		return nil, nil
+	}
+	return svrConn, prevAddr
+}
+
+// quicMigrationAllowed returns true if a QUIC connection may move from the client address prev to
+// addr: the same IP, or an IP in QUICMigrationCIDRs.
+func quicMigrationAllowed(prev, addr net.Addr) bool {
+	ip := addrIP(addr)
+	if ip == nil {
+		return false
+	}
+	if prevIP := addrIP(prev); prevIP != nil && prevIP.Equal(ip) {
+		return true
+	}
+	for _, cidr := range QUICMigrationCIDRs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func addrIP(addr net.Addr) net.IP {
+	if udpAddr, ok := addr.(*net.UDPAddr); ok {
+		return udpAddr.IP
+	}
+	return nil
+}
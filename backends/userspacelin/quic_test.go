@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+// longHeader returns a QUIC v1 initial packet header with the given connection IDs.
+func longHeader(dcid, scid []byte) []byte {
+	b := []byte{0xc0, 0, 0, 0, 1, byte(len(dcid))}
+	b = append(b, dcid...)
+	b = append(b, byte(len(scid)))
+	b = append(b, scid...)
+	return append(b, 0xaa, 0xbb) // token length and payload
+}
+
+func TestQUICLongHeaderConnIDs(t *testing.T) {
+	dcid, scid := []byte("destination"), []byte("src")
+	valid := longHeader(dcid, scid)
+
+	versionNegotiation := longHeader(dcid, scid)
+	versionNegotiation[0] = 0x80
+	versionNegotiation[4] = 0
+
+	noFixedBit := longHeader(dcid, scid)
+	noFixedBit[0] = 0x80
+
+	for _, tc := range []struct {
+		name       string
+		b          []byte
+		ok         bool
+		dcid, scid []byte
+	}{
+		{"valid", valid, true, dcid, scid},
+		{"empty connection IDs", longHeader(nil, nil), true, []byte{}, []byte{}},
+		{"version negotiation", versionNegotiation, true, dcid, scid},
+		{"empty", nil, false, nil, nil},
+		{"short header", []byte{0x40, 1, 2, 3, 4, 5, 6, 7}, false, nil, nil},
+		{"no fixed bit", noFixedBit, false, nil, nil},
+		{"truncated version", valid[:4], false, nil, nil},
+		{"truncated destination ID", valid[:6+len(dcid)-1], false, nil, nil},
+		{"missing source ID length", valid[:6+len(dcid)], false, nil, nil},
+		{"truncated source ID", valid[:6+len(dcid)+1+len(scid)-1], false, nil, nil},
+		{"destination ID too long", longHeader(make([]byte, maxQUICConnIDLen+1), scid), false, nil, nil},
+		{"source ID too long", longHeader(dcid, make([]byte, maxQUICConnIDLen+1)), false, nil, nil},
+		{"destination ID length past the end", []byte{0xc0, 0, 0, 0, 1, 0xff, 1, 2}, false, nil, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d, s, ok := quicLongHeaderConnIDs(tc.b)
+			if ok != tc.ok {
+				t.Fatalf("expected ok %v, got %v", tc.ok, ok)
+			}
+			if !bytes.Equal(d, tc.dcid) || !bytes.Equal(s, tc.scid) {
+				t.Errorf("expected IDs %q and %q, got %q and %q", tc.dcid, tc.scid, d, s)
+			}
+		})
+	}
+}
+
+func TestQUICFlowsLookup(t *testing.T) {
+	defer func(cidrs []*net.IPNet) { QUICMigrationCIDRs = cidrs }(QUICMigrationCIDRs)
+	_, trusted, _ := net.ParseCIDR("10.2.0.0/16")
+	QUICMigrationCIDRs = []*net.IPNet{trusted}
+
+	client := &net.UDPAddr{IP: net.ParseIP("10.1.0.1"), Port: 4000}
+	svrConn := &net.UDPConn{}
+
+	f := newQUICFlows()
+	f.add(client, svrConn)
+	// the endpoint chooses its connection ID
+	f.learn(svrConn, longHeader([]byte("client"), []byte("server-id")))
+
+	shortHeader := append([]byte{0x40}, "server-id, then the payload"...)
+
+	for _, tc := range []struct {
+		name     string
+		datagram []byte
+		addr     string
+		found    bool
+	}{
+		{"long header, rebound port", longHeader([]byte("server-id"), nil), "10.1.0.1:5000", true},
+		{"short header, rebound port", shortHeader, "10.1.0.1:5000", true},
+		{"short header, trusted network", shortHeader, "10.2.3.4:4000", true},
+		{"short header, other IP", shortHeader, "192.0.2.1:4000", false},
+		{"long header, other IP", longHeader([]byte("server-id"), nil), "192.0.2.1:4000", false},
+		{"unknown connection ID", longHeader([]byte("other-id!"), nil), "10.1.0.1:5000", false},
+		{"truncated short header", shortHeader[:4], "10.1.0.1:5000", false},
+		{"truncated long header", longHeader([]byte("server-id"), nil)[:8], "10.1.0.1:5000", false},
+		{"not QUIC", []byte{0x00, 0x01}, "10.1.0.1:5000", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			addr, err := net.ResolveUDPAddr("udp", tc.addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			conn, prev := f.lookup(tc.datagram, addr)
+			if found := conn != nil; found != tc.found {
+				t.Fatalf("expected found %v, got %v", tc.found, found)
+			}
+			if tc.found && (conn != svrConn || prev != client) {
+				t.Errorf("expected the connection of %s, got %v from %s", client, conn, prev)
+			}
+		})
+	}
+}
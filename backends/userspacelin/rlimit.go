@@ -1,4 +1,6 @@
+//go:build !windows
 // +build !windows
+
 /*
 Copyright 2021 The Kubernetes Authors.
 
@@ -17,8 +19,40 @@ limitations under the License.
 
 package userspacelin
 
-import "golang.org/x/sys/unix"
+import (
+	"bytes"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
 
 func setRLimit(limit uint64) error {
 	return unix.Setrlimit(unix.RLIMIT_NOFILE, &unix.Rlimit{Max: limit, Cur: limit})
 }
+
+// getRLimit returns the current limit of open files.
+func getRLimit() (uint64, error) {
+	rlimit := unix.Rlimit{}
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &rlimit); err != nil {
+		return 0, err
+	}
+	return rlimit.Cur, nil
+}
+
+// runningInUserNS returns true if the process runs in a user namespace, where raising the
+// limits is not allowed.
+func runningInUserNS() bool {
+	uidMap, err := os.ReadFile("/proc/self/uid_map")
+	if err != nil {
+		// no user namespaces on this system
+		return false
+	}
+
+	return !isInitialUIDMap(uidMap)
+}
+
+// isInitialUIDMap returns true if uidMap maps the full range of uids, like the one of the initial
+// user namespace.
+func isInitialUIDMap(uidMap []byte) bool {
+	return bytes.Equal(bytes.Join(bytes.Fields(uidMap), []byte(" ")), []byte("0 0 4294967295"))
+}
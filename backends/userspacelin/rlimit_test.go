@@ -0,0 +1,93 @@
+//go:build !windows
+// +build !windows
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestIsInitialUIDMap(t *testing.T) {
+	for uidMap, expected := range map[string]bool{
+		"         0          0 4294967295\n": true,
+		"0 0 4294967295":                     true,
+		"         0       1000          1\n": false,
+		"0 100000 65536\n":                   false,
+		"":                                   false,
+	} {
+		if initial := isInitialUIDMap([]byte(uidMap)); initial != expected {
+			t.Errorf("%q: expected %v, got %v", uidMap, expected, initial)
+		}
+	}
+}
+
+func TestSetOpenFilesLimit(t *testing.T) {
+	defer func(set func(uint64) error, get func() (uint64, error), userNS func() bool) {
+		setOpenFilesRLimit, getOpenFilesRLimit, inUserNS = set, get, userNS
+	}(setOpenFilesRLimit, getOpenFilesRLimit, inUserNS)
+
+	errDenied := errors.New("operation not permitted")
+
+	for _, tc := range []struct {
+		name      string
+		limit     uint64
+		setErr    error
+		userNS    bool
+		expectErr bool
+		current   uint64
+	}{
+		{name: "set", limit: 64000, current: 64000},
+		{name: "kept", limit: 0, current: 1024},
+		{name: "denied", limit: 64000, setErr: errDenied, expectErr: true},
+		{name: "denied in a user namespace", limit: 64000, setErr: errDenied, userNS: true, current: 1024},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			current := uint64(1024)
+			setOpenFilesRLimit = func(limit uint64) error {
+				if tc.setErr != nil {
+					return tc.setErr
+				}
+				current = limit
+				return nil
+			}
+			getOpenFilesRLimit = func() (uint64, error) { return current, nil }
+			inUserNS = func() bool { return tc.userNS }
+
+			MaxOpenFiles.Set(0)
+
+			err := setOpenFilesLimit(tc.limit)
+			if tc.expectErr {
+				if !errors.Is(err, errDenied) {
+					t.Errorf("expected the error to be returned, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if v := testutil.ToFloat64(MaxOpenFiles); v != float64(tc.current) {
+				t.Errorf("expected the effective limit %d, got %v", tc.current, v)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import "errors"
+
+var errNoRLimit = errors.New("open files limits are not supported on this platform")
+
+func setRLimit(limit uint64) error {
+	return nil
+}
+
+func getRLimit() (uint64, error) {
+	return 0, errNoRLimit
+}
+
+func runningInUserNS() bool {
+	return false
+}
@@ -19,6 +19,7 @@ package userspacelin
 import (
 	"errors"
 	"fmt"
+	"math/rand"
 	"net"
 	"reflect"
 	"sort"
@@ -29,12 +30,14 @@ import (
 
 	"sigs.k8s.io/kpng/api/localv1"
 
-	"sigs.k8s.io/kpng/backends/iptables"
+	"sigs.k8s.io/kpng/backends/common"
+	"sigs.k8s.io/kpng/client/slowstart"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 
 	klog "k8s.io/klog/v2"
+	"k8s.io/utils/clock"
 )
 
 var (
@@ -59,7 +62,11 @@ type affinityPolicy struct {
 // LoadBalancerRR is a round-robin load balancer.
 type LoadBalancerRR struct {
 	lock     sync.RWMutex
-	services map[iptables.ServicePortName]*balancerState
+	services map[common.ServicePortName]*balancerState
+	outliers *outlierDetector
+
+	// clock expires the sticky sessions.
+	clock clock.PassiveClock
 }
 
 // Ensure this implements LoadBalancer.
@@ -69,6 +76,54 @@ type balancerState struct {
 	endpoints []string // a list of "ip:port" style strings
 	index     int      // current index into endpoints
 	affinity  affinityPolicy
+
+	// weights of the endpoints having one; when set, the endpoints are chosen using a smooth
+	// weighted round-robin, tracking the current weight of each endpoint.
+	weights        map[string]int
+	currentWeights map[string]int
+}
+
+// next returns the next endpoint. This assumes the lb.lock is held.
+func (state *balancerState) next() string {
+	if len(state.weights) == 0 {
+		endpoint := state.endpoints[state.index]
+		state.index = (state.index + 1) % len(state.endpoints)
+		return endpoint
+	}
+
+	total := 0
+	best := ""
+	for _, endpoint := range state.endpoints {
+		weight, ok := state.weights[endpoint]
+		if !ok {
+			weight = 1
+		}
+		total += weight
+
+		state.currentWeights[endpoint] += weight
+		if best == "" || state.currentWeights[endpoint] > state.currentWeights[best] {
+			best = endpoint
+		}
+	}
+
+	state.currentWeights[best] -= total
+	return best
+}
+
+// setWeight sets the weight of an endpoint (0 for the default weight). This assumes the lb.lock is held.
+func (state *balancerState) setWeight(endpoint string, weight int32) {
+	delete(state.currentWeights, endpoint)
+
+	if weight <= 0 {
+		delete(state.weights, endpoint)
+		return
+	}
+
+	if state.weights == nil {
+		state.weights = map[string]int{}
+		state.currentWeights = map[string]int{}
+	}
+	state.weights[endpoint] = int(weight)
 }
 
 func newAffinityPolicy(affinityClientIP *localv1.ClientIPAffinity, ttlSeconds int) *affinityPolicy {
@@ -82,11 +137,13 @@ func newAffinityPolicy(affinityClientIP *localv1.ClientIPAffinity, ttlSeconds in
 // NewLoadBalancerRR returns a new LoadBalancerRR.
 func NewLoadBalancerRR() *LoadBalancerRR {
 	return &LoadBalancerRR{
-		services: map[iptables.ServicePortName]*balancerState{},
+		services: map[common.ServicePortName]*balancerState{},
+		outliers: newOutlierDetector(OutlierDetection),
+		clock:    clock.RealClock{},
 	}
 }
 
-func (lb *LoadBalancerRR) NewService(svcPort iptables.ServicePortName, affinityType *localv1.ClientIPAffinity, ttlSeconds int) error {
+func (lb *LoadBalancerRR) NewService(svcPort common.ServicePortName, affinityType *localv1.ClientIPAffinity, ttlSeconds int) error {
 	klog.V(4).Infof("LoadBalancerRR NewService %q", svcPort)
 	lb.lock.Lock()
 	lb.lock.Unlock()
@@ -95,7 +152,7 @@ func (lb *LoadBalancerRR) NewService(svcPort iptables.ServicePortName, affinityT
 }
 
 // This assumes that lb.lock is already held.
-func (lb *LoadBalancerRR) newServiceInternal(svcPort iptables.ServicePortName, affinityClientIP *localv1.ClientIPAffinity, ttlSeconds int) *balancerState {
+func (lb *LoadBalancerRR) newServiceInternal(svcPort common.ServicePortName, affinityClientIP *localv1.ClientIPAffinity, ttlSeconds int) *balancerState {
 	if ttlSeconds == 0 {
 		ttlSeconds = int(v1.DefaultClientIPServiceAffinitySeconds) //default to 3 hours if not specified.  Should 0 be unlimited instead????
 	}
@@ -109,7 +166,7 @@ func (lb *LoadBalancerRR) newServiceInternal(svcPort iptables.ServicePortName, a
 	return lb.services[svcPort]
 }
 
-func (lb *LoadBalancerRR) DeleteService(svcPort iptables.ServicePortName) {
+func (lb *LoadBalancerRR) DeleteService(svcPort common.ServicePortName) {
 	klog.V(4).Infof("LoadBalancerRR DeleteService %q", svcPort)
 	lb.lock.Lock()
 	defer lb.lock.Unlock()
@@ -126,7 +183,7 @@ func isSessionAffinity(affinity *affinityPolicy) bool {
 }
 
 // ServiceHasEndpoints checks whether a service entry has endpoints.
-func (lb *LoadBalancerRR) ServiceHasEndpoints(svcPort iptables.ServicePortName) bool {
+func (lb *LoadBalancerRR) ServiceHasEndpoints(svcPort common.ServicePortName) bool {
 	lb.lock.RLock()
 	defer lb.lock.RUnlock()
 	state, exists := lb.services[svcPort]
@@ -137,7 +194,7 @@ func (lb *LoadBalancerRR) ServiceHasEndpoints(svcPort iptables.ServicePortName)
 
 // NextEndpoint returns a service endpoint.
 // The service endpoint is chosen using the round-robin algorithm.
-func (lb *LoadBalancerRR) NextEndpoint(svcPort iptables.ServicePortName, srcAddr net.Addr, sessionAffinityReset bool) (string, error) {
+func (lb *LoadBalancerRR) NextEndpoint(svcPort common.ServicePortName, srcAddr net.Addr, sessionAffinityReset bool) (string, error) {
 	// Coarse locking is simple.  We can get more fine-grained if/when we
 	// can prove it matters.
 	lb.lock.Lock()
@@ -164,18 +221,22 @@ func (lb *LoadBalancerRR) NextEndpoint(svcPort iptables.ServicePortName, srcAddr
 		}
 		if !sessionAffinityReset {
 			sessionAffinity, exists := state.affinity.affinityMap[ipaddr]
-			if exists && int(time.Since(sessionAffinity.lastUsed).Seconds()) < state.affinity.ttlSeconds {
+			if exists && int(lb.clock.Since(sessionAffinity.lastUsed).Seconds()) < state.affinity.ttlSeconds &&
+				lb.outliers.available(svcPort, sessionAffinity.endpoint) {
 				// Affinity wins.
 				endpoint := sessionAffinity.endpoint
-				sessionAffinity.lastUsed = time.Now()
+				sessionAffinity.lastUsed = lb.clock.Now()
 				klog.V(4).Infof("NextEndpoint for service %q from IP %s with sessionAffinity %#v: %s", svcPort, ipaddr, sessionAffinity, endpoint)
 				return endpoint, nil
 			}
 		}
 	}
-	// Take the next endpoint.
-	endpoint := state.endpoints[state.index]
-	state.index = (state.index + 1) % len(state.endpoints)
+	// Take the next endpoint. Endpoints ramping up (see slowstart) are skipped with a probability
+	// decreasing over the ramp-up, and ejected endpoints are skipped unless they are all ejected.
+	endpoint := state.next()
+	for n := 1; n < len(state.endpoints) && !lb.accept(svcPort, endpoint); n++ {
+		endpoint = state.next()
+	}
 
 	if sessionAffinityEnabled {
 		var affinity *affinityState
@@ -184,7 +245,7 @@ func (lb *LoadBalancerRR) NextEndpoint(svcPort iptables.ServicePortName, srcAddr
 			affinity = new(affinityState) //&affinityState{ipaddr, "TCP", "", endpoint, time.Now()}
 			state.affinity.affinityMap[ipaddr] = affinity
 		}
-		affinity.lastUsed = time.Now()
+		affinity.lastUsed = lb.clock.Now()
 		affinity.endpoint = endpoint
 		affinity.clientIP = ipaddr
 		klog.V(4).Infof("Updated affinity key %s: %#v", ipaddr, state.affinity.affinityMap[ipaddr])
@@ -194,7 +255,7 @@ func (lb *LoadBalancerRR) NextEndpoint(svcPort iptables.ServicePortName, srcAddr
 }
 
 // Remove any session affinity records associated to a particular endpoint (for example when a pod goes down).
-func removeSessionAffinityByEndpoint(state *balancerState, svcPort iptables.ServicePortName, endpoint string) {
+func removeSessionAffinityByEndpoint(state *balancerState, svcPort common.ServicePortName, endpoint string) {
 	for _, affinity := range state.affinity.affinityMap {
 		if affinity.endpoint == endpoint {
 			klog.V(4).Infof("Removing client: %s from affinityMap for service %q", affinity.endpoint, svcPort)
@@ -206,7 +267,7 @@ func removeSessionAffinityByEndpoint(state *balancerState, svcPort iptables.Serv
 // Loop through the valid endpoints and then the endpoints associated with the Load Balancer.
 // Then remove any session affinity records that are not in both lists.
 // This assumes the lb.lock is held.
-func (lb *LoadBalancerRR) removeStaleAffinity(svcPort iptables.ServicePortName, newEndpoints []string) {
+func (lb *LoadBalancerRR) removeStaleAffinity(svcPort common.ServicePortName, newEndpoints []string) {
 	newEndpointsSet := sets.NewString()
 	for _, newEndpoint := range newEndpoints {
 		newEndpointsSet.Insert(newEndpoint)
@@ -236,7 +297,7 @@ func (lb *LoadBalancerRR) OnEndpointsAdd(ep *localv1.Endpoint, svc *localv1.Serv
 	for portname := range portsToEndpoints {
 		// OMG endpoints are named the same thing as their service so we can use this to find the service name
 		// MEANWHILE endpointSlice has a LABEL that references the service
-		svcPort := iptables.ServicePortName{NamespacedName: namespacedName, Port: portname}
+		svcPort := common.ServicePortName{NamespacedName: namespacedName, Port: portname}
 		newEndpoints := portsToEndpoints[portname]
 		state, exists := lb.services[svcPort]
 		if state != nil {
@@ -251,6 +312,11 @@ func (lb *LoadBalancerRR) OnEndpointsAdd(ep *localv1.Endpoint, svc *localv1.Serv
 			state.endpoints = ShuffleStrings(newEndpoints)
 			// Reset the round-robin index.
 			state.index = 0
+
+			for _, endpoint := range portsToEndpoints[portname] {
+				state.setWeight(endpoint, ep.GetWeight())
+				slowstart.Default().Add(rampKey(svcPort, endpoint))
+			}
 		}
 	}
 }
@@ -262,14 +328,14 @@ func (lb *LoadBalancerRR) OnEndpointsAdd(ep *localv1.Endpoint, svc *localv1.Serv
 
 // 	portsToEndpoints := buildPortsToEndp
 // 	ointsMap(endpoints)
-// 	registeredEndpoints := make(map[iptables.ServicePortName]bool)
+// 	registeredEndpoints := make(map[common.ServicePortName]bool)
 
 // 	lb.lock.Lock()
 // 	defer lb.lock.Unlock()
 
 // 	// new stuff
 // 	for portname := range portsToEndpoints {
-// 		svcPort := iptables.ServicePortName{NamespacedName: types.NamespacedName{Namespace: endpoints.Namespace, Name: endpoints.Name}, Port: portname}
+// 		svcPort := common.ServicePortName{NamespacedName: types.NamespacedName{Namespace: endpoints.Namespace, Name: endpoints.Name}, Port: portname}
 // 		newEndpoints := portsToEndpoints[portname]
 // 		state, exists := lb.services[svcPort]
 
@@ -300,7 +366,7 @@ func (lb *LoadBalancerRR) OnEndpointsAdd(ep *localv1.Endpoint, svc *localv1.Serv
 // 	// clean old stuff
 // 	// Now remove all endpoints missing from the update.
 // 	for portname := range oldPortsToEndpoints {
-// 		svcPort := iptables.ServicePortName{NamespacedName: types.NamespacedName{Namespace: oldEndpoints.Namespace, Name: oldEndpoints.Name}, Port: portname}
+// 		svcPort := common.ServicePortName{NamespacedName: types.NamespacedName{Namespace: oldEndpoints.Namespace, Name: oldEndpoints.Name}, Port: portname}
 // 		if _, exists := registeredEndpoints[svcPort]; !exists {
 // 			lb.resetService(svcPort)
 // 		}
@@ -314,7 +380,7 @@ func (lb *LoadBalancerRR) OnEndpointsDelete(ep *localv1.Endpoint, svc *localv1.S
 	defer lb.lock.Unlock()
 
 	for portname := range portsToEndpoints {
-		svcPort := iptables.ServicePortName{NamespacedName: types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}, Port: portname}
+		svcPort := common.ServicePortName{NamespacedName: types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}, Port: portname}
 		if state, ok := lb.services[svcPort]; ok {
 			if len(ep.IPs.V4) > 0 && len(ep.PortOverrides) > 0 {
 				deletedIP := fmt.Sprintf("%s:%d", ep.IPs.V4[0], ep.PortOverrides[0].Port)
@@ -324,6 +390,9 @@ func (lb *LoadBalancerRR) OnEndpointsDelete(ep *localv1.Endpoint, svc *localv1.S
 						state.endpoints[i] = state.endpoints[len(state.endpoints)-1]
 						state.endpoints = state.endpoints[:len(state.endpoints)-1]
 						state.index = 0
+						state.setWeight(deletedIP, 0)
+						slowstart.Default().Delete(rampKey(svcPort, deletedIP))
+						lb.outliers.forget(svcPort, deletedIP)
 					}
 				}
 			}
@@ -334,9 +403,50 @@ func (lb *LoadBalancerRR) OnEndpointsDelete(ep *localv1.Endpoint, svc *localv1.S
 	}
 }
 
+func (lb *LoadBalancerRR) ResetAffinity(ep *localv1.Endpoint, svc *localv1.Service) {
+	portsToEndpoints := buildPortsToEndpointsMap(ep, svc)
+
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+
+	for portname, endpoints := range portsToEndpoints {
+		svcPort := common.ServicePortName{NamespacedName: types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}, Port: portname}
+		if state, ok := lb.services[svcPort]; ok {
+			for _, endpoint := range endpoints {
+				removeSessionAffinityByEndpoint(state, svcPort, endpoint)
+			}
+		}
+	}
+}
+
 func (lb *LoadBalancerRR) OnEndpointsSynced() {
 }
 
+// accept returns true if the endpoint can take a new connection. This assumes the lb.lock is held.
+func (lb *LoadBalancerRR) accept(svcPort common.ServicePortName, endpoint string) bool {
+	return rand.Float64() < rampFactor(svcPort, endpoint) && lb.outliers.available(svcPort, endpoint)
+}
+
+// ReportResult feeds the outlier detection.
+func (lb *LoadBalancerRR) ReportResult(svcPort common.ServicePortName, endpoint string, err error) {
+	lb.lock.RLock()
+	endpointCount := 0
+	if state, ok := lb.services[svcPort]; ok && state != nil {
+		endpointCount = len(state.endpoints)
+	}
+	lb.lock.RUnlock()
+
+	lb.outliers.report(svcPort, endpoint, endpointCount, err != nil)
+}
+
+func rampKey(svcPort common.ServicePortName, endpoint string) string {
+	return svcPort.String() + "/" + endpoint
+}
+
+func rampFactor(svcPort common.ServicePortName, endpoint string) float64 {
+	return slowstart.Default().Factor(rampKey(svcPort, endpoint))
+}
+
 // Tests whether two slices are equivalent.  This sorts both slices in-place.
 func slicesEquiv(lhs, rhs []string) bool {
 	if len(lhs) != len(rhs) {
@@ -350,7 +460,7 @@ func slicesEquiv(lhs, rhs []string) bool {
 	return false
 }
 
-func (lb *LoadBalancerRR) CleanupStaleStickySessions(svcPort iptables.ServicePortName) {
+func (lb *LoadBalancerRR) CleanupStaleStickySessions(svcPort common.ServicePortName) {
 	lb.lock.Lock()
 	defer lb.lock.Unlock()
 
@@ -359,7 +469,7 @@ func (lb *LoadBalancerRR) CleanupStaleStickySessions(svcPort iptables.ServicePor
 		return
 	}
 	for ip, affinity := range state.affinity.affinityMap {
-		if int(time.Since(affinity.lastUsed).Seconds()) >= state.affinity.ttlSeconds {
+		if int(lb.clock.Since(affinity.lastUsed).Seconds()) >= state.affinity.ttlSeconds {
 			klog.V(4).Infof("Removing client %s from affinityMap for service %q", affinity.clientIP, svcPort)
 			delete(state.affinity.affinityMap, ip)
 		}
@@ -0,0 +1,75 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	testingclock "k8s.io/utils/clock/testing"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/common"
+)
+
+func TestStickySessionExpiry(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+
+	lb := NewLoadBalancerRR()
+	lb.clock = fakeClock
+
+	svcPort := common.ServicePortName{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "svc"}, Port: "http"}
+	lb.NewService(svcPort, &localv1.ClientIPAffinity{}, 10)
+	lb.services[svcPort].endpoints = []string{"10.1.0.1:80", "10.1.0.2:80"}
+
+	client := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 40000}
+	next := func() string {
+		t.Helper()
+		endpoint, err := lb.NextEndpoint(svcPort, client, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return endpoint
+	}
+
+	sticky := next()
+
+	// each use extends the session
+	for i := 0; i < 3; i++ {
+		fakeClock.Step(9 * time.Second)
+		if endpoint := next(); endpoint != sticky {
+			t.Fatalf("session lost after %d uses: got %s, expected %s", i+1, endpoint, sticky)
+		}
+	}
+
+	lb.CleanupStaleStickySessions(svcPort)
+	if len(lb.services[svcPort].affinity.affinityMap) != 1 {
+		t.Fatal("session cleaned up before its expiry")
+	}
+
+	fakeClock.Step(10 * time.Second)
+	lb.CleanupStaleStickySessions(svcPort)
+	if len(lb.services[svcPort].affinity.affinityMap) != 0 {
+		t.Fatal("expired session not cleaned up")
+	}
+
+	if endpoint := next(); endpoint == sticky {
+		t.Errorf("expired session kept %s", endpoint)
+	}
+}
@@ -17,16 +17,17 @@ limitations under the License.
 package userspacelin
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"log"
+	"strings"
 	"time"
 
 	utilnet "k8s.io/apimachinery/pkg/util/net"
 	klog "k8s.io/klog/v2"
 	netutils "k8s.io/utils/net"
 
-	"k8s.io/utils/exec"
-
 	iptablesutil "sigs.k8s.io/kpng/backends/iptables/util"
 
 	"sync"
@@ -34,9 +35,16 @@ import (
 	"github.com/spf13/pflag"
 
 	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/auditlog"
+	"sigs.k8s.io/kpng/client/backendcmd"
+	"sigs.k8s.io/kpng/client/drain"
 	"sigs.k8s.io/kpng/client/localsink"
 	"sigs.k8s.io/kpng/client/localsink/decoder"
+	"sigs.k8s.io/kpng/client/localsink/familyfilter"
 	"sigs.k8s.io/kpng/client/localsink/filterreset"
+	"sigs.k8s.io/kpng/client/privhelper"
+	"sigs.k8s.io/kpng/client/serviceevents"
+	"sigs.k8s.io/kpng/client/slowstart"
 )
 
 type Backend struct {
@@ -52,16 +60,78 @@ var proxier *UserspaceLinux
 
 // var usImpl map[v1.IPFamily]*UserspaceLinux
 var _ decoder.Interface = &Backend{}
+var _ decoder.InitialSyncListener = &Backend{}
+var _ serviceevents.SessionAffinityResetListener = &Backend{}
+var _ backendcmd.Checker = &Backend{}
+var _ familyfilter.Backend = &Backend{}
+var _ drain.Backend = &Backend{}
+var _ auditlog.Backend = &Backend{}
 
 func New() *Backend {
 	return &Backend{}
 }
 
 func (s *Backend) Sink() localsink.Sink {
-	return filterreset.New(decoder.New(s))
+	return filterreset.New(decoder.New(serviceevents.Wrap(s)))
+}
+
+// SupportsDrain is true: the node port listeners stop accepting while the node is draining.
+func (s *Backend) SupportsDrain() bool { return true }
+
+// RecordsChanges is true: the chains and rules of the portals are recorded.
+func (s *Backend) RecordsChanges() bool { return true }
+
+// SupportsSlowStart is true: the round robin skips the endpoints ramping up with a decreasing
+// probability.
+func (s *Backend) SupportsSlowStart() bool { return true }
+
+// IPFamilies returns IPv4: the proxier only listens and writes rules for IPv4.
+func (s *Backend) IPFamilies() []localv1.IPFamily {
+	return []localv1.IPFamily{localv1.IPFamily_IPv4}
 }
 
 func (s *Backend) BindFlags(flags *pflag.FlagSet) {
+	iptablesutil.BindFlags(flags)
+	flags.Uint64Var(&MaxOpenFilesLimit, "max-open-files", MaxOpenFilesLimit, "Limit of open files of the proxy (0 to keep the current limit)")
+	OutlierDetection.BindFlags(flags)
+	UDP.BindFlags(flags)
+	TCP.BindFlags(flags)
+	flags.StringVar(&statusSocket, "status-socket", "", "Unix socket serving the state and the connections of the proxy as JSON (see kpng userspace status and conns), disabled if empty")
+	flags.StringVar(&listenIP, "listen-ip", "0.0.0.0", "IP the proxy listens on (0.0.0.0 to use the host IP)")
+	flags.BoolVar(&AllowLocalhostProxy, "allow-localhost-proxy", false, "Allow --listen-ip to be a loopback address, enabling route_localnet (for CI and single-node setups)")
+	flags.StringSliceVar(&quicMigrationCIDRs, "udp-quic-migration-cidrs", nil, "Networks the QUIC connections tracked by --udp-flow-tracking=quic may move to from another client IP (the port changes of a client are always followed)")
+	flags.StringSliceVar(&serviceClusterIPRange, "service-cluster-ip-range", nil, "Service cluster IP ranges (v4 and/or v6), so the packets to other destinations skip the rules of the cluster IPs")
+	flags.Var(&proxyPortRange, "proxy-port-range", "Range of host ports (beginPort-endPort, single port or beginPort+offset) the proxies listen on, random ports if empty")
+	flags.StringSliceVar(&reservedPorts, "reserved-ports", nil, "Ports or port ranges (beginPort-endPort) of --proxy-port-range never allocated to the proxies")
+}
+
+var (
+	statusSocket          string
+	listenIP              string
+	quicMigrationCIDRs    []string
+	serviceClusterIPRange []string
+	proxyPortRange        utilnet.PortRange
+	reservedPorts         []string
+)
+
+// Probe checks that the iptables binaries are available, the proxier can't write nft rules.
+func (s *Backend) Probe() error {
+	return backendcmd.Err(s.Checks())
+}
+
+// Checks detects the iptables mode and runs the IPv4 iptables commands of the proxier.
+func (s *Backend) Checks() []backendcmd.Check {
+	switch mode := iptablesutil.DetectMode(privhelper.Exec()); mode {
+	case iptablesutil.ModeMissing, iptablesutil.ModeNFTJSON, iptablesutil.ModeNetlink:
+		return []backendcmd.Check{{Name: "iptables mode", Err: fmt.Errorf("iptables can't be run on this host (detected mode %s)", mode)}}
+
+	default:
+		cmds, err := iptablesutil.CheckCommands(privhelper.Exec(), iptablesutil.ProtocolIPv4, mode)
+		return []backendcmd.Check{
+			{Name: "iptables mode", Detail: string(mode)},
+			{Name: "iptables commands", Detail: strings.Join(cmds, ", "), Err: err},
+		}
+	}
 }
 
 func (s *Backend) Setup() {
@@ -69,27 +139,80 @@ func (s *Backend) Setup() {
 	// hostname = s.NodeName
 	// make a proxier for ipv4
 	klog.V(0).InfoS("Using Userspace Proxier!")
-	execer := exec.New()
-	iptables := iptablesutil.New(execer, iptablesutil.Protocol("IPv4"))
+	iptablesutil.RegisterMetrics()
+	RegisterMetrics()
+
+	if UDP.FlowTracking != FlowTrackingAddress && UDP.FlowTracking != FlowTrackingQUIC {
+		klog.Fatalf("invalid --udp-flow-tracking %q, must be %s or %s", UDP.FlowTracking, FlowTrackingAddress, FlowTrackingQUIC)
+	}
+
+	for _, cidr := range quicMigrationCIDRs {
+		_, ipNet, err := netutils.ParseCIDRSloppy(cidr)
+		if err != nil {
+			klog.Fatalf("invalid QUIC migration network %q: %v", cidr, err)
+		}
+		QUICMigrationCIDRs = append(QUICMigrationCIDRs, ipNet)
+	}
+
+	for _, cidr := range serviceClusterIPRange {
+		_, ipNet, err := netutils.ParseCIDRSloppy(cidr)
+		if err != nil {
+			klog.Fatalf("invalid service cluster IP range %q: %v", cidr, err)
+		}
+		ServiceCIDRs = append(ServiceCIDRs, ipNet)
+	}
+
+	for _, ports := range reservedPorts {
+		r := utilnet.PortRange{}
+		if err := r.Set(ports); err != nil {
+			klog.Fatalf("invalid reserved ports %q: %v", ports, err)
+		}
+		ReservedPorts = append(ReservedPorts, r)
+	}
+
+	ip := netutils.ParseIPSloppy(listenIP)
+	if ip == nil {
+		klog.Fatalf("invalid listen IP %q", listenIP)
+	}
+
+	execer := privhelper.Exec()
+	iptables := auditedIPTables{iptablesutil.New(execer, iptablesutil.Protocol("IPv4"))}
 	proxier, err = NewUserspaceLinux(
 		NewLoadBalancerRR(),
-		netutils.ParseIPSloppy("0.0.0.0"),
+		ip,
 		iptables,
 		execer,
-		utilnet.PortRange{Base: 30000, Size: 2768},
+		proxyPortRange,
 		time.Duration(15),
 		time.Duration(15),
 		time.Second,
 	)
-	if err != nil {
+	if errors.Is(err, ErrProxyOnLocalhost) {
+		log.Fatalf("unable to create proxier: %v (see --allow-localhost-proxy)", err)
+	} else if err != nil {
 		log.Fatal("unable to create proxier: ", err)
 	}
+
+	if statusSocket != "" {
+		if err := proxier.serveStatus(statusSocket); err != nil {
+			klog.Fatal("unable to serve the status: ", err)
+		}
+	}
+
+	drain.OnChange(proxier.setDraining)
+	proxier.setDraining(drain.Draining())
 }
 
 func (s *Backend) Reset() { /* noop, we're wrapped in filterreset */ }
 
+// InitialSync lets the proxier sync once the initial state was received.
+func (s *Backend) InitialSync() {
+	proxier.OnInitialSync()
+}
+
 func (s *Backend) Sync() {
 	proxier.syncProxyRules()
+	slowstart.Default().Synced()
 }
 
 func (s *Backend) SetService(svc *localv1.Service) {
@@ -128,6 +251,11 @@ func (s *Backend) DeleteEndpoint(namespace, serviceName, epKey string) {
 	}
 }
 
+// ResetSessionAffinity sends the clients stuck to a removed endpoint to the others right away.
+func (s *Backend) ResetSessionAffinity(svc *localv1.Service, endpoint *localv1.Endpoint) {
+	proxier.loadBalancer.ResetAffinity(endpoint, svc)
+}
+
 // 1
 // 2 <-- last connection sent here
 // 3
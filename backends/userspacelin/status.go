@@ -0,0 +1,149 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const readHeaderTimeout = 5 * time.Second
+
+// Status is the state of the proxier served by the status socket (see `kpng userspace status`).
+type Status struct {
+	Services []ServiceStatus `json:"services"`
+	Ports    []PortStatus    `json:"ports"`
+}
+
+// ServiceStatus is a service port proxied by the userspace proxy.
+type ServiceStatus struct {
+	Service         string   `json:"service"`
+	Protocol        string   `json:"protocol"`
+	Portal          string   `json:"portal"`
+	ProxyPort       int      `json:"proxyPort"`
+	NodePort        int      `json:"nodePort,omitempty"`
+	ExternalIPs     []string `json:"externalIPs,omitempty"`
+	LoadBalancerIPs []string `json:"loadBalancerIPs,omitempty"`
+	// ActiveConnections are the proxied TCP connections, or the active UDP clients.
+	ActiveConnections int64 `json:"activeConnections"`
+}
+
+// PortStatus is a node port held open by the proxy.
+type PortStatus struct {
+	Port  string `json:"port"`
+	Owner string `json:"owner"`
+}
+
+func (proxier *UserspaceLinux) status() (status Status) {
+	proxier.mu.Lock()
+	for name, info := range proxier.serviceMap {
+		active := atomic.LoadInt64(&info.activeConnsAtomic)
+		if info.ActiveClients != nil {
+			info.ActiveClients.Mu.Lock()
+			active += int64(len(info.ActiveClients.Clients))
+			info.ActiveClients.Mu.Unlock()
+		}
+
+		status.Services = append(status.Services, ServiceStatus{
+			Service:           name.String(),
+			Protocol:          info.protocol.String(),
+			Portal:            net.JoinHostPort(info.portal.ip.String(), strconv.Itoa(info.portal.port)),
+			ProxyPort:         info.proxyPort,
+			NodePort:          info.nodePort,
+			ExternalIPs:       info.externalIPs,
+			LoadBalancerIPs:   info.loadBalancerIPs,
+			ActiveConnections: active,
+		})
+	}
+	proxier.mu.Unlock()
+
+	proxier.portMapMutex.Lock()
+	for key, value := range proxier.portMap {
+		status.Ports = append(status.Ports, PortStatus{Port: key.String(), Owner: value.owner.String()})
+	}
+	proxier.portMapMutex.Unlock()
+
+	sort.Slice(status.Services, func(i, j int) bool {
+		a, b := status.Services[i], status.Services[j]
+		return a.Service < b.Service || a.Service == b.Service && a.Protocol < b.Protocol
+	})
+	sort.Slice(status.Ports, func(i, j int) bool { return status.Ports[i].Port < status.Ports[j].Port })
+
+	return
+}
+
+// serveStatus serves the status of the proxier as JSON on GET /status, and its connections on GET
+// /connections (optionally filtered by ?service=namespace/name), over a unix socket.
+func (proxier *UserspaceLinux) serveStatus(socket string) error {
+	os.Remove(socket)
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(socket, 0600); err != nil {
+		l.Close()
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(proxier.status()); err != nil {
+			klog.Error("userspace status: failed to send response: ", err)
+		}
+	})
+
+	mux.HandleFunc("/connections", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+			return
+		}
+
+		conns := Connections{Connections: proxier.listConnections(r.URL.Query().Get("service"))}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(conns); err != nil {
+			klog.Error("userspace connections: failed to send response: ", err)
+		}
+	})
+
+	klog.Info("serving the userspace proxy status on ", socket)
+
+	go func() {
+		server := &http.Server{Handler: mux, ReadHeaderTimeout: readHeaderTimeout}
+		if err := server.Serve(l); err != nil {
+			klog.Error("userspace status: ", err)
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/common"
+)
+
+func TestStatus(t *testing.T) {
+	proxier, _ := newFakeProxier()
+
+	if status := proxier.status(); len(status.Services) != 0 || len(status.Ports) != 0 {
+		t.Errorf("expected an empty status, got %+v", status)
+	}
+
+	svc := servicePortsOf(
+		&localv1.PortMapping{Name: "http", Protocol: localv1.Protocol_TCP, Port: 80, NodePort: 30080},
+		&localv1.PortMapping{Name: "dns", Protocol: localv1.Protocol_UDP, Port: 53},
+	)
+	update(proxier, nil, svc)
+
+	name := func(port string) common.ServicePortName {
+		return common.ServicePortName{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "svc"}, Port: port}
+	}
+
+	web := proxier.serviceMap[name("http")]
+	atomic.StoreInt64(&web.activeConnsAtomic, 2)
+
+	dns := proxier.serviceMap[name("dns")]
+	dns.ActiveClients.Clients["10.1.0.1:1234"] = nil
+
+	expected := Status{
+		Services: []ServiceStatus{
+			{Service: "ns/svc:dns", Protocol: "UDP", Portal: "10.96.0.10:53", ProxyPort: dns.proxyPort, ActiveConnections: 1},
+			{Service: "ns/svc:http", Protocol: "TCP", Portal: "10.96.0.10:80", ProxyPort: web.proxyPort, NodePort: 30080, ActiveConnections: 2},
+		},
+		Ports: []PortStatus{{Port: "<nil>:30080/TCP", Owner: "ns/svc:http"}},
+	}
+
+	status := proxier.status()
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("expected the status\n%+v\ngot\n%+v", expected, status)
+	}
+
+	// served as JSON
+	socket := filepath.Join(t.TempDir(), "status.sock")
+	if err := proxier.serveStatus(socket); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+
+	resp, err := client.Get("http://status/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	served := Status{}
+	if err := json.NewDecoder(resp.Body).Decode(&served); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(served, expected) {
+		t.Errorf("expected the served status\n%+v\ngot\n%+v", expected, served)
+	}
+
+	resp, err = client.Post("http://status/status", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected POST to be refused, got %s", resp.Status)
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import "github.com/spf13/pflag"
+
+// TCPConfig tunes the TCP proxy sockets.
+type TCPConfig struct {
+	// AcceptWorkers is the number of goroutines accepting the connections of each socket, and
+	// connecting them to the endpoints.
+	AcceptWorkers int
+	// AcceptBatch is the maximum number of pending connections accepted at once by a worker, with
+	// non-blocking accepts until none is left (1 accepts them one by one).
+	AcceptBatch int
+}
+
+// TCP is the default configuration of the TCP proxy sockets.
+var TCP = TCPConfig{
+	AcceptWorkers: 1,
+	AcceptBatch:   1,
+}
+
+func (c *TCPConfig) BindFlags(flags *pflag.FlagSet) {
+	flags.IntVar(&c.AcceptWorkers, "tcp-accept-workers", c.AcceptWorkers, "Number of goroutines accepting the connections of each TCP proxy socket and connecting them to the endpoints")
+	flags.IntVar(&c.AcceptBatch, "tcp-accept-batch", c.AcceptBatch, "Maximum number of pending TCP connections accepted at once by a worker, with non-blocking accepts (1 to accept them one by one)")
+}
+
+// acceptWorkers returns the number of workers of each socket, at least 1.
+func (c *TCPConfig) acceptWorkers() int {
+	if c.AcceptWorkers < 1 {
+		return 1
+	}
+	return c.AcceptWorkers
+}
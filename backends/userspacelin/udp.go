@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"strconv"
+
+	"github.com/spf13/pflag"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+const (
+	// AnnotationUDPMaxDatagramSize overrides UDPConfig.MaxDatagramSize for a service (the
+	// annotation must be included with --with-service-annotations on the server).
+	AnnotationUDPMaxDatagramSize = "kpng.sigs.k8s.io/udp-max-datagram-size"
+	// AnnotationUDPReadBuffer overrides UDPConfig.ReadBuffer for a service.
+	AnnotationUDPReadBuffer = "kpng.sigs.k8s.io/udp-read-buffer"
+	// AnnotationUDPFlowTracking overrides UDPConfig.FlowTracking for a service.
+	AnnotationUDPFlowTracking = "kpng.sigs.k8s.io/udp-flow-tracking"
+
+	// FlowTrackingAddress keys the UDP flows by client address.
+	FlowTrackingAddress = "address"
+	// FlowTrackingQUIC also follows the QUIC connections of the clients across address changes.
+	FlowTrackingQUIC = "quic"
+
+	// maxUDPDatagramSize is the largest UDP payload (over IPv4).
+	maxUDPDatagramSize = 65507
+)
+
+// UDPConfig tunes the UDP proxy sockets.
+type UDPConfig struct {
+	// MaxDatagramSize is the size of the datagrams read by the proxy; larger ones are truncated.
+	MaxDatagramSize int
+	// ReadBuffer is the receive buffer size of the sockets (0 keeps the system default).
+	ReadBuffer int
+	// FlowTracking is how the datagrams are mapped to the backend connections (FlowTrackingAddress
+	// or FlowTrackingQUIC).
+	FlowTracking string
+}
+
+// UDP is the default configuration of the UDP proxy sockets.
+var UDP = UDPConfig{
+	MaxDatagramSize: 4096,
+	FlowTracking:    FlowTrackingAddress,
+}
+
+func (c *UDPConfig) BindFlags(flags *pflag.FlagSet) {
+	flags.IntVar(&c.MaxDatagramSize, "udp-max-datagram-size", c.MaxDatagramSize, "Size of the UDP datagrams read by the proxy, larger ones are truncated (overridden by the "+AnnotationUDPMaxDatagramSize+" service annotation)")
+	flags.StringVar(&c.FlowTracking, "udp-flow-tracking", c.FlowTracking, "How the UDP datagrams are mapped to the endpoints: address (by client address) or quic (also following the QUIC connection IDs when the client address changes), overridden by the "+AnnotationUDPFlowTracking+" service annotation")
+	flags.IntVar(&c.ReadBuffer, "udp-read-buffer", c.ReadBuffer, "Receive buffer size of the UDP proxy sockets, 0 for the system default (overridden by the "+AnnotationUDPReadBuffer+" service annotation)")
+}
+
+// udpConfigOf returns the UDP configuration of a service, with its annotations applied, and the
+// invalid annotations that were ignored.
+func udpConfigOf(service *localv1.Service) (cfg UDPConfig, invalid []string) {
+	cfg = UDP
+
+	parse := func(annotation string, value *int, max int) {
+		s, ok := service.GetAnnotations()[annotation]
+		if !ok {
+			return
+		}
+
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 0 || (max != 0 && v > max) {
+			invalid = append(invalid, annotation)
+			return
+		}
+		*value = v
+	}
+
+	parse(AnnotationUDPMaxDatagramSize, &cfg.MaxDatagramSize, maxUDPDatagramSize)
+	parse(AnnotationUDPReadBuffer, &cfg.ReadBuffer, 0)
+
+	switch tracking := service.GetAnnotations()[AnnotationUDPFlowTracking]; tracking {
+	case "":
+	case FlowTrackingAddress, FlowTrackingQUIC:
+		cfg.FlowTracking = tracking
+	default:
+		invalid = append(invalid, AnnotationUDPFlowTracking)
+	}
+
+	if cfg.MaxDatagramSize <= 0 || cfg.MaxDatagramSize > maxUDPDatagramSize {
+		cfg.MaxDatagramSize = maxUDPDatagramSize
+	}
+
+	return
+}
+
+// udpDatagramSize returns the size of the buffers reading the datagrams of the service.
+func (info *ServiceInfo) udpDatagramSize() int {
+	if info.udp.MaxDatagramSize <= 0 {
+		return UDP.MaxDatagramSize
+	}
+	return info.udp.MaxDatagramSize
+}
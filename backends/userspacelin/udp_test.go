@@ -0,0 +1,109 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/common"
+)
+
+func TestUDPConfigOf(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		annotations map[string]string
+		expected    UDPConfig
+		invalid     []string
+	}{
+		{
+			name:     "defaults",
+			expected: UDP,
+		},
+		{
+			name: "overrides",
+			annotations: map[string]string{
+				AnnotationUDPMaxDatagramSize: "1500",
+				AnnotationUDPReadBuffer:      "1048576",
+				AnnotationUDPFlowTracking:    FlowTrackingQUIC,
+			},
+			expected: UDPConfig{MaxDatagramSize: 1500, ReadBuffer: 1 << 20, FlowTracking: FlowTrackingQUIC},
+		},
+		{
+			name: "invalid",
+			annotations: map[string]string{
+				AnnotationUDPMaxDatagramSize: "65508",
+				AnnotationUDPReadBuffer:      "-1",
+				AnnotationUDPFlowTracking:    "tcp",
+			},
+			expected: UDP,
+			invalid:  []string{AnnotationUDPMaxDatagramSize, AnnotationUDPReadBuffer, AnnotationUDPFlowTracking},
+		},
+		{
+			name:        "not a number",
+			annotations: map[string]string{AnnotationUDPReadBuffer: "1Mi"},
+			expected:    UDP,
+			invalid:     []string{AnnotationUDPReadBuffer},
+		},
+		{
+			name:        "no limit",
+			annotations: map[string]string{AnnotationUDPMaxDatagramSize: "0"},
+			expected:    UDPConfig{MaxDatagramSize: maxUDPDatagramSize, FlowTracking: FlowTrackingAddress},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, invalid := udpConfigOf(&localv1.Service{Namespace: "ns", Name: "svc", Annotations: tc.annotations})
+			if cfg != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, cfg)
+			}
+			if !reflect.DeepEqual(invalid, tc.invalid) {
+				t.Errorf("expected the invalid annotations %q, got %q", tc.invalid, invalid)
+			}
+		})
+	}
+}
+
+func TestUDPConfigChange(t *testing.T) {
+	proxier, _ := newFakeProxier()
+	name := common.ServicePortName{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "svc"}, Port: "dns"}
+
+	v1 := servicePortsOf(&localv1.PortMapping{Name: "dns", Protocol: localv1.Protocol_UDP, Port: 53})
+	v1.Annotations = map[string]string{AnnotationUDPFlowTracking: "tcp"}
+	update(proxier, nil, v1)
+
+	info := proxier.serviceMap[name]
+	if info.udp != UDP {
+		t.Fatalf("expected the default UDP config, got %+v", info.udp)
+	}
+
+	// the invalid annotation doesn't restart the proxy
+	update(proxier, v1, v1)
+	if proxier.serviceMap[name] != info {
+		t.Error("expected the same proxy when the service didn't change")
+	}
+
+	v2 := servicePortsOf(&localv1.PortMapping{Name: "dns", Protocol: localv1.Protocol_UDP, Port: 53})
+	v2.Annotations = map[string]string{AnnotationUDPFlowTracking: FlowTrackingQUIC}
+	update(proxier, v1, v2)
+
+	if info := proxier.serviceMap[name]; info.udp.FlowTracking != FlowTrackingQUIC {
+		t.Errorf("expected the annotation to be applied, got %+v", info.udp)
+	}
+}
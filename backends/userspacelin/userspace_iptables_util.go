@@ -36,6 +36,8 @@ type Interface interface {
 	ChainExists(table iptables.Table, chain iptables.Chain) (bool, error)
 	// EnsureRule checks if the specified rule is present and, if not, creates it.  If the rule existed, return true.
 	EnsureRule(position iptables.RulePosition, table iptables.Table, chain iptables.Chain, args ...string) (bool, error)
+	// EnsureRules appends the rules missing from their chains in one transaction, and returns how many were appended.
+	EnsureRules(rules []iptables.RuleSpec) (int, error)
 	// DeleteRule checks if the specified rule is present and, if so, deletes it.
 	DeleteRule(table iptables.Table, chain iptables.Chain, args ...string) error
 	// IsIPv6 returns true if this is managing ipv6 tables.
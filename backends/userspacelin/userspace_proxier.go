@@ -17,27 +17,32 @@ limitations under the License.
 package userspacelin
 
 import (
+	"errors"
 	"fmt"
 	"net"
-	"reflect"
+
+	"google.golang.org/protobuf/proto"
 
 	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/changetracker"
+	"sigs.k8s.io/kpng/client/localsink/supervise"
+	"sigs.k8s.io/kpng/client/plugins/conntrack"
+	"sigs.k8s.io/kpng/client/rulecomment"
 
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
-	k8snet "k8s.io/apimachinery/pkg/util/net"
-
 	// libcontaineruserns "github.com/opencontainers/runc/libcontainer/userns"
 
 	"k8s.io/apimachinery/pkg/types"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	utilnet "k8s.io/apimachinery/pkg/util/net"
-	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/sets"
+	utilsysctl "k8s.io/component-helpers/node/util/sysctl"
 
 	// utilfeature "k8s.io/apiserver/pkg/util/feature"
 
@@ -45,7 +50,7 @@ import (
 
 	// kubefeatures "k8s.io/kubernetes/pkg/features"
 	// "k8s.io/kubernetes/pkg/proxy/config"
-	"sigs.k8s.io/kpng/backends/iptables"
+	"sigs.k8s.io/kpng/backends/common"
 	iptablesutil "sigs.k8s.io/kpng/backends/iptables/util"
 
 	utilexec "k8s.io/utils/exec"
@@ -64,6 +69,14 @@ type ServiceInfo struct {
 	Timeout time.Duration
 	// ActiveClients is the cache of active UDP clients being proxied by this proxy for this service
 	ActiveClients *ClientCache
+	// activeConnsAtomic is the number of TCP connections being proxied. Only access this with atomic ops.
+	activeConnsAtomic int64
+	// tcpFlows are the TCP connections being proxied
+	tcpFlows *flowSet
+	// udp configures the UDP sockets
+	udp UDPConfig
+	// dscpAtomic is the DSCP of the connections to the endpoints. Only access this with atomic ops.
+	dscpAtomic uint32
 
 	isAliveAtomic           int32 // Only access this with atomic ops
 	portal                  portal
@@ -85,6 +98,16 @@ type ServiceInfo struct {
 	isFinishedAtomic int32
 }
 
+// DSCP returns the DSCP value of the packets sent to the endpoints, 0 if not set.
+func (info *ServiceInfo) DSCP() uint8 {
+	return uint8(atomic.LoadUint32(&info.dscpAtomic))
+}
+
+// setDSCP sets the DSCP value of the next connections to the endpoints.
+func (info *ServiceInfo) setDSCP(dscp uint32) {
+	atomic.StoreUint32(&info.dscpAtomic, dscp&0x3f)
+}
+
 func (info *ServiceInfo) setStarted() {
 	atomic.StoreInt32(&info.isStartedAtomic, 1)
 }
@@ -144,7 +167,8 @@ type UserspaceLinux struct {
 
 	loadBalancer    LoadBalancer
 	mu              sync.Mutex // protects serviceMap
-	serviceMap      map[iptables.ServicePortName]*ServiceInfo
+	serviceMap      map[common.ServicePortName]*ServiceInfo
+	servicePorts    map[servicePortKey]common.ServicePortName // names of the serviceMap ports, protected by mu
 	syncPeriod      time.Duration
 	minSyncPeriod   time.Duration
 	udpIdleTimeout  time.Duration
@@ -153,20 +177,24 @@ type UserspaceLinux struct {
 	listenIP        net.IP
 	iptables        iptablesutil.Interface
 	hostIP          net.IP
+	canRedirect     bool // false when the kernel can't REDIRECT IPv6 traffic; DNAT is used instead
 	localAddrs      netutils.IPSet
 	proxyPorts      PortAllocator
 	makeProxySocket ProxySocketFunc
 	exec            utilexec.Interface
-	// endpointsSynced and servicesSynced are set to 1 when the corresponding
-	// objects are synced after startup. This is used to avoid updating iptables
-	// with some partial data after kube-proxy restart.
-	endpointsSynced int32
-	servicesSynced  int32
-	initialized     int32
-	// protects serviceChanges
-	serviceChangesLock sync.Mutex
-	serviceChanges     map[types.NamespacedName]*UserspaceServiceChangeTracker // map of service changes, this is the entire state-space of all services in k8s.
-	syncRunner         asyncRunnerInterface                                    // governs calls to syncProxyRules
+	// initialized is set to 1 by the initial sync of the stream. This is used to
+	// avoid updating iptables with some partial data after a restart.
+	initialized    int32
+	serviceChanges *changetracker.Tracker[types.NamespacedName, *localv1.Service] // changes of the services since the last sync
+	syncRunner     asyncRunnerInterface                                           // governs calls to syncProxyRules
+
+	// draining is true when the public portals are closed (see drain), protected by mu
+	draining bool
+
+	// rules of the previous run, kept until the first sync (see prewarm)
+	staleRules         []savedRule
+	ensuredRules       map[string]bool
+	previousProxyPorts map[string]int
 
 	stopChan chan struct{}
 }
@@ -183,14 +211,32 @@ func (k *portMapKey) String() string {
 	return fmt.Sprintf("%s/%s", net.JoinHostPort(k.ip, strconv.Itoa(k.port)), k.protocol)
 }
 
+// servicePortKey identifies a port of a service by its number and protocol, so a port renamed in
+// place is found under its previous name.
+type servicePortKey struct {
+	types.NamespacedName
+	port     int
+	protocol localv1.Protocol
+}
+
 // A value for the portMap
 type portMapValue struct {
-	owner  iptables.ServicePortName
+	owner  common.ServicePortName
 	socket interface {
 		Close() error
 	}
 }
 
+// MaxOpenFilesLimit is the limit of open files set by the proxier (0 to keep the current one).
+var MaxOpenFilesLimit uint64 = 64 * 1000
+
+// AllowLocalhostProxy lets the proxier listen on the loopback address (for CI and single-node
+// setups), instead of failing with ErrProxyOnLocalhost.
+var AllowLocalhostProxy bool
+
+// ReservedPorts are the ports of the proxy port range never allocated to the proxies.
+var ReservedPorts []utilnet.PortRange
+
 var (
 	// ErrProxyOnLocalhost is returned by NewProxier if the user requests a proxier on
 	// the loopback address. May be checked for by callers of NewProxier to know whether
@@ -201,8 +247,8 @@ var (
 // NewProxier returns a new Proxier given a LoadBalancer and an address on
 // which to listen.  Because of the iptables logic, It is assumed that there
 // is only a single Proxier active on a machine. An error will be returned if
-// the proxier cannot be started due to an invalid ListenIP (loopback) or
-// if iptables fails to update or acquire the initial lock. Once a proxier is
+// the proxier cannot be started due to an invalid ListenIP (loopback, unless
+// AllowLocalhostProxy is set) or if iptables fails to update or acquire the initial lock. Once a proxier is
 // created, it will keep iptables up to date in the background and will not
 // terminate if a particular iptables call fails.
 
@@ -216,26 +262,17 @@ func NewUserspaceLinux(loadBalancer LoadBalancer, listenIP net.IP, iptables ipta
 // default ProxySockets.
 func NewCustomProxier(loadBalancer LoadBalancer, listenIP net.IP, iptables iptablesutil.Interface, exec utilexec.Interface, pr utilnet.PortRange, syncPeriod, minSyncPeriod, udpIdleTimeout time.Duration, makeProxySocket ProxySocketFunc) (*UserspaceLinux, error) {
 
-	// If listenIP is given, assume that is the intended host IP.  Otherwise
-	// try to find a suitable host IP address from network interfaces.
-	var err error
-	hostIP, err := utilnet.ChooseHostInterface()
-
-	err = setRLimit(64 * 1000)
+	hostIP, err := hostIPFor(listenIP, iptables.IsIPv6(), utilsysctl.New())
 	if err != nil {
+		return nil, err
+	}
 
-		// TODO @jayunit100 enable this once we bump to 1.22
-		/**
-		if utilfeature.DefaultFeatureGate.Enabled(kubefeatures.KubeletInUserNamespace) && libcontaineruserns.RunningInUserNS() {
-		} else {
-			return nil, fmt.Errorf("failed to set open file handler limit to 64000: %w", err)
-		}
-		*/
-		klog.V(2).InfoS("Failed to set open file handler limit to 64000 (running in UserNS, ignoring)", "err", err)
+	if err := setOpenFilesLimit(MaxOpenFilesLimit); err != nil {
+		return nil, err
 	}
 
-	// make a dummy port range, newPortAllocator will make one for us w/ defaults
-	proxyPorts := newPortAllocator(k8snet.PortRange{})
+	// an empty port range makes a random allocator, the kernel choosing the ports
+	proxyPorts := newPortAllocator(pr, ReservedPorts...)
 
 	klog.V(2).InfoS("Setting proxy IP and initializing iptables", "ip", hostIP)
 
@@ -243,6 +280,77 @@ func NewCustomProxier(loadBalancer LoadBalancer, listenIP net.IP, iptables iptab
 	return createProxier(loadBalancer, hostIP, iptables, exec, hostIP, proxyPorts, syncPeriod, minSyncPeriod, udpIdleTimeout, makeProxySocket)
 }
 
+// the open files limit calls (replaced in tests)
+var (
+	setOpenFilesRLimit = setRLimit
+	getOpenFilesRLimit = getRLimit
+	inUserNS           = runningInUserNS
+)
+
+// setOpenFilesLimit sets the limit of open files of the proxy (unless it's 0), and records the
+// effective one. Failing to raise it is ignored in a user namespace, where it's not allowed.
+func setOpenFilesLimit(limit uint64) error {
+	if limit != 0 {
+		if err := setOpenFilesRLimit(limit); err != nil {
+			if !inUserNS() {
+				return fmt.Errorf("failed to set open file handler limit to %d: %w", limit, err)
+			}
+			klog.V(2).InfoS("Failed to set open file handler limit (running in UserNS, ignoring)", "limit", limit, "err", err)
+		}
+	}
+	if current, err := getOpenFilesRLimit(); err == nil {
+		MaxOpenFiles.Set(float64(current))
+	}
+	return nil
+}
+
+// sysctlRouteLocalnet is the sysctl letting the packets from the containers be routed to 127.0.0.0/8.
+const sysctlRouteLocalnet = "net/ipv4/conf/all/route_localnet"
+
+// hostIPFor returns the IP of the portals for listenIP: listenIP itself if given, otherwise an IP
+// of the host's interfaces. A loopback listenIP needs AllowLocalhostProxy.
+func hostIPFor(listenIP net.IP, ipv6 bool, sysctl utilsysctl.Interface) (hostIP net.IP, err error) {
+	if listenIP.IsLoopback() {
+		if !AllowLocalhostProxy {
+			return nil, ErrProxyOnLocalhost
+		}
+		if err := allowLocalhostProxy(sysctl, ipv6); err != nil {
+			return nil, err
+		}
+	}
+
+	hostIP = listenIP
+	if hostIP == nil || hostIP.IsUnspecified() {
+		if ipv6 {
+			hostIP, err = utilnet.ResolveBindAddress(net.IPv6unspecified)
+		} else {
+			hostIP, err = utilnet.ChooseHostInterface()
+		}
+		if err != nil {
+			klog.ErrorS(err, "Failed to select a host IP")
+		}
+	}
+	return hostIP, nil
+}
+
+// allowLocalhostProxy makes the portals DNAT-ed to the loopback address routable: the packets
+// from the containers are only accepted with route_localnet, which doesn't exist in IPv6.
+func allowLocalhostProxy(sysctl utilsysctl.Interface, ipv6 bool) error {
+	if ipv6 {
+		klog.Warning("Proxying on localhost in IPv6: only the traffic from the host will reach the services")
+		return nil
+	}
+
+	klog.Warning("Proxying on localhost: enabling route_localnet, the node must drop the martian packets to 127.0.0.0/8 it receives")
+	if val, _ := sysctl.GetSysctl(sysctlRouteLocalnet); val == 1 {
+		return nil
+	}
+	if err := sysctl.SetSysctl(sysctlRouteLocalnet, 1); err != nil {
+		return fmt.Errorf("failed to enable route_localnet: %w", err)
+	}
+	return nil
+}
+
 // createProxier makes a userspace proxier.  It does some iptables actions but it doesn't actually run iptables AS the proxy.
 func createProxier(loadBalancer LoadBalancer, listenIP net.IP, iptablesInterfaceImpl iptablesutil.Interface, exec utilexec.Interface, hostIP net.IP, proxyPorts PortAllocator, syncPeriod, minSyncPeriod, udpIdleTimeout time.Duration, makeProxySocket ProxySocketFunc) (*UserspaceLinux, error) {
 	// Hack: since the userspace proxy is old, we don't expect people to need to replace this loadbalancer. so we hardcode it to round_robin.go.
@@ -255,15 +363,15 @@ func createProxier(loadBalancer LoadBalancer, listenIP net.IP, iptablesInterface
 	if err := iptablesInit(iptablesInterfaceImpl); err != nil {
 		return nil, fmt.Errorf("failed to initialize iptables: %v", err)
 	}
-	// Flush old iptables rules (since the bound ports will be invalid after a restart).
-	// When OnUpdate() is first called, the rules will be recreated.
-	if err := iptablesFlush(iptablesInterfaceImpl); err != nil {
-		return nil, fmt.Errorf("failed to flush iptables: %v", err)
+	canRedirect := true
+	if iptablesInterfaceImpl.IsIPv6() {
+		canRedirect = ipv6RedirectSupported(iptablesInterfaceImpl)
 	}
 	proxier := &UserspaceLinux{
 		loadBalancer:    loadBalancer, // <----
-		serviceMap:      make(map[iptables.ServicePortName]*ServiceInfo),
-		serviceChanges:  make(map[types.NamespacedName]*UserspaceServiceChangeTracker),
+		serviceMap:      make(map[common.ServicePortName]*ServiceInfo),
+		servicePorts:    make(map[servicePortKey]common.ServicePortName),
+		serviceChanges:  newServiceChangeTracker(),
 		portMap:         make(map[portMapKey]*portMapValue),
 		syncPeriod:      syncPeriod,
 		minSyncPeriod:   minSyncPeriod,
@@ -271,6 +379,7 @@ func createProxier(loadBalancer LoadBalancer, listenIP net.IP, iptablesInterface
 		listenIP:        listenIP,
 		iptables:        iptablesInterfaceImpl,
 		hostIP:          hostIP,
+		canRedirect:     canRedirect,
 		proxyPorts:      proxyPorts,
 		makeProxySocket: makeProxySocket,
 		exec:            exec,
@@ -278,6 +387,15 @@ func createProxier(loadBalancer LoadBalancer, listenIP net.IP, iptablesInterface
 	}
 	klog.V(3).InfoS("Record sync param", "minSyncPeriod", minSyncPeriod, "syncPeriod", syncPeriod, "burstSyncs", numBurstSyncs)
 	proxier.syncRunner = newBoundedFrequencyRunner("userspace-proxy-sync-runner", proxier.syncProxyRules, minSyncPeriod, syncPeriod, numBurstSyncs)
+
+	// Keep the rules of the previous run until the first sync, when they are replaced. If they
+	// can't be read, flush them (since the bound ports will be invalid after a restart).
+	if err := proxier.prewarm(); err != nil {
+		klog.ErrorS(err, "Failed to read the rules of the previous run, flushing them")
+		if err := iptablesFlush(iptablesInterfaceImpl); err != nil {
+			return nil, fmt.Errorf("failed to flush iptables: %v", err)
+		}
+	}
 	return proxier, nil
 }
 
@@ -288,6 +406,20 @@ func CleanupLeftovers(ipt iptablesutil.Interface) (encounteredError bool) {
 	// we want to ensure we remove all of the iptables rules it creates.
 	// Currently they are all in iptablesInit()
 	// Delete Rules first, then Flush and Delete Chains
+	for _, cidr := range serviceCIDRs(ipt.IsIPv6()) {
+		if err := ipt.DeleteRule(iptablesutil.TableNAT, iptablesutil.ChainOutput, clusterIPArgs(cidr, iptablesHostClusterIPChain)...); err != nil {
+			if !iptablesutil.IsNotFoundError(err) {
+				klog.ErrorS(err, "Error removing userspace rule")
+				encounteredError = true
+			}
+		}
+		if err := ipt.DeleteRule(iptablesutil.TableNAT, iptablesutil.ChainPrerouting, clusterIPArgs(cidr, iptablesContainerClusterIPChain)...); err != nil {
+			if !iptablesutil.IsNotFoundError(err) {
+				klog.ErrorS(err, "Error removing userspace rule")
+				encounteredError = true
+			}
+		}
+	}
 	args := []string{"-m", "comment", "--comment", "handle ClusterIPs; NOTE: this must be before the NodePort rules"}
 	if err := ipt.DeleteRule(iptablesutil.TableNAT, iptablesutil.ChainOutput, append(args, "-j", string(iptablesHostPortalChain))...); err != nil {
 		if !iptablesutil.IsNotFoundError(err) {
@@ -325,7 +457,7 @@ func CleanupLeftovers(ipt iptablesutil.Interface) (encounteredError bool) {
 
 	// flush and delete chains.
 	tableChains := map[iptablesutil.Table][]iptablesutil.Chain{
-		iptablesutil.TableNAT:    {iptablesContainerPortalChain, iptablesHostPortalChain, iptablesHostNodePortChain, iptablesContainerNodePortChain},
+		iptablesutil.TableNAT:    {iptablesContainerClusterIPChain, iptablesHostClusterIPChain, iptablesContainerPortalChain, iptablesHostPortalChain, iptablesHostNodePortChain, iptablesContainerNodePortChain},
 		iptablesutil.TableFilter: {iptablesNonLocalNodePortChain},
 	}
 	for table, chains := range tableChains {
@@ -380,7 +512,7 @@ func (proxier *UserspaceLinux) syncProxyRules() {
 
 	// don't sync rules till we've received services and endpoints
 	if !proxier.isInitialized() {
-		klog.V(2).InfoS("Not syncing userspace proxy until Services and Endpoints have been received from master")
+		klog.V(2).InfoS("Not syncing userspace proxy until the initial services and endpoints have been received")
 		return
 	}
 
@@ -388,28 +520,24 @@ func (proxier *UserspaceLinux) syncProxyRules() {
 		klog.ErrorS(err, "Failed to ensure iptables")
 	}
 
-	// ... we can remove these locks bc kpng runs synchronous streams to update things ...
-	proxier.serviceChangesLock.Lock()
-	oldChanges := proxier.serviceChanges
-
-	// make the "current" service changes a new map and rebuild it...
-	proxier.serviceChanges = make(map[types.NamespacedName]*UserspaceServiceChangeTracker)
-	proxier.serviceChangesLock.Unlock()
+	changes := proxier.serviceChanges.Take()
 
 	proxier.mu.Lock()
 	defer proxier.mu.Unlock()
 
-	klog.V(4).InfoS("userspace proxy: processing service events", "count", len(oldChanges))
-	for _, oldChange := range oldChanges {
-		for _, svcChange := range oldChange.items {
-			existingPorts := proxier.mergeService(svcChange.current)
-			proxier.unmergeService(svcChange.previous, existingPorts)
-		}
+	klog.V(4).InfoS("userspace proxy: processing service events", "count", len(changes))
+	for _, change := range changes {
+		existingPorts := proxier.mergeService(change.Current)
+		proxier.unmergeService(change.Previous, existingPorts)
+		proxier.clearProtocolChanges(change.Previous, change.Current)
 	}
 
 	proxier.localAddrs = GetLocalAddrSet()
 
 	proxier.ensurePortals()
+	if proxier.ensuredRules != nil {
+		proxier.removeStaleRules()
+	}
 	proxier.cleanupStaleStickySessions()
 }
 
@@ -429,6 +557,31 @@ func (proxier *UserspaceLinux) ensurePortals() {
 	}
 }
 
+// setDraining closes the public portals (external IPs, load balancer IPs and node ports) while the
+// node is draining: new connections to them are refused, while the established ones, already
+// redirected, go on.
+func (proxier *UserspaceLinux) setDraining(draining bool) {
+	proxier.mu.Lock()
+	defer proxier.mu.Unlock()
+
+	if draining == proxier.draining {
+		return
+	}
+	proxier.draining = draining
+
+	for name, info := range proxier.serviceMap {
+		var err error
+		if draining {
+			err = utilerrors.NewAggregate(proxier.closePublicPortals(name, info))
+		} else {
+			err = proxier.openPublicPortals(name, info)
+		}
+		if err != nil {
+			klog.ErrorS(err, "Failed to update the public portals", "servicePortName", name, "draining", draining)
+		}
+	}
+}
+
 // clean up any stale sticky session records in the hash map.
 func (proxier *UserspaceLinux) cleanupStaleStickySessions() {
 	for name := range proxier.serviceMap {
@@ -436,8 +589,12 @@ func (proxier *UserspaceLinux) cleanupStaleStickySessions() {
 	}
 }
 
-func (proxier *UserspaceLinux) stopProxy(service iptables.ServicePortName, info *ServiceInfo) error {
+func (proxier *UserspaceLinux) stopProxy(service common.ServicePortName, info *ServiceInfo) error {
 	delete(proxier.serviceMap, service)
+	key := servicePortKey{service.NamespacedName, info.portal.port, info.protocol}
+	if proxier.servicePorts[key] == service {
+		delete(proxier.servicePorts, key)
+	}
 	info.setAlive(false)
 	err := info.socket.Close()
 	port := info.socket.ListenPort()
@@ -445,7 +602,7 @@ func (proxier *UserspaceLinux) stopProxy(service iptables.ServicePortName, info
 	return err
 }
 
-func (proxier *UserspaceLinux) getServiceInfo(service iptables.ServicePortName) (*ServiceInfo, bool) {
+func (proxier *UserspaceLinux) getServiceInfo(service common.ServicePortName) (*ServiceInfo, bool) {
 	proxier.mu.Lock()
 	defer proxier.mu.Unlock()
 	info, ok := proxier.serviceMap[service]
@@ -453,13 +610,18 @@ func (proxier *UserspaceLinux) getServiceInfo(service iptables.ServicePortName)
 }
 
 // addServiceOnPortInternal starts listening for a new service, returning the ServiceInfo.
-// Pass proxyPort=0 to allocate a random port. The timeout only applies to UDP
+// Pass proxyPort=0 to allocate a random port. The timeout and udp only apply to UDP
 // connections, for now.
-func (proxier *UserspaceLinux) addServiceOnPortInternal(service iptables.ServicePortName, protocol localv1.Protocol, proxyPort int, timeout time.Duration) (*ServiceInfo, error) {
+func (proxier *UserspaceLinux) addServiceOnPortInternal(service common.ServicePortName, protocol localv1.Protocol, proxyPort int, timeout time.Duration, udp UDPConfig) (*ServiceInfo, error) {
 	sock, err := proxier.makeProxySocket(protocol, proxier.listenIP, proxyPort)
 	if err != nil {
 		return nil, err
 	}
+	if udpSock, ok := sock.(*udpProxySocket); ok && udp.ReadBuffer != 0 {
+		if err := udpSock.SetReadBuffer(udp.ReadBuffer); err != nil {
+			klog.ErrorS(err, "Failed to set the UDP read buffer", "service", service, "size", udp.ReadBuffer)
+		}
+	}
 	_, portStr, err := net.SplitHostPort(sock.Addr().String())
 	if err != nil {
 		sock.Close()
@@ -472,25 +634,96 @@ func (proxier *UserspaceLinux) addServiceOnPortInternal(service iptables.Service
 	}
 	si := &ServiceInfo{
 		Timeout:                 timeout,
-		ActiveClients:           newClientCache(),
+		ActiveClients:           newClientCache(udp.FlowTracking),
+		tcpFlows:                &flowSet{flows: map[*flow]struct{}{}},
 		isAliveAtomic:           1,
 		proxyPort:               portNum,
 		protocol:                protocol,
 		socket:                  sock,
 		sessionClientIPAffinity: nil, // default
+		udp:                     udp,
 	}
 	proxier.serviceMap[service] = si
 
 	klog.V(2).InfoS("Proxying for service", "service", service, "protocol", protocol, "portNum", portNum)
-	go func() {
-		defer runtime.HandleCrash()
+	go supervise.Run("proxy loop of "+service.String(), func() {
 		sock.ProxyLoop(service, si, proxier.loadBalancer)
-	}()
+	})
 
 	return si, nil
 }
 
-func (proxier *UserspaceLinux) cleanupPortalAndProxy(serviceName iptables.ServicePortName, info *ServiceInfo) error {
+// handOverService starts a new proxy for a reconfigured service on the proxy port of the
+// previous one, which keeps accepting connections until the portals of the new configuration are
+// opened (see retireService). It returns the previous proxy, or nil if the port can't be handed
+// over (the service must then be stopped before being added again).
+func (proxier *UserspaceLinux) handOverService(service common.ServicePortName, previous *ServiceInfo, protocol localv1.Protocol, udp UDPConfig) *ServiceInfo {
+	if !canReusePort || previous.protocol != protocol {
+		return nil
+	}
+
+	if _, err := proxier.addServiceOnPortInternal(service, protocol, previous.proxyPort, proxier.udpIdleTimeout, udp); err != nil {
+		klog.V(2).InfoS("Failed to hand the proxy port over, restarting the proxy", "serviceName", service, "proxyPort", previous.proxyPort, "err", err)
+		return nil
+	}
+
+	klog.V(2).InfoS("Handing the proxy port over to the new configuration", "serviceName", service, "proxyPort", previous.proxyPort)
+	return previous
+}
+
+// retireService closes the portals of the previous proxy of a service that aren't opened by the
+// current one, then its socket, once the connections queued in its backlog are accepted. The proxy
+// port, now used by the current proxy, is kept.
+func (proxier *UserspaceLinux) retireService(service common.ServicePortName, previous, current *ServiceInfo) {
+	el := []error{}
+
+	samePort := previous.portal.port == current.portal.port
+	if !samePort || !previous.portal.ip.Equal(current.portal.ip) {
+		el = append(el, proxier.closeOnePortal(previous.portal, previous.protocol, proxier.listenIP, previous.proxyPort, service)...)
+	}
+
+	if !proxier.draining {
+		stale := *previous
+		stale.externalIPs = staleIPs(previous.externalIPs, current.externalIPs, samePort)
+		stale.loadBalancerIPs = staleIPs(previous.loadBalancerIPs, current.loadBalancerIPs, samePort)
+		if previous.nodePort == current.nodePort {
+			stale.nodePort = 0
+		}
+		el = append(el, proxier.closePublicPortals(service, &stale)...)
+	}
+
+	if err := utilerrors.NewAggregate(el); err != nil {
+		klog.ErrorS(err, "Some errors closing the previous iptables portals for service", "servicePortName", service)
+	}
+
+	previous.setAlive(false)
+	if tcp, ok := previous.socket.(*tcpProxySocket); ok {
+		if n := tcp.drainBacklog(service, previous, proxier.loadBalancer); n != 0 {
+			klog.V(2).InfoS("Drained the backlog of the previous proxy socket", "servicePortName", service, "connections", n)
+		}
+	}
+	if err := previous.socket.Close(); err != nil {
+		klog.ErrorS(err, "Failed to close the previous proxy socket", "servicePortName", service)
+	}
+	previous.setFinished()
+}
+
+// staleIPs returns the IPs of previous not in current (all of them if the port changed).
+func staleIPs(previous, current []string, samePort bool) (stale []string) {
+	if !samePort {
+		return previous
+	}
+
+	kept := sets.NewString(current...)
+	for _, ip := range previous {
+		if !kept.Has(ip) {
+			stale = append(stale, ip)
+		}
+	}
+	return
+}
+
+func (proxier *UserspaceLinux) cleanupPortalAndProxy(serviceName common.ServicePortName, info *ServiceInfo) error {
 	if err := proxier.closePortal(serviceName, info); err != nil {
 		return fmt.Errorf("Failed to close portal for %q: %v", serviceName, err)
 	}
@@ -507,45 +740,80 @@ func (proxier *UserspaceLinux) mergeService(service *localv1.Service) sets.Strin
 	if ShouldSkipService(service) {
 		return nil
 	}
+	if len(service.IPs.ClusterIPs.V4) == 0 {
+		klog.V(3).InfoS("Skipping service without an IPv4 cluster IP", "serviceName", service.NamespacedName())
+		return nil
+	}
 	existingPorts := sets.NewString()
 	svcName := types.NamespacedName{Namespace: service.Namespace, Name: service.Name}
 	for i := range service.Ports {
 		//TODO print Ports
 		servicePort := &service.Ports[i]
 		//TODO print servicePort
-		serviceName := iptables.ServicePortName{NamespacedName: svcName, Port: (*servicePort).Name}
+		serviceName := common.ServicePortName{NamespacedName: svcName, Port: (*servicePort).Name}
 		existingPorts.Insert((*servicePort).Name)
+		if renamed, ok := proxier.stopRenamedPort(service, serviceName, *servicePort); ok {
+			// already stopped, not to be unmerged
+			existingPorts.Insert(renamed.Port)
+		}
 		info, exists := proxier.serviceMap[serviceName]
 		// TODO: check health of the socket? What if ProxyLoop exited?
 		if exists && sameConfig(info, service, *servicePort) {
-			// Nothing changed.
+			// Nothing changed, but the DSCP of the next connections.
+			info.setDSCP(service.DSCP)
 			continue
 		}
-		if exists {
-			klog.V(4).InfoS("Something changed for service: stopping it", "serviceName", serviceName)
-			if err := proxier.cleanupPortalAndProxy(serviceName, info); err != nil {
-				klog.ErrorS(err, "Failed to cleanup portal and proxy")
-			}
-			info.setFinished()
+		serviceIP := net.ParseIP(service.IPs.ClusterIPs.V4[0])
+		udp, invalid := udpConfigOf(service)
+		for _, annotation := range invalid {
+			klog.V(1).InfoS("Ignoring invalid annotation", "service", svcName, "annotation", annotation, "value", service.Annotations[annotation])
 		}
-		proxyPort, err := proxier.proxyPorts.AllocateNext()
-		if err != nil {
-			klog.ErrorS(err, "Failed to allocate proxy port", "serviceName", serviceName)
-			continue
+
+		// previous is the proxy of the service handed over to the new one (see handOverService)
+		var previous *ServiceInfo
+		if exists {
+			previous = proxier.handOverService(serviceName, info, (*servicePort).Protocol, udp)
 		}
 
-		serviceIP := net.ParseIP(service.IPs.ClusterIPs.V4[0])
-		klog.V(0).InfoS("Adding new service", "serviceName", serviceName, "addr", net.JoinHostPort(serviceIP.String(), strconv.Itoa(int((*servicePort).Port))), "protocol", (*servicePort).Protocol)
-		info, err = proxier.addServiceOnPortInternal(serviceName, (*servicePort).Protocol, proxyPort, proxier.udpIdleTimeout)
-		if err != nil {
-			klog.ErrorS(err, "Failed to start proxy", "serviceName", serviceName)
-			continue
+		if previous != nil {
+			info = proxier.serviceMap[serviceName]
+		} else {
+			if exists {
+				klog.V(4).InfoS("Something changed for service: stopping it", "serviceName", serviceName)
+				if err := proxier.cleanupPortalAndProxy(serviceName, info); err != nil {
+					klog.ErrorS(err, "Failed to cleanup portal and proxy")
+				}
+				info.setFinished()
+			}
+			proxyPort, err := proxier.proxyPorts.AllocateFor(serviceName.String())
+			if err != nil {
+				klog.ErrorS(err, "Failed to allocate proxy port", "serviceName", serviceName)
+				continue
+			}
+
+			klog.V(0).InfoS("Adding new service", "serviceName", serviceName, "addr", net.JoinHostPort(serviceIP.String(), strconv.Itoa(int((*servicePort).Port))), "protocol", (*servicePort).Protocol)
+			info = nil
+			if proxyPort == 0 {
+				info = proxier.addServiceOnPreviousPort(serviceName, (*servicePort).Protocol, udp)
+			}
+			if info == nil {
+				info, err = proxier.addServiceOnPortInternal(serviceName, (*servicePort).Protocol, proxyPort, proxier.udpIdleTimeout, udp)
+				if err != nil {
+					klog.ErrorS(err, "Failed to start proxy", "serviceName", serviceName)
+					continue
+				}
+			}
 		}
 		info.portal.ip = serviceIP
 		info.portal.port = int((*servicePort).Port)
+		proxier.servicePorts[servicePortKey{svcName, info.portal.port, info.protocol}] = serviceName
 		info.externalIPs = service.GetIPs().ExternalIPs.GetV4()
-		info.loadBalancerIPs = service.GetIPs().LoadBalancerIPs.GetV4()
-		info.nodePort = int((*servicePort).GetNodePort())
+		info.loadBalancerIPs = service.GetIPs().LoadBalancerVIPs().GetV4()
+		info.nodePort = 0
+		if service.HasNodePort(*servicePort) {
+			info.nodePort = int((*servicePort).NodePort)
+		}
+		info.setDSCP(service.DSCP)
 		// info.affinityClientIP = service.GetClientIP()
 		// Deep-copy in case the service instance changes
 		/**
@@ -569,6 +837,9 @@ func (proxier *UserspaceLinux) mergeService(service *localv1.Service) sets.Strin
 		if err := proxier.openPortal(serviceName, info); err != nil {
 			klog.ErrorS(err, "Failed to open portal", "serviceName", serviceName)
 		}
+		if previous != nil {
+			proxier.retireService(serviceName, previous, info)
+		}
 		proxier.loadBalancer.NewService(serviceName, service.GetClientIP(), info.stickyMaxAgeSeconds)
 
 		info.setStarted()
@@ -577,6 +848,54 @@ func (proxier *UserspaceLinux) mergeService(service *localv1.Service) sets.Strin
 	return existingPorts
 }
 
+// clearConntrack removes the conntrack entries of an IP port (replaced in tests).
+var clearConntrack = conntrack.ClearIPPort
+
+// clearProtocolChanges removes the conntrack entries of the IPv4 ports served by the service with
+// another protocol, once the proxies and rules of their previous protocol are stopped.
+func (proxier *UserspaceLinux) clearProtocolChanges(previous, current *localv1.Service) {
+	for _, ipp := range conntrack.ProtocolChanges(previous, current) {
+		if netutils.IsIPv6String(ipp.DnatIP) {
+			continue
+		}
+		klog.V(1).InfoS("Clearing the conntrack entries of the previous protocol", "service", current.NamespacedName(), "ip", ipp.DnatIP, "port", ipp.Port, "protocol", ipp.Protocol)
+		clearConntrack(ipp)
+	}
+}
+
+// stopRenamedPort stops the proxy of a port renamed to serviceName, found by its number and protocol
+// under a name the service doesn't have anymore, before the port is added again under its new name:
+// its portals, claims and rules are named after the previous one, and its port can't be handed over.
+func (proxier *UserspaceLinux) stopRenamedPort(service *localv1.Service, serviceName common.ServicePortName, servicePort *localv1.PortMapping) (renamed common.ServicePortName, ok bool) {
+	if _, exists := proxier.serviceMap[serviceName]; exists {
+		return
+	}
+
+	renamed, ok = proxier.servicePorts[servicePortKey{serviceName.NamespacedName, int(servicePort.Port), servicePort.Protocol}]
+	if !ok {
+		return
+	}
+	for _, port := range service.Ports {
+		if port.Name == renamed.Port {
+			// the name is still used, by another port
+			return renamed, false
+		}
+	}
+
+	info, exists := proxier.serviceMap[renamed]
+	if !exists {
+		return renamed, false
+	}
+
+	klog.V(1).InfoS("Service port renamed, stopping it under its previous name", "serviceName", serviceName, "previousName", renamed)
+	if err := proxier.cleanupPortalAndProxy(renamed, info); err != nil {
+		klog.ErrorS(err, "Failed to cleanup portal and proxy")
+	}
+	proxier.loadBalancer.DeleteService(renamed)
+	info.setFinished()
+	return renamed, true
+}
+
 func (proxier *UserspaceLinux) unmergeService(service *localv1.Service, existingPorts sets.String) {
 	if service == nil {
 		return
@@ -592,7 +911,7 @@ func (proxier *UserspaceLinux) unmergeService(service *localv1.Service, existing
 		if existingPorts.Has((*servicePort).Name) {
 			continue
 		}
-		serviceName := iptables.ServicePortName{NamespacedName: svcName, Port: (*servicePort).Name}
+		serviceName := common.ServicePortName{NamespacedName: svcName, Port: (*servicePort).Name}
 
 		klog.V(1).InfoS("Stopping service", "serviceName", serviceName)
 		info, exists := proxier.serviceMap[serviceName]
@@ -618,6 +937,17 @@ func (proxier *UserspaceLinux) unmergeService(service *localv1.Service, existing
 	// }
 }
 
+// newServiceChangeTracker returns a tracker dropping the changes that bring a service back to its
+// previous state.
+func newServiceChangeTracker() *changetracker.Tracker[types.NamespacedName, *localv1.Service] {
+	tracker := changetracker.New[types.NamespacedName](func(a, b *localv1.Service) bool {
+		return proto.Equal(a, b)
+	})
+	tracker.Pending = ServiceChangesPending
+	tracker.Total = ServiceChangesTotal
+	return tracker
+}
+
 func (proxier *UserspaceLinux) serviceChange(previous, current *localv1.Service, detail string) {
 	var svcName types.NamespacedName
 	if current != nil {
@@ -627,26 +957,10 @@ func (proxier *UserspaceLinux) serviceChange(previous, current *localv1.Service,
 	}
 	klog.V(0).InfoS("Record service change", "action", detail, "svcName", svcName)
 
-	proxier.serviceChangesLock.Lock()
-	defer proxier.serviceChangesLock.Unlock()
-
-	change, exists := proxier.serviceChanges[svcName]
-	if !exists {
-		// change.previous is only set for new changes. We must keep
-		// the oldest service info (or nil) because correct unmerging
-		// depends on the next update/del after a merge, not subsequent
-		// updates.
-		change = &UserspaceServiceChangeTracker{items: map[types.NamespacedName]*userspaceServiceChange{svcName: &userspaceServiceChange{previous: previous}}}
-		proxier.serviceChanges[svcName] = change
-	}
-
-	// Always use the most current service (or nil) as change.current
-	change.items[svcName].current = current
-
-	if reflect.DeepEqual(change.items[svcName].previous, change.items[svcName].current) {
-		// collapsed change had no effect
-		delete(proxier.serviceChanges, svcName)
-	} else if proxier.isInitialized() {
+	// the tracker keeps the oldest service info (or nil) because correct
+	// unmerging depends on the next update/del after a merge, not
+	// subsequent updates.
+	if proxier.serviceChanges.Record(svcName, previous, current) && proxier.isInitialized() {
 		// change will have an effect, ask the proxy to sync
 		proxier.syncRunner.Run()
 	}
@@ -655,10 +969,6 @@ func (proxier *UserspaceLinux) serviceChange(previous, current *localv1.Service,
 // OnServiceAdd is called whenever creation of new service object
 // is observed.
 func (proxier *UserspaceLinux) OnServiceAdd(service *localv1.Service) {
-	atomic.StoreInt32(&proxier.servicesSynced, 1)
-	if atomic.LoadInt32(&proxier.endpointsSynced) > 0 {
-		atomic.StoreInt32(&proxier.initialized, 1)
-	}
 	proxier.serviceChange(nil, service, "OnServiceAdd")
 	//_ = proxier.mergeService(service)
 }
@@ -678,32 +988,9 @@ func (proxier *UserspaceLinux) OnServiceDelete(service *localv1.Service) {
 	//proxier.unmergeService(service, sets.NewString())
 }
 
-// OnServiceSynced is called once all the initial event handlers were
-// called and the state is fully propagated to local cache.
-func (proxier *UserspaceLinux) OnServiceSynced() {
-	klog.V(2).InfoS("Userspace OnServiceSynced")
-
-	// Mark services as initialized and (if endpoints are already
-	// initialized) the entire proxy as initialized
-	atomic.StoreInt32(&proxier.servicesSynced, 1)
-	if atomic.LoadInt32(&proxier.endpointsSynced) > 0 {
-		atomic.StoreInt32(&proxier.initialized, 1)
-	}
-
-	// Must sync from a goroutine to avoid blocking the
-	// service event handler on startup with large numbers
-	// of initial objects
-	go proxier.syncProxyRules()
-}
-
 // OnEndpointsAdd is called whenever creation of new endpoints object
 // is observed.
 func (proxier *UserspaceLinux) OnEndpointsAdd(ep *localv1.Endpoint, svc *localv1.Service) {
-	atomic.StoreInt32(&proxier.endpointsSynced, 1)
-	if atomic.LoadInt32(&proxier.servicesSynced) > 0 {
-		atomic.StoreInt32(&proxier.initialized, 1)
-	}
-
 	proxier.loadBalancer.OnEndpointsAdd(ep, svc)
 }
 
@@ -719,23 +1006,13 @@ func (proxier *UserspaceLinux) OnEndpointsDelete(ep *localv1.Endpoint, svc *loca
 	proxier.loadBalancer.OnEndpointsDelete(ep, svc)
 }
 
-// OnEndpointsSynced is called once all the initial event handlers were
-// called and the state is fully propagated to local cache.
-func (proxier *UserspaceLinux) OnEndpointsSynced() {
-	klog.V(2).InfoS("Userspace OnEndpointsSynced")
+// OnInitialSync is called once the initial services and endpoints were
+// received, before their first sync.
+func (proxier *UserspaceLinux) OnInitialSync() {
+	klog.V(2).InfoS("Userspace OnInitialSync")
 	proxier.loadBalancer.OnEndpointsSynced()
 
-	// Mark endpoints as initialized and (if services are already
-	// initialized) the entire proxy as initialized
-	atomic.StoreInt32(&proxier.endpointsSynced, 1)
-	if atomic.LoadInt32(&proxier.servicesSynced) > 0 {
-		atomic.StoreInt32(&proxier.initialized, 1)
-	}
-
-	// Must sync from a goroutine to avoid blocking the
-	// service event handler on startup with large numbers
-	// of initial objects
-	go proxier.syncProxyRules()
+	atomic.StoreInt32(&proxier.initialized, 1)
 }
 
 // TODO do we need portmapping?
@@ -751,6 +1028,12 @@ func sameConfig(info *ServiceInfo, service *localv1.Service, port *localv1.PortM
 	if !ipsEqual(info.externalIPs, service.IPs.ExternalIPs.V4) {
 		return false
 	}
+	if pr == localv1.Protocol_UDP {
+		// the invalid annotations are logged when the service is (re)started, not on every sync
+		if udp, _ := udpConfigOf(service); info.udp != udp {
+			return false
+		}
+	}
 
 	// TODO. build this loadBalancerStatus up properly.
 	// loadBalancerStatus := v1.LoadBalancerStatus{}
@@ -777,93 +1060,100 @@ func ipsEqual(lhs, rhs []string) bool {
 	return true
 }
 
-func (proxier *UserspaceLinux) openPortal(service iptables.ServicePortName, info *ServiceInfo) error {
-	err := proxier.openOnePortal(info.portal, info.protocol, proxier.listenIP, info.proxyPort, service)
+func (proxier *UserspaceLinux) openPortal(service common.ServicePortName, info *ServiceInfo) error {
+	rules, err := proxier.portalRules(info.portal, info.protocol, proxier.listenIP, info.proxyPort, service)
 	if err != nil {
 		return err
 	}
-	for _, publicIP := range info.externalIPs {
-		err = proxier.openOnePortal(portal{net.ParseIP(publicIP), info.portal.port, true}, info.protocol, proxier.listenIP, info.proxyPort, service)
+	if !proxier.draining {
+		publicRules, err := proxier.publicPortalRules(service, info)
 		if err != nil {
 			return err
 		}
+		rules = append(rules, publicRules...)
+	}
+	return proxier.ensureRules(service, rules)
+}
+
+// openPublicPortals opens the external IPs, load balancer IPs and node port of a service.
+func (proxier *UserspaceLinux) openPublicPortals(service common.ServicePortName, info *ServiceInfo) error {
+	rules, err := proxier.publicPortalRules(service, info)
+	if err != nil {
+		return err
+	}
+	return proxier.ensureRules(service, rules)
+}
+
+// publicPortalRules claims the external IPs, load balancer IPs and node port of a service, and
+// returns their rules.
+func (proxier *UserspaceLinux) publicPortalRules(service common.ServicePortName, info *ServiceInfo) (rules []iptablesutil.RuleSpec, err error) {
+	portals := []portal{}
+	for _, publicIP := range info.externalIPs {
+		portals = append(portals, portal{net.ParseIP(publicIP), info.portal.port, true})
 	}
 	for _, ingress := range info.loadBalancerIPs {
 		if ingress != "" {
-			err = proxier.openOnePortal(portal{net.ParseIP(ingress), info.portal.port, false}, info.protocol, proxier.listenIP, info.proxyPort, service)
-			if err != nil {
-				return err
-			}
+			portals = append(portals, portal{net.ParseIP(ingress), info.portal.port, false})
 		}
 	}
+
+	for _, portal := range portals {
+		portalRules, err := proxier.portalRules(portal, info.protocol, proxier.listenIP, info.proxyPort, service)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, portalRules...)
+	}
+
 	if info.nodePort != 0 {
-		//TODO Add log here
-		err = proxier.openNodePort(info.nodePort, info.protocol, proxier.listenIP, info.proxyPort, service)
+		nodePortRules, err := proxier.nodePortRules(info.nodePort, info.protocol, proxier.listenIP, info.proxyPort, service)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		rules = append(rules, nodePortRules...)
 	}
-	return nil
+	return rules, nil
 }
 
-func (proxier *UserspaceLinux) openOnePortal(portal portal, protocol localv1.Protocol, proxyIP net.IP, proxyPort int, name iptables.ServicePortName) error {
+// portalRules claims the port of a portal if its IP is local, and returns the rules redirecting it
+// to the proxy.
+func (proxier *UserspaceLinux) portalRules(portal portal, protocol localv1.Protocol, proxyIP net.IP, proxyPort int, name common.ServicePortName) ([]iptablesutil.RuleSpec, error) {
+	if !proxier.sameFamily(portal.ip) {
+		klog.V(4).InfoS("Skipping portal of another IP family", "servicePortName", name, "ip", portal.ip)
+		return nil, nil
+	}
+
 	if proxier.localAddrs.Has(portal.ip) {
 		err := proxier.claimNodePort(portal.ip, portal.port, protocol, name)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
+	containerChain, hostChain := portalChainsOf(portal.ip)
+
 	// Handle traffic from containers.
-	args := proxier.iptablesContainerPortalArgs(portal.ip, portal.isExternal, false, portal.port, protocol, proxyIP, proxyPort, name)
-	portalAddress := net.JoinHostPort(portal.ip.String(), strconv.Itoa(portal.port))
-	existed, err := proxier.iptables.EnsureRule(iptablesutil.Append, iptablesutil.TableNAT, iptablesContainerPortalChain, args...)
-	if err != nil {
-		klog.ErrorS(err, "Failed to install iptables rule for service", "chain", iptablesContainerPortalChain, "servicePortName", name, "args", args)
-		return err
+	rules := []iptablesutil.RuleSpec{
+		{Table: iptablesutil.TableNAT, Chain: containerChain, Args: proxier.iptablesContainerPortalArgs(portal.ip, portal.isExternal, false, portal.port, protocol, proxyIP, proxyPort, name)},
 	}
-	if !existed {
-		klog.V(3).InfoS("Opened iptables from-containers portal for service", "servicePortName", name, "protocol", protocol, "portalAddress", portalAddress)
-	}
-	if portal.isExternal {
-		args := proxier.iptablesContainerPortalArgs(portal.ip, false, true, portal.port, protocol, proxyIP, proxyPort, name)
-		existed, err := proxier.iptables.EnsureRule(iptablesutil.Append, iptablesutil.TableNAT, iptablesContainerPortalChain, args...)
-		if err != nil {
-			klog.ErrorS(err, "Failed to install iptables rule that opens service for local traffic", "chain", iptablesContainerPortalChain, "servicePortName", name, "args", args)
-			return err
-		}
-		if !existed {
-			klog.V(3).InfoS("Opened iptables from-containers portal for service for local traffic", "servicePortName", name, "protocol", protocol, "portalAddress", portalAddress)
-		}
 
-		args = proxier.iptablesHostPortalArgs(portal.ip, true, portal.port, protocol, proxyIP, proxyPort, name)
-		existed, err = proxier.iptables.EnsureRule(iptablesutil.Append, iptablesutil.TableNAT, iptablesHostPortalChain, args...)
-		if err != nil {
-			klog.ErrorS(err, "Failed to install iptables rule for service for dst-local traffic", "chain", iptablesHostPortalChain, "servicePortName", name)
-			return err
-		}
-		if !existed {
-			klog.V(3).InfoS("Opened iptables from-host portal for service for dst-local traffic", "servicePortName", name, "protocol", protocol, "portalAddress", portalAddress)
-		}
-		return nil
+	if portal.isExternal {
+		// Handle local traffic, and the dst-local traffic from the host.
+		return append(rules,
+			iptablesutil.RuleSpec{Table: iptablesutil.TableNAT, Chain: containerChain, Args: proxier.iptablesContainerPortalArgs(portal.ip, false, true, portal.port, protocol, proxyIP, proxyPort, name)},
+			iptablesutil.RuleSpec{Table: iptablesutil.TableNAT, Chain: hostChain, Args: proxier.iptablesHostPortalArgs(portal.ip, true, portal.port, protocol, proxyIP, proxyPort, name)},
+		), nil
 	}
 
 	// Handle traffic from the host.
-	args = proxier.iptablesHostPortalArgs(portal.ip, false, portal.port, protocol, proxyIP, proxyPort, name)
-	existed, err = proxier.iptables.EnsureRule(iptablesutil.Append, iptablesutil.TableNAT, iptablesHostPortalChain, args...)
-	if err != nil {
-		klog.ErrorS(err, "Failed to install iptables rule for service", "chain", iptablesHostPortalChain, "servicePortName", name)
-		return err
-	}
-	if !existed {
-		klog.V(3).InfoS("Opened iptables from-host portal for service", "servicePortName", name, "protocol", protocol, "portalAddress", portalAddress)
-	}
-	return nil
+	return append(rules,
+		iptablesutil.RuleSpec{Table: iptablesutil.TableNAT, Chain: hostChain, Args: proxier.iptablesHostPortalArgs(portal.ip, false, portal.port, protocol, proxyIP, proxyPort, name)},
+	), nil
 }
 
 // Marks a port as being owned by a particular service, or returns error if already claimed.
 // Idempotent: reclaiming with the same owner is not an error
-func (proxier *UserspaceLinux) claimNodePort(ip net.IP, port int, protocol localv1.Protocol, owner iptables.ServicePortName) error {
+func (proxier *UserspaceLinux) claimNodePort(ip net.IP, port int, protocol localv1.Protocol, owner common.ServicePortName) error {
 	proxier.portMapMutex.Lock()
 	defer proxier.portMapMutex.Unlock()
 
@@ -896,7 +1186,7 @@ func (proxier *UserspaceLinux) claimNodePort(ip net.IP, port int, protocol local
 
 // Release a claim on a port.  Returns an error if the owner does not match the claim.
 // Tolerates release on an unclaimed port, to simplify .
-func (proxier *UserspaceLinux) releaseNodePort(ip net.IP, port int, protocol localv1.Protocol, owner iptables.ServicePortName) error {
+func (proxier *UserspaceLinux) releaseNodePort(ip net.IP, port int, protocol localv1.Protocol, owner common.ServicePortName) error {
 	proxier.portMapMutex.Lock()
 	defer proxier.portMapMutex.Unlock()
 
@@ -915,53 +1205,41 @@ func (proxier *UserspaceLinux) releaseNodePort(ip net.IP, port int, protocol loc
 	return nil
 }
 
-func (proxier *UserspaceLinux) openNodePort(nodePort int, protocol localv1.Protocol, proxyIP net.IP, proxyPort int, name iptables.ServicePortName) error {
+// nodePortRules claims a node port, and returns the rules redirecting it to the proxy.
+func (proxier *UserspaceLinux) nodePortRules(nodePort int, protocol localv1.Protocol, proxyIP net.IP, proxyPort int, name common.ServicePortName) ([]iptablesutil.RuleSpec, error) {
 	// TODO: Do we want to allow containers to access public services?  Probably yes.
 	// TODO: We could refactor this to be the same code as portal, but with IP == nil
 
 	err := proxier.claimNodePort(nil, nodePort, protocol, name)
 	if err != nil {
-		return err
-	}
-
-	// Handle traffic from containers.
-	args := proxier.iptablesContainerPortalArgs(nil, false, false, nodePort, protocol, proxyIP, proxyPort, name)
-	existed, err := proxier.iptables.EnsureRule(iptablesutil.Append, iptablesutil.TableNAT, iptablesContainerNodePortChain, args...)
-	if err != nil {
-		klog.ErrorS(err, "Failed to install iptables rule for service", "chain", iptablesContainerNodePortChain, "servicePortName", name)
-		return err
-	}
-	if !existed {
-		klog.InfoS("Opened iptables from-containers public port for service", "servicePortName", name, "protocol", protocol, "nodePort", nodePort)
+		return nil, err
 	}
 
-	// Handle traffic from the host.
-	args = proxier.iptablesHostNodePortArgs(nodePort, protocol, proxyIP, proxyPort, name)
-	existed, err = proxier.iptables.EnsureRule(iptablesutil.Append, iptablesutil.TableNAT, iptablesHostNodePortChain, args...)
-	if err != nil {
-		klog.ErrorS(err, "Failed to install iptables rule for service", "chain", iptablesHostNodePortChain, "servicePortName", name)
-		return err
-	}
-	if !existed {
-		klog.InfoS("Opened iptables from-host public port for service", "servicePortName", name, "protocol", protocol, "nodePort", nodePort)
-	}
+	return []iptablesutil.RuleSpec{
+		// Handle traffic from containers.
+		{Table: iptablesutil.TableNAT, Chain: iptablesContainerNodePortChain, Args: proxier.iptablesContainerPortalArgs(nil, false, false, nodePort, protocol, proxyIP, proxyPort, name)},
+		// Handle traffic from the host.
+		{Table: iptablesutil.TableNAT, Chain: iptablesHostNodePortChain, Args: proxier.iptablesHostNodePortArgs(nodePort, protocol, proxyIP, proxyPort, name)},
+		{Table: iptablesutil.TableFilter, Chain: iptablesNonLocalNodePortChain, Args: proxier.iptablesNonLocalNodePortArgs(nodePort, protocol, proxyIP, proxyPort, name)},
+	}, nil
+}
 
-	args = proxier.iptablesNonLocalNodePortArgs(nodePort, protocol, proxyIP, proxyPort, name)
-	existed, err = proxier.iptables.EnsureRule(iptablesutil.Append, iptablesutil.TableFilter, iptablesNonLocalNodePortChain, args...)
-	if err != nil {
-		klog.ErrorS(err, "Failed to install iptables rule for service", "chain", iptablesNonLocalNodePortChain, "servicePortName", name)
-		return err
+func (proxier *UserspaceLinux) closePortal(service common.ServicePortName, info *ServiceInfo) error {
+	// Collect errors and report them all at the end.
+	el := proxier.closeOnePortal(info.portal, info.protocol, proxier.listenIP, info.proxyPort, service)
+	if !proxier.draining {
+		el = append(el, proxier.closePublicPortals(service, info)...)
 	}
-	if !existed {
-		klog.InfoS("Opened iptables from-non-local public port for service", "servicePortName", name, "protocol", protocol, "nodePort", nodePort)
+	if len(el) == 0 {
+		klog.V(3).InfoS("Closed iptables portals for service", "servicePortName", service)
+	} else {
+		klog.ErrorS(nil, "Some errors closing iptables portals for service", "servicePortName", service)
 	}
-
-	return nil
+	return utilerrors.NewAggregate(el)
 }
 
-func (proxier *UserspaceLinux) closePortal(service iptables.ServicePortName, info *ServiceInfo) error {
-	// Collect errors and report them all at the end.
-	el := proxier.closeOnePortal(info.portal, info.protocol, proxier.listenIP, info.proxyPort, service)
+// closePublicPortals closes the external IPs, load balancer IPs and node port of a service.
+func (proxier *UserspaceLinux) closePublicPortals(service common.ServicePortName, info *ServiceInfo) (el []error) {
 	for _, publicIP := range info.externalIPs {
 		el = append(el, proxier.closeOnePortal(portal{net.ParseIP(publicIP), info.portal.port, true}, info.protocol, proxier.listenIP, info.proxyPort, service)...)
 	}
@@ -973,15 +1251,14 @@ func (proxier *UserspaceLinux) closePortal(service iptables.ServicePortName, inf
 	if info.nodePort != 0 {
 		el = append(el, proxier.closeNodePort(info.nodePort, info.protocol, proxier.listenIP, info.proxyPort, service)...)
 	}
-	if len(el) == 0 {
-		klog.V(3).InfoS("Closed iptables portals for service", "servicePortName", service)
-	} else {
-		klog.ErrorS(nil, "Some errors closing iptables portals for service", "servicePortName", service)
-	}
-	return utilerrors.NewAggregate(el)
+	return el
 }
 
-func (proxier *UserspaceLinux) closeOnePortal(portal portal, protocol localv1.Protocol, proxyIP net.IP, proxyPort int, name iptables.ServicePortName) []error {
+func (proxier *UserspaceLinux) closeOnePortal(portal portal, protocol localv1.Protocol, proxyIP net.IP, proxyPort int, name common.ServicePortName) []error {
+	if !proxier.sameFamily(portal.ip) {
+		return nil
+	}
+
 	el := []error{}
 	if proxier.localAddrs.Has(portal.ip) {
 		if err := proxier.releaseNodePort(portal.ip, portal.port, protocol, name); err != nil {
@@ -989,23 +1266,25 @@ func (proxier *UserspaceLinux) closeOnePortal(portal portal, protocol localv1.Pr
 		}
 	}
 
+	containerChain, hostChain := portalChainsOf(portal.ip)
+
 	// Handle traffic from containers.
 	args := proxier.iptablesContainerPortalArgs(portal.ip, portal.isExternal, false, portal.port, protocol, proxyIP, proxyPort, name)
-	if err := proxier.iptables.DeleteRule(iptablesutil.TableNAT, iptablesContainerPortalChain, args...); err != nil {
-		klog.ErrorS(err, "Failed to delete iptables rule for service", "chain", iptablesContainerPortalChain, "servicePortName", name)
+	if err := proxier.iptables.DeleteRule(iptablesutil.TableNAT, containerChain, args...); err != nil {
+		klog.ErrorS(err, "Failed to delete iptables rule for service", "chain", containerChain, "servicePortName", name)
 		el = append(el, err)
 	}
 
 	if portal.isExternal {
 		args := proxier.iptablesContainerPortalArgs(portal.ip, false, true, portal.port, protocol, proxyIP, proxyPort, name)
-		if err := proxier.iptables.DeleteRule(iptablesutil.TableNAT, iptablesContainerPortalChain, args...); err != nil {
-			klog.ErrorS(err, "Failed to delete iptables rule for service", "chain", iptablesContainerPortalChain, "servicePortName", name)
+		if err := proxier.iptables.DeleteRule(iptablesutil.TableNAT, containerChain, args...); err != nil {
+			klog.ErrorS(err, "Failed to delete iptables rule for service", "chain", containerChain, "servicePortName", name)
 			el = append(el, err)
 		}
 
 		args = proxier.iptablesHostPortalArgs(portal.ip, true, portal.port, protocol, proxyIP, proxyPort, name)
-		if err := proxier.iptables.DeleteRule(iptablesutil.TableNAT, iptablesHostPortalChain, args...); err != nil {
-			klog.ErrorS(err, "Failed to delete iptables rule for service", "chain", iptablesHostPortalChain, "servicePortName", name)
+		if err := proxier.iptables.DeleteRule(iptablesutil.TableNAT, hostChain, args...); err != nil {
+			klog.ErrorS(err, "Failed to delete iptables rule for service", "chain", hostChain, "servicePortName", name)
 			el = append(el, err)
 		}
 		return el
@@ -1013,15 +1292,15 @@ func (proxier *UserspaceLinux) closeOnePortal(portal portal, protocol localv1.Pr
 
 	// Handle traffic from the host (portalIP is not external).
 	args = proxier.iptablesHostPortalArgs(portal.ip, false, portal.port, protocol, proxyIP, proxyPort, name)
-	if err := proxier.iptables.DeleteRule(iptablesutil.TableNAT, iptablesHostPortalChain, args...); err != nil {
-		klog.ErrorS(err, "Failed to delete iptables rule for service", "chain", iptablesHostPortalChain, "servicePortName", name)
+	if err := proxier.iptables.DeleteRule(iptablesutil.TableNAT, hostChain, args...); err != nil {
+		klog.ErrorS(err, "Failed to delete iptables rule for service", "chain", hostChain, "servicePortName", name)
 		el = append(el, err)
 	}
 
 	return el
 }
 
-func (proxier *UserspaceLinux) closeNodePort(nodePort int, protocol localv1.Protocol, proxyIP net.IP, proxyPort int, name iptables.ServicePortName) []error {
+func (proxier *UserspaceLinux) closeNodePort(nodePort int, protocol localv1.Protocol, proxyIP net.IP, proxyPort int, name common.ServicePortName) []error {
 	el := []error{}
 
 	// Handle traffic from containers.
@@ -1057,6 +1336,44 @@ func (proxier *UserspaceLinux) closeNodePort(nodePort int, protocol localv1.Prot
 var iptablesContainerPortalChain iptablesutil.Chain = "KUBE-PORTALS-CONTAINER"
 var iptablesHostPortalChain iptablesutil.Chain = "KUBE-PORTALS-HOST"
 
+// Chains for the portals of the cluster IPs in ServiceCIDRs, only traversed by the packets to
+// these CIDRs.
+var iptablesContainerClusterIPChain iptablesutil.Chain = "KUBE-CLUSTERIPS-CONTAINER"
+var iptablesHostClusterIPChain iptablesutil.Chain = "KUBE-CLUSTERIPS-HOST"
+
+// ServiceCIDRs are the service cluster IP ranges. If set, the portals of the cluster IPs are
+// moved out of the generic portal chains, so the other packets skip their rules.
+var ServiceCIDRs []*net.IPNet
+
+// serviceCIDRs returns the ServiceCIDRs of the IP family (IPv6 or not).
+func serviceCIDRs(ipv6 bool) (cidrs []*net.IPNet) {
+	for _, cidr := range ServiceCIDRs {
+		if (cidr.IP.To4() == nil) == ipv6 {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	return
+}
+
+// clusterIPArgs are the arguments of the rule jumping to the cluster IP chains for a CIDR.
+func clusterIPArgs(cidr *net.IPNet, chain iptablesutil.Chain) []string {
+	return []string{
+		"-d", cidr.String(),
+		"-m", "comment", "--comment", "handle ClusterIPs in the service CIDR; NOTE: this must be before the NodePort rules",
+		"-j", string(chain),
+	}
+}
+
+// portalChainsOf returns the chains of the portal rules of ip, from containers and from the host.
+func portalChainsOf(ip net.IP) (container, host iptablesutil.Chain) {
+	for _, cidr := range ServiceCIDRs {
+		if cidr.Contains(ip) {
+			return iptablesContainerClusterIPChain, iptablesHostClusterIPChain
+		}
+	}
+	return iptablesContainerPortalChain, iptablesHostPortalChain
+}
+
 // Chains for NodePort services
 var iptablesContainerNodePortChain iptablesutil.Chain = "KUBE-NODEPORT-CONTAINER"
 var iptablesHostNodePortChain iptablesutil.Chain = "KUBE-NODEPORT-HOST"
@@ -1064,9 +1381,8 @@ var iptablesNonLocalNodePortChain iptablesutil.Chain = "KUBE-NODEPORT-NON-LOCAL"
 
 // Ensure that the iptables infrastructure we use is set up.  This can safely be called periodically.
 func iptablesInit(ipt Interface) error {
-	// TODO: There is almost certainly room for optimization here.  E.g. If
-	// we knew the service-cluster-ip-range CIDR we could fast-track outbound packets not
-	// destined for a service. There's probably more, help wanted.
+	// The cluster IPs in the service CIDRs have their own chains, so the packets to other
+	// destinations don't traverse their rules.
 
 	// Danger - order of these rules matters here:
 	//
@@ -1085,8 +1401,22 @@ func iptablesInit(ipt Interface) error {
 	// the NodePort would take priority (incorrectly).
 	// This is unlikely (and would only affect outgoing traffic from the cluster to the load balancer, which seems
 	// doubly-unlikely), but we need to be careful to keep the rules in the right order.
-	args := []string{ /* service-cluster-ip-range matching could go here */ }
-	args = append(args, "-m", "comment", "--comment", "handle ClusterIPs; NOTE: this must be before the NodePort rules")
+	for _, cidr := range serviceCIDRs(ipt.IsIPv6()) {
+		if _, err := ipt.EnsureChain(iptablesutil.TableNAT, iptablesContainerClusterIPChain); err != nil {
+			return err
+		}
+		if _, err := ipt.EnsureRule(iptablesutil.Prepend, iptablesutil.TableNAT, iptablesutil.ChainPrerouting, clusterIPArgs(cidr, iptablesContainerClusterIPChain)...); err != nil {
+			return err
+		}
+		if _, err := ipt.EnsureChain(iptablesutil.TableNAT, iptablesHostClusterIPChain); err != nil {
+			return err
+		}
+		if _, err := ipt.EnsureRule(iptablesutil.Prepend, iptablesutil.TableNAT, iptablesutil.ChainOutput, clusterIPArgs(cidr, iptablesHostClusterIPChain)...); err != nil {
+			return err
+		}
+	}
+
+	args := []string{"-m", "comment", "--comment", "handle ClusterIPs; NOTE: this must be before the NodePort rules"}
 	if _, err := ipt.EnsureChain(iptablesutil.TableNAT, iptablesContainerPortalChain); err != nil {
 		return err
 	}
@@ -1131,6 +1461,27 @@ func iptablesInit(ipt Interface) error {
 	return nil
 }
 
+// ipv6RedirectSupported checks that ip6tables can REDIRECT, by adding and removing a probe rule
+// in the container portal chain.
+func ipv6RedirectSupported(ipt iptablesutil.Interface) bool {
+	args := []string{
+		"-m", "comment", "--comment", "kpng REDIRECT probe",
+		"-p", "tcp", "-m", "tcp", "--dport", "1",
+		"-d", ToCIDR(localhostIPv6),
+		"-j", "REDIRECT", "--to-ports", "1",
+	}
+
+	if _, err := ipt.EnsureRule(iptablesutil.Append, iptablesutil.TableNAT, iptablesContainerPortalChain, args...); err != nil {
+		klog.InfoS("IPv6 REDIRECT not supported, using DNAT to the host IP", "err", err)
+		return false
+	}
+
+	if err := ipt.DeleteRule(iptablesutil.TableNAT, iptablesContainerPortalChain, args...); err != nil {
+		klog.ErrorS(err, "Failed to delete the IPv6 REDIRECT probe rule")
+	}
+	return true
+}
+
 // Flush all of our custom iptables rules.
 func iptablesFlush(ipt iptablesutil.Interface) error {
 	el := []error{}
@@ -1140,6 +1491,14 @@ func iptablesFlush(ipt iptablesutil.Interface) error {
 	if err := ipt.FlushChain(iptablesutil.TableNAT, iptablesHostPortalChain); err != nil {
 		el = append(el, err)
 	}
+	if len(serviceCIDRs(ipt.IsIPv6())) != 0 {
+		if err := ipt.FlushChain(iptablesutil.TableNAT, iptablesContainerClusterIPChain); err != nil {
+			el = append(el, err)
+		}
+		if err := ipt.FlushChain(iptablesutil.TableNAT, iptablesHostClusterIPChain); err != nil {
+			el = append(el, err)
+		}
+	}
 	if err := ipt.FlushChain(iptablesutil.TableNAT, iptablesContainerNodePortChain); err != nil {
 		el = append(el, err)
 	}
@@ -1162,8 +1521,18 @@ var localhostIPv4 = net.ParseIP("127.0.0.1")
 var zeroIPv6 = net.ParseIP("::")
 var localhostIPv6 = net.ParseIP("::1")
 
+// portalComment returns the comment of the portal rules of a service port. The rules are ensured
+// one by one, their comment has no revision to stay the same when the service changes.
+func portalComment(service common.ServicePortName) string {
+	return rulecomment.Comment{
+		Backend: "userspacelin",
+		Service: service.NamespacedName.String(),
+		Port:    service.Port,
+	}.String()
+}
+
 // Build a slice of iptables args that are common to from-container and from-host portal rules.
-func iptablesCommonPortalArgs(destIP net.IP, addPhysicalInterfaceMatch bool, addDstLocalMatch bool, destPort int, protocol localv1.Protocol, service iptables.ServicePortName) []string {
+func iptablesCommonPortalArgs(destIP net.IP, addPhysicalInterfaceMatch bool, addDstLocalMatch bool, destPort int, protocol localv1.Protocol, service common.ServicePortName) []string {
 	// This list needs to include all fields as they are eventually spit out
 	// by iptables-save.  This is because some systems do not support the
 	// 'iptables -C' arg, and so fall back on parsing iptables-save output.
@@ -1174,13 +1543,14 @@ func iptablesCommonPortalArgs(destIP net.IP, addPhysicalInterfaceMatch bool, add
 	// iptables versions.
 	args := []string{
 		"-m", "comment",
-		"--comment", service.String(),
+		"--comment", portalComment(service),
 		"-p", strings.ToLower(protocol.String()),
 		"-m", strings.ToLower(protocol.String()),
 		"--dport", fmt.Sprintf("%d", destPort),
 	}
 
 	if destIP != nil {
+		// iptables-save prints /32 for IPv4 and /128 for IPv6
 		args = append(args, "-d", ToCIDR(destIP))
 	}
 
@@ -1196,7 +1566,7 @@ func iptablesCommonPortalArgs(destIP net.IP, addPhysicalInterfaceMatch bool, add
 }
 
 // Build a slice of iptables args for a from-container portal rule.
-func (proxier *UserspaceLinux) iptablesContainerPortalArgs(destIP net.IP, addPhysicalInterfaceMatch bool, addDstLocalMatch bool, destPort int, protocol localv1.Protocol, proxyIP net.IP, proxyPort int, service iptables.ServicePortName) []string {
+func (proxier *UserspaceLinux) iptablesContainerPortalArgs(destIP net.IP, addPhysicalInterfaceMatch bool, addDstLocalMatch bool, destPort int, protocol localv1.Protocol, proxyIP net.IP, proxyPort int, service common.ServicePortName) []string {
 	args := iptablesCommonPortalArgs(destIP, addPhysicalInterfaceMatch, addDstLocalMatch, destPort, protocol, service)
 
 	// This is tricky.
@@ -1230,20 +1600,24 @@ func (proxier *UserspaceLinux) iptablesContainerPortalArgs(destIP net.IP, addPhy
 	// interfaces but not ALL interfaces, short of doing it manually, and
 	// this is simpler than that.
 	//
-	// If the proxy is bound to localhost only, all of this is broken.  Not
-	// allowed.
-	if proxyIP.Equal(zeroIPv4) || proxyIP.Equal(zeroIPv6) {
-		// TODO: Can we REDIRECT with IPv6?
+	// If the proxy is bound to localhost only, all of this is broken, unless
+	// route_localnet is enabled.  Only allowed with AllowLocalhostProxy.
+	//
+	// IPv6 REDIRECT needs ip6tables NAT support in the kernel (3.7+); when it's
+	// missing, we fall back to DNAT to the host IP.
+	if (proxyIP.Equal(zeroIPv4) || proxyIP.Equal(zeroIPv6)) && proxier.canRedirect {
 		args = append(args, "-j", "REDIRECT", "--to-ports", fmt.Sprintf("%d", proxyPort))
 	} else {
-		// TODO: Can we DNAT with IPv6?
-		args = append(args, "-j", "DNAT", "--to-destination", net.JoinHostPort(proxyIP.String(), strconv.Itoa(proxyPort)))
+		if proxyIP.Equal(zeroIPv4) || proxyIP.Equal(zeroIPv6) {
+			proxyIP = proxier.hostIP
+		}
+		args = append(args, "-j", "DNAT", "--to-destination", dnatDestination(proxyIP, proxyPort))
 	}
 	return args
 }
 
 // Build a slice of iptables args for a from-host portal rule.
-func (proxier *UserspaceLinux) iptablesHostPortalArgs(destIP net.IP, addDstLocalMatch bool, destPort int, protocol localv1.Protocol, proxyIP net.IP, proxyPort int, service iptables.ServicePortName) []string {
+func (proxier *UserspaceLinux) iptablesHostPortalArgs(destIP net.IP, addDstLocalMatch bool, destPort int, protocol localv1.Protocol, proxyIP net.IP, proxyPort int, service common.ServicePortName) []string {
 	args := iptablesCommonPortalArgs(destIP, false, addDstLocalMatch, destPort, protocol, service)
 
 	// This is tricky.
@@ -1265,44 +1639,59 @@ func (proxier *UserspaceLinux) iptablesHostPortalArgs(destIP net.IP, addDstLocal
 	// that IP.  Unlike the previous case, this works because the proxy is
 	// ONLY listening on that IP, not the bridge.
 	//
-	// If the proxy is bound to localhost only, this should work, but we
-	// don't allow it for now.
+	// If the proxy is bound to localhost only, this works, but it's only
+	// allowed with AllowLocalhostProxy.
 	if proxyIP.Equal(zeroIPv4) || proxyIP.Equal(zeroIPv6) {
 		proxyIP = proxier.hostIP
 	}
-	// TODO: Can we DNAT with IPv6?
-	args = append(args, "-j", "DNAT", "--to-destination", net.JoinHostPort(proxyIP.String(), strconv.Itoa(proxyPort)))
+	args = append(args, "-j", "DNAT", "--to-destination", dnatDestination(proxyIP, proxyPort))
 	return args
 }
 
 // Build a slice of iptables args for a from-host public-port rule.
 // See iptablesHostPortalArgs
 // TODO: Should we just reuse iptablesHostPortalArgs?
-func (proxier *UserspaceLinux) iptablesHostNodePortArgs(nodePort int, protocol localv1.Protocol, proxyIP net.IP, proxyPort int, service iptables.ServicePortName) []string {
+func (proxier *UserspaceLinux) iptablesHostNodePortArgs(nodePort int, protocol localv1.Protocol, proxyIP net.IP, proxyPort int, service common.ServicePortName) []string {
 	args := iptablesCommonPortalArgs(nil, false, false, nodePort, protocol, service)
 
 	if proxyIP.Equal(zeroIPv4) || proxyIP.Equal(zeroIPv6) {
 		proxyIP = proxier.hostIP
 	}
-	// TODO: Can we DNAT with IPv6?
-	args = append(args, "-j", "DNAT", "--to-destination", net.JoinHostPort(proxyIP.String(), strconv.Itoa(proxyPort)))
+	args = append(args, "-j", "DNAT", "--to-destination", dnatDestination(proxyIP, proxyPort))
 	return args
 }
 
 // Build a slice of iptables args for an from-non-local public-port rule.
-func (proxier *UserspaceLinux) iptablesNonLocalNodePortArgs(nodePort int, protocol localv1.Protocol, proxyIP net.IP, proxyPort int, service iptables.ServicePortName) []string {
+func (proxier *UserspaceLinux) iptablesNonLocalNodePortArgs(nodePort int, protocol localv1.Protocol, proxyIP net.IP, proxyPort int, service common.ServicePortName) []string {
 	args := iptablesCommonPortalArgs(nil, false, false, proxyPort, protocol, service)
 	args = append(args, "-m", "state", "--state", "NEW", "-j", "ACCEPT")
 	return args
 }
 
+// dnatDestination formats the --to-destination of a DNAT rule (IPv6 addresses are bracketed).
+func dnatDestination(ip net.IP, port int) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return net.JoinHostPort(ip.String(), strconv.Itoa(port))
+}
+
+// sameFamily returns true if ip is of the IP family managed by the proxier. A nil ip matches any family.
+func (proxier *UserspaceLinux) sameFamily(ip net.IP) bool {
+	if ip == nil {
+		return true
+	}
+	return (ip.To4() == nil) == proxier.iptables.IsIPv6()
+}
+
+// isTooManyFDsError returns true if the error is caused by the process running out of file
+// descriptors (the proxy can't recover from it).
 func isTooManyFDsError(err error) bool {
-	return strings.Contains(err.Error(), "too many open files")
+	return errors.Is(err, syscall.EMFILE)
 }
 
+// isClosedError returns true if the error is caused by the use of a closed connection or listener
+// (ie: the proxy was stopped).
 func isClosedError(err error) bool {
-	// A brief discussion about handling closed error here:
-	// https://code.google.com/p/go/issues/detail?id=4373#c14
-	// TODO: maybe create a stoppable TCP listener that returns a StoppedError
-	return strings.HasSuffix(err.Error(), "use of closed network connection")
+	return errors.Is(err, net.ErrClosed)
 }
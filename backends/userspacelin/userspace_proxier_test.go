@@ -0,0 +1,395 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/backends/common"
+	iptablesutil "sigs.k8s.io/kpng/backends/iptables/util"
+	"sigs.k8s.io/kpng/client/plugins/conntrack"
+)
+
+// fakeRules is an iptables interface keeping the rules written by the proxier.
+type fakeRules struct {
+	iptablesutil.Interface
+	rules map[string]bool
+}
+
+func (f *fakeRules) IsIPv6() bool { return false }
+
+func (f *fakeRules) EnsureRules(rules []iptablesutil.RuleSpec) (added int, err error) {
+	for _, rule := range rules {
+		key := string(rule.Chain) + " " + strings.Join(rule.Args, " ")
+		if !f.rules[key] {
+			f.rules[key] = true
+			added++
+		}
+	}
+	return
+}
+
+func (f *fakeRules) DeleteRule(_ iptablesutil.Table, chain iptablesutil.Chain, args ...string) error {
+	delete(f.rules, string(chain)+" "+strings.Join(args, " "))
+	return nil
+}
+
+// matching returns the rules containing all the parts.
+func (f *fakeRules) matching(parts ...string) (rules []string) {
+rules:
+	for rule := range f.rules {
+		for _, part := range parts {
+			if !strings.Contains(rule, part) {
+				continue rules
+			}
+		}
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	return
+}
+
+// fakeSocket is a proxy socket that doesn't proxy.
+type fakeSocket struct {
+	port   int
+	closed bool
+}
+
+func (s *fakeSocket) Addr() net.Addr                                               { return &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: s.port} }
+func (s *fakeSocket) Close() error                                                 { s.closed = true; return nil }
+func (s *fakeSocket) ListenPort() int                                              { return s.port }
+func (s *fakeSocket) ProxyLoop(common.ServicePortName, *ServiceInfo, LoadBalancer) {}
+
+func newFakeProxier() (*UserspaceLinux, *fakeRules) {
+	rules := &fakeRules{rules: map[string]bool{}}
+	nextPort := 40000
+
+	proxier := &UserspaceLinux{
+		loadBalancer: NewLoadBalancerRR(),
+		serviceMap:   map[common.ServicePortName]*ServiceInfo{},
+		servicePorts: map[servicePortKey]common.ServicePortName{},
+		portMap:      map[portMapKey]*portMapValue{},
+		listenIP:     net.IPv4(10, 0, 0, 1),
+		hostIP:       net.IPv4(10, 0, 0, 1),
+		iptables:     rules,
+		proxyPorts:   newPortAllocator(utilnet.PortRange{}),
+		makeProxySocket: func(_ localv1.Protocol, _ net.IP, port int) (ProxySocket, error) {
+			if port == 0 {
+				nextPort++
+				port = nextPort
+			}
+			return &fakeSocket{port: port}, nil
+		},
+	}
+	return proxier, rules
+}
+
+func servicePortsOf(ports ...*localv1.PortMapping) *localv1.Service {
+	return &localv1.Service{
+		Namespace: "ns",
+		Name:      "svc",
+		Type:      "NodePort",
+		IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.96.0.10"), ExternalIPs: localv1.NewIPSet()},
+		Ports:     ports,
+	}
+}
+
+// update applies a change of the service like a sync.
+func update(proxier *UserspaceLinux, previous, current *localv1.Service) {
+	existingPorts := proxier.mergeService(current)
+	proxier.unmergeService(previous, existingPorts)
+	proxier.clearProtocolChanges(previous, current)
+}
+
+func TestPortRename(t *testing.T) {
+	proxier, rules := newFakeProxier()
+
+	v1 := servicePortsOf(&localv1.PortMapping{Name: "http", Protocol: localv1.Protocol_TCP, Port: 80, NodePort: 30080})
+	update(proxier, nil, v1)
+
+	if len(rules.matching("port=http ")) == 0 {
+		t.Fatal("no rules for ns/svc:http")
+	}
+
+	v2 := servicePortsOf(&localv1.PortMapping{Name: "web", Protocol: localv1.Protocol_TCP, Port: 80, NodePort: 30080})
+	update(proxier, v1, v2)
+
+	if leaked := rules.matching("port=http "); len(leaked) != 0 {
+		t.Errorf("rules of the previous name left: %q", leaked)
+	}
+	if len(rules.matching("port=web ", "--dport 80")) == 0 || len(rules.matching("port=web ", "--dport 30080")) == 0 {
+		t.Errorf("missing rules for ns/svc:web: %q", rules.matching())
+	}
+
+	if len(proxier.serviceMap) != 1 {
+		t.Errorf("expected 1 proxy, got %v", proxier.serviceMap)
+	}
+	if owner := proxier.portMap[portMapKey{ip: "<nil>", port: 30080, protocol: localv1.Protocol_TCP}]; owner == nil || owner.owner.Port != "web" {
+		t.Errorf("node port not claimed by the new name: %+v", owner)
+	}
+}
+
+func TestPortSwap(t *testing.T) {
+	proxier, rules := newFakeProxier()
+
+	v1 := servicePortsOf(
+		&localv1.PortMapping{Name: "a", Protocol: localv1.Protocol_TCP, Port: 80},
+		&localv1.PortMapping{Name: "b", Protocol: localv1.Protocol_TCP, Port: 81},
+	)
+	update(proxier, nil, v1)
+
+	v2 := servicePortsOf(
+		&localv1.PortMapping{Name: "a", Protocol: localv1.Protocol_TCP, Port: 81},
+		&localv1.PortMapping{Name: "b", Protocol: localv1.Protocol_TCP, Port: 80},
+	)
+	update(proxier, v1, v2)
+
+	if len(rules.matching("port=a ", "--dport 80")) != 0 || len(rules.matching("port=b ", "--dport 81")) != 0 {
+		t.Errorf("rules of the previous ports left: %q", rules.matching())
+	}
+	if len(rules.matching("port=a ", "--dport 81")) == 0 || len(rules.matching("port=b ", "--dport 80")) == 0 {
+		t.Errorf("missing rules of the swapped ports: %q", rules.matching())
+	}
+}
+
+func TestPortProtocolChange(t *testing.T) {
+	proxier, rules := newFakeProxier()
+
+	var cleared []string
+	defer func(prev func(conntrack.IPPort)) { clearConntrack = prev }(clearConntrack)
+	clearConntrack = func(ipp conntrack.IPPort) { cleared = append(cleared, ipp.Key()) }
+
+	v1 := servicePortsOf(&localv1.PortMapping{Name: "dns", Protocol: localv1.Protocol_TCP, Port: 53, NodePort: 30053})
+	update(proxier, nil, v1)
+
+	if len(cleared) != 0 {
+		t.Errorf("conntrack entries cleared for a new service: %q", cleared)
+	}
+
+	for _, v2 := range []*localv1.Service{
+		// same name
+		servicePortsOf(&localv1.PortMapping{Name: "dns", Protocol: localv1.Protocol_UDP, Port: 53, NodePort: 30053}),
+		// renamed
+		servicePortsOf(&localv1.PortMapping{Name: "dns-udp", Protocol: localv1.Protocol_UDP, Port: 53, NodePort: 30053}),
+	} {
+		cleared = nil
+		update(proxier, v1, v2)
+
+		if expected := []string{"TCP/10.96.0.10,53", "TCP/node,30053"}; strings.Join(cleared, " ") != strings.Join(expected, " ") {
+			t.Errorf("%s: cleared conntrack entries %q, expected %q", v2.Ports[0].Name, cleared, expected)
+		}
+		if leaked := rules.matching("-p tcp"); len(leaked) != 0 {
+			t.Errorf("%s: rules of the previous protocol left: %q", v2.Ports[0].Name, leaked)
+		}
+		if len(rules.matching("port="+v2.Ports[0].Name+" ", "-p udp")) == 0 {
+			t.Errorf("%s: missing udp rules: %q", v2.Ports[0].Name, rules.matching())
+		}
+		if _, ok := proxier.portMap[portMapKey{ip: "<nil>", port: 30053, protocol: localv1.Protocol_TCP}]; ok {
+			t.Errorf("%s: tcp node port still claimed", v2.Ports[0].Name)
+		}
+
+		v1 = servicePortsOf(&localv1.PortMapping{Name: "dns", Protocol: localv1.Protocol_TCP, Port: 53, NodePort: 30053})
+		update(proxier, v2, v1)
+	}
+}
+
+// fakeSysctl is a sysctl interface keeping the values written.
+type fakeSysctl struct {
+	values map[string]int
+	err    error
+}
+
+func (f *fakeSysctl) GetSysctl(name string) (int, error) { return f.values[name], nil }
+
+func (f *fakeSysctl) SetSysctl(name string, value int) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.values[name] = value
+	return nil
+}
+
+func TestHostIPFor(t *testing.T) {
+	defer func(allow bool) { AllowLocalhostProxy = allow }(AllowLocalhostProxy)
+
+	for _, tc := range []struct {
+		name        string
+		listenIP    string
+		ipv6        bool
+		allow       bool
+		setErr      error
+		hostIP      string
+		expectErr   bool
+		routeLocal  int
+		initialized bool
+	}{
+		{name: "listen IP", listenIP: "10.0.0.5", hostIP: "10.0.0.5"},
+		{name: "localhost not allowed", listenIP: "127.0.0.1", expectErr: true},
+		{name: "localhost", listenIP: "127.0.0.1", allow: true, hostIP: "127.0.0.1", routeLocal: 1},
+		{name: "route_localnet already enabled", listenIP: "127.0.0.1", allow: true, hostIP: "127.0.0.1", routeLocal: 1, initialized: true, setErr: errors.New("read-only")},
+		{name: "route_localnet not writable", listenIP: "127.0.0.1", allow: true, setErr: errors.New("read-only"), expectErr: true},
+		{name: "IPv6 localhost", listenIP: "::1", ipv6: true, allow: true, hostIP: "::1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			AllowLocalhostProxy = tc.allow
+			sysctl := &fakeSysctl{values: map[string]int{}, err: tc.setErr}
+			if tc.initialized {
+				sysctl.values[sysctlRouteLocalnet] = 1
+			}
+
+			hostIP, err := hostIPFor(net.ParseIP(tc.listenIP), tc.ipv6, sysctl)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error: %v, got %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if hostIP.String() != tc.hostIP {
+				t.Errorf("expected host IP %s, got %s", tc.hostIP, hostIP)
+			}
+			if v := sysctl.values[sysctlRouteLocalnet]; v != tc.routeLocal {
+				t.Errorf("expected route_localnet=%d, got %d", tc.routeLocal, v)
+			}
+		})
+	}
+
+	AllowLocalhostProxy = false
+	if _, err := hostIPFor(net.ParseIP("127.0.0.1"), false, &fakeSysctl{values: map[string]int{}}); !errors.Is(err, ErrProxyOnLocalhost) {
+		t.Errorf("expected ErrProxyOnLocalhost, got %v", err)
+	}
+}
+
+// probeRules is an ip6tables interface failing to add the REDIRECT rules if the kernel can't.
+type probeRules struct {
+	iptablesutil.Interface
+	canRedirect bool
+	rules       []string
+}
+
+func (f *probeRules) IsIPv6() bool { return true }
+
+func (f *probeRules) EnsureRule(_ iptablesutil.RulePosition, _ iptablesutil.Table, chain iptablesutil.Chain, args ...string) (bool, error) {
+	rule := string(chain) + " " + strings.Join(args, " ")
+	if !f.canRedirect && strings.Contains(rule, "-j REDIRECT") {
+		return false, errors.New("No chain/target/match by that name")
+	}
+	f.rules = append(f.rules, rule)
+	return false, nil
+}
+
+func (f *probeRules) DeleteRule(_ iptablesutil.Table, chain iptablesutil.Chain, args ...string) error {
+	rule := string(chain) + " " + strings.Join(args, " ")
+	for i := range f.rules {
+		if f.rules[i] == rule {
+			f.rules = append(f.rules[:i], f.rules[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func TestIPv6RedirectSupported(t *testing.T) {
+	for _, canRedirect := range []bool{true, false} {
+		ipt := &probeRules{canRedirect: canRedirect}
+		if supported := ipv6RedirectSupported(ipt); supported != canRedirect {
+			t.Errorf("expected REDIRECT support %v, got %v", canRedirect, supported)
+		}
+		if len(ipt.rules) != 0 {
+			t.Errorf("expected the probe rule to be removed, got %q", ipt.rules)
+		}
+	}
+}
+
+func TestIPv6PortalArgs(t *testing.T) {
+	proxier, _ := newFakeProxier()
+	proxier.iptables = &probeRules{}
+	proxier.hostIP = net.ParseIP("fd00::1")
+
+	name := common.ServicePortName{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "svc"}, Port: "http"}
+	destIP := net.ParseIP("fd00:96::10")
+
+	for _, tc := range []struct {
+		name        string
+		proxyIP     net.IP
+		canRedirect bool
+		target      string
+	}{
+		{"redirect", net.IPv6unspecified, true, "-j REDIRECT --to-ports 40001"},
+		{"no redirect", net.IPv6unspecified, false, "-j DNAT --to-destination [fd00::1]:40001"},
+		{"bound", net.ParseIP("fd00::2"), true, "-j DNAT --to-destination [fd00::2]:40001"},
+	} {
+		proxier.canRedirect = tc.canRedirect
+
+		args := strings.Join(proxier.iptablesContainerPortalArgs(destIP, false, false, 80, localv1.Protocol_TCP, tc.proxyIP, 40001, name), " ")
+		if !strings.Contains(args, "-d fd00:96::10/128 ") || !strings.HasSuffix(args, tc.target) {
+			t.Errorf("%s: expected a rule to fd00:96::10/128 ending with %q, got %q", tc.name, tc.target, args)
+		}
+	}
+
+	args := strings.Join(proxier.iptablesHostPortalArgs(destIP, false, 80, localv1.Protocol_TCP, net.IPv6unspecified, 40001, name), " ")
+	if !strings.HasSuffix(args, "-j DNAT --to-destination [fd00::1]:40001") {
+		t.Errorf("expected the host portal to DNAT to the host IP, got %q", args)
+	}
+}
+
+func TestSameFamily(t *testing.T) {
+	proxier, _ := newFakeProxier()
+
+	for _, ipt := range []iptablesutil.Interface{&fakeRules{}, &probeRules{}} {
+		proxier.iptables = ipt
+		ipv6 := ipt.IsIPv6()
+
+		if !proxier.sameFamily(nil) {
+			t.Errorf("ipv6=%v: expected no IP to match", ipv6)
+		}
+		if proxier.sameFamily(net.ParseIP("10.96.0.10")) == ipv6 {
+			t.Errorf("ipv6=%v: unexpected match of an IPv4 address", ipv6)
+		}
+		if proxier.sameFamily(net.ParseIP("fd00:96::10")) != ipv6 {
+			t.Errorf("ipv6=%v: unexpected match of an IPv6 address", ipv6)
+		}
+	}
+
+	// the portals of the other family are skipped
+	proxier.iptables = &probeRules{}
+	rules, err := proxier.portalRules(portal{ip: net.ParseIP("10.96.0.10"), port: 80}, localv1.Protocol_TCP, net.IPv6unspecified, 40001, common.ServicePortName{})
+	if err != nil || len(rules) != 0 {
+		t.Errorf("expected no rules for an IPv4 portal, got %v, %v", rules, err)
+	}
+}
+
+func TestDNATDestination(t *testing.T) {
+	for ip, expected := range map[string]string{
+		"10.0.0.1":           "10.0.0.1:40001",
+		"::ffff:10.0.0.1":    "10.0.0.1:40001",
+		"fd00::1":            "[fd00::1]:40001",
+		"2001:db8::10:0:0:1": "[2001:db8::10:0:0:1]:40001",
+	} {
+		if dest := dnatDestination(net.ParseIP(ip), 40001); dest != expected {
+			t.Errorf("%s: expected %s, got %s", ip, expected, dest)
+		}
+	}
+}
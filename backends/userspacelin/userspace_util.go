@@ -27,15 +27,17 @@ import (
 	utilrand "k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/client-go/util/flowcontrol"
 	klog "k8s.io/klog/v2"
+	"k8s.io/utils/clock"
 	utilnet "k8s.io/utils/net"
 	"sigs.k8s.io/kpng/api/localv1"
-	"sigs.k8s.io/kpng/backends/iptables"
+	"sigs.k8s.io/kpng/backends/common"
+	iptablesutil "sigs.k8s.io/kpng/backends/iptables/util"
 )
 
 // ShouldSkipService checks if a given service should skip proxying
 func ShouldSkipService(service *localv1.Service) bool {
 	// if ClusterIP is "None" or empty, skip proxying
-	if !iptables.IsServiceIPSet(service) {
+	if !common.IsServiceIPSet(service) {
 		klog.V(3).Infof("Skipping service %s in namespace %s due to empty ClusterIPs", service.Name, service.Namespace)
 		return true
 	}
@@ -55,16 +57,15 @@ func isValidEndpoint(host string, port int) bool {
 // ToCIDR returns a host address of the form <ip-address>/32 for
 // IPv4 and <ip-address>/128 for IPv6
 func ToCIDR(ip net.IP) string {
-	len := 32
-	if ip.To4() == nil {
-		len = 128
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String() + "/32"
 	}
-	return fmt.Sprintf("%s/%d", ip.String(), len)
+	return ip.String() + "/128"
 }
 
 // BuildPortsToEndpointsMap builds a map of portname -> all ip:ports for that
 // portname. Explode Endpoints.Subsets[*] into this structure.
-// func BuildPortsToEndpointsMap(service []*iptables.ServicePortName, endpoints *kpng.Endpoint) map[string][]string {
+// func BuildPortsToEndpointsMap(service []*common.ServicePortName, endpoints *kpng.Endpoint) map[string][]string {
 // 	portsToEndpoints := map[string][]string{}
 // 	ipSet := endpoints.GetIPs()
 // 	for _, i := range ipSet.V4 {
@@ -83,21 +84,10 @@ func ToCIDR(ip net.IP) string {
 // 	return portsToEndpoints
 // }
 
-// GetLocalAddrs returns a list of all network addresses on the local system
+// GetLocalAddrs returns a list of all network addresses on the local system, cached until
+// netlink notifies an address change or the --local-addresses-ttl expires.
 func GetLocalAddrs() ([]net.IP, error) {
-	var localAddrs []net.IP
-	addrs, err := net.InterfaceAddrs()
-	if err != nil {
-		return nil, err
-	}
-	for _, addr := range addrs {
-		ip, _, err := net.ParseCIDR(addr.String())
-		if err != nil {
-			return nil, err
-		}
-		localAddrs = append(localAddrs, ip)
-	}
-	return localAddrs, nil
+	return iptablesutil.LocalAddrs()
 }
 
 // GetLocalAddrSet return a local IPSet.
@@ -215,42 +205,43 @@ type timer interface {
 	Sleep(d time.Duration)
 }
 
-// implement our timer in terms of std time.Timer.
-type realTimer struct {
-	timer *time.Timer
+// clockTimer implements our timer in terms of a clock, real or fake (in tests).
+type clockTimer struct {
+	clock clock.Clock
+	timer clock.Timer
 	next  time.Time
 }
 
-func (rt *realTimer) C() <-chan time.Time {
-	return rt.timer.C
+func (ct *clockTimer) C() <-chan time.Time {
+	return ct.timer.C()
 }
 
-func (rt *realTimer) Reset(d time.Duration) bool {
-	rt.next = time.Now().Add(d)
-	return rt.timer.Reset(d)
+func (ct *clockTimer) Reset(d time.Duration) bool {
+	ct.next = ct.clock.Now().Add(d)
+	return ct.timer.Reset(d)
 }
 
-func (rt *realTimer) Stop() bool {
-	return rt.timer.Stop()
+func (ct *clockTimer) Stop() bool {
+	return ct.timer.Stop()
 }
 
-func (rt *realTimer) Now() time.Time {
-	return time.Now()
+func (ct *clockTimer) Now() time.Time {
+	return ct.clock.Now()
 }
 
-func (rt *realTimer) Remaining() time.Duration {
-	return rt.next.Sub(time.Now())
+func (ct *clockTimer) Remaining() time.Duration {
+	return ct.next.Sub(ct.clock.Now())
 }
 
-func (rt *realTimer) Since(t time.Time) time.Duration {
-	return time.Since(t)
+func (ct *clockTimer) Since(t time.Time) time.Duration {
+	return ct.clock.Since(t)
 }
 
-func (rt *realTimer) Sleep(d time.Duration) {
-	time.Sleep(d)
+func (ct *clockTimer) Sleep(d time.Duration) {
+	ct.clock.Sleep(d)
 }
 
-var _ timer = &realTimer{}
+var _ timer = &clockTimer{}
 
 // NewBoundedFrequencyRunner creates a new BoundedFrequencyRunner instance,
 // which will manage runs of the specified function.
@@ -282,8 +273,13 @@ var _ timer = &realTimer{}
 // The maxInterval must be greater than or equal to the minInterval,  If the
 // caller passes a maxInterval less than minInterval, this function will panic.
 func newBoundedFrequencyRunner(name string, fn func(), minInterval, maxInterval time.Duration, burstRuns int) *BoundedFrequencyRunner {
-	timer := &realTimer{timer: time.NewTimer(0)} // will tick immediately
-	<-timer.C()                                  // consume the first tick
+	return newBoundedFrequencyRunnerWithClock(name, fn, minInterval, maxInterval, burstRuns, clock.RealClock{})
+}
+
+// newBoundedFrequencyRunnerWithClock is newBoundedFrequencyRunner with the time given by clk.
+func newBoundedFrequencyRunnerWithClock(name string, fn func(), minInterval, maxInterval time.Duration, burstRuns int, clk clock.Clock) *BoundedFrequencyRunner {
+	timer := &clockTimer{clock: clk, timer: clk.NewTimer(maxInterval)}
+	timer.Stop() // started by Loop
 	return construct(name, fn, minInterval, maxInterval, burstRuns, timer)
 }
 
@@ -0,0 +1,103 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userspacelin
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestBoundedFrequencyRunner(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+
+	runs := 0
+	bfr := newBoundedFrequencyRunnerWithClock("test", func() { runs++ }, time.Second, time.Minute, 1, fakeClock)
+
+	fired := func() bool {
+		select {
+		case <-bfr.timer.C():
+			return true
+		default:
+			return false
+		}
+	}
+	expectRuns := func(step string, expected int) {
+		t.Helper()
+		if runs != expected {
+			t.Fatalf("%s: %d runs, expected %d", step, runs, expected)
+		}
+	}
+
+	bfr.tryRun()
+	expectRuns("first run", 1)
+
+	// too soon: the run is deferred to the end of minInterval
+	bfr.tryRun()
+	expectRuns("second run", 1)
+	fakeClock.Step(time.Second - time.Millisecond)
+	if fired() {
+		t.Fatal("timer fired before minInterval")
+	}
+	fakeClock.Step(time.Millisecond)
+	if !fired() {
+		t.Fatal("timer not fired after minInterval")
+	}
+	bfr.tryRun()
+	expectRuns("deferred run", 2)
+
+	// a retry comes before the periodic run
+	bfr.RetryAfter(5 * time.Second)
+	bfr.doRetry()
+	fakeClock.Step(5 * time.Second)
+	if !fired() {
+		t.Fatal("timer not fired after the retry interval")
+	}
+	bfr.tryRun()
+	expectRuns("retry", 3)
+
+	// periodic run
+	fakeClock.Step(time.Minute - time.Millisecond)
+	if fired() {
+		t.Fatal("timer fired before maxInterval")
+	}
+	fakeClock.Step(time.Millisecond)
+	if !fired() {
+		t.Fatal("timer not fired after maxInterval")
+	}
+	bfr.tryRun()
+	expectRuns("periodic run", 4)
+
+	bfr.stop()
+	if fakeClock.HasWaiters() {
+		t.Error("timer still running after stop")
+	}
+}
+
+func TestToCIDR(t *testing.T) {
+	for ip, expected := range map[string]string{
+		"10.0.0.1":        "10.0.0.1/32",
+		"::ffff:10.0.0.1": "10.0.0.1/32",
+		"fd00::1":         "fd00::1/128",
+	} {
+		if cidr := ToCIDR(net.ParseIP(ip)); cidr != expected {
+			t.Errorf("%s: expected %s, got %s", ip, expected, cidr)
+		}
+	}
+}
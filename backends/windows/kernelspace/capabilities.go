@@ -0,0 +1,132 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelspace
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/kpng/client/backendcmd"
+)
+
+// preserveDIPVersion is the first HNS version (Windows Server 2022) supporting
+// the preserve-destination load balancer flag along with DSR.
+var preserveDIPVersion = hcn.VersionRanges{
+	hcn.VersionRange{MinVersion: hcn.Version{Major: 13, Minor: 0}, MaxVersion: hcn.Version{Major: math.MaxInt32, Minor: math.MaxInt32}},
+}
+
+// hnsCapabilities are the load balancer features of the HNS running on the
+// node, probed once at startup. Features missing on older Windows builds are
+// dropped from the load balancer flags instead of failing the policy creation.
+type hnsCapabilities struct {
+	version hcn.Version
+
+	dsr             bool
+	sessionAffinity bool
+	ipv6            bool
+	preserveDIP     bool
+}
+
+func probeHNSCapabilities(features hcn.SupportedFeatures) (c hnsCapabilities) {
+	globals, err := hcn.GetGlobals()
+	if err != nil {
+		// pre-1803 builds, nothing is supported
+		klog.ErrorS(err, "Failed to query the HNS version")
+		return
+	}
+
+	c = hnsCapabilities{
+		version:         globals.Version,
+		dsr:             features.DSR,
+		sessionAffinity: features.SessionAffinity,
+		ipv6:            features.IPv6DualStack,
+		preserveDIP:     features.DSR && inVersionRanges(globals.Version, preserveDIPVersion),
+	}
+
+	klog.InfoS("Probed HNS capabilities", "version", c.version, "dsr", c.dsr, "sessionAffinity", c.sessionAffinity,
+		"ipv6", c.ipv6, "preserveDIP", c.preserveDIP)
+	return
+}
+
+// hnsChecks checks the HNS API level required by the proxier, and the HNS networks of the pods
+// (see networkNames).
+func hnsChecks() []backendcmd.Check {
+	apiCheck := backendcmd.Check{Name: "HNS API"}
+	if globals, err := hcn.GetGlobals(); err != nil {
+		apiCheck.Err = fmt.Errorf("failed to query the HNS version: %w", err)
+	} else if features := hcn.GetSupportedFeatures(); !features.Api.V2 {
+		apiCheck.Err = fmt.Errorf("HNS %d.%d doesn't support the v2 API", globals.Version.Major, globals.Version.Minor)
+	} else {
+		apiCheck.Detail = fmt.Sprintf("%d.%d (dsr: %v, session affinity: %v, dual-stack: %v)", globals.Version.Major, globals.Version.Minor,
+			features.DSR, features.SessionAffinity, features.IPv6DualStack)
+	}
+
+	networkCheck := backendcmd.Check{Name: "HNS network"}
+	names, err := networkNames(*hnsNetworkNames, *cniConfDir)
+	if err != nil {
+		networkCheck.Err = err
+	} else {
+		networkCheck.Detail = strings.Join(names, ", ")
+		for _, name := range names {
+			if _, err := hcn.GetNetworkByName(name); err != nil {
+				networkCheck.Err = fmt.Errorf("HNS network %s: %w", name, err)
+				break
+			}
+		}
+	}
+
+	return []backendcmd.Check{apiCheck, networkCheck}
+}
+
+// filter removes the flags this HNS version doesn't support.
+func (c hnsCapabilities) filter(flags loadBalancerFlags) loadBalancerFlags {
+	if !c.dsr {
+		flags.isDSR = false
+	}
+	if !c.sessionAffinity {
+		flags.sessionAffinity = false
+	}
+	if !c.preserveDIP {
+		flags.preserveDIP = false
+		flags.useMUX = false
+	}
+	return flags
+}
+
+func inVersionRanges(v hcn.Version, ranges hcn.VersionRanges) bool {
+	for _, r := range ranges {
+		if versionLess(v, r.MinVersion) || versionLess(r.MaxVersion, v) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func versionLess(a, b hcn.Version) bool {
+	if a.Major != b.Major {
+		return a.Major < b.Major
+	}
+	return a.Minor < b.Minor
+}
@@ -3,13 +3,19 @@ package kernelspace
 // KubeProxyWinkernelConfiguration contains Windows/HNS settings for
 // the Kubernetes proxy server.
 type KubeProxyWinkernelConfiguration struct {
-	// networkName is the name of the network kube-proxy will use
-	// to create endpoints and policies
-	NetworkName string
+	// NetworkNames are the names of the HNS networks of the pods, the remote
+	// endpoints being created in the first one (see networkNames if empty)
+	NetworkNames []string
+	// CNIConfDir is the CNI configuration directory the networks are read
+	// from when they are not named
+	CNIConfDir string
 	// sourceVip is the IP address of the source VIP endpoint used for
 	// NAT when loadbalancing
 	SourceVip string
 	// enableDSR tells kube-proxy whether HNS policies should be created
 	// with DSR
 	EnableDSR bool
+	// nodePortAddresses are the CIDRs of the host IPs node ports are exposed on, or all
+	// the host IPs if empty
+	NodePortAddresses []string
 }
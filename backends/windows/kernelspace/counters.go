@@ -0,0 +1,189 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelspace
+
+import (
+	"sync"
+
+	"github.com/Microsoft/hcsshim"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+var (
+	servicePacketsDesc = prometheus.NewDesc(
+		"kpng_windows_service_packets_total",
+		"The packets received and sent by the local endpoints of the service, from the VFP counters of their HNS endpoints, by direction",
+		[]string{"namespace", "service", "direction"}, nil)
+
+	serviceDroppedPacketsDesc = prometheus.NewDesc(
+		"kpng_windows_service_dropped_packets_total",
+		"The packets dropped by the VFP ports of the local endpoints of the service, by direction (incoming or outgoing)",
+		[]string{"namespace", "service", "direction"}, nil)
+)
+
+// serviceCounters exports the counters of the HNS endpoints local to the node, by service. HNS
+// doesn't count the packets matching a load balancer policy, so the counters of the VFP port of the
+// endpoints are queried instead, at each scrape, and their increase since the previous scrape is
+// added to running totals, which don't go down when an endpoint is removed. An endpoint backing
+// several services is counted in only one of them (the first by namespace and name), so that the
+// totals of the services add up to the traffic of the endpoints.
+var serviceCounters = &serviceCountersCollector{
+	getStats: hcsshim.GetHNSEndpointStats,
+}
+
+type serviceCountersCollector struct {
+	getStats func(endpointID string) (*hcsshim.HNSEndpointStats, error)
+
+	mu        sync.Mutex
+	endpoints map[types.NamespacedName][]string
+
+	// the running totals, updated by Collect
+	totalsMu sync.Mutex
+	last     map[string]endpointCounters // the counters of the endpoints at the previous scrape, by ID
+	totals   map[types.NamespacedName]*endpointCounters
+}
+
+var _ prometheus.Collector = &serviceCountersCollector{}
+
+type endpointCounters struct {
+	received, sent, droppedIn, droppedOut uint64
+}
+
+func (c *endpointCounters) add(o endpointCounters) {
+	c.received += o.received
+	c.sent += o.sent
+	c.droppedIn += o.droppedIn
+	c.droppedOut += o.droppedOut
+}
+
+// since returns the increase of the counters since prev, or the counters if they went down (ie: the
+// VFP port was recreated).
+func (c endpointCounters) since(prev endpointCounters) endpointCounters {
+	if c.received < prev.received || c.sent < prev.sent || c.droppedIn < prev.droppedIn || c.droppedOut < prev.droppedOut {
+		return c
+	}
+	return endpointCounters{
+		received:   c.received - prev.received,
+		sent:       c.sent - prev.sent,
+		droppedIn:  c.droppedIn - prev.droppedIn,
+		droppedOut: c.droppedOut - prev.droppedOut,
+	}
+}
+
+func (c *serviceCountersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- servicePacketsDesc
+	ch <- serviceDroppedPacketsDesc
+}
+
+func (c *serviceCountersCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	endpoints := c.endpoints
+	c.mu.Unlock()
+
+	// count each endpoint in the first service it backs
+	owners := map[string]types.NamespacedName{}
+	for svc, ids := range endpoints {
+		for _, id := range ids {
+			if owner, ok := owners[id]; !ok || svc.String() < owner.String() {
+				owners[id] = svc
+			}
+		}
+	}
+
+	c.totalsMu.Lock()
+	defer c.totalsMu.Unlock()
+
+	if c.totals == nil {
+		c.totals = map[types.NamespacedName]*endpointCounters{}
+	}
+
+	last := make(map[string]endpointCounters, len(owners))
+	for id, svc := range owners {
+		stats, err := c.getStats(id)
+		if err != nil {
+			// the endpoint may have been deleted since the last sync
+			klog.V(4).InfoS("Failed to get the HNS endpoint stats", "hnsID", id, "err", err)
+			if prev, ok := c.last[id]; ok {
+				last[id] = prev
+			}
+			continue
+		}
+
+		counters := endpointCounters{
+			received:   stats.PacketsReceived,
+			sent:       stats.PacketsSent,
+			droppedIn:  stats.DroppedPacketsIncoming,
+			droppedOut: stats.DroppedPacketsOutgoing,
+		}
+		last[id] = counters
+
+		total := c.totals[svc]
+		if total == nil {
+			total = &endpointCounters{}
+			c.totals[svc] = total
+		}
+		total.add(counters.since(c.last[id]))
+	}
+	c.last = last
+
+	for svc, total := range c.totals {
+		if _, ok := endpoints[svc]; !ok {
+			// the service is gone
+			delete(c.totals, svc)
+			continue
+		}
+
+		counter := func(desc *prometheus.Desc, v uint64, direction string) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(v), svc.Namespace, svc.Name, direction)
+		}
+		counter(servicePacketsDesc, total.received, "received")
+		counter(servicePacketsDesc, total.sent, "sent")
+		counter(serviceDroppedPacketsDesc, total.droppedIn, "incoming")
+		counter(serviceDroppedPacketsDesc, total.droppedOut, "outgoing")
+	}
+}
+
+// setEndpoints records the local HNS endpoints of each service, as known by a sync. The endpoints
+// are looked up by IP in the endpoints queried from HNS, which are stored by ID and by IP.
+func (c *serviceCountersCollector) setEndpoints(endpointsMap EndpointsMap, hnsEndpoints map[string]*endpointsInfo) {
+	endpoints := make(map[types.NamespacedName][]string, len(endpointsMap))
+	for svc, svcEndpoints := range endpointsMap {
+		seen := map[string]bool{}
+		for _, ep := range *svcEndpoints {
+			if !ep.Local {
+				continue
+			}
+			for _, ip := range ep.IPs.All() {
+				hnsEp := hnsEndpoints[ip]
+				if hnsEp == nil || !hnsEp.isLocal || seen[hnsEp.hnsID] {
+					continue
+				}
+				seen[hnsEp.hnsID] = true
+				endpoints[svc] = append(endpoints[svc], hnsEp.hnsID)
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.endpoints = endpoints
+	c.mu.Unlock()
+}
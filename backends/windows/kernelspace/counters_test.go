@@ -0,0 +1,110 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelspace
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Microsoft/hcsshim"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// collectReceived returns the received packets counters collected, by service.
+func collectReceived(t *testing.T, c prometheus.Collector) map[string]float64 {
+	t.Helper()
+
+	ch := make(chan prometheus.Metric, 100)
+	c.Collect(ch)
+	close(ch)
+
+	received := map[string]float64{}
+	for m := range ch {
+		if m.Desc() != servicePacketsDesc {
+			continue
+		}
+		metric := &dto.Metric{}
+		if err := m.Write(metric); err != nil {
+			t.Fatal(err)
+		}
+		labels := map[string]string{}
+		for _, l := range metric.Label {
+			labels[l.GetName()] = l.GetValue()
+		}
+		if labels["direction"] == "received" {
+			received[labels["namespace"]+"/"+labels["service"]] = metric.Counter.GetValue()
+		}
+	}
+	return received
+}
+
+func TestServiceCounters(t *testing.T) {
+	stats := map[string]uint64{} // packets received, by endpoint ID
+	c := &serviceCountersCollector{
+		getStats: func(id string) (*hcsshim.HNSEndpointStats, error) {
+			received, ok := stats[id]
+			if !ok {
+				return nil, fmt.Errorf("endpoint %s not found", id)
+			}
+			return &hcsshim.HNSEndpointStats{PacketsReceived: received}, nil
+		},
+	}
+
+	a := types.NamespacedName{Namespace: "ns", Name: "a"}
+	b := types.NamespacedName{Namespace: "ns", Name: "b"}
+
+	expect := func(expected map[string]float64) {
+		t.Helper()
+		if received := collectReceived(t, c); !reflect.DeepEqual(received, expected) {
+			t.Errorf("expected %v, got %v", expected, received)
+		}
+	}
+
+	// ep2 backs both services, and is counted in the first one only
+	stats["ep1"], stats["ep2"], stats["ep3"] = 10, 20, 30
+	c.endpoints = map[types.NamespacedName][]string{a: {"ep1", "ep2"}, b: {"ep2", "ep3"}}
+	expect(map[string]float64{"ns/a": 30, "ns/b": 30})
+
+	stats["ep1"], stats["ep2"], stats["ep3"] = 15, 25, 35
+	expect(map[string]float64{"ns/a": 40, "ns/b": 35})
+
+	// the removed endpoints stay counted
+	delete(stats, "ep1")
+	c.endpoints = map[types.NamespacedName][]string{a: {"ep2"}, b: {"ep2", "ep3"}}
+	expect(map[string]float64{"ns/a": 40, "ns/b": 35})
+
+	// an endpoint failing to report its stats isn't counted twice
+	delete(stats, "ep3")
+	expect(map[string]float64{"ns/a": 40, "ns/b": 35})
+	stats["ep3"] = 40
+	expect(map[string]float64{"ns/a": 40, "ns/b": 40})
+
+	// recreated VFP ports restart from zero
+	stats["ep2"] = 5
+	expect(map[string]float64{"ns/a": 45, "ns/b": 40})
+
+	// the counters of the removed services are dropped
+	c.endpoints = map[types.NamespacedName][]string{b: {"ep2", "ep3"}}
+	stats["ep2"] = 10
+	expect(map[string]float64{"ns/b": 45})
+}
@@ -78,6 +78,59 @@ func (info *endpointsInfo) IsTerminating() bool {
 	return info.terminating
 }
 
+// endpointConditions returns the conditions of an endpoint. The endpoints without conditions are
+// ready (the servers not knowing them only send the ready endpoints).
+func endpointConditions(endpoint *localv1.Endpoint) (ready, serving, terminating bool) {
+	if c := endpoint.Conditions; c != nil {
+		return c.Ready, c.Serving, c.Terminating
+	}
+	return true, true, false
+}
+
+// newEndpointsInfos returns the infos of the endpoints of a service, not bound to HNS endpoints yet.
+func newEndpointsInfos(endpoints endpointsInfoByName, hns HCNUtils) []*endpointsInfo {
+	infos := make([]*endpointsInfo, 0, len(endpoints))
+	for _, e := range endpoints {
+		ready, serving, terminating := endpointConditions(e)
+		infos = append(infos, &endpointsInfo{
+			ip:          e.IPs.First(),
+			isLocal:     e.Local,
+			hns:         hns,
+			ready:       ready,
+			serving:     serving,
+			terminating: terminating,
+		})
+	}
+	return infos
+}
+
+// usableEndpoints returns the endpoints to load balance to, and the ones of the cluster-wide load
+// balancers among them. The cluster-wide load balancers use the ready endpoints or, when none is
+// ready, the serving terminating ones, so the connections still reach the pods being replaced
+// instead of failing. The local load balancers (preserve-destination, DSR) use the local endpoints
+// picked the same way among the local ones.
+func usableEndpoints(endpoints []*endpointsInfo) (usable []*endpointsInfo, cluster map[*endpointsInfo]bool) {
+	var hasReady, hasReadyLocal bool
+	for _, ep := range endpoints {
+		if ep.IsReady() {
+			hasReady = true
+			hasReadyLocal = hasReadyLocal || ep.GetIsLocal()
+		}
+	}
+
+	cluster = map[*endpointsInfo]bool{}
+	for _, ep := range endpoints {
+		draining := ep.IsServing() && ep.IsTerminating()
+		if ep.IsReady() || draining && !hasReady {
+			cluster[ep] = true
+		}
+		if cluster[ep] || draining && ep.GetIsLocal() && !hasReadyLocal {
+			usable = append(usable, ep)
+		}
+	}
+	return
+}
+
 // GetZoneHint returns the zone hint for the endpoint.
 func (info *endpointsInfo) GetZoneHints() sets.String {
 	return sets.String{}
@@ -323,10 +376,10 @@ func (em EndpointsMap) getLocalReadyEndpointIPs() map[types.NamespacedName]sets.
 		for _, endpointEntry := range *endpoints {
 			// Only add ready windowsEndpoint for health checking. Terminating windowsEndpoint may still serve traffic
 			// but the health check signal should fail if there are only terminating windowsEndpoint on a node.
-			//TODO: CHECK no endpoint.Topology and endpoint.Conditions Endpointslicecache.go
-			// if !ep.IsReady() {
-			// 	continue
-			// }
+			//TODO: CHECK no endpoint.Topology Endpointslicecache.go
+			if ready, _, _ := endpointConditions(endpointEntry); !ready {
+				continue
+			}
 
 			if endpointEntry.Local {
 				nsn := service
@@ -0,0 +1,105 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelspace
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+func TestEndpointConditions(t *testing.T) {
+	for _, test := range []struct {
+		conditions                  *localv1.EndpointConditions
+		ready, serving, terminating bool
+	}{
+		{nil, true, true, false},
+		{&localv1.EndpointConditions{Ready: true, Serving: true}, true, true, false},
+		{&localv1.EndpointConditions{Serving: true, Terminating: true}, false, true, true},
+	} {
+		ready, serving, terminating := endpointConditions(&localv1.Endpoint{Conditions: test.conditions})
+		if ready != test.ready || serving != test.serving || terminating != test.terminating {
+			t.Errorf("%v: expected %v/%v/%v, got %v/%v/%v", test.conditions,
+				test.ready, test.serving, test.terminating, ready, serving, terminating)
+		}
+	}
+}
+
+func TestUsableEndpoints(t *testing.T) {
+	// the endpoints are named by their IP: r(eady), d(raining: serving terminating), t(erminating,
+	// not serving), then l(ocal) or r(emote)
+	endpoint := func(ip string) *endpointsInfo {
+		info := &endpointsInfo{ip: ip, isLocal: ip[1] == 'l'}
+		switch ip[0] {
+		case 'r':
+			info.ready, info.serving = true, true
+		case 'd':
+			info.serving, info.terminating = true, true
+		case 't':
+			info.terminating = true
+		}
+		return info
+	}
+
+	for _, test := range []struct {
+		name      string
+		endpoints []string
+		usable    []string
+		cluster   []string
+	}{
+		{"no endpoints", nil, nil, nil},
+		{"ready", []string{"rl1", "rr1", "dl1", "dr1"}, []string{"rl1", "rr1"}, []string{"rl1", "rr1"}},
+		{"draining fallback", []string{"dl1", "dr1", "tl1"}, []string{"dl1", "dr1"}, []string{"dl1", "dr1"}},
+		{"terminating only", []string{"tl1", "tr1"}, nil, nil},
+		// the local load balancers fall back to the local draining endpoints
+		{"remote ready", []string{"rr1", "dl1", "dr1"}, []string{"dl1", "rr1"}, []string{"rr1"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			infos := make([]*endpointsInfo, 0, len(test.endpoints))
+			for _, ip := range test.endpoints {
+				infos = append(infos, endpoint(ip))
+			}
+
+			usable, cluster := usableEndpoints(infos)
+
+			ips := func(infos []*endpointsInfo) (ips []string) {
+				for _, info := range infos {
+					ips = append(ips, info.ip)
+				}
+				sort.Strings(ips)
+				return
+			}
+			clusterIPs := []string(nil)
+			for info := range cluster {
+				clusterIPs = append(clusterIPs, info.ip)
+			}
+			sort.Strings(clusterIPs)
+
+			if got := ips(usable); !reflect.DeepEqual(got, test.usable) {
+				t.Errorf("expected the usable endpoints %v, got %v", test.usable, got)
+			}
+			if !reflect.DeepEqual(clusterIPs, test.cluster) {
+				t.Errorf("expected the cluster endpoints %v, got %v", test.cluster, clusterIPs)
+			}
+		})
+	}
+}
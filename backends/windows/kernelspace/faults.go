@@ -0,0 +1,75 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelspace
+
+import (
+	"github.com/Microsoft/hcsshim/hcn"
+
+	"sigs.k8s.io/kpng/client/faultinject"
+)
+
+// faultyHCN injects faults in the HNS calls creating or deleting objects.
+type faultyHCN struct {
+	HCN
+	injector *faultinject.Injector
+}
+
+// withFaults wraps h with the global fault injector when it's enabled.
+func withFaults(h HCN) HCN {
+	if !faultinject.Enabled() {
+		return h
+	}
+	return &faultyHCN{HCN: h, injector: faultinject.Default()}
+}
+
+func (h *faultyHCN) CreateEndpoint(endpoint *hcn.HostComputeEndpoint, network *hcn.HostComputeNetwork) (*hcn.HostComputeEndpoint, error) {
+	if err := h.injector.Inject("hns create endpoint"); err != nil {
+		return nil, err
+	}
+	return h.HCN.CreateEndpoint(endpoint, network)
+}
+
+func (h *faultyHCN) CreateRemoteEndpoint(endpoint *hcn.HostComputeEndpoint, network *hcn.HostComputeNetwork) (*hcn.HostComputeEndpoint, error) {
+	if err := h.injector.Inject("hns create remote endpoint"); err != nil {
+		return nil, err
+	}
+	return h.HCN.CreateRemoteEndpoint(endpoint, network)
+}
+
+func (h *faultyHCN) CreateLoadBalancer(loadbalancer *hcn.HostComputeLoadBalancer) (*hcn.HostComputeLoadBalancer, error) {
+	if err := h.injector.Inject("hns create load balancer"); err != nil {
+		return nil, err
+	}
+	return h.HCN.CreateLoadBalancer(loadbalancer)
+}
+
+func (h *faultyHCN) DeleteLoadBalancer(loadbalancer *hcn.HostComputeLoadBalancer) error {
+	if err := h.injector.Inject("hns delete load balancer"); err != nil {
+		return err
+	}
+	return h.HCN.DeleteLoadBalancer(loadbalancer)
+}
+
+func (h *faultyHCN) DeleteEndpoint(endpoint *hcn.HostComputeEndpoint) error {
+	if err := h.injector.Inject("hns delete endpoint"); err != nil {
+		return err
+	}
+	return h.HCN.DeleteEndpoint(endpoint)
+}
@@ -0,0 +1,112 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelspace
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+
+	"sigs.k8s.io/kpng/client/faultinject"
+)
+
+// countingHCN counts the HNS calls creating or deleting objects
+type countingHCN struct {
+	HCN
+	calls int
+}
+
+func (h *countingHCN) CreateEndpoint(endpoint *hcn.HostComputeEndpoint, _ *hcn.HostComputeNetwork) (*hcn.HostComputeEndpoint, error) {
+	h.calls++
+	return endpoint, nil
+}
+
+func (h *countingHCN) CreateRemoteEndpoint(endpoint *hcn.HostComputeEndpoint, _ *hcn.HostComputeNetwork) (*hcn.HostComputeEndpoint, error) {
+	h.calls++
+	return endpoint, nil
+}
+
+func (h *countingHCN) CreateLoadBalancer(loadbalancer *hcn.HostComputeLoadBalancer) (*hcn.HostComputeLoadBalancer, error) {
+	h.calls++
+	return loadbalancer, nil
+}
+
+func (h *countingHCN) DeleteLoadBalancer(*hcn.HostComputeLoadBalancer) error {
+	h.calls++
+	return nil
+}
+
+func (h *countingHCN) DeleteEndpoint(*hcn.HostComputeEndpoint) error {
+	h.calls++
+	return nil
+}
+
+func TestFaultyHCN(t *testing.T) {
+	if h := (&countingHCN{}); withFaults(h) != HCN(h) {
+		t.Error("expected no fault injection when disabled")
+	}
+
+	calls := []struct {
+		op   string
+		call func(h HCN) error
+	}{
+		{"hns create endpoint", func(h HCN) error {
+			_, err := h.CreateEndpoint(&hcn.HostComputeEndpoint{}, &hcn.HostComputeNetwork{})
+			return err
+		}},
+		{"hns create remote endpoint", func(h HCN) error {
+			_, err := h.CreateRemoteEndpoint(&hcn.HostComputeEndpoint{}, &hcn.HostComputeNetwork{})
+			return err
+		}},
+		{"hns create load balancer", func(h HCN) error {
+			_, err := h.CreateLoadBalancer(&hcn.HostComputeLoadBalancer{})
+			return err
+		}},
+		{"hns delete load balancer", func(h HCN) error { return h.DeleteLoadBalancer(&hcn.HostComputeLoadBalancer{}) }},
+		{"hns delete endpoint", func(h HCN) error { return h.DeleteEndpoint(&hcn.HostComputeEndpoint{}) }},
+	}
+
+	for _, rate := range []float64{0, 1} {
+		inner := &countingHCN{}
+		h := &faultyHCN{HCN: inner, injector: faultinject.New(faultinject.Config{ErrorRate: rate, Seed: 1})}
+
+		for _, c := range calls {
+			err := c.call(h)
+
+			if rate == 0 {
+				if err != nil {
+					t.Errorf("%s: unexpected error: %v", c.op, err)
+				}
+				continue
+			}
+
+			injected := faultinject.Error{}
+			if !errors.As(err, &injected) || injected.Op != c.op {
+				t.Errorf("%s: expected an injected fault, got %v", c.op, err)
+			}
+		}
+
+		// the failed calls must not reach HNS
+		if expected := int(1-rate) * len(calls); inner.calls != expected {
+			t.Errorf("rate %v: expected %d calls to reach HNS, got %d", rate, expected, inner.calls)
+		}
+	}
+}
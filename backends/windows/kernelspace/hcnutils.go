@@ -29,6 +29,8 @@ import (
 	"k8s.io/klog/v2"
 
 	netutils "k8s.io/utils/net"
+
+	"sigs.k8s.io/kpng/client/auditlog"
 )
 
 type HCN interface {
@@ -276,11 +278,12 @@ func (hns hcnutils) getAllLoadBalancers() (map[loadBalancerIdentifier]*loadBalan
 	loadBalancers := make(map[loadBalancerIdentifier]*(loadBalancerInfo))
 	for _, lb := range lbs {
 		portMap := lb.PortMappings[0]
+		sessionAffinity := portMap.DistributionType == hcn.LoadBalancerDistributionSourceIP
 		if len(lb.FrontendVIPs) == 0 {
 			// Leave VIP uninitialized
-			id = loadBalancerIdentifier{protocol: uint16(portMap.Protocol), internalPort: portMap.InternalPort, externalPort: portMap.ExternalPort, endpointsCount: len(lb.HostComputeEndpoints)}
+			id = loadBalancerIdentifier{protocol: uint16(portMap.Protocol), internalPort: portMap.InternalPort, externalPort: portMap.ExternalPort, endpointsCount: len(lb.HostComputeEndpoints), sessionAffinity: sessionAffinity}
 		} else {
-			id = loadBalancerIdentifier{protocol: uint16(portMap.Protocol), internalPort: portMap.InternalPort, externalPort: portMap.ExternalPort, vip: lb.FrontendVIPs[0], endpointsCount: len(lb.HostComputeEndpoints)}
+			id = loadBalancerIdentifier{protocol: uint16(portMap.Protocol), internalPort: portMap.InternalPort, externalPort: portMap.ExternalPort, vip: lb.FrontendVIPs[0], endpointsCount: len(lb.HostComputeEndpoints), sessionAffinity: sessionAffinity}
 		}
 		loadBalancers[id] = &loadBalancerInfo{
 			hnsID: lb.Id,
@@ -294,10 +297,10 @@ func (hns hcnutils) getLoadBalancer(endpoints []endpointsInfo, flags loadBalance
 	var id loadBalancerIdentifier
 	vips := []string{}
 	if len(vip) > 0 {
-		id = loadBalancerIdentifier{protocol: protocol, internalPort: internalPort, externalPort: externalPort, vip: vip, endpointsCount: len(endpoints)}
+		id = loadBalancerIdentifier{protocol: protocol, internalPort: internalPort, externalPort: externalPort, vip: vip, endpointsCount: len(endpoints), sessionAffinity: flags.sessionAffinity}
 		vips = append(vips, vip)
 	} else {
-		id = loadBalancerIdentifier{protocol: protocol, internalPort: internalPort, externalPort: externalPort, endpointsCount: len(endpoints)}
+		id = loadBalancerIdentifier{protocol: protocol, internalPort: internalPort, externalPort: externalPort, endpointsCount: len(endpoints), sessionAffinity: flags.sessionAffinity}
 	}
 
 	if lb, found := previousLoadBalancers[id]; found {
@@ -393,6 +396,8 @@ func deleteAllHnsLoadBalancerPolicy() {
 		_, err = plist.Delete()
 		if err != nil {
 			klog.ErrorS(err, "Failed to delete policy list")
+		} else {
+			auditlog.Record("windows", auditlog.Delete, "hns-policy-list", plist.ID, "")
 		}
 	}
 
@@ -487,21 +492,59 @@ func (h *ihcn) GetLoadBalancerByID(loadBalancerId string) (*hcn.HostComputeLoadB
 }
 
 func (h *ihcn) CreateEndpoint(endpoint *hcn.HostComputeEndpoint, network *hcn.HostComputeNetwork) (*hcn.HostComputeEndpoint, error) {
-	return network.CreateEndpoint(endpoint)
+	ep, err := network.CreateEndpoint(endpoint)
+	if err == nil {
+		auditlog.Record("windows", auditlog.Add, "hns-endpoint", auditEndpoint(ep), "")
+	}
+	return ep, err
 }
 
 func (h *ihcn) CreateRemoteEndpoint(endpoint *hcn.HostComputeEndpoint, network *hcn.HostComputeNetwork) (*hcn.HostComputeEndpoint, error) {
-	return network.CreateRemoteEndpoint(endpoint)
+	ep, err := network.CreateRemoteEndpoint(endpoint)
+	if err == nil {
+		auditlog.Record("windows", auditlog.Add, "hns-remote-endpoint", auditEndpoint(ep), "")
+	}
+	return ep, err
 }
 
 func (h *ihcn) CreateLoadBalancer(loadbalancer *hcn.HostComputeLoadBalancer) (*hcn.HostComputeLoadBalancer, error) {
-	return loadbalancer.Create()
+	lb, err := loadbalancer.Create()
+	if err == nil {
+		auditlog.Record("windows", auditlog.Add, "hns-load-balancer", auditLoadBalancer(lb), "")
+	}
+	return lb, err
 }
 
 func (h *ihcn) DeleteLoadBalancer(loadbalancer *hcn.HostComputeLoadBalancer) error {
-	return loadbalancer.Delete()
+	err := loadbalancer.Delete()
+	if err == nil {
+		auditlog.Record("windows", auditlog.Delete, "hns-load-balancer", auditLoadBalancer(loadbalancer), "")
+	}
+	return err
 }
 
 func (h *ihcn) DeleteEndpoint(endpoint *hcn.HostComputeEndpoint) error {
-	return endpoint.Delete()
+	err := endpoint.Delete()
+	if err == nil {
+		auditlog.Record("windows", auditlog.Delete, "hns-endpoint", auditEndpoint(endpoint), "")
+	}
+	return err
+}
+
+// auditEndpoint describes an endpoint for the audit log
+func auditEndpoint(ep *hcn.HostComputeEndpoint) string {
+	ips := make([]string, 0, len(ep.IpConfigurations))
+	for _, cfg := range ep.IpConfigurations {
+		ips = append(ips, cfg.IpAddress)
+	}
+	return fmt.Sprintf("%s %v", ep.Id, ips)
+}
+
+// auditLoadBalancer describes a load balancer for the audit log
+func auditLoadBalancer(lb *hcn.HostComputeLoadBalancer) string {
+	ports := make([]string, 0, len(lb.PortMappings))
+	for _, pm := range lb.PortMappings {
+		ports = append(ports, fmt.Sprintf("%d:%d->%d", pm.Protocol, pm.ExternalPort, pm.InternalPort))
+	}
+	return fmt.Sprintf("%s vips=%v ports=%v endpoints=%v", lb.Id, lb.FrontendVIPs, ports, lb.HostComputeEndpoints)
 }
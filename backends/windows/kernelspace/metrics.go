@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 /*
 Copyright 2017 The Kubernetes Authors.
 
@@ -18,25 +21,118 @@ package kernelspace
 
 import (
 	"sync"
-	// "k8s.io/component-base/metrics/legacyregistry"
-	//	"k8s.io/kubernetes/pkg/proxy/metrics"
+	"time"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// the operation label values of the HNS metrics
+const (
+	opCreateEndpoint       = "create_endpoint"
+	opCreateRemoteEndpoint = "create_remote_endpoint"
+	opDeleteEndpoint       = "delete_endpoint"
+	opCreateLoadBalancer   = "create_load_balancer"
+	opDeleteLoadBalancer   = "delete_load_balancer"
+)
+
+var (
+	// HNSOperationDuration is the latency of the HNS calls creating or deleting objects, by operation.
+	HNSOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kpng_windows_hns_operation_duration_seconds",
+		Help:    "Latency of the HNS calls creating or deleting endpoints and load balancer policies, by operation",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"operation"})
+
+	// HNSOperationFailures counts the failed HNS calls creating or deleting objects, by operation.
+	HNSOperationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kpng_windows_hns_operation_failures_total",
+		Help: "The total number of failed HNS calls creating or deleting endpoints and load balancer policies, by operation",
+	}, []string{"operation"})
+
+	// HNSEndpoints is the number of endpoints of the HNS network, local or remote, as of the last sync.
+	HNSEndpoints = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kpng_windows_hns_endpoints",
+		Help: "The number of endpoints of the HNS network at the end of the last sync, by kind (local or remote)",
+	}, []string{"kind"})
+
+	// HNSLoadBalancers is the number of HNS load balancer policies, as of the last sync.
+	HNSLoadBalancers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kpng_windows_hns_load_balancers",
+		Help: "The number of HNS load balancer policies at the end of the last sync",
+	})
 )
 
 var registerMetricsOnce sync.Once
 
-// RegisterMetrics registers kube-proxy metrics for Windows modes.
+// RegisterMetrics registers the HNS metrics and the counters of the services.
 func RegisterMetrics() {
-
-	// TODO Jay commenting these out for now
-	/**	registerMetricsOnce.Do(func() {
-		legacyregistry.MustRegister(metrics.SyncProxyRulesLatency)
-		legacyregistry.MustRegister(metrics.SyncProxyRulesLastTimestamp)
-		legacyregistry.MustRegister(metrics.EndpointChangesPending)
-		legacyregistry.MustRegister(metrics.EndpointChangesTotal)
-		legacyregistry.MustRegister(metrics.ServiceChangesPending)
-		legacyregistry.MustRegister(metrics.ServiceChangesTotal)
-		legacyregistry.MustRegister(metrics.SyncProxyRulesLastQueuedTimestamp)
+	registerMetricsOnce.Do(func() {
+		prometheus.MustRegister(HNSOperationDuration, HNSOperationFailures, HNSEndpoints, HNSLoadBalancers, serviceCounters)
 	})
-	*/
+}
+
+// observeHNS records the latency of an HNS operation started at start, and its failure.
+func observeHNS(operation string, start time.Time, err error) {
+	HNSOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		HNSOperationFailures.WithLabelValues(operation).Inc()
+	}
+}
+
+// setHNSObjects sets the HNS object gauges from the endpoints and load balancers known by a sync.
+func setHNSObjects(endpoints map[string]*endpointsInfo, loadBalancers map[loadBalancerIdentifier]*loadBalancerInfo) {
+	// the endpoints are stored by ID and by IP
+	local, remote := map[string]bool{}, map[string]bool{}
+	for _, ep := range endpoints {
+		if ep.isLocal {
+			local[ep.hnsID] = true
+		} else {
+			remote[ep.hnsID] = true
+		}
+	}
+
+	HNSEndpoints.WithLabelValues("local").Set(float64(len(local)))
+	HNSEndpoints.WithLabelValues("remote").Set(float64(len(remote)))
+	HNSLoadBalancers.Set(float64(len(loadBalancers)))
+}
+
+// measuredHCN records the metrics of the HNS calls creating or deleting objects.
+type measuredHCN struct {
+	HCN
+}
+
+func (h measuredHCN) CreateEndpoint(endpoint *hcn.HostComputeEndpoint, network *hcn.HostComputeNetwork) (*hcn.HostComputeEndpoint, error) {
+	start := time.Now()
+	ep, err := h.HCN.CreateEndpoint(endpoint, network)
+	observeHNS(opCreateEndpoint, start, err)
+	return ep, err
+}
+
+func (h measuredHCN) CreateRemoteEndpoint(endpoint *hcn.HostComputeEndpoint, network *hcn.HostComputeNetwork) (*hcn.HostComputeEndpoint, error) {
+	start := time.Now()
+	ep, err := h.HCN.CreateRemoteEndpoint(endpoint, network)
+	observeHNS(opCreateRemoteEndpoint, start, err)
+	return ep, err
+}
+
+func (h measuredHCN) CreateLoadBalancer(loadbalancer *hcn.HostComputeLoadBalancer) (*hcn.HostComputeLoadBalancer, error) {
+	start := time.Now()
+	lb, err := h.HCN.CreateLoadBalancer(loadbalancer)
+	observeHNS(opCreateLoadBalancer, start, err)
+	return lb, err
+}
+
+func (h measuredHCN) DeleteLoadBalancer(loadbalancer *hcn.HostComputeLoadBalancer) error {
+	start := time.Now()
+	err := h.HCN.DeleteLoadBalancer(loadbalancer)
+	observeHNS(opDeleteLoadBalancer, start, err)
+	return err
+}
 
+func (h measuredHCN) DeleteEndpoint(endpoint *hcn.HostComputeEndpoint) error {
+	start := time.Now()
+	err := h.HCN.DeleteEndpoint(endpoint)
+	observeHNS(opDeleteEndpoint, start, err)
+	return err
 }
@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// cniNetworkNames returns the names of the networks configured in the CNI configuration files of
+// dir, in the order the runtime reads them (the first file configures the pods by default). The
+// HNS network created by the Windows plugins (win-bridge, win-overlay, sdnbridge, ...) is named
+// after the network.
+func cniNetworkNames(dir string) (names []string, err error) {
+	files := []string{}
+	for _, ext := range []string{"*.conf", "*.conflist", "*.json"} {
+		matches, err := filepath.Glob(filepath.Join(dir, ext))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	seen := map[string]bool{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		name, err := cniNetworkName(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CNI configuration %s: %w", file, err)
+		}
+
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no CNI network configured in %s", dir)
+	}
+	return
+}
+
+// cniNetworkName returns the name of the network of a CNI configuration (single plugin or list).
+func cniNetworkName(data []byte) (string, error) {
+	conf := struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return "", err
+	}
+	return conf.Name, nil
+}
+
+// networkNames returns the names of the HNS networks of the pods: the given ones, or the ones of
+// the KUBE_NETWORK environment variable (comma-separated), or the ones of the CNI configuration in
+// cniConfDir. The first one is the primary network, where the remote endpoints are created.
+func networkNames(names []string, cniConfDir string) ([]string, error) {
+	if len(names) != 0 {
+		return names, nil
+	}
+
+	if env := os.Getenv("KUBE_NETWORK"); env != "" {
+		for _, name := range strings.Split(env, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	}
+
+	if cniConfDir == "" {
+		return nil, fmt.Errorf("--network-name, KUBE_NETWORK and --cni-conf-dir not set")
+	}
+
+	names, err := cniNetworkNames(cniConfDir)
+	if err != nil {
+		return nil, fmt.Errorf("--network-name and KUBE_NETWORK not set, and %w", err)
+	}
+	return names, nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelspace
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNetworkNames(t *testing.T) {
+	dir := t.TempDir()
+	for file, conf := range map[string]string{
+		"10-bridge.conflist": `{"cniVersion": "0.3.1", "name": "cbr0", "plugins": [{"type": "win-bridge"}]}`,
+		"20-overlay.conf":    `{"cniVersion": "0.3.1", "name": "vxlan0", "type": "win-overlay"}`,
+		"30-bridge.conf":     `{"cniVersion": "0.3.1", "name": "cbr0", "type": "win-bridge"}`,
+		"README.md":          "not a configuration",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(conf), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv("KUBE_NETWORK", "")
+
+	names, err := networkNames(nil, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"cbr0", "vxlan0"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("from the CNI configuration: expected %v, got %v", expected, names)
+	}
+
+	t.Setenv("KUBE_NETWORK", "Calico, external")
+
+	names, err = networkNames(nil, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"Calico", "external"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("from KUBE_NETWORK: expected %v, got %v", expected, names)
+	}
+
+	names, err = networkNames([]string{"flag"}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"flag"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("from the flag: expected %v, got %v", expected, names)
+	}
+
+	t.Setenv("KUBE_NETWORK", "")
+
+	if _, err := networkNames(nil, t.TempDir()); err == nil {
+		t.Error("expected an error without any CNI configuration")
+	}
+}
@@ -22,7 +22,6 @@ package kernelspace
 import (
 	"fmt"
 	"net"
-	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -117,6 +116,17 @@ type Proxier struct {
 	hostMac           string
 	isDSR             bool
 	supportedFeatures hcn.SupportedFeatures
+	hnsCapabilities   hnsCapabilities
+	// nodePortAddresses restricts the node ports to the host IPs in these CIDRs
+	nodePortAddresses []string
+	networkInterfacer NetworkInterfacer
+	// lastNodePortIPs are the host IPs of the node ports at the last sync that could list them,
+	// nodePortIPsListed telling if one could
+	lastNodePortIPs   []string
+	nodePortIPsListed bool
+	// secondaryNetworks are the other HNS networks of the pods (on multi-network nodes), their
+	// local endpoints being load balanced like the ones of network
+	secondaryNetworks []hnsNetworkInfo
 }
 
 // BaseEndpointInfo contains base information that defines an endpoint.
@@ -232,21 +242,24 @@ func newHostNetworkService() (HCNUtils, hcn.SupportedFeatures) {
 	var h HCNUtils
 	supportedFeatures := hcn.GetSupportedFeatures()
 	if supportedFeatures.Api.V2 {
-		h = hcnutils{&ihcn{}}
+		// the injected faults are measured as failures
+		h = hcnutils{measuredHCN{withFaults(&ihcn{})}}
 	}
 
 	return h, supportedFeatures
 }
 
+// getNetworkName returns hnsNetworkName, or the primary HNS network of the flags if empty.
 func getNetworkName(hnsNetworkName string) (string, error) {
-	if len(hnsNetworkName) == 0 {
-		klog.V(3).InfoS("Flag --network-name not set, checking environment variable")
-		hnsNetworkName = os.Getenv("KUBE_NETWORK")
-		if len(hnsNetworkName) == 0 {
-			return "", fmt.Errorf("Environment variable KUBE_NETWORK and network-flag not initialized")
-		}
+	if len(hnsNetworkName) != 0 {
+		return hnsNetworkName, nil
 	}
-	return hnsNetworkName, nil
+
+	names, err := networkNames(*hnsNetworkNames, *cniConfDir)
+	if err != nil {
+		return "", err
+	}
+	return names[0], nil
 }
 
 func getNetworkInfo(hns HCNUtils, hnsNetworkName string) (*hnsNetworkInfo, error) {
@@ -294,10 +307,12 @@ func NewProxier(
 	// this will introspect the underlying kernel.
 	hns, supportedFeatures := newHostNetworkService()
 	// --network-name <-- this is often passed in by calico
-	hnsNetworkName, err := getNetworkName(config.NetworkName)
+	hnsNetworkNames, err := networkNames(config.NetworkNames, config.CNIConfDir)
 	if err != nil {
 		return nil, err
 	}
+	hnsNetworkName := hnsNetworkNames[0]
+	klog.InfoS("HNS networks", "primary", hnsNetworkName, "secondary", hnsNetworkNames[1:])
 
 	klog.V(3).InfoS("Cleaning up old HNS policy lists")
 
@@ -331,13 +346,15 @@ func NewProxier(
 
 	klog.V(1).InfoS("Hns Network loaded", "hnsNetworkInfo", hnsNetworkInfo)
 
+	capabilities := probeHNSCapabilities(supportedFeatures)
+
 	isDSR := config.EnableDSR
-	err = hcn.DSRSupported()
-	if isDSR && err != nil {
-		return nil, err
+	if isDSR && !capabilities.dsr {
+		klog.InfoS("DSR is not supported on this version of Windows, disabling it", "hnsVersion", capabilities.version)
+		isDSR = false
 	}
 
-	klog.InfoS("Enable DSR?", "isDSR", winkernelConfig.EnableDSR)
+	klog.InfoS("Enable DSR?", "isDSR", isDSR)
 
 	// Why do we need VIPs?
 
@@ -383,6 +400,16 @@ func NewProxier(
 	}
 
 	isIPv6 := netutils.IsIPv6(nodeIP)
+	if isIPv6 && !capabilities.ipv6 {
+		return nil, fmt.Errorf("IPv6 load balancers are not supported by this version of Windows (HNS %v)", capabilities.version)
+	}
+
+	for _, cidr := range config.NodePortAddresses {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("invalid nodeport address %q: %w", cidr, err)
+		}
+	}
+
 	myProxier := &Proxier{
 		endPointsRefCount: make(endPointsReferenceCountMap),
 		serviceMap:        make(ServicesSnapshot),
@@ -395,11 +422,15 @@ func NewProxier(
 		recorder:          recorder,
 		hns:               hns,
 		network:           *hnsNetworkInfo,
+		secondaryNetworks: unresolvedNetworks(hnsNetworkNames[1:]),
 		sourceVip:         *sourceVip,
 		hostMac:           hostMac,
 		isDSR:             isDSR,
 		supportedFeatures: supportedFeatures,
+		hnsCapabilities:   capabilities,
 		isIPv6Mode:        isIPv6,
+		nodePortAddresses: config.NodePortAddresses,
+		networkInterfacer: RealNetwork{},
 	}
 
 	ipFamily := v1.IPv4Protocol
@@ -481,11 +512,12 @@ type loadBalancerInfo struct {
 }
 
 type loadBalancerIdentifier struct {
-	protocol       uint16
-	internalPort   uint16
-	externalPort   uint16
-	vip            string
-	endpointsCount int
+	protocol        uint16
+	internalPort    uint16
+	externalPort    uint16
+	vip             string
+	endpointsCount  int
+	sessionAffinity bool
 }
 
 type loadBalancerFlags struct {
@@ -515,6 +547,81 @@ type remoteSubnetInfo struct {
 const NETWORK_TYPE_OVERLAY = "overlay"
 const NETWORK_TYPE_L2BRIDGE = "L2Bridge"
 
+// nodePortIPs returns the host IPs the node ports are exposed on, or nil for all the host IPs.
+func (proxier *Proxier) nodePortIPs() ([]string, error) {
+	if len(proxier.nodePortAddresses) == 0 {
+		return nil, nil
+	}
+
+	ips := []string{}
+
+	addresses, err := GetNodeAddresses(proxier.nodePortAddresses, proxier.networkInterfacer)
+	if err != nil {
+		return nil, err
+	}
+
+	zeroCIDR := IPv4ZeroCIDR
+	if proxier.isIPv6Mode {
+		zeroCIDR = IPv6ZeroCIDR
+	}
+	if addresses.Has(zeroCIDR) {
+		return nil, nil
+	}
+
+	for _, address := range addresses.List() {
+		if netutils.IsIPv6String(address) == proxier.isIPv6Mode {
+			ips = append(ips, address)
+		}
+	}
+	return ips, nil
+}
+
+// syncNodePortIPs returns the host IPs of the node ports (see nodePortIPs), or the ones of the
+// last sync if they can't be listed, so the node port load balancers stay on the same IPs. ok is
+// false if they were never listed.
+func (proxier *Proxier) syncNodePortIPs() (ips []string, ok bool) {
+	ips, err := proxier.nodePortIPs()
+	if err == nil {
+		proxier.lastNodePortIPs, proxier.nodePortIPsListed = ips, true
+		return ips, true
+	}
+
+	klog.ErrorS(err, "Failed to get the host IPs for node ports, keeping the previous ones", "nodePortAddresses", proxier.nodePortAddresses)
+	return proxier.lastNodePortIPs, proxier.nodePortIPsListed
+}
+
+// unresolvedNetworks returns the HNS networks of the names, to be resolved at the next sync (they
+// may be created later).
+func unresolvedNetworks(names []string) []hnsNetworkInfo {
+	networks := make([]hnsNetworkInfo, 0, len(names))
+	for _, name := range names {
+		networks = append(networks, hnsNetworkInfo{name: name})
+	}
+	return networks
+}
+
+// secondaryNetworksChanged refreshes the secondary HNS networks, and returns true if one of them
+// was removed or recreated since the last sync (the load balancers of its endpoints are stale).
+func (proxier *Proxier) secondaryNetworksChanged() (changed bool) {
+	for i, network := range proxier.secondaryNetworks {
+		updated, err := proxier.hns.getNetworkByName(network.name)
+		switch {
+		case err == nil:
+			proxier.secondaryNetworks[i] = *updated
+		case isNetworkNotFoundError(err):
+			proxier.secondaryNetworks[i] = hnsNetworkInfo{name: network.name}
+		default:
+			continue
+		}
+
+		if network.id != "" && network.id != proxier.secondaryNetworks[i].id {
+			klog.InfoS("The secondary HNS network is not present or has changed since the last sync, please check the CNI deployment", "hnsNetworkName", network.name)
+			changed = true
+		}
+	}
+	return
+}
+
 // This is where all of the hns save/restore calls happen.
 // assumes Proxier.mu is held
 func (proxier *Proxier) syncProxyRules() {
@@ -548,12 +655,22 @@ func (proxier *Proxier) syncProxyRules() {
 		return
 	}
 
+	if proxier.secondaryNetworksChanged() {
+		proxier.cleanupAllPolicies()
+		return
+	}
+
 	// We assume that if this was called, we really want to sync them,
 	// even if nothing changed in the meantime. In other words, callers are
 	// responsible for detecting no-op changes and not calling this function.
 	serviceUpdateResult := proxier.serviceMap.Update(proxier.serviceChanges)
 	endpointUpdateResult := proxier.endpointsMap.Update(proxier.endpointsChanges)
 
+	for _, svcInfo := range serviceUpdateResult.AffinityChanged {
+		klog.V(2).InfoS("Session affinity changed, recreating load balancers", "servicePortName", svcInfo.serviceNameString)
+		svcInfo.deleteAllHnsLoadBalancerPolicy()
+	}
+
 	staleServices := serviceUpdateResult.UDPStaleClusterIP
 	// merge stale services gathered from updateEndpointsMap
 	for _, svcPortName := range endpointUpdateResult.StaleServiceNames {
@@ -573,6 +690,21 @@ func (proxier *Proxier) syncProxyRules() {
 		klog.V(4).InfoS("No existing endpoints found in HNS")
 		queriedEndpoints = make(map[string]*(endpointsInfo))
 	}
+	for _, network := range proxier.secondaryNetworks {
+		if network.id == "" {
+			continue
+		}
+		networkEndpoints, err := hns.getAllEndpointsByNetwork(network.name)
+		if err != nil {
+			klog.ErrorS(err, "Querying HNS for endpoints failed", "hnsNetworkName", network.name)
+			return
+		}
+		for key, ep := range networkEndpoints {
+			if _, ok := queriedEndpoints[key]; !ok {
+				queriedEndpoints[key] = ep
+			}
+		}
+	}
 	queriedLoadBalancers, err := hns.getAllLoadBalancers()
 	if queriedLoadBalancers == nil {
 		klog.V(4).InfoS("No existing load balancers found in HNS")
@@ -582,6 +714,13 @@ func (proxier *Proxier) syncProxyRules() {
 		klog.ErrorS(err, "Querying HNS for load balancers failed")
 		return
 	}
+	// the host IPs of the node ports, if restricted by --nodeport-addresses
+	nodePortIPs, ok := proxier.syncNodePortIPs()
+	if !ok {
+		// programming the node ports on all the host IPs would expose them on unwanted ones
+		return
+	}
+
 	if strings.EqualFold(proxier.network.networkType, NETWORK_TYPE_OVERLAY) {
 		if _, ok := queriedEndpoints[proxier.sourceVip]; !ok {
 			_, err = newSourceVIP(hns, hnsNetworkName, proxier.sourceVip, proxier.hostMac, proxier.nodeIP.String())
@@ -643,23 +782,8 @@ func (proxier *Proxier) syncProxyRules() {
 
 			endpoints, ok := proxier.endpointsMap[svcName]
 			if ok {
-				for _, e := range *endpoints {
-					ep := &endpointsInfo{
-						ip:      e.IPs.First(),
-						isLocal: e.Local,
-						hns:     proxier.hns,
-						ready:   true,
-						serving: true, // TODO same as above?
-					}
-
-					if !ok {
-						klog.ErrorS(nil, "Failed to cast endpointsInfo", "serviceName", svcName)
-						continue
-					}
-
-					if !ep.IsReady() {
-						continue
-					}
+				usable, cluster := usableEndpoints(newEndpointsInfos(*endpoints, proxier.hns))
+				for _, ep := range usable {
 					var newHnsEndpoint *endpointsInfo
 					hnsNetworkName := proxier.network.name
 					var err error
@@ -757,7 +881,9 @@ func (proxier *Proxier) syncProxyRules() {
 					// Save the hnsId for reference
 					klog.V(1).InfoS("Hns endpoint resource", "endpointsInfo", newHnsEndpoint)
 
-					hnsEndpoints = append(hnsEndpoints, *newHnsEndpoint)
+					if cluster[ep] {
+						hnsEndpoints = append(hnsEndpoints, *newHnsEndpoint)
+					}
 					if newHnsEndpoint.GetIsLocal() {
 						hnsLocalEndpoints = append(hnsLocalEndpoints, *newHnsEndpoint)
 					} else {
@@ -798,7 +924,7 @@ func (proxier *Proxier) syncProxyRules() {
 
 			hnsLoadBalancer, err := hns.getLoadBalancer(
 				hnsEndpoints,
-				loadBalancerFlags{isDSR: proxier.isDSR, isIPv6: proxier.isIPv6Mode, sessionAffinity: sessionAffinityClientIP},
+				proxier.hnsCapabilities.filter(loadBalancerFlags{isDSR: proxier.isDSR, isIPv6: proxier.isIPv6Mode, sessionAffinity: sessionAffinityClientIP}),
 				sourceVip,
 				svcInfo.ClusterIP().String(),
 				Enum(svcInfo.Protocol()),
@@ -823,10 +949,32 @@ func (proxier *Proxier) syncProxyRules() {
 					nodePortEndpoints = hnsLocalEndpoints
 				}
 
-				if len(nodePortEndpoints) > 0 {
+				if len(nodePortEndpoints) > 0 && nodePortIPs != nil {
+					// one load balancer per selected host IP
+					svcInfo.nodePortIPs = make([]*externalIPInfo, 0, len(nodePortIPs))
+					for _, nodePortIP := range nodePortIPs {
+						hnsLoadBalancer, err := hns.getLoadBalancer(
+							nodePortEndpoints,
+							proxier.hnsCapabilities.filter(loadBalancerFlags{isDSR: svcInfo.localTrafficDSR, localRoutedVIP: true, sessionAffinity: sessionAffinityClientIP, isIPv6: proxier.isIPv6Mode}),
+							sourceVip,
+							nodePortIP,
+							Enum(svcInfo.Protocol()),
+							uint16(svcInfo.targetPort),
+							uint16(svcInfo.NodePort()),
+							queriedLoadBalancers,
+						)
+						if err != nil {
+							klog.ErrorS(err, "Policy creation failed")
+							continue
+						}
+
+						svcInfo.nodePortIPs = append(svcInfo.nodePortIPs, &externalIPInfo{ip: nodePortIP, hnsID: hnsLoadBalancer.hnsID})
+						klog.V(3).InfoS("Hns LoadBalancer resource created for nodePort resources", "clusterIP", svcInfo.ClusterIP(), "nodeIP", nodePortIP, "nodeport", svcInfo.NodePort(), "hnsID", hnsLoadBalancer.hnsID)
+					}
+				} else if len(nodePortEndpoints) > 0 {
 					hnsLoadBalancer, err := hns.getLoadBalancer(
 						nodePortEndpoints,
-						loadBalancerFlags{isDSR: svcInfo.localTrafficDSR, localRoutedVIP: true, sessionAffinity: sessionAffinityClientIP, isIPv6: proxier.isIPv6Mode},
+						proxier.hnsCapabilities.filter(loadBalancerFlags{isDSR: svcInfo.localTrafficDSR, localRoutedVIP: true, sessionAffinity: sessionAffinityClientIP, isIPv6: proxier.isIPv6Mode}),
 						sourceVip,
 						"",
 						Enum(svcInfo.Protocol()),
@@ -858,7 +1006,7 @@ func (proxier *Proxier) syncProxyRules() {
 					// Try loading existing policies, if already available
 					hnsLoadBalancer, err = hns.getLoadBalancer(
 						externalIPEndpoints,
-						loadBalancerFlags{isDSR: svcInfo.localTrafficDSR, sessionAffinity: sessionAffinityClientIP, isIPv6: proxier.isIPv6Mode},
+						proxier.hnsCapabilities.filter(loadBalancerFlags{isDSR: svcInfo.localTrafficDSR, sessionAffinity: sessionAffinityClientIP, isIPv6: proxier.isIPv6Mode}),
 						sourceVip,
 						externalIP.ip,
 						Enum(svcInfo.Protocol()),
@@ -887,7 +1035,7 @@ func (proxier *Proxier) syncProxyRules() {
 				if len(lbIngressEndpoints) > 0 {
 					hnsLoadBalancer, err := hns.getLoadBalancer(
 						lbIngressEndpoints,
-						loadBalancerFlags{isDSR: svcInfo.preserveDIP || svcInfo.localTrafficDSR, useMUX: svcInfo.preserveDIP, preserveDIP: svcInfo.preserveDIP, sessionAffinity: sessionAffinityClientIP, isIPv6: proxier.isIPv6Mode},
+						proxier.hnsCapabilities.filter(loadBalancerFlags{isDSR: svcInfo.preserveDIP || svcInfo.localTrafficDSR, useMUX: svcInfo.preserveDIP, preserveDIP: svcInfo.preserveDIP, sessionAffinity: sessionAffinityClientIP, isIPv6: proxier.isIPv6Mode}),
 						sourceVip,
 						lbIngressIP.ip,
 						Enum(svcInfo.Protocol()),
@@ -913,6 +1061,10 @@ func (proxier *Proxier) syncProxyRules() {
 		}
 	}
 
+	// queriedEndpoints and queriedLoadBalancers also hold the objects created by this sync
+	setHNSObjects(queriedEndpoints, queriedLoadBalancers)
+	serviceCounters.setEndpoints(proxier.endpointsMap, queriedEndpoints)
+
 	//metrics.SyncProxyRulesLastTimestamp.SetToCurrentTime()
 
 	// Update service healthchecks.  The endpoints list might include services that are
@@ -26,6 +26,7 @@ import (
 	netutils "k8s.io/utils/net"
 
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
 	"sigs.k8s.io/kpng/api/localv1"
 )
 
@@ -186,6 +187,12 @@ func (proxier *Proxier) newServiceInfo(port *localv1.PortMapping, service *local
 	targetPort = int(port.TargetPort)
 	//}
 
+	if preserveDIP && !proxier.hnsCapabilities.preserveDIP {
+		klog.V(2).InfoS("preserve-destination is not supported on this version of Windows, ignoring it",
+			"service", klog.KRef(service.Namespace, service.Name))
+		preserveDIP = false
+	}
+
 	info.preserveDIP = preserveDIP
 	info.targetPort = targetPort
 	info.hns = proxier.hns
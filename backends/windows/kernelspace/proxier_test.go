@@ -0,0 +1,71 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelspace
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// fakeNetwork is a NetworkInterfacer with a single interface, failing if err is set.
+type fakeNetwork struct {
+	addrs []net.Addr
+	err   error
+}
+
+func (n *fakeNetwork) Interfaces() ([]net.Interface, error) {
+	if n.err != nil {
+		return nil, n.err
+	}
+	return []net.Interface{{Name: "eth0"}}, nil
+}
+
+func (n *fakeNetwork) Addrs(*net.Interface) ([]net.Addr, error) { return n.addrs, nil }
+
+func TestSyncNodePortIPs(t *testing.T) {
+	network := &fakeNetwork{err: errors.New("interfaces unavailable")}
+	proxier := &Proxier{nodePortAddresses: []string{"10.0.0.0/24"}, networkInterfacer: network}
+
+	if ips, err := proxier.nodePortIPs(); ips != nil || err == nil {
+		t.Errorf("expected no IPs (not all of them) and an error, got %v, %v", ips, err)
+	}
+	if _, ok := proxier.syncNodePortIPs(); ok {
+		t.Error("expected no node port IPs before they could be listed")
+	}
+
+	network.err = nil
+	network.addrs = []net.Addr{
+		&net.IPNet{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(24, 32)},
+		&net.IPNet{IP: net.ParseIP("192.168.1.5"), Mask: net.CIDRMask(24, 32)},
+	}
+
+	expected := []string{"10.0.0.5"}
+	if ips, ok := proxier.syncNodePortIPs(); !ok || !reflect.DeepEqual(ips, expected) {
+		t.Errorf("expected %v, got %v (ok: %v)", expected, ips, ok)
+	}
+
+	// the load balancers stay on the previous IPs
+	network.err = errors.New("interfaces unavailable")
+	if ips, ok := proxier.syncNodePortIPs(); !ok || !reflect.DeepEqual(ips, expected) {
+		t.Errorf("expected the previous IPs %v, got %v (ok: %v)", expected, ips, ok)
+	}
+}
@@ -64,6 +64,7 @@ type serviceInfo struct {
 	loadBalancerIngressIPs []*loadBalancerIngressInfo
 	hnsID                  string
 	nodePorthnsID          string
+	nodePortIPs            []*externalIPInfo
 	policyApplied          bool
 	remoteEndpoint         *endpointsInfo
 	hns                    HCNUtils
@@ -83,6 +84,11 @@ func (svcInfo *serviceInfo) deleteAllHnsLoadBalancerPolicy() {
 	hns.deleteLoadBalancer(svcInfo.nodePorthnsID)
 	svcInfo.nodePorthnsID = ""
 
+	for _, nodePortIP := range svcInfo.nodePortIPs {
+		hns.deleteLoadBalancer(nodePortIP.hnsID)
+	}
+	svcInfo.nodePortIPs = nil
+
 	for _, externalIP := range svcInfo.externalIPs {
 		hns.deleteLoadBalancer(externalIP.hnsID)
 		externalIP.hnsID = ""
@@ -172,11 +178,13 @@ func (info *BaseServiceInfo) Protocol() v1.Protocol {
 
 // SessionAffinityType is part of the ServicePort interface.
 func (info *BaseServiceInfo) SessionAffinityType() v1.ServiceAffinity {
-	// TODO figure out how to plumb this up jay
-	return "None"
+	if info.sessionAffinity.ClientIP != nil {
+		return v1.ServiceAffinityClientIP
+	}
+	return v1.ServiceAffinityNone
 }
 
-// StickyMaxAgeSeconds is just a thing im hacking together to get this to compile..
+// StickyMaxAgeSeconds returns the ClientIP affinity timeout, 0 if there's no affinity.
 func (info *BaseServiceInfo) StickyMaxAgeSeconds() int {
 	return info.stickyMaxAgeSeconds
 }
@@ -247,21 +255,38 @@ func (sct *ServiceChangeTracker) newBaseServiceInfo(port *localv1.PortMapping, s
 	}
 
 	clusterIP := GetClusterIPByFamily(sct.ipFamily, service)
+	nodePort := 0
+	if service.HasNodePort(port) {
+		nodePort = int(port.NodePort)
+	}
+
 	info := &BaseServiceInfo{
 		clusterIP:         net.ParseIP(clusterIP),
 		port:              int(port.Port),
 		targetPort:        int(port.TargetPort),
 		protocol:          v1Proto,
-		nodePort:          int(port.NodePort),
+		nodePort:          nodePort,
 		nodeLocalExternal: nodeLocalExternal,
 		nodeLocalInternal: nodeLocalInternal,
 		// internalTrafficPolicy: service.Spec.InternalTrafficPolicy, //TODO : CHECK InternalTrafficPolicy
 		hintsAnnotation:          service.Annotations[v1.AnnotationTopologyAwareHints],
 		loadBalancerSourceRanges: getLoadbalancerSourceRanges(service.IPFilters),
-		loadBalancerIPs:          getLoadBalancerIPs(service.IPs.LoadBalancerIPs, sct.ipFamily),
+		loadBalancerIPs:          getLoadBalancerIPs(service.IPs.LoadBalancerVIPs(), sct.ipFamily),
 		sessionAffinity:          getSessionAffinity(service.SessionAffinity),
 	}
 
+	if clientIP := info.sessionAffinity.ClientIP; clientIP != nil {
+		info.stickyMaxAgeSeconds = int(clientIP.ClientIP.GetTimeoutSeconds())
+		if info.stickyMaxAgeSeconds == 0 {
+			info.stickyMaxAgeSeconds = int(v1.DefaultClientIPServiceAffinitySeconds)
+		}
+		if info.stickyMaxAgeSeconds != int(v1.DefaultClientIPServiceAffinitySeconds) {
+			// HNS load balancers only have a flag for source IP distribution, with a fixed timeout.
+			klog.V(2).InfoS("Custom session affinity timeout is not supported by HNS, using the default",
+				"service", klog.KRef(service.Namespace, service.Name), "timeoutSeconds", info.stickyMaxAgeSeconds)
+		}
+	}
+
 	// filter external ips, source ranges and ingress ips
 	// prior to dual stack services, this was considered an error, but with dual stack
 	// services, this is actually expected. Hence we downgraded from reporting by events
@@ -406,6 +431,9 @@ type UpdateServiceMapResult struct {
 	// UDPStaleClusterIP holds stale (no longer assigned to a Service) Service IPs that had UDP ports.
 	// Callers can use this to abort timeout-waits or clear connection-tracking information.
 	UDPStaleClusterIP sets.String
+	// AffinityChanged holds the previous service ports which session affinity changed, with load
+	// balancers that HNS needs to be recreated.
+	AffinityChanged []*serviceInfo
 }
 
 // ServiceMap maps a service to its ServicePort.
@@ -414,7 +442,7 @@ type ServicesSnapshot map[types.NamespacedName]serviceChange
 
 func (svcSnap *ServicesSnapshot) Update(changes *ServiceChangeTracker) (result UpdateServiceMapResult) {
 	result.UDPStaleClusterIP = sets.NewString()
-	svcSnap.apply(changes, result.UDPStaleClusterIP)
+	svcSnap.apply(changes, &result)
 
 	// TODO: If this will appear to be computationally expensive, consider
 	// computing this incrementally similarly to serviceMap.
@@ -434,30 +462,50 @@ func (svcSnap *ServicesSnapshot) Update(changes *ServiceChangeTracker) (result U
 	return result
 }
 
-func (svcSnap *ServicesSnapshot) apply(changes *ServiceChangeTracker, UDPStaleClusterIP sets.String) {
+func (svcSnap *ServicesSnapshot) apply(changes *ServiceChangeTracker, result *UpdateServiceMapResult) {
 	for svcName, change := range changes.items {
-		svcSnap.merge(svcName, change, UDPStaleClusterIP)
+		svcSnap.merge(svcName, change, result)
 	}
 	// clear changes after applying them to ServiceMap.
 	changes.items = make(map[types.NamespacedName]*serviceChange)
 	//metrics.ServiceChangesPending.Set(0)
 }
 
-func (svcSnap *ServicesSnapshot) merge(svcName types.NamespacedName, other *serviceChange, UDPStaleClusterIP sets.String) {
+func (svcSnap *ServicesSnapshot) merge(svcName types.NamespacedName, other *serviceChange, result *UpdateServiceMapResult) {
 	// existingPorts is going to store all identifiers of all services in `other` ServiceMap.
 	if other == nil {
 		for _, svcInfo := range (*svcSnap)[svcName] {
 
 			if string(svcInfo.Protocol()) == string(v1.ProtocolUDP) {
-				UDPStaleClusterIP.Insert(svcInfo.ClusterIP().String())
+				result.UDPStaleClusterIP.Insert(svcInfo.ClusterIP().String())
 			}
 		}
 		delete(*svcSnap, svcName)
 		return
 	}
+	result.AffinityChanged = append(result.AffinityChanged, affinityChanged((*svcSnap)[svcName], *other)...)
 	(*svcSnap)[svcName] = *other
 }
 
+// affinityChanged returns the previous service ports with load balancers which session affinity
+// changed.
+func affinityChanged(previous, current serviceChange) (changed []*serviceInfo) {
+	for portName, prevPort := range previous {
+		curPort, ok := current[portName]
+		if !ok || (prevPort.SessionAffinity().ClientIP == nil) == (curPort.SessionAffinity().ClientIP == nil) {
+			continue
+		}
+
+		prevInfo, ok := prevPort.(*serviceInfo)
+		if !ok || !prevInfo.policyApplied {
+			continue
+		}
+
+		changed = append(changed, prevInfo)
+	}
+	return
+}
+
 // serviceToServiceMap translates a single Service object to a ServiceMap.
 //
 // NOTE: service object should NOT be modified.
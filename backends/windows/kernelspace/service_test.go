@@ -0,0 +1,104 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelspace
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+func TestSessionAffinity(t *testing.T) {
+	tracker := NewServiceChangeTracker(newServiceInfo, v1.IPv4Protocol, nil)
+	snapshot := ServicesSnapshot{}
+	svcName := types.NamespacedName{Namespace: "ns", Name: "svc"}
+	portName := ServicePortName{NamespacedName: svcName, Port: "http", Protocol: localv1.Protocol_TCP}
+
+	// update sets the service with the ClientIP affinity timeout (none if negative), and returns
+	// its port and the update result
+	update := func(timeout int32) (*serviceInfo, UpdateServiceMapResult) {
+		t.Helper()
+
+		svc := &localv1.Service{
+			Namespace: svcName.Namespace,
+			Name:      svcName.Name,
+			Type:      "ClusterIP",
+			IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.0.0.1"), ExternalIPs: localv1.NewIPSet()},
+			Ports:     []*localv1.PortMapping{{Name: "http", Protocol: localv1.Protocol_TCP, Port: 80, TargetPort: 8080}},
+		}
+		if timeout >= 0 {
+			svc.SessionAffinity = &localv1.Service_ClientIP{ClientIP: &localv1.ClientIPAffinity{TimeoutSeconds: timeout}}
+		}
+
+		tracker.Update(svc)
+		result := snapshot.Update(tracker)
+
+		info, ok := snapshot[svcName][portName].(*serviceInfo)
+		if !ok {
+			t.Fatalf("no port %v in %v", portName, snapshot)
+		}
+		return info, result
+	}
+
+	expect := func(info *serviceInfo, affinity v1.ServiceAffinity, maxAge int) {
+		t.Helper()
+		if info.SessionAffinityType() != affinity || info.StickyMaxAgeSeconds() != maxAge {
+			t.Errorf("expected the affinity %s for %ds, got %s for %ds", affinity, maxAge,
+				info.SessionAffinityType(), info.StickyMaxAgeSeconds())
+		}
+	}
+
+	none, result := update(-1)
+	expect(none, v1.ServiceAffinityNone, 0)
+	if len(result.AffinityChanged) != 0 {
+		t.Errorf("expected no affinity change for a new service, got %v", result.AffinityChanged)
+	}
+
+	// the load balancers not programmed yet have nothing to recreate
+	clientIP, result := update(0)
+	expect(clientIP, v1.ServiceAffinityClientIP, int(v1.DefaultClientIPServiceAffinitySeconds))
+	if len(result.AffinityChanged) != 0 {
+		t.Errorf("expected no affinity change without load balancers, got %v", result.AffinityChanged)
+	}
+
+	clientIP.policyApplied = true
+
+	custom, result := update(60)
+	expect(custom, v1.ServiceAffinityClientIP, 60)
+	if len(result.AffinityChanged) != 0 {
+		t.Errorf("expected no affinity change when only the timeout changes, got %v", result.AffinityChanged)
+	}
+
+	// the snapshot is only updated, the load balancers are recreated by the sync
+	custom.policyApplied = true
+	custom.hnsID = "lb"
+
+	none, result = update(-1)
+	expect(none, v1.ServiceAffinityNone, 0)
+	if len(result.AffinityChanged) != 1 || result.AffinityChanged[0] != custom {
+		t.Errorf("expected the previous port to be recreated, got %v", result.AffinityChanged)
+	}
+	if custom.hnsID != "lb" {
+		t.Error("expected the load balancers not to be deleted by the snapshot update")
+	}
+}
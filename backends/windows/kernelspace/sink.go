@@ -33,6 +33,7 @@ import (
 	"sigs.k8s.io/kpng/client/localsink"
 	"sigs.k8s.io/kpng/client/localsink/decoder"
 	"sigs.k8s.io/kpng/client/localsink/filterreset"
+	"sigs.k8s.io/kpng/client/localsink/readyfilter"
 	"sigs.k8s.io/kpng/client/serviceevents"
 )
 
@@ -41,7 +42,9 @@ type Backend struct {
 }
 
 var (
-	_ decoder.Interface = &Backend{}
+	_ decoder.Interface   = &Backend{}
+	_ backendcmd.Checker  = &Backend{}
+	_ readyfilter.Backend = &Backend{}
 	//proxier       Provider
 	//proxierState  Proxier
 	proxier       *Proxier
@@ -93,6 +96,21 @@ var (
 		false,
 		"Set this flag to enable DSR")
 
+	hnsNetworkNames = flag.StringSlice(
+		"network-name",
+		nil,
+		"Names of the HNS networks of the pods, the remote endpoints being created in the first one (KUBE_NETWORK, comma-separated, or the networks of the CNI configuration if not set)")
+
+	cniConfDir = flag.String(
+		"cni-conf-dir",
+		`C:\etc\cni\net.d`,
+		"CNI configuration directory the HNS networks are read from when they are not named")
+
+	nodePortAddresses = flag.StringSlice(
+		"nodeport-addresses",
+		nil,
+		"CIDRs of the host IPs NodePort services are exposed on (all the host IPs if empty)")
+
 	winkernelConfig KubeProxyWinkernelConfiguration
 )
 
@@ -117,6 +135,24 @@ func New() *Backend {
 	return &Backend{}
 }
 
+// Probe checks that the HNS API and network can be used.
+func (s *Backend) Probe() error {
+	return backendcmd.Err(s.Checks())
+}
+
+// Checks checks the HNS API level and network.
+func (s *Backend) Checks() []backendcmd.Check {
+	return hnsChecks()
+}
+
+// WithTerminatingEndpoints returns true: the load balancers fall back to the serving terminating
+// endpoints (see usableEndpoints).
+func (s *Backend) WithTerminatingEndpoints() bool { return true }
+
+// RecordsChanges is true: the changes of the HNS endpoints, load balancers and policy lists are
+// recorded.
+func (s *Backend) RecordsChanges() bool { return true }
+
 func (s *Backend) Sink() localsink.Sink {
 	return filterreset.New(decoder.New(serviceevents.Wrap(s)))
 }
@@ -159,12 +195,14 @@ func (s *Backend) Setup() {
 		"sync period duration")
 
 	klog.Info("Starting Windows Kernel Proxier.")
+	RegisterMetrics()
 	klog.InfoS("  Cluster CIDR", "clusterCIDR", *clusterCIDR)
 	klog.InfoS("  Enable DSR", "enableDSR", *enableDSR)
 	klog.InfoS("  Masquerade all traffic", "masqueradeAll", *masqueradeAll)
 	klog.InfoS("  Masquerade bit", "masqueradeBit", *masqueradeBit)
 	klog.InfoS("  Node ip", "nodeip", *nodeip)
 	klog.InfoS("  Source VIP", "sourceVip", *sourceVip)
+	klog.InfoS("  NodePort addresses", "nodePortAddresses", *nodePortAddresses)
 
 	//proxyMode := getProxyMode(string(config.Mode), WindowsKernelCompatTester{})
 	//dualStackMode := getDualStackMode(config.Winkernel.NetworkName, DualStackCompatTester{})
@@ -172,8 +210,10 @@ func (s *Backend) Setup() {
 	//_ = proxyMode
 
 	winkernelConfig.EnableDSR = *enableDSR
-	winkernelConfig.NetworkName = "" // remove from config? proxier gets network name from KUBE_NETWORK env var
+	winkernelConfig.NetworkNames = *hnsNetworkNames
+	winkernelConfig.CNIConfDir = *cniConfDir
 	winkernelConfig.SourceVip = *sourceVip
+	winkernelConfig.NodePortAddresses = *nodePortAddresses
 
 	proxier, err = NewProxier(
 		syncPeriod,
@@ -194,9 +234,13 @@ func (s *Backend) Setup() {
 
 }
 
+// InitialSync lets the proxier sync once the initial state was received.
+func (s *Backend) InitialSync() {
+	proxier.setInitialized(true)
+}
+
 func (s *Backend) Sync() {
 	klog.V(0).InfoS("backend.Sync()")
-	proxier.setInitialized(true)
 	proxier.Sync()
 }
 
@@ -172,6 +172,20 @@ type NetworkInterfacer interface {
 	Interfaces() ([]net.Interface, error)
 }
 
+// RealNetwork implements the NetworkInterfacer interface for production code, just
+// wrapping the underlying net library function calls.
+type RealNetwork struct{}
+
+// Addrs wraps net.Interface.Addrs()
+func (RealNetwork) Addrs(intf *net.Interface) ([]net.Addr, error) {
+	return intf.Addrs()
+}
+
+// Interfaces wraps net.Interfaces()
+func (RealNetwork) Interfaces() ([]net.Interface, error) {
+	return net.Interfaces()
+}
+
 // IsZeroCIDR checks whether the input CIDR string is either
 // the IPv4 or IPv6 zero CIDR
 func IsZeroCIDR(cidr string) bool {
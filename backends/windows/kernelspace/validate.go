@@ -0,0 +1,47 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelspace
+
+import (
+	"fmt"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink/fullstate"
+	"sigs.k8s.io/kpng/client/localsink/validate"
+)
+
+var _ validate.Validator = &Backend{}
+
+// ValidateState rejects the services with SCTP ports, the HNS load balancers only balance TCP and UDP.
+func (s *Backend) ValidateState(state []*fullstate.ServiceEndpoints) (rejected []validate.Rejection) {
+	for _, seps := range state {
+		for _, port := range seps.Service.Ports {
+			if port.Protocol == localv1.Protocol_SCTP {
+				rejected = append(rejected, validate.Rejection{
+					Namespace: seps.Service.Namespace,
+					Name:      seps.Service.Name,
+					Reason:    fmt.Sprintf("port %d is SCTP, not supported by the HNS load balancers", port.Port),
+				})
+				break
+			}
+		}
+	}
+	return
+}
@@ -17,21 +17,21 @@ limitations under the License.
 package userspace
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
-	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
-	"k8s.io/apimachinery/pkg/util/runtime"
-
 	"k8s.io/apimachinery/pkg/types"
 	utilnet "k8s.io/apimachinery/pkg/util/net"
 	"k8s.io/klog/v2"
 	netutils "k8s.io/utils/net"
 	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink/supervise"
 )
 
 const allAvailableInterfaces string = ""
@@ -112,6 +112,7 @@ type Proxier struct {
 	loadBalancer   LoadBalancer
 	mu             sync.Mutex // protects serviceMap
 	serviceMap     map[ServicePortPortalName]*serviceInfo
+	servicePorts   map[servicePortKey]ServicePortName // names of the proxied ports, used by the service handlers only
 	syncPeriod     time.Duration
 	udpIdleTimeout time.Duration
 	numProxyLoops  int32 // use atomic ops to access this; mostly for testing
@@ -119,6 +120,14 @@ type Proxier struct {
 	hostIP         net.IP
 }
 
+// servicePortKey identifies a port of a service by its number and protocol, so a port renamed in
+// place is found under its previous name.
+type servicePortKey struct {
+	types.NamespacedName
+	port     int
+	protocol localv1.Protocol
+}
+
 // Used below.
 var localhostIPv4 = netutils.ParseIPSloppy("127.0.0.1")
 var localhostIPv6 = netutils.ParseIPSloppy("::1")
@@ -154,6 +163,7 @@ func createProxier(loadBalancer LoadBalancer, listenIP net.IP, netsh Interface,
 	return &Proxier{
 		loadBalancer:   loadBalancer,
 		serviceMap:     make(map[ServicePortPortalName]*serviceInfo),
+		servicePorts:   make(map[servicePortKey]ServicePortName),
 		syncPeriod:     syncPeriod,
 		udpIdleTimeout: udpIdleTimeout,
 		netsh:          netsh,
@@ -265,9 +275,10 @@ func (proxier *Proxier) addServicePortPortal(servicePortPortalName ServicePortPo
 
 	klog.V(2).InfoS("Proxying for service", "servicePortPortalName", servicePortPortalName.String(), "addr", net.JoinHostPort(listenIP, strconv.Itoa(port)), "protocol", protocol)
 	go func(service ServicePortPortalName, proxier *Proxier) {
-		defer runtime.HandleCrash()
 		atomic.AddInt32(&proxier.numProxyLoops, 1)
-		sock.ProxyLoop(service, si, proxier)
+		supervise.Run("proxy loop of "+service.String(), func() {
+			sock.ProxyLoop(service, si, proxier)
+		})
 		atomic.AddInt32(&proxier.numProxyLoops, -1)
 	}(servicePortPortalName, proxier)
 
@@ -291,9 +302,10 @@ func (proxier *Proxier) closeServicePortPortal(servicePortPortalName ServicePort
 	return nil
 }
 
-// getListenIPPortMap returns a slice of all listen IPs for a service.
-func getListenIPPortMap(service *localv1.Service, listenPort, nodePort int) map[string]int {
+// getListenIPPortMap returns a slice of all listen IPs for a service port.
+func getListenIPPortMap(service *localv1.Service, port *localv1.PortMapping) map[string]int {
 	listenIPPortMap := make(map[string]int)
+	listenPort := int(port.Port)
 
 	for _, ip := range service.IPs.GetClusterIPs().All() {
 		listenIPPortMap[ip] = listenPort
@@ -303,12 +315,12 @@ func getListenIPPortMap(service *localv1.Service, listenPort, nodePort int) map[
 		listenIPPortMap[ip] = listenPort
 	}
 
-	for _, ip := range service.IPs.GetLoadBalancerIPs().All() {
+	for _, ip := range service.IPs.LoadBalancerVIPs().All() {
 		listenIPPortMap[ip] = listenPort
 	}
 
-	if nodePort != 0 {
-		listenIPPortMap[allAvailableInterfaces] = nodePort
+	if service.HasNodePort(port) {
+		listenIPPortMap[allAvailableInterfaces] = int(port.NodePort)
 	}
 
 	return listenIPPortMap
@@ -329,9 +341,14 @@ func (proxier *Proxier) mergeService(service *localv1.Service) map[ServicePortPo
 		servicePort := &service.Ports[i]
 		// create a slice of all the source IPs to use for service port portals
 
-		listenIPPortMap := getListenIPPortMap(service, int((*servicePort).GetPort()), int((*servicePort).GetNodePort()))
+		listenIPPortMap := getListenIPPortMap(service, *servicePort)
 		protocol := (*servicePort).Protocol
 
+		// the portals of a renamed port listen on the same addresses, they must be closed first
+		for _, name := range proxier.stopRenamedPort(service, *servicePort) {
+			existingPortPortals[name] = true
+		}
+
 		for listenIP, listenPort := range listenIPPortMap {
 			servicePortPortalName := ServicePortPortalName{
 				NamespacedName: svcName,
@@ -375,12 +392,53 @@ func (proxier *Proxier) mergeService(service *localv1.Service) map[ServicePortPo
 				timeoutSeconds = int(service.GetClientIP().TimeoutSeconds)
 			}
 			proxier.loadBalancer.NewService(servicePortName, service.GetClientIP(), timeoutSeconds)
+			proxier.servicePorts[servicePortKey{svcName, int((*servicePort).GetPort()), protocol}] = servicePortName
 		}
 	}
 
 	return existingPortPortals
 }
 
+// stopRenamedPort closes the portals of the port renamed to servicePort, found by its number and
+// protocol under a name the service doesn't have anymore, and returns them so they are not unmerged.
+func (proxier *Proxier) stopRenamedPort(service *localv1.Service, servicePort *localv1.PortMapping) (closed []ServicePortPortalName) {
+	svcName := types.NamespacedName{Namespace: service.Namespace, Name: service.Name}
+	key := servicePortKey{svcName, int(servicePort.GetPort()), servicePort.Protocol}
+
+	renamed, ok := proxier.servicePorts[key]
+	if !ok || renamed.Port == servicePort.GetName() {
+		return nil
+	}
+	for _, port := range service.Ports {
+		if port.GetName() == renamed.Port {
+			// the name is still used, by another port
+			return nil
+		}
+	}
+
+	klog.V(1).InfoS("Service port renamed, stopping it under its previous name", "servicePortName", renamed.String(), "name", servicePort.GetName())
+
+	proxier.mu.Lock()
+	portals := map[ServicePortPortalName]*serviceInfo{}
+	for name, info := range proxier.serviceMap {
+		if name.NamespacedName == svcName && name.Port == renamed.Port {
+			portals[name] = info
+		}
+	}
+	proxier.mu.Unlock()
+
+	for name, info := range portals {
+		if err := proxier.closeServicePortPortal(name, info); err != nil {
+			klog.ErrorS(err, "Failed to close service port portal", "servicePortPortalName", name.String())
+		}
+		closed = append(closed, name)
+	}
+
+	proxier.loadBalancer.DeleteService(renamed)
+	delete(proxier.servicePorts, key)
+	return closed
+}
+
 func (proxier *Proxier) unmergeService(service *localv1.Service, existingPortPortals map[ServicePortPortalName]bool) {
 	if service == nil {
 		return
@@ -407,7 +465,7 @@ func (proxier *Proxier) unmergeService(service *localv1.Service, existingPortPor
 		servicePort := &service.Ports[i]
 		serviceName := ServicePortName{NamespacedName: svcName, Port: (*servicePort).GetName()}
 		// create a slice of all the source IPs to use for service port portals
-		listenIPPortMap := getListenIPPortMap(service, int((*servicePort).GetPort()), int((*servicePort).GetNodePort()))
+		listenIPPortMap := getListenIPPortMap(service, *servicePort)
 
 		for listenIP := range listenIPPortMap {
 			servicePortPortalName := ServicePortPortalName{
@@ -434,6 +492,11 @@ func (proxier *Proxier) unmergeService(service *localv1.Service, existingPortPor
 		// Only delete load balancer if all listen ips per name/port show inactive.
 		if !servicePortNameMap[serviceName] {
 			proxier.loadBalancer.DeleteService(serviceName)
+
+			key := servicePortKey{svcName, int((*servicePort).GetPort()), (*servicePort).Protocol}
+			if proxier.servicePorts[key] == serviceName {
+				delete(proxier.servicePorts, key)
+			}
 		}
 	}
 }
@@ -486,15 +549,19 @@ func (proxier *Proxier) OnEndpointsSynced() {
 	proxier.loadBalancer.OnEndpointsSynced()
 }
 
+// isTooManyFDsError returns true if the error is caused by the process running out of sockets
+// (the proxy can't recover from it).
 func isTooManyFDsError(err error) bool {
-	return strings.Contains(err.Error(), "too many open files")
+	return errors.Is(err, syscall.EMFILE) || errors.Is(err, wsaEMFILE)
 }
 
+// wsaEMFILE is the "too many open sockets" error of winsock.
+const wsaEMFILE syscall.Errno = 10024
+
+// isClosedError returns true if the error is caused by the use of a closed connection or listener
+// (ie: the proxy was stopped).
 func isClosedError(err error) bool {
-	// A brief discussion about handling closed error here:
-	// https://code.google.com/p/go/issues/detail?id=4373#c14
-	// TODO: maybe create a stoppable TCP listener that returns a StoppedError
-	return strings.HasSuffix(err.Error(), "use of closed network connection")
+	return errors.Is(err, net.ErrClosed)
 }
 
 func sameConfig(info *serviceInfo, service *localv1.Service, protocol localv1.Protocol, listenPort int) bool {
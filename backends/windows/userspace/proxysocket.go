@@ -26,9 +26,9 @@ import (
 	"time"
 
 	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink/supervise"
 
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/klog/v2"
 )
 
@@ -273,7 +273,7 @@ func (udp *udpProxySocket) getBackendConn(activeClients *clientCache, cliAddr ne
 		}
 		activeClients.clients[cliAddr.String()] = svrConn
 		go func(cliAddr net.Addr, svrConn net.Conn, activeClients *clientCache, service ServicePortPortalName, timeout time.Duration) {
-			defer runtime.HandleCrash()
+			defer supervise.Recover("UDP proxy of " + cliAddr.String())
 			udp.proxyClient(cliAddr, svrConn, activeClients, service, timeout)
 		}(cliAddr, svrConn, activeClients, service, timeout)
 	}
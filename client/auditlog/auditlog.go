@@ -0,0 +1,219 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package auditlog records the changes backends make to the kernel (rules, IPVS servers, HNS
+// policies...) in an append-only file of JSON lines, so the datapath changes can be
+// reconstructed after an incident.
+//
+// Each entry has the revision of the local state that triggered it: the number of syncs the
+// backend received.
+package auditlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+)
+
+// Operations
+const (
+	Add    = "add"
+	Update = "update"
+	Delete = "delete"
+)
+
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Revision uint64    `json:"revision"`
+	Backend  string    `json:"backend"`
+	Op       string    `json:"op"`
+	// Kind is the kind of kernel object (ie: "rule", "virtual-server", "hns-policy")
+	Kind    string `json:"kind"`
+	Object  string `json:"object"`
+	Service string `json:"service,omitempty"`
+}
+
+type Config struct {
+	Path       string
+	MaxSizeMB  int
+	MaxBackups int
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&c.Path, "audit-log", "", "Append the kernel changes to this file, as JSON lines (supported by to-iptables, to-nft, to-ipvs, to-ipvsfullstate, to-userspacelin and to-winkernel), disabled if empty")
+	flags.IntVar(&c.MaxSizeMB, "audit-log-max-size", 100, "Size in megabytes after which the audit log is rotated")
+	flags.IntVar(&c.MaxBackups, "audit-log-max-backups", 5, "Number of rotated audit logs to keep")
+}
+
+func (c *Config) Enabled() bool {
+	return c.Path != ""
+}
+
+// Backend is implemented by the backend commands recording their kernel changes (see Record).
+type Backend interface {
+	RecordsChanges() bool
+}
+
+// CheckBackend returns an error if the audit log is enabled and the backend named use doesn't
+// record its changes.
+func (c *Config) CheckBackend(use string, backend interface{}) error {
+	if !c.Enabled() {
+		return nil
+	}
+	if b, ok := backend.(Backend); ok && b.RecordsChanges() {
+		return nil
+	}
+	return fmt.Errorf("--audit-log is not supported by %s", use)
+}
+
+// Log is an audit log file.
+type Log struct {
+	cfg Config
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func Open(cfg Config) (l *Log, err error) {
+	l = &Log{cfg: cfg}
+	err = l.open()
+	return
+}
+
+func (l *Log) open() (err error) {
+	l.f, err = os.OpenFile(l.cfg.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return
+	}
+
+	stat, err := l.f.Stat()
+	if err != nil {
+		l.f.Close()
+		return
+	}
+
+	l.size = stat.Size()
+	return
+}
+
+// rotate moves the current file to path.1 (shifting the previous backups), and opens a new one.
+func (l *Log) rotate() (err error) {
+	l.f.Close()
+
+	os.Remove(fmt.Sprintf("%s.%d", l.cfg.Path, l.cfg.MaxBackups))
+	for n := l.cfg.MaxBackups - 1; n > 0; n-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.cfg.Path, n), fmt.Sprintf("%s.%d", l.cfg.Path, n+1))
+	}
+
+	if l.cfg.MaxBackups > 0 {
+		os.Rename(l.cfg.Path, l.cfg.Path+".1")
+	} else {
+		os.Remove(l.cfg.Path)
+	}
+
+	return l.open()
+}
+
+func (l *Log) Write(e Entry) (err error) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return os.ErrClosed
+	}
+
+	if maxSize := int64(l.cfg.MaxSizeMB) << 20; maxSize > 0 && l.size != 0 && l.size+int64(len(line)) > maxSize {
+		if err = l.rotate(); err != nil {
+			l.f = nil
+			return
+		}
+	}
+
+	n, err := l.f.Write(line)
+	l.size += int64(n)
+	return
+}
+
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return nil
+	}
+
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+var (
+	std      *Log
+	revision uint64
+)
+
+// Setup opens the log backends record to, if enabled by the configuration.
+func Setup(cfg *Config) (err error) {
+	if !cfg.Enabled() {
+		return
+	}
+
+	std, err = Open(*cfg)
+	return
+}
+
+// Enabled returns true when the changes are recorded.
+func Enabled() bool {
+	return std != nil
+}
+
+// Revision returns the revision of the local state, 0 if the syncs are not counted.
+func Revision() uint64 {
+	return atomic.LoadUint64(&revision)
+}
+
+// Record adds an entry to the audit log, if enabled.
+func Record(backend, op, kind, object, service string) {
+	if std == nil {
+		return
+	}
+
+	err := std.Write(Entry{
+		Time:     time.Now(),
+		Revision: Revision(),
+		Backend:  backend,
+		Op:       op,
+		Kind:     kind,
+		Object:   object,
+		Service:  service,
+	})
+	if err != nil {
+		klog.Error("failed to write the audit log: ", err)
+	}
+}
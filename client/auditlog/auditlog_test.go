@@ -0,0 +1,106 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auditlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readEntries(t *testing.T, path string) (entries []Entry) {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		e := Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	// a tiny max size to rotate on each write
+	l, err := Open(Config{Path: path, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.cfg.MaxSizeMB = 1
+
+	big := strings.Repeat("x", 600<<10)
+
+	for _, object := range []string{"a", "b", "c", "d"} {
+		if err := l.Write(Entry{Op: Add, Kind: "rule", Object: object + big}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	for file, expected := range map[string]string{path: "d", path + ".1": "c", path + ".2": "b"} {
+		entries := readEntries(t, file)
+		if len(entries) != 1 || entries[0].Object[:1] != expected {
+			t.Errorf("%s: expected only %s, got %d entries", file, expected, len(entries))
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("too many backups kept")
+	}
+}
+
+type recordingBackend bool
+
+func (b recordingBackend) RecordsChanges() bool { return bool(b) }
+
+func TestCheckBackend(t *testing.T) {
+	enabled := &Config{Path: "/var/log/kpng/audit.log"}
+
+	for _, tc := range []struct {
+		name    string
+		cfg     *Config
+		backend interface{}
+		fails   bool
+	}{
+		{"disabled", &Config{}, struct{}{}, false},
+		{"supported", enabled, recordingBackend(true), false},
+		{"unsupported", enabled, recordingBackend(false), true},
+		{"not implemented", enabled, struct{}{}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.CheckBackend("to-test", tc.backend)
+			if (err != nil) != tc.fails {
+				t.Errorf("expected failure: %v, got %v", tc.fails, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auditlog
+
+import (
+	"sync/atomic"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink"
+)
+
+// Sink counts the syncs sent to a backend to give their revision to the entries.
+type Sink struct {
+	localsink.Sink
+}
+
+var _ localsink.Sink = &Sink{}
+
+func NewSink(sink localsink.Sink) *Sink {
+	atomic.StoreUint64(&revision, 1)
+	return &Sink{Sink: sink}
+}
+
+func (s *Sink) Send(op *localv1.OpItem) (err error) {
+	err = s.Sink.Send(op)
+
+	if _, isSync := op.Op.(*localv1.OpItem_Sync); isSync {
+		atomic.AddUint64(&revision, 1)
+	}
+
+	return
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backendcmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Prober is implemented by the backends able to tell whether the host supports them, for the
+// automatic selection.
+type Prober interface {
+	// Probe returns nil if the backend can run on this host, or the reason why it can't.
+	Probe() error
+}
+
+// AutoPreference is the order in which the backends are tried by the automatic selection:
+// nft, then IPVS, then iptables (in any of its modes), then the eBPF proof of concept, and
+// userspacelin last.
+var AutoPreference = []string{"to-nft", "to-ipvs", "to-iptables", "to-ebpf", "to-userspacelin"}
+
+// Rejection is a backend skipped by the automatic selection.
+type Rejection struct {
+	Use string
+	Err error
+}
+
+// Select returns the first registered backend of preference supported by the host, and the
+// backends skipped before it. The backends not implementing Prober are assumed supported.
+func Select(preference []string) (selected UseCmd, cmd Cmd, rejected []Rejection, err error) {
+	for _, use := range preference {
+		useCmd, ok := Lookup(use)
+		if !ok {
+			continue
+		}
+
+		cmd = useCmd.New()
+
+		if prober, ok := cmd.(Prober); ok {
+			if err := prober.Probe(); err != nil {
+				rejected = append(rejected, Rejection{Use: use, Err: err})
+				continue
+			}
+		}
+
+		return useCmd, cmd, rejected, nil
+	}
+
+	msgs := make([]string, 0, len(rejected))
+	for _, r := range rejected {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", r.Use, r.Err))
+	}
+
+	err = errors.New("no supported backend found")
+	if len(msgs) != 0 {
+		err = fmt.Errorf("%w (%s)", err, strings.Join(msgs, "; "))
+	}
+	return UseCmd{}, nil, rejected, err
+}
+
+// Lookup returns the registered backend with the given name.
+func Lookup(use string) (UseCmd, bool) {
+	for _, useCmd := range registry {
+		if useCmd.Use == use {
+			return useCmd, true
+		}
+	}
+	return UseCmd{}, false
+}
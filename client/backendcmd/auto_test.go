@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backendcmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/pflag"
+
+	"sigs.k8s.io/kpng/client/localsink"
+)
+
+type testCmd struct{}
+
+func (testCmd) BindFlags(*pflag.FlagSet) {}
+func (testCmd) Sink() localsink.Sink     { return nil }
+
+type probedCmd struct {
+	testCmd
+	err error
+}
+
+func (c probedCmd) Probe() error { return c.err }
+
+func TestSelect(t *testing.T) {
+	defer func(r []UseCmd) { registry = r }(registry)
+	registry = nil
+
+	Register("to-unsupported", func() Cmd { return probedCmd{err: errors.New("missing kernel module")} })
+	Register("to-supported", func() Cmd { return probedCmd{} })
+	Register("to-unprobed", func() Cmd { return testCmd{} })
+
+	selected, cmd, rejected, err := Select([]string{"to-missing", "to-unsupported", "to-supported", "to-unprobed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selected.Use != "to-supported" || cmd == nil {
+		t.Errorf("expected to-supported, got %q", selected.Use)
+	}
+	if len(rejected) != 1 || rejected[0].Use != "to-unsupported" {
+		t.Errorf("expected to-unsupported to be rejected, got %v", rejected)
+	}
+
+	if selected, _, _, _ := Select([]string{"to-unprobed"}); selected.Use != "to-unprobed" {
+		t.Errorf("expected backends without probe to be supported, got %q", selected.Use)
+	}
+
+	if _, _, _, err := Select([]string{"to-unsupported"}); err == nil {
+		t.Error("expected an error without supported backend")
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backendcmd
+
+import (
+	"fmt"
+	"io"
+)
+
+// Check is the result of one probe of the host for a backend.
+type Check struct {
+	// Name is what was checked (ie: "IPVS kernel modules").
+	Name string
+	// Detail is what was found, if anything worth printing (ie: a version).
+	Detail string
+	// Err is nil if the check passed.
+	Err error
+}
+
+// Checker is implemented by the backends able to report each of their probes of the host, for
+// kpng check-backend. Their Probe should fail if any check fails.
+type Checker interface {
+	Checks() []Check
+}
+
+// Checks returns the checks of cmd: its Checks if it implements Checker, or its Probe as a
+// single check if it implements Prober.
+func Checks(cmd Cmd) []Check {
+	switch c := cmd.(type) {
+	case Checker:
+		return c.Checks()
+	case Prober:
+		return []Check{{Name: "probe", Err: c.Probe()}}
+	default:
+		return nil
+	}
+}
+
+// Err returns the error of the first failed check, prefixed by its name, nil if all passed.
+func Err(checks []Check) error {
+	for _, check := range checks {
+		if check.Err != nil {
+			return fmt.Errorf("%s: %w", check.Name, check.Err)
+		}
+	}
+	return nil
+}
+
+// WriteReport writes a line per check to w, and returns the number of failed checks.
+func WriteReport(w io.Writer, checks []Check) (failed int) {
+	for _, check := range checks {
+		status, detail := "PASS", check.Detail
+		if check.Err != nil {
+			status, detail = "FAIL", check.Err.Error()
+			failed++
+		}
+
+		if detail == "" {
+			fmt.Fprintf(w, "%s  %s\n", status, check.Name)
+		} else {
+			fmt.Fprintf(w, "%s  %s: %s\n", status, check.Name, detail)
+		}
+	}
+	return
+}
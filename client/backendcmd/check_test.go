@@ -0,0 +1,63 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backendcmd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type checkedCmd struct {
+	testCmd
+	checks []Check
+}
+
+func (c checkedCmd) Checks() []Check { return c.checks }
+
+func TestChecks(t *testing.T) {
+	missing := errors.New("missing kernel module")
+
+	if checks := Checks(testCmd{}); len(checks) != 0 {
+		t.Errorf("expected no checks without probe, got %v", checks)
+	}
+	if checks := Checks(probedCmd{err: missing}); len(checks) != 1 || checks[0].Err != missing {
+		t.Errorf("expected the probe as a single check, got %v", checks)
+	}
+
+	checks := Checks(checkedCmd{checks: []Check{
+		{Name: "kernel version", Detail: "5.15.0"},
+		{Name: "kernel modules", Err: missing},
+		{Name: "binaries"},
+	}})
+	if err := Err(checks); !errors.Is(err, missing) || err.Error() != "kernel modules: missing kernel module" {
+		t.Errorf("expected the first failure, got %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	if failed := WriteReport(out, checks); failed != 1 {
+		t.Errorf("expected 1 failed check, got %d", failed)
+	}
+
+	expected := `PASS  kernel version: 5.15.0
+FAIL  kernel modules: missing kernel module
+PASS  binaries
+`
+	if out.String() != expected {
+		t.Errorf("unexpected report:\n%s", out.String())
+	}
+}
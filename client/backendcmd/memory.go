@@ -0,0 +1,29 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backendcmd
+
+import "io"
+
+// MemoryKernel is implemented by the backends able to write their rules to in-memory fakes of
+// the kernel instead of the host, for kpng dev.
+type MemoryKernel interface {
+	// UseMemoryKernel makes the backend write to the fakes. It's called before Sink.
+	UseMemoryKernel()
+	// WriteKernelState writes the state of the fakes like the tools of the kernel list it (ie:
+	// iptables-save). It's called between the syncs.
+	WriteKernelState(w io.Writer) error
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package changetracker accumulates the changes of objects (services, endpoints...) received by
+// a backend between two syncs of its rules.
+package changetracker
+
+import "sync"
+
+// Change is the collapsed change of an object: its state before the first change since the last
+// sync, and after the last one. The zero value of V (ie: nil) is used for absent objects.
+type Change[V any] struct {
+	Previous V
+	Current  V
+}
+
+// Gauge is a metric set by the tracker (like prometheus.Gauge).
+type Gauge interface {
+	Set(float64)
+}
+
+// Counter is a metric incremented by the tracker (like prometheus.Counter).
+type Counter interface {
+	Inc()
+}
+
+// Tracker accumulates the changes of objects identified by a K key. It's safe for concurrent use.
+type Tracker[K comparable, V any] struct {
+	// Equal tells if two states of an object are the same, to drop the changes going back to the
+	// previous state. If nil, changes are never dropped.
+	Equal func(a, b V) bool
+
+	// Pending is set to the number of objects with pending changes, if not nil.
+	Pending Gauge
+	// Total is incremented on each recorded change, if not nil.
+	Total Counter
+
+	mu    sync.Mutex
+	items map[K]*Change[V]
+}
+
+// New returns a tracker dropping the changes back to the previous state, as told by equal (may
+// be nil).
+func New[K comparable, V any](equal func(a, b V) bool) *Tracker[K, V] {
+	return &Tracker[K, V]{Equal: equal}
+}
+
+// Record records a change of the object identified by key, from previous to current. previous
+// is only used for the first change since the last Take; the next ones only update the current
+// state. It returns true if the object has a pending change, false if it's back to its previous
+// state.
+func (t *Tracker[K, V]) Record(key K, previous, current V) (pending bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.Total != nil {
+		t.Total.Inc()
+	}
+
+	if t.items == nil {
+		t.items = make(map[K]*Change[V])
+	}
+
+	change, ok := t.items[key]
+	if !ok {
+		change = &Change[V]{Previous: previous}
+		t.items[key] = change
+	}
+
+	change.Current = current
+
+	pending = true
+	if t.Equal != nil && t.Equal(change.Previous, change.Current) {
+		// collapsed change had no effect
+		delete(t.items, key)
+		pending = false
+	}
+
+	t.updatePending()
+	return
+}
+
+// Take returns the pending changes, resetting the tracker.
+func (t *Tracker[K, V]) Take() (changes map[K]*Change[V]) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	changes = t.items
+	if changes == nil {
+		changes = map[K]*Change[V]{}
+	}
+
+	t.items = nil
+	t.updatePending()
+	return
+}
+
+// Len returns the number of objects with pending changes.
+func (t *Tracker[K, V]) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.items)
+}
+
+func (t *Tracker[K, V]) updatePending() {
+	if t.Pending != nil {
+		t.Pending.Set(float64(len(t.items)))
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package changetracker
+
+import (
+	"testing"
+)
+
+type gauge float64
+
+func (g *gauge) Set(v float64) { *g = gauge(v) }
+
+type counter int
+
+func (c *counter) Inc() { *c++ }
+
+func ptr(s string) *string { return &s }
+
+func equal(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func TestCollapse(t *testing.T) {
+	pending, total := gauge(0), counter(0)
+
+	tracker := New[string](equal)
+	tracker.Pending = &pending
+	tracker.Total = &total
+
+	// a: added then updated, collapsed into one add
+	tracker.Record("a", nil, ptr("a1"))
+	tracker.Record("a", ptr("a1"), ptr("a2"))
+
+	// b: updated then back to its previous state, dropped
+	if !tracker.Record("b", ptr("b1"), ptr("b2")) {
+		t.Error("expected b to have a pending change")
+	}
+	if tracker.Record("b", ptr("b2"), ptr("b1")) {
+		t.Error("expected b to have no pending change")
+	}
+
+	// c: added then deleted, dropped
+	tracker.Record("c", nil, ptr("c1"))
+	tracker.Record("c", ptr("c1"), nil)
+
+	// d: deleted
+	tracker.Record("d", ptr("d1"), nil)
+
+	if pending != 2 || total != 7 {
+		t.Errorf("expected 2 pending and 7 total changes, got %v and %v", pending, total)
+	}
+
+	changes := tracker.Take()
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %d", len(changes))
+	}
+
+	if a := changes["a"]; a == nil || a.Previous != nil || *a.Current != "a2" {
+		t.Errorf("expected a to be added with a2, got %+v", a)
+	}
+	if d := changes["d"]; d == nil || *d.Previous != "d1" || d.Current != nil {
+		t.Errorf("expected d to be deleted, got %+v", d)
+	}
+
+	if tracker.Len() != 0 || pending != 0 {
+		t.Errorf("expected no pending change after Take, got %d (gauge %v)", tracker.Len(), pending)
+	}
+
+	// the previous state is the one after the last Take
+	tracker.Record("a", ptr("a2"), ptr("a3"))
+	if a := tracker.Take()["a"]; a == nil || *a.Previous != "a2" || *a.Current != "a3" {
+		t.Errorf("expected a to be updated from a2 to a3, got %+v", a)
+	}
+}
+
+func TestNoEqual(t *testing.T) {
+	tracker := &Tracker[string, *string]{}
+
+	tracker.Record("a", nil, ptr("a1"))
+	if !tracker.Record("a", nil, nil) {
+		t.Error("expected changes not to be dropped without Equal")
+	}
+
+	if a := tracker.Take()["a"]; a == nil || a.Current != nil {
+		t.Errorf("expected a to be deleted, got %+v", a)
+	}
+}
@@ -32,6 +32,7 @@ import (
 	"k8s.io/klog/v2"
 
 	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/grpcflags"
 	"sigs.k8s.io/kpng/client/localsink"
 	"sigs.k8s.io/kpng/client/localsink/fullstate"
 	"sigs.k8s.io/kpng/client/tlsflags"
@@ -45,7 +46,8 @@ type FlagSet = tlsflags.FlagSet
 // Other needs can use `&EndpointsClient{...}` directly.
 func New(flags FlagSet) (epc *EndpointsClient) {
 	epc = &EndpointsClient{
-		TLS: &tlsflags.Flags{},
+		TLS:  &tlsflags.Flags{},
+		GRPC: &grpcflags.Flags{},
 	}
 	epc.ctx, epc.cancel = context.WithCancel(context.Background())
 	epc.DefaultFlags(flags)
@@ -62,8 +64,8 @@ type EndpointsClient struct {
 	// ErrorDelay is the delay before retrying after an error.
 	ErrorDelay time.Duration
 
-	// GRPCBuffer is the max size of a gRPC message
-	MaxMsgSize int
+	// GRPC holds the message sizes, keepalive and reconnection backoff of the connection.
+	GRPC *grpcflags.Flags
 
 	Sink localsink.Sink
 
@@ -81,9 +83,11 @@ func (epc *EndpointsClient) DefaultFlags(flags FlagSet) {
 
 	flags.DurationVar(&epc.ErrorDelay, "error-delay", 1*time.Second, "duration to wait before retrying after errors")
 
-	flags.IntVar(&epc.MaxMsgSize, "max-msg-size", 4<<20, "max gRPC message size")
-
 	epc.TLS.Bind(flags, "")
+	epc.GRPC.BindClient(flags, "")
+
+	// kept for compatibility, the last one set wins
+	flags.IntVar(&epc.GRPC.MaxRecvMsgSize, "max-msg-size", epc.GRPC.MaxRecvMsgSize, "max gRPC message size (deprecated: use --grpc-max-recv-msg-size)")
 }
 
 // Next sends the next diff to the sink, waiting for a new revision as needed.
@@ -107,7 +111,9 @@ retry:
 	}
 
 	err = epc.watch.Send(&localv1.WatchReq{
-		NodeName: nodeName,
+		NodeName:                 nodeName,
+		IPFamilies:               localsink.IPFamilies(epc.Sink),
+		WithTerminatingEndpoints: localsink.WithTerminatingEndpoints(epc.Sink),
 	})
 	if err != nil {
 		epc.postError()
@@ -167,10 +173,7 @@ func (epc *EndpointsClient) Context() context.Context {
 func (epc *EndpointsClient) DialContext(ctx context.Context) (conn *grpc.ClientConn, err error) {
 	klog.Info("connecting to ", epc.Target)
 
-	opts := append(
-		make([]grpc.DialOption, 0),
-		grpc.WithMaxMsgSize(epc.MaxMsgSize),
-	)
+	opts := epc.GRPC.DialOptions()
 
 	tlsCfg := epc.TLS.Config()
 	if tlsCfg == nil {
@@ -0,0 +1,209 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package drain lets backends stop accepting new connections to the node ports and the
+// externally routed IPs (external and load balancer IPs) of the node, while keeping the
+// established ones, so the node can be taken out of the external load balancers before a
+// maintenance. Draining is requested with `kpng drain`, through a unix socket.
+package drain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+)
+
+type Config struct {
+	// Socket to listen on for drain requests (disabled if empty).
+	Socket string
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&c.Socket, "drain-socket", "", "Unix socket to listen on for drain requests (see kpng drain; supported by to-ipvs and to-userspacelin), disabled if empty")
+}
+
+func (c *Config) Enabled() bool {
+	return c.Socket != ""
+}
+
+// Backend is implemented by the backend commands whose sinks refuse new connections while the
+// node is draining (see OnChange).
+type Backend interface {
+	SupportsDrain() bool
+}
+
+// CheckBackend returns an error if draining is enabled and the backend named use doesn't support it.
+func (c *Config) CheckBackend(use string, backend interface{}) error {
+	if !c.Enabled() {
+		return nil
+	}
+	if b, ok := backend.(Backend); ok && b.SupportsDrain() {
+		return nil
+	}
+	return fmt.Errorf("--drain-socket is not supported by %s", use)
+}
+
+type request struct {
+	// Drain is the state requested, nil to only get the current state.
+	Drain *bool
+}
+
+type response struct {
+	Draining bool
+	Error    string
+}
+
+var (
+	mu       sync.Mutex
+	draining bool
+	watchers []func(draining bool)
+
+	// notifyMu serializes the calls to the watchers, made without holding mu
+	notifyMu sync.Mutex
+)
+
+// Draining returns true if the node is draining.
+func Draining() bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return draining
+}
+
+// OnChange registers a function called when the node starts or stops draining. The watchers are
+// called one at a time, in the order of the changes.
+func OnChange(watcher func(draining bool)) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	watchers = append(watchers, watcher)
+}
+
+// Set starts or stops draining the node.
+func Set(drain bool) {
+	notifyMu.Lock()
+	defer notifyMu.Unlock()
+
+	mu.Lock()
+	if drain == draining {
+		mu.Unlock()
+		return
+	}
+
+	if drain {
+		klog.Info("draining the node: refusing new connections to node ports and external IPs")
+	} else {
+		klog.Info("stopped draining the node")
+	}
+
+	draining = drain
+	toNotify := append([]func(bool){}, watchers...)
+	mu.Unlock()
+
+	for _, watcher := range toNotify {
+		watcher(drain)
+	}
+}
+
+// Setup listens for drain requests if the configuration requires it.
+func Setup(cfg *Config) error {
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	os.Remove(cfg.Socket)
+
+	l, err := net.Listen("unix", cfg.Socket)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(cfg.Socket, 0600); err != nil {
+		l.Close()
+		return err
+	}
+
+	klog.Info("listening for drain requests on ", cfg.Socket)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				klog.Error("drain: failed to accept: ", err)
+				return
+			}
+
+			go handle(conn)
+		}
+	}()
+
+	return nil
+}
+
+func handle(conn net.Conn) {
+	defer conn.Close()
+
+	req := request{}
+	resp := response{}
+
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		resp.Error = fmt.Sprint("invalid request: ", err)
+	} else if req.Drain != nil {
+		Set(*req.Drain)
+	}
+
+	resp.Draining = Draining()
+
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		klog.Error("drain: failed to send response: ", err)
+	}
+}
+
+// Request asks the kpng listening on socket to start or stop draining (or nothing if drain is
+// nil), returning its state.
+func Request(ctx context.Context, socket string, drain *bool) (draining bool, err error) {
+	d := net.Dialer{}
+	conn, err := d.DialContext(ctx, "unix", socket)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if err = json.NewEncoder(conn).Encode(request{Drain: drain}); err != nil {
+		return
+	}
+
+	resp := response{}
+	if err = json.NewDecoder(conn).Decode(&resp); err != nil {
+		return
+	}
+
+	if resp.Error != "" {
+		err = fmt.Errorf("drain: %s", resp.Error)
+	}
+
+	return resp.Draining, err
+}
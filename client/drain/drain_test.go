@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drain
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRequest(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "drain.sock")
+
+	if err := Setup(&Config{Socket: socket}); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := []bool{}
+	OnChange(func(draining bool) { changes = append(changes, draining) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	yes, no := true, false
+
+	for _, step := range []struct {
+		drain    *bool
+		draining bool
+	}{
+		{nil, false},
+		{&yes, true},
+		{&yes, true},
+		{nil, true},
+		{&no, false},
+	} {
+		draining, err := Request(ctx, socket, step.drain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if draining != step.draining {
+			t.Errorf("expected draining=%v, got %v", step.draining, draining)
+		}
+		if Draining() != step.draining {
+			t.Errorf("expected Draining()=%v, got %v", step.draining, Draining())
+		}
+	}
+
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Errorf("expected watchers to be called on changes only, got %v", changes)
+	}
+}
+
+func TestWatcherReadsState(t *testing.T) {
+	states := []bool{}
+	OnChange(func(bool) { states = append(states, Draining()) })
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Set(true)
+		Set(false)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Set deadlocked calling a watcher reading the state")
+	}
+
+	if len(states) != 2 || !states[0] || states[1] {
+		t.Errorf("expected the watcher to see the new states, got %v", states)
+	}
+}
+
+type drainingBackend bool
+
+func (b drainingBackend) SupportsDrain() bool { return bool(b) }
+
+func TestCheckBackend(t *testing.T) {
+	enabled := &Config{Socket: "/run/kpng/drain.sock"}
+
+	for _, tc := range []struct {
+		name    string
+		cfg     *Config
+		backend interface{}
+		fails   bool
+	}{
+		{"disabled", &Config{}, struct{}{}, false},
+		{"supported", enabled, drainingBackend(true), false},
+		{"unsupported", enabled, drainingBackend(false), true},
+		{"not implemented", enabled, struct{}{}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.CheckBackend("to-test", tc.backend)
+			if (err != nil) != tc.fails {
+				t.Errorf("expected failure: %v, got %v", tc.fails, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package drift lets operators check whether the rules installed in the kernel still match the
+// ones the backend last wrote, without changing anything: on SIGUSR1, the backends implementing
+// Reporter compare both states and the differences are written to the log. A drift points to
+// another program (or a person) changing the rules of kpng.
+package drift
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+// Reporter is implemented by the backends able to compare the state they last wrote with the one
+// installed in the kernel. Drift must not change the kernel, and may be called during a sync.
+type Reporter interface {
+	Drift() (Report, error)
+}
+
+// Report is the difference between the state a backend last wrote and the one installed.
+type Report struct {
+	// Missing are the objects written by the backend that are not installed.
+	Missing []string
+	// Unexpected are the installed objects the backend didn't write.
+	Unexpected []string
+}
+
+// Drifted returns true if the installed state differs from the written one.
+func (r Report) Drifted() bool {
+	return len(r.Missing) != 0 || len(r.Unexpected) != 0
+}
+
+// Add adds the objects of other to r, prefixed by prefix (ie: the IP family).
+func (r *Report) Add(prefix string, other Report) {
+	for _, obj := range other.Missing {
+		r.Missing = append(r.Missing, prefix+obj)
+	}
+	for _, obj := range other.Unexpected {
+		r.Unexpected = append(r.Unexpected, prefix+obj)
+	}
+}
+
+func (r Report) String() string {
+	if !r.Drifted() {
+		return "no drift"
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%d missing, %d unexpected", len(r.Missing), len(r.Unexpected))
+	for _, obj := range r.Missing {
+		fmt.Fprintf(b, "\n- %s", obj)
+	}
+	for _, obj := range r.Unexpected {
+		fmt.Fprintf(b, "\n+ %s", obj)
+	}
+	return b.String()
+}
+
+// Compare returns the report of the installed objects against the written ones, in any order.
+// An object written twice must be installed twice.
+func Compare(written, installed []string) (r Report) {
+	count := make(map[string]int, len(written))
+	for _, obj := range written {
+		count[obj]++
+	}
+	for _, obj := range installed {
+		count[obj]--
+	}
+
+	for obj, n := range count {
+		for ; n > 0; n-- {
+			r.Missing = append(r.Missing, obj)
+		}
+		for ; n < 0; n++ {
+			r.Unexpected = append(r.Unexpected, obj)
+		}
+	}
+
+	sort.Strings(r.Missing)
+	sort.Strings(r.Unexpected)
+	return
+}
+
+var (
+	mu        sync.Mutex
+	reporters = map[string]Reporter{}
+	watchOnce sync.Once
+)
+
+// Watch logs the drift of backend, named name, on SIGUSR1 if it implements Reporter.
+func Watch(name string, backend any) {
+	reporter, ok := backend.(Reporter)
+	if !ok {
+		klog.V(1).Infof("backend %s can't report its drift", name)
+		return
+	}
+
+	mu.Lock()
+	reporters[name] = reporter
+	mu.Unlock()
+
+	watchOnce.Do(func() {
+		if len(signals) == 0 {
+			klog.Info("drift reports are not supported on this platform")
+			return
+		}
+
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, signals...)
+
+		go func() {
+			for sig := range ch {
+				klog.Info("got signal ", sig, ", reporting the drift of the kernel state")
+				Log()
+			}
+		}()
+	})
+}
+
+// Log writes the drift of the watched backends to the log.
+func Log() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	names := make([]string, 0, len(reporters))
+	for name := range reporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		report, err := reporters[name].Drift()
+		switch {
+		case err != nil:
+			klog.Errorf("drift of %s: %v", name, err)
+		case report.Drifted():
+			klog.Warningf("drift of %s: %s", name, report)
+		default:
+			klog.Infof("drift of %s: %s", name, report)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drift
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	r := Compare(
+		[]string{"nat -A KUBE-SERVICES -j KUBE-SVC-B", "nat -A KUBE-SERVICES -j KUBE-SVC-A", "nat -A KUBE-SVC-A -j RETURN", "nat -A KUBE-SVC-A -j RETURN"},
+		[]string{"nat -A KUBE-SERVICES -j KUBE-SVC-A", "nat -A KUBE-SVC-A -j RETURN", "filter -A KUBE-FORWARD -j DROP"},
+	)
+
+	expected := Report{
+		Missing:    []string{"nat -A KUBE-SERVICES -j KUBE-SVC-B", "nat -A KUBE-SVC-A -j RETURN"},
+		Unexpected: []string{"filter -A KUBE-FORWARD -j DROP"},
+	}
+	if !reflect.DeepEqual(r, expected) {
+		t.Errorf("expected %+v, got %+v", expected, r)
+	}
+
+	if r := Compare([]string{"a", "b"}, []string{"b", "a"}); r.Drifted() || r.String() != "no drift" {
+		t.Errorf("expected no drift, got %s", r)
+	}
+}
+
+func TestReportAdd(t *testing.T) {
+	r := Report{}
+	r.Add("IPv6 ", Report{Missing: []string{"nat chain KUBE-SVC-A"}, Unexpected: []string{"nat chain KUBE-SVC-B"}})
+
+	if expected := "1 missing, 1 unexpected\n- IPv6 nat chain KUBE-SVC-A\n+ IPv6 nat chain KUBE-SVC-B"; r.String() != expected {
+		t.Errorf("expected %q, got %q", expected, r.String())
+	}
+}
+
+type fakeReporter struct{ calls int }
+
+func (f *fakeReporter) Drift() (Report, error) {
+	f.calls++
+	return Report{}, nil
+}
+
+func TestWatch(t *testing.T) {
+	defer func() { reporters = map[string]Reporter{} }()
+
+	reporter := &fakeReporter{}
+	Watch("to-fake", reporter)
+	Watch("to-other", struct{}{})
+
+	Log()
+	if reporter.calls != 1 || len(reporters) != 1 {
+		t.Errorf("expected only the reporter to be called once, got %d calls and %d reporters", reporter.calls, len(reporters))
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package faultinject makes the calls of backends to the kernel (iptables, nft, netlink, HNS...)
+// randomly fail or slow down, to test how backends recover. It must never be enabled in production.
+package faultinject
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+)
+
+// Error is returned by the calls made to fail.
+type Error struct {
+	Op string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("injected fault in %s", e.Op)
+}
+
+type Config struct {
+	// ErrorRate is the probability for a call to fail, between 0 and 1.
+	ErrorRate float64
+	// Latency is the maximum delay added to each call (a random delay up to Latency).
+	Latency time.Duration
+	// Seed of the random source (0 to use the current time).
+	Seed int64
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.Float64Var(&c.ErrorRate, "inject-error-rate", 0, "Probability for a kernel call to fail (testing only)")
+	flags.DurationVar(&c.Latency, "inject-latency", 0, "Maximum latency added to kernel calls (testing only)")
+	flags.Int64Var(&c.Seed, "inject-seed", 0, "Seed of the fault injection (testing only)")
+
+	for _, name := range []string{"inject-error-rate", "inject-latency", "inject-seed"} {
+		flags.MarkHidden(name)
+	}
+}
+
+func (c *Config) Enabled() bool {
+	return c.ErrorRate > 0 || c.Latency > 0
+}
+
+// Injector injects faults.
+type Injector struct {
+	cfg Config
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func New(cfg Config) *Injector {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return &Injector{
+		cfg:  cfg,
+		rand: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Inject is to be called before the operation op. It waits for the injected latency, and
+// returns an error if the operation must fail.
+func (i *Injector) Inject(op string) error {
+	if i == nil {
+		return nil
+	}
+
+	i.mu.Lock()
+	var delay time.Duration
+	if i.cfg.Latency > 0 {
+		delay = time.Duration(i.rand.Int63n(int64(i.cfg.Latency)))
+	}
+	fail := i.rand.Float64() < i.cfg.ErrorRate
+	i.mu.Unlock()
+
+	time.Sleep(delay)
+
+	if fail {
+		klog.V(1).Info("injecting a fault in ", op)
+		return Error{Op: op}
+	}
+	return nil
+}
+
+var std *Injector
+
+// Setup enables the fault injection if the configuration requires it.
+func Setup(cfg *Config) {
+	if !cfg.Enabled() {
+		return
+	}
+
+	klog.Warningf("fault injection enabled (error rate: %v, latency: %v)", cfg.ErrorRate, cfg.Latency)
+	std = New(*cfg)
+}
+
+// Enabled returns true if faults are injected.
+func Enabled() bool {
+	return std != nil
+}
+
+// Default returns the global injector (nil if disabled).
+func Default() *Injector {
+	return std
+}
+
+// Inject calls Inject on the global injector (see Setup).
+func Inject(op string) error {
+	return std.Inject(op)
+}
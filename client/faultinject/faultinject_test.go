@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package faultinject
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestErrorRate(t *testing.T) {
+	for _, rate := range []float64{0, 0.1, 0.5, 1} {
+		i := New(Config{ErrorRate: rate, Seed: 42})
+
+		failures := 0
+		for n := 0; n < 1000; n++ {
+			err := i.Inject("test")
+			if err == nil {
+				continue
+			}
+
+			if !errors.As(err, &Error{}) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			failures++
+		}
+
+		if got := float64(failures) / 1000; got < rate-0.05 || got > rate+0.05 {
+			t.Errorf("rate %v: got %v failures", rate, got)
+		}
+	}
+}
+
+func TestLatency(t *testing.T) {
+	i := New(Config{Latency: 10 * time.Millisecond, Seed: 42})
+
+	start := time.Now()
+	for n := 0; n < 10; n++ {
+		if err := i.Inject("test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond+50*time.Millisecond {
+		t.Errorf("too much latency: %v", elapsed)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	var i *Injector
+	if err := i.Inject("test"); err != nil {
+		t.Fatal(err)
+	}
+}
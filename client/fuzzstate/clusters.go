@@ -0,0 +1,227 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fuzzstate
+
+import (
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink/fullstate"
+)
+
+// clusters are the seed states, as received from real clusters (the kubelet-managed and
+// cloud-specific details left out).
+var clusters = []func() []*fullstate.ServiceEndpoints{
+	kindCluster,
+	appCluster,
+	dualStackCluster,
+}
+
+func tcp(name string, port, targetPort int32) *localv1.PortMapping {
+	return &localv1.PortMapping{Name: name, Protocol: localv1.Protocol_TCP, Port: port, TargetPort: targetPort}
+}
+
+// kindCluster is a fresh kind cluster: the API server and CoreDNS.
+func kindCluster() []*fullstate.ServiceEndpoints {
+	return []*fullstate.ServiceEndpoints{
+		{
+			Service: &localv1.Service{
+				Namespace: "default",
+				Name:      "kubernetes",
+				Type:      "ClusterIP",
+				IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.96.0.1"), ExternalIPs: &localv1.IPSet{}},
+				Ports:     []*localv1.PortMapping{tcp("https", 443, 6443)},
+			},
+			Endpoints: []*localv1.Endpoint{
+				{IPs: localv1.NewIPSet("172.18.0.2"), Local: true},
+			},
+		},
+		{
+			Service: &localv1.Service{
+				Namespace: "kube-system",
+				Name:      "kube-dns",
+				Type:      "ClusterIP",
+				IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.96.0.10"), ExternalIPs: &localv1.IPSet{}},
+				Ports: []*localv1.PortMapping{
+					{Name: "dns", Protocol: localv1.Protocol_UDP, Port: 53, TargetPort: 53},
+					tcp("dns-tcp", 53, 53),
+					tcp("metrics", 9153, 9153),
+				},
+			},
+			Endpoints: []*localv1.Endpoint{
+				{IPs: localv1.NewIPSet("10.244.0.2"), Local: true},
+				{IPs: localv1.NewIPSet("10.244.0.3"), Local: true},
+			},
+		},
+	}
+}
+
+// appCluster is a cluster running applications behind an ingress controller, with the usual
+// variations: Local traffic policies, named target ports, headless and affinity services.
+func appCluster() []*fullstate.ServiceEndpoints {
+	return append(kindCluster(), []*fullstate.ServiceEndpoints{
+		{
+			Service: &localv1.Service{
+				Namespace: "ingress-nginx",
+				Name:      "ingress-nginx-controller",
+				Type:      "LoadBalancer",
+				IPs: &localv1.ServiceIPs{
+					ClusterIPs:      localv1.NewIPSet("10.96.120.7"),
+					ExternalIPs:     &localv1.IPSet{},
+					LoadBalancerIPs: localv1.NewIPSet("198.51.100.10"),
+				},
+				IPFilters: []*localv1.IPFilter{
+					{SourceRanges: []string{"192.0.2.0/24", "198.51.100.0/24"}},
+				},
+				Ports: []*localv1.PortMapping{
+					{Name: "http", Protocol: localv1.Protocol_TCP, Port: 80, TargetPortName: "http", NodePort: 31080},
+					{Name: "https", Protocol: localv1.Protocol_TCP, Port: 443, TargetPortName: "https", NodePort: 31443},
+				},
+				ExternalTrafficToLocal: true,
+			},
+			Endpoints: []*localv1.Endpoint{
+				{
+					IPs:   localv1.NewIPSet("10.244.1.12"),
+					Local: true,
+					PortOverrides: []*localv1.PortName{
+						{Name: "http", Port: 80},
+						{Name: "https", Port: 443},
+					},
+				},
+				{
+					IPs: localv1.NewIPSet("10.244.2.9"),
+					PortOverrides: []*localv1.PortName{
+						{Name: "http", Port: 80},
+						{Name: "https", Port: 443},
+					},
+				},
+			},
+		},
+		{
+			Service: &localv1.Service{
+				Namespace: "ingress-nginx",
+				Name:      "ingress-nginx-controller-admission",
+				Type:      "ClusterIP",
+				IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.96.33.150"), ExternalIPs: &localv1.IPSet{}},
+				Ports: []*localv1.PortMapping{
+					{Name: "https-webhook", Protocol: localv1.Protocol_TCP, Port: 443, TargetPortName: "webhook"},
+				},
+			},
+			Endpoints: []*localv1.Endpoint{
+				{IPs: localv1.NewIPSet("10.244.1.12"), Local: true, PortOverrides: []*localv1.PortName{{Name: "webhook", Port: 8443}}},
+				{IPs: localv1.NewIPSet("10.244.2.9"), PortOverrides: []*localv1.PortName{{Name: "webhook", Port: 8443}}},
+			},
+		},
+		{
+			Service: &localv1.Service{
+				Namespace: "monitoring",
+				Name:      "prometheus-operated",
+				Type:      "ClusterIP",
+				IPs:       &localv1.ServiceIPs{ClusterIPs: &localv1.IPSet{}, ExternalIPs: &localv1.IPSet{}, Headless: true},
+				Ports:     []*localv1.PortMapping{tcp("web", 9090, 9090)},
+			},
+			Endpoints: []*localv1.Endpoint{
+				{IPs: localv1.NewIPSet("10.244.2.31")},
+			},
+		},
+		{
+			Service: &localv1.Service{
+				Namespace: "shop",
+				Name:      "cart",
+				Type:      "NodePort",
+				IPs: &localv1.ServiceIPs{
+					ClusterIPs:  localv1.NewIPSet("10.96.81.4"),
+					ExternalIPs: localv1.NewIPSet("203.0.113.5"),
+				},
+				Ports: []*localv1.PortMapping{
+					{Name: "http", Protocol: localv1.Protocol_TCP, Port: 8080, TargetPort: 8080, NodePort: 30808},
+				},
+				SessionAffinity: &localv1.Service_ClientIP{
+					ClientIP: &localv1.ClientIPAffinity{TimeoutSeconds: 10800},
+				},
+				InternalTrafficToLocal: true,
+			},
+			Endpoints: []*localv1.Endpoint{
+				{IPs: localv1.NewIPSet("10.244.1.40"), Local: true},
+				{IPs: localv1.NewIPSet("10.244.1.41"), Local: true},
+				{IPs: localv1.NewIPSet("10.244.2.40")},
+			},
+		},
+		{
+			Service: &localv1.Service{
+				Namespace: "shop",
+				Name:      "payments",
+				Type:      "LoadBalancer",
+				IPs: &localv1.ServiceIPs{
+					ClusterIPs:      localv1.NewIPSet("10.96.81.9"),
+					ExternalIPs:     &localv1.IPSet{},
+					LoadBalancerIPs: localv1.NewIPSet("198.51.100.11"),
+				},
+				Ports: []*localv1.PortMapping{
+					tcp("grpc", 50051, 50051),
+					{Name: "stun", Protocol: localv1.Protocol_UDP, Port: 3478, TargetPort: 3478},
+				},
+				NoLoadBalancerNodePorts: true,
+			},
+		},
+	}...)
+}
+
+// dualStackCluster is a dual-stack cluster, with single and dual-stack services.
+func dualStackCluster() []*fullstate.ServiceEndpoints {
+	return []*fullstate.ServiceEndpoints{
+		{
+			Service: &localv1.Service{
+				Namespace: "default",
+				Name:      "kubernetes",
+				Type:      "ClusterIP",
+				IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.96.0.1"), ExternalIPs: &localv1.IPSet{}},
+				Ports:     []*localv1.PortMapping{tcp("https", 443, 6443)},
+			},
+			Endpoints: []*localv1.Endpoint{
+				{IPs: localv1.NewIPSet("172.18.0.2"), Local: true},
+			},
+		},
+		{
+			Service: &localv1.Service{
+				Namespace: "web",
+				Name:      "frontend",
+				Type:      "NodePort",
+				IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.96.14.2", "fd00:10:96::e02"), ExternalIPs: &localv1.IPSet{}},
+				Ports: []*localv1.PortMapping{
+					{Name: "http", Protocol: localv1.Protocol_TCP, Port: 80, TargetPort: 8080, NodePort: 30080},
+				},
+				ExternalTrafficToLocal: true,
+			},
+			Endpoints: []*localv1.Endpoint{
+				{IPs: localv1.NewIPSet("10.244.0.7", "fd00:10:244::7"), Local: true},
+				{IPs: localv1.NewIPSet("10.244.1.7", "fd00:10:244:1::7")},
+			},
+		},
+		{
+			Service: &localv1.Service{
+				Namespace: "web",
+				Name:      "backend-v6",
+				Type:      "ClusterIP",
+				IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("fd00:10:96::51"), ExternalIPs: &localv1.IPSet{}},
+				Ports:     []*localv1.PortMapping{tcp("api", 8000, 8000)},
+			},
+			Endpoints: []*localv1.Endpoint{
+				{IPs: localv1.NewIPSet("fd00:10:244::21"), Local: true},
+				{IPs: localv1.NewIPSet("fd00:10:244:1::21")},
+			},
+		},
+	}
+}
@@ -0,0 +1,383 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fuzzstate generates random but valid states (services and their endpoints) for the fuzz
+// tests of the backends: the states of real clusters, mutated by the fuzz data.
+//
+// A fuzz target takes the seed cluster and the mutations as arguments:
+//
+//	func FuzzRender(f *testing.F) {
+//		fuzzstate.Seeds(f)
+//		f.Fuzz(func(t *testing.T, seed uint8, data []byte) {
+//			state := fuzzstate.State(seed, data)
+//			// render the state and check the invariants
+//		})
+//	}
+package fuzzstate
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink/fullstate"
+)
+
+// Seeds adds the states of the clusters to the corpus of a fuzz target, as is and with every
+// mutation applied once.
+func Seeds(f *testing.F) {
+	allMutations := make([]byte, 0, 2*len(mutations))
+	for i := range mutations {
+		allMutations = append(allMutations, byte(i), byte(i*37))
+	}
+
+	for i := range clusters {
+		f.Add(uint8(i), []byte(nil))
+		f.Add(uint8(i), allMutations)
+	}
+}
+
+// State returns the state of the seed cluster (modulo the number of clusters) mutated by data,
+// sorted by service like the fullstate sink sends it.
+func State(seed uint8, data []byte) []*fullstate.ServiceEndpoints {
+	g := &generator{
+		data:  data,
+		state: clusters[int(seed)%len(clusters)](),
+		used:  map[int32]bool{},
+	}
+
+	for _, seps := range g.state {
+		for _, port := range seps.Service.Ports {
+			g.used[port.NodePort] = true
+		}
+	}
+
+	for len(g.data) != 0 {
+		mutations[int(g.next())%len(mutations)](g)
+	}
+
+	sort.Slice(g.state, func(i, j int) bool {
+		return g.state[i].Service.NamespacedName() < g.state[j].Service.NamespacedName()
+	})
+
+	return g.state
+}
+
+var (
+	serviceTypes = []string{"ClusterIP", "NodePort", "LoadBalancer"}
+	protocols    = []localv1.Protocol{localv1.Protocol_TCP, localv1.Protocol_UDP, localv1.Protocol_SCTP}
+)
+
+// generator mutates a state with the fuzz data. The IPs, node ports and names it allocates are
+// unique, as in a cluster; the rest comes from the data.
+type generator struct {
+	data  []byte
+	state []*fullstate.ServiceEndpoints
+
+	// count of the allocated names and addresses
+	n int
+	// used node ports
+	used map[int32]bool
+}
+
+// next consumes a byte of the data, 0 when all was consumed.
+func (g *generator) next() byte {
+	if len(g.data) == 0 {
+		return 0
+	}
+	b := g.data[0]
+	g.data = g.data[1:]
+	return b
+}
+
+func (g *generator) nextBool() bool {
+	return g.next()&1 == 1
+}
+
+// pick returns an index lower than n, -1 if n is 0.
+func (g *generator) pick(n int) int {
+	if n == 0 {
+		return -1
+	}
+	return int(g.next()) % n
+}
+
+func (g *generator) alloc() int {
+	g.n++
+	return g.n
+}
+
+func (g *generator) ip4(prefix string) string {
+	n := g.alloc()
+	return fmt.Sprintf("%s.%d.%d", prefix, n/250%256, n%250+1)
+}
+
+func (g *generator) ip6(prefix string) string {
+	return fmt.Sprintf("%s%x", prefix, g.alloc())
+}
+
+func (g *generator) nodePort() int32 {
+	for {
+		port := 30000 + int32(g.alloc()%2768)
+		if !g.used[port] {
+			g.used[port] = true
+			return port
+		}
+	}
+}
+
+// service returns a random service of the state, nil if there's none.
+func (g *generator) service() *fullstate.ServiceEndpoints {
+	i := g.pick(len(g.state))
+	if i < 0 {
+		return nil
+	}
+	return g.state[i]
+}
+
+// allocateNodePorts gives the ports of the service a node port, or removes them, as the API
+// server does when the type changes.
+func (g *generator) allocateNodePorts(svc *localv1.Service) {
+	for _, port := range svc.Ports {
+		switch {
+		case svc.Type == "NodePort", svc.Type == "LoadBalancer" && !svc.NoLoadBalancerNodePorts:
+			if port.NodePort == 0 {
+				port.NodePort = g.nodePort()
+			}
+		case svc.Type == "ClusterIP":
+			delete(g.used, port.NodePort)
+			port.NodePort = 0
+		}
+	}
+}
+
+func (g *generator) addPort(svc *localv1.Service) {
+	protocol := protocols[g.pick(len(protocols))]
+	port := int32(g.next())<<8 | int32(g.next())
+	if port == 0 {
+		port = 80
+	}
+
+	for _, p := range svc.Ports {
+		if p.Port == port && p.Protocol == protocol {
+			return // the API server rejects duplicate ports
+		}
+	}
+
+	p := &localv1.PortMapping{
+		Name:     fmt.Sprint("port-", g.alloc()),
+		Protocol: protocol,
+		Port:     port,
+	}
+	if g.nextBool() {
+		p.TargetPortName = p.Name + "-target"
+	} else {
+		p.TargetPort = 1 + int32(g.next())<<8 | int32(g.next())
+	}
+
+	svc.Ports = append(svc.Ports, p)
+	g.allocateNodePorts(svc)
+}
+
+func (g *generator) addEndpoint(seps *fullstate.ServiceEndpoints) {
+	ep := &localv1.Endpoint{
+		IPs:   localv1.NewIPSet(),
+		Local: g.nextBool(),
+	}
+
+	ips := seps.Service.IPs.ClusterIPs
+	if len(ips.V4) != 0 || len(ips.V6) == 0 {
+		ep.IPs.Add(g.ip4("10.245"))
+	}
+	if len(ips.V6) != 0 {
+		ep.IPs.Add(g.ip6("fd00:245::"))
+	}
+
+	for _, port := range seps.Service.Ports {
+		if port.TargetPortName != "" && g.nextBool() {
+			ep.PortOverrides = append(ep.PortOverrides, &localv1.PortName{Name: port.TargetPortName, Port: 1 + int32(g.next())})
+		}
+	}
+
+	seps.Endpoints = append(seps.Endpoints, ep)
+}
+
+// mutations of the state; the first byte of the data selects one, the next ones are its
+// parameters.
+var mutations = []func(g *generator){
+	// add a service
+	func(g *generator) {
+		n := g.alloc()
+		svc := &localv1.Service{
+			Namespace: "fuzz",
+			Name:      fmt.Sprint("svc-", n),
+			Type:      serviceTypes[g.pick(len(serviceTypes))],
+			IPs: &localv1.ServiceIPs{
+				ClusterIPs:  localv1.NewIPSet(g.ip4("10.100")),
+				ExternalIPs: &localv1.IPSet{},
+			},
+		}
+		if g.nextBool() {
+			svc.IPs.ClusterIPs.Add(g.ip6("fd00:100::"))
+		}
+
+		seps := &fullstate.ServiceEndpoints{Service: svc}
+		for i := g.pick(3); i >= 0; i-- {
+			g.addPort(svc)
+		}
+		for i := g.pick(4); i > 0; i-- {
+			g.addEndpoint(seps)
+		}
+
+		g.state = append(g.state, seps)
+	},
+	// delete a service
+	func(g *generator) {
+		if i := g.pick(len(g.state)); i >= 0 {
+			for _, port := range g.state[i].Service.Ports {
+				delete(g.used, port.NodePort)
+			}
+			g.state = append(g.state[:i], g.state[i+1:]...)
+		}
+	},
+	// add a port
+	func(g *generator) {
+		if seps := g.service(); seps != nil {
+			g.addPort(seps.Service)
+		}
+	},
+	// delete a port
+	func(g *generator) {
+		if seps := g.service(); seps != nil {
+			svc := seps.Service
+			if i := g.pick(len(svc.Ports)); i >= 0 {
+				delete(g.used, svc.Ports[i].NodePort)
+				svc.Ports = append(svc.Ports[:i], svc.Ports[i+1:]...)
+			}
+		}
+	},
+	// add an endpoint
+	func(g *generator) {
+		if seps := g.service(); seps != nil {
+			g.addEndpoint(seps)
+		}
+	},
+	// delete an endpoint
+	func(g *generator) {
+		if seps := g.service(); seps != nil {
+			if i := g.pick(len(seps.Endpoints)); i >= 0 {
+				seps.Endpoints = append(seps.Endpoints[:i], seps.Endpoints[i+1:]...)
+			}
+		}
+	},
+	// move an endpoint to or from the node
+	func(g *generator) {
+		if seps := g.service(); seps != nil {
+			if i := g.pick(len(seps.Endpoints)); i >= 0 {
+				seps.Endpoints[i].Local = !seps.Endpoints[i].Local
+			}
+		}
+	},
+	// change the type of a service
+	func(g *generator) {
+		if seps := g.service(); seps != nil && !seps.Service.IPs.Headless {
+			seps.Service.Type = serviceTypes[g.pick(len(serviceTypes))]
+			if seps.Service.Type != "LoadBalancer" {
+				seps.Service.NoLoadBalancerNodePorts = false
+			}
+			g.allocateNodePorts(seps.Service)
+		}
+	},
+	// toggle the node ports of a LoadBalancer service (allocateLoadBalancerNodePorts)
+	func(g *generator) {
+		if seps := g.service(); seps != nil && seps.Service.Type == "LoadBalancer" {
+			svc := seps.Service
+			svc.NoLoadBalancerNodePorts = !svc.NoLoadBalancerNodePorts
+			if svc.NoLoadBalancerNodePorts {
+				// the node ports are kept unless removed explicitly
+				for _, port := range svc.Ports {
+					if g.nextBool() {
+						delete(g.used, port.NodePort)
+						port.NodePort = 0
+					}
+				}
+			}
+			g.allocateNodePorts(svc)
+		}
+	},
+	// toggle the traffic policies of a service
+	func(g *generator) {
+		if seps := g.service(); seps != nil {
+			b := g.next()
+			seps.Service.ExternalTrafficToLocal = b&1 == 1
+			seps.Service.InternalTrafficToLocal = b&2 == 2
+		}
+	},
+	// toggle the session affinity of a service
+	func(g *generator) {
+		if seps := g.service(); seps != nil {
+			if seps.Service.SessionAffinity != nil {
+				seps.Service.SessionAffinity = nil
+			} else {
+				seps.Service.SessionAffinity = &localv1.Service_ClientIP{
+					ClientIP: &localv1.ClientIPAffinity{TimeoutSeconds: 1 + int32(g.next())<<8 | int32(g.next())},
+				}
+			}
+		}
+	},
+	// add an external IP
+	func(g *generator) {
+		if seps := g.service(); seps != nil {
+			ips := seps.Service.IPs
+			if g.nextBool() {
+				ips.ExternalIPs.Add(g.ip6("2001:db8::"))
+			} else {
+				ips.ExternalIPs.Add(g.ip4("203.0"))
+			}
+		}
+	},
+	// add a load-balancer IP
+	func(g *generator) {
+		if seps := g.service(); seps != nil && seps.Service.Type == "LoadBalancer" {
+			ips := seps.Service.IPs
+			if ips.LoadBalancerIPs == nil {
+				ips.LoadBalancerIPs = localv1.NewIPSet()
+			}
+			ips.LoadBalancerIPs.Add(g.ip4("198.51"))
+		}
+	},
+	// add a load-balancer source range
+	func(g *generator) {
+		if seps := g.service(); seps != nil && seps.Service.Type == "LoadBalancer" {
+			seps.Service.IPFilters = append(seps.Service.IPFilters, &localv1.IPFilter{
+				SourceRanges: []string{fmt.Sprintf("192.0.2.%d/%d", g.next(), 24+g.next()%9)},
+			})
+		}
+	},
+	// make a ClusterIP service headless, or give it a cluster IP back
+	func(g *generator) {
+		if seps := g.service(); seps != nil && seps.Service.Type == "ClusterIP" {
+			ips := seps.Service.IPs
+			ips.Headless = !ips.Headless
+			if ips.Headless {
+				ips.ClusterIPs = &localv1.IPSet{}
+			} else {
+				ips.ClusterIPs = localv1.NewIPSet(g.ip4("10.100"))
+			}
+		}
+	},
+}
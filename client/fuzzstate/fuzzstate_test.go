@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fuzzstate
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestStateIsValid(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 1000; i++ {
+		data := make([]byte, rng.Intn(256))
+		rng.Read(data)
+
+		nodePorts := map[int32]string{}
+
+		for _, seps := range State(uint8(i), data) {
+			svc := seps.Service
+			if err := svc.Validate(); err != nil {
+				t.Fatalf("seed %d, data %x: %v", i, data, err)
+			}
+
+			for _, port := range svc.Ports {
+				if port.NodePort == 0 {
+					continue
+				}
+				if prev, ok := nodePorts[port.NodePort]; ok {
+					t.Fatalf("seed %d, data %x: node port %d of %s already used by %s", i, data, port.NodePort, svc.NamespacedName(), prev)
+				}
+				nodePorts[port.NodePort] = svc.NamespacedName()
+			}
+
+			for _, ep := range seps.Endpoints {
+				if err := ep.Validate(); err != nil {
+					t.Fatalf("seed %d, data %x: endpoint of %s: %v", i, data, svc.NamespacedName(), err)
+				}
+			}
+		}
+	}
+}
+
+func TestStateIsDeterministic(t *testing.T) {
+	data := []byte("some fuzz data")
+
+	a, b := State(1, data), State(1, data)
+	if len(a) != len(b) {
+		t.Fatalf("got %d then %d services", len(a), len(b))
+	}
+	for i := range a {
+		if a[i].Service.String() != b[i].Service.String() {
+			t.Errorf("got %v then %v", a[i].Service, b[i].Service)
+		}
+	}
+}
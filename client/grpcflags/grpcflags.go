@@ -0,0 +1,176 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package grpcflags binds the tuning flags of the gRPC servers and clients: message sizes,
+// keepalives and reconnection backoff.
+package grpcflags
+
+import (
+	"math"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/keepalive"
+)
+
+// defaultMinConnectTimeout is gRPC's default, not exported by it.
+const defaultMinConnectTimeout = 20 * time.Second
+
+// FlagSet matches flag.FlagSet and pflag.FlagSet
+type FlagSet interface {
+	DurationVar(varPtr *time.Duration, name string, value time.Duration, doc string)
+	IntVar(varPtr *int, name string, value int, doc string)
+}
+
+type Flags struct {
+	MaxRecvMsgSize int
+	MaxSendMsgSize int
+
+	// KeepaliveTime is the idle time after which the peer is pinged, and KeepaliveTimeout
+	// how long the ping's answer is waited before closing the connection.
+	KeepaliveTime    time.Duration
+	KeepaliveTimeout time.Duration
+
+	// KeepaliveMinTime is the minimum time between the pings of the clients (server only).
+	KeepaliveMinTime time.Duration
+
+	// BackoffBaseDelay and BackoffMaxDelay bound the delay between the connection attempts, and
+	// MinConnectTimeout is the minimum time given to each attempt (client only).
+	BackoffBaseDelay  time.Duration
+	BackoffMaxDelay   time.Duration
+	MinConnectTimeout time.Duration
+}
+
+// BindServer binds the flags of a gRPC server. The defaults are gRPC's ones.
+func (f *Flags) BindServer(flags FlagSet, prefix string) {
+	f.bind(flags, prefix, 4<<20)
+
+	flags.DurationVar(&f.KeepaliveTime, prefix+"grpc-keepalive-time", 2*time.Hour, "ping the clients after this idle time")
+	flags.DurationVar(&f.KeepaliveMinTime, prefix+"grpc-keepalive-min-time", 5*time.Minute, "minimum time between the pings of a client, the clients pinging more often are disconnected")
+}
+
+// BindClient binds the flags of a gRPC client. The default max received message size fits the
+// full states of big clusters, the others are gRPC's defaults.
+func (f *Flags) BindClient(flags FlagSet, prefix string) {
+	f.bind(flags, prefix, 64<<20)
+
+	flags.DurationVar(&f.KeepaliveTime, prefix+"grpc-keepalive-time", 0, "ping the server after this idle time (0 to disable, must not be less than the server's keepalive min time)")
+	flags.DurationVar(&f.BackoffBaseDelay, prefix+"grpc-backoff-base-delay", backoff.DefaultConfig.BaseDelay, "delay before reconnecting after the first failure")
+	flags.DurationVar(&f.BackoffMaxDelay, prefix+"grpc-backoff-max-delay", backoff.DefaultConfig.MaxDelay, "max delay between the reconnection attempts")
+	flags.DurationVar(&f.MinConnectTimeout, prefix+"grpc-min-connect-timeout", defaultMinConnectTimeout, "minimum time given to a connection attempt")
+}
+
+func (f *Flags) bind(flags FlagSet, prefix string, maxRecvMsgSize int) {
+	flags.IntVar(&f.MaxRecvMsgSize, prefix+"grpc-max-recv-msg-size", maxRecvMsgSize, "max size of the gRPC messages received")
+	flags.IntVar(&f.MaxSendMsgSize, prefix+"grpc-max-send-msg-size", math.MaxInt32, "max size of the gRPC messages sent")
+	flags.DurationVar(&f.KeepaliveTimeout, prefix+"grpc-keepalive-timeout", 20*time.Second, "time to wait for a ping's answer before closing the connection")
+}
+
+// ServerOptions returns the options of a gRPC server with the flag values.
+func (f *Flags) ServerOptions() (opts []grpc.ServerOption) {
+	if f == nil {
+		return
+	}
+
+	if f.MaxRecvMsgSize != 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(f.MaxRecvMsgSize))
+	}
+	if f.MaxSendMsgSize != 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(f.MaxSendMsgSize))
+	}
+
+	params, policy := f.serverKeepalive()
+	opts = append(opts, grpc.KeepaliveParams(params), grpc.KeepaliveEnforcementPolicy(policy))
+
+	return
+}
+
+func (f *Flags) serverKeepalive() (params keepalive.ServerParameters, policy keepalive.EnforcementPolicy) {
+	params = keepalive.ServerParameters{
+		Time:    f.KeepaliveTime,
+		Timeout: f.KeepaliveTimeout,
+	}
+	policy = keepalive.EnforcementPolicy{
+		MinTime: f.KeepaliveMinTime,
+		// the clients may ping between their watches
+		PermitWithoutStream: true,
+	}
+	return
+}
+
+// DialOptions returns the options of a gRPC client with the flag values.
+func (f *Flags) DialOptions() (opts []grpc.DialOption) {
+	if f == nil {
+		return
+	}
+
+	if callOpts := f.callOptions(); len(callOpts) != 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+	}
+	if params := f.clientKeepalive(); params != nil {
+		opts = append(opts, grpc.WithKeepaliveParams(*params))
+	}
+	if params := f.connectParams(); params != nil {
+		opts = append(opts, grpc.WithConnectParams(*params))
+	}
+
+	return
+}
+
+func (f *Flags) callOptions() (opts []grpc.CallOption) {
+	if f.MaxRecvMsgSize != 0 {
+		opts = append(opts, grpc.MaxCallRecvMsgSize(f.MaxRecvMsgSize))
+	}
+	if f.MaxSendMsgSize != 0 {
+		opts = append(opts, grpc.MaxCallSendMsgSize(f.MaxSendMsgSize))
+	}
+	return
+}
+
+// clientKeepalive returns nil if the client doesn't ping the server.
+func (f *Flags) clientKeepalive() *keepalive.ClientParameters {
+	if f.KeepaliveTime == 0 {
+		return nil
+	}
+	return &keepalive.ClientParameters{
+		Time:                f.KeepaliveTime,
+		Timeout:             f.KeepaliveTimeout,
+		PermitWithoutStream: true,
+	}
+}
+
+// connectParams returns nil if none is set, gRPC's defaults replacing the unset ones otherwise.
+func (f *Flags) connectParams() *grpc.ConnectParams {
+	if f.BackoffBaseDelay == 0 && f.BackoffMaxDelay == 0 && f.MinConnectTimeout == 0 {
+		return nil
+	}
+
+	params := &grpc.ConnectParams{
+		Backoff:           backoff.DefaultConfig,
+		MinConnectTimeout: defaultMinConnectTimeout,
+	}
+	if f.MinConnectTimeout != 0 {
+		params.MinConnectTimeout = f.MinConnectTimeout
+	}
+	if f.BackoffBaseDelay != 0 {
+		params.Backoff.BaseDelay = f.BackoffBaseDelay
+	}
+	if f.BackoffMaxDelay != 0 {
+		params.Backoff.MaxDelay = f.BackoffMaxDelay
+	}
+	return params
+}
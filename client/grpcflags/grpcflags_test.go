@@ -0,0 +1,205 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package grpcflags
+
+import (
+	"context"
+	"flag"
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func parse(t *testing.T, bind func(f *Flags, flags FlagSet), args ...string) *Flags {
+	t.Helper()
+
+	f := &Flags{}
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	bind(f, flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func bindServer(f *Flags, flags FlagSet) { f.BindServer(flags, "api-") }
+func bindClient(f *Flags, flags FlagSet) { f.BindClient(flags, "api-") }
+
+func TestServerOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		args   []string
+		params keepalive.ServerParameters
+		policy keepalive.EnforcementPolicy
+	}{
+		{
+			name:   "defaults",
+			params: keepalive.ServerParameters{Time: 2 * time.Hour, Timeout: 20 * time.Second},
+			policy: keepalive.EnforcementPolicy{MinTime: 5 * time.Minute, PermitWithoutStream: true},
+		},
+		{
+			name:   "overrides",
+			args:   []string{"--api-grpc-keepalive-time=1m", "--api-grpc-keepalive-timeout=5s", "--api-grpc-keepalive-min-time=10s"},
+			params: keepalive.ServerParameters{Time: time.Minute, Timeout: 5 * time.Second},
+			policy: keepalive.EnforcementPolicy{MinTime: 10 * time.Second, PermitWithoutStream: true},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := parse(t, bindServer, tc.args...)
+
+			params, policy := f.serverKeepalive()
+			if params != tc.params {
+				t.Errorf("expected the keepalive parameters %+v, got %+v", tc.params, params)
+			}
+			if policy != tc.policy {
+				t.Errorf("expected the enforcement policy %+v, got %+v", tc.policy, policy)
+			}
+
+			// the max message sizes and the keepalive parameters and policy
+			if n := len(f.ServerOptions()); n != 4 {
+				t.Errorf("expected 4 server options, got %d", n)
+			}
+		})
+	}
+}
+
+func TestDialOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		args      []string
+		callOpts  []grpc.CallOption
+		keepalive *keepalive.ClientParameters
+		connect   *grpc.ConnectParams
+	}{
+		{
+			name: "defaults",
+			callOpts: []grpc.CallOption{
+				grpc.MaxRecvMsgSizeCallOption{MaxRecvMsgSize: 64 << 20},
+				grpc.MaxSendMsgSizeCallOption{MaxSendMsgSize: math.MaxInt32},
+			},
+			connect: &grpc.ConnectParams{Backoff: backoff.DefaultConfig, MinConnectTimeout: 20 * time.Second},
+		},
+		{
+			name: "overrides",
+			args: []string{
+				"--api-grpc-max-recv-msg-size=1024", "--api-grpc-max-send-msg-size=2048",
+				"--api-grpc-keepalive-time=30s", "--api-grpc-keepalive-timeout=5s",
+				"--api-grpc-backoff-base-delay=2s", "--api-grpc-backoff-max-delay=1m", "--api-grpc-min-connect-timeout=3s",
+			},
+			callOpts: []grpc.CallOption{
+				grpc.MaxRecvMsgSizeCallOption{MaxRecvMsgSize: 1024},
+				grpc.MaxSendMsgSizeCallOption{MaxSendMsgSize: 2048},
+			},
+			keepalive: &keepalive.ClientParameters{Time: 30 * time.Second, Timeout: 5 * time.Second, PermitWithoutStream: true},
+			connect: &grpc.ConnectParams{
+				Backoff: backoff.Config{
+					BaseDelay:  2 * time.Second,
+					Multiplier: backoff.DefaultConfig.Multiplier,
+					Jitter:     backoff.DefaultConfig.Jitter,
+					MaxDelay:   time.Minute,
+				},
+				MinConnectTimeout: 3 * time.Second,
+			},
+		},
+		{
+			name: "zeros",
+			args: []string{
+				"--api-grpc-max-recv-msg-size=0", "--api-grpc-max-send-msg-size=0",
+				"--api-grpc-backoff-base-delay=0", "--api-grpc-backoff-max-delay=0", "--api-grpc-min-connect-timeout=0",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := parse(t, bindClient, tc.args...)
+
+			if callOpts := f.callOptions(); !reflect.DeepEqual(callOpts, tc.callOpts) {
+				t.Errorf("expected the call options %+v, got %+v", tc.callOpts, callOpts)
+			}
+			if params := f.clientKeepalive(); !reflect.DeepEqual(params, tc.keepalive) {
+				t.Errorf("expected the keepalive parameters %+v, got %+v", tc.keepalive, params)
+			}
+			if params := f.connectParams(); !reflect.DeepEqual(params, tc.connect) {
+				t.Errorf("expected the connect parameters %+v, got %+v", tc.connect, params)
+			}
+		})
+	}
+}
+
+func TestNilFlags(t *testing.T) {
+	var f *Flags
+	if len(f.ServerOptions()) != 0 || len(f.DialOptions()) != 0 {
+		t.Error("expected no options without flags")
+	}
+}
+
+// check calls the health service of a server with the server flags through a client with the
+// client flags, with a message of about size bytes.
+func check(t *testing.T, server, client *Flags, size int) error {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(server.ServerOptions()...)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	opts := append(client.DialOptions(),
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }))
+
+	conn, err := grpc.Dial("bufnet", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the health server doesn't know the service, but it had to read its name
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: strings.Repeat("a", size)})
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	return err
+}
+
+func TestMessageSizes(t *testing.T) {
+	big := 4096
+
+	if err := check(t, &Flags{}, &Flags{}, big); err != nil {
+		t.Fatal("expected no limit without flags, got ", err)
+	}
+	if err := check(t, &Flags{MaxRecvMsgSize: big / 2}, &Flags{}, big); status.Code(err) != codes.ResourceExhausted {
+		t.Error("expected the server to reject the message, got ", err)
+	}
+	if err := check(t, &Flags{}, &Flags{MaxSendMsgSize: big / 2}, big); status.Code(err) != codes.ResourceExhausted {
+		t.Error("expected the client not to send the message, got ", err)
+	}
+}
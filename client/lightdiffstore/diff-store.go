@@ -30,7 +30,8 @@ type DiffStore struct {
 }
 
 func New() *DiffStore {
-	return &DiffStore{tree: btree.New(2)}
+	// wide nodes: a diff store holds every endpoint sent to a node
+	return &DiffStore{tree: btree.New(32)}
 }
 
 // Reset the store to clear, marking all entries with the given state (and removing previously deleted ones)
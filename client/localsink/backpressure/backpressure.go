@@ -0,0 +1,227 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package backpressure spaces the syncs of a backend when the services change continuously (ie:
+// during a cluster-wide rollout), so each sync of an iptables-heavy node programs a batch of
+// changes instead of rewriting the rules for each of them.
+//
+// The sink waits before requesting the next change set until the sync period passed since the
+// last sync; the changes received in the meantime are coalesced upstream. The period starts at
+// the minimum, and is doubled (up to the maximum) while the change rate, smoothed exponentially
+// over the syncs, is above the high rate. It is halved back once the rate drops below the low
+// rate; between both, it's kept, so it doesn't flap around a single threshold.
+package backpressure
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink"
+)
+
+// firstPeriod is the period set by the first raise when the minimum is 0.
+const firstPeriod = time.Second
+
+type Config struct {
+	// MinPeriod is the minimum delay between two syncs.
+	MinPeriod time.Duration
+	// MaxPeriod caps the period raised under sustained changes (0 disables the raises).
+	MaxPeriod time.Duration
+	// HighRate is the smoothed number of changes per second above which the period is raised.
+	HighRate float64
+	// LowRate is the smoothed number of changes per second below which the period is lowered.
+	LowRate float64
+	// Smoothing is the weight of the last sync in the smoothed change rate, in ]0, 1].
+	Smoothing float64
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.DurationVar(&c.MinPeriod, "min-sync-period", 0, "Minimum delay between two syncs of the backend, the changes received in the meantime are synced together")
+	flags.DurationVar(&c.MaxPeriod, "max-sync-period", 0, "Maximum delay between two syncs, the minimum being doubled up to it under sustained changes (0 to disable)")
+	flags.Float64Var(&c.HighRate, "sync-backpressure-high-rate", 50, "Changes per second (smoothed) above which the delay between two syncs is doubled")
+	flags.Float64Var(&c.LowRate, "sync-backpressure-low-rate", 10, "Changes per second (smoothed) below which the delay between two syncs is halved")
+	flags.Float64Var(&c.Smoothing, "sync-backpressure-smoothing", 0.3, "Weight of the last sync in the smoothed change rate, in ]0, 1]")
+}
+
+func (c *Config) Enabled() bool {
+	return c.MinPeriod > 0 || c.MaxPeriod > 0
+}
+
+// adaptive returns true if the period is raised under sustained changes.
+func (c *Config) adaptive() bool {
+	return c.MaxPeriod > c.MinPeriod
+}
+
+// Check returns an error if the settings of an enabled sink are invalid.
+func (c *Config) Check() error {
+	if !c.Enabled() || !c.adaptive() {
+		return nil
+	}
+	if c.Smoothing <= 0 || c.Smoothing > 1 {
+		return fmt.Errorf("invalid sync backpressure smoothing %v, must be in ]0, 1]", c.Smoothing)
+	}
+	if c.LowRate > c.HighRate {
+		return fmt.Errorf("sync backpressure low rate %v above the high rate %v", c.LowRate, c.HighRate)
+	}
+	return nil
+}
+
+// Counter is a metric increased by the sink (like prometheus.Counter).
+type Counter interface {
+	Add(float64)
+}
+
+// Gauge is a metric set by the sink (like prometheus.Gauge).
+type Gauge interface {
+	Set(float64)
+}
+
+// Sink passes the operations to the sink of a backend, delaying the requests of the next change
+// set.
+type Sink struct {
+	sink localsink.Sink
+	cfg  Config
+
+	// Period is set to the sync period in seconds, if not nil.
+	Period Gauge
+	// Rate is set to the smoothed change rate, if not nil.
+	Rate Gauge
+	// Delayed is increased by the seconds spent delaying the requests, if not nil.
+	Delayed Counter
+
+	mu       sync.Mutex
+	period   time.Duration
+	rate     float64
+	changes  int
+	reset    bool // the change set follows a reset, and is not a change rate
+	lastSync time.Time
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+var _ localsink.Sink = &Sink{}
+
+func New(cfg Config, sink localsink.Sink) *Sink {
+	return &Sink{
+		sink:   sink,
+		cfg:    cfg,
+		period: cfg.MinPeriod,
+		reset:  true,
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+func (s *Sink) Setup() {
+	s.sink.Setup()
+	if s.Period != nil {
+		s.Period.Set(s.period.Seconds())
+	}
+}
+
+func (s *Sink) WaitRequest() (nodeName string, err error) {
+	s.mu.Lock()
+	delay := time.Duration(0)
+	if !s.lastSync.IsZero() {
+		delay = s.period - s.now().Sub(s.lastSync)
+	}
+	s.mu.Unlock()
+
+	if delay > 0 {
+		s.sleep(delay)
+		if s.Delayed != nil {
+			s.Delayed.Add(delay.Seconds())
+		}
+	}
+
+	return s.sink.WaitRequest()
+}
+
+func (s *Sink) Reset() {
+	s.mu.Lock()
+	s.reset = true
+	s.mu.Unlock()
+
+	s.sink.Reset()
+}
+
+func (s *Sink) Send(op *localv1.OpItem) error {
+	s.mu.Lock()
+	switch op.Op.(type) {
+	case *localv1.OpItem_Reset_:
+		s.reset = true
+	case *localv1.OpItem_Set, *localv1.OpItem_Delete:
+		s.changes++
+	case *localv1.OpItem_Sync:
+		s.synced()
+	}
+	s.mu.Unlock()
+
+	return s.sink.Send(op)
+}
+
+// synced updates the change rate and the period at a sync. Assumes s.mu is held.
+func (s *Sink) synced() {
+	now := s.now()
+	elapsed := now.Sub(s.lastSync)
+	changes, reset := s.changes, s.reset
+	s.lastSync, s.changes, s.reset = now, 0, false
+
+	// the full state sent after a reset is not a change rate
+	if reset || elapsed <= 0 || !s.cfg.adaptive() {
+		return
+	}
+
+	rate := float64(changes) / elapsed.Seconds()
+	s.rate = s.cfg.Smoothing*rate + (1-s.cfg.Smoothing)*s.rate
+	if s.Rate != nil {
+		s.Rate.Set(s.rate)
+	}
+
+	period := s.period
+	switch {
+	case s.rate > s.cfg.HighRate && period < s.cfg.MaxPeriod:
+		period *= 2
+		if period < firstPeriod {
+			period = firstPeriod
+		}
+		if period > s.cfg.MaxPeriod {
+			period = s.cfg.MaxPeriod
+		}
+		klog.Infof("raising the sync period to %v, %.1f changes/s", period, s.rate)
+
+	case s.rate < s.cfg.LowRate && period > s.cfg.MinPeriod:
+		period /= 2
+		if period < firstPeriod || period < s.cfg.MinPeriod {
+			period = s.cfg.MinPeriod
+		}
+		klog.Infof("lowering the sync period to %v, %.1f changes/s", period, s.rate)
+
+	default:
+		return
+	}
+
+	s.period = period
+	if s.Period != nil {
+		s.Period.Set(period.Seconds())
+	}
+}
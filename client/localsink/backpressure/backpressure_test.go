@@ -0,0 +1,106 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backpressure
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+type nopSink struct{ syncs int }
+
+func (*nopSink) Setup()                       {}
+func (*nopSink) WaitRequest() (string, error) { return "node", nil }
+func (*nopSink) Reset()                       {}
+
+func (s *nopSink) Send(op *localv1.OpItem) error {
+	if _, ok := op.Op.(*localv1.OpItem_Sync); ok {
+		s.syncs++
+	}
+	return nil
+}
+
+type gauge float64
+
+func (g *gauge) Set(v float64) { *g = gauge(v) }
+
+type counter float64
+
+func (c *counter) Add(v float64) { *c += counter(v) }
+
+var (
+	setOp  = &localv1.OpItem{Op: &localv1.OpItem_Set{Set: &localv1.Value{Ref: &localv1.Ref{Set: localv1.Set_ServicesSet, Path: "ns/svc"}}}}
+	syncOp = &localv1.OpItem{Op: &localv1.OpItem_Sync{Sync: &localv1.EmptyOp{}}}
+)
+
+func TestBackpressure(t *testing.T) {
+	inner := &nopSink{}
+	s := New(Config{MaxPeriod: 8 * time.Second, HighRate: 50, LowRate: 10, Smoothing: 0.5}, inner)
+
+	period, delayed := new(gauge), new(counter)
+	s.Period, s.Delayed = period, delayed
+
+	clock := time.Unix(0, 0)
+	s.now = func() time.Time { return clock }
+	s.sleep = func(d time.Duration) { clock = clock.Add(d) }
+
+	// round waits for the request, and sends the changes made at rate per second since the
+	// last sync, received after a second.
+	round := func(rate int) time.Duration {
+		s.WaitRequest()
+		clock = clock.Add(time.Second)
+
+		changes := rate
+		if !s.lastSync.IsZero() {
+			changes = int(float64(rate) * clock.Sub(s.lastSync).Seconds())
+		}
+		for i := 0; i < changes; i++ {
+			s.Send(setOp)
+		}
+		s.Send(syncOp)
+		return time.Duration(float64(*period) * float64(time.Second))
+	}
+
+	periods := []time.Duration{}
+	// the initial state is not a change rate
+	periods = append(periods, round(1000))
+	// a rollout: raised up to the max
+	for i := 0; i < 5; i++ {
+		periods = append(periods, round(200))
+	}
+	// quiet: kept while the smoothed rate is above the low rate, then lowered back
+	for i := 0; i < 8; i++ {
+		periods = append(periods, round(0))
+	}
+
+	sec := time.Second
+	expected := []time.Duration{0, sec, 2 * sec, 4 * sec, 8 * sec, 8 * sec, 8 * sec, 8 * sec, 8 * sec, 8 * sec, 4 * sec, 2 * sec, sec, 0}
+	if inner.syncs != len(periods) {
+		t.Errorf("%d syncs, expected %d", inner.syncs, len(periods))
+	}
+	if !reflect.DeepEqual(periods, expected) {
+		t.Errorf("periods %v, expected %v", periods, expected)
+	}
+
+	// each request waited for the period set by the previous sync
+	if *delayed != 62 {
+		t.Errorf("delayed %vs, expected 62s", *delayed)
+	}
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cniwait delays the setup of a backend until the network plugin (CNI) of the node is
+// ready, so the rules written during the node bootstrap don't reference bridges or interfaces
+// that don't exist yet (ie: the interface of the userspace REDIRECT rules).
+//
+// The readiness is checked with files (ie: the configuration written by the plugin) and network
+// interfaces (ie: its bridge). Once the timeout is reached, the backend is set up anyway.
+package cniwait
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink"
+)
+
+type Config struct {
+	// Checks are the conditions of the readiness, as "file:<glob>" or "interface:<name>" (none
+	// disables the wait).
+	Checks []string
+	// Timeout is the maximum wait (0 to wait forever).
+	Timeout time.Duration
+	// Interval is the delay between two checks.
+	Interval time.Duration
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVar(&c.Checks, "wait-for-cni", nil, "Wait for the CNI before setting up the backend, until these files exist (file:<glob>, ie: file:/etc/cni/net.d/*.conflist) and these interfaces are up (interface:<name>, ie: interface:cni0)")
+	flags.DurationVar(&c.Timeout, "wait-for-cni-timeout", 5*time.Minute, "Maximum wait for the CNI, the backend being set up anyway after it (0 to wait forever)")
+	flags.DurationVar(&c.Interval, "wait-for-cni-interval", time.Second, "Delay between two checks of the CNI")
+}
+
+func (c *Config) Enabled() bool {
+	return len(c.Checks) != 0
+}
+
+// Check returns an error if the settings of an enabled wait are invalid.
+func (c *Config) Check() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("invalid CNI check interval %v, must be positive", c.Interval)
+	}
+	_, err := parseChecks(c.Checks)
+	return err
+}
+
+// check is a condition of the readiness of the CNI.
+type check struct {
+	kind  string // file or interface
+	value string
+}
+
+func (c check) String() string {
+	return c.kind + ":" + c.value
+}
+
+func parseChecks(specs []string) (checks []check, err error) {
+	checks = make([]check, 0, len(specs))
+	for _, spec := range specs {
+		kind, value, _ := strings.Cut(spec, ":")
+		if value == "" {
+			return nil, fmt.Errorf("invalid CNI check %q, expected file:<glob> or interface:<name>", spec)
+		}
+
+		switch kind {
+		case "file":
+			if _, err := filepath.Match(value, ""); err != nil {
+				return nil, fmt.Errorf("invalid CNI check %q: %w", spec, err)
+			}
+		case "interface":
+		default:
+			return nil, fmt.Errorf("invalid CNI check %q, expected file:<glob> or interface:<name>", spec)
+		}
+
+		checks = append(checks, check{kind, value})
+	}
+	return
+}
+
+// Sink passes the operations to the sink of a backend, once the CNI is ready.
+type Sink struct {
+	sink   localsink.Sink
+	cfg    Config
+	checks []check
+
+	now   func() time.Time
+	sleep func(time.Duration)
+	// glob and interfaceUp are the probes of the checks
+	glob        func(pattern string) ([]string, error)
+	interfaceUp func(name string) bool
+}
+
+var _ localsink.Sink = &Sink{}
+
+// New returns the sink waiting for the CNI before setting up sink. cfg must be checked.
+func New(cfg Config, sink localsink.Sink) *Sink {
+	checks, _ := parseChecks(cfg.Checks)
+
+	return &Sink{
+		sink:        sink,
+		cfg:         cfg,
+		checks:      checks,
+		now:         time.Now,
+		sleep:       time.Sleep,
+		glob:        filepath.Glob,
+		interfaceUp: interfaceUp,
+	}
+}
+
+func interfaceUp(name string) bool {
+	iface, err := net.InterfaceByName(name)
+	return err == nil && iface.Flags&net.FlagUp != 0
+}
+
+// Setup waits for the CNI, then sets up the backend.
+func (s *Sink) Setup() {
+	s.wait()
+	s.sink.Setup()
+}
+
+func (s *Sink) wait() {
+	start := s.now()
+	logged := false
+
+	for {
+		pending := s.pending()
+		if len(pending) == 0 {
+			if logged {
+				klog.Infof("CNI ready after %v", s.now().Sub(start).Round(time.Millisecond))
+			}
+			return
+		}
+
+		if s.cfg.Timeout > 0 && s.now().Sub(start) >= s.cfg.Timeout {
+			klog.Warningf("CNI not ready after %v (waiting for %v), setting up the backend anyway", s.cfg.Timeout, pending)
+			return
+		}
+
+		if !logged {
+			klog.Infof("waiting for the CNI (%v)", pending)
+			logged = true
+		}
+
+		s.sleep(s.cfg.Interval)
+	}
+}
+
+// pending returns the checks not passing yet.
+func (s *Sink) pending() (pending []check) {
+	for _, c := range s.checks {
+		ok := false
+		switch c.kind {
+		case "file":
+			matches, _ := s.glob(c.value)
+			ok = len(matches) != 0
+		case "interface":
+			ok = s.interfaceUp(c.value)
+		}
+
+		if !ok {
+			pending = append(pending, c)
+		}
+	}
+	return
+}
+
+func (s *Sink) WaitRequest() (nodeName string, err error) {
+	return s.sink.WaitRequest()
+}
+
+func (s *Sink) Reset() {
+	s.sink.Reset()
+}
+
+func (s *Sink) Send(op *localv1.OpItem) error {
+	return s.sink.Send(op)
+}
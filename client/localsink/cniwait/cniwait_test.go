@@ -0,0 +1,117 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cniwait
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+type recordingSink struct {
+	ops []string
+}
+
+func (s *recordingSink) Setup()                     { s.ops = append(s.ops, "setup") }
+func (*recordingSink) WaitRequest() (string, error) { return "node", nil }
+func (*recordingSink) Reset()                       {}
+func (*recordingSink) Send(*localv1.OpItem) error   { return nil }
+
+// fakeHost is a host whose CNI becomes ready at readyAt.
+type fakeHost struct {
+	now     time.Time
+	readyAt time.Time
+}
+
+func (h *fakeHost) ready() bool { return !h.now.Before(h.readyAt) }
+
+func (h *fakeHost) glob(pattern string) ([]string, error) {
+	if !h.ready() {
+		return nil, nil
+	}
+	return []string{filepath.Join(filepath.Dir(pattern), "10-cni.conflist")}, nil
+}
+
+func (h *fakeHost) interfaceUp(name string) bool {
+	return h.ready()
+}
+
+func newTestSink(cfg Config, host *fakeHost) (*Sink, *recordingSink) {
+	backend := &recordingSink{}
+
+	s := New(cfg, backend)
+	s.now = func() time.Time { return host.now }
+	s.sleep = func(d time.Duration) {
+		backend.ops = append(backend.ops, "sleep")
+		host.now = host.now.Add(d)
+	}
+	s.glob = host.glob
+	s.interfaceUp = host.interfaceUp
+	return s, backend
+}
+
+func TestWait(t *testing.T) {
+	host := &fakeHost{now: time.Unix(0, 0), readyAt: time.Unix(3, 0)}
+
+	s, backend := newTestSink(Config{
+		Checks:   []string{"file:/etc/cni/net.d/*.conflist", "interface:cni0"},
+		Interval: time.Second,
+	}, host)
+	s.Setup()
+
+	expected := []string{"sleep", "sleep", "sleep", "setup"}
+	if !reflect.DeepEqual(backend.ops, expected) {
+		t.Errorf("expected %v, got %v", expected, backend.ops)
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	host := &fakeHost{now: time.Unix(0, 0), readyAt: time.Unix(3600, 0)}
+
+	s, backend := newTestSink(Config{
+		Checks:   []string{"interface:cni0"},
+		Timeout:  2 * time.Second,
+		Interval: time.Second,
+	}, host)
+	s.Setup()
+
+	expected := []string{"sleep", "sleep", "setup"}
+	if !reflect.DeepEqual(backend.ops, expected) {
+		t.Errorf("expected %v, got %v", expected, backend.ops)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for _, test := range []struct {
+		checks []string
+		valid  bool
+	}{
+		{nil, true},
+		{[]string{"file:/etc/cni/net.d/*.conf*", "interface:cni0"}, true},
+		{[]string{"interface:"}, false},
+		{[]string{"bridge:cni0"}, false},
+		{[]string{"file:/etc/cni/["}, false},
+	} {
+		cfg := Config{Checks: test.checks, Interval: time.Second}
+		if err := cfg.Check(); (err == nil) != test.valid {
+			t.Errorf("%v: expected valid=%v, got %v", test.checks, test.valid, err)
+		}
+	}
+}
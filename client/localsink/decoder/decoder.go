@@ -20,6 +20,7 @@ import (
 	"strings"
 
 	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
 
 	"sigs.k8s.io/kpng/api/localv1"
 	"sigs.k8s.io/kpng/client/localsink"
@@ -61,14 +62,48 @@ type Interface interface {
 	Reset()
 }
 
+// ExternalNamesListener is implemented by the decoders acting on the ExternalName services (ie: DNS).
+// They are not sent to SetService as they don't need rules.
+type ExternalNamesListener interface {
+	// SetExternalName is called when an ExternalName service is added or updated
+	SetExternalName(service *localv1.Service)
+	// DeleteExternalName is called when an ExternalName service is deleted
+	DeleteExternalName(namespace, name string)
+}
+
+// InitialSyncListener is implemented by the decoders that must not act on a partial state (ie: to
+// not flush the rules of a previous run before the services are received).
+type InitialSyncListener interface {
+	// InitialSync is called once, when the stream marks the next Sync as the one of the first
+	// complete state (see localv1.OpItem_InitialSync).
+	InitialSync()
+}
+
+// FailingSyncer is implemented by the decoders that can report the failure of a Sync (ie: a failed
+// iptables-restore), so the client can re-deliver the state (see the requeue package).
+type FailingSyncer interface {
+	// SyncErr returns the error of the last Sync, nil if it succeeded.
+	SyncErr() error
+}
+
+// BackgroundSyncer is implemented by the FailingSyncers also syncing on their own between the Syncs
+// (ie: to delete the rules whose grace period expired), to report the failures of these syncs.
+type BackgroundSyncer interface {
+	// OnSyncErr sets the func called with the error of a failed background sync, before Setup.
+	// It's called without any lock of the decoder held, so the state can be re-delivered.
+	OnSyncErr(func(error))
+}
+
 type Sink struct {
 	Interface
+
+	initialSync bool
 }
 
 var _ localsink.Sink = &Sink{}
 
 func New(iface Interface) *Sink {
-	return &Sink{iface}
+	return &Sink{Interface: iface}
 }
 
 func (s *Sink) Send(op *localv1.OpItem) (err error) {
@@ -85,6 +120,11 @@ func (s *Sink) Send(op *localv1.OpItem) (err error) {
 				return
 			}
 
+			if err := v.Validate(); err != nil {
+				klog.Error("ignoring service: ", err)
+				return nil
+			}
+
 			s.SetService(v)
 
 		case localv1.Set_EndpointsSet:
@@ -95,9 +135,29 @@ func (s *Sink) Send(op *localv1.OpItem) (err error) {
 				return
 			}
 
+			if err := v.Validate(); err != nil {
+				klog.Error("ignoring endpoint ", set.Ref.Path, ": ", err)
+				return nil
+			}
+
 			parts := strings.Split(set.Ref.Path, "/")
 			s.SetEndpoint(parts[0], parts[1], parts[2], v)
 
+		case localv1.Set_ExternalNamesSet:
+			l, ok := s.Interface.(ExternalNamesListener)
+			if !ok {
+				return
+			}
+
+			v := &localv1.Service{}
+
+			err = proto.Unmarshal(set.Bytes, v)
+			if err != nil {
+				return
+			}
+
+			l.SetExternalName(v)
+
 		default:
 			return
 		}
@@ -113,12 +173,29 @@ func (s *Sink) Send(op *localv1.OpItem) (err error) {
 		case localv1.Set_EndpointsSet: // Endpoint: namespace/name/key
 			s.DeleteEndpoint(parts[0], parts[1], parts[2])
 
+		case localv1.Set_ExternalNamesSet: // Service: namespace/name
+			if l, ok := s.Interface.(ExternalNamesListener); ok {
+				l.DeleteExternalName(parts[0], parts[1])
+			}
+
 		default:
 			// unknown set, ignore
 		}
 
+	case *localv1.OpItem_InitialSync:
+		if !s.initialSync {
+			s.initialSync = true
+			if l, ok := s.Interface.(InitialSyncListener); ok {
+				l.InitialSync()
+			}
+		}
+
 	case *localv1.OpItem_Sync:
 		s.Sync()
+
+		if f, ok := s.Interface.(FailingSyncer); ok {
+			err = f.SyncErr()
+		}
 	}
 
 	return
@@ -0,0 +1,228 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package familyfilter lets the backends handling only some IP families (ie: IPv4 only) receive
+// only the IPs of these families.
+//
+// The services left without cluster IPs, and their endpoints, are withheld from the backend as if
+// deleted. So are the endpoints left without IPs. The other IPs, and the source ranges, of the
+// other families are removed.
+package familyfilter
+
+import (
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink"
+)
+
+// Backend is implemented by the backends handling only some IP families.
+type Backend interface {
+	// IPFamilies returns the IP families handled by the backend.
+	IPFamilies() []localv1.IPFamily
+}
+
+// Sink passes the operations to the sink of a backend with only the IPs of its families.
+type Sink struct {
+	sink     localsink.Sink
+	families []localv1.IPFamily
+
+	services  map[string]bool                       // by path, true if withheld
+	endpoints map[string]map[string]*localv1.OpItem // by service path, then by path
+	sent      map[string]bool                       // endpoint paths sent to the backend
+}
+
+var _ localsink.Sink = &Sink{}
+
+func New(families []localv1.IPFamily, sink localsink.Sink) *Sink {
+	s := &Sink{sink: sink, families: families}
+	s.clear()
+	return s
+}
+
+func (s *Sink) clear() {
+	s.services = map[string]bool{}
+	s.endpoints = map[string]map[string]*localv1.OpItem{}
+	s.sent = map[string]bool{}
+}
+
+func (s *Sink) Setup() { s.sink.Setup() }
+
+func (s *Sink) WaitRequest() (nodeName string, err error) {
+	return s.sink.WaitRequest()
+}
+
+func (s *Sink) Reset() {
+	s.clear()
+	s.sink.Reset()
+}
+
+// IPFamilies returns the IP families passed to the backend.
+func (s *Sink) IPFamilies() []localv1.IPFamily { return s.families }
+
+func (s *Sink) Send(op *localv1.OpItem) error {
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Reset_:
+		s.clear()
+
+	case *localv1.OpItem_Set:
+		switch path := v.Set.Ref.Path; v.Set.Ref.Set {
+		case localv1.Set_ServicesSet:
+			return s.setService(path, op)
+
+		case localv1.Set_EndpointsSet:
+			svc := servicePath(path)
+			if s.endpoints[svc] == nil {
+				s.endpoints[svc] = map[string]*localv1.OpItem{}
+			}
+			s.endpoints[svc][path] = op
+			if s.services[svc] {
+				return nil
+			}
+			return s.setEndpoint(path, op)
+		}
+
+	case *localv1.OpItem_Delete:
+		switch path := v.Delete.Path; v.Delete.Set {
+		case localv1.Set_ServicesSet:
+			withheld := s.services[path]
+			delete(s.services, path)
+			if withheld {
+				return nil
+			}
+
+		case localv1.Set_EndpointsSet:
+			svc := servicePath(path)
+			delete(s.endpoints[svc], path)
+			if len(s.endpoints[svc]) == 0 {
+				delete(s.endpoints, svc)
+			}
+			if !s.sent[path] {
+				return nil
+			}
+			delete(s.sent, path)
+		}
+	}
+
+	return s.sink.Send(op)
+}
+
+// setService sends the service with the IPs of the families, withholding it and its endpoints if it
+// has no cluster IP left.
+func (s *Sink) setService(path string, op *localv1.OpItem) error {
+	svc := &localv1.Service{}
+	if err := proto.Unmarshal(op.GetSet().Bytes, svc); err != nil {
+		// the backend reports it
+		return s.sink.Send(op)
+	}
+
+	withheld, known := s.services[path]
+
+	pruned := svc.ForIPFamilies(s.families...)
+	if pruned == nil {
+		s.services[path] = true
+		if withheld || !known {
+			return nil
+		}
+
+		for _, epPath := range sortedPaths(s.endpoints[path]) {
+			if !s.sent[epPath] {
+				continue
+			}
+			delete(s.sent, epPath)
+			if err := s.sink.Send(deleteOp(localv1.Set_EndpointsSet, epPath)); err != nil {
+				return err
+			}
+		}
+		return s.sink.Send(deleteOp(localv1.Set_ServicesSet, path))
+	}
+
+	s.services[path] = false
+	if err := s.sendSet(op, svc, pruned); err != nil {
+		return err
+	}
+
+	if withheld {
+		for _, epPath := range sortedPaths(s.endpoints[path]) {
+			if err := s.setEndpoint(epPath, s.endpoints[path][epPath]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setEndpoint sends the endpoint with the IPs of the families, deleting it if it has none left.
+func (s *Sink) setEndpoint(path string, op *localv1.OpItem) error {
+	ep := &localv1.Endpoint{}
+	if err := proto.Unmarshal(op.GetSet().Bytes, ep); err != nil {
+		return s.sink.Send(op)
+	}
+
+	pruned := ep.ForIPFamilies(s.families...)
+	if pruned == nil {
+		if !s.sent[path] {
+			return nil
+		}
+		delete(s.sent, path)
+		return s.sink.Send(deleteOp(localv1.Set_EndpointsSet, path))
+	}
+
+	s.sent[path] = true
+	return s.sendSet(op, ep, pruned)
+}
+
+// sendSet sends op, with the value replaced by pruned if it differs from the decoded one.
+func (s *Sink) sendSet(op *localv1.OpItem, decoded, pruned proto.Message) error {
+	if pruned == decoded {
+		return s.sink.Send(op)
+	}
+
+	// deterministic to keep the same bytes for the same value
+	ba, err := proto.MarshalOptions{Deterministic: true}.Marshal(pruned)
+	if err != nil {
+		return err
+	}
+
+	return s.sink.Send(&localv1.OpItem{Op: &localv1.OpItem_Set{Set: &localv1.Value{
+		Ref:   op.GetSet().Ref,
+		Bytes: ba,
+	}}})
+}
+
+// servicePath returns the path of the service of the endpoint at path (namespace/name/key).
+func servicePath(path string) string {
+	if i := strings.LastIndexByte(path, '/'); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+func sortedPaths(ops map[string]*localv1.OpItem) []string {
+	paths := make([]string, 0, len(ops))
+	for path := range ops {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func deleteOp(set localv1.Set, path string) *localv1.OpItem {
+	return &localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: &localv1.Ref{Set: set, Path: path}}}
+}
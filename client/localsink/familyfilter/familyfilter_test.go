@@ -0,0 +1,138 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package familyfilter
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+// recordingSink records the operations it receives, with the IPs set.
+type recordingSink struct {
+	ops []string
+}
+
+func (*recordingSink) Setup()                       {}
+func (*recordingSink) WaitRequest() (string, error) { return "node", nil }
+func (s *recordingSink) Reset()                     { s.ops = append(s.ops, "reset") }
+
+func (s *recordingSink) Send(op *localv1.OpItem) error {
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Set:
+		var ips *localv1.IPSet
+		switch v.Set.Ref.Set {
+		case localv1.Set_ServicesSet:
+			svc := &localv1.Service{}
+			if err := proto.Unmarshal(v.Set.Bytes, svc); err != nil {
+				return err
+			}
+			ips = svc.IPs.ClusterIPs
+		case localv1.Set_EndpointsSet:
+			ep := &localv1.Endpoint{}
+			if err := proto.Unmarshal(v.Set.Bytes, ep); err != nil {
+				return err
+			}
+			ips = ep.IPs
+		}
+		s.ops = append(s.ops, "set "+v.Set.Ref.Path+" "+ips.String())
+	case *localv1.OpItem_Delete:
+		s.ops = append(s.ops, "del "+v.Delete.Path)
+	case *localv1.OpItem_Sync:
+		s.ops = append(s.ops, "sync")
+	}
+	return nil
+}
+
+func set(s localv1.Set, path string, m proto.Message) *localv1.OpItem {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	return &localv1.OpItem{Op: &localv1.OpItem_Set{Set: &localv1.Value{Ref: &localv1.Ref{Set: s, Path: path}, Bytes: b}}}
+}
+
+func setService(name string, clusterIPs ...string) *localv1.OpItem {
+	return set(localv1.Set_ServicesSet, "ns/"+name, &localv1.Service{
+		Namespace: "ns",
+		Name:      name,
+		IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet(clusterIPs...)},
+	})
+}
+
+func setEndpoint(name, key string, ips ...string) *localv1.OpItem {
+	return set(localv1.Set_EndpointsSet, "ns/"+name+"/"+key, &localv1.Endpoint{IPs: localv1.NewIPSet(ips...)})
+}
+
+func deleteEndpoint(name, key string) *localv1.OpItem {
+	return deleteOp(localv1.Set_EndpointsSet, "ns/"+name+"/"+key)
+}
+
+var syncOp = &localv1.OpItem{Op: &localv1.OpItem_Sync{Sync: &localv1.EmptyOp{}}}
+
+func TestFamilyFilter(t *testing.T) {
+	inner := &recordingSink{}
+	s := New([]localv1.IPFamily{localv1.IPFamily_IPv4}, inner)
+
+	send := func(ops ...*localv1.OpItem) {
+		t.Helper()
+		for _, op := range ops {
+			if err := s.Send(op); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	expect := func(ops ...string) {
+		t.Helper()
+		if !reflect.DeepEqual(inner.ops, ops) {
+			t.Errorf("expected %q, got %q", ops, inner.ops)
+		}
+		inner.ops = nil
+	}
+
+	send(
+		setService("dual", "10.96.0.1", "fd00::1"), setEndpoint("dual", "1", "10.1.0.1", "fd01::1"), setEndpoint("dual", "2", "fd01::2"),
+		setService("v6", "fd00::2"), setEndpoint("v6", "1", "fd01::3"),
+		syncOp)
+	expect(`set ns/dual V4:"10.96.0.1"`, `set ns/dual/1 V4:"10.1.0.1"`, "sync")
+
+	// the IPv6 endpoint gets an IPv4
+	send(setEndpoint("dual", "2", "10.1.0.2", "fd01::2"), syncOp)
+	expect(`set ns/dual/2 V4:"10.1.0.2"`, "sync")
+
+	// the deletes of the withheld services and endpoints are withheld too
+	send(deleteEndpoint("v6", "1"), deleteOp(localv1.Set_ServicesSet, "ns/v6"), syncOp)
+	expect("sync")
+
+	// the service loses its IPv4: it's deleted with its endpoints
+	send(setService("dual", "fd00::1"), syncOp)
+	expect("del ns/dual/1", "del ns/dual/2", "del ns/dual", "sync")
+
+	// the endpoint changes are withheld, then set back with the service
+	send(setEndpoint("dual", "3", "10.1.0.3"), deleteEndpoint("dual", "1"), syncOp)
+	expect("sync")
+
+	send(setService("dual", "10.96.0.1"), syncOp)
+	expect(`set ns/dual V4:"10.96.0.1"`, `set ns/dual/2 V4:"10.1.0.2"`, `set ns/dual/3 V4:"10.1.0.3"`, "sync")
+
+	// an endpoint losing its IPv4 is deleted
+	send(setEndpoint("dual", "3", "fd01::3"), deleteEndpoint("dual", "3"), syncOp)
+	expect("del ns/dual/3", "sync")
+}
@@ -46,11 +46,12 @@ func (ps *Sink) Setup() {
 	}
 }
 
-func (ps *Sink) Send(op *localv1.OpItem) error {
+// Send sends the operation to all the target sinks, and returns the first error.
+func (ps *Sink) Send(op *localv1.OpItem) (err error) {
 	for _, sink := range ps.targetSinks {
-		if err := sink.Send(op); err != nil {
-			return err
+		if sinkErr := sink.Send(op); sinkErr != nil && err == nil {
+			err = sinkErr
 		}
 	}
-	return nil
+	return
 }
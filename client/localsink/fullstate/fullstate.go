@@ -17,8 +17,11 @@ limitations under the License.
 package fullstate
 
 import (
+	"strings"
+
 	"github.com/google/btree"
 	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
 
 	localv1 "sigs.k8s.io/kpng/api/localv1"
 	"sigs.k8s.io/kpng/client/localsink"
@@ -37,6 +40,9 @@ type Sink struct {
 	Config    *localsink.Config
 	Callback  Callback
 	SetupFunc Setup
+	// SyncErr returns the error of the last Callback, if not nil (ie: a failed nft run), so the
+	// client can re-deliver the state (see the requeue package).
+	SyncErr func() error
 
 	data *btree.BTree
 }
@@ -86,7 +92,10 @@ func (s *Sink) Send(op *localv1.OpItem) (err error) {
 	case *localv1.OpItem_Set:
 		set := op.GetSet()
 
-		var v proto.Message
+		var v interface {
+			proto.Message
+			Validate() error
+		}
 		switch set.Ref.Set {
 		case localv1.Set_ServicesSet:
 			v = &localv1.Service{}
@@ -102,40 +111,77 @@ func (s *Sink) Send(op *localv1.OpItem) (err error) {
 			return
 		}
 
+		if err := v.Validate(); err != nil {
+			klog.Error("ignoring ", set.Ref.Path, ": ", err)
+			s.data.Delete(kv{Path: set.Ref.Path})
+			return nil
+		}
+
 		s.data.ReplaceOrInsert(kv{set.Ref.Path, v})
 
 	case *localv1.OpItem_Delete:
-		s.data.Delete(kv{Path: op.GetDelete().Path})
+		switch op.GetDelete().Set {
+		case localv1.Set_ServicesSet, localv1.Set_EndpointsSet:
+			// the other sets may have the same paths (ie: a service changed to an ExternalName)
+			s.data.Delete(kv{Path: op.GetDelete().Path})
+		}
 
 	case *localv1.OpItem_Sync:
 		results := make(chan *ServiceEndpoints)
 
+		// done stops the producer when the callback returns (or panics) without reading everything
+		done := make(chan struct{})
+		defer close(done)
+
+		send := func(seps *ServiceEndpoints) bool {
+			select {
+			case results <- seps:
+				return true
+			case <-done:
+				return false
+			}
+		}
+
 		go func() {
 			defer close(results)
 
 			var seps *ServiceEndpoints
+			svcPrefix := ""
 
+			stopped := false
 			s.data.Ascend(func(i btree.Item) bool {
-				switch v := i.(kv).Value.(type) {
+				item := i.(kv)
+
+				switch v := item.Value.(type) {
 				case *localv1.Service:
-					if seps != nil {
-						results <- seps
+					if seps != nil && !send(seps) {
+						stopped = true
+						return false
 					}
 
 					seps = &ServiceEndpoints{Service: v}
+					svcPrefix = item.Path + "/"
 				case *localv1.Endpoint:
+					// endpoints of an ignored service have no service before them
+					if seps == nil || !strings.HasPrefix(item.Path, svcPrefix) {
+						return true
+					}
 					seps.Endpoints = append(seps.Endpoints, v)
 				}
 
 				return true
 			})
 
-			if seps != nil {
-				results <- seps
+			if seps != nil && !stopped {
+				send(seps)
 			}
 		}()
 
 		s.Callback(results)
+
+		if s.SyncErr != nil {
+			err = s.SyncErr()
+		}
 	}
 
 	return
@@ -17,7 +17,11 @@ limitations under the License.
 package fullstate
 
 import (
+	"errors"
+	"fmt"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 
@@ -45,6 +49,9 @@ func TestAddRemoveService(t *testing.T) {
 	svcBytes, _ := proto.Marshal(&localv1.Service{
 		Namespace: "test",
 		Name:      "nginx",
+		IPs: &localv1.ServiceIPs{
+			ClusterIPs: localv1.NewIPSet("10.0.0.1"),
+		},
 	})
 
 	sink.Send(&localv1.OpItem{
@@ -72,3 +79,133 @@ func TestAddRemoveService(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestInvalidService(t *testing.T) {
+	var latestSeps []*ServiceEndpoints
+
+	sink := New(nil)
+	sink.Callback = ArrayCallback(func(seps []*ServiceEndpoints) {
+		latestSeps = seps
+	})
+
+	send := func(set localv1.Set, path string, value proto.Message) {
+		bytes, _ := proto.Marshal(value)
+		sink.Send(&localv1.OpItem{
+			Op: &localv1.OpItem_Set{
+				Set: &localv1.Value{
+					Ref:   &localv1.Ref{Set: set, Path: path},
+					Bytes: bytes,
+				},
+			},
+		})
+	}
+
+	// no IPs, invalid
+	send(localv1.Set_ServicesSet, "test/nginx", &localv1.Service{Namespace: "test", Name: "nginx"})
+	send(localv1.Set_EndpointsSet, "test/nginx/ep", &localv1.Endpoint{IPs: localv1.NewIPSet("10.1.0.1")})
+	sink.Send(syncOp)
+
+	if len(latestSeps) != 0 {
+		t.Errorf("expected the invalid service and its endpoints to be ignored, got %v", latestSeps)
+	}
+}
+
+func TestExternalNameDeleteKeepsService(t *testing.T) {
+	var latestSeps []*ServiceEndpoints
+
+	sink := New(nil)
+	sink.Callback = ArrayCallback(func(seps []*ServiceEndpoints) {
+		latestSeps = seps
+	})
+
+	svcBytes, _ := proto.Marshal(&localv1.Service{
+		Namespace: "test",
+		Name:      "nginx",
+		IPs: &localv1.ServiceIPs{
+			ClusterIPs: localv1.NewIPSet("10.0.0.1"),
+		},
+	})
+
+	// the service was an ExternalName before
+	sink.Send(&localv1.OpItem{
+		Op: &localv1.OpItem_Set{
+			Set: &localv1.Value{
+				Ref:   &localv1.Ref{Set: localv1.Set_ServicesSet, Path: "test/nginx"},
+				Bytes: svcBytes,
+			},
+		},
+	})
+	sink.Send(&localv1.OpItem{
+		Op: &localv1.OpItem_Delete{
+			Delete: &localv1.Ref{Set: localv1.Set_ExternalNamesSet, Path: "test/nginx"},
+		},
+	})
+	sink.Send(syncOp)
+
+	if len(latestSeps) != 1 {
+		t.Errorf("expected the service to be kept, got %d services", len(latestSeps))
+	}
+}
+
+func TestSyncErr(t *testing.T) {
+	var syncErr error
+
+	sink := New(nil)
+	sink.Callback = ArrayCallback(func([]*ServiceEndpoints) {})
+	sink.SyncErr = func() error { return syncErr }
+
+	if err := sink.Send(syncOp); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	syncErr = errors.New("failed")
+	if err := sink.Send(syncOp); err != syncErr {
+		t.Errorf("expected the sync error, got %v", err)
+	}
+}
+
+func TestCallbackPanicStopsProducer(t *testing.T) {
+	sink := New(nil)
+	sink.Callback = func(ch <-chan *ServiceEndpoints) {
+		<-ch
+		panic("callback failed")
+	}
+
+	for i := 0; i < 3; i++ {
+		path := fmt.Sprintf("test/svc-%d", i)
+		svcBytes, _ := proto.Marshal(&localv1.Service{
+			Namespace: "test",
+			Name:      path[len("test/"):],
+			IPs: &localv1.ServiceIPs{
+				ClusterIPs: localv1.NewIPSet(fmt.Sprintf("10.0.0.%d", i+1)),
+			},
+		})
+		sink.Send(&localv1.OpItem{
+			Op: &localv1.OpItem_Set{
+				Set: &localv1.Value{
+					Ref:   &localv1.Ref{Set: localv1.Set_ServicesSet, Path: path},
+					Bytes: svcBytes,
+				},
+			},
+		})
+	}
+
+	goroutines := runtime.NumGoroutine()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the callback's panic")
+			}
+		}()
+		sink.Send(syncOp)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > goroutines {
+		if time.Now().After(deadline) {
+			t.Fatalf("the producer is still running: %d goroutines, expected %d", runtime.NumGoroutine(), goroutines)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -18,6 +18,7 @@ package fullstatepipe
 
 import (
 	"fmt"
+	"runtime/debug"
 	"sync"
 
 	"sigs.k8s.io/kpng/client"
@@ -66,13 +67,11 @@ func (pipe *Pipe) Callback(ch <-chan *client.ServiceEndpoints) {
 		}
 
 		for _, stage := range pipe.stages {
-			myCh := make(chan *client.ServiceEndpoints)
-			go func() {
-				for _, item := range buf {
-					myCh <- item
-				}
-				close(myCh)
-			}()
+			myCh := make(chan *client.ServiceEndpoints, len(buf))
+			for _, item := range buf {
+				myCh <- item
+			}
+			close(myCh)
 
 			stage(myCh)
 		}
@@ -80,6 +79,9 @@ func (pipe *Pipe) Callback(ch <-chan *client.ServiceEndpoints) {
 		pipe.buffer = buf[:0]
 
 	case Parallel:
+		failed := new(stagePanic)
+		defer failed.raise()
+
 		channels := make([]chan *client.ServiceEndpoints, len(pipe.stages))
 
 		wg := new(sync.WaitGroup)
@@ -92,7 +94,7 @@ func (pipe *Pipe) Callback(ch <-chan *client.ServiceEndpoints) {
 			stage := stage
 			go func() {
 				defer wg.Done()
-				stage(childCh)
+				failed.run(stage, childCh)
 			}()
 		}
 
@@ -109,6 +111,9 @@ func (pipe *Pipe) Callback(ch <-chan *client.ServiceEndpoints) {
 		wg.Wait()
 
 	case ParallelSendSequenceClose:
+		failed := new(stagePanic)
+		defer failed.raise()
+
 		channels := make([]chan *client.ServiceEndpoints, len(pipe.stages))
 		waitGroups := make([]*sync.WaitGroup, len(pipe.stages))
 
@@ -124,7 +129,7 @@ func (pipe *Pipe) Callback(ch <-chan *client.ServiceEndpoints) {
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
-				stage(childCh)
+				failed.run(stage, childCh)
 			}()
 		}
 
@@ -143,3 +148,40 @@ func (pipe *Pipe) Callback(ch <-chan *client.ServiceEndpoints) {
 		panic(fmt.Errorf("unknown strategy: %d", pipe.strategy))
 	}
 }
+
+// stagePanic records the first panic of the stages running in their own goroutines, to raise it
+// again in the goroutine of the callback, where the sink's supervision can handle it.
+type stagePanic struct {
+	mu    sync.Mutex
+	p     any
+	stack []byte
+}
+
+// run calls the stage, recovering its panic. The rest of the channel is then drained, so the
+// items sent to the failed stage don't block the others.
+func (s *stagePanic) run(stage fullstate.Callback, ch <-chan *client.ServiceEndpoints) {
+	defer func() {
+		p := recover()
+		if p == nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.p == nil {
+			s.p, s.stack = p, debug.Stack()
+		}
+		s.mu.Unlock()
+
+		for range ch {
+		}
+	}()
+
+	stage(ch)
+}
+
+// raise panics again with the recorded panic, if any.
+func (s *stagePanic) raise() {
+	if s.p != nil {
+		panic(fmt.Errorf("pipe stage panicked: %v\n%s", s.p, s.stack))
+	}
+}
@@ -21,7 +21,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"sync"
+	"testing"
 	"time"
 
 	"sigs.k8s.io/kpng/api/localv1"
@@ -95,6 +97,43 @@ func ExampleParallelSendSequenceClose() {
 	// s3 finished
 }
 
+func TestStagePanic(t *testing.T) {
+	for _, strategy := range []Strategy{Parallel, ParallelSendSequenceClose} {
+		t.Run(fmt.Sprint(strategy), func(t *testing.T) {
+			received := 0
+			pipe := New(strategy,
+				func(ch <-chan *client.ServiceEndpoints) {
+					<-ch
+					panic("stage failed")
+				},
+				func(ch <-chan *client.ServiceEndpoints) {
+					for range ch {
+						received++
+					}
+				},
+			)
+
+			ch := make(chan *client.ServiceEndpoints, 10)
+			for i := 0; i < cap(ch); i++ {
+				ch <- &client.ServiceEndpoints{Service: &localv1.Service{Name: fmt.Sprint("svc-", i)}}
+			}
+			close(ch)
+
+			defer func() {
+				p := recover()
+				if p == nil || !strings.Contains(fmt.Sprint(p), "pipe stage panicked: stage failed") {
+					t.Errorf("expected the stage's panic to be raised again, got %v", p)
+				}
+				if received != cap(ch) {
+					t.Errorf("expected the other stage to receive %d items, got %d", cap(ch), received)
+				}
+			}()
+
+			pipe.Callback(ch)
+		})
+	}
+}
+
 func failAfter1Sec() {
 	time.Sleep(time.Second)
 	panic("example timed out")
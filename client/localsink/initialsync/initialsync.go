@@ -0,0 +1,319 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package initialsync programs the initial state of a backend in steps, so a node starting with
+// thousands of services restores the connectivity of its critical services (ie: kube-dns) before
+// the long tail, and reports its progress.
+//
+// The operations of the first change set are held until its sync, then sent grouped by service:
+// the services with a critical label or of the critical priority class (see localv1.Priority) and
+// their endpoints first, followed by a sync, then the other services by priority class, in
+// batches each followed by a sync. Each sync so programs a bounded number of services, the initial
+// sync marker (see localv1.OpItem_InitialSync) preceding the last one. The progress is logged and
+// exported after each sync. The later change sets are passed through.
+package initialsync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink"
+)
+
+type Config struct {
+	// CriticalLabels are the labels ("key=value", or "key" for any value) of the services
+	// programmed first.
+	CriticalLabels []string
+	// Batch is the number of services programmed by each sync of the initial state (0 for all).
+	Batch int
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVar(&c.CriticalLabels, "critical-service-labels", nil, "Labels (key=value, or key for any value) of the services programmed first at startup (ie: k8s-app=kube-dns)")
+	flags.IntVar(&c.Batch, "initial-sync-batch", 0, "Number of services programmed by each sync of the initial state at startup (0 for a single sync)")
+}
+
+func (c *Config) Enabled() bool {
+	return len(c.CriticalLabels) != 0 || c.Batch > 0
+}
+
+// Check returns an error if the settings are invalid.
+func (c *Config) Check() error {
+	if c.Batch < 0 {
+		return fmt.Errorf("invalid initial sync batch %d, must be positive", c.Batch)
+	}
+	for _, s := range c.CriticalLabels {
+		if _, err := parseLabel(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// label is a critical label, matching any value if value is empty.
+type label struct {
+	key, value string
+}
+
+func parseLabel(s string) (label, error) {
+	key, value, _ := strings.Cut(s, "=")
+	if key == "" {
+		return label{}, fmt.Errorf("invalid critical service label %q, must be key=value or key", s)
+	}
+	return label{key, value}, nil
+}
+
+func (l label) matches(labels map[string]string) bool {
+	value, ok := labels[l.key]
+	return ok && (l.value == "" || value == l.value)
+}
+
+// Gauge is a metric set by the sink (like prometheus.Gauge).
+type Gauge interface {
+	Set(float64)
+}
+
+// group holds the operations of a service and its endpoints.
+type group struct {
+	ops      []*localv1.OpItem
+	service  bool // the group has operations on the service
+	critical bool
+	rank     int // of the priority class of the service
+}
+
+// Sink passes the operations to the sink of a backend, programming its initial state in steps.
+type Sink struct {
+	sink   localsink.Sink
+	batch  int
+	labels []label
+
+	// Progress is set to the share of the services of the initial state programmed, if not nil.
+	Progress Gauge
+
+	done    bool
+	initial *localv1.OpItem   // the initial sync marker held, sent before the last sync
+	groups  []*group          // in the order of their first operation
+	byPath  map[string]*group // by service path
+	start   time.Time         // first operation held
+
+	now func() time.Time
+}
+
+var _ localsink.Sink = &Sink{}
+
+func New(cfg Config, sink localsink.Sink) *Sink {
+	s := &Sink{
+		sink:  sink,
+		batch: cfg.Batch,
+		now:   time.Now,
+	}
+	for _, l := range cfg.CriticalLabels {
+		if parsed, err := parseLabel(l); err == nil {
+			s.labels = append(s.labels, parsed)
+		}
+	}
+	s.clear()
+	return s
+}
+
+func (s *Sink) clear() {
+	s.initial = nil
+	s.groups = nil
+	s.byPath = map[string]*group{}
+	s.start = time.Time{}
+}
+
+func (s *Sink) Setup() {
+	s.sink.Setup()
+	if s.Progress != nil {
+		s.Progress.Set(0)
+	}
+}
+
+func (s *Sink) WaitRequest() (nodeName string, err error) {
+	return s.sink.WaitRequest()
+}
+
+// Reset drops the operations held, the state being sent again.
+func (s *Sink) Reset() {
+	s.clear()
+	s.sink.Reset()
+}
+
+func (s *Sink) Send(op *localv1.OpItem) error {
+	if s.done {
+		return s.sink.Send(op)
+	}
+
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Reset_:
+		s.clear()
+
+	case *localv1.OpItem_Set:
+		g := s.hold(v.Set.Ref.Path, op)
+		if v.Set.Ref.Set == localv1.Set_ServicesSet {
+			g.service = true
+			s.classify(g, v.Set.Bytes)
+		}
+		return nil
+
+	case *localv1.OpItem_Delete:
+		g := s.hold(v.Delete.Path, op)
+		if v.Delete.Set == localv1.Set_ServicesSet {
+			g.service = true
+		}
+		return nil
+
+	case *localv1.OpItem_InitialSync:
+		// the first complete state is only sent by the last step
+		s.initial = op
+		return nil
+
+	case *localv1.OpItem_Sync:
+		return s.program(op)
+	}
+
+	return s.sink.Send(op)
+}
+
+// hold keeps op in the group of the service of path ("namespace/name[/...]").
+func (s *Sink) hold(path string, op *localv1.OpItem) *group {
+	if s.start.IsZero() {
+		s.start = s.now()
+	}
+
+	parts := strings.SplitN(path, "/", 3)
+	svc := strings.Join(parts[:min(len(parts), 2)], "/")
+
+	g := s.byPath[svc]
+	if g == nil {
+		g = &group{rank: localv1.Priority_NormalPriority.Rank()}
+		s.byPath[svc] = g
+		s.groups = append(s.groups, g)
+	}
+	g.ops = append(g.ops, op)
+	return g
+}
+
+// classify sets the priority of the group of the encoded service.
+func (s *Sink) classify(g *group, ba []byte) {
+	svc := &localv1.Service{}
+	if err := proto.Unmarshal(ba, svc); err != nil {
+		// the backend reports it
+		return
+	}
+
+	g.rank = svc.Priority.Rank()
+	g.critical = svc.Priority == localv1.Priority_CriticalPriority
+	for _, l := range s.labels {
+		if l.matches(svc.Labels) {
+			g.critical = true
+		}
+	}
+}
+
+// program sends the operations held in steps, each followed by syncOp. All the steps are sent
+// even if one fails (a failed sync is retried by the backend), the first error is returned.
+func (s *Sink) program(syncOp *localv1.OpItem) (err error) {
+	var critical, others []*group
+	total := 0
+	for _, g := range s.groups {
+		if g.service {
+			total++
+		}
+		if g.critical {
+			critical = append(critical, g)
+		} else {
+			others = append(others, g)
+		}
+	}
+
+	sort.SliceStable(others, func(i, j int) bool { return others[i].rank > others[j].rank })
+
+	steps := [][]*group{}
+	if len(critical) != 0 {
+		steps = append(steps, critical)
+	}
+	batch := s.batch
+	if batch <= 0 {
+		batch = len(others)
+	}
+	for len(others) != 0 {
+		n := min(batch, len(others))
+		steps = append(steps, others[:n])
+		others = others[n:]
+	}
+	if len(steps) == 0 {
+		steps = append(steps, nil)
+	}
+
+	klog.Infof("programming the initial state: %d services (%d critical) in %d syncs", total, len(critical), len(steps))
+
+	programmed := 0
+	for i, step := range steps {
+		for _, g := range step {
+			for _, op := range g.ops {
+				if sendErr := s.sink.Send(op); sendErr != nil && err == nil {
+					err = sendErr
+				}
+			}
+			if g.service {
+				programmed++
+			}
+		}
+
+		if s.initial != nil && i == len(steps)-1 {
+			if sendErr := s.sink.Send(s.initial); sendErr != nil && err == nil {
+				err = sendErr
+			}
+		}
+
+		if syncErr := s.sink.Send(syncOp); syncErr != nil && err == nil {
+			err = syncErr
+		}
+
+		klog.Infof("initial state: %d/%d services programmed", programmed, total)
+		if s.Progress != nil {
+			if total == 0 {
+				s.Progress.Set(1)
+			} else {
+				s.Progress.Set(float64(programmed) / float64(total))
+			}
+		}
+	}
+
+	if !s.start.IsZero() {
+		klog.Infof("initial state programmed in %v", s.now().Sub(s.start))
+	}
+
+	s.done = true
+	s.clear()
+	return
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
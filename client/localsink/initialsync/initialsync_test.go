@@ -0,0 +1,191 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package initialsync
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+// recordSink records the paths of the operations sent, "sync" for the syncs and "initial" for the
+// initial sync marker.
+type recordSink struct{ ops []string }
+
+func (*recordSink) Setup()                       {}
+func (*recordSink) WaitRequest() (string, error) { return "node", nil }
+func (*recordSink) Reset()                       {}
+
+func (s *recordSink) Send(op *localv1.OpItem) error {
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Set:
+		s.ops = append(s.ops, v.Set.Ref.Path)
+	case *localv1.OpItem_Delete:
+		s.ops = append(s.ops, "-"+v.Delete.Path)
+	case *localv1.OpItem_InitialSync:
+		s.ops = append(s.ops, "initial")
+	case *localv1.OpItem_Sync:
+		s.ops = append(s.ops, "sync")
+	}
+	return nil
+}
+
+type gauge []float64
+
+func (g *gauge) Set(v float64) { *g = append(*g, v) }
+
+func serviceOp(t *testing.T, namespace, name string, labels map[string]string) *localv1.OpItem {
+	return encodedServiceOp(t, &localv1.Service{Namespace: namespace, Name: name, Labels: labels})
+}
+
+func encodedServiceOp(t *testing.T, svc *localv1.Service) *localv1.OpItem {
+	ba, err := proto.Marshal(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &localv1.OpItem{Op: &localv1.OpItem_Set{Set: &localv1.Value{
+		Ref:   &localv1.Ref{Set: localv1.Set_ServicesSet, Path: svc.NamespacedName()},
+		Bytes: ba,
+	}}}
+}
+
+func endpointOp(path string) *localv1.OpItem {
+	return &localv1.OpItem{Op: &localv1.OpItem_Set{Set: &localv1.Value{
+		Ref: &localv1.Ref{Set: localv1.Set_EndpointsSet, Path: path},
+	}}}
+}
+
+var (
+	syncOp        = &localv1.OpItem{Op: &localv1.OpItem_Sync{Sync: &localv1.EmptyOp{}}}
+	initialSyncOp = &localv1.OpItem{Op: &localv1.OpItem_InitialSync{InitialSync: &localv1.EmptyOp{}}}
+)
+
+func TestProgram(t *testing.T) {
+	backend := &recordSink{}
+	progress := &gauge{}
+
+	s := New(Config{CriticalLabels: []string{"k8s-app=kube-dns", "critical"}, Batch: 2}, backend)
+	s.Progress = progress
+	s.Setup()
+
+	for _, op := range []*localv1.OpItem{
+		serviceOp(t, "default", "a", nil),
+		serviceOp(t, "kube-system", "kube-dns", map[string]string{"k8s-app": "kube-dns"}),
+		serviceOp(t, "default", "b", map[string]string{"k8s-app": "other"}),
+		serviceOp(t, "default", "c", nil),
+		serviceOp(t, "ingress", "nginx", map[string]string{"critical": ""}),
+		endpointOp("default/a/x"),
+		endpointOp("kube-system/kube-dns/x"),
+		endpointOp("ingress/nginx/x"),
+	} {
+		if err := s.Send(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(backend.ops) != 0 {
+		t.Fatalf("operations sent before the sync: %v", backend.ops)
+	}
+
+	if err := s.Send(syncOp); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"kube-system/kube-dns", "kube-system/kube-dns/x", "ingress/nginx", "ingress/nginx/x", "sync",
+		"default/a", "default/a/x", "default/b", "sync",
+		"default/c", "sync",
+	}
+	if !reflect.DeepEqual(backend.ops, expected) {
+		t.Errorf("expected %v, got %v", expected, backend.ops)
+	}
+
+	if expected := (gauge{0, 0.4, 0.8, 1}); !reflect.DeepEqual(*progress, expected) {
+		t.Errorf("expected progress %v, got %v", expected, *progress)
+	}
+
+	// the next change sets are passed through
+	backend.ops = nil
+	s.Send(endpointOp("default/c/x"))
+	s.Send(syncOp)
+
+	if expected := []string{"default/c/x", "sync"}; !reflect.DeepEqual(backend.ops, expected) {
+		t.Errorf("expected %v, got %v", expected, backend.ops)
+	}
+}
+
+func TestProgramPriority(t *testing.T) {
+	backend := &recordSink{}
+
+	s := New(Config{Batch: 2}, backend)
+	for _, svc := range []*localv1.Service{
+		{Namespace: "default", Name: "low", Priority: localv1.Priority_LowPriority},
+		{Namespace: "default", Name: "a"},
+		{Namespace: "default", Name: "high", Priority: localv1.Priority_HighPriority},
+		{Namespace: "kube-system", Name: "kube-dns", Priority: localv1.Priority_CriticalPriority},
+		{Namespace: "default", Name: "b"},
+	} {
+		s.Send(encodedServiceOp(t, svc))
+	}
+	s.Send(initialSyncOp)
+	s.Send(syncOp)
+
+	// the state is only complete at the last sync
+	expected := []string{
+		"kube-system/kube-dns", "sync",
+		"default/high", "default/a", "sync",
+		"default/b", "default/low", "initial", "sync",
+	}
+	if !reflect.DeepEqual(backend.ops, expected) {
+		t.Errorf("expected %v, got %v", expected, backend.ops)
+	}
+}
+
+func TestEmptyState(t *testing.T) {
+	backend := &recordSink{}
+	progress := &gauge{}
+
+	s := New(Config{Batch: 10}, backend)
+	s.Progress = progress
+	s.Send(syncOp)
+
+	if expected := []string{"sync"}; !reflect.DeepEqual(backend.ops, expected) {
+		t.Errorf("expected %v, got %v", expected, backend.ops)
+	}
+	if expected := (gauge{1}); !reflect.DeepEqual(*progress, expected) {
+		t.Errorf("expected progress %v, got %v", expected, *progress)
+	}
+}
+
+func TestConfigCheck(t *testing.T) {
+	for _, tc := range []struct {
+		cfg Config
+		ok  bool
+	}{
+		{Config{CriticalLabels: []string{"k8s-app=kube-dns", "critical"}, Batch: 100}, true},
+		{Config{}, true},
+		{Config{Batch: -1}, false},
+		{Config{CriticalLabels: []string{"=value"}}, false},
+	} {
+		if err := tc.cfg.Check(); (err == nil) != tc.ok {
+			t.Errorf("%+v: expected ok=%t, got %v", tc.cfg, tc.ok, err)
+		}
+	}
+}
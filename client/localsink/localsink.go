@@ -51,3 +51,72 @@ func (c *Config) BindFlags(flags *pflag.FlagSet) {
 func (c *Config) WaitRequest() (nodeName string, err error) {
 	return c.NodeName, nil
 }
+
+// IPFamiliesRequester is implemented by the sinks requesting only the IPs of some families.
+type IPFamiliesRequester interface {
+	// IPFamilies returns the IP families of the IPs to send, all if empty.
+	IPFamilies() []localv1.IPFamily
+}
+
+// IPFamilies returns the IP families requested by the sink, nil for all.
+func IPFamilies(sink Sink) []localv1.IPFamily {
+	if r, ok := sink.(IPFamiliesRequester); ok {
+		return r.IPFamilies()
+	}
+	return nil
+}
+
+// TerminatingEndpointsRequester is implemented by the sinks requesting the endpoints that are not
+// ready but still serving while terminating, with the conditions of the endpoints.
+type TerminatingEndpointsRequester interface {
+	// WithTerminatingEndpoints returns true to request the terminating endpoints.
+	WithTerminatingEndpoints() bool
+}
+
+// WithTerminatingEndpoints returns true if the sink requests the terminating endpoints.
+func WithTerminatingEndpoints(sink Sink) bool {
+	if r, ok := sink.(TerminatingEndpointsRequester); ok {
+		return r.WithTerminatingEndpoints()
+	}
+	return false
+}
+
+// requestingSink holds the request of a sink to the server.
+type requestingSink struct {
+	Sink
+	families    []localv1.IPFamily
+	terminating bool
+}
+
+func (s requestingSink) IPFamilies() []localv1.IPFamily { return s.families }
+func (s requestingSink) WithTerminatingEndpoints() bool { return s.terminating }
+
+// requesting returns the request of the sink, to add to it.
+func requesting(sink Sink) requestingSink {
+	if r, ok := sink.(requestingSink); ok {
+		return r
+	}
+	return requestingSink{Sink: sink, families: IPFamilies(sink), terminating: WithTerminatingEndpoints(sink)}
+}
+
+// RequestingIPFamilies returns the sink requesting only the IPs of the given families (all if
+// empty) to the server.
+func RequestingIPFamilies(sink Sink, families []localv1.IPFamily) Sink {
+	if len(families) == 0 {
+		return sink
+	}
+	r := requesting(sink)
+	r.families = families
+	return r
+}
+
+// RequestingTerminatingEndpoints returns the sink requesting the terminating endpoints to the
+// server if terminating is true.
+func RequestingTerminatingEndpoints(sink Sink, terminating bool) Sink {
+	if !terminating {
+		return sink
+	}
+	r := requesting(sink)
+	r.terminating = true
+	return r
+}
@@ -0,0 +1,434 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package migrate moves the services of a node from a backend to another in steps: a growing
+// percentage of the services matching a label selector is programmed by the new backend, the
+// others staying on the old one. The services are probed through the old backend before being
+// migrated, and the ones that were reachable are probed again through the new backend before the
+// next step; if one of them isn't reachable anymore, the last step is rolled back and the
+// migration stops.
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash"
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink"
+)
+
+type Config struct {
+	// Selector restricts the migration to the services having these labels (`key=value` or
+	// `key`, comma separated; all services if empty).
+	Selector string
+	// Steps are the percentages of the selected services programmed by the new backend, in order.
+	Steps []int
+	// StepInterval is the time between two steps.
+	StepInterval time.Duration
+	// ProbeTimeout is the timeout of the connections probing the services.
+	ProbeTimeout time.Duration
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&c.Selector, "migrate-selector", "", "Labels of the services to migrate (key=value or key, comma separated; all services if empty)")
+	flags.IntSliceVar(&c.Steps, "migrate-steps", []int{10, 25, 50, 100}, "Percentages of the selected services moved to the new backend at each step")
+	flags.DurationVar(&c.StepInterval, "migrate-step-interval", 5*time.Minute, "Time between two migration steps")
+	flags.DurationVar(&c.ProbeTimeout, "migrate-probe-timeout", 2*time.Second, "Timeout of the connections probing the migrated services")
+}
+
+// Sink sends the services and their endpoints to the old or the new backend, depending on the
+// current step of the migration.
+type Sink struct {
+	cfg      Config
+	selector map[string]string
+	from, to localsink.Sink
+
+	// probe returns an error if svc can't be reached from the node
+	probe func(svc *localv1.Service) error
+
+	mu       sync.Mutex
+	step     int
+	halted   bool
+	services map[string]*service
+}
+
+type service struct {
+	svc       *localv1.Service
+	set       *localv1.OpItem
+	endpoints map[string]*localv1.OpItem
+	// migrated is true when the service is programmed by the new backend
+	migrated bool
+	// reachable is true if the service answered the probe before being migrated
+	reachable bool
+}
+
+var _ localsink.Sink = &Sink{}
+
+// New returns a sink migrating the services from a backend to another. All the services are
+// sent to the old backend until the first step, one interval after the setup.
+func New(cfg Config, from, to localsink.Sink) (*Sink, error) {
+	selector, err := parseSelector(cfg.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Steps) == 0 {
+		return nil, errors.New("no migration step")
+	}
+	prev := 0
+	for _, step := range cfg.Steps {
+		if step <= prev || step > 100 {
+			return nil, fmt.Errorf("migration steps must be increasing percentages, got %v", cfg.Steps)
+		}
+		prev = step
+	}
+
+	s := &Sink{
+		cfg:      cfg,
+		selector: selector,
+		from:     from,
+		to:       to,
+		step:     -1,
+		services: map[string]*service{},
+	}
+	s.probe = s.dial
+
+	return s, nil
+}
+
+func parseSelector(selector string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		key, value, hasValue := strings.Cut(term, "=")
+		if key == "" || strings.Contains(key, "!") {
+			return nil, fmt.Errorf("invalid selector term %q", term)
+		}
+		if !hasValue {
+			value = "*"
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+func (s *Sink) Setup() {
+	s.from.Setup()
+	s.to.Setup()
+
+	go func() {
+		for range time.Tick(s.cfg.StepInterval) {
+			if !s.next() {
+				return
+			}
+		}
+	}()
+}
+
+func (s *Sink) WaitRequest() (nodeName string, err error) {
+	return s.from.WaitRequest()
+}
+
+func (s *Sink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.services = map[string]*service{}
+	s.from.Reset()
+	s.to.Reset()
+}
+
+func (s *Sink) Send(op *localv1.OpItem) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Set:
+		switch v.Set.Ref.Set {
+		case localv1.Set_ServicesSet:
+			svc := &localv1.Service{}
+			if err = proto.Unmarshal(v.Set.Bytes, svc); err != nil {
+				return
+			}
+
+			state := s.service(v.Set.Ref.Path)
+
+			if migrated := s.selected(v.Set.Ref.Path, svc); migrated != state.migrated {
+				if err = s.remove(state); err != nil {
+					return
+				}
+				state.migrated = migrated
+				state.svc, state.set = svc, op
+				return s.add(state)
+			}
+
+			state.svc, state.set = svc, op
+
+		case localv1.Set_EndpointsSet:
+			state := s.service(servicePath(v.Set.Ref.Path))
+			state.endpoints[v.Set.Ref.Path] = op
+		}
+
+		return s.sinkOf(s.service(servicePath(v.Set.Ref.Path))).Send(op)
+
+	case *localv1.OpItem_Delete:
+		key := servicePath(v.Delete.Path)
+		state, ok := s.services[key]
+		if !ok {
+			return s.from.Send(op)
+		}
+
+		switch v.Delete.Set {
+		case localv1.Set_ServicesSet:
+			state.svc = nil
+			state.set = nil
+		case localv1.Set_EndpointsSet:
+			delete(state.endpoints, v.Delete.Path)
+		}
+
+		sink := s.sinkOf(state)
+		if state.svc == nil && len(state.endpoints) == 0 {
+			delete(s.services, key)
+		}
+		return sink.Send(op)
+
+	default: // sync and reset
+		if err = s.from.Send(op); err != nil {
+			return
+		}
+		return s.to.Send(op)
+	}
+}
+
+// servicePath returns the namespace/name of the service of a service or endpoint path.
+func servicePath(path string) string {
+	parts := strings.SplitN(path, "/", 3)
+	if len(parts) < 2 {
+		return path
+	}
+	return parts[0] + "/" + parts[1]
+}
+
+func (s *Sink) service(key string) *service {
+	state, ok := s.services[key]
+	if !ok {
+		state = &service{endpoints: map[string]*localv1.OpItem{}}
+		s.services[key] = state
+	}
+	return state
+}
+
+func (s *Sink) sinkOf(state *service) localsink.Sink {
+	if state.migrated {
+		return s.to
+	}
+	return s.from
+}
+
+// selected returns true if the service must be programmed by the new backend at the current step.
+func (s *Sink) selected(key string, svc *localv1.Service) bool {
+	if s.step < 0 {
+		return false
+	}
+
+	labels := svc.GetLabels()
+	for k, v := range s.selector {
+		value, ok := labels[k]
+		if !ok || (v != "*" && v != value) {
+			return false
+		}
+	}
+
+	return int(xxhash.Sum64String(key)%100) < s.cfg.Steps[s.step]
+}
+
+// remove deletes a service and its endpoints from the backend programming it.
+func (s *Sink) remove(state *service) error {
+	sink := s.sinkOf(state)
+
+	for path := range state.endpoints {
+		if err := sink.Send(deleteOp(localv1.Set_EndpointsSet, path)); err != nil {
+			return err
+		}
+	}
+	if state.set != nil {
+		return sink.Send(deleteOp(localv1.Set_ServicesSet, state.set.GetSet().Ref.Path))
+	}
+	return nil
+}
+
+// add sends a service and its endpoints to the backend programming it.
+func (s *Sink) add(state *service) error {
+	sink := s.sinkOf(state)
+
+	if state.set != nil {
+		if err := sink.Send(state.set); err != nil {
+			return err
+		}
+	}
+	for _, op := range state.endpoints {
+		if err := sink.Send(op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteOp(set localv1.Set, path string) *localv1.OpItem {
+	return &localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: &localv1.Ref{Set: set, Path: path}}}
+}
+
+// next checks the migrated services and moves to the next step if they are all reachable, or
+// back to the previous one if not. It returns false once the migration is over. The probes are
+// run without holding the lock, so they don't delay the updates.
+func (s *Sink) next() bool {
+	s.mu.Lock()
+	halted := s.halted
+	s.mu.Unlock()
+
+	if halted {
+		return false
+	}
+
+	migrated, _ := s.targets(func(state *service) bool { return state.migrated && state.reachable })
+
+	for key, svc := range migrated {
+		if err := s.probe(svc); err != nil {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			klog.Errorf("service %s is unreachable after its migration, rolling back to %d%% and stopping the migration: %v",
+				key, s.stepPercent(s.step-1), err)
+			s.halted = true
+			s.apply(s.step - 1)
+			return false
+		}
+	}
+
+	pending, last := s.targets(func(state *service) bool { return !state.migrated })
+	if last {
+		klog.Info("migration done, all the selected services are programmed by the new backend")
+		return false
+	}
+
+	// probe the services before migrating them, to only check the ones that could be reached
+	reachable := make(map[string]bool, len(pending))
+	for key, svc := range pending {
+		reachable[key] = s.probe(svc) == nil
+		if !reachable[key] {
+			klog.V(1).Infof("service %s is not reachable before its migration, it won't be checked after", key)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, state := range s.services {
+		if !state.migrated {
+			state.reachable = reachable[key]
+		}
+	}
+
+	s.apply(s.step + 1)
+	klog.Infof("migrating %d%% of the selected services", s.stepPercent(s.step))
+	return true
+}
+
+// targets returns the services matching filter, and whether the current step is the last one.
+func (s *Sink) targets(filter func(state *service) bool) (svcs map[string]*localv1.Service, last bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	svcs = map[string]*localv1.Service{}
+	for key, state := range s.services {
+		if state.svc != nil && filter(state) {
+			svcs[key] = state.svc
+		}
+	}
+	return svcs, s.step == len(s.cfg.Steps)-1
+}
+
+func (s *Sink) stepPercent(step int) int {
+	if step < 0 {
+		return 0
+	}
+	return s.cfg.Steps[step]
+}
+
+// apply moves the services to the backend they are assigned to by step, then syncs the backends.
+func (s *Sink) apply(step int) {
+	s.step = step
+
+	for key, state := range s.services {
+		migrated := state.svc != nil && s.selected(key, state.svc)
+		if migrated == state.migrated {
+			continue
+		}
+		if err := s.remove(state); err != nil {
+			klog.Errorf("failed to remove service %s from its backend: %v", key, err)
+		}
+		state.migrated = migrated
+		if err := s.add(state); err != nil {
+			klog.Errorf("failed to move service %s to its backend: %v", key, err)
+		}
+	}
+
+	sync := &localv1.OpItem{Op: &localv1.OpItem_Sync{Sync: &localv1.EmptyOp{}}}
+	for _, sink := range []localsink.Sink{s.from, s.to} {
+		if err := sink.Send(sync); err != nil {
+			klog.Error("failed to sync after a migration step: ", err)
+		}
+	}
+}
+
+// dial connects to the TCP ports of the cluster IPs of the service, which is reachable if one of
+// them accepts the connection. Services without TCP port are considered reachable.
+func (s *Sink) dial(svc *localv1.Service) (err error) {
+	ips := svc.GetIPs().GetClusterIPs()
+	if ips == nil {
+		return nil
+	}
+
+	for _, ip := range append(ips.V4, ips.V6...) {
+		for _, port := range svc.GetPorts() {
+			if port.Protocol != localv1.Protocol_TCP {
+				continue
+			}
+
+			var conn net.Conn
+			conn, err = net.DialTimeout("tcp", net.JoinHostPort(ip, strconv.Itoa(int(port.Port))), s.cfg.ProbeTimeout)
+			if err == nil {
+				conn.Close()
+				return nil
+			}
+		}
+	}
+	return
+}
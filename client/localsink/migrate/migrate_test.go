@@ -0,0 +1,217 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+// stateSink records the values set in a backend.
+type stateSink struct {
+	values map[localv1.Set]map[string]bool
+	syncs  int
+}
+
+func newStateSink() *stateSink {
+	return &stateSink{values: map[localv1.Set]map[string]bool{
+		localv1.Set_ServicesSet:  {},
+		localv1.Set_EndpointsSet: {},
+	}}
+}
+
+func (s *stateSink) Setup()                       {}
+func (s *stateSink) WaitRequest() (string, error) { return "node", nil }
+func (s *stateSink) Reset()                       {}
+
+func (s *stateSink) services() int { return len(s.values[localv1.Set_ServicesSet]) }
+
+func (s *stateSink) paths(set localv1.Set) (paths []string) {
+	for path := range s.values[set] {
+		paths = append(paths, path)
+	}
+	return
+}
+
+func (s *stateSink) Send(op *localv1.OpItem) error {
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Set:
+		s.values[v.Set.Ref.Set][v.Set.Ref.Path] = true
+	case *localv1.OpItem_Delete:
+		if !s.values[v.Delete.Set][v.Delete.Path] {
+			return fmt.Errorf("%s %s is not set", v.Delete.Set, v.Delete.Path)
+		}
+		delete(s.values[v.Delete.Set], v.Delete.Path)
+	case *localv1.OpItem_Sync:
+		s.syncs++
+	}
+	return nil
+}
+
+func setOp(t *testing.T, set localv1.Set, path string, msg proto.Message) *localv1.OpItem {
+	t.Helper()
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &localv1.OpItem{Op: &localv1.OpItem_Set{Set: &localv1.Value{Ref: &localv1.Ref{Set: set, Path: path}, Bytes: b}}}
+}
+
+// sendServices sends n services with one endpoint each, the even ones labelled app=web.
+func sendServices(t *testing.T, s *Sink, n int) {
+	for i := 0; i < n; i++ {
+		name := fmt.Sprint("svc-", i)
+		svc := &localv1.Service{Namespace: "default", Name: name}
+		if i%2 == 0 {
+			svc.Labels = map[string]string{"app": "web"}
+		}
+
+		// the endpoint is sent first, before its service is known
+		if err := s.Send(setOp(t, localv1.Set_EndpointsSet, "default/"+name+"/ep", &localv1.Endpoint{})); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Send(setOp(t, localv1.Set_ServicesSet, "default/"+name, svc)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	from, to := newStateSink(), newStateSink()
+
+	s, err := New(Config{Selector: "app=web", Steps: []int{10, 50, 100}}, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	probed := map[string]bool{}
+	s.probe = func(svc *localv1.Service) error {
+		probed[svc.NamespacedName()] = true
+		return nil
+	}
+
+	sendServices(t, s, 200)
+
+	check := func(min, max int) {
+		t.Helper()
+		if n := to.services(); n < min || n > max {
+			t.Errorf("expected %d to %d migrated services, got %d", min, max, n)
+		}
+		if from.services()+to.services() != 200 || len(from.paths(localv1.Set_EndpointsSet))+len(to.paths(localv1.Set_EndpointsSet)) != 200 {
+			t.Errorf("expected each service and endpoint in one backend, got %v and %v", from.values, to.values)
+		}
+		for _, path := range to.paths(localv1.Set_EndpointsSet) {
+			if !to.values[localv1.Set_ServicesSet][servicePath(path)] {
+				t.Errorf("endpoint %s migrated without its service", path)
+			}
+		}
+	}
+
+	check(0, 0)
+
+	if !s.next() {
+		t.Fatal("expected the migration to continue")
+	}
+	check(1, 25)
+
+	if !s.next() {
+		t.Fatal("expected the migration to continue")
+	}
+	check(30, 70)
+
+	if !s.next() {
+		t.Fatal("expected the migration to continue")
+	}
+	check(100, 100)
+
+	if s.next() {
+		t.Error("expected the migration to be done")
+	}
+
+	for _, path := range to.paths(localv1.Set_ServicesSet) {
+		if !probed[path] {
+			t.Errorf("service %s migrated without being probed", path)
+		}
+	}
+
+	// deleting a migrated service deletes it from the new backend
+	path := to.paths(localv1.Set_ServicesSet)[0]
+	if err := s.Send(&localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: &localv1.Ref{Set: localv1.Set_ServicesSet, Path: path}}}); err != nil {
+		t.Fatal(err)
+	}
+	if to.values[localv1.Set_ServicesSet][path] {
+		t.Errorf("expected %s to be deleted", path)
+	}
+}
+
+func TestMigrateRollback(t *testing.T) {
+	from, to := newStateSink(), newStateSink()
+
+	s, err := New(Config{Steps: []int{20, 60, 100}}, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unreachable := false
+	s.probe = func(svc *localv1.Service) error {
+		if unreachable {
+			return errors.New("timeout")
+		}
+		return nil
+	}
+
+	sendServices(t, s, 100)
+
+	if !s.next() || !s.next() {
+		t.Fatal("expected the migration to continue")
+	}
+	migrated := to.services()
+	syncs := to.syncs
+
+	unreachable = true
+	if s.next() {
+		t.Fatal("expected the migration to stop")
+	}
+
+	if n := to.services(); n >= migrated || n == 0 {
+		t.Errorf("expected the rollback to the first step, got %d migrated services (%d before)", n, migrated)
+	}
+	if to.syncs == syncs {
+		t.Error("expected the rollback to be synced")
+	}
+	if s.next() {
+		t.Error("expected the migration to stay stopped")
+	}
+}
+
+func TestNewValidation(t *testing.T) {
+	for _, cfg := range []Config{
+		{Steps: nil},
+		{Steps: []int{50, 20}},
+		{Steps: []int{10, 200}},
+		{Steps: []int{100}, Selector: "app!=web"},
+	} {
+		if _, err := New(cfg, newStateSink(), newStateSink()); err == nil {
+			t.Errorf("expected %+v to be rejected", cfg)
+		}
+	}
+}
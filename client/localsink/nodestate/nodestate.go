@@ -0,0 +1,199 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package nodestate summarizes what a backend programmed on the node (its services and endpoints,
+// its last sync and whether it failed), and periodically publishes it when it changed, so the
+// state of the fleet can be observed from the API server (as NodeProxyState objects) instead of
+// scraping the metrics of each node.
+package nodestate
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink"
+)
+
+type Config struct {
+	// Interval between two publications of the state, if it changed (0 disables them).
+	Interval time.Duration
+	// Kubeconfig of the API server the state is published to (in-cluster if empty).
+	Kubeconfig string
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.DurationVar(&c.Interval, "node-state-interval", 0, "Interval between the publications of the NodeProxyState of the node, when it changed (0 to disable)")
+	flags.StringVar(&c.Kubeconfig, "node-state-kubeconfig", "", "Kubeconfig of the API server the NodeProxyState of the node is published to (in-cluster config if empty)")
+}
+
+func (c *Config) Enabled() bool {
+	return c.Interval > 0
+}
+
+// State is the summary of what a backend programmed on a node.
+type State struct {
+	// Node is the name of the node.
+	Node string
+	// Backend is the backend programming the node (ie: to-iptables).
+	Backend string
+	// Services and Endpoints are the numbers of services and endpoints programmed.
+	Services  int
+	Endpoints int
+	// LastSync is the time of the last sync of the backend, zero before the first one.
+	LastSync time.Time
+	// Error is the error of the last sync, if it failed.
+	Error string
+	// Rejected are the services the backend refused to program at the last sync, with the reason
+	// (see the validate package).
+	Rejected []string
+}
+
+// Publisher publishes the state of a node (ie: as a NodeProxyState object).
+type Publisher interface {
+	Publish(ctx context.Context, state State) error
+}
+
+// Sink passes the operations to the sink of a backend, keeping the summary of its state.
+type Sink struct {
+	localsink.Sink
+
+	cfg       Config
+	publisher Publisher
+
+	// Rejected returns the services rejected by the backend, called after each sync if not nil.
+	Rejected func() []string
+
+	mu        sync.Mutex
+	state     State
+	services  map[string]bool
+	endpoints map[string]bool
+}
+
+var _ localsink.Sink = &Sink{}
+
+// New returns a sink publishing the state of the backend named backend with publisher.
+func New(cfg Config, backend string, publisher Publisher, sink localsink.Sink) *Sink {
+	return &Sink{
+		Sink:      sink,
+		cfg:       cfg,
+		publisher: publisher,
+		state:     State{Backend: backend},
+		services:  map[string]bool{},
+		endpoints: map[string]bool{},
+	}
+}
+
+func (s *Sink) Setup() {
+	s.Sink.Setup()
+
+	go func() {
+		var published State
+		for range time.Tick(s.cfg.Interval) {
+			state := s.State()
+			if state.Node == "" || reflect.DeepEqual(state, published) {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Interval)
+			err := s.publisher.Publish(ctx, state)
+			cancel()
+
+			if err != nil {
+				klog.Error("failed to publish the node state: ", err)
+				continue
+			}
+			published = state
+		}
+	}()
+}
+
+func (s *Sink) WaitRequest() (nodeName string, err error) {
+	nodeName, err = s.Sink.WaitRequest()
+	if err == nil {
+		s.mu.Lock()
+		s.state.Node = nodeName
+		s.mu.Unlock()
+	}
+	return
+}
+
+func (s *Sink) Reset() {
+	s.mu.Lock()
+	s.services = map[string]bool{}
+	s.endpoints = map[string]bool{}
+	s.mu.Unlock()
+
+	s.Sink.Reset()
+}
+
+func (s *Sink) Send(op *localv1.OpItem) (err error) {
+	err = s.Sink.Send(op)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Reset_:
+		s.services = map[string]bool{}
+		s.endpoints = map[string]bool{}
+
+	case *localv1.OpItem_Set:
+		if paths := s.paths(v.Set.Ref.Set); paths != nil {
+			paths[v.Set.Ref.Path] = true
+		}
+
+	case *localv1.OpItem_Delete:
+		delete(s.paths(v.Delete.Set), v.Delete.Path)
+
+	case *localv1.OpItem_Sync:
+		s.state.Services = len(s.services)
+		s.state.Endpoints = len(s.endpoints)
+		s.state.LastSync = time.Now()
+		s.state.Error = ""
+		if err != nil {
+			s.state.Error = err.Error()
+		}
+		if s.Rejected != nil {
+			s.state.Rejected = s.Rejected()
+		}
+	}
+
+	return
+}
+
+// paths returns the paths of the given set, nil if it isn't counted. Assumes s.mu is held.
+func (s *Sink) paths(set localv1.Set) map[string]bool {
+	switch set {
+	case localv1.Set_ServicesSet:
+		return s.services
+	case localv1.Set_EndpointsSet:
+		return s.endpoints
+	}
+	return nil
+}
+
+// State returns the state of the backend at its last sync.
+func (s *Sink) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
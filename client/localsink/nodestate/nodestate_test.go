@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodestate
+
+import (
+	"errors"
+	"testing"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+type fakeSink struct {
+	syncErr error
+}
+
+func (*fakeSink) Setup()                       {}
+func (*fakeSink) WaitRequest() (string, error) { return "node-1", nil }
+func (*fakeSink) Reset()                       {}
+
+func (s *fakeSink) Send(op *localv1.OpItem) error {
+	if _, ok := op.Op.(*localv1.OpItem_Sync); ok {
+		return s.syncErr
+	}
+	return nil
+}
+
+func set(s localv1.Set, path string) *localv1.OpItem {
+	return &localv1.OpItem{Op: &localv1.OpItem_Set{Set: &localv1.Value{Ref: &localv1.Ref{Set: s, Path: path}}}}
+}
+
+func del(s localv1.Set, path string) *localv1.OpItem {
+	return &localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: &localv1.Ref{Set: s, Path: path}}}
+}
+
+var syncOp = &localv1.OpItem{Op: &localv1.OpItem_Sync{Sync: &localv1.EmptyOp{}}}
+
+func TestState(t *testing.T) {
+	inner := &fakeSink{}
+	s := New(Config{}, "to-iptables", nil, inner)
+
+	if _, err := s.WaitRequest(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, op := range []*localv1.OpItem{
+		set(localv1.Set_ServicesSet, "ns/a"),
+		set(localv1.Set_ServicesSet, "ns/b"),
+		set(localv1.Set_EndpointsSet, "ns/a/ep1"),
+		set(localv1.Set_EndpointsSet, "ns/a/ep2"),
+		set(localv1.Set_GlobalNodeInfos, "node-1"),
+	} {
+		s.Send(op)
+	}
+
+	// not synced yet
+	if st := s.State(); st.Services != 0 || !st.LastSync.IsZero() {
+		t.Errorf("state changed before the sync: %+v", st)
+	}
+
+	s.Send(syncOp)
+	st := s.State()
+	if st.Node != "node-1" || st.Backend != "to-iptables" || st.Services != 2 || st.Endpoints != 2 || st.LastSync.IsZero() || st.Error != "" {
+		t.Errorf("unexpected state: %+v", st)
+	}
+
+	inner.syncErr = errors.New("iptables-restore failed")
+	s.Send(del(localv1.Set_EndpointsSet, "ns/a/ep2"))
+	if err := s.Send(syncOp); err != inner.syncErr {
+		t.Errorf("the sync error must be returned, got %v", err)
+	}
+	if st := s.State(); st.Endpoints != 1 || st.Error != "iptables-restore failed" {
+		t.Errorf("unexpected state after a failed sync: %+v", st)
+	}
+
+	inner.syncErr = nil
+	s.Rejected = func() []string { return []string{"ns/b: SCTP is not supported"} }
+	s.Send(syncOp)
+	if st := s.State(); len(st.Rejected) != 1 || st.Rejected[0] != "ns/b: SCTP is not supported" {
+		t.Errorf("unexpected rejected services: %+v", st)
+	}
+
+	s.Reset()
+	s.Send(set(localv1.Set_ServicesSet, "ns/a"))
+	s.Send(syncOp)
+	if st := s.State(); st.Services != 1 || st.Endpoints != 0 || st.Error != "" {
+		t.Errorf("unexpected state after a reset: %+v", st)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package readyfilter lets the backends not handling the terminating endpoints share a stream
+// requesting them (ie: while migrating to a backend falling back to them): only the ready endpoints
+// are passed, the endpoints becoming not ready being deleted.
+package readyfilter
+
+import (
+	"google.golang.org/protobuf/proto"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink"
+)
+
+// Backend is implemented by the backends handling the terminating endpoints.
+type Backend interface {
+	// WithTerminatingEndpoints returns true if the backend handles the terminating endpoints.
+	WithTerminatingEndpoints() bool
+}
+
+// Handles returns true if the backend handles the terminating endpoints.
+func Handles(backend interface{}) bool {
+	b, ok := backend.(Backend)
+	return ok && b.WithTerminatingEndpoints()
+}
+
+// Sink passes the operations to the sink of a backend with only the ready endpoints.
+type Sink struct {
+	sink localsink.Sink
+	sent map[string]bool // endpoint paths sent to the backend
+}
+
+var _ localsink.Sink = &Sink{}
+
+func New(sink localsink.Sink) *Sink {
+	return &Sink{sink: sink, sent: map[string]bool{}}
+}
+
+func (s *Sink) Setup() { s.sink.Setup() }
+
+func (s *Sink) WaitRequest() (nodeName string, err error) {
+	return s.sink.WaitRequest()
+}
+
+func (s *Sink) Reset() {
+	s.sent = map[string]bool{}
+	s.sink.Reset()
+}
+
+func (s *Sink) Send(op *localv1.OpItem) error {
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Reset_:
+		s.sent = map[string]bool{}
+
+	case *localv1.OpItem_Set:
+		if v.Set.Ref.Set != localv1.Set_EndpointsSet {
+			break
+		}
+
+		path := v.Set.Ref.Path
+
+		ep := &localv1.Endpoint{}
+		if err := proto.Unmarshal(v.Set.Bytes, ep); err != nil {
+			// the backend reports it
+			break
+		}
+
+		if !ep.IsReady() {
+			if !s.sent[path] {
+				return nil
+			}
+			delete(s.sent, path)
+			return s.sink.Send(&localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: v.Set.Ref}})
+		}
+
+		s.sent[path] = true
+
+	case *localv1.OpItem_Delete:
+		if v.Delete.Set != localv1.Set_EndpointsSet {
+			break
+		}
+		if !s.sent[v.Delete.Path] {
+			return nil
+		}
+		delete(s.sent, v.Delete.Path)
+	}
+
+	return s.sink.Send(op)
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package readyfilter
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+// recordingSink records the operations it receives.
+type recordingSink struct {
+	ops []string
+}
+
+func (*recordingSink) Setup()                       {}
+func (*recordingSink) WaitRequest() (string, error) { return "node", nil }
+func (s *recordingSink) Reset()                     { s.ops = append(s.ops, "reset") }
+
+func (s *recordingSink) Send(op *localv1.OpItem) error {
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Set:
+		s.ops = append(s.ops, "set "+v.Set.Ref.Path)
+	case *localv1.OpItem_Delete:
+		s.ops = append(s.ops, "del "+v.Delete.Path)
+	case *localv1.OpItem_Sync:
+		s.ops = append(s.ops, "sync")
+	}
+	return nil
+}
+
+func set(s localv1.Set, path string, m proto.Message) *localv1.OpItem {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	return &localv1.OpItem{Op: &localv1.OpItem_Set{Set: &localv1.Value{Ref: &localv1.Ref{Set: s, Path: path}, Bytes: b}}}
+}
+
+func setEndpoint(key string, conditions *localv1.EndpointConditions) *localv1.OpItem {
+	return set(localv1.Set_EndpointsSet, "ns/svc/"+key, &localv1.Endpoint{IPs: localv1.NewIPSet("10.1.0.1"), Conditions: conditions})
+}
+
+func deleteEndpoint(key string) *localv1.OpItem {
+	return &localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: &localv1.Ref{Set: localv1.Set_EndpointsSet, Path: "ns/svc/" + key}}}
+}
+
+var (
+	syncOp      = &localv1.OpItem{Op: &localv1.OpItem_Sync{Sync: &localv1.EmptyOp{}}}
+	ready       = &localv1.EndpointConditions{Ready: true, Serving: true}
+	terminating = &localv1.EndpointConditions{Serving: true, Terminating: true}
+)
+
+func TestReadyFilter(t *testing.T) {
+	inner := &recordingSink{}
+	s := New(inner)
+
+	send := func(ops ...*localv1.OpItem) {
+		t.Helper()
+		for _, op := range ops {
+			if err := s.Send(op); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	expect := func(ops ...string) {
+		t.Helper()
+		if !reflect.DeepEqual(inner.ops, ops) {
+			t.Errorf("expected %q, got %q", ops, inner.ops)
+		}
+		inner.ops = nil
+	}
+
+	send(set(localv1.Set_ServicesSet, "ns/svc", &localv1.Service{Namespace: "ns", Name: "svc"}),
+		setEndpoint("1", ready), setEndpoint("2", nil), setEndpoint("3", terminating), syncOp)
+	expect("set ns/svc", "set ns/svc/1", "set ns/svc/2", "sync")
+
+	// the terminating endpoint was never sent
+	send(deleteEndpoint("3"), syncOp)
+	expect("sync")
+
+	// a ready endpoint starts terminating
+	send(setEndpoint("1", terminating), syncOp)
+	expect("del ns/svc/1", "sync")
+
+	send(deleteEndpoint("1"), deleteEndpoint("2"), syncOp)
+	expect("del ns/svc/2", "sync")
+}
+
+type handling bool
+
+func (h handling) WithTerminatingEndpoints() bool { return bool(h) }
+
+func TestHandles(t *testing.T) {
+	if Handles(struct{}{}) || Handles(handling(false)) {
+		t.Error("expected the backend not to handle the terminating endpoints")
+	}
+	if !Handles(handling(true)) {
+		t.Error("expected the backend to handle the terminating endpoints")
+	}
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package requeue retains the last full state sent to a sink, and re-delivers it when a sync
+// fails (the Send of the Sync operation returns an error), so a transient failure of the backend
+// (ie: a busy xtables lock) heals without waiting for the next change upstream.
+//
+// The state is re-delivered as after a reconnection: a Reset, the state and a Sync. Only the
+// backends reporting their failed syncs (see ReportsFailures) are wrapped, the state is not
+// retained for the others.
+package requeue
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink"
+	"sigs.k8s.io/kpng/client/localsink/decoder"
+)
+
+type Config struct {
+	// Backoff is the delay before the first re-delivery after a failed sync (0 disables them).
+	Backoff time.Duration
+	// MaxBackoff caps the delay, doubled after each failure.
+	MaxBackoff time.Duration
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.DurationVar(&c.Backoff, "sync-retry-backoff", time.Second, "Delay before the state is re-delivered to the backend after a failed sync (for the backends reporting them, ie: to-iptables), doubled after each failure (0 to disable)")
+	flags.DurationVar(&c.MaxBackoff, "sync-retry-max-backoff", time.Minute, "Maximum delay between the re-deliveries of the state after failed syncs")
+}
+
+// ReportsFailures returns true if the backend reports its failed syncs, the only ones re-delivered.
+func ReportsFailures(backend interface{}) bool {
+	_, ok := backend.(decoder.FailingSyncer)
+	return ok
+}
+
+// Counter is a metric incremented by the sink (like prometheus.Counter).
+type Counter interface {
+	Inc()
+}
+
+// Sink passes the operations to the sink of a backend, and re-delivers the state if it fails.
+// The failures are not returned upstream.
+type Sink struct {
+	sink localsink.Sink
+	cfg  Config
+
+	// Failed is incremented on each failed sync, if not nil.
+	Failed Counter
+	// Requeued is incremented on each re-delivery of the state, if not nil.
+	Requeued Counter
+
+	// mu serializes the operations from upstream and the re-deliveries
+	mu       sync.Mutex
+	state    map[ref]*localv1.OpItem
+	inFlight bool // a change set is being received
+	failed   bool
+	backoff  time.Duration
+	timer    *time.Timer
+
+	afterFunc func(d time.Duration, f func()) *time.Timer
+}
+
+type ref struct {
+	set  localv1.Set
+	path string
+}
+
+var _ localsink.Sink = &Sink{}
+
+func New(cfg Config, sink localsink.Sink) *Sink {
+	return &Sink{
+		sink:      sink,
+		cfg:       cfg,
+		state:     map[ref]*localv1.OpItem{},
+		afterFunc: time.AfterFunc,
+	}
+}
+
+func (s *Sink) Setup() { s.sink.Setup() }
+
+func (s *Sink) WaitRequest() (nodeName string, err error) {
+	return s.sink.WaitRequest()
+}
+
+func (s *Sink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the full state will be sent again
+	s.state = map[ref]*localv1.OpItem{}
+	s.sink.Reset()
+}
+
+func (s *Sink) Send(op *localv1.OpItem) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Reset_:
+		s.state = map[ref]*localv1.OpItem{}
+
+	case *localv1.OpItem_Set:
+		s.state[ref{v.Set.Ref.Set, v.Set.Ref.Path}] = op
+
+	case *localv1.OpItem_Delete:
+		delete(s.state, ref{v.Delete.Set, v.Delete.Path})
+
+	case *localv1.OpItem_Sync:
+		s.inFlight = false
+		s.synced(s.sink.Send(op))
+		return nil
+	}
+
+	s.inFlight = true
+	return s.sink.Send(op)
+}
+
+// SyncFailed re-delivers the state after a sync done by the backend on its own failed (see
+// decoder.BackgroundSyncer).
+func (s *Sink) SyncFailed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.synced(err)
+}
+
+// synced handles the result of a sync, scheduling a re-delivery if it failed.
+// Assumes s.mu is held.
+func (s *Sink) synced(err error) {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	if err == nil {
+		if s.failed {
+			klog.Info("sync succeeded after a failure")
+		}
+		s.failed = false
+		s.backoff = 0
+		return
+	}
+
+	if s.Failed != nil {
+		s.Failed.Inc()
+	}
+
+	s.failed = true
+	if s.cfg.Backoff <= 0 {
+		klog.Error("sync failed: ", err)
+		return
+	}
+
+	switch {
+	case s.backoff == 0:
+		s.backoff = s.cfg.Backoff
+	case s.backoff*2 > s.cfg.MaxBackoff:
+		s.backoff = s.cfg.MaxBackoff
+	default:
+		s.backoff *= 2
+	}
+
+	klog.Error("sync failed, re-delivering the state in ", s.backoff, ": ", err)
+	s.timer = s.afterFunc(s.backoff, s.requeue)
+}
+
+// requeue re-delivers the state, unless a change set is being received (its sync will be
+// retried instead).
+func (s *Sink) requeue() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.failed || s.inFlight {
+		return
+	}
+
+	if s.Requeued != nil {
+		s.Requeued.Inc()
+	}
+
+	refs := make([]ref, 0, len(s.state))
+	for r := range s.state {
+		refs = append(refs, r)
+	}
+	// the services before their endpoints
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].set != refs[j].set {
+			return refs[i].set < refs[j].set
+		}
+		return refs[i].path < refs[j].path
+	})
+
+	s.sink.Reset()
+	for _, r := range refs {
+		if err := s.sink.Send(s.state[r]); err != nil {
+			s.synced(err)
+			return
+		}
+	}
+
+	s.synced(s.sink.Send(&localv1.OpItem{Op: &localv1.OpItem_Sync{}}))
+}
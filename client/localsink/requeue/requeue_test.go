@@ -0,0 +1,188 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package requeue
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+// failingSink records the operations it receives, and fails the syncs while fail > 0.
+type failingSink struct {
+	fail int
+	ops  []string
+}
+
+func (*failingSink) Setup()                       {}
+func (*failingSink) WaitRequest() (string, error) { return "node", nil }
+func (s *failingSink) Reset()                     { s.ops = append(s.ops, "reset") }
+
+func (s *failingSink) Send(op *localv1.OpItem) error {
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Set:
+		s.ops = append(s.ops, "set "+v.Set.Ref.Path)
+	case *localv1.OpItem_Delete:
+		s.ops = append(s.ops, "del "+v.Delete.Path)
+	case *localv1.OpItem_Sync:
+		s.ops = append(s.ops, "sync")
+		if s.fail > 0 {
+			s.fail--
+			return errors.New("sync failed")
+		}
+	}
+	return nil
+}
+
+type counter int
+
+func (c *counter) Inc() { *c++ }
+
+func set(s localv1.Set, path string) *localv1.OpItem {
+	return &localv1.OpItem{Op: &localv1.OpItem_Set{Set: &localv1.Value{Ref: &localv1.Ref{Set: s, Path: path}}}}
+}
+
+func del(s localv1.Set, path string) *localv1.OpItem {
+	return &localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: &localv1.Ref{Set: s, Path: path}}}
+}
+
+var syncOp = &localv1.OpItem{Op: &localv1.OpItem_Sync{Sync: &localv1.EmptyOp{}}}
+
+func TestRequeue(t *testing.T) {
+	inner := &failingSink{fail: 2}
+	s := New(Config{Backoff: time.Second, MaxBackoff: 3 * time.Second}, inner)
+
+	failed, requeued := new(counter), new(counter)
+	s.Failed, s.Requeued = failed, requeued
+
+	var delays []time.Duration
+	var retry func()
+	s.afterFunc = func(d time.Duration, f func()) *time.Timer {
+		delays = append(delays, d)
+		retry = f
+		return time.NewTimer(time.Hour)
+	}
+
+	for _, op := range []*localv1.OpItem{
+		set(localv1.Set_EndpointsSet, "ns/a/ep"),
+		set(localv1.Set_ServicesSet, "ns/b"),
+		set(localv1.Set_ServicesSet, "ns/a"),
+		set(localv1.Set_ServicesSet, "ns/c"),
+		del(localv1.Set_ServicesSet, "ns/c"),
+		syncOp,
+	} {
+		if err := s.Send(op); err != nil {
+			t.Fatal("the failure must not be returned upstream: ", err)
+		}
+	}
+
+	inner.ops = nil
+	retry() // fails again
+	retry() // succeeds
+
+	expected := []string{
+		"reset", "set ns/a", "set ns/b", "set ns/a/ep", "sync",
+		"reset", "set ns/a", "set ns/b", "set ns/a/ep", "sync",
+	}
+	if !reflect.DeepEqual(inner.ops, expected) {
+		t.Errorf("re-delivered %q, expected %q", inner.ops, expected)
+	}
+
+	if expected := []time.Duration{time.Second, 2 * time.Second}; !reflect.DeepEqual(delays, expected) {
+		t.Errorf("delays are %v, expected %v", delays, expected)
+	}
+	if *failed != 2 || *requeued != 2 {
+		t.Errorf("%d failures and %d re-deliveries counted, expected 2 and 2", *failed, *requeued)
+	}
+
+	// nothing to re-deliver after a success
+	inner.ops = nil
+	retry()
+	if len(inner.ops) != 0 {
+		t.Errorf("re-delivered %q after a successful sync", inner.ops)
+	}
+}
+
+func TestRequeueInFlight(t *testing.T) {
+	inner := &failingSink{fail: 1}
+	s := New(Config{Backoff: time.Second, MaxBackoff: time.Second}, inner)
+
+	var retry func()
+	s.afterFunc = func(d time.Duration, f func()) *time.Timer {
+		retry = f
+		return time.NewTimer(time.Hour)
+	}
+
+	s.Send(set(localv1.Set_ServicesSet, "ns/a"))
+	s.Send(syncOp)
+
+	// a change set is being received, its sync will be retried instead
+	s.Send(set(localv1.Set_ServicesSet, "ns/b"))
+
+	inner.ops = nil
+	retry()
+	if len(inner.ops) != 0 {
+		t.Errorf("re-delivered %q during a change set", inner.ops)
+	}
+
+	s.Send(syncOp)
+	if expected := []string{"sync"}; !reflect.DeepEqual(inner.ops, expected) {
+		t.Errorf("sent %q, expected %q", inner.ops, expected)
+	}
+}
+
+type failingSyncer struct{}
+
+func (failingSyncer) SyncErr() error { return nil }
+
+func TestReportsFailures(t *testing.T) {
+	if ReportsFailures(struct{}{}) {
+		t.Error("expected a backend without SyncErr not to report its failures")
+	}
+	if !ReportsFailures(failingSyncer{}) {
+		t.Error("expected a FailingSyncer to report its failures")
+	}
+}
+
+func TestSyncFailed(t *testing.T) {
+	inner := &failingSink{}
+	s := New(Config{Backoff: time.Second, MaxBackoff: time.Second}, inner)
+
+	var retry func()
+	s.afterFunc = func(d time.Duration, f func()) *time.Timer {
+		retry = f
+		return time.NewTimer(time.Hour)
+	}
+
+	s.Send(set(localv1.Set_ServicesSet, "ns/a"))
+	s.Send(syncOp)
+
+	// the backend failed to sync on its own
+	s.SyncFailed(errors.New("resync failed"))
+	if retry == nil {
+		t.Fatal("expected a re-delivery to be scheduled")
+	}
+
+	inner.ops = nil
+	retry()
+	if expected := []string{"reset", "set ns/a", "sync"}; !reflect.DeepEqual(inner.ops, expected) {
+		t.Errorf("re-delivered %q, expected %q", inner.ops, expected)
+	}
+}
@@ -0,0 +1,315 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package selftest checks that a backend really programs the services it was given: it
+// periodically connects, from the node, to a sample of the cluster IPs and ports of the services
+// having endpoints, and reports the ones that can't be reached. A failing target usually means
+// the rules of the backend drifted from the state it was sent.
+package selftest
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink"
+)
+
+type Config struct {
+	// Interval between two rounds of probes (0 disables the self-test).
+	Interval time.Duration
+	// Sample is the number of targets probed in each round.
+	Sample int
+	// Timeout of a probe.
+	Timeout time.Duration
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.DurationVar(&c.Interval, "self-test-interval", 0, "Interval between the self-tests connecting to a sample of the cluster IPs of the services (0 to disable)")
+	flags.IntVar(&c.Sample, "self-test-sample", 10, "Number of service ports probed by each self-test")
+	flags.DurationVar(&c.Timeout, "self-test-timeout", time.Second, "Timeout of a self-test probe")
+}
+
+func (c *Config) Enabled() bool {
+	return c.Interval > 0
+}
+
+// Counter is a metric incremented by the self-test (like prometheus.Counter).
+type Counter interface {
+	Inc()
+}
+
+// Gauge is a metric set by the self-test (like prometheus.Gauge).
+type Gauge interface {
+	Set(float64)
+}
+
+// Sink passes the operations to the sink of a backend, keeping the services to probe them once
+// they are synced.
+type Sink struct {
+	localsink.Sink
+
+	cfg Config
+
+	// Succeeded is incremented on each successful probe, if not nil.
+	Succeeded Counter
+	// Failed is incremented on each failed probe, if not nil.
+	Failed Counter
+	// Failing is set to the number of failed probes of the last round, if not nil.
+	Failing Gauge
+
+	// probe returns an error if the target can't be reached
+	probe func(ctx context.Context, t target) error
+
+	mu        sync.Mutex
+	synced    bool
+	services  map[string]*localv1.Service
+	endpoints map[string]map[string]bool // local flag by endpoint key, by service
+}
+
+// target is a port of a cluster IP.
+type target struct {
+	service  string
+	protocol localv1.Protocol
+	addr     string
+}
+
+var _ localsink.Sink = &Sink{}
+
+func New(cfg Config, sink localsink.Sink) *Sink {
+	s := &Sink{
+		Sink:      sink,
+		cfg:       cfg,
+		services:  map[string]*localv1.Service{},
+		endpoints: map[string]map[string]bool{},
+	}
+	s.probe = s.dial
+	return s
+}
+
+func (s *Sink) Setup() {
+	s.Sink.Setup()
+
+	go func() {
+		for range time.Tick(s.cfg.Interval) {
+			s.run(context.Background())
+		}
+	}()
+}
+
+func (s *Sink) Reset() {
+	s.mu.Lock()
+	s.synced = false
+	s.services = map[string]*localv1.Service{}
+	s.endpoints = map[string]map[string]bool{}
+	s.mu.Unlock()
+
+	s.Sink.Reset()
+}
+
+func (s *Sink) Send(op *localv1.OpItem) (err error) {
+	if err = s.Sink.Send(op); err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Set:
+		switch v.Set.Ref.Set {
+		case localv1.Set_ServicesSet:
+			svc := &localv1.Service{}
+			if err := proto.Unmarshal(v.Set.Bytes, svc); err != nil {
+				return err
+			}
+			s.services[v.Set.Ref.Path] = svc
+
+		case localv1.Set_EndpointsSet:
+			ep := &localv1.Endpoint{}
+			if err := proto.Unmarshal(v.Set.Bytes, ep); err != nil {
+				return err
+			}
+
+			key, epKey := splitEndpointPath(v.Set.Ref.Path)
+			if s.endpoints[key] == nil {
+				s.endpoints[key] = map[string]bool{}
+			}
+			s.endpoints[key][epKey] = ep.Local
+		}
+
+	case *localv1.OpItem_Delete:
+		switch v.Delete.Set {
+		case localv1.Set_ServicesSet:
+			delete(s.services, v.Delete.Path)
+
+		case localv1.Set_EndpointsSet:
+			key, epKey := splitEndpointPath(v.Delete.Path)
+			delete(s.endpoints[key], epKey)
+			if len(s.endpoints[key]) == 0 {
+				delete(s.endpoints, key)
+			}
+		}
+
+	case *localv1.OpItem_Sync:
+		s.synced = true
+	}
+
+	return
+}
+
+// splitEndpointPath splits namespace/service/key into the service and the endpoint key.
+func splitEndpointPath(path string) (service, key string) {
+	parts := strings.SplitN(path, "/", 3)
+	if len(parts) < 3 {
+		return path, ""
+	}
+	return parts[0] + "/" + parts[1], parts[2]
+}
+
+// targets returns the ports of the cluster IPs that must be reachable from the node: the ones of
+// the services with endpoints (local ones if the internal traffic policy is Local).
+func (s *Sink) targets() (targets []target) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.synced {
+		return nil
+	}
+
+	for key, svc := range s.services {
+		reachable := false
+		for _, local := range s.endpoints[key] {
+			if local || !svc.InternalTrafficToLocal {
+				reachable = true
+				break
+			}
+		}
+		if !reachable {
+			continue
+		}
+
+		ips := svc.GetIPs().GetClusterIPs()
+		if ips == nil {
+			continue
+		}
+
+		for _, ip := range append(append([]string{}, ips.V4...), ips.V6...) {
+			for _, port := range svc.Ports {
+				if port.Protocol != localv1.Protocol_TCP && port.Protocol != localv1.Protocol_UDP {
+					continue // SCTP can't be probed
+				}
+
+				targets = append(targets, target{
+					service:  key,
+					protocol: port.Protocol,
+					addr:     net.JoinHostPort(ip, strconv.Itoa(int(port.Port))),
+				})
+			}
+		}
+	}
+
+	return
+}
+
+// run probes a sample of the targets, returning the number of failures.
+func (s *Sink) run(ctx context.Context) (failing int) {
+	targets := s.targets()
+
+	rand.Shuffle(len(targets), func(i, j int) { targets[i], targets[j] = targets[j], targets[i] })
+	if len(targets) > s.cfg.Sample {
+		targets = targets[:s.cfg.Sample]
+	}
+
+	errs := make([]error, len(targets))
+	wg := sync.WaitGroup{}
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t target) {
+			defer wg.Done()
+			errs[i] = s.probe(ctx, t)
+		}(i, t)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		t := targets[i]
+
+		if err == nil {
+			klog.V(3).Infof("self-test: service %s reachable at %s/%s", t.service, t.protocol, t.addr)
+			if s.Succeeded != nil {
+				s.Succeeded.Inc()
+			}
+			continue
+		}
+
+		failing++
+		klog.Warningf("self-test: service %s unreachable at %s/%s, the rules may not be programmed: %v", t.service, t.protocol, t.addr, err)
+		if s.Failed != nil {
+			s.Failed.Inc()
+		}
+	}
+
+	if s.Failing != nil {
+		s.Failing.Set(float64(failing))
+	}
+	return
+}
+
+// dial connects to a TCP target. An UDP target fails only if the node answers that the port is
+// unreachable (ie: no rule translated the cluster IP), as a silent service is normal.
+func (s *Sink) dial(ctx context.Context, t target) error {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	if t.protocol == localv1.Protocol_TCP {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", t.addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "udp", t.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte{0}); err != nil {
+		return err
+	}
+
+	deadline, _ := ctx.Deadline()
+	conn.SetReadDeadline(deadline)
+
+	_, err = conn.Read(make([]byte, 1))
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return err
+	}
+	return nil
+}
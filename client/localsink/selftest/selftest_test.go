@@ -0,0 +1,158 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package selftest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+type nopSink struct{}
+
+func (nopSink) Setup()                       {}
+func (nopSink) WaitRequest() (string, error) { return "node", nil }
+func (nopSink) Reset()                       {}
+func (nopSink) Send(*localv1.OpItem) error   { return nil }
+
+type counter int
+
+func (c *counter) Inc()          { *c++ }
+func (c *counter) Set(v float64) { *c = counter(v) }
+
+func send(t *testing.T, s *Sink, set localv1.Set, path string, msg proto.Message) {
+	t.Helper()
+
+	op := &localv1.OpItem{Op: &localv1.OpItem_Sync{Sync: &localv1.EmptyOp{}}}
+	if msg != nil {
+		b, err := proto.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		op = &localv1.OpItem{Op: &localv1.OpItem_Set{Set: &localv1.Value{Ref: &localv1.Ref{Set: set, Path: path}, Bytes: b}}}
+	}
+
+	if err := s.Send(op); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func service(name string, local bool, ports ...*localv1.PortMapping) *localv1.Service {
+	return &localv1.Service{
+		Namespace:              "default",
+		Name:                   name,
+		IPs:                    &localv1.ServiceIPs{ClusterIPs: &localv1.IPSet{V4: []string{"10.0.0.1"}, V6: []string{"fd00::1"}}},
+		Ports:                  ports,
+		InternalTrafficToLocal: local,
+	}
+}
+
+func TestTargets(t *testing.T) {
+	s := New(Config{Sample: 100}, nopSink{})
+
+	tcp := &localv1.PortMapping{Protocol: localv1.Protocol_TCP, Port: 80}
+	udp := &localv1.PortMapping{Protocol: localv1.Protocol_UDP, Port: 53}
+	sctp := &localv1.PortMapping{Protocol: localv1.Protocol_SCTP, Port: 9}
+
+	send(t, s, localv1.Set_ServicesSet, "default/web", service("web", false, tcp, sctp))
+	send(t, s, localv1.Set_EndpointsSet, "default/web/a", &localv1.Endpoint{})
+	send(t, s, localv1.Set_ServicesSet, "default/dns", service("dns", true, udp))
+	send(t, s, localv1.Set_EndpointsSet, "default/dns/a", &localv1.Endpoint{Local: true})
+	send(t, s, localv1.Set_ServicesSet, "default/remote", service("remote", true, tcp))
+	send(t, s, localv1.Set_EndpointsSet, "default/remote/a", &localv1.Endpoint{})
+	send(t, s, localv1.Set_ServicesSet, "default/empty", service("empty", false, tcp))
+
+	if targets := s.targets(); len(targets) != 0 {
+		t.Errorf("expected no target before the sync, got %v", targets)
+	}
+
+	send(t, s, 0, "", nil)
+
+	got := []string{}
+	for _, target := range s.targets() {
+		got = append(got, target.service+" "+target.protocol.String()+" "+target.addr)
+	}
+	sort.Strings(got)
+
+	expected := []string{
+		"default/dns UDP 10.0.0.1:53",
+		"default/dns UDP [fd00::1]:53",
+		"default/web TCP 10.0.0.1:80",
+		"default/web TCP [fd00::1]:80",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestRun(t *testing.T) {
+	s := New(Config{Sample: 3}, nopSink{})
+
+	var succeeded, failed, failing counter
+	s.Succeeded, s.Failed, s.Failing = &succeeded, &failed, &failing
+
+	s.probe = func(_ context.Context, t target) error {
+		if strings.HasPrefix(t.addr, "[") {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	for _, name := range []string{"a", "b", "c"} {
+		send(t, s, localv1.Set_ServicesSet, "default/"+name, service(name, false, &localv1.PortMapping{Protocol: localv1.Protocol_TCP, Port: 80}))
+		send(t, s, localv1.Set_EndpointsSet, "default/"+name+"/ep", &localv1.Endpoint{})
+	}
+	send(t, s, 0, "", nil)
+
+	n := s.run(context.Background())
+	if succeeded+failed != 3 || int(failed) != n || int(failing) != n {
+		t.Errorf("expected 3 probes with %d failures, got %d successes, %d failures and %d failing", n, succeeded, failed, failing)
+	}
+}
+
+func TestDial(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	s := New(Config{Timeout: time.Second}, nopSink{})
+
+	if err := s.dial(context.Background(), target{protocol: localv1.Protocol_TCP, addr: addr}); err == nil {
+		t.Error("expected a closed TCP port to fail")
+	}
+
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+
+	if err := s.dial(context.Background(), target{protocol: localv1.Protocol_TCP, addr: addr}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package servicelatency records the programming latency of an allow-list of critical services
+// (ie: kube-dns, the ingress controller), so their SLOs can be tracked without a metric series
+// for each service of the cluster.
+//
+// The latency of a service runs from the first change of the service or of its endpoints received
+// by the node to the end of the sync programming it. A failed sync doesn't end it: the retries
+// are included. A namespace in the allow-list tracks all its services as one.
+//
+// The latency of each priority class of services (see localv1.Priority) can be recorded too, the
+// classes bounding the number of series as well.
+package servicelatency
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/proto"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink"
+)
+
+// MaxServices caps the allow-list, and so the number of series of the metric of each backend.
+const MaxServices = 20
+
+type Config struct {
+	// Services are the services ("namespace/name") and namespaces whose latency is recorded.
+	Services []string
+	// PriorityClasses records the latency of each priority class of services.
+	PriorityClasses bool
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVar(&c.Services, "latency-services", nil, fmt.Sprintf("Services (namespace/name) and namespaces whose programming latency is exported, up to %d (ie: kube-system/kube-dns,ingress-nginx)", MaxServices))
+	flags.BoolVar(&c.PriorityClasses, "latency-priority-classes", false, "Export the programming latency of each priority class of services (critical, high, normal and low)")
+}
+
+func (c *Config) Enabled() bool {
+	return len(c.Services) != 0 || c.PriorityClasses
+}
+
+// Check returns an error if the allow-list is invalid.
+func (c *Config) Check() error {
+	if len(c.Services) > MaxServices {
+		return fmt.Errorf("%d latency services, the maximum is %d", len(c.Services), MaxServices)
+	}
+	for _, service := range c.Services {
+		if _, err := parseTarget(service); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// target is a service of the allow-list, or a namespace if name is empty.
+type target struct {
+	namespace, name string
+}
+
+func parseTarget(s string) (target, error) {
+	namespace, name, _ := strings.Cut(s, "/")
+	if namespace == "" || strings.Contains(name, "/") || (strings.Contains(s, "/") && name == "") {
+		return target{}, fmt.Errorf("invalid latency service %q, must be namespace/name or namespace", s)
+	}
+	return target{namespace, name}, nil
+}
+
+// Observer is a metric observing the latencies in seconds (like prometheus.Observer).
+type Observer interface {
+	Observe(float64)
+}
+
+// Sink passes the operations to the sink of a backend, recording the programming latency of the
+// services of the allow-list.
+type Sink struct {
+	sink    localsink.Sink
+	targets map[target]bool
+
+	// Latency returns the metric of a service (name is empty for a namespace), if not nil.
+	Latency func(namespace, name string) Observer
+	// ClassLatency returns the metric of a priority class (see localv1.Priority.Class), if not nil.
+	ClassLatency func(class string) Observer
+
+	pending map[target]time.Time // first change not programmed yet
+
+	// classes are the priority classes of the services by path, nil if not recorded
+	classes        map[string]localv1.Priority
+	pendingClasses map[localv1.Priority]time.Time
+
+	now func() time.Time
+}
+
+var _ localsink.Sink = &Sink{}
+
+func New(cfg Config, sink localsink.Sink) *Sink {
+	s := &Sink{
+		sink:    sink,
+		targets: map[target]bool{},
+		pending: map[target]time.Time{},
+		now:     time.Now,
+	}
+	if cfg.PriorityClasses {
+		s.classes = map[string]localv1.Priority{}
+		s.pendingClasses = map[localv1.Priority]time.Time{}
+	}
+	for _, service := range cfg.Services {
+		if t, err := parseTarget(service); err == nil {
+			s.targets[t] = true
+		}
+	}
+	return s
+}
+
+func (s *Sink) Setup() { s.sink.Setup() }
+
+func (s *Sink) WaitRequest() (nodeName string, err error) {
+	return s.sink.WaitRequest()
+}
+
+// Reset is passed to the backend, the changes not programmed yet still pending.
+func (s *Sink) Reset() { s.sink.Reset() }
+
+func (s *Sink) Send(op *localv1.OpItem) error {
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Reset_:
+		if s.classes != nil {
+			s.classes = map[string]localv1.Priority{}
+		}
+	case *localv1.OpItem_Set:
+		if s.classes != nil && v.Set.Ref.Set == localv1.Set_ServicesSet {
+			svc := &localv1.Service{}
+			if err := proto.Unmarshal(v.Set.Bytes, svc); err == nil {
+				s.classes[v.Set.Ref.Path] = svc.Priority
+			}
+		}
+		s.changed(v.Set.Ref.Path)
+	case *localv1.OpItem_Delete:
+		s.changed(v.Delete.Path)
+		if s.classes != nil && v.Delete.Set == localv1.Set_ServicesSet {
+			delete(s.classes, v.Delete.Path)
+		}
+	case *localv1.OpItem_Sync:
+		err := s.sink.Send(op)
+		if err == nil {
+			s.synced()
+		}
+		return err
+	}
+
+	return s.sink.Send(op)
+}
+
+// changed starts the latency of the targets of the service or endpoint at path, if not pending.
+func (s *Sink) changed(path string) {
+	parts := strings.SplitN(path, "/", 3)
+	if len(parts) < 2 {
+		return
+	}
+
+	now := time.Time{}
+	if s.classes != nil {
+		// the unknown services are of the default class
+		class := s.classes[parts[0]+"/"+parts[1]]
+		if _, ok := s.pendingClasses[class]; !ok {
+			now = s.now()
+			s.pendingClasses[class] = now
+		}
+	}
+
+	for _, t := range []target{{parts[0], parts[1]}, {parts[0], ""}} {
+		if !s.targets[t] {
+			continue
+		}
+		if _, ok := s.pending[t]; ok {
+			continue
+		}
+		if now.IsZero() {
+			now = s.now()
+		}
+		s.pending[t] = now
+	}
+}
+
+// synced records the latencies of the pending targets and classes.
+func (s *Sink) synced() {
+	if len(s.pending) == 0 && len(s.pendingClasses) == 0 {
+		return
+	}
+
+	now := s.now()
+	for t, start := range s.pending {
+		if s.Latency != nil {
+			s.Latency(t.namespace, t.name).Observe(now.Sub(start).Seconds())
+		}
+		delete(s.pending, t)
+	}
+	for class, start := range s.pendingClasses {
+		if s.ClassLatency != nil {
+			s.ClassLatency(class.Class()).Observe(now.Sub(start).Seconds())
+		}
+		delete(s.pendingClasses, class)
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package servicelatency
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+type syncSink struct{ err error }
+
+func (*syncSink) Setup()                       {}
+func (*syncSink) WaitRequest() (string, error) { return "node", nil }
+func (*syncSink) Reset()                       {}
+
+func (s *syncSink) Send(op *localv1.OpItem) error {
+	if _, ok := op.Op.(*localv1.OpItem_Sync); ok {
+		return s.err
+	}
+	return nil
+}
+
+type observer []float64
+
+func (o *observer) Observe(v float64) { *o = append(*o, v) }
+
+func setOp(set localv1.Set, path string) *localv1.OpItem {
+	return &localv1.OpItem{Op: &localv1.OpItem_Set{Set: &localv1.Value{Ref: &localv1.Ref{Set: set, Path: path}}}}
+}
+
+func deleteOp(set localv1.Set, path string) *localv1.OpItem {
+	return &localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: &localv1.Ref{Set: set, Path: path}}}
+}
+
+var syncOp = &localv1.OpItem{Op: &localv1.OpItem_Sync{Sync: &localv1.EmptyOp{}}}
+
+func TestConfigCheck(t *testing.T) {
+	for _, services := range [][]string{
+		{"kube-system/kube-dns", "ingress-nginx"},
+		nil,
+	} {
+		cfg := Config{Services: services}
+		if err := cfg.Check(); err != nil {
+			t.Errorf("%q: %v", services, err)
+		}
+	}
+
+	tooMany := make([]string, MaxServices+1)
+	for i := range tooMany {
+		tooMany[i] = "ns"
+	}
+	for _, services := range [][]string{
+		{"/svc"},
+		{"ns/"},
+		{"ns/svc/port"},
+		tooMany,
+	} {
+		cfg := Config{Services: services}
+		if err := cfg.Check(); err == nil {
+			t.Errorf("%q: no error", services)
+		}
+	}
+}
+
+func TestLatency(t *testing.T) {
+	inner := &syncSink{}
+	s := New(Config{Services: []string{"kube-system/kube-dns", "ingress"}}, inner)
+
+	latencies := map[string]*observer{}
+	s.Latency = func(namespace, name string) Observer {
+		key := namespace + "/" + name
+		if latencies[key] == nil {
+			latencies[key] = &observer{}
+		}
+		return latencies[key]
+	}
+
+	clock := time.Unix(0, 0)
+	s.now = func() time.Time { return clock }
+	step := func(d time.Duration) { clock = clock.Add(d) }
+
+	s.Send(setOp(localv1.Set_ServicesSet, "default/web"))
+	s.Send(setOp(localv1.Set_EndpointsSet, "kube-system/kube-dns/abc"))
+	step(time.Second)
+	s.Send(setOp(localv1.Set_ServicesSet, "kube-system/kube-dns"))
+	s.Send(setOp(localv1.Set_ServicesSet, "ingress/controller"))
+	step(time.Second)
+	s.Send(syncOp)
+
+	expected := map[string]*observer{
+		"kube-system/kube-dns": {2},
+		"ingress/":             {1},
+	}
+	if !reflect.DeepEqual(latencies, expected) {
+		t.Fatalf("latencies %v, expected %v", latencies, expected)
+	}
+
+	// the failed syncs are included
+	inner.err = errors.New("failed")
+	s.Send(deleteOp(localv1.Set_EndpointsSet, "ingress/controller/def"))
+	step(time.Second)
+	s.Send(syncOp)
+	step(time.Second)
+	s.Send(syncOp)
+
+	inner.err = nil
+	step(time.Second)
+	s.Send(syncOp)
+
+	expected["ingress/"] = &observer{1, 3}
+	if !reflect.DeepEqual(latencies, expected) {
+		t.Fatalf("latencies %v, expected %v", latencies, expected)
+	}
+
+	// no change, no latency
+	s.Send(syncOp)
+	if !reflect.DeepEqual(latencies, expected) {
+		t.Errorf("latencies %v recorded without a change", latencies)
+	}
+}
+
+func serviceOp(t *testing.T, path string, priority localv1.Priority) *localv1.OpItem {
+	ba, err := proto.Marshal(&localv1.Service{Priority: priority})
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := setOp(localv1.Set_ServicesSet, path)
+	op.GetSet().Bytes = ba
+	return op
+}
+
+func TestClassLatency(t *testing.T) {
+	s := New(Config{PriorityClasses: true}, &syncSink{})
+
+	latencies := map[string]*observer{}
+	s.ClassLatency = func(class string) Observer {
+		if latencies[class] == nil {
+			latencies[class] = &observer{}
+		}
+		return latencies[class]
+	}
+
+	clock := time.Unix(0, 0)
+	s.now = func() time.Time { return clock }
+	step := func(d time.Duration) { clock = clock.Add(d) }
+
+	s.Send(serviceOp(t, "kube-system/kube-dns", localv1.Priority_CriticalPriority))
+	s.Send(serviceOp(t, "default/web", localv1.Priority_NormalPriority))
+	step(time.Second)
+	s.Send(setOp(localv1.Set_EndpointsSet, "kube-system/kube-dns/abc"))
+	s.Send(setOp(localv1.Set_EndpointsSet, "other/unknown/abc"))
+	step(time.Second)
+	s.Send(syncOp)
+
+	expected := map[string]*observer{
+		"critical": {2},
+		"normal":   {2},
+	}
+	if !reflect.DeepEqual(latencies, expected) {
+		t.Fatalf("latencies %v, expected %v", latencies, expected)
+	}
+
+	// the endpoints follow the class of their service, until it's deleted
+	s.Send(setOp(localv1.Set_EndpointsSet, "kube-system/kube-dns/def"))
+	s.Send(deleteOp(localv1.Set_ServicesSet, "kube-system/kube-dns"))
+	step(time.Second)
+	s.Send(syncOp)
+
+	expected["critical"] = &observer{2, 1}
+	if !reflect.DeepEqual(latencies, expected) {
+		t.Fatalf("latencies %v, expected %v", latencies, expected)
+	}
+
+	s.Send(deleteOp(localv1.Set_EndpointsSet, "kube-system/kube-dns/def"))
+	step(time.Second)
+	s.Send(syncOp)
+
+	expected["normal"] = &observer{2, 1}
+	if !reflect.DeepEqual(latencies, expected) {
+		t.Errorf("latencies %v, expected %v", latencies, expected)
+	}
+}
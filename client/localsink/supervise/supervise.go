@@ -0,0 +1,231 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package supervise isolates the panics of the backends, so they don't crash the whole process.
+//
+// A panic of the sink of a backend is recovered, logged and counted, and the sink is replaced by a
+// new one (the backend keeps its kernel state). The new sink is given the state delivered to the
+// previous one, then the change set in which it panicked fails: the state is re-delivered from
+// upstream (see the requeue package), and the backend is resynced with the difference.
+//
+// The goroutines of the backends (ie: the proxy loops of the userspace backends) are supervised
+// with Run and Recover. The stages of a fullstatepipe run in goroutines too, their panics being
+// raised again in the goroutine of the sink. The other goroutines of the backends (ie: the
+// rendering of the nft script) are not covered: a panic in them crashes the process.
+package supervise
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink"
+)
+
+// Counter is a metric incremented by the package (like prometheus.Counter).
+type Counter interface {
+	Inc()
+}
+
+// GoroutinePanicked is incremented on each panic recovered by Run and Recover, if not nil.
+var GoroutinePanicked Counter
+
+// restartDelay is the delay before a goroutine is run again after a panic.
+var restartDelay = time.Second
+
+// Sink passes the operations to the sink of a backend, replacing it after a panic.
+type Sink struct {
+	newSink func() localsink.Sink
+	sink    localsink.Sink
+
+	// Panicked is incremented on each panic of the sink, if not nil.
+	Panicked Counter
+
+	state     map[ref]*localv1.OpItem // delivered to the sink
+	initial   *localv1.OpItem         // the initial sync marker delivered to the sink
+	resetting bool
+	seen      map[ref]bool
+	panicErr  error // the panic in the current change set
+}
+
+type ref struct {
+	set  localv1.Set
+	path string
+}
+
+var _ localsink.Sink = &Sink{}
+
+// New returns a sink passing the operations to the sinks returned by newSink (ie: the Sink method
+// of a backend).
+func New(newSink func() localsink.Sink) *Sink {
+	return &Sink{
+		newSink: newSink,
+		sink:    newSink(),
+		state:   map[ref]*localv1.OpItem{},
+	}
+}
+
+func (s *Sink) Setup() { s.sink.Setup() }
+
+func (s *Sink) WaitRequest() (nodeName string, err error) {
+	return s.sink.WaitRequest()
+}
+
+func (s *Sink) Reset() {
+	s.resetting = true
+	s.seen = map[ref]bool{}
+
+	if p, stack := s.call(s.sink.Reset); p != nil {
+		s.restart(p, stack)
+	}
+}
+
+func (s *Sink) Send(op *localv1.OpItem) (err error) {
+	_, isSync := op.Op.(*localv1.OpItem_Sync)
+
+	if s.panicErr != nil {
+		// the rest of the change set is re-delivered with the state
+		if isSync {
+			err, s.panicErr = s.panicErr, nil
+		}
+		return
+	}
+
+	if p, stack := s.call(func() { err = s.sink.Send(op) }); p != nil {
+		s.restart(p, stack)
+		if isSync {
+			err, s.panicErr = s.panicErr, nil
+		}
+		return
+	}
+
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Set:
+		r := ref{v.Set.Ref.Set, v.Set.Ref.Path}
+		s.state[r] = op
+		if s.resetting {
+			s.seen[r] = true
+		}
+
+	case *localv1.OpItem_Delete:
+		delete(s.state, ref{v.Delete.Set, v.Delete.Path})
+
+	case *localv1.OpItem_InitialSync:
+		s.initial = op
+
+	case *localv1.OpItem_Sync:
+		if s.resetting {
+			// the paths not sent again are deleted by the sink
+			for r := range s.state {
+				if !s.seen[r] {
+					delete(s.state, r)
+				}
+			}
+			s.resetting = false
+			s.seen = nil
+		}
+	}
+
+	return
+}
+
+// call calls f, returning the value of its panic, if any, and the stack of the panicking goroutine.
+func (s *Sink) call(f func()) (p any, stack []byte) {
+	defer func() {
+		if p = recover(); p != nil {
+			stack = debug.Stack()
+		}
+	}()
+
+	f()
+	return
+}
+
+// restart replaces the sink after a panic, giving the new one the state delivered to the previous.
+func (s *Sink) restart(p any, stack []byte) {
+	klog.Errorf("backend panicked, restarting it: %v\n%s", p, stack)
+	if s.Panicked != nil {
+		s.Panicked.Inc()
+	}
+
+	s.panicErr = fmt.Errorf("backend restarted after a panic: %v", p)
+	s.sink = s.newSink()
+
+	refs := make([]ref, 0, len(s.state))
+	for r := range s.state {
+		refs = append(refs, r)
+	}
+	// the services before their endpoints
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].set != refs[j].set {
+			return refs[i].set < refs[j].set
+		}
+		return refs[i].path < refs[j].path
+	})
+
+	ops := make([]*localv1.OpItem, 0, len(refs)+1)
+	for _, r := range refs {
+		ops = append(ops, s.state[r])
+	}
+	if s.initial != nil {
+		// the new sink also gets a complete state at the next sync
+		ops = append(ops, s.initial)
+	}
+
+	for _, op := range ops {
+		// the errors are reported by the sync
+		if p, stack := s.call(func() { s.sink.Send(op) }); p != nil {
+			klog.Errorf("backend panicked again while restarted, the state will be re-delivered: %v\n%s", p, stack)
+			if s.Panicked != nil {
+				s.Panicked.Inc()
+			}
+			s.sink = s.newSink()
+			s.state = map[ref]*localv1.OpItem{}
+			return
+		}
+	}
+}
+
+// Run runs f, again after a panic, until it returns. The panics are logged and counted.
+func Run(what string, f func()) {
+	for !runOnce(what, f) {
+		time.Sleep(restartDelay)
+	}
+}
+
+func runOnce(what string, f func()) (returned bool) {
+	defer Recover(what)
+
+	f()
+	return true
+}
+
+// Recover logs and counts the panic of a goroutine, if any. It must be deferred.
+func Recover(what string) {
+	p := recover()
+	if p == nil {
+		return
+	}
+
+	klog.Errorf("%s panicked: %v\n%s", what, p, debug.Stack())
+	if GoroutinePanicked != nil {
+		GoroutinePanicked.Inc()
+	}
+}
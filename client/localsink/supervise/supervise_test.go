@@ -0,0 +1,179 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supervise
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink"
+)
+
+// panickingSink records the operations it receives, and panics on the Set of a path.
+type panickingSink struct {
+	ops     *[]string
+	panicOn string
+}
+
+func (*panickingSink) Setup()                       {}
+func (*panickingSink) WaitRequest() (string, error) { return "node", nil }
+func (s *panickingSink) Reset()                     { *s.ops = append(*s.ops, "reset") }
+
+func (s *panickingSink) Send(op *localv1.OpItem) error {
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Set:
+		if v.Set.Ref.Path == s.panicOn {
+			panic("bad " + s.panicOn)
+		}
+		*s.ops = append(*s.ops, "set "+v.Set.Ref.Path)
+	case *localv1.OpItem_Delete:
+		*s.ops = append(*s.ops, "del "+v.Delete.Path)
+	case *localv1.OpItem_InitialSync:
+		*s.ops = append(*s.ops, "initial")
+	case *localv1.OpItem_Sync:
+		*s.ops = append(*s.ops, "sync")
+	}
+	return nil
+}
+
+type counter int
+
+func (c *counter) Inc() { *c++ }
+
+func set(path string) *localv1.OpItem {
+	return &localv1.OpItem{Op: &localv1.OpItem_Set{Set: &localv1.Value{Ref: &localv1.Ref{Set: localv1.Set_ServicesSet, Path: path}}}}
+}
+
+func del(path string) *localv1.OpItem {
+	return &localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: &localv1.Ref{Set: localv1.Set_ServicesSet, Path: path}}}
+}
+
+var (
+	syncOp        = &localv1.OpItem{Op: &localv1.OpItem_Sync{Sync: &localv1.EmptyOp{}}}
+	initialSyncOp = &localv1.OpItem{Op: &localv1.OpItem_InitialSync{InitialSync: &localv1.EmptyOp{}}}
+)
+
+func TestSink(t *testing.T) {
+	ops := []string{}
+	sinks := 0
+	panicOn := "ns/c"
+
+	s := New(func() localsink.Sink {
+		sinks++
+		return &panickingSink{ops: &ops, panicOn: panicOn}
+	})
+	panicked := new(counter)
+	s.Panicked = panicked
+
+	send := func(op *localv1.OpItem) error {
+		t.Helper()
+		return s.Send(op)
+	}
+	expect := func(expected ...string) {
+		t.Helper()
+		if !reflect.DeepEqual(ops, expected) {
+			t.Errorf("expected %q, got %q", expected, ops)
+		}
+		ops = ops[:0]
+	}
+
+	for _, op := range []*localv1.OpItem{set("ns/a"), set("ns/b"), initialSyncOp, syncOp} {
+		if err := send(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect("set ns/a", "set ns/b", "initial", "sync")
+
+	// the sink panics: it's replaced and given the delivered state, marked complete, and the sync
+	// fails
+	panicOn = ""
+	s.sink.(*panickingSink).panicOn = "ns/c"
+	for _, op := range []*localv1.OpItem{del("ns/b"), set("ns/c"), set("ns/d")} {
+		if err := send(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := send(syncOp); err == nil {
+		t.Error("expected the sync to fail after a panic")
+	}
+	expect("del ns/b", "set ns/a", "initial")
+
+	if sinks != 2 || *panicked != 1 {
+		t.Errorf("expected 2 sinks and 1 panic, got %d and %d", sinks, *panicked)
+	}
+
+	// the state is re-delivered to the new sink
+	s.Reset()
+	for _, op := range []*localv1.OpItem{set("ns/a"), set("ns/c"), set("ns/d"), syncOp} {
+		if err := send(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect("reset", "set ns/a", "set ns/c", "set ns/d", "sync")
+
+	paths := []string{}
+	for r := range s.state {
+		paths = append(paths, r.path)
+	}
+	sort.Strings(paths)
+	if expected := []string{"ns/a", "ns/c", "ns/d"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected the delivered state %q, got %q", expected, paths)
+	}
+}
+
+func TestRun(t *testing.T) {
+	defer func(prev time.Duration) { restartDelay = prev }(restartDelay)
+	restartDelay = 0
+
+	defer func(prev Counter) { GoroutinePanicked = prev }(GoroutinePanicked)
+	panicked := new(counter)
+	GoroutinePanicked = panicked
+
+	runs := 0
+	Run("test loop", func() {
+		runs++
+		if runs < 3 {
+			panic("bad loop")
+		}
+	})
+
+	if runs != 3 || *panicked != 2 {
+		t.Errorf("expected 3 runs and 2 panics, got %d and %d", runs, *panicked)
+	}
+}
+
+func panicInBackend() {
+	panic("failed")
+}
+
+func TestCallStack(t *testing.T) {
+	p, stack := (&Sink{}).call(panicInBackend)
+	if p != "failed" {
+		t.Errorf("expected the panic value, got %v", p)
+	}
+	if !strings.Contains(string(stack), "panicInBackend") {
+		t.Errorf("expected the stack of the panic, got:\n%s", stack)
+	}
+
+	if p, stack := (&Sink{}).call(func() {}); p != nil || stack != nil {
+		t.Errorf("expected no panic, got %v\n%s", p, stack)
+	}
+}
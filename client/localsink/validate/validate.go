@@ -0,0 +1,276 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package validate lets the backends check the full state before programming it, and reject the
+// services they can't program (ie: SCTP on Windows, or more endpoints than an ipset can hold)
+// instead of failing the whole sync or programming them partially.
+//
+// The rejected services and their endpoints are withheld from the backend, as if deleted, until a
+// later state is accepted. The rejections are logged, and published with the state of the node
+// (see the nodestate package).
+package validate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink"
+	"sigs.k8s.io/kpng/client/localsink/fullstate"
+)
+
+// Rejection is a service a backend can't program.
+type Rejection struct {
+	Namespace string
+	Name      string
+	// Reason tells why the service can't be programmed.
+	Reason string
+}
+
+func (r Rejection) String() string {
+	return fmt.Sprintf("%s/%s: %s", r.Namespace, r.Name, r.Reason)
+}
+
+// Validator is implemented by the backends rejecting the configurations they can't program.
+type Validator interface {
+	// ValidateState returns the services of state that must not be programmed. It's called
+	// before each sync with the full state, and must not change the backend.
+	ValidateState(state []*fullstate.ServiceEndpoints) []Rejection
+}
+
+// Gauge is a metric set by the sink (like prometheus.Gauge).
+type Gauge interface {
+	Set(float64)
+}
+
+// Sink passes the operations to the sink of a backend, withholding the services it rejects.
+type Sink struct {
+	sink      localsink.Sink
+	validator Validator
+
+	// Rejected is set to the number of rejected services after each sync, if not nil.
+	Rejected Gauge
+
+	mu        sync.Mutex
+	services  map[string]*localv1.OpItem            // by path
+	endpoints map[string]map[string]*localv1.OpItem // by service path, then by path
+	rejected  map[string]Rejection                  // by service path
+}
+
+var _ localsink.Sink = &Sink{}
+
+func New(validator Validator, sink localsink.Sink) *Sink {
+	s := &Sink{sink: sink, validator: validator}
+	s.clear()
+	return s
+}
+
+func (s *Sink) clear() {
+	s.services = map[string]*localv1.OpItem{}
+	s.endpoints = map[string]map[string]*localv1.OpItem{}
+	s.rejected = map[string]Rejection{}
+}
+
+func (s *Sink) Setup() { s.sink.Setup() }
+
+func (s *Sink) WaitRequest() (nodeName string, err error) {
+	return s.sink.WaitRequest()
+}
+
+func (s *Sink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clear()
+	s.sink.Reset()
+}
+
+func (s *Sink) Send(op *localv1.OpItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Reset_:
+		s.clear()
+
+	case *localv1.OpItem_Set:
+		switch path := v.Set.Ref.Path; v.Set.Ref.Set {
+		case localv1.Set_ServicesSet:
+			s.services[path] = op
+			if s.isRejected(path) {
+				return nil
+			}
+
+		case localv1.Set_EndpointsSet:
+			svc := servicePath(path)
+			if s.endpoints[svc] == nil {
+				s.endpoints[svc] = map[string]*localv1.OpItem{}
+			}
+			s.endpoints[svc][path] = op
+			if s.isRejected(svc) {
+				return nil
+			}
+		}
+
+	case *localv1.OpItem_Delete:
+		switch path := v.Delete.Path; v.Delete.Set {
+		case localv1.Set_ServicesSet:
+			delete(s.services, path)
+			if s.isRejected(path) {
+				// already deleted from the backend
+				delete(s.rejected, path)
+				return nil
+			}
+
+		case localv1.Set_EndpointsSet:
+			svc := servicePath(path)
+			delete(s.endpoints[svc], path)
+			if len(s.endpoints[svc]) == 0 {
+				delete(s.endpoints, svc)
+			}
+			if s.isRejected(svc) {
+				return nil
+			}
+		}
+
+	case *localv1.OpItem_Sync:
+		if err := s.validate(); err != nil {
+			return err
+		}
+	}
+
+	return s.sink.Send(op)
+}
+
+// isRejected returns true if the service at path is withheld from the backend. Assumes s.mu is held.
+func (s *Sink) isRejected(path string) bool {
+	_, ok := s.rejected[path]
+	return ok
+}
+
+// servicePath returns the path of the service of the endpoint at path (namespace/name/key).
+func servicePath(path string) string {
+	if i := strings.LastIndexByte(path, '/'); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+// validate validates the state, deleting the newly rejected services from the backend and setting
+// the ones not rejected anymore. Assumes s.mu is held.
+func (s *Sink) validate() error {
+	state := make([]*fullstate.ServiceEndpoints, 0, len(s.services))
+	for _, path := range sortedPaths(s.services) {
+		svc := &localv1.Service{}
+		if err := proto.Unmarshal(s.services[path].GetSet().Bytes, svc); err != nil {
+			// the backend reports it
+			continue
+		}
+
+		seps := &fullstate.ServiceEndpoints{Service: svc}
+		for _, epPath := range sortedPaths(s.endpoints[path]) {
+			ep := &localv1.Endpoint{}
+			if err := proto.Unmarshal(s.endpoints[path][epPath].GetSet().Bytes, ep); err != nil {
+				continue
+			}
+			seps.Endpoints = append(seps.Endpoints, ep)
+		}
+		state = append(state, seps)
+	}
+
+	rejected := map[string]Rejection{}
+	for _, r := range s.validator.ValidateState(state) {
+		rejected[r.Namespace+"/"+r.Name] = r
+	}
+
+	for path, r := range rejected {
+		if _, ok := s.services[path]; !ok {
+			delete(rejected, path)
+			continue
+		}
+		if prev, ok := s.rejected[path]; ok {
+			if prev.Reason != r.Reason {
+				klog.Warning("service rejected by the backend: ", r)
+			}
+			continue
+		}
+
+		klog.Warning("service rejected by the backend: ", r)
+		for _, epPath := range sortedPaths(s.endpoints[path]) {
+			if err := s.sink.Send(deleteOp(localv1.Set_EndpointsSet, epPath)); err != nil {
+				return err
+			}
+		}
+		if err := s.sink.Send(deleteOp(localv1.Set_ServicesSet, path)); err != nil {
+			return err
+		}
+	}
+
+	for path := range s.rejected {
+		if _, ok := rejected[path]; ok {
+			continue
+		}
+
+		klog.Info("service not rejected by the backend anymore: ", path)
+		if err := s.sink.Send(s.services[path]); err != nil {
+			return err
+		}
+		for _, epPath := range sortedPaths(s.endpoints[path]) {
+			if err := s.sink.Send(s.endpoints[path][epPath]); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.rejected = rejected
+	if s.Rejected != nil {
+		s.Rejected.Set(float64(len(rejected)))
+	}
+	return nil
+}
+
+func sortedPaths(ops map[string]*localv1.OpItem) []string {
+	paths := make([]string, 0, len(ops))
+	for path := range ops {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func deleteOp(set localv1.Set, path string) *localv1.OpItem {
+	return &localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: &localv1.Ref{Set: set, Path: path}}}
+}
+
+// Rejections returns the services rejected at the last sync, sorted.
+func (s *Sink) Rejections() []Rejection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rejections := make([]Rejection, 0, len(s.rejected))
+	for _, r := range s.rejected {
+		rejections = append(rejections, r)
+	}
+	sort.Slice(rejections, func(i, j int) bool {
+		return rejections[i].String() < rejections[j].String()
+	})
+	return rejections
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validate
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink/fullstate"
+)
+
+// recordingSink records the operations it receives.
+type recordingSink struct {
+	ops []string
+}
+
+func (*recordingSink) Setup()                       {}
+func (*recordingSink) WaitRequest() (string, error) { return "node", nil }
+func (s *recordingSink) Reset()                     { s.ops = append(s.ops, "reset") }
+
+func (s *recordingSink) Send(op *localv1.OpItem) error {
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Set:
+		s.ops = append(s.ops, "set "+v.Set.Ref.Path)
+	case *localv1.OpItem_Delete:
+		s.ops = append(s.ops, "del "+v.Delete.Path)
+	case *localv1.OpItem_Sync:
+		s.ops = append(s.ops, "sync")
+	}
+	return nil
+}
+
+// maxEndpoints rejects the services with more than max endpoints.
+type maxEndpoints int
+
+func (max maxEndpoints) ValidateState(state []*fullstate.ServiceEndpoints) (rejected []Rejection) {
+	for _, seps := range state {
+		if len(seps.Endpoints) > int(max) {
+			rejected = append(rejected, Rejection{Namespace: seps.Service.Namespace, Name: seps.Service.Name, Reason: "too many endpoints"})
+		}
+	}
+	return
+}
+
+type gauge float64
+
+func (g *gauge) Set(v float64) { *g = gauge(v) }
+
+func set(s localv1.Set, path string, m proto.Message) *localv1.OpItem {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	return &localv1.OpItem{Op: &localv1.OpItem_Set{Set: &localv1.Value{Ref: &localv1.Ref{Set: s, Path: path}, Bytes: b}}}
+}
+
+func setService(name string) *localv1.OpItem {
+	return set(localv1.Set_ServicesSet, "ns/"+name, &localv1.Service{Namespace: "ns", Name: name})
+}
+
+func setEndpoint(name, key string) *localv1.OpItem {
+	return set(localv1.Set_EndpointsSet, "ns/"+name+"/"+key, &localv1.Endpoint{IPs: localv1.NewIPSet("10.1.0.1")})
+}
+
+var syncOp = &localv1.OpItem{Op: &localv1.OpItem_Sync{Sync: &localv1.EmptyOp{}}}
+
+func TestValidate(t *testing.T) {
+	inner := &recordingSink{}
+	s := New(maxEndpoints(1), inner)
+	rejected := new(gauge)
+	s.Rejected = rejected
+
+	send := func(ops ...*localv1.OpItem) {
+		t.Helper()
+		for _, op := range ops {
+			if err := s.Send(op); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	expect := func(ops ...string) {
+		t.Helper()
+		if !reflect.DeepEqual(inner.ops, ops) {
+			t.Errorf("expected %q, got %q", ops, inner.ops)
+		}
+		inner.ops = nil
+	}
+
+	send(setService("a"), setEndpoint("a", "1"), setService("b"), setEndpoint("b", "1"), syncOp)
+	expect("set ns/a", "set ns/a/1", "set ns/b", "set ns/b/1", "sync")
+
+	// b gets too many endpoints: it's deleted from the backend
+	send(setEndpoint("b", "2"), syncOp)
+	expect("set ns/b/2", "del ns/b/1", "del ns/b/2", "del ns/b", "sync")
+
+	if r := s.Rejections(); len(r) != 1 || r[0].String() != "ns/b: too many endpoints" {
+		t.Errorf("expected b to be rejected, got %v", r)
+	}
+	if *rejected != 1 {
+		t.Errorf("expected 1 rejected service, got %v", *rejected)
+	}
+
+	// the changes of a rejected service are withheld
+	send(setEndpoint("b", "3"), setEndpoint("a", "2"), syncOp)
+	expect("set ns/a/2", "del ns/a/1", "del ns/a/2", "del ns/a", "sync")
+
+	// once accepted again, the service is set back with its endpoints
+	send(&localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: &localv1.Ref{Set: localv1.Set_EndpointsSet, Path: "ns/b/2"}}},
+		&localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: &localv1.Ref{Set: localv1.Set_EndpointsSet, Path: "ns/b/3"}}},
+		syncOp)
+	expect("set ns/b", "set ns/b/1", "sync")
+
+	// a rejected service deleted is not deleted again
+	send(&localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: &localv1.Ref{Set: localv1.Set_ServicesSet, Path: "ns/a"}}}, syncOp)
+	expect("sync")
+
+	if r := s.Rejections(); len(r) != 0 {
+		t.Errorf("expected no rejected service, got %v", r)
+	}
+	if *rejected != 0 {
+		t.Errorf("expected no rejected service, got %v", *rejected)
+	}
+}
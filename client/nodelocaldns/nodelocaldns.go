@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package nodelocaldns exempts the addresses of a node-local DNS cache (like NodeLocal DNSCache,
+// listening on 169.254.20.10 and possibly on the cluster IP of kube-dns) from the service rules of
+// the backends, and optionally bypasses conntrack for its DNS traffic, as the cache expects when
+// it doesn't install its own rules.
+package nodelocaldns
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+	netutils "k8s.io/utils/net"
+)
+
+// Port is the DNS port of the NOTRACK rules.
+const Port = 53
+
+type Config struct {
+	// IPs are the addresses the cache listens on.
+	IPs []string
+	// NoTrack enables the rules bypassing conntrack for the DNS traffic to and from the IPs.
+	NoTrack bool
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVar(&c.IPs, "node-local-dns-ips", nil, "Addresses of the node-local DNS cache (ie: 169.254.20.10), not DNATed nor masqueraded by the iptables and nft backends")
+	flags.BoolVar(&c.NoTrack, "node-local-dns-notrack", false, "Bypass conntrack for the DNS traffic to and from --node-local-dns-ips (the NOTRACK rules of the node-local DNS cache)")
+}
+
+func (c *Config) Enabled() bool {
+	return len(c.IPs) != 0
+}
+
+// Cache holds the parsed addresses of the cache. A nil Cache exempts nothing.
+type Cache struct {
+	v4, v6  []string
+	noTrack bool
+}
+
+// New parses the configuration.
+func New(cfg Config) (*Cache, error) {
+	c := &Cache{noTrack: cfg.NoTrack}
+
+	for _, s := range cfg.IPs {
+		ip := netutils.ParseIPSloppy(strings.TrimSpace(s))
+		if ip == nil {
+			return nil, fmt.Errorf("invalid node-local DNS IP %q", s)
+		}
+
+		if ip.To4() != nil {
+			c.v4 = append(c.v4, ip.String())
+		} else {
+			c.v6 = append(c.v6, ip.String())
+		}
+	}
+
+	return c, nil
+}
+
+// IPs returns the addresses of the cache in the given family.
+func (c *Cache) IPs(ipv6 bool) []string {
+	if c == nil {
+		return nil
+	}
+	if ipv6 {
+		return c.v6
+	}
+	return c.v4
+}
+
+// NoTrack returns true if the DNS traffic of the cache must bypass conntrack.
+func (c *Cache) NoTrack() bool {
+	return c != nil && c.noTrack
+}
+
+var std *Cache
+
+// Setup enables the exemptions if the configuration requires it.
+func Setup(cfg *Config) error {
+	if !cfg.Enabled() {
+		if cfg.NoTrack {
+			return errors.New("--node-local-dns-notrack requires --node-local-dns-ips")
+		}
+		return nil
+	}
+
+	cache, err := New(*cfg)
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("node-local DNS cache on %v (notrack: %v)", cfg.IPs, cfg.NoTrack)
+	std = cache
+	return nil
+}
+
+// Default returns the global cache (nil if disabled).
+func Default() *Cache {
+	return std
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodelocaldns
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	cache, err := New(Config{IPs: []string{"169.254.20.10", " 10.96.0.10", "fd00::a"}, NoTrack: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cache.IPs(false); !reflect.DeepEqual(got, []string{"169.254.20.10", "10.96.0.10"}) {
+		t.Errorf("unexpected IPv4 addresses: %v", got)
+	}
+	if got := cache.IPs(true); !reflect.DeepEqual(got, []string{"fd00::a"}) {
+		t.Errorf("unexpected IPv6 addresses: %v", got)
+	}
+	if !cache.NoTrack() {
+		t.Error("expected notrack")
+	}
+
+	if _, err := New(Config{IPs: []string{"169.254.20.0/24"}}); err == nil {
+		t.Error("expected a CIDR to be rejected")
+	}
+}
+
+func TestNilCache(t *testing.T) {
+	var cache *Cache
+	if cache.IPs(false) != nil || cache.NoTrack() {
+		t.Error("a nil cache must exempt nothing")
+	}
+}
+
+func TestSetup(t *testing.T) {
+	if err := Setup(&Config{NoTrack: true}); err == nil {
+		t.Error("expected notrack without IPs to fail")
+	}
+	if err := Setup(&Config{}); err != nil || Default() != nil {
+		t.Errorf("expected no cache without IPs, got %v (err: %v)", Default(), err)
+	}
+}
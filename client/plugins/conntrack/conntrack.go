@@ -59,6 +59,9 @@ func (ct Conntrack) Callback(ch <-chan *client.ServiceEndpoints) {
 
 	ct.once.Do(setupConntrack)
 
+	// the IP ports served, whatever their protocol
+	served := map[string]bool{}
+
 	for seps := range ch {
 		allIPs := seps.Service.IPs.All().All()
 
@@ -86,6 +89,8 @@ func (ct Conntrack) Callback(ch <-chan *client.ServiceEndpoints) {
 					Port:     port,
 				}
 
+				served[ipPortKey(ipp)] = true
+
 				hasEndpoints := false
 
 				for _, ep := range seps.Endpoints {
@@ -125,6 +130,13 @@ func (ct Conntrack) Callback(ch <-chan *client.ServiceEndpoints) {
 		}
 	}
 
+	for _, item := range ct.ipPorts.Deleted() {
+		if ipp := item.Value().Get(); served[ipPortKey(ipp)] {
+			klog.V(1).Infof("cleaning conntrack entries for the previous protocol of service IP:port %v", ipp)
+			cleanupIPPortEntries(ipp)
+		}
+	}
+
 	for _, item := range ct.flows.Deleted() {
 		flow := item.Value().Get()
 		klog.V(1).Infof("cleaning conntrack entries for delete flow %v", flow)
@@ -1,3 +1,6 @@
+//go:build linux
+// +build linux
+
 /*
 Copyright 2021 The Kubernetes Authors.
 
@@ -21,6 +24,7 @@ import (
 	"flag"
 	"fmt"
 
+	"google.golang.org/protobuf/proto"
 	"k8s.io/klog/v2"
 	"k8s.io/utils/exec"
 	exectesting "k8s.io/utils/exec/testing"
@@ -130,3 +134,75 @@ func (e printCmdsExecer) CommandContext(ctx context.Context, cmd string, args ..
 func (e printCmdsExecer) LookPath(file string) (string, error) {
 	return "/bin/" + file, nil
 }
+
+func ExampleConntrack_protocolChange() {
+	execer = printCmdsExecer{}
+
+	ct := New()
+
+	state := []*fullstate.ServiceEndpoints{
+		{
+			Service: &api.Service{
+				Namespace: "test-ns",
+				Name:      "test-svc",
+				Type:      "ClusterIP",
+				IPs: &api.ServiceIPs{
+					ClusterIPs: api.NewIPSet("10.1.1.1"),
+				},
+				Ports: []*api.PortMapping{
+					{Name: "p1", Protocol: api.Protocol_UDP, Port: 53, TargetPort: 5353},
+				},
+			},
+			Endpoints: []*api.Endpoint{
+				{IPs: api.NewIPSet("10.1.2.1")},
+			},
+		},
+	}
+
+	fmt.Println("-- initial state --")
+	ct.Callback(arrayCh(state))
+
+	fmt.Println("-- change the protocol --")
+	state[0].Service.Ports[0].Protocol = api.Protocol_TCP
+	ct.Callback(arrayCh(state))
+
+	// Output:
+	// -- initial state --
+	// /bin/conntrack [-D -p udp --dport 53 --orig-dst 10.1.1.1]
+	// -- change the protocol --
+	// /bin/conntrack [-D -p tcp --dport 53 --orig-dst 10.1.1.1]
+	// /bin/conntrack [-D -p udp --dport 53 --orig-dst 10.1.1.1]
+	// /bin/conntrack [-D -p udp --dport 53 --dst-nat 10.1.2.1 --orig-dst 10.1.1.1]
+}
+
+func ExampleSink_protocolChange() {
+	execer = printCmdsExecer{}
+
+	sink := NewSink()
+
+	svc := &api.Service{
+		Namespace: "test-ns",
+		Name:      "test-svc",
+		Type:      "NodePort",
+		IPs: &api.ServiceIPs{
+			ClusterIPs: api.NewIPSet("10.1.1.1"),
+		},
+		Ports: []*api.PortMapping{
+			{Name: "p1", Protocol: api.Protocol_UDP, Port: 53, NodePort: 30053, TargetPort: 5353},
+			{Name: "p2", Protocol: api.Protocol_TCP, Port: 80, NodePort: 30080, TargetPort: 8080},
+		},
+	}
+	sink.SetService(svc)
+	sink.Sync()
+
+	fmt.Println("-- change the protocol of p1 --")
+	svc = proto.Clone(svc).(*api.Service)
+	svc.Ports[0].Protocol = api.Protocol_TCP
+	sink.SetService(svc)
+	sink.Sync()
+
+	// Output:
+	// -- change the protocol of p1 --
+	// /bin/conntrack [-D -p udp --dport 53 --orig-dst 10.1.1.1]
+	// /bin/conntrack [-D -p udp --dport 30053]
+}
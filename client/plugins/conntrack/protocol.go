@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conntrack
+
+import (
+	"strconv"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+// ipPortsOf returns the IP ports of a service ("node" being the IP of the node ports).
+func ipPortsOf(svc *localv1.Service) (ipPorts []IPPort) {
+	allIPs := svc.IPs.All().All()
+	if svc.Type == "NodePort" {
+		allIPs = append(allIPs, "node")
+	}
+
+	for _, svcIP := range allIPs {
+		for _, svcPort := range svc.Ports {
+			port := svcPort.Port
+			if svcIP == "node" {
+				port = svcPort.NodePort
+			}
+			if port == 0 {
+				continue
+			}
+			ipPorts = append(ipPorts, IPPort{Protocol: svcPort.Protocol, DnatIP: svcIP, Port: port})
+		}
+	}
+	return
+}
+
+// ipPortKey is the IP port without its protocol.
+func ipPortKey(ipp IPPort) string {
+	return ipp.DnatIP + "," + strconv.Itoa(int(ipp.Port))
+}
+
+// ProtocolChanges returns the IP ports of previous that current serves with another protocol only
+// (ie: a port changed from UDP to TCP). Their conntrack entries must be cleared once the rules of
+// previous are removed: the tracked flows of the old protocol would otherwise keep being sent to
+// the endpoints these rules chose.
+func ProtocolChanges(previous, current *localv1.Service) (changed []IPPort) {
+	if previous == nil || current == nil {
+		return nil
+	}
+
+	served := map[string]bool{}
+	kept := map[IPPort]bool{}
+	for _, ipp := range ipPortsOf(current) {
+		served[ipPortKey(ipp)] = true
+		kept[ipp] = true
+	}
+
+	for _, ipp := range ipPortsOf(previous) {
+		if served[ipPortKey(ipp)] && !kept[ipp] {
+			changed = append(changed, ipp)
+		}
+	}
+	return
+}
+
+// ClearIPPort removes the conntrack entries of an IP port.
+func ClearIPPort(ipp IPPort) {
+	cleanupIPPortEntries(ipp)
+}
@@ -43,7 +43,9 @@ func (ps *Sink) Setup() {
 }
 
 func (ps *Sink) SetService(svc *localv1.Service) {
-	ps.services[svc.Namespace+"/"+svc.Name] = svc
+	key := svc.Namespace + "/" + svc.Name
+	ps.staleIPPorts = append(ps.staleIPPorts, ProtocolChanges(ps.services[key], svc)...)
+	ps.services[key] = svc
 }
 
 func (ps *Sink) DeleteService(namespace, name string) {
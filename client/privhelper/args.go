@@ -0,0 +1,137 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package privhelper
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// argRules are the arguments refused for a command: the options making it read or write files, or
+// run programs (ie: iptables --modprobe). The rulesets are only accepted on stdin.
+type argRules struct {
+	// short options refused, alone or grouped (-M, -vM, -M/tmp/x); the grouped stdinOnly options
+	// are refused too
+	short string
+	// long options refused, with a value or abbreviated (--modprobe=x, --mod x)
+	long []string
+	// stdinOnly are the options reading a file, only accepted to read stdin ("-f -")
+	stdinOnly []string
+	// noOperands refuses the operands (the file to read), except the values of valueOptions
+	noOperands   bool
+	valueOptions []string
+	// noInclude refuses the include statements, in the arguments and on stdin
+	noInclude bool
+}
+
+var commandArgRules = map[string]argRules{
+	"iptables":         {short: "M", long: []string{"--modprobe"}},
+	"iptables-save":    {short: "Mf", long: []string{"--modprobe", "--file"}},
+	"iptables-restore": {short: "M", long: []string{"--modprobe"}, noOperands: true, valueOptions: []string{"-w", "-W", "-T", "--wait", "--wait-interval", "--table"}},
+	"nft":              {short: "fI", long: []string{"--includepath"}, stdinOnly: []string{"-f", "--file"}, noInclude: true},
+	"ipset":            {long: []string{"-file", "--file"}},
+	"conntrack":        {short: "R", long: []string{"--load-file"}},
+}
+
+var includeStatement = regexp.MustCompile(`\binclude\s*["$]`)
+
+// checkArgs refuses the arguments and stdin of the command name if they could make it access
+// anything else than the kernel state.
+func checkArgs(name string, args []string, stdin []byte) error {
+	// the variants of iptables take the same arguments
+	tool := filepath.Base(name)
+	tool = strings.Replace(tool, "ip6tables", "iptables", 1)
+	tool = strings.Replace(tool, "-legacy", "", 1)
+	tool = strings.Replace(tool, "-nft", "", 1)
+
+	rules, ok := commandArgRules[tool]
+	if !ok {
+		return nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if rules.stdinOnly != nil && isOption(arg, rules.stdinOnly) {
+			if value := optionValue(arg); value != "" {
+				if value != "-" {
+					return fmt.Errorf("%s: only stdin can be read, not %q", name, value)
+				}
+				continue
+			}
+			if i+1 >= len(args) || args[i+1] != "-" {
+				return fmt.Errorf("%s: only stdin can be read with %s", name, arg)
+			}
+			i++
+			continue
+		}
+
+		if isOption(arg, rules.long) {
+			return fmt.Errorf("%s: option not allowed: %s", name, arg)
+		}
+
+		if rules.short != "" && len(arg) > 1 && arg[0] == '-' && arg[1] != '-' && strings.ContainsAny(arg[1:], rules.short) {
+			return fmt.Errorf("%s: option not allowed: %s", name, arg)
+		}
+
+		if rules.noOperands && !strings.HasPrefix(arg, "-") {
+			if i == 0 || !isOption(args[i-1], rules.valueOptions) || strings.Contains(args[i-1], "=") {
+				return fmt.Errorf("%s: operand not allowed: %q", name, arg)
+			}
+		}
+	}
+
+	if rules.noInclude {
+		if includeStatement.MatchString(strings.Join(args, " ")) || includeStatement.Match(stdin) {
+			return fmt.Errorf("%s: include statements are not allowed", name)
+		}
+	}
+
+	return nil
+}
+
+// isOption returns true if arg is one of the options, abbreviated or not, with or without a value.
+func isOption(arg string, options []string) bool {
+	name := arg
+	if idx := strings.IndexByte(name, '='); idx >= 0 {
+		name = name[:idx]
+	}
+	if strings.TrimLeft(name, "-") == "" {
+		return false
+	}
+
+	for _, option := range options {
+		if name == option {
+			return true
+		}
+		// only long options can be abbreviated
+		if strings.HasPrefix(option, "--") && strings.HasPrefix(name, "--") && strings.HasPrefix(option, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// optionValue returns the value of the option given as --opt=value.
+func optionValue(arg string) string {
+	if idx := strings.IndexByte(arg, '='); idx >= 0 {
+		return arg[idx+1:]
+	}
+	return ""
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package privhelper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"k8s.io/utils/exec"
+)
+
+// ErrNotSupported is returned for the features of exec.Cmd the helper doesn't provide.
+var ErrNotSupported = errors.New("not supported by the privileged helper")
+
+// Client runs commands through the helper.
+type Client struct {
+	Socket string
+}
+
+var _ exec.Interface = &Client{}
+
+func NewClient(socket string) *Client {
+	return &Client{Socket: socket}
+}
+
+func (c *Client) Command(cmd string, args ...string) exec.Cmd {
+	return c.CommandContext(context.Background(), cmd, args...)
+}
+
+func (c *Client) CommandContext(ctx context.Context, cmd string, args ...string) exec.Cmd {
+	return &command{
+		client: c,
+		ctx:    ctx,
+		req:    request{Name: cmd, Args: args},
+	}
+}
+
+// LookPath returns the file as is, since it's resolved by the helper.
+func (c *Client) LookPath(file string) (string, error) {
+	return file, nil
+}
+
+func (c *Client) run(ctx context.Context, req request) (resp response, err error) {
+	d := net.Dialer{}
+	conn, err := d.DialContext(ctx, "unix", c.Socket)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if err = json.NewEncoder(conn).Encode(req); err != nil {
+		return
+	}
+
+	err = json.NewDecoder(conn).Decode(&resp)
+	return
+}
+
+// command implements exec.Cmd.
+type command struct {
+	client *Client
+	ctx    context.Context
+	req    request
+
+	stdin          io.Reader
+	stdout, stderr io.Writer
+
+	// dirOrEnv is set if the caller needs a directory or an environment the helper doesn't provide
+	dirOrEnv bool
+
+	done chan struct{}
+	err  error
+}
+
+var _ exec.Cmd = &command{}
+
+func (c *command) SetDir(dir string)                  { c.dirOrEnv = true }
+func (c *command) SetStdin(in io.Reader)              { c.stdin = in }
+func (c *command) SetStdout(out io.Writer)            { c.stdout = out }
+func (c *command) SetStderr(out io.Writer)            { c.stderr = out }
+func (c *command) SetEnv(env []string)                { c.dirOrEnv = true }
+func (c *command) Stop()                              {}
+func (c *command) StdoutPipe() (io.ReadCloser, error) { return nil, ErrNotSupported }
+func (c *command) StderrPipe() (io.ReadCloser, error) { return nil, ErrNotSupported }
+
+func (c *command) Run() error {
+	if c.dirOrEnv {
+		return fmt.Errorf("privileged helper: setting the directory or the environment: %w", ErrNotSupported)
+	}
+
+	if c.stdin != nil {
+		stdin, err := io.ReadAll(c.stdin)
+		if err != nil {
+			return err
+		}
+		c.req.Stdin = stdin
+	}
+
+	resp, err := c.client.run(c.ctx, c.req)
+	if err != nil {
+		return fmt.Errorf("privileged helper: %w", err)
+	}
+
+	if resp.Error != "" {
+		return fmt.Errorf("privileged helper: %s", resp.Error)
+	}
+
+	if c.stdout != nil {
+		c.stdout.Write(resp.Stdout)
+	}
+	if c.stderr != nil {
+		c.stderr.Write(resp.Stderr)
+	}
+
+	if resp.ExitCode != 0 {
+		return exec.CodeExitError{
+			Err:  fmt.Errorf("exit status %d", resp.ExitCode),
+			Code: resp.ExitCode,
+		}
+	}
+	return nil
+}
+
+func (c *command) CombinedOutput() ([]byte, error) {
+	out := &bytes.Buffer{}
+	c.stdout, c.stderr = out, out
+	err := c.Run()
+	return out.Bytes(), err
+}
+
+func (c *command) Output() ([]byte, error) {
+	out := &bytes.Buffer{}
+	c.stdout = out
+	err := c.Run()
+	return out.Bytes(), err
+}
+
+func (c *command) Start() error {
+	c.done = make(chan struct{})
+	go func() {
+		c.err = c.Run()
+		close(c.done)
+	}()
+	return nil
+}
+
+func (c *command) Wait() error {
+	if c.done == nil {
+		return errors.New("privileged helper: command not started")
+	}
+	<-c.done
+	return c.err
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package privhelper allows backends to run without CAP_NET_ADMIN: a privileged helper listens on a
+// unix socket and runs the kernel tools (iptables, nft, ipset...) on behalf of the backends, which use
+// the Exec implementation of k8s.io/utils/exec talking to the helper.
+//
+// Only the changes made by running commands go through the helper: the IPVS and Windows backends
+// talk to the kernel directly and still need the privileges.
+package privhelper
+
+import (
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/exec"
+)
+
+// DefaultAllowed are the commands the helper runs by default.
+var DefaultAllowed = []string{
+	"iptables", "iptables-save", "iptables-restore",
+	"ip6tables", "ip6tables-save", "ip6tables-restore",
+	"iptables-legacy", "iptables-legacy-save", "iptables-legacy-restore",
+	"ip6tables-legacy", "ip6tables-legacy-save", "ip6tables-legacy-restore",
+	"iptables-nft", "iptables-nft-save", "iptables-nft-restore",
+	"ip6tables-nft", "ip6tables-nft-save", "ip6tables-nft-restore",
+	"nft",
+	"ipset",
+	"conntrack",
+}
+
+// request is sent by the client to run a command. The helper runs it from / with its own minimal
+// environment, so the client can't change what the command loads (ie: with LD_PRELOAD), and refuses
+// the arguments reading or writing files: the rulesets are passed on Stdin.
+type request struct {
+	Name  string
+	Args  []string
+	Stdin []byte
+}
+
+// response is sent back by the helper when the command is done.
+type response struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+	// Error is set if the command couldn't be run at all.
+	Error string
+}
+
+// Config of the backends using the helper.
+type Config struct {
+	// Socket of the helper. If empty, the commands are run by the backend itself.
+	Socket string
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&c.Socket, "privileged-helper", "", "Unix socket of the privileged helper running the kernel commands (empty to run them directly)")
+}
+
+var std exec.Interface = exec.New()
+
+// Setup makes Exec use the helper if configured.
+func Setup(cfg *Config) {
+	if cfg.Socket == "" {
+		return
+	}
+
+	klog.Info("running kernel commands through the privileged helper at ", cfg.Socket)
+	std = NewClient(cfg.Socket)
+}
+
+// Exec returns the exec.Interface backends must use to run kernel commands.
+func Exec() exec.Interface {
+	return std
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package privhelper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/utils/exec"
+)
+
+func startServer(t *testing.T) *Client {
+	socket := filepath.Join(t.TempDir(), "helper.sock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	s := &Server{Socket: socket, SocketMode: 0600, Allowed: []string{"cat", "sh"}, AllowedUID: int64(os.Getuid()), AllowedGID: -1}
+	go s.Run(ctx)
+
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(socket); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return NewClient(socket)
+}
+
+func TestRun(t *testing.T) {
+	c := startServer(t)
+
+	cmd := c.Command("cat")
+	cmd.SetStdin(bytes.NewBufferString("*nat\nCOMMIT\n"))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "*nat\nCOMMIT\n" {
+		t.Errorf("unexpected output: %q", out)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	c := startServer(t)
+
+	out, err := c.Command("sh", "-c", "echo failed >&2; exit 4").CombinedOutput()
+
+	exitErr, ok := err.(exec.ExitError)
+	if !ok {
+		t.Fatalf("expected an exit error, got %v", err)
+	}
+	if !exitErr.Exited() || exitErr.ExitStatus() != 4 {
+		t.Errorf("expected exit status 4, got %v", exitErr)
+	}
+	if string(out) != "failed\n" {
+		t.Errorf("unexpected output: %q", out)
+	}
+}
+
+func TestNotAllowed(t *testing.T) {
+	c := startServer(t)
+
+	for _, name := range []string{"rm", "/bin/sh", "./sh", "../bin/sh", ""} {
+		if err := c.Command(name, "-c", "true").Run(); err == nil {
+			t.Errorf("command %q should be refused", name)
+		} else if _, isExit := err.(exec.ExitError); isExit {
+			t.Errorf("command %q: unexpected exit error: %v", name, err)
+		}
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	c := startServer(t)
+
+	t.Setenv("LD_PRELOAD", "/tmp/evil.so")
+
+	out, err := c.Command("sh", "-c", "echo $PATH $LD_PRELOAD; pwd").CombinedOutput()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := securePath + "\n/\n"; string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	cmd := c.Command("sh", "-c", "true")
+	cmd.SetEnv([]string{"LD_PRELOAD=/tmp/evil.so"})
+	if err := cmd.Run(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("setting the environment should not be supported, got %v", err)
+	}
+
+	cmd = c.Command("sh", "-c", "true")
+	cmd.SetDir("/tmp")
+	if err := cmd.Run(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("setting the directory should not be supported, got %v", err)
+	}
+}
+
+func TestResolveAllowed(t *testing.T) {
+	s := &Server{Allowed: []string{"sh", "no-such-command", "relative/cmd", "/opt/bin/tool"}}
+	s.resolveAllowed()
+
+	if path := s.paths["sh"]; !filepath.IsAbs(path) || !strings.HasSuffix(path, "/sh") {
+		t.Errorf("sh should be resolved to an absolute path, got %q", path)
+	}
+	if path := s.paths["/opt/bin/tool"]; path != "/opt/bin/tool" {
+		t.Errorf("absolute commands should be kept, got %q", path)
+	}
+	for _, name := range []string{"no-such-command", "relative/cmd"} {
+		if _, ok := s.paths[name]; ok {
+			t.Errorf("%q should not be allowed", name)
+		}
+	}
+}
+
+func TestAllowedPeer(t *testing.T) {
+	s := &Server{AllowedUID: 1000, AllowedGID: 2000}
+
+	for _, tc := range []struct {
+		uid, gid uint32
+		allowed  bool
+	}{
+		{0, 0, true},
+		{1000, 1000, true},
+		{1001, 2000, true},
+		{1001, 1001, false},
+	} {
+		if allowed := s.allowedPeer(tc.uid, tc.gid); allowed != tc.allowed {
+			t.Errorf("uid %d gid %d: expected allowed=%v", tc.uid, tc.gid, tc.allowed)
+		}
+	}
+
+	if (&Server{AllowedUID: -1, AllowedGID: -1}).allowedPeer(1000, 1000) {
+		t.Error("only root should be allowed by default")
+	}
+}
+
+func TestCheckArgs(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		args  []string
+		stdin string
+		valid bool
+	}{
+		{"iptables", []string{"-w", "5", "-t", "nat", "-A", "KUBE-MARK-MASQ", "-j", "MARK", "--or-mark", "0x4000"}, "", true},
+		{"iptables", []string{"--modprobe=/tmp/x", "-L"}, "", false},
+		{"iptables", []string{"--mod", "/tmp/x", "-L"}, "", false},
+		{"ip6tables-legacy", []string{"-vM/tmp/x", "-L"}, "", false},
+		{"iptables-save", []string{"-t", "nat"}, "", true},
+		{"iptables-nft-save", []string{"-f", "/etc/cron.d/x"}, "", false},
+		{"iptables-restore", []string{"-w", "5", "-W", "100000", "--noflush", "--counters"}, "*nat\nCOMMIT\n", true},
+		{"iptables-restore", []string{"--noflush", "/etc/shadow"}, "", false},
+		{"iptables-restore", []string{"--wait=5", "etc/shadow"}, "", false},
+		{"/usr/sbin/iptables-restore", []string{"--modprobe", "/tmp/x"}, "", false},
+		{"nft", []string{"-f", "-"}, "table ip k8s {}", true},
+		{"nft", []string{"--file=-"}, "", true},
+		{"nft", []string{"-j", "list", "ruleset"}, "", true},
+		{"nft", []string{"-f", "/etc/shadow"}, "", false},
+		{"nft", []string{"--file=/etc/shadow"}, "", false},
+		{"nft", []string{"-jf", "/etc/shadow"}, "", false},
+		{"nft", []string{"-f"}, "", false},
+		{"nft", []string{"-I", "/etc", "-f", "-"}, "", false},
+		{"nft", []string{"-f", "-"}, `include "/etc/shadow"`, false},
+		{"nft", []string{"include", `"/etc/shadow"`}, "", false},
+		{"ipset", []string{"restore", "-exist"}, "", true},
+		{"ipset", []string{"-file", "/etc/shadow", "restore"}, "", false},
+		{"conntrack", []string{"-D", "-p", "udp", "--orig-dst", "10.0.0.1"}, "", true},
+		{"conntrack", []string{"--load-file", "/etc/shadow"}, "", false},
+		{"cat", []string{"/etc/hostname"}, "", true},
+	} {
+		err := checkArgs(tc.name, tc.args, []byte(tc.stdin))
+		if valid := err == nil; valid != tc.valid {
+			t.Errorf("%s %q: expected valid=%v, got %v", tc.name, tc.args, tc.valid, err)
+		}
+	}
+}
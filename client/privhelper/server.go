@@ -0,0 +1,221 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package privhelper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+)
+
+// Server is the privileged helper.
+type Server struct {
+	// Socket to listen on.
+	Socket string
+	// SocketMode are the permissions of the socket, restricting the processes allowed to use the helper.
+	SocketMode uint32
+	// Allowed are the commands the helper accepts to run.
+	Allowed []string
+	// AllowedUID and AllowedGID are the user and group of the processes allowed to use the helper,
+	// besides root (-1 for none).
+	AllowedUID int64
+	AllowedGID int64
+
+	// paths are the absolute paths of the allowed commands
+	paths map[string]string
+}
+
+// securePath is the only PATH the allowed commands are looked up in, and run with; nothing of
+// the client's environment reaches the commands.
+const securePath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+const (
+	// maxRequestSize bounds the requests, rulesets included
+	maxRequestSize = 64 << 20
+	// requestTimeout bounds the time to read a request
+	requestTimeout = 30 * time.Second
+)
+
+func (s *Server) BindFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&s.Socket, "socket", "/run/kpng/helper.sock", "Unix socket to listen on")
+	flags.Uint32Var(&s.SocketMode, "socket-mode", 0660, "Permissions of the socket")
+	flags.Int64Var(&s.AllowedUID, "allowed-uid", -1, "User allowed to use the helper besides root (-1 for none)")
+	flags.Int64Var(&s.AllowedGID, "allowed-gid", -1, "Group allowed to use the helper besides root (-1 for none)")
+	flags.StringSliceVar(&s.Allowed, "allowed-commands", DefaultAllowed, "Commands the helper accepts to run, looked up in "+securePath+" (or absolute paths)")
+}
+
+// Run serves the requests until ctx is done.
+func (s *Server) Run(ctx context.Context) error {
+	s.resolveAllowed()
+
+	os.Remove(s.Socket)
+
+	l, err := net.Listen("unix", s.Socket)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(s.Socket, os.FileMode(s.SocketMode)); err != nil {
+		l.Close()
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	klog.Info("privileged helper listening on ", s.Socket)
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		go s.handle(ctx, conn)
+	}
+}
+
+// resolveAllowed finds the absolute paths of the allowed commands in securePath.
+func (s *Server) resolveAllowed() {
+	s.paths = make(map[string]string, len(s.Allowed))
+
+	for _, name := range s.Allowed {
+		if strings.Contains(name, "/") {
+			if filepath.IsAbs(name) {
+				s.paths[name] = filepath.Clean(name)
+			} else {
+				klog.Warningf("privileged helper: ignoring the relative command %q", name)
+			}
+			continue
+		}
+
+		path, err := lookSecurePath(name)
+		if err != nil {
+			klog.V(2).Infof("privileged helper: command %q not available: %v", name, err)
+			continue
+		}
+		s.paths[name] = path
+	}
+}
+
+func lookSecurePath(name string) (string, error) {
+	for _, dir := range filepath.SplitList(securePath) {
+		path := filepath.Join(dir, name)
+
+		fi, err := os.Stat(path)
+		if err != nil || fi.IsDir() || fi.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		return path, nil
+	}
+	return "", exec.ErrNotFound
+}
+
+func (s *Server) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	uid, gid, err := peerCred(conn)
+	if err != nil {
+		klog.Error("privileged helper: failed to check the client: ", err)
+		return
+	}
+	if !s.allowedPeer(uid, gid) {
+		klog.Warningf("privileged helper: refusing the client with uid %d and gid %d", uid, gid)
+		return
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(requestTimeout)); err != nil {
+		klog.Error("privileged helper: ", err)
+		return
+	}
+
+	req := request{}
+	if err := json.NewDecoder(io.LimitReader(conn, maxRequestSize)).Decode(&req); err != nil {
+		klog.Error("privileged helper: invalid request: ", err)
+		return
+	}
+
+	resp := s.run(ctx, req)
+
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		klog.Error("privileged helper: failed to send response: ", err)
+	}
+}
+
+// allowedPeer returns true if the process with this uid and gid can use the helper.
+func (s *Server) allowedPeer(uid, gid uint32) bool {
+	return uid == 0 || int64(uid) == s.AllowedUID || int64(gid) == s.AllowedGID
+}
+
+func (s *Server) run(ctx context.Context, req request) (resp response) {
+	path, ok := s.paths[req.Name]
+	if !ok {
+		klog.Warningf("privileged helper: refusing to run %q", req.Name)
+		resp.Error = fmt.Sprintf("command not allowed: %q", req.Name)
+		return
+	}
+
+	if err := checkArgs(req.Name, req.Args, req.Stdin); err != nil {
+		klog.Warning("privileged helper: refusing to run ", err)
+		resp.Error = err.Error()
+		return
+	}
+
+	klog.V(4).Infof("privileged helper: running %s %v", path, req.Args)
+
+	cmd := exec.CommandContext(ctx, path, req.Args...)
+	cmd.Dir = "/"
+	cmd.Env = []string{"PATH=" + securePath}
+	cmd.Stdin = bytes.NewReader(req.Stdin)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+
+	resp.Stdout = stdout.Bytes()
+	resp.Stderr = stderr.Bytes()
+
+	exitErr := &exec.ExitError{}
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		resp.ExitCode = exitErr.ExitCode()
+	default:
+		resp.Error = err.Error()
+	}
+
+	return
+}
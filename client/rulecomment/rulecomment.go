@@ -0,0 +1,189 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rulecomment formats the comments kpng puts on the kernel rules it programs, so a live
+// rule can be mapped back to the service port that created it (see `kpng explain`).
+//
+// A comment is a list of space separated fields, starting with "kpng:<backend>":
+//
+//	kpng:iptables svc=<namespace>/<name> port=<port name> rev=<revision> rule=<kind>
+//
+// The empty fields are omitted. The revision is the one of the local state in which the service
+// last changed (see the auditlog package). The local API has no service UID, the service is
+// identified by its namespace and name.
+package rulecomment
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/auditlog"
+)
+
+// currentRevision is replaced in tests.
+var currentRevision = auditlog.Revision
+
+// Prefix starts the comments of the rules programmed by kpng.
+const Prefix = "kpng:"
+
+type Comment struct {
+	Backend string
+	// Service is "<namespace>/<name>"
+	Service  string
+	Port     string
+	Revision uint64
+	// Rule is the kind of rule (ie: "cluster-ip", "nodeport")
+	Rule string
+}
+
+// For returns the comment of the rules of a service port, at the current revision.
+func For(backend string, svc *localv1.Service, port string) Comment {
+	return Comment{
+		Backend:  backend,
+		Service:  svc.Namespace + "/" + svc.Name,
+		Port:     port,
+		Revision: currentRevision(),
+	}
+}
+
+// WithRule returns a copy of the comment for a kind of rule.
+func (c Comment) WithRule(rule string) Comment {
+	c.Rule = rule
+	return c
+}
+
+func (c Comment) String() string {
+	b := &strings.Builder{}
+	b.WriteString(Prefix)
+	b.WriteString(c.Backend)
+
+	field := func(key, value string) {
+		if value == "" {
+			return
+		}
+		b.WriteByte(' ')
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(value)
+	}
+
+	field("svc", c.Service)
+	field("port", c.Port)
+	if c.Revision != 0 {
+		field("rev", strconv.FormatUint(c.Revision, 10))
+	}
+	field("rule", c.Rule)
+
+	return b.String()
+}
+
+// ServicePort returns the service port name, like "<namespace>/<name>:<port>".
+func (c Comment) ServicePort() string {
+	if c.Port == "" {
+		return c.Service
+	}
+	return c.Service + ":" + c.Port
+}
+
+// Parse parses a comment written by kpng. It returns false if the comment is not one.
+func Parse(s string) (c Comment, ok bool) {
+	s = strings.Trim(s, `"`)
+
+	fields := strings.Fields(s)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], Prefix) {
+		return
+	}
+
+	c.Backend = strings.TrimPrefix(fields[0], Prefix)
+
+	for _, field := range fields[1:] {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			return Comment{}, false
+		}
+
+		switch key {
+		case "svc":
+			c.Service = value
+		case "port":
+			c.Port = value
+		case "rev":
+			rev, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return Comment{}, false
+			}
+			c.Revision = rev
+		case "rule":
+			c.Rule = value
+		}
+		// unknown fields are ignored, to read the comments of newer versions
+	}
+
+	return c, true
+}
+
+// Revisions tracks the revision in which each service last changed, for the backends receiving
+// the full state at each sync.
+type Revisions struct {
+	mu       sync.Mutex
+	services map[string]serviceRevision
+}
+
+type serviceRevision struct {
+	svc  *localv1.Service
+	rev  uint64
+	seen bool
+}
+
+// Of returns the revision in which the service last changed.
+func (r *Revisions) Of(svc *localv1.Service) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.services == nil {
+		r.services = map[string]serviceRevision{}
+	}
+
+	key := svc.Namespace + "/" + svc.Name
+
+	sr, ok := r.services[key]
+	if !ok || !proto.Equal(sr.svc, svc) {
+		sr = serviceRevision{svc: svc, rev: currentRevision()}
+	}
+	sr.seen = true
+	r.services[key] = sr
+
+	return sr.rev
+}
+
+// Done forgets the services not seen since the previous call.
+func (r *Revisions) Done() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, sr := range r.services {
+		if !sr.seen {
+			delete(r.services, key)
+			continue
+		}
+		sr.seen = false
+		r.services[key] = sr
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rulecomment
+
+import (
+	"testing"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+func TestCommentRoundTrip(t *testing.T) {
+	for _, c := range []Comment{
+		{Backend: "iptables", Service: "my-ns/my-svc", Port: "http", Revision: 12, Rule: "cluster-ip"},
+		{Backend: "nft", Service: "my-ns/my-svc"},
+		{Backend: "userspacelin"},
+	} {
+		parsed, ok := Parse(`"` + c.String() + `"`)
+		if !ok || parsed != c {
+			t.Errorf("%q parsed as %+v (ok: %v), expected %+v", c.String(), parsed, ok, c)
+		}
+	}
+
+	if s := (Comment{Backend: "iptables", Service: "ns/svc", Port: "dns", Revision: 3, Rule: "nodeport"}).String(); s != "kpng:iptables svc=ns/svc port=dns rev=3 rule=nodeport" {
+		t.Errorf("unexpected comment %q", s)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"kubernetes service nodeports",
+		"my-ns/my-svc:http",
+		"kpng:iptables svc",
+		"kpng:iptables rev=x",
+	} {
+		if c, ok := Parse(s); ok {
+			t.Errorf("%q should not parse, got %+v", s, c)
+		}
+	}
+
+	if c, ok := Parse("kpng:nft svc=a/b future=1"); !ok || c.Service != "a/b" {
+		t.Errorf("unknown fields should be ignored, got %+v (ok: %v)", c, ok)
+	}
+}
+
+func TestRevisions(t *testing.T) {
+	defer func(f func() uint64) { currentRevision = f }(currentRevision)
+	revision := uint64(0)
+	currentRevision = func() uint64 { return revision }
+
+	r := &Revisions{}
+	svc := &localv1.Service{Namespace: "ns", Name: "svc", Type: "ClusterIP"}
+
+	revision = 1
+	if rev := r.Of(svc); rev != 1 {
+		t.Errorf("expected revision 1, got %d", rev)
+	}
+	r.Done()
+
+	revision = 2
+	if rev := r.Of(svc); rev != 1 {
+		t.Errorf("unchanged service: expected revision 1, got %d", rev)
+	}
+	r.Done()
+
+	revision = 3
+	if rev := r.Of(&localv1.Service{Namespace: "ns", Name: "svc", Type: "NodePort"}); rev != 3 {
+		t.Errorf("changed service: expected revision 3, got %d", rev)
+	}
+	r.Done()
+	r.Done()
+
+	if len(r.services) != 0 {
+		t.Errorf("expected the unseen services to be forgotten, got %v", r.services)
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package servicechains computes the hashed keys backends use to name the
+// kernel objects (ie: iptables chains) of a service port.
+//
+// The algorithm is part of kpng's API and will not change: external tools can
+// rely on it to map chains back to services. For a service port, the key is
+// computed as follows:
+//
+//  1. build the service port name: "<namespace>/<name>", followed by
+//     ":<port name>" if the port is named;
+//  2. append the lowercase protocol ("tcp", "udp" or "sctp");
+//  3. for an endpoint key, append the endpoint ("<ip>:<port>");
+//  4. hash the result with sha256, encode it in standard base32 and keep the
+//     first 16 characters.
+//
+// This is the same algorithm as the upstream kube-proxy.
+package servicechains
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kpng/api/localv1"
+)
+
+// Prefixes of the iptables chains using the service keys.
+const (
+	ServicePrefix  = "KUBE-SVC-"
+	FirewallPrefix = "KUBE-FW-"
+	LocalPrefix    = "KUBE-XLB-"
+	EndpointPrefix = "KUBE-SEP-"
+	BucketPrefix   = "KUBE-SVB-"
+)
+
+// NameFor returns the key of the given port of a service.
+func NameFor(svc *localv1.Service, portName string, protocol localv1.Protocol) string {
+	return Hash(ServicePortName(svc.Namespace, svc.Name, portName), protocol)
+}
+
+// EndpointNameFor returns the key of an endpoint ("<ip>:<port>") of the given port of a service.
+func EndpointNameFor(svc *localv1.Service, portName string, protocol localv1.Protocol, endpoint string) string {
+	return EndpointHash(ServicePortName(svc.Namespace, svc.Name, portName), protocol, endpoint)
+}
+
+// ServicePortName returns the name of a service port, as used in the keys and in rule comments.
+func ServicePortName(namespace, name, portName string) string {
+	s := namespace + "/" + name
+	if portName != "" {
+		s += ":" + portName
+	}
+	return s
+}
+
+// Hash returns the key of a service port from its name (see ServicePortName).
+func Hash(servicePortName string, protocol localv1.Protocol) string {
+	return hash(servicePortName + protocolString(protocol))
+}
+
+// EndpointHash returns the key of an endpoint of a service port from its name (see ServicePortName).
+func EndpointHash(servicePortName string, protocol localv1.Protocol, endpoint string) string {
+	return hash(servicePortName + protocolString(protocol) + endpoint)
+}
+
+// BucketHash returns the hash of a bucket of the endpoints balanced from chain, when they're
+// split in nested chains.
+func BucketHash(chain string, bucket int) string {
+	return hash(chain + "/" + strconv.Itoa(bucket))
+}
+
+func protocolString(protocol localv1.Protocol) string {
+	return strings.ToLower(protocol.String())
+}
+
+func hash(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return base32.StdEncoding.EncodeToString(h[:])[:16]
+}
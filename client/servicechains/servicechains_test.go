@@ -0,0 +1,100 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package servicechains
+
+import (
+	"fmt"
+	"testing"
+
+	"sigs.k8s.io/kpng/api/localv1"
+)
+
+var kubernetesSvc = &localv1.Service{Namespace: "default", Name: "kubernetes"}
+
+func ExampleNameFor() {
+	fmt.Println(ServicePrefix + NameFor(kubernetesSvc, "https", localv1.Protocol_TCP))
+	// Output:
+	// KUBE-SVC-NPX46M4PTMTKRN6Y
+}
+
+// TestStableNames checks the keys don't change between releases; any failure
+// here breaks the tools relying on them.
+func TestStableNames(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		expected string
+	}{
+		// same chain as the upstream kube-proxy
+		{NameFor(kubernetesSvc, "https", localv1.Protocol_TCP), "NPX46M4PTMTKRN6Y"},
+		{NameFor(kubernetesSvc, "", localv1.Protocol_UDP), "67XCG43RULXL4PCD"},
+		{EndpointNameFor(kubernetesSvc, "https", localv1.Protocol_TCP, "10.0.0.1:6443"), "NMFUQD3Y5ZBKJ7GW"},
+	} {
+		if tc.name != tc.expected {
+			t.Errorf("expected %s, got %s", tc.expected, tc.name)
+		}
+	}
+}
+
+func TestNoCollisions(t *testing.T) {
+	seen := map[string]string{}
+
+	check := func(name, from string) {
+		if len(name) != 16 {
+			t.Fatalf("%s: unexpected key length: %q", from, name)
+		}
+		if prev, ok := seen[name]; ok {
+			t.Fatalf("collision on %s between %s and %s", name, prev, from)
+		}
+		seen[name] = from
+	}
+
+	protocols := []localv1.Protocol{localv1.Protocol_TCP, localv1.Protocol_UDP, localv1.Protocol_SCTP}
+
+	for ns := 0; ns < 20; ns++ {
+		for svcIdx := 0; svcIdx < 50; svcIdx++ {
+			svc := &localv1.Service{
+				Namespace: fmt.Sprintf("ns-%d", ns),
+				Name:      fmt.Sprintf("svc-%d", svcIdx),
+			}
+
+			for _, portName := range []string{"", "http", "https", "dns"} {
+				for _, protocol := range protocols {
+					from := fmt.Sprintf("%s/%s:%s/%v", svc.Namespace, svc.Name, portName, protocol)
+					check(NameFor(svc, portName, protocol), from)
+
+					for bucket := 0; bucket < 3; bucket++ {
+						check(BucketHash(ServicePrefix+NameFor(svc, portName, protocol), bucket), fmt.Sprintf("%s#%d", from, bucket))
+					}
+
+					for ep := 0; ep < 3; ep++ {
+						endpoint := fmt.Sprintf("10.%d.%d.%d:8080", ns, svcIdx, ep)
+						check(EndpointNameFor(svc, portName, protocol, endpoint), from+"@"+endpoint)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestProtocolMatters(t *testing.T) {
+	tcp := NameFor(kubernetesSvc, "dns", localv1.Protocol_TCP)
+	udp := NameFor(kubernetesSvc, "dns", localv1.Protocol_UDP)
+
+	if tcp == udp {
+		t.Errorf("same key for TCP and UDP: %s", tcp)
+	}
+}
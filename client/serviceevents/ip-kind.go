@@ -22,6 +22,7 @@ type IPKind int
 const (
 	ClusterIP IPKind = iota
 	ExternalIP
+	// LoadBalancerIP are the load-balancer IPs without the Proxy ipMode (see localv1.ServiceIPs).
 	LoadBalancerIP
 )
 
@@ -17,6 +17,8 @@ limitations under the License.
 package serviceevents
 
 import (
+	"google.golang.org/protobuf/proto"
+
 	"sigs.k8s.io/kpng/api/localv1"
 )
 
@@ -45,6 +47,13 @@ type SessionAffinityListener interface {
 	DisableSessionAffinity(svc *localv1.Service)
 }
 
+// SessionAffinityResetListener is notified when an endpoint of a service with ClientIP affinity
+// is deleted (or changes its IPs), so the clients stuck to it are balanced again right away
+// instead of after the affinity timeout.
+type SessionAffinityResetListener interface {
+	ResetSessionAffinity(svc *localv1.Service, endpoint *localv1.Endpoint)
+}
+
 // ServicesListener analyzes updates to the Service set and produced detailed
 // events about the changes.
 //
@@ -65,7 +74,10 @@ type ServicesListener struct {
 	TrafficPolicyListener   TrafficPolicyListener
 	SessionAffinityListener SessionAffinityListener
 
-	services map[string]*localv1.Service
+	SessionAffinityResetListener SessionAffinityResetListener
+
+	services  map[string]*localv1.Service
+	endpoints map[string]*localv1.Endpoint // only with a SessionAffinityResetListener
 }
 
 // New creates a new ServicesListener.
@@ -73,7 +85,8 @@ type ServicesListener struct {
 // Reminder: you need to associate listeners for this listener to be useful.
 func New() *ServicesListener {
 	return &ServicesListener{
-		services: map[string]*localv1.Service{},
+		services:  map[string]*localv1.Service{},
+		endpoints: map[string]*localv1.Endpoint{},
 	}
 }
 
@@ -101,6 +114,48 @@ func (sl *ServicesListener) DeleteService(namespace, name string) {
 	sl.diff(svc, nil)
 }
 
+// SetEndpoint is called when an endpoint is added or updated
+func (sl *ServicesListener) SetEndpoint(namespace, serviceName, key string, endpoint *localv1.Endpoint) {
+	if sl.SessionAffinityResetListener == nil {
+		return
+	}
+
+	epKey := namespace + "/" + serviceName + "/" + key
+	prevEp := sl.endpoints[epKey]
+	sl.endpoints[epKey] = endpoint
+
+	if prevEp != nil && !proto.Equal(prevEp.IPs, endpoint.IPs) {
+		sl.resetSessionAffinity(namespace, serviceName, prevEp)
+	}
+}
+
+// DeleteEndpoint is called when an endpoint is deleted
+func (sl *ServicesListener) DeleteEndpoint(namespace, serviceName, key string) {
+	if sl.SessionAffinityResetListener == nil {
+		return
+	}
+
+	epKey := namespace + "/" + serviceName + "/" + key
+	prevEp, ok := sl.endpoints[epKey]
+	if !ok {
+		return // already removed
+	}
+
+	delete(sl.endpoints, epKey)
+
+	sl.resetSessionAffinity(namespace, serviceName, prevEp)
+}
+
+// resetSessionAffinity notifies the removal of the endpoint if its service has ClientIP affinity.
+func (sl *ServicesListener) resetSessionAffinity(namespace, serviceName string, endpoint *localv1.Endpoint) {
+	svc := sl.services[namespace+"/"+serviceName]
+	if svc == nil || svc.GetClientIP() == nil {
+		return
+	}
+
+	sl.SessionAffinityResetListener.ResetSessionAffinity(svc, endpoint)
+}
+
 func (sl *ServicesListener) diff(prevSvc, currSvc *localv1.Service) {
 	var prevPorts, currPorts []*localv1.PortMapping
 
@@ -142,7 +197,7 @@ func (sl *ServicesListener) diff(prevSvc, currSvc *localv1.Service) {
 			if svc.IPs == nil {
 				return nil
 			}
-			return svc.IPs.LoadBalancerIPs
+			return svc.IPs.LoadBalancerVIPs()
 		}},
 	}
 
@@ -326,3 +326,69 @@ func Example() {
 	//     ip: 10.1.1.1 (ClusterIP)
 
 }
+
+type sessAffResetLsnr struct{}
+
+func (_ sessAffResetLsnr) ResetSessionAffinity(svc *localv1.Service, endpoint *localv1.Endpoint) {
+	fmt.Print("RESET svc: ", svc.Namespace, "/", svc.Name, "\n    endpoint: ", cleanStr(endpoint), "\n")
+}
+
+func ExampleServicesListener_DeleteEndpoint() {
+	sl := New()
+	sl.SessionAffinityResetListener = sessAffResetLsnr{}
+
+	sticky := &localv1.Service{Namespace: "ns", Name: "sticky",
+		SessionAffinity: &localv1.Service_ClientIP{ClientIP: &localv1.ClientIPAffinity{TimeoutSeconds: 10800}}}
+	sl.SetService(sticky)
+	sl.SetService(&localv1.Service{Namespace: "ns", Name: "other"})
+
+	ep1 := &localv1.Endpoint{IPs: &localv1.IPSet{V4: []string{"10.2.0.1"}}}
+	ep2 := &localv1.Endpoint{IPs: &localv1.IPSet{V4: []string{"10.2.0.2"}}}
+	sl.SetEndpoint("ns", "sticky", "a", ep1)
+	sl.SetEndpoint("ns", "sticky", "b", ep2)
+	sl.SetEndpoint("ns", "other", "a", ep1)
+
+	fmt.Println("// same IPs")
+	sl.SetEndpoint("ns", "sticky", "a", &localv1.Endpoint{IPs: &localv1.IPSet{V4: []string{"10.2.0.1"}}})
+
+	fmt.Println("// IP changed")
+	sl.SetEndpoint("ns", "sticky", "b", &localv1.Endpoint{IPs: &localv1.IPSet{V4: []string{"10.2.0.3"}}})
+
+	fmt.Println("// deleted")
+	sl.DeleteEndpoint("ns", "sticky", "a")
+	sl.DeleteEndpoint("ns", "sticky", "a")
+
+	fmt.Println("// no affinity")
+	sl.DeleteEndpoint("ns", "other", "a")
+
+	// Output:
+	// // same IPs
+	// // IP changed
+	// RESET svc: ns/sticky
+	//     endpoint: IPs:{V4:"10.2.0.2"}
+	// // deleted
+	// RESET svc: ns/sticky
+	//     endpoint: IPs:{V4:"10.2.0.1"}
+	// // no affinity
+}
+
+func ExampleServicesListener_proxyLoadBalancerIPs() {
+	sl := New()
+	sl.IPsListener = ipsLsnr{}
+
+	fmt.Println("// load-balancer IP in VIP mode")
+	sl.SetService(&localv1.Service{Namespace: "ns", Name: "svc",
+		IPs: &localv1.ServiceIPs{LoadBalancerIPs: localv1.NewIPSet("1.2.3.4")}})
+
+	fmt.Println("// switched to Proxy mode")
+	sl.SetService(&localv1.Service{Namespace: "ns", Name: "svc",
+		IPs: &localv1.ServiceIPs{LoadBalancerIPs: localv1.NewIPSet("1.2.3.4"), ProxyLoadBalancerIPs: localv1.NewIPSet("1.2.3.4")}})
+
+	// Output:
+	// // load-balancer IP in VIP mode
+	// ADD svc: Namespace:"ns" Name:"svc" IPs:{LoadBalancerIPs:{V4:"1.2.3.4"}}
+	//     ip: 1.2.3.4 (LoadBalancerIP)
+	// // switched to Proxy mode
+	// DEL svc: Namespace:"ns" Name:"svc" IPs:{LoadBalancerIPs:{V4:"1.2.3.4"}}
+	//     ip: 1.2.3.4 (LoadBalancerIP)
+}
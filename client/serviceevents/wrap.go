@@ -29,6 +29,9 @@ type wrapper struct {
 }
 
 var _ decoder.Interface = wrapper{}
+var _ decoder.InitialSyncListener = wrapper{}
+var _ decoder.FailingSyncer = wrapper{}
+var _ decoder.ExternalNamesListener = wrapper{}
 
 // Wrap a decoder so it receives detailled events depending on which interfaces
 // it implements.
@@ -60,6 +63,9 @@ func Wrap(backend decoder.Interface) decoder.Interface {
 	if v, ok := backend.(TrafficPolicyListener); ok {
 		l.TrafficPolicyListener = v
 	}
+	if v, ok := backend.(SessionAffinityResetListener); ok {
+		l.SessionAffinityResetListener = v
+	}
 
 	wrap := wrapper{
 		Interface: backend,
@@ -77,3 +83,38 @@ func (w wrapper) DeleteService(namespace, name string) {
 	w.l.DeleteService(namespace, name)
 	w.Interface.DeleteService(namespace, name)
 }
+
+func (w wrapper) SetEndpoint(namespace, serviceName, key string, endpoint *localv1.Endpoint) {
+	w.Interface.SetEndpoint(namespace, serviceName, key, endpoint)
+	w.l.SetEndpoint(namespace, serviceName, key, endpoint)
+}
+
+func (w wrapper) DeleteEndpoint(namespace, serviceName, key string) {
+	w.Interface.DeleteEndpoint(namespace, serviceName, key)
+	w.l.DeleteEndpoint(namespace, serviceName, key)
+}
+
+func (w wrapper) InitialSync() {
+	if l, ok := w.Interface.(decoder.InitialSyncListener); ok {
+		l.InitialSync()
+	}
+}
+
+func (w wrapper) SetExternalName(service *localv1.Service) {
+	if l, ok := w.Interface.(decoder.ExternalNamesListener); ok {
+		l.SetExternalName(service)
+	}
+}
+
+func (w wrapper) DeleteExternalName(namespace, name string) {
+	if l, ok := w.Interface.(decoder.ExternalNamesListener); ok {
+		l.DeleteExternalName(namespace, name)
+	}
+}
+
+func (w wrapper) SyncErr() error {
+	if f, ok := w.Interface.(decoder.FailingSyncer); ok {
+		return f.SyncErr()
+	}
+	return nil
+}
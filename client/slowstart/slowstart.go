@@ -0,0 +1,193 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package slowstart ramps up the traffic share of new endpoints over a window, so pods joining
+// behind high-traffic services are not overloaded while they warm up. Backends scale the weight
+// (or the selection probability) of each endpoint by its Factor.
+package slowstart
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+)
+
+type Config struct {
+	// Window is the duration of the ramp-up (0 disables slow start).
+	Window time.Duration
+	// MinFactor is the share of its traffic a new endpoint starts with, between 0 and 1.
+	MinFactor float64
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.DurationVar(&c.Window, "slow-start-window", 0, "Duration over which the traffic share of new endpoints ramps up (0 to disable)")
+	flags.Float64Var(&c.MinFactor, "slow-start-min-factor", 0.1, "Share of its traffic a new endpoint starts with during slow start, between 0 and 1")
+}
+
+func (c *Config) Enabled() bool {
+	return c.Window > 0
+}
+
+// Backend is implemented by the backends ramping up the new endpoints.
+type Backend interface {
+	SupportsSlowStart() bool
+}
+
+// CheckBackend returns an error if slow start is enabled and the backend named use doesn't support it.
+func (c *Config) CheckBackend(use string, backend interface{}) error {
+	if !c.Enabled() {
+		return nil
+	}
+	if b, ok := backend.(Backend); ok && b.SupportsSlowStart() {
+		return nil
+	}
+	return fmt.Errorf("--slow-start-window is not supported by %s", use)
+}
+
+// Ramp tracks the endpoints ramping up. A nil Ramp has no endpoint ramping up.
+type Ramp struct {
+	cfg Config
+	now func() time.Time
+
+	mu     sync.Mutex
+	synced bool
+	added  map[string]time.Time
+}
+
+func New(cfg Config) *Ramp {
+	return &Ramp{
+		cfg:   cfg,
+		now:   time.Now,
+		added: map[string]time.Time{},
+	}
+}
+
+// Synced is to be called once the initial endpoints are known: only the endpoints added later
+// ramp up, so restarting a backend does not throttle all of them.
+func (r *Ramp) Synced() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.synced = true
+}
+
+// Add starts the ramp-up of an endpoint (identified by key), unless it's already known.
+func (r *Ramp) Add(key string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.synced {
+		return
+	}
+	if _, ok := r.added[key]; !ok {
+		r.added[key] = r.now()
+	}
+}
+
+// Delete forgets an endpoint, so it ramps up again if it comes back.
+func (r *Ramp) Delete(key string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.added, key)
+}
+
+// Factor returns the share of its traffic an endpoint should receive now, in ]0, 1].
+func (r *Ramp) Factor(key string) float64 {
+	if r == nil {
+		return 1
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	added, ok := r.added[key]
+	if !ok {
+		return 1
+	}
+
+	elapsed := r.now().Sub(added)
+	if elapsed >= r.cfg.Window {
+		return 1
+	}
+
+	return r.cfg.MinFactor + (1-r.cfg.MinFactor)*float64(elapsed)/float64(r.cfg.Window)
+}
+
+// Weight returns the weight scaled by the Factor of the endpoint (at least 1).
+func (r *Ramp) Weight(key string, weight int32) int32 {
+	scaled := int32(float64(weight) * r.Factor(key))
+	if scaled < 1 {
+		return 1
+	}
+	return scaled
+}
+
+// Ramping returns true if an endpoint is ramping up.
+func (r *Ramp) Ramping(key string) bool {
+	return r.Factor(key) < 1
+}
+
+// Step is the interval at which the backends applying weights should refresh them.
+func (r *Ramp) Step() time.Duration {
+	step := r.cfg.Window / 10
+	if step < time.Second {
+		step = time.Second
+	}
+	return step
+}
+
+var std *Ramp
+
+// Setup enables slow start if the configuration requires it.
+func Setup(cfg *Config) error {
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	if cfg.MinFactor <= 0 || cfg.MinFactor > 1 {
+		return fmt.Errorf("invalid slow start min factor %v, must be in ]0, 1]", cfg.MinFactor)
+	}
+
+	klog.Infof("slow start enabled (window: %v, min factor: %v)", cfg.Window, cfg.MinFactor)
+	std = New(*cfg)
+	return nil
+}
+
+// Enabled returns true if slow start is enabled.
+func Enabled() bool {
+	return std != nil
+}
+
+// Default returns the global ramp (nil if disabled).
+func Default() *Ramp {
+	return std
+}
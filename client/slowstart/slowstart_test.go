@@ -0,0 +1,103 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package slowstart
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRamp(t *testing.T) {
+	now := time.Unix(0, 0)
+
+	r := New(Config{Window: 10 * time.Second, MinFactor: 0.1})
+	r.now = func() time.Time { return now }
+
+	// initial endpoints don't ramp up
+	r.Add("initial")
+	r.Synced()
+	r.Add("new")
+
+	if f := r.Factor("initial"); f != 1 {
+		t.Errorf("initial endpoint: expected factor 1, got %v", f)
+	}
+
+	for _, step := range []struct {
+		elapsed time.Duration
+		factor  float64
+		weight  int32
+	}{
+		{0, 0.1, 10},
+		{5 * time.Second, 0.55, 55},
+		{10 * time.Second, 1, 100},
+	} {
+		now = time.Unix(0, 0).Add(step.elapsed)
+
+		if f := r.Factor("new"); f < step.factor-0.001 || f > step.factor+0.001 {
+			t.Errorf("after %v: expected factor %v, got %v", step.elapsed, step.factor, f)
+		}
+		if w := r.Weight("new", 100); w != step.weight {
+			t.Errorf("after %v: expected weight %d, got %d", step.elapsed, step.weight, w)
+		}
+	}
+
+	if r.Ramping("new") {
+		t.Error("expected the ramp-up to be done")
+	}
+
+	// weights never drop to 0
+	r.Add("other")
+	if w := r.Weight("other", 1); w != 1 {
+		t.Errorf("expected weight 1, got %d", w)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	var r *Ramp
+	r.Add("test")
+
+	if f := r.Factor("test"); f != 1 {
+		t.Errorf("expected factor 1, got %v", f)
+	}
+}
+
+type rampingBackend bool
+
+func (b rampingBackend) SupportsSlowStart() bool { return bool(b) }
+
+func TestCheckBackend(t *testing.T) {
+	enabled := &Config{Window: time.Minute, MinFactor: 0.1}
+
+	for _, tc := range []struct {
+		name    string
+		cfg     *Config
+		backend interface{}
+		fails   bool
+	}{
+		{"disabled", &Config{}, struct{}{}, false},
+		{"supported", enabled, rampingBackend(true), false},
+		{"unsupported", enabled, rampingBackend(false), true},
+		{"not implemented", enabled, struct{}{}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.CheckBackend("to-test", tc.backend)
+			if (err != nil) != tc.fails {
+				t.Errorf("expected failure: %v, got %v", tc.fails, err)
+			}
+		})
+	}
+}
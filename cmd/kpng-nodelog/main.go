@@ -79,7 +79,7 @@ func (s *sink) Send(op *localv1.OpItem) (err error) {
 
 		var v proto.Message
 		switch set.Ref.Set {
-		case localv1.Set_ServicesSet:
+		case localv1.Set_ServicesSet, localv1.Set_ExternalNamesSet:
 			v = &localv1.Service{}
 		case localv1.Set_EndpointsSet:
 			v = &localv1.Endpoint{}
@@ -111,6 +111,9 @@ func (s *sink) Send(op *localv1.OpItem) (err error) {
 		fmt.Println("-", refStr, "->", prev)
 		delete(prevs, refStr)
 
+	case *localv1.OpItem_InitialSync:
+		fmt.Println("> initial state complete")
+
 	case *localv1.OpItem_Sync:
 		fmt.Println("> sync after", time.Since(s.start))
 		s.start = time.Time{}
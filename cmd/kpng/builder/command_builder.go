@@ -19,17 +19,44 @@ package builder
 import (
 	"context"
 	"errors"
+	"fmt"
+	"os"
+	"strings"
 
 	"k8s.io/klog/v2"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/auditlog"
 	"sigs.k8s.io/kpng/client/backendcmd"
+	"sigs.k8s.io/kpng/client/drain"
+	"sigs.k8s.io/kpng/client/drift"
+	"sigs.k8s.io/kpng/client/faultinject"
 	"sigs.k8s.io/kpng/client/localsink"
+	"sigs.k8s.io/kpng/client/localsink/backpressure"
+	"sigs.k8s.io/kpng/client/localsink/cniwait"
+	"sigs.k8s.io/kpng/client/localsink/decoder"
+	"sigs.k8s.io/kpng/client/localsink/familyfilter"
+	"sigs.k8s.io/kpng/client/localsink/initialsync"
+	"sigs.k8s.io/kpng/client/localsink/migrate"
+	"sigs.k8s.io/kpng/client/localsink/nodestate"
+	"sigs.k8s.io/kpng/client/localsink/readyfilter"
+	"sigs.k8s.io/kpng/client/localsink/requeue"
+	"sigs.k8s.io/kpng/client/localsink/selftest"
+	"sigs.k8s.io/kpng/client/localsink/servicelatency"
+	"sigs.k8s.io/kpng/client/localsink/supervise"
+	"sigs.k8s.io/kpng/client/localsink/validate"
+	"sigs.k8s.io/kpng/client/nodelocaldns"
+	"sigs.k8s.io/kpng/client/privhelper"
+	"sigs.k8s.io/kpng/client/slowstart"
 
 	"sigs.k8s.io/kpng/server/jobs/store2api"
 	"sigs.k8s.io/kpng/server/jobs/store2file"
 	"sigs.k8s.io/kpng/server/jobs/store2localdiff"
+	"sigs.k8s.io/kpng/server/pkg/endpoints"
+	"sigs.k8s.io/kpng/server/pkg/metrics"
 	"sigs.k8s.io/kpng/server/proxystore"
 )
 
@@ -99,7 +126,10 @@ func ToLocalCmd(ctx context.Context, store *proxystore.Store, storeProducerJobSe
 		Use: "to-local",
 	}
 
-	job := &store2localdiff.Job{}
+	job := &store2localdiff.Job{
+		HostNetworkEndpoints: endpoints.HostNetworkNodeName,
+	}
+	cmd.PersistentFlags().Var(&job.HostNetworkEndpoints, "host-network-endpoints", endpoints.HostNetworkFlagUsage)
 
 	cmd.PersistentPreRunE = func(_ *cobra.Command, _ []string) (err error) {
 		job.Store = store
@@ -126,20 +156,433 @@ func ToLocalCmd(ctx context.Context, store *proxystore.Store, storeProducerJobSe
 func LocalCmds(run func(sink localsink.Sink) error) (cmds []*cobra.Command) {
 	// sink backends
 	for _, useCmd := range backendcmd.Registered() {
+		use := useCmd.Use
 		backend := useCmd.New()
+		cfg := &localConfig{}
 
 		cmd := &cobra.Command{
-			Use: useCmd.Use,
+			Use: use,
 			RunE: func(_ *cobra.Command, _ []string) error {
-				return run(backend.Sink())
+				if err := cfg.checkBackend(use, backend); err != nil {
+					return err
+				}
+				if err := cfg.setup(); err != nil {
+					return err
+				}
+
+				drift.Watch(use, backend)
+				metrics.Kpng_backend.WithLabelValues(use, "explicit").Set(1)
+				return run(cfg.sink(use, cfg.validated(use, backend)))
 			},
 		}
 
 		backend.BindFlags(cmd.Flags())
+		cfg.bindFlags(cmd.Flags())
 		klog.Infof("Appending discovered command %v", cmd.Name())
 		cmds = append(cmds, cmd)
 	}
 
+	cmds = append(cmds, autoCmd(run), migrateCmd(run))
+
+	return
+}
+
+// autoCmd runs the first backend of backendcmd.AutoPreference supported by the host.
+func autoCmd(run func(sink localsink.Sink) error) *cobra.Command {
+	cfg := &localConfig{}
+
+	cmd := &cobra.Command{
+		Use:   "to-auto",
+		Short: "run the first backend supported by this host among " + strings.Join(backendcmd.AutoPreference, ", "),
+		Long: `Probe the host and run the first supported backend among ` + strings.Join(backendcmd.AutoPreference, ", ") + `.
+The flags of the selected backend are accepted too; the flags of the other backends are rejected.`,
+		// the flags of the backend are only known once it's selected
+		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			// setup first, so the probes go through the privileged helper if any
+			if err := cfg.setup(); err != nil {
+				return err
+			}
+
+			selected, backend, rejected, err := backendcmd.Select(backendcmd.AutoPreference)
+			for _, r := range rejected {
+				klog.Infof("backend %s is not supported: %v", r.Use, r.Err)
+			}
+			if err != nil {
+				return err
+			}
+
+			klog.Infof("automatically selected backend %s", selected.Use)
+
+			if err := parseBackendFlags(cmd, os.Args[1:], backend); err != nil {
+				return err
+			}
+			if err := cfg.checkBackend(selected.Use, backend); err != nil {
+				return err
+			}
+
+			drift.Watch(selected.Use, backend)
+			metrics.Kpng_backend.WithLabelValues(selected.Use, "auto").Set(1)
+			return run(cfg.sink(selected.Use, cfg.validated(selected.Use, backend)))
+		},
+	}
+
+	cfg.bindFlags(cmd.Flags())
+
+	return cmd
+}
+
+// migrateCmd runs two backends, the services moving from the first to the second in steps.
+func migrateCmd(run func(sink localsink.Sink) error) *cobra.Command {
+	cfg := &localConfig{}
+	migrateCfg := &migrate.Config{}
+	var from, to string
+
+	cmd := &cobra.Command{
+		Use:   "to-migrate",
+		Short: "migrate the services from a backend to another in steps",
+		Long: `Run two backends, moving a growing share of the services from the first to the second,
+as long as the migrated services stay reachable. The flags of both backends are accepted too.`,
+		// the flags of the backends are only known once they are parsed
+		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if from == to {
+				return fmt.Errorf("can't migrate from %s to itself", from)
+			}
+
+			fromCmd, ok := backendcmd.Lookup(from)
+			if !ok {
+				return fmt.Errorf("unknown backend %q", from)
+			}
+			toCmd, ok := backendcmd.Lookup(to)
+			if !ok {
+				return fmt.Errorf("unknown backend %q", to)
+			}
+
+			fromBackend, toBackend := fromCmd.New(), toCmd.New()
+			if err := parseBackendFlags(cmd, os.Args[1:], fromBackend, toBackend); err != nil {
+				return err
+			}
+			if err := cfg.checkBackend(from, fromBackend); err != nil {
+				return err
+			}
+			if err := cfg.checkBackend(to, toBackend); err != nil {
+				return err
+			}
+
+			if err := cfg.setup(); err != nil {
+				return err
+			}
+
+			sink, err := migrate.New(*migrateCfg, cfg.validated(from, fromBackend), cfg.validated(to, toBackend))
+			if err != nil {
+				return err
+			}
+
+			drift.Watch(from, fromBackend)
+			drift.Watch(to, toBackend)
+			metrics.Kpng_backend.WithLabelValues(from, "migrate-from").Set(1)
+			metrics.Kpng_backend.WithLabelValues(to, "migrate-to").Set(1)
+			return run(cfg.sink("to-migrate", sink))
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&from, "migrate-from", "to-iptables", "Backend the services are migrated from")
+	flags.StringVar(&to, "migrate-to", "to-nft", "Backend the services are migrated to")
+	migrateCfg.BindFlags(flags)
+	cfg.bindFlags(flags)
+
+	return cmd
+}
+
+// parseBackendFlags parses the flags of backends in args, the flags of cmd being already parsed.
+// The flags bound by more than one backend are set in all of them.
+func parseBackendFlags(cmd *cobra.Command, args []string, backends ...backendcmd.Cmd) error {
+	flags := pflag.NewFlagSet(cmd.Name(), pflag.ContinueOnError)
+
+	for _, backend := range backends {
+		backendFlags := pflag.NewFlagSet(cmd.Name(), pflag.ContinueOnError)
+		backend.BindFlags(backendFlags)
+
+		backendFlags.VisitAll(func(f *pflag.Flag) {
+			if shared := flags.Lookup(f.Name); shared != nil {
+				shared.Value = sharedValue{shared.Value, f.Value}
+				return
+			}
+			flags.AddFlag(f)
+		})
+	}
+
+	// accept the flags of cmd without changing them again
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if flags.Lookup(f.Name) != nil {
+			return
+		}
+		shorthand := f.Shorthand
+		if flags.ShorthandLookup(shorthand) != nil {
+			shorthand = ""
+		}
+		flags.AddFlag(&pflag.Flag{
+			Name:        f.Name,
+			Shorthand:   shorthand,
+			NoOptDefVal: f.NoOptDefVal,
+			Value:       ignoredValue(f.Value.Type()),
+		})
+	})
+
+	return flags.Parse(args)
+}
+
+// ignoredValue is a flag value discarding what it's set to.
+type ignoredValue string
+
+func (v ignoredValue) String() string   { return "" }
+func (v ignoredValue) Set(string) error { return nil }
+func (v ignoredValue) Type() string     { return string(v) }
+
+// sharedValue is a flag value setting the values of a flag bound by more than one backend.
+type sharedValue []pflag.Value
+
+func (v sharedValue) String() string { return v[0].String() }
+func (v sharedValue) Type() string   { return v[0].Type() }
+
+func (v sharedValue) Set(s string) error {
+	for _, value := range v {
+		if err := value.Set(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localConfig holds the settings shared by the local commands.
+type localConfig struct {
+	audit     auditlog.Config
+	faults    faultinject.Config
+	helper    privhelper.Config
+	slowStart slowstart.Config
+	drain     drain.Config
+	selfTest  selftest.Config
+	requeue   requeue.Config
+	pace      backpressure.Config
+	latency   servicelatency.Config
+	nodeState nodestate.Config
+	localDNS  nodelocaldns.Config
+	cni       cniwait.Config
+	initial   initialsync.Config
+
+	nodeStatePublisher nodestate.Publisher
+	validators         []*validate.Sink
+	// families are the IP families requested by the backends, nil for all
+	families    []localv1.IPFamily
+	allFamilies bool
+	// terminating is true if a backend handles the terminating endpoints
+	terminating bool
+	// failingSyncs is true if a backend reports its failed syncs
+	failingSyncs bool
+	// backgroundSyncers are the backends reporting the failures of the syncs they do on their own
+	backgroundSyncers []decoder.BackgroundSyncer
+}
+
+func (c *localConfig) bindFlags(flags *pflag.FlagSet) {
+	c.audit.BindFlags(flags)
+	c.faults.BindFlags(flags)
+	c.helper.BindFlags(flags)
+	c.slowStart.BindFlags(flags)
+	c.drain.BindFlags(flags)
+	c.selfTest.BindFlags(flags)
+	c.requeue.BindFlags(flags)
+	c.pace.BindFlags(flags)
+	c.latency.BindFlags(flags)
+	c.nodeState.BindFlags(flags)
+	c.localDNS.BindFlags(flags)
+	c.cni.BindFlags(flags)
+	c.initial.BindFlags(flags)
+}
+
+func (c *localConfig) setup() error {
+	if err := auditlog.Setup(&c.audit); err != nil {
+		return err
+	}
+	faultinject.Setup(&c.faults)
+	privhelper.Setup(&c.helper)
+	if err := slowstart.Setup(&c.slowStart); err != nil {
+		return err
+	}
+	if err := nodelocaldns.Setup(&c.localDNS); err != nil {
+		return err
+	}
+	if err := c.pace.Check(); err != nil {
+		return err
+	}
+	if err := c.latency.Check(); err != nil {
+		return err
+	}
+	if err := c.cni.Check(); err != nil {
+		return err
+	}
+	if err := c.initial.Check(); err != nil {
+		return err
+	}
+	if c.nodeState.Enabled() {
+		publisher, err := newNodeStatePublisher(c.nodeState.Kubeconfig)
+		if err != nil {
+			return err
+		}
+		c.nodeStatePublisher = publisher
+	}
+	return drain.Setup(&c.drain)
+}
+
+// checkBackend returns an error if a setting isn't supported by the backend named use.
+func (c *localConfig) checkBackend(use string, backend backendcmd.Cmd) error {
+	if err := c.audit.CheckBackend(use, backend); err != nil {
+		return err
+	}
+	if err := c.slowStart.CheckBackend(use, backend); err != nil {
+		return err
+	}
+	return c.drain.CheckBackend(use, backend)
+}
+
+// sink returns the sink of the backend named use, wrapped, from the innermost, to:
+// - record the programming latency of the critical services and priority classes, if enabled
+// - publish the node state, if enabled
+// - re-deliver the state after a failed sync, if the backend reports them
+// - self-test the programmed services, if enabled
+// - program the initial state in steps, if enabled
+// - space the syncs, if enabled
+// - delay the setup until the CNI is ready, if enabled
+// - count the syncs for the revisions of the audit log and of the rule comments
+// - request the IP families and the terminating endpoints the backends handle
+func (c *localConfig) sink(use string, sink localsink.Sink) localsink.Sink {
+	if c.latency.Enabled() {
+		latency := servicelatency.New(c.latency, sink)
+		latency.Latency = func(namespace, name string) servicelatency.Observer {
+			return metrics.Kpng_service_programming_latency.WithLabelValues(use, namespace, name)
+		}
+		latency.ClassLatency = func(class string) servicelatency.Observer {
+			return metrics.Kpng_priority_programming_latency.WithLabelValues(use, class)
+		}
+		sink = latency
+	}
+
+	if c.nodeState.Enabled() {
+		state := nodestate.New(c.nodeState, use, c.nodeStatePublisher, sink)
+		state.Rejected = c.rejected
+		sink = state
+	}
+
+	if c.failingSyncs {
+		requeued := requeue.New(c.requeue, sink)
+		requeued.Failed = metrics.Kpng_sync_failures.WithLabelValues(use)
+		requeued.Requeued = metrics.Kpng_sync_retries.WithLabelValues(use)
+		for _, syncer := range c.backgroundSyncers {
+			syncer.OnSyncErr(requeued.SyncFailed)
+		}
+		sink = requeued
+	}
+
+	if c.selfTest.Enabled() {
+		selfTest := selftest.New(c.selfTest, sink)
+		selfTest.Succeeded = metrics.Kpng_self_test_probes.WithLabelValues(use, "success")
+		selfTest.Failed = metrics.Kpng_self_test_probes.WithLabelValues(use, "failure")
+		selfTest.Failing = metrics.Kpng_self_test_failing.WithLabelValues(use)
+		sink = selfTest
+	}
+
+	if c.initial.Enabled() {
+		initial := initialsync.New(c.initial, sink)
+		initial.Progress = metrics.Kpng_initial_sync_progress.WithLabelValues(use)
+		sink = initial
+	}
+
+	if c.pace.Enabled() {
+		paced := backpressure.New(c.pace, sink)
+		paced.Period = metrics.Kpng_sync_period.WithLabelValues(use)
+		paced.Rate = metrics.Kpng_sync_change_rate.WithLabelValues(use)
+		paced.Delayed = metrics.Kpng_sync_backpressure.WithLabelValues(use)
+		sink = paced
+	}
+
+	if c.cni.Enabled() {
+		sink = cniwait.New(c.cni, sink)
+	}
+	sink = localsink.RequestingIPFamilies(auditlog.NewSink(sink), c.requestedIPFamilies())
+	return localsink.RequestingTerminatingEndpoints(sink, c.terminating)
+}
+
+// validated returns the sink of the backend named use, restarted after a panic, withholding the
+// services it rejects if it validates the state, the terminating endpoints if it doesn't handle
+// them, and the IPs of the families it doesn't handle.
+func (c *localConfig) validated(use string, backend backendcmd.Cmd) localsink.Sink {
+	supervised := supervise.New(backend.Sink)
+	supervised.Panicked = metrics.Kpng_backend_panics.WithLabelValues(use, "sink")
+	// the goroutines are counted for the last backend (the target of a migration)
+	supervise.GoroutinePanicked = metrics.Kpng_backend_panics.WithLabelValues(use, "goroutine")
+
+	var sink localsink.Sink = supervised
+
+	if validator, ok := backend.(validate.Validator); ok {
+		validated := validate.New(validator, sink)
+		validated.Rejected = metrics.Kpng_rejected_services.WithLabelValues(use)
+		c.validators = append(c.validators, validated)
+		sink = validated
+	}
+
+	if requeue.ReportsFailures(backend) {
+		c.failingSyncs = true
+
+		if syncer, ok := backend.(decoder.BackgroundSyncer); ok {
+			c.backgroundSyncers = append(c.backgroundSyncers, syncer)
+		}
+	}
+
+	if readyfilter.Handles(backend) {
+		c.terminating = true
+	} else {
+		sink = readyfilter.New(sink)
+	}
+
+	filtered, ok := backend.(familyfilter.Backend)
+	if !ok {
+		c.allFamilies = true
+		return sink
+	}
+
+	for _, family := range filtered.IPFamilies() {
+		if !hasIPFamily(c.families, family) {
+			c.families = append(c.families, family)
+		}
+	}
+	return familyfilter.New(filtered.IPFamilies(), sink)
+}
+
+// requestedIPFamilies returns the IP families handled by the backends, nil if one handles all.
+func (c *localConfig) requestedIPFamilies() []localv1.IPFamily {
+	if c.allFamilies {
+		return nil
+	}
+	return c.families
+}
+
+func hasIPFamily(families []localv1.IPFamily, family localv1.IPFamily) bool {
+	for _, f := range families {
+		if f == family {
+			return true
+		}
+	}
+	return false
+}
+
+// rejected returns the services rejected by the backends at their last sync.
+func (c *localConfig) rejected() (rejected []string) {
+	for _, v := range c.validators {
+		for _, r := range v.Rejections() {
+			rejected = append(rejected, r.String())
+		}
+	}
 	return
 }
 
@@ -0,0 +1,90 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"sigs.k8s.io/kpng/client/localsink/nodestate"
+)
+
+// nodeProxyStatesGVR are the NodeProxyState objects, defined by hack/kpng-nodeproxystate-crd.yaml.
+var nodeProxyStatesGVR = schema.GroupVersionResource{
+	Group:    "kpng.sigs.k8s.io",
+	Version:  "v1alpha1",
+	Resource: "nodeproxystates",
+}
+
+// nodeStatePublisher publishes the state of the node as the NodeProxyState named after it.
+type nodeStatePublisher struct {
+	client dynamic.Interface
+}
+
+var _ nodestate.Publisher = nodeStatePublisher{}
+
+func newNodeStatePublisher(kubeconfig string) (nodeStatePublisher, error) {
+	cfg, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nodeStatePublisher{}, fmt.Errorf("error building the kubeconfig of the node state: %w", err)
+	}
+
+	client, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nodeStatePublisher{}, fmt.Errorf("error building the kubernetes client of the node state: %w", err)
+	}
+
+	return nodeStatePublisher{client: client}, nil
+}
+
+func (p nodeStatePublisher) Publish(ctx context.Context, state nodestate.State) error {
+	status := map[string]interface{}{
+		"backend":   state.Backend,
+		"services":  int64(state.Services),
+		"endpoints": int64(state.Endpoints),
+	}
+	if !state.LastSync.IsZero() {
+		status["lastSyncTime"] = state.LastSync.UTC().Format(time.RFC3339)
+	}
+	if state.Error != "" {
+		status["error"] = state.Error
+	}
+	if len(state.Rejected) != 0 {
+		rejected := make([]interface{}, 0, len(state.Rejected))
+		for _, r := range state.Rejected {
+			rejected = append(rejected, r)
+		}
+		status["rejected"] = rejected
+	}
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": nodeProxyStatesGVR.GroupVersion().String(),
+		"kind":       "NodeProxyState",
+		"metadata":   map[string]interface{}{"name": state.Node},
+		"status":     status,
+	}}
+
+	_, err := p.client.Resource(nodeProxyStatesGVR).Apply(ctx, state.Node, obj, metav1.ApplyOptions{FieldManager: "kpng", Force: true})
+	return err
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/kpng/client/backendcmd"
+	"sigs.k8s.io/kpng/client/privhelper"
+)
+
+// checkBackendCmd runs the environment checks of a backend and prints their report. It fails
+// when a check fails, so it can run as an init container before the backend starts.
+func checkBackendCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check-backend",
+		Short: "check that the host can run a backend (as an init container)",
+	}
+
+	for _, useCmd := range backendcmd.Registered() {
+		cmd.AddCommand(checkOneBackendCmd(useCmd))
+	}
+
+	return cmd
+}
+
+func checkOneBackendCmd(useCmd backendcmd.UseCmd) *cobra.Command {
+	backend := useCmd.New()
+	helper := &privhelper.Config{}
+
+	cmd := &cobra.Command{
+		Use:          useCmd.Use,
+		Aliases:      []string{strings.TrimPrefix(useCmd.Use, "to-")},
+		Short:        "check that the host can run " + useCmd.Use,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			privhelper.Setup(helper)
+
+			checks := backendcmd.Checks(backend)
+			if len(checks) == 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s has no checks\n", useCmd.Use)
+				return nil
+			}
+
+			if failed := backendcmd.WriteReport(cmd.OutOrStdout(), checks); failed != 0 {
+				return fmt.Errorf("%d of %d checks of %s failed", failed, len(checks), useCmd.Use)
+			}
+			return nil
+		},
+	}
+
+	backend.BindFlags(cmd.Flags())
+	helper.BindFlags(cmd.Flags())
+
+	return cmd
+}
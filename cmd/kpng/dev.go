@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/klog/v2"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/backendcmd"
+	"sigs.k8s.io/kpng/client/drift"
+	"sigs.k8s.io/kpng/client/localsink"
+	"sigs.k8s.io/kpng/server/jobs/file2store"
+	"sigs.k8s.io/kpng/server/jobs/store2localdiff"
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+// devCmd runs the file source and a backend writing to in-memory fakes of the kernel, printing
+// their state when it changes: an inner loop for backend developers, without a Linux host.
+func devCmd() *cobra.Command {
+	var (
+		input string
+		full  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "dev",
+		Short: "run a backend against in-memory kernel fakes, fed by a file, and print its rules on changes",
+	}
+
+	flags := cmd.PersistentFlags()
+	flags.StringVarP(&input, "input", "i", "globalv1-state.yaml", "Input file for the globalv1-state, reloaded when it changes")
+	flags.BoolVar(&full, "full", false, "Print the whole state after each change, instead of the added and removed lines")
+
+	for _, useCmd := range backendcmd.Registered() {
+		backend := useCmd.New()
+		kernel, ok := backend.(backendcmd.MemoryKernel)
+		if !ok {
+			continue
+		}
+
+		backendCmd := &cobra.Command{
+			Use:   useCmd.Use,
+			Short: "run " + useCmd.Use + " against in-memory kernel fakes",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				ctx := setupGlobal()
+
+				kernel.UseMemoryKernel()
+
+				store := proxystore.New()
+				go (&file2store.Job{FilePath: input, Store: store}).Run(ctx)
+
+				job := &store2localdiff.Job{
+					Store: store,
+					Sink: &devSink{
+						Sink:   backend.Sink(),
+						kernel: kernel,
+						out:    cmd.OutOrStdout(),
+						full:   full,
+					},
+				}
+				return job.Run(ctx)
+			},
+		}
+
+		backend.BindFlags(backendCmd.Flags())
+		cmd.AddCommand(backendCmd)
+	}
+
+	return cmd
+}
+
+// devSink passes the operations to the sink of a backend, and prints the state of its in-memory
+// kernel after each sync changing it.
+type devSink struct {
+	localsink.Sink
+
+	kernel backendcmd.MemoryKernel
+	out    io.Writer
+	full   bool
+
+	syncs   int
+	printed bool
+	last    string
+}
+
+func (s *devSink) Send(op *localv1.OpItem) error {
+	if err := s.Sink.Send(op); err != nil {
+		return err
+	}
+
+	if _, ok := op.Op.(*localv1.OpItem_Sync); !ok {
+		return nil
+	}
+	s.syncs++
+
+	buf := &strings.Builder{}
+	if err := s.kernel.WriteKernelState(buf); err != nil {
+		klog.Error("failed to write the state of the in-memory kernel: ", err)
+		return nil
+	}
+
+	state := buf.String()
+	if s.printed && state == s.last {
+		return nil
+	}
+	first, prev := !s.printed, s.last
+	s.printed, s.last = true, state
+
+	if first || s.full {
+		fmt.Fprintf(s.out, "### sync %d\n%s", s.syncs, state)
+		return nil
+	}
+
+	// the lines moved within the state are not printed
+	report := drift.Compare(strings.Split(prev, "\n"), strings.Split(state, "\n"))
+	fmt.Fprintf(s.out, "### sync %d: %d lines removed, %d added\n", s.syncs, len(report.Missing), len(report.Unexpected))
+	for _, line := range report.Missing {
+		fmt.Fprintln(s.out, "-", line)
+	}
+	for _, line := range report.Unexpected {
+		fmt.Fprintln(s.out, "+", line)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/kpng/client/drain"
+)
+
+// drainCmd asks a running backend (started with --drain-socket) to stop accepting new
+// connections to the node ports and external IPs, or to accept them again with --undo.
+func drainCmd() *cobra.Command {
+	var (
+		socket  string
+		undo    bool
+		status  bool
+		timeout time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "drain",
+		Short: "stop accepting new connections to node ports and external IPs (for node maintenance)",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			var req *bool
+			if !status {
+				drainIt := !undo
+				req = &drainIt
+			}
+
+			draining, err := drain.Request(ctx, socket, req)
+			if err != nil {
+				return err
+			}
+
+			if draining {
+				fmt.Println("draining")
+			} else {
+				fmt.Println("not draining")
+			}
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&socket, "socket", "/run/kpng/drain.sock", "drain socket of the backend (its --drain-socket)")
+	flags.BoolVar(&undo, "undo", false, "accept new connections again")
+	flags.BoolVar(&status, "status", false, "only print whether the node is draining")
+	flags.DurationVar(&timeout, "timeout", 10*time.Second, "request timeout")
+
+	return cmd
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/kpng/backends/ebpf"
+)
+
+// ebpfCmd groups the commands inspecting and removing the state of the ebpf backend (to-ebpf).
+func ebpfCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ebpf",
+		Short: "inspect or remove the pinned state of the ebpf backend (to-ebpf)",
+	}
+
+	cmd.AddCommand(ebpfDumpCmd(), ebpfCleanupCmd())
+
+	return cmd
+}
+
+// ebpfDumpCmd prints the service and backend maps pinned by the ebpf backend, with the
+// counters of the services.
+func ebpfDumpCmd() *cobra.Command {
+	pinPath := ebpf.DefaultPinPath
+
+	cmd := &cobra.Command{
+		Use:          "dump",
+		Short:        "print the services, their backend slots and counters, and the backends of the pinned maps",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return ebpf.Dump(os.Stdout, pinPath)
+		},
+	}
+
+	cmd.Flags().StringVar(&pinPath, "pin-path", pinPath, "bpffs directory where the backend pins its maps (its --pin-path)")
+
+	return cmd
+}
+
+// ebpfCleanupCmd detaches the program and removes the maps pinned by the ebpf backend, which
+// outlive kpng.
+func ebpfCleanupCmd() *cobra.Command {
+	pinPath := ebpf.DefaultPinPath
+
+	cmd := &cobra.Command{
+		Use:          "cleanup",
+		Short:        "detach the program and remove the maps pinned by the ebpf backend (stop kpng first)",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return ebpf.Unpin(pinPath)
+		},
+	}
+
+	cmd.Flags().StringVar(&pinPath, "pin-path", pinPath, "bpffs directory where the backend pins its maps and link (its --pin-path)")
+
+	return cmd
+}
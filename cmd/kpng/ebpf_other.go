@@ -0,0 +1,38 @@
+//go:build !linux
+// +build !linux
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+)
+
+// ebpfCmd is only available on linux, the only platform of the ebpf backend.
+func ebpfCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:    "ebpf",
+		Short:  "inspect or remove the pinned state of the ebpf backend (linux only)",
+		Hidden: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return errors.New("the ebpf backend is only available on linux")
+		},
+	}
+}
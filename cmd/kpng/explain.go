@@ -0,0 +1,159 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/kpng/client/rulecomment"
+)
+
+var iptablesCommentRE = regexp.MustCompile(`--comment ("[^"]*"|[^ ]+)`)
+
+// explainCmd maps a live rule back to the service port that created it, from its comment.
+func explainCmd() *cobra.Command {
+	var family, table string
+
+	cmd := &cobra.Command{
+		Use:   "explain <iptables-rule|nft-handle>",
+		Short: "print the service port that created a rule",
+		Long: `Print the service port that created a rule, from the comment kpng puts on it.
+
+The argument is either an iptables rule, as printed by iptables-save (ie: kpng explain -- -A KUBE-SVC-... -m comment --comment "kpng:iptables ..."),
+or the handle of an nftables rule (as printed by nft -a list ruleset), looked up in the live ruleset.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			if handle, err := strconv.ParseUint(args[0], 10, 64); err == nil && len(args) == 1 {
+				return explainNftHandle(handle, family, table)
+			}
+			return explainIptablesRule(strings.Join(args, " "))
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&family, "family", "", "only look up the nft handle in the tables of this family (ip, ip6...)")
+	flags.StringVar(&table, "table", "", "only look up the nft handle in this table")
+
+	return cmd
+}
+
+func explainIptablesRule(rule string) error {
+	m := iptablesCommentRE.FindStringSubmatch(rule)
+	if m == nil {
+		return errors.New("the rule has no comment")
+	}
+
+	c, ok := rulecomment.Parse(m[1])
+	if !ok {
+		return fmt.Errorf("the rule was not created by kpng (comment: %s)", m[1])
+	}
+
+	printComment(c)
+	return nil
+}
+
+type nftRuleset struct {
+	Nftables []struct {
+		Rule *nftRule `json:"rule"`
+	} `json:"nftables"`
+}
+
+type nftRule struct {
+	Family  string `json:"family"`
+	Table   string `json:"table"`
+	Chain   string `json:"chain"`
+	Handle  uint64 `json:"handle"`
+	Comment string `json:"comment"`
+}
+
+func explainNftHandle(handle uint64, family, table string) error {
+	out, err := exec.Command("nft", "-j", "list", "ruleset").Output()
+	if err != nil {
+		return fmt.Errorf("nft list ruleset: %w", err)
+	}
+
+	ruleset := nftRuleset{}
+	if err := json.Unmarshal(out, &ruleset); err != nil {
+		return fmt.Errorf("invalid nft output: %w", err)
+	}
+
+	found := 0
+	for _, item := range ruleset.Nftables {
+		rule := item.Rule
+		if rule == nil || rule.Handle != handle ||
+			(family != "" && rule.Family != family) || (table != "" && rule.Table != table) {
+			continue
+		}
+
+		// handles are only unique within a table
+		if found != 0 {
+			fmt.Println()
+		}
+		found++
+
+		fmt.Printf("table:    %s %s\n", rule.Family, rule.Table)
+		fmt.Printf("chain:    %s\n", rule.Chain)
+
+		c, ok := rulecomment.Parse(rule.Comment)
+		if !ok {
+			// comments are skipped when too long, the service chains are named after it
+			c, ok = nftChainComment(rule.Chain)
+		}
+		if !ok {
+			fmt.Println("not created by kpng for a service")
+			continue
+		}
+		printComment(c)
+	}
+
+	if found == 0 {
+		return fmt.Errorf("no nft rule with handle %d", handle)
+	}
+	return nil
+}
+
+// nftChainComment returns the service of a service chain of the nft backend
+// (svc_<namespace>_<name>_...). Kubernetes names can't contain an underscore.
+func nftChainComment(chain string) (c rulecomment.Comment, ok bool) {
+	parts := strings.SplitN(chain, "_", 4)
+	if len(parts) < 3 || parts[0] != "svc" {
+		return
+	}
+	return rulecomment.Comment{Backend: "nft", Service: parts[1] + "/" + parts[2]}, true
+}
+
+func printComment(c rulecomment.Comment) {
+	fmt.Printf("backend:  %s\n", c.Backend)
+	fmt.Printf("service:  %s\n", c.Service)
+	if c.Port != "" {
+		fmt.Printf("port:     %s\n", c.Port)
+	}
+	if c.Revision != 0 {
+		fmt.Printf("revision: %d\n", c.Revision)
+	}
+	if c.Rule != "" {
+		fmt.Printf("rule:     %s\n", c.Rule)
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"sigs.k8s.io/kpng/client/grpcflags"
+	"sigs.k8s.io/kpng/client/tlsflags"
+	"sigs.k8s.io/kpng/cmd/kpng/builder"
+	"sigs.k8s.io/kpng/server/jobs/api2store"
+	"sigs.k8s.io/kpng/server/jobs/federation"
+	"sigs.k8s.io/kpng/server/jobs/kube2store"
+	"sigs.k8s.io/kpng/server/pkg/apiwatch"
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+var (
+	fedKubeConfigs []string
+	fedAPIs        []string
+	fedAPITLS      = &tlsflags.Flags{}
+	fedAPIGRPC     = &grpcflags.Flags{}
+	fedK8sCfg      = &kube2store.K8sConfig{}
+	fedCfg         = &federation.Config{}
+
+	fedKubeClients []*kubernetes.Clientset
+	fedKubeDynamic []dynamic.Interface
+	fedConflicts   federation.ConflictPolicy
+)
+
+// federation2storeCmd merges the state of several clusters (through their kubeconfigs) and/or
+// kpng servers into a single store, so multi-cluster services can be programmed by any backend.
+func federation2storeCmd() *cobra.Command {
+	fedCmd := &cobra.Command{
+		Use:   "federate",
+		Short: "merge the globalv1 state of multiple clusters or kpng APIs",
+	}
+
+	flags := fedCmd.PersistentFlags()
+	flags.StringSliceVar(&fedKubeConfigs, "kubeconfigs", nil, "kubeconfigs of the clusters to watch, by priority order")
+	flags.StringSliceVar(&fedAPIs, "apis", nil, "remote kpng API servers to watch, by priority order (after the kubeconfigs)")
+	fedAPITLS.Bind(flags, "api-client-")
+	fedAPIGRPC.BindClient(flags, "api-client-")
+	fedK8sCfg.BindFlags(flags)
+	fedCfg.BindFlags(flags)
+
+	ctx := setupGlobal()
+	store := proxystore.New()
+	run := func() {
+		federation2storeCmdRun(ctx, store)
+	}
+	fedCmd.AddCommand(builder.ToAPICmd(ctx, store, federation2storeCmdSetup, run))
+	fedCmd.AddCommand(builder.ToFileCmd(ctx, store, federation2storeCmdSetup, run))
+	fedCmd.AddCommand(builder.ToLocalCmd(ctx, store, federation2storeCmdSetup, run))
+
+	return fedCmd
+}
+
+func federation2storeCmdSetup() (err error) {
+	if len(fedKubeConfigs)+len(fedAPIs) == 0 {
+		return errors.New("at least one of --kubeconfigs or --apis is required")
+	}
+
+	fedConflicts, err = fedCfg.ConflictPolicy()
+	if err != nil {
+		return
+	}
+
+	if err = fedK8sCfg.ValidateShards(); err != nil {
+		return
+	}
+
+	fedKubeClients = make([]*kubernetes.Clientset, 0, len(fedKubeConfigs))
+	fedKubeDynamic = make([]dynamic.Interface, 0, len(fedKubeConfigs))
+	for _, kubeConfig := range fedKubeConfigs {
+		cfg, err := clientcmd.BuildConfigFromFlags("", kubeConfig)
+		if err != nil {
+			return fmt.Errorf("Error building kubeconfig %s: %w", kubeConfig, err)
+		}
+		fedK8sCfg.ApplyClientConfig(cfg)
+
+		client, err := kubernetes.NewForConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("Error building kubernetes clientset for %s: %w", kubeConfig, err)
+		}
+
+		dynClient, err := dynamic.NewForConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("Error building kubernetes dynamic client for %s: %w", kubeConfig, err)
+		}
+
+		fedKubeClients = append(fedKubeClients, client)
+		fedKubeDynamic = append(fedKubeDynamic, dynClient)
+	}
+	return nil
+}
+
+// federation2storeCmdRun starts one job per source, each in its own store, and merges them in store.
+func federation2storeCmdRun(ctx context.Context, store *proxystore.Store) {
+	sources := make([]federation.Source, 0, len(fedKubeClients)+len(fedAPIs))
+
+	for i, client := range fedKubeClients {
+		srcStore := proxystore.New()
+		sources = append(sources, federation.Source{Name: fmt.Sprintf("kube-%d", i), Store: srcStore})
+
+		go kube2store.Job{
+			Kube:    client,
+			Dynamic: fedKubeDynamic[i],
+			Store:   srcStore,
+			Config:  fedK8sCfg,
+		}.Run(ctx)
+	}
+
+	for i, api := range fedAPIs {
+		srcStore := proxystore.New()
+		sources = append(sources, federation.Source{Name: fmt.Sprintf("api-%d", i), Store: srcStore})
+
+		job := &api2store.Job{
+			Watch: apiwatch.Watch{Server: api, TLSFlags: fedAPITLS, GRPC: fedAPIGRPC},
+			Store: srcStore,
+		}
+		go job.Run(ctx)
+	}
+
+	(&federation.Job{
+		Sources:     sources,
+		Store:       store,
+		Conflicts:   fedConflicts,
+		SyncAll:     fedCfg.SyncAll,
+		SyncTimeout: fedCfg.SyncTimeout,
+	}).Run(ctx)
+}
@@ -25,11 +25,17 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
 
 	// this depends on the kpng server to run the integrated app
+	"sigs.k8s.io/kpng/server/jobs/federation"
+	"sigs.k8s.io/kpng/server/jobs/file2store"
 	"sigs.k8s.io/kpng/server/jobs/kube2store"
+	"sigs.k8s.io/kpng/server/jobs/prober"
+	"sigs.k8s.io/kpng/server/jobs/statecache"
 	"sigs.k8s.io/kpng/server/proxystore"
 )
 
@@ -43,8 +49,18 @@ var (
 	// to in-cluster configuration using internal pod service accounts.
 	kubeServer string
 
-	kubeClient = &kubernetes.Clientset{}
-	k8sCfg     = &kube2store.K8sConfig{}
+	// staticServices is a file of services merged with the cluster's ones
+	staticServices string
+
+	kubeClient  = &kubernetes.Clientset{}
+	kubeDynamic dynamic.Interface
+	k8sCfg      = &kube2store.K8sConfig{}
+
+	// proberCfg configures the active health checking of the endpoints
+	proberCfg = &prober.Config{}
+
+	// stateCacheCfg configures the persistence of the state for fast restarts
+	stateCacheCfg = &statecache.Config{}
 )
 
 // kube2storeCmd generates the kube-to-store command, which is the "normal" way to run KPNG,
@@ -59,9 +75,12 @@ func kube2storeCmd() *cobra.Command {
 	flags := k2sCmd.PersistentFlags()
 	flags.StringVar(&kubeConfig, "kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster. Defaults to envvar KUBECONFIG.")
 	flags.StringVar(&kubeServer, "server", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+	flags.StringVar(&staticServices, "static-services", "", "File of services and endpoints to add to the cluster's ones, in the file command's input format. Cluster services win on conflicts.")
 
 	// k8sCfg is the configuration of how we interact w/ and watch the K8s APIServer
 	k8sCfg.BindFlags(k2sCmd.PersistentFlags())
+	proberCfg.BindFlags(k2sCmd.PersistentFlags())
+	stateCacheCfg.BindFlags(k2sCmd.PersistentFlags())
 
 	ctx := setupGlobal()
 	store := proxystore.New()
@@ -78,6 +97,10 @@ func kube2storeCmd() *cobra.Command {
 // kube2storeCmdSetup performs any neccessary setup steps that need to happen
 // before the kube2store job starts.
 func kube2storeCmdSetup() error {
+	if err := k8sCfg.ValidateShards(); err != nil {
+		return err
+	}
+
 	if kubeConfig == "" {
 		kubeConfig = os.Getenv("KUBECONFIG")
 	}
@@ -85,19 +108,71 @@ func kube2storeCmdSetup() error {
 	if err != nil {
 		return fmt.Errorf("Error building kubeconfig: %w", err)
 	}
+	k8sCfg.ApplyClientConfig(cfg)
 
 	kubeClient, err = kubernetes.NewForConfig(cfg)
 	if err != nil {
 		return fmt.Errorf("Error building kubernetes clientset: %w", err)
 	}
+
+	kubeDynamic, err = dynamic.NewForConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("Error building kubernetes dynamic client: %w", err)
+	}
 	return nil
 }
 
 // kube2storeCmdRun kicks off the kube2store job.
 func kube2storeCmdRun(ctx context.Context, store *proxystore.Store) {
-	kube2store.Job{
-		Kube:   kubeClient,
-		Store:  store,
-		Config: k8sCfg,
-	}.Run(ctx)
+	if proberCfg.Enabled() {
+		// health check the endpoints before they reach the store
+		probedStore := proxystore.New()
+		go (&prober.Job{Source: probedStore, Store: store, Config: proberCfg}).Run(ctx)
+		store = probedStore
+	}
+
+	job := kube2store.Job{
+		Kube:    kubeClient,
+		Dynamic: kubeDynamic,
+		Store:   store,
+		Config:  k8sCfg,
+	}
+
+	if staticServices == "" {
+		runStateCache(ctx, store)
+		job.Run(ctx)
+		return
+	}
+
+	// merge the static services with the cluster's ones
+	kubeStore, staticStore := proxystore.New(), proxystore.New()
+
+	job.Store = kubeStore
+	runStateCache(ctx, kubeStore)
+	go job.Run(ctx)
+
+	go (&file2store.Job{FilePath: staticServices, Store: staticStore}).Run(ctx)
+
+	(&federation.Job{
+		Sources: []federation.Source{
+			{Name: "kube", Store: kubeStore},
+			{Name: "static", Store: staticStore},
+		},
+		Store:     store,
+		Conflicts: federation.FirstWins,
+	}).Run(ctx)
+}
+
+// runStateCache restores the cluster's state of the previous run in the store, to serve it while
+// the informers resync, and persists the state, if enabled.
+func runStateCache(ctx context.Context, store *proxystore.Store) {
+	if !stateCacheCfg.Enabled() {
+		return
+	}
+
+	if err := statecache.Restore(store, stateCacheCfg); err != nil {
+		klog.Error("failed to restore the state cache: ", err)
+	}
+
+	go (&statecache.Job{Store: store, Config: stateCacheCfg}).Run(ctx)
 }
@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"sigs.k8s.io/kpng/server/jobs/loadgen"
+)
+
+// loadgenCmd generates synthetic service churn, either in a cluster or in a file read by `kpng file`,
+// to validate the performance of kpng at scale.
+func loadgenCmd() *cobra.Command {
+	cfg := &loadgen.Config{}
+
+	var (
+		kubeconfig string
+		server     string
+		outputFile string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "loadgen",
+		Short: "generate services and endpoints churn for scale tests",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx := setupGlobal()
+
+			job := &loadgen.Job{Config: cfg}
+
+			if outputFile != "" {
+				job.Target = &loadgen.FileTarget{Path: outputFile}
+			} else {
+				if kubeconfig == "" {
+					kubeconfig = os.Getenv("KUBECONFIG")
+				}
+				restCfg, err := clientcmd.BuildConfigFromFlags(server, kubeconfig)
+				if err != nil {
+					return fmt.Errorf("Error building kubeconfig: %w", err)
+				}
+				// the load is limited by the job, not by the client
+				restCfg.QPS, restCfg.Burst = -1, 0
+
+				client, err := kubernetes.NewForConfig(restCfg)
+				if err != nil {
+					return fmt.Errorf("Error building kubernetes clientset: %w", err)
+				}
+				job.Target = &loadgen.KubeTarget{Client: client}
+			}
+
+			return job.Run(ctx)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&kubeconfig, "kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster. Defaults to envvar KUBECONFIG.")
+	flags.StringVar(&server, "server", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+	flags.StringVarP(&outputFile, "output", "o", "", "Write the services to this file, in the input format of the file command, instead of the Kubernetes API")
+	cfg.BindFlags(flags)
+
+	return cmd
+}
@@ -54,9 +54,19 @@ func main() {
 
 	cmd.AddCommand(
 		kube2storeCmd(), // no-op?
+		federation2storeCmd(),
 		file2storeCmd(),
 		api2storeCmd(),
 		local2sinkCmd(),
+		privilegedHelperCmd(),
+		drainCmd(),
+		checkBackendCmd(),
+		ebpfCmd(),
+		userspaceCmd(),
+		explainCmd(),
+		loadgenCmd(),
+		devCmd(),
+		relayCmd(),
 		versionCmd(),
 	)
 
@@ -74,6 +84,20 @@ func setupGlobal() (ctx context.Context) {
 	if len(*exportMetrics) != 0 {
 		prometheus.MustRegister(metrics.Kpng_k8s_api_events)
 		prometheus.MustRegister(metrics.Kpng_node_local_events)
+		prometheus.MustRegister(metrics.Kpng_invalid_objects)
+		prometheus.MustRegister(metrics.Kpng_backend)
+		prometheus.MustRegister(metrics.Kpng_self_test_probes)
+		prometheus.MustRegister(metrics.Kpng_self_test_failing)
+		prometheus.MustRegister(metrics.Kpng_sync_failures)
+		prometheus.MustRegister(metrics.Kpng_sync_retries)
+		prometheus.MustRegister(metrics.Kpng_backend_panics)
+		prometheus.MustRegister(metrics.Kpng_rejected_services)
+		prometheus.MustRegister(metrics.Kpng_sync_period)
+		prometheus.MustRegister(metrics.Kpng_sync_change_rate)
+		prometheus.MustRegister(metrics.Kpng_sync_backpressure)
+		prometheus.MustRegister(metrics.Kpng_service_programming_latency)
+		prometheus.MustRegister(metrics.Kpng_priority_programming_latency)
+		prometheus.MustRegister(metrics.Kpng_initial_sync_progress)
 		klog.Infof("exporting metrics to: %v ", *exportMetrics)
 		metrics.StartMetricsServer(*exportMetrics, ctx.Done())
 	}
@@ -0,0 +1,42 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/kpng/client/privhelper"
+)
+
+// privilegedHelperCmd runs the helper performing the kernel changes for backends started
+// with --privileged-helper, so they can run without CAP_NET_ADMIN.
+func privilegedHelperCmd() *cobra.Command {
+	server := &privhelper.Server{}
+
+	cmd := &cobra.Command{
+		Use:   "privileged-helper",
+		Short: "run the kernel commands of unprivileged backends",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx := setupGlobal()
+			return server.Run(ctx)
+		},
+	}
+
+	server.BindFlags(cmd.Flags())
+
+	return cmd
+}
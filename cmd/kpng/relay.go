@@ -0,0 +1,45 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/kpng/server/jobs/relay"
+)
+
+// relayCmd proxies the local API between a server and its backends, recording the streams, or
+// replays a recorded stream to the backends.
+func relayCmd() *cobra.Command {
+	cfg := &relay.Config{}
+	job := relay.New(cfg)
+
+	cmd := &cobra.Command{
+		Use:   "relay",
+		Short: "relay the local API to the backends, recording or replaying the streams",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx := setupGlobal()
+			return job.Run(ctx)
+		},
+	}
+
+	flags := cmd.Flags()
+	job.BindFlags(flags)
+	cfg.BindFlags(flags)
+
+	return cmd
+}
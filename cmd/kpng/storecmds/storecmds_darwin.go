@@ -18,3 +18,9 @@ limitations under the License.
 
 
 package storecmds
+
+// the backends able to run against in-memory kernel fakes, for kpng dev
+import (
+	_ "sigs.k8s.io/kpng/backends/iptables"
+	_ "sigs.k8s.io/kpng/backends/nft"
+)
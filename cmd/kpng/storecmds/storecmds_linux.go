@@ -24,5 +24,6 @@ import (
 	_ "sigs.k8s.io/kpng/backends/ipvs-as-sink"
 	_ "sigs.k8s.io/kpng/backends/ipvsfullstate"
 	_ "sigs.k8s.io/kpng/backends/nft"
+	_ "sigs.k8s.io/kpng/backends/plugin"
 	_ "sigs.k8s.io/kpng/backends/userspacelin"
 )
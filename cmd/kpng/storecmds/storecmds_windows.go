@@ -20,6 +20,10 @@ limitations under the License.
 package storecmds
 
 import (
+        // for kpng dev only
+        _ "sigs.k8s.io/kpng/backends/iptables"
+        _ "sigs.k8s.io/kpng/backends/nft"
+
         _ "sigs.k8s.io/kpng/backends/windows/kernelspace"
         _ "sigs.k8s.io/kpng/backends/windows/userspace"
 )
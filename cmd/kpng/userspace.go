@@ -0,0 +1,173 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// userspaceCmd groups the commands talking to a running userspace proxy.
+func userspaceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "userspace",
+		Short: "inspect a running userspace proxy (to-userspacelin)",
+	}
+
+	cmd.AddCommand(userspaceStatusCmd())
+	cmd.AddCommand(userspaceConnsCmd())
+
+	return cmd
+}
+
+// userspaceStatusCmd prints the services, proxy ports and active connections of a running
+// userspace proxy (started with --status-socket) as JSON.
+func userspaceStatusCmd() *cobra.Command {
+	client := &userspaceClient{}
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "print the proxy ports, node ports and active connections of the services as JSON",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			body, err := client.get("/status")
+			if err != nil {
+				return err
+			}
+
+			out := &bytes.Buffer{}
+			if err := json.Indent(out, body, "", "  "); err != nil {
+				return err
+			}
+			_, err = out.WriteTo(os.Stdout)
+			return err
+		},
+	}
+
+	client.bindFlags(cmd.Flags())
+
+	return cmd
+}
+
+// userspaceConnsCmd lists the TCP connections and UDP clients proxied by a running userspace proxy.
+func userspaceConnsCmd() *cobra.Command {
+	var (
+		client  = &userspaceClient{}
+		service string
+		asJSON  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "conns",
+		Short: "list the active TCP connections and UDP clients of the services, with their endpoint, age and bytes",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			body, err := client.get("/connections?" + url.Values{"service": {service}}.Encode())
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				out := &bytes.Buffer{}
+				if err := json.Indent(out, body, "", "  "); err != nil {
+					return err
+				}
+				_, err = out.WriteTo(os.Stdout)
+				return err
+			}
+
+			conns := struct {
+				Connections []struct {
+					Service, Protocol, Client, Endpoint, Age string
+					Sent, Received                           int64
+				}
+			}{}
+			if err := json.Unmarshal(body, &conns); err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "SERVICE\tPROTOCOL\tCLIENT\tENDPOINT\tAGE\tSENT\tRECEIVED")
+			for _, c := range conns.Connections {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%d\n", c.Service, c.Protocol, c.Client, c.Endpoint, c.Age, c.Sent, c.Received)
+			}
+			return w.Flush()
+		},
+	}
+
+	flags := cmd.Flags()
+	client.bindFlags(flags)
+	flags.StringVar(&service, "service", "", "only list the connections of this service (namespace/name)")
+	flags.BoolVar(&asJSON, "json", false, "print the connections as JSON")
+
+	return cmd
+}
+
+// userspaceClient queries the status socket of a userspace proxy.
+type userspaceClient struct {
+	socket  string
+	timeout time.Duration
+}
+
+func (c *userspaceClient) bindFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&c.socket, "socket", "/run/kpng/userspace.sock", "status socket of the userspace proxy (its --status-socket)")
+	flags.DurationVar(&c.timeout, "timeout", 10*time.Second, "request timeout")
+}
+
+// get returns the body of a GET on the status socket.
+func (c *userspaceClient) get(path string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", c.socket)
+		},
+	}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://userspace"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("userspace %s: %s: %s", path, resp.Status, bytes.TrimSpace(body))
+	}
+
+	return body, nil
+}
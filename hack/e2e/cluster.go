@@ -0,0 +1,174 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// The networks of the test clusters, the same as hack/common.sh.
+const (
+	clusterCIDRv4 = "10.1.0.0/16"
+	serviceCIDRv4 = "10.2.0.0/16"
+	clusterCIDRv6 = "fd6d:706e:6701::/56"
+	serviceCIDRv6 = "fd6d:706e:6702::/112"
+)
+
+// Cluster is a kind cluster.
+type Cluster struct {
+	Name     string
+	IPFamily string
+	// Dir holds the kubeconfigs and logs of the cluster.
+	Dir string
+}
+
+// Kubeconfig is the kubeconfig to reach the cluster from the host.
+func (c *Cluster) Kubeconfig() string {
+	return filepath.Join(c.Dir, "kubeconfig")
+}
+
+// InternalKubeconfig is the kubeconfig to reach the cluster from its nodes.
+func (c *Cluster) InternalKubeconfig() string {
+	return filepath.Join(c.Dir, "kubeconfig.conf")
+}
+
+func (c *Cluster) cidrs() (clusterCIDRs, serviceCIDRs []string) {
+	switch c.IPFamily {
+	case "ipv6":
+		return []string{clusterCIDRv6}, []string{serviceCIDRv6}
+	case "dual":
+		return []string{clusterCIDRv4, clusterCIDRv6}, []string{serviceCIDRv4, serviceCIDRv6}
+	default:
+		return []string{clusterCIDRv4}, []string{serviceCIDRv4}
+	}
+}
+
+func (c *Cluster) config() string {
+	clusterCIDRs, serviceCIDRs := c.cidrs()
+
+	return fmt.Sprintf(`kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+networking:
+  ipFamily: %q
+  podSubnet: %q
+  serviceSubnet: %q
+nodes:
+- role: control-plane
+- role: worker
+- role: worker
+`, c.IPFamily, strings.Join(clusterCIDRs, ","), strings.Join(serviceCIDRs, ","))
+}
+
+func kind(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	log.Print("running kind ", strings.Join(args, " "))
+
+	cmd := exec.CommandContext(ctx, "kind", args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("kind %s failed: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// Exists returns true if the cluster is already created.
+func (c *Cluster) Exists(ctx context.Context) (bool, error) {
+	out, err := kind(ctx, nil, "get", "clusters")
+	if err != nil {
+		return false, err
+	}
+
+	for _, name := range strings.Fields(string(out)) {
+		if name == c.Name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Create creates the cluster, replacing any previous cluster with the same name.
+func (c *Cluster) Create(ctx context.Context, nodeImage string) error {
+	if exists, err := c.Exists(ctx); err != nil {
+		return err
+	} else if exists {
+		if err := c.Delete(ctx); err != nil {
+			return err
+		}
+	}
+
+	args := []string{"create", "cluster", "--name", c.Name, "--retain", "--wait=1m", "--config=-"}
+	if nodeImage != "" {
+		args = append(args, "--image", nodeImage)
+	}
+
+	if _, err := kind(ctx, []byte(c.config()), args...); err != nil {
+		return err
+	}
+
+	return c.WriteKubeconfigs(ctx)
+}
+
+// WriteKubeconfigs writes the kubeconfigs of the cluster to its directory.
+func (c *Cluster) WriteKubeconfigs(ctx context.Context) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+
+	for path, args := range map[string][]string{
+		c.Kubeconfig():         {"get", "kubeconfig", "--name", c.Name},
+		c.InternalKubeconfig(): {"get", "kubeconfig", "--internal", "--name", c.Name},
+	} {
+		out, err := kind(ctx, nil, args...)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, out, 0600); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadImage loads a local container image in the nodes of the cluster.
+func (c *Cluster) LoadImage(ctx context.Context, image string) error {
+	_, err := kind(ctx, nil, "load", "docker-image", image, "--name", c.Name)
+	return err
+}
+
+// ExportLogs exports the logs of the cluster in its directory.
+func (c *Cluster) ExportLogs(ctx context.Context) error {
+	_, err := kind(ctx, nil, "export", "logs", filepath.Join(c.Dir, "logs"), "--name", c.Name)
+	return err
+}
+
+// Delete deletes the cluster.
+func (c *Cluster) Delete(ctx context.Context) error {
+	_, err := kind(ctx, nil, "delete", "cluster", "--name", c.Name)
+	return err
+}
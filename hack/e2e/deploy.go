@@ -0,0 +1,216 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// The kpng deployment, the same as hack/test_e2e.sh.
+const (
+	namespace          = "kube-system"
+	configMapName      = "kpng"
+	serviceAccountName = "kpng"
+	clusterRoleName    = "system:node-proxier"
+	serverAddress      = "unix:///k8s/proxy.sock"
+
+	dsTemplatePath = "../kpng-deployment-ds-template.txt"
+)
+
+// Deployment describes how kpng is deployed.
+type Deployment struct {
+	Image   string
+	Backend string
+	// SingleProcess runs the kube watcher and the backend in the same process.
+	SingleProcess bool
+	// BackendArgs are added to the backend command.
+	BackendArgs []string
+	LogLevel    int
+}
+
+// dsTemplateData matches hack/kpng-ds-yaml-gen.go.
+type dsTemplateData struct {
+	Namespace          string
+	ServiceAccountName string
+	ImagePullPolicy    string
+	IsEbpfBackend      bool
+	KpngImage          string
+	Backend            string
+	E2eBackendArgs     string
+	E2eServerArgs      string
+	ConfigMapName      string
+	Deployment_model   string
+}
+
+func yamlList(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, "'"+arg+"'")
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+func (d *Deployment) daemonSet(cluster *Cluster) (*appsv1.DaemonSet, error) {
+	kubeconfig := "--kubeconfig=/var/lib/kpng/kubeconfig.conf"
+	verbosity := fmt.Sprintf("--v=%d", d.LogLevel)
+
+	serverArgs := []string{"kube", kubeconfig, "to-api", "--listen=" + serverAddress}
+	backendArgs := []string{"local", "--api=" + serverAddress, "to-" + d.Backend, verbosity}
+	model := "split-process-per-node"
+
+	if d.SingleProcess {
+		backendArgs = []string{"kube", kubeconfig, "to-local", "to-" + d.Backend, verbosity}
+		model = "single-process-per-node"
+	}
+
+	if d.Backend == "nft" {
+		clusterCIDRs, _ := cluster.cidrs()
+		for _, cidr := range clusterCIDRs {
+			backendArgs = append(backendArgs, "--cluster-cidrs="+cidr)
+		}
+	}
+
+	backendArgs = append(backendArgs, d.BackendArgs...)
+
+	tmpl, err := template.ParseFiles(dsTemplatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	err = tmpl.Execute(buf, dsTemplateData{
+		Namespace:          namespace,
+		ServiceAccountName: serviceAccountName,
+		ImagePullPolicy:    "IfNotPresent",
+		IsEbpfBackend:      d.Backend == "ebpf",
+		KpngImage:          d.Image,
+		Backend:            d.Backend,
+		E2eBackendArgs:     yamlList(backendArgs),
+		E2eServerArgs:      yamlList(serverArgs),
+		ConfigMapName:      configMapName,
+		Deployment_model:   model,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ds := &appsv1.DaemonSet{}
+	if err := yaml.UnmarshalStrict(buf.Bytes(), ds); err != nil {
+		return nil, fmt.Errorf("invalid kpng daemonset: %w", err)
+	}
+	return ds, nil
+}
+
+func ignoreExists(err error) error {
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+// Deploy replaces kube-proxy with kpng in the cluster, and waits for kpng to be running on all nodes.
+func (d *Deployment) Deploy(ctx context.Context, client kubernetes.Interface, cluster *Cluster) error {
+	err := client.AppsV1().DaemonSets(namespace).Delete(ctx, "kube-proxy", metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	_, err = client.CoreV1().ServiceAccounts(namespace).Create(ctx, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: serviceAccountName},
+	}, metav1.CreateOptions{})
+	if err = ignoreExists(err); err != nil {
+		return err
+	}
+
+	_, err = client.RbacV1().ClusterRoleBindings().Create(ctx, &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "kpng"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: clusterRoleName},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Namespace: namespace, Name: serviceAccountName}},
+	}, metav1.CreateOptions{})
+	if err = ignoreExists(err); err != nil {
+		return err
+	}
+
+	kubeconfig, err := os.ReadFile(cluster.InternalKubeconfig())
+	if err != nil {
+		return err
+	}
+
+	_, err = client.CoreV1().ConfigMaps(namespace).Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: configMapName},
+		Data:       map[string]string{"kubeconfig.conf": string(kubeconfig)},
+	}, metav1.CreateOptions{})
+	if err = ignoreExists(err); err != nil {
+		return err
+	}
+
+	ds, err := d.daemonSet(cluster)
+	if err != nil {
+		return err
+	}
+
+	err = client.AppsV1().DaemonSets(namespace).Delete(ctx, ds.Name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	// wait for the previous pods to be gone
+	err = wait.PollImmediateWithContext(ctx, time.Second, time.Minute, func(ctx context.Context) (bool, error) {
+		pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: "app=kpng"})
+		if err != nil {
+			return false, err
+		}
+		return len(pods.Items) == 0, nil
+	})
+	if err != nil {
+		return fmt.Errorf("previous kpng pods still running: %w", err)
+	}
+
+	if _, err := client.AppsV1().DaemonSets(namespace).Create(ctx, ds, metav1.CreateOptions{}); err != nil {
+		return err
+	}
+
+	log.Printf("waiting for kpng (%s backend) to be running", d.Backend)
+
+	return wait.PollImmediateWithContext(ctx, 2*time.Second, 3*time.Minute, func(ctx context.Context) (bool, error) {
+		ds, err := client.AppsV1().DaemonSets(namespace).Get(ctx, ds.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		status := ds.Status
+		return status.DesiredNumberScheduled != 0 &&
+			status.ObservedGeneration >= ds.Generation &&
+			status.UpdatedNumberScheduled == status.DesiredNumberScheduled &&
+			status.NumberAvailable == status.DesiredNumberScheduled, nil
+	})
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDaemonSet(t *testing.T) {
+	d := &Deployment{Image: "kpng:test", Backend: "nft", LogLevel: 2}
+
+	ds, err := d.daemonSet(&Cluster{IPFamily: "dual"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	containers := ds.Spec.Template.Spec.Containers
+	if len(containers) != 2 {
+		t.Fatalf("expected the server and backend containers, got %d", len(containers))
+	}
+
+	backend := containers[1]
+	if backend.Name != "kpng-nft" || backend.Image != "kpng:test" {
+		t.Errorf("unexpected backend container: %s (%s)", backend.Name, backend.Image)
+	}
+
+	expected := []string{"local", "--api=unix:///k8s/proxy.sock", "to-nft", "--v=2",
+		"--cluster-cidrs=" + clusterCIDRv4, "--cluster-cidrs=" + clusterCIDRv6}
+	if !reflect.DeepEqual(backend.Args, expected) {
+		t.Errorf("unexpected backend args: %q", backend.Args)
+	}
+}
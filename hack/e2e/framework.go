@@ -0,0 +1,298 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AgnhostImage serves the backends and runs the clients of the tests.
+const AgnhostImage = "registry.k8s.io/e2e-test-images/agnhost:2.40"
+
+// The ports of the backends.
+const (
+	httpPort = 8080
+	udpPort  = 8081
+	sctpPort = 8082
+)
+
+// Framework runs the tests in their own namespace.
+type Framework struct {
+	Client    kubernetes.Interface
+	Namespace string
+
+	ctx context.Context
+	t   *testing.T
+}
+
+// NewFramework creates the namespace of the test, deleted when the test is done.
+func NewFramework(ctx context.Context, t *testing.T, client kubernetes.Interface) *Framework {
+	t.Helper()
+
+	ns, err := client.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "kpng-e2e-"},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("failed to create the test namespace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		err := client.CoreV1().Namespaces().Delete(context.Background(), ns.Name, metav1.DeleteOptions{})
+		if err != nil {
+			t.Logf("failed to delete namespace %s: %v", ns.Name, err)
+		}
+	})
+
+	return &Framework{
+		Client:    client,
+		Namespace: ns.Name,
+		ctx:       ctx,
+		t:         t,
+	}
+}
+
+// Nodes returns the schedulable nodes of the cluster.
+func (f *Framework) Nodes() []corev1.Node {
+	f.t.Helper()
+
+	nodes, err := f.Client.CoreV1().Nodes().List(f.ctx, metav1.ListOptions{})
+	if err != nil {
+		f.t.Fatalf("failed to list nodes: %v", err)
+	}
+
+	ready := make([]corev1.Node, 0, len(nodes.Items))
+	for _, node := range nodes.Items {
+		if !node.Spec.Unschedulable && len(node.Spec.Taints) == 0 {
+			ready = append(ready, node)
+		}
+	}
+
+	if len(ready) < 2 {
+		f.t.Fatalf("need at least 2 schedulable nodes, got %d", len(ready))
+	}
+	return ready
+}
+
+// NodeIP returns the first internal IP of the node matching the family of the cluster.
+func NodeIP(node corev1.Node, ipv6 bool) string {
+	for _, addr := range node.Status.Addresses {
+		if addr.Type != corev1.NodeInternalIP {
+			continue
+		}
+		if ip := net.ParseIP(addr.Address); ip != nil && (ip.To4() == nil) == ipv6 {
+			return addr.Address
+		}
+	}
+	return ""
+}
+
+// Backends creates a deployment of netexec pods labeled app=name, optionally pinned to a node, and
+// returns the names of its pods once they are ready.
+func (f *Framework) Backends(name string, replicas int32, nodeName string) []string {
+	f.t.Helper()
+
+	labels := map[string]string{"app": name}
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					NodeName: nodeName,
+					Containers: []corev1.Container{{
+						Name:  "netexec",
+						Image: AgnhostImage,
+						Args: []string{"netexec",
+							"--http-port=" + strconv.Itoa(httpPort),
+							"--udp-port=" + strconv.Itoa(udpPort),
+							"--sctp-port=" + strconv.Itoa(sctpPort),
+						},
+						Ports: []corev1.ContainerPort{
+							{Name: "http", ContainerPort: httpPort, Protocol: corev1.ProtocolTCP},
+							{Name: "udp", ContainerPort: udpPort, Protocol: corev1.ProtocolUDP},
+							{Name: "sctp", ContainerPort: sctpPort, Protocol: corev1.ProtocolSCTP},
+						},
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{
+								HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromInt(httpPort)},
+							},
+							PeriodSeconds: 1,
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	_, err := f.Client.AppsV1().Deployments(f.Namespace).Create(f.ctx, deploy, metav1.CreateOptions{})
+	if err != nil {
+		f.t.Fatalf("failed to create deployment %s: %v", name, err)
+	}
+
+	var podNames []string
+	err = wait.PollImmediateWithContext(f.ctx, time.Second, 2*time.Minute, func(ctx context.Context) (bool, error) {
+		pods, err := f.Client.CoreV1().Pods(f.Namespace).List(ctx, metav1.ListOptions{LabelSelector: "app=" + name})
+		if err != nil {
+			return false, err
+		}
+
+		podNames = podNames[:0]
+		for _, pod := range pods.Items {
+			if pod.DeletionTimestamp == nil && podReady(&pod) {
+				podNames = append(podNames, pod.Name)
+			}
+		}
+		return len(podNames) == int(replicas), nil
+	})
+	if err != nil {
+		f.t.Fatalf("backends %s not ready: %v", name, err)
+	}
+
+	return podNames
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// Service creates the service, with a selector on app=name.
+func (f *Framework) Service(name string, spec corev1.ServiceSpec) *corev1.Service {
+	f.t.Helper()
+
+	spec.Selector = map[string]string{"app": name}
+
+	svc, err := f.Client.CoreV1().Services(f.Namespace).Create(f.ctx, &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       spec,
+	}, metav1.CreateOptions{})
+	if err != nil {
+		f.t.Fatalf("failed to create service %s: %v", name, err)
+	}
+	return svc
+}
+
+// Client describes a client pod connecting to a service.
+type Client struct {
+	// NodeName pins the client to a node.
+	NodeName string
+	// HostNetwork runs the client in the network namespace of its node.
+	HostNetwork bool
+}
+
+// Hostnames connects count times to the address with the protocol, and returns the hostnames of the
+// backends which answered (an empty string for each failed connection).
+//
+// SCTP backends don't return their hostname, so successful SCTP connections return "ok".
+func (f *Framework) Hostnames(c Client, protocol corev1.Protocol, host string, port int32, count int) []string {
+	f.t.Helper()
+
+	addr := net.JoinHostPort(host, strconv.Itoa(int(port)))
+
+	var probe string
+	switch protocol {
+	case corev1.ProtocolTCP:
+		probe = "curl -g -q -s --max-time 2 http://" + addr + "/hostname"
+	case corev1.ProtocolUDP:
+		probe = fmt.Sprintf("echo hostname | nc -u -w 2 %s %d", host, port)
+	case corev1.ProtocolSCTP:
+		probe = "/agnhost connect --protocol=sctp --timeout=2s " + addr + " && echo -n ok"
+	default:
+		f.t.Fatalf("unknown protocol %s", protocol)
+	}
+
+	script := fmt.Sprintf("for i in $(seq %d); do %s; echo; done", count, probe)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "client-"},
+		Spec: corev1.PodSpec{
+			NodeName:      c.NodeName,
+			HostNetwork:   c.HostNetwork,
+			RestartPolicy: corev1.RestartPolicyNever,
+			Tolerations:   []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+			Containers: []corev1.Container{{
+				Name:    "client",
+				Image:   AgnhostImage,
+				Command: []string{"sh", "-c", script},
+			}},
+		},
+	}
+
+	pod, err := f.Client.CoreV1().Pods(f.Namespace).Create(f.ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		f.t.Fatalf("failed to create client pod: %v", err)
+	}
+	defer f.Client.CoreV1().Pods(f.Namespace).Delete(context.Background(), pod.Name, metav1.DeleteOptions{})
+
+	err = wait.PollImmediateWithContext(f.ctx, time.Second, 2*time.Minute, func(ctx context.Context) (bool, error) {
+		pod, err = f.Client.CoreV1().Pods(f.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed, nil
+	})
+	if err != nil {
+		f.t.Fatalf("client pod %s didn't complete: %v", pod.Name, err)
+	}
+
+	logs, err := f.Client.CoreV1().Pods(f.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{}).DoRaw(f.ctx)
+	if err != nil {
+		f.t.Fatalf("failed to get the logs of client pod %s: %v", pod.Name, err)
+	}
+
+	hostnames := strings.Split(strings.TrimSuffix(string(logs), "\n"), "\n")
+	f.t.Logf("%s %s from %s: %q", protocol, addr, pod.Spec.NodeName, hostnames)
+	return hostnames
+}
+
+// Eventually retries fn until it returns true, failing the test after the timeout. Services take some
+// time to be programmed by the backends.
+func (f *Framework) Eventually(timeout time.Duration, fn func() bool) {
+	f.t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for !fn() {
+		if time.Now().After(deadline) {
+			f.t.Fatalf("condition not met after %v", timeout)
+		}
+		select {
+		case <-f.ctx.Done():
+			f.t.Fatalf("interrupted: %v", f.ctx.Err())
+		case <-time.After(time.Second):
+		}
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"encoding/xml"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+type junitTestSuite struct {
+	XMLName  xml.Name        `xml:"testsuite"`
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     float64         `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name    string        `xml:"name,attr"`
+	Time    float64       `xml:"time,attr"`
+	Failure *junitMessage `xml:"failure,omitempty"`
+	Skipped *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// Reporter collects the results of the tests in a JUnit report.
+type Reporter struct {
+	mu    sync.Mutex
+	suite junitTestSuite
+}
+
+func NewReporter(name string) *Reporter {
+	return &Reporter{suite: junitTestSuite{Name: name}}
+}
+
+// Track records the result of the test when it's done.
+func (r *Reporter) Track(t *testing.T) {
+	start := time.Now()
+
+	t.Cleanup(func() {
+		tc := junitTestCase{
+			Name: t.Name(),
+			Time: time.Since(start).Seconds(),
+		}
+
+		switch {
+		case t.Failed():
+			tc.Failure = &junitMessage{Message: "test failed, see the test output"}
+		case t.Skipped():
+			tc.Skipped = &junitMessage{Message: "test skipped"}
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.suite.Cases = append(r.suite.Cases, tc)
+		r.suite.Tests++
+		r.suite.Time += tc.Time
+		if tc.Failure != nil {
+			r.suite.Failures++
+		}
+		if tc.Skipped != nil {
+			r.suite.Skipped++
+		}
+	})
+}
+
+// WriteFile writes the JUnit report.
+func (r *Reporter) WriteFile(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out, err := xml.MarshalIndent(r.suite, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append([]byte(xml.Header), out...), 0644)
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var (
+	backend       = flag.String("backend", "", "kpng backend to test (iptables, ipvs, nft, ebpf, userspacelin...); the tests are skipped if empty")
+	ipFamily      = flag.String("ip-family", "ipv4", "IP family of the cluster (ipv4, ipv6 or dual)")
+	image         = flag.String("image", "kpng:test", "kpng image, loaded in the cluster (see make build)")
+	nodeImage     = flag.String("node-image", "docker.io/kindest/node:v1.25.3", "kind node image")
+	singleProcess = flag.Bool("single-process", false, "run kpng in a single process per node")
+	keepCluster   = flag.Bool("keep-cluster", false, "keep the cluster after the tests")
+	reuseCluster  = flag.Bool("reuse-cluster", false, "use the existing cluster with the same name instead of creating it (kpng is redeployed)")
+	artifacts     = flag.String("artifacts", "", "directory for the kubeconfigs, logs and JUnit report (default: a temporary directory)")
+)
+
+var (
+	cluster  *Cluster
+	client   kubernetes.Interface
+	reporter *Reporter
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	if *backend == "" {
+		os.Exit(m.Run())
+	}
+
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	name := "kpng-e2e-" + *ipFamily + "-" + *backend
+
+	dir := *artifacts
+	if dir == "" {
+		tmp, err := os.MkdirTemp("", name)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		dir = tmp
+	}
+
+	cluster = &Cluster{
+		Name:     name,
+		IPFamily: *ipFamily,
+		Dir:      filepath.Join(dir, name),
+	}
+
+	log.Printf("testing %s on %s (artifacts in %s)", *backend, cluster.Name, cluster.Dir)
+
+	var err error
+	if *reuseCluster {
+		err = cluster.WriteKubeconfigs(ctx)
+	} else {
+		err = cluster.Create(ctx, *nodeImage)
+	}
+	if err != nil {
+		log.Print("failed to setup the cluster: ", err)
+		return 1
+	}
+
+	if !*keepCluster && !*reuseCluster {
+		defer func() {
+			if err := cluster.Delete(context.Background()); err != nil {
+				log.Print("failed to delete the cluster: ", err)
+			}
+		}()
+	}
+
+	if err := cluster.LoadImage(ctx, *image); err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", cluster.Kubeconfig())
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	client, err = kubernetes.NewForConfig(config)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	deployment := &Deployment{
+		Image:         *image,
+		Backend:       *backend,
+		SingleProcess: *singleProcess,
+		LogLevel:      4,
+	}
+
+	if err := deployment.Deploy(ctx, client, cluster); err != nil {
+		log.Print("failed to deploy kpng: ", err)
+		cluster.ExportLogs(context.Background())
+		return 1
+	}
+
+	reporter = NewReporter(cluster.Name)
+
+	code := m.Run()
+
+	if err := reporter.WriteFile(filepath.Join(cluster.Dir, "junit.xml")); err != nil {
+		log.Print("failed to write the JUnit report: ", err)
+	}
+
+	if err := cluster.ExportLogs(context.Background()); err != nil {
+		log.Print("failed to export the logs: ", err)
+	}
+
+	return code
+}
+
+// setup skips the test if no backend is selected, and returns the framework of the test otherwise.
+func setup(t *testing.T) *Framework {
+	t.Helper()
+
+	if client == nil {
+		t.Skip("no backend selected (use -args -backend=<backend>)")
+	}
+
+	reporter.Track(t)
+	t.Parallel()
+
+	return NewFramework(context.Background(), t, client)
+}
+
+// skipBackends skips the test for the backends not supporting it yet.
+func skipBackends(t *testing.T, backends ...string) {
+	t.Helper()
+
+	for _, b := range backends {
+		if b == *backend {
+			t.Skipf("not supported by the %s backend", b)
+		}
+	}
+}
+
+func ipv6() bool {
+	return *ipFamily == "ipv6"
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const programTimeout = time.Minute
+
+// allFrom returns true if all the hostnames are backends, and all the backends answered.
+func allFrom(hostnames, backends []string) bool {
+	seen := map[string]bool{}
+	for _, h := range hostnames {
+		if !contains(backends, h) {
+			return false
+		}
+		seen[h] = true
+	}
+	return len(seen) == len(backends)
+}
+
+// noneAnswered returns true if all the connections failed.
+func noneAnswered(hostnames []string) bool {
+	for _, h := range hostnames {
+		if h != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func servicePort(protocol corev1.Protocol, port, targetPort int) []corev1.ServicePort {
+	return []corev1.ServicePort{{
+		Protocol:   protocol,
+		Port:       int32(port),
+		TargetPort: intstr.FromInt(targetPort),
+	}}
+}
+
+func TestClusterIP(t *testing.T) {
+	f := setup(t)
+
+	backends := f.Backends("clusterip", 2, "")
+	svc := f.Service("clusterip", corev1.ServiceSpec{
+		Ports: servicePort(corev1.ProtocolTCP, 80, httpPort),
+	})
+
+	f.Eventually(programTimeout, func() bool {
+		return allFrom(f.Hostnames(Client{}, corev1.ProtocolTCP, svc.Spec.ClusterIP, 80, 20), backends)
+	})
+}
+
+func TestNodePort(t *testing.T) {
+	f := setup(t)
+
+	nodes := f.Nodes()
+	backends := f.Backends("nodeport", 1, nodes[0].Name)
+	svc := f.Service("nodeport", corev1.ServiceSpec{
+		Type:  corev1.ServiceTypeNodePort,
+		Ports: servicePort(corev1.ProtocolTCP, 80, httpPort),
+	})
+	nodePort := svc.Spec.Ports[0].NodePort
+
+	// the node port must be reachable on the nodes without local backend too
+	for _, node := range nodes {
+		nodeIP := NodeIP(node, ipv6())
+		client := Client{NodeName: nodes[0].Name, HostNetwork: true}
+
+		f.Eventually(programTimeout, func() bool {
+			return allFrom(f.Hostnames(client, corev1.ProtocolTCP, nodeIP, nodePort, 5), backends)
+		})
+	}
+}
+
+func TestExternalTrafficPolicyLocal(t *testing.T) {
+	f := setup(t)
+
+	nodes := f.Nodes()
+	backends := f.Backends("etp-local", 1, nodes[0].Name)
+	svc := f.Service("etp-local", corev1.ServiceSpec{
+		Type:                  corev1.ServiceTypeNodePort,
+		ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal,
+		Ports:                 servicePort(corev1.ProtocolTCP, 80, httpPort),
+	})
+	nodePort := svc.Spec.Ports[0].NodePort
+
+	// connect from the other node so the traffic is external to the node
+	client := Client{NodeName: nodes[1].Name, HostNetwork: true}
+
+	withBackend := NodeIP(nodes[0], ipv6())
+	f.Eventually(programTimeout, func() bool {
+		return allFrom(f.Hostnames(client, corev1.ProtocolTCP, withBackend, nodePort, 5), backends)
+	})
+
+	withoutBackend := NodeIP(nodes[1], ipv6())
+	if hostnames := f.Hostnames(client, corev1.ProtocolTCP, withoutBackend, nodePort, 3); !noneAnswered(hostnames) {
+		t.Errorf("node %s has no local backend but answered: %q", nodes[1].Name, hostnames)
+	}
+}
+
+func TestUDP(t *testing.T) {
+	f := setup(t)
+
+	backends := f.Backends("udp", 2, "")
+	svc := f.Service("udp", corev1.ServiceSpec{
+		Ports: servicePort(corev1.ProtocolUDP, 81, udpPort),
+	})
+
+	f.Eventually(programTimeout, func() bool {
+		return allFrom(f.Hostnames(Client{}, corev1.ProtocolUDP, svc.Spec.ClusterIP, 81, 20), backends)
+	})
+}
+
+func TestSCTP(t *testing.T) {
+	f := setup(t)
+	skipBackends(t, "ebpf", "userspacelin")
+
+	f.Backends("sctp", 1, "")
+	svc := f.Service("sctp", corev1.ServiceSpec{
+		Ports: servicePort(corev1.ProtocolSCTP, 82, sctpPort),
+	})
+
+	f.Eventually(programTimeout, func() bool {
+		return allFrom(f.Hostnames(Client{}, corev1.ProtocolSCTP, svc.Spec.ClusterIP, 82, 3), []string{"ok"})
+	})
+}
+
+func TestSessionAffinity(t *testing.T) {
+	f := setup(t)
+	skipBackends(t, "ebpf")
+
+	backends := f.Backends("affinity", 3, "")
+	svc := f.Service("affinity", corev1.ServiceSpec{
+		SessionAffinity: corev1.ServiceAffinityClientIP,
+		Ports:           servicePort(corev1.ProtocolTCP, 80, httpPort),
+	})
+
+	f.Eventually(programTimeout, func() bool {
+		hostnames := f.Hostnames(Client{}, corev1.ProtocolTCP, svc.Spec.ClusterIP, 80, 10)
+		if noneAnswered(hostnames) {
+			return false
+		}
+
+		// all the connections of the client must go to the same backend
+		return contains(backends, hostnames[0]) && allFrom(hostnames, hostnames[:1])
+	})
+}
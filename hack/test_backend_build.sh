@@ -45,6 +45,7 @@ case $package in
   "ipvsfullstate") build_package backends/ipvsfullstate ;;
   "nft")           build_package backends/nft ;;
   "ebpf")          build_package backends/ebpf ;;
+  "plugin")        build_package backends/plugin ;;
   "userspacelin")  build_package backends/userspacelin;;
   "")         build_all_backends ;;
   *)          echo "invalid argument: '$package'" ;;
@@ -18,7 +18,6 @@ package api2local
 
 import (
 	"context"
-	"time"
 
 	"github.com/spf13/pflag"
 	"google.golang.org/grpc"
@@ -75,7 +74,7 @@ func (j *Job) Run(ctx context.Context) {
 		}
 
 		klog.Error("local watch error: ", err)
-		time.Sleep(5 * time.Second) // TODO parameter?
+		j.WaitAfterError()
 	}
 }
 
@@ -116,7 +115,9 @@ func (j *Job) runLoop(watch localv1.Sets_WatchClient) (err error) {
 	}
 
 	err = watch.Send(&localv1.WatchReq{
-		NodeName: nodeName,
+		NodeName:                 nodeName,
+		IPFamilies:               localsink.IPFamilies(j.Sink),
+		WithTerminatingEndpoints: localsink.WithTerminatingEndpoints(j.Sink),
 	})
 	if err != nil {
 		return
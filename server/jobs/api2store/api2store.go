@@ -18,7 +18,6 @@ package api2store
 
 import (
 	"context"
-	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -49,7 +48,7 @@ func (j *Job) Run(ctx context.Context) {
 		}
 
 		klog.Error("watch error: ", err)
-		time.Sleep(5 * time.Second) // TODO parameter?
+		j.WaitAfterError()
 	}
 }
 
@@ -0,0 +1,290 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package federation merges the global state of several sources (clusters or
+// upstream kpng servers), each filling its own store, into a single store.
+package federation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+// ConflictPolicy tells how services defined by more than one source are merged.
+type ConflictPolicy string
+
+const (
+	// FirstWins keeps the service and the endpoints of the first source defining it.
+	FirstWins ConflictPolicy = "first-wins"
+	// MergeEndpoints keeps the service of the first source defining it, with
+	// the endpoints of every source defining it (ie: a multi-cluster service).
+	MergeEndpoints ConflictPolicy = "merge-endpoints"
+)
+
+type Config struct {
+	Conflicts   string
+	SyncAll     bool
+	SyncTimeout time.Duration
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&c.Conflicts, "conflicts", string(FirstWins),
+		fmt.Sprintf("how to merge services defined by more than one source (%s or %s)", FirstWins, MergeEndpoints))
+	flags.BoolVar(&c.SyncAll, "sync-all-sources", false, "wait for every source to be synced before serving the merged state (ie: when the sources are the shards of a cluster)")
+	flags.DurationVar(&c.SyncTimeout, "sync-timeout", time.Minute, "how long to wait for the first source to be synced before serving the other synced ones (0 to wait forever, ignored with --sync-all-sources)")
+}
+
+func (c *Config) ConflictPolicy() (ConflictPolicy, error) {
+	switch p := ConflictPolicy(c.Conflicts); p {
+	case FirstWins, MergeEndpoints:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown conflict policy: %q", c.Conflicts)
+	}
+}
+
+// Source is a store to merge.
+type Source struct {
+	// Name identifies the source. It's used to keep the endpoints of different sources apart.
+	Name  string
+	Store *proxystore.Store
+}
+
+// Job merges the Sources, ordered by priority, into the Store.
+//
+// The merged state is marked as synced once the first source is synced. The
+// other sources are included as they get synced, so an unreachable secondary
+// cluster doesn't prevent the local one from being programmed. If the first
+// source is still not synced after SyncTimeout (unless it's 0), the merged state
+// is marked as synced once any source is synced.
+//
+// With SyncAll, the merged state is marked as synced once all the sources are
+// synced instead, as needed when each source only has a part of the state
+// (ie: the namespace shards of a cluster).
+type Job struct {
+	Sources     []Source
+	Store       *proxystore.Store
+	Conflicts   ConflictPolicy
+	SyncAll     bool
+	SyncTimeout time.Duration
+}
+
+type snapshot struct {
+	synced bool
+	kvs    []*proxystore.KV
+}
+
+func (j *Job) Run(ctx context.Context) {
+	defer j.Store.Close()
+
+	var (
+		mu        sync.Mutex
+		snapshots = make([]*snapshot, len(j.Sources))
+		changed   = make(chan struct{}, 1)
+	)
+
+	for i, src := range j.Sources {
+		go func(i int, src Source) {
+			var (
+				rev    uint64
+				closed bool
+			)
+
+			for {
+				var snap *snapshot
+				rev, closed = src.Store.ViewContext(ctx, rev, func(tx *proxystore.Tx) {
+					snap = takeSnapshot(tx)
+				})
+
+				if closed {
+					return
+				}
+
+				mu.Lock()
+				snapshots[i] = snap
+				mu.Unlock()
+
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}(i, src)
+	}
+
+	var timeout <-chan time.Time
+	if j.SyncTimeout != 0 && !j.SyncAll {
+		timer := time.NewTimer(j.SyncTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	timedOut := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		case <-timeout:
+			timedOut = true
+		}
+
+		mu.Lock()
+		snaps := append([]*snapshot(nil), snapshots...)
+		mu.Unlock()
+
+		j.update(snaps, timedOut)
+	}
+}
+
+func takeSnapshot(tx *proxystore.Tx) *snapshot {
+	snap := &snapshot{synced: tx.AllSynced()}
+
+	for _, set := range proxystore.AllSets {
+		tx.Each(set, func(kv *proxystore.KV) bool {
+			snap.kvs = append(snap.kvs, kv)
+			return true
+		})
+	}
+
+	return snap
+}
+
+// merge computes the merged entries from the sources' snapshots. Once timedOut, any synced source
+// makes the merged state synced.
+func (j *Job) merge(snaps []*snapshot, timedOut bool) (merged []*proxystore.KV, synced bool) {
+	usable := func(i int) bool {
+		return snaps[i] != nil && snaps[i].synced
+	}
+
+	synced = len(snaps) != 0 && usable(0)
+	if timedOut && !j.SyncAll {
+		for i := range snaps {
+			if usable(i) {
+				synced = true
+				break
+			}
+		}
+	}
+	if j.SyncAll {
+		for i := range snaps {
+			if !usable(i) {
+				synced = false
+				break
+			}
+		}
+	}
+
+	// services and nodes: first source wins
+	svcOwner := map[string]int{}
+	nodeSeen := map[string]bool{}
+
+	for i, snap := range snaps {
+		if !usable(i) {
+			continue
+		}
+
+		for _, kv := range snap.kvs {
+			switch kv.Set {
+			case proxystore.Services:
+				key := kv.Namespace + "/" + kv.Name
+				if _, exists := svcOwner[key]; exists {
+					continue
+				}
+				svcOwner[key] = i
+				merged = append(merged, kv)
+
+			case proxystore.Nodes:
+				if nodeSeen[kv.Name] {
+					continue
+				}
+				nodeSeen[kv.Name] = true
+				merged = append(merged, kv)
+			}
+		}
+	}
+
+	// endpoints: from the owner only, or from all sources, depending on the policy
+	for i, snap := range snaps {
+		if !usable(i) {
+			continue
+		}
+
+		for _, kv := range snap.kvs {
+			if kv.Set != proxystore.Endpoints {
+				continue
+			}
+
+			owner, exists := svcOwner[kv.Namespace+"/"+kv.Endpoint.ServiceName]
+			if !exists {
+				continue
+			}
+			if owner != i && j.Conflicts != MergeEndpoints {
+				continue
+			}
+
+			epKV := *kv
+			epKV.Source = j.Sources[i].Name + "/" + kv.Source
+			merged = append(merged, &epKV)
+		}
+	}
+
+	return
+}
+
+func (j *Job) update(snaps []*snapshot, timedOut bool) {
+	merged, synced := j.merge(snaps, timedOut)
+
+	j.Store.Update(func(tx *proxystore.Tx) {
+		desired := map[proxystore.Set]map[string]bool{}
+		for _, set := range proxystore.AllSets {
+			desired[set] = map[string]bool{}
+		}
+
+		for _, kv := range merged {
+			path := kv.Path()
+			desired[kv.Set][path] = true
+			tx.SetRaw(kv.Set, path, kv.Value)
+		}
+
+		for _, set := range proxystore.AllSets {
+			toDel := make([]string, 0)
+			tx.Each(set, func(kv *proxystore.KV) bool {
+				if path := kv.Path(); !desired[set][path] {
+					toDel = append(toDel, path)
+				}
+				return true
+			})
+
+			for _, path := range toDel {
+				tx.DelRaw(set, path)
+			}
+		}
+
+		if synced {
+			for _, set := range proxystore.AllSets {
+				tx.SetSync(set)
+			}
+		}
+	})
+}
@@ -0,0 +1,316 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package federation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/server/jobs/file2store"
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+func clusterStore(synced bool, clusterIP string, svcNames []string, epIPs ...string) *proxystore.Store {
+	s := proxystore.New()
+	s.Update(func(tx *proxystore.Tx) {
+		for _, name := range svcNames {
+			tx.SetService(&localv1.Service{Namespace: "default", Name: name, IPs: &localv1.ServiceIPs{
+				ClusterIPs: &localv1.IPSet{V4: []string{clusterIP}},
+			}})
+
+			eis := make([]*globalv1.EndpointInfo, 0, len(epIPs))
+			for _, ip := range epIPs {
+				eis = append(eis, &globalv1.EndpointInfo{
+					Namespace:   "default",
+					SourceName:  name + "-abcde",
+					ServiceName: name,
+					Endpoint:    &localv1.Endpoint{IPs: &localv1.IPSet{V4: []string{ip}}},
+				})
+			}
+			tx.SetEndpointsOfSource("default", name+"-abcde", eis)
+		}
+
+		if synced {
+			for _, set := range proxystore.AllSets {
+				tx.SetSync(set)
+			}
+		}
+	})
+	return s
+}
+
+func runOnce(j *Job) {
+	runOnceTimedOut(j, false)
+}
+
+func runOnceTimedOut(j *Job, timedOut bool) {
+	snaps := make([]*snapshot, len(j.Sources))
+	for i, src := range j.Sources {
+		src.Store.View(0, func(tx *proxystore.Tx) {
+			snaps[i] = takeSnapshot(tx)
+		})
+	}
+	j.update(snaps, timedOut)
+}
+
+// state returns the services' cluster IPs and endpoints of the merged store, as "svc=ip:ep,ep"
+func state(s *proxystore.Store) (synced bool, result []string) {
+	// not View, as it blocks while the store is still at rev 0
+	s.Update(func(tx *proxystore.Tx) {
+		synced = tx.AllSynced()
+		tx.Each(proxystore.Services, func(kv *proxystore.KV) bool {
+			eps := make([]string, 0)
+			tx.EachEndpointOfService(kv.Namespace, kv.Name, func(ei *globalv1.EndpointInfo) {
+				eps = append(eps, ei.Endpoint.IPs.V4[0])
+			})
+			sort.Strings(eps)
+
+			result = append(result, kv.Name+"="+kv.Service.Service.IPs.ClusterIPs.V4[0]+":"+strings.Join(eps, ","))
+			return true
+		})
+	})
+	return
+}
+
+func TestConflicts(t *testing.T) {
+	for _, tc := range []struct {
+		policy   ConflictPolicy
+		expected string
+	}{
+		{FirstWins, "shared=10.96.0.1:10.1.0.1 svc-a=10.96.0.1:10.1.0.1 svc-b=10.97.0.1:10.2.0.1"},
+		{MergeEndpoints, "shared=10.96.0.1:10.1.0.1,10.2.0.1 svc-a=10.96.0.1:10.1.0.1 svc-b=10.97.0.1:10.2.0.1"},
+	} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			j := &Job{
+				Sources: []Source{
+					{Name: "a", Store: clusterStore(true, "10.96.0.1", []string{"shared", "svc-a"}, "10.1.0.1")},
+					{Name: "b", Store: clusterStore(true, "10.97.0.1", []string{"shared", "svc-b"}, "10.2.0.1")},
+				},
+				Store:     proxystore.New(),
+				Conflicts: tc.policy,
+			}
+
+			runOnce(j)
+
+			synced, result := state(j.Store)
+			if !synced {
+				t.Error("merged store should be synced")
+			}
+			if s := strings.Join(result, " "); s != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, s)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	primary := clusterStore(false, "10.96.0.1", []string{"svc-a"}, "10.1.0.1")
+	secondary := clusterStore(false, "10.97.0.1", []string{"svc-b"}, "10.2.0.1")
+
+	j := &Job{
+		Sources:   []Source{{Name: "a", Store: primary}, {Name: "b", Store: secondary}},
+		Store:     proxystore.New(),
+		Conflicts: FirstWins,
+	}
+
+	runOnce(j)
+	if synced, result := state(j.Store); synced || len(result) != 0 {
+		t.Errorf("nothing should be merged before the primary is synced (synced: %v, state: %v)", synced, result)
+	}
+
+	primary.Update(func(tx *proxystore.Tx) {
+		for _, set := range proxystore.AllSets {
+			tx.SetSync(set)
+		}
+	})
+
+	runOnce(j)
+	if synced, result := state(j.Store); !synced || strings.Join(result, " ") != "svc-a=10.96.0.1:10.1.0.1" {
+		t.Errorf("only the primary should be merged (synced: %v, state: %v)", synced, result)
+	}
+
+	primary.Update(func(tx *proxystore.Tx) {
+		tx.DelService("default", "svc-a")
+	})
+	secondary.Update(func(tx *proxystore.Tx) {
+		for _, set := range proxystore.AllSets {
+			tx.SetSync(set)
+		}
+	})
+
+	runOnce(j)
+	if _, result := state(j.Store); strings.Join(result, " ") != "svc-b=10.97.0.1:10.2.0.1" {
+		t.Errorf("removed services should be removed: %v", result)
+	}
+}
+
+func TestSyncTimeout(t *testing.T) {
+	primary := clusterStore(false, "10.96.0.1", []string{"svc-a"}, "10.1.0.1")
+	secondary := clusterStore(true, "10.97.0.1", []string{"svc-b"}, "10.2.0.1")
+
+	j := &Job{
+		Sources:   []Source{{Name: "a", Store: primary}, {Name: "b", Store: secondary}},
+		Store:     proxystore.New(),
+		Conflicts: FirstWins,
+	}
+
+	runOnce(j)
+	if synced, _ := state(j.Store); synced {
+		t.Error("the merged store should not be synced before the primary is synced")
+	}
+
+	runOnceTimedOut(j, true)
+	if synced, result := state(j.Store); !synced || strings.Join(result, " ") != "svc-b=10.97.0.1:10.2.0.1" {
+		t.Errorf("the secondary should be served after the timeout (synced: %v, state: %v)", synced, result)
+	}
+
+	// the shards still wait for each other
+	j.SyncAll = true
+	j.Store = proxystore.New()
+
+	runOnceTimedOut(j, true)
+	if synced, _ := state(j.Store); synced {
+		t.Error("the merged store should not be synced before all the sources are synced")
+	}
+}
+
+func TestRun(t *testing.T) {
+	primary := clusterStore(false, "10.96.0.1", []string{"svc-a"}, "10.1.0.1")
+	secondary := clusterStore(true, "10.97.0.1", []string{"svc-b"}, "10.2.0.1")
+
+	j := &Job{
+		Sources:     []Source{{Name: "a", Store: primary}, {Name: "b", Store: secondary}},
+		Store:       proxystore.New(),
+		Conflicts:   FirstWins,
+		SyncTimeout: 100 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		j.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for synced := false; !synced; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the merged store should be synced after the timeout")
+		}
+		synced, _ = state(j.Store)
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job should stop when the context is done")
+	}
+
+	if _, closed := j.Store.View(0, func(*proxystore.Tx) {}); !closed {
+		t.Error("the merged store should be closed")
+	}
+}
+
+func TestSyncAll(t *testing.T) {
+	shard0 := clusterStore(true, "10.96.0.1", []string{"svc-a"}, "10.1.0.1")
+	shard1 := clusterStore(false, "10.96.0.2", []string{"svc-b"}, "10.1.0.2")
+
+	j := &Job{
+		Sources:   []Source{{Name: "shard-0", Store: shard0}, {Name: "shard-1", Store: shard1}},
+		Store:     proxystore.New(),
+		Conflicts: FirstWins,
+		SyncAll:   true,
+	}
+
+	runOnce(j)
+	if synced, _ := state(j.Store); synced {
+		t.Error("the merged store should not be synced before all the sources are synced")
+	}
+
+	shard1.Update(func(tx *proxystore.Tx) {
+		for _, set := range proxystore.AllSets {
+			tx.SetSync(set)
+		}
+	})
+
+	runOnce(j)
+	if synced, result := state(j.Store); !synced || strings.Join(result, " ") != "svc-a=10.96.0.1:10.1.0.1 svc-b=10.96.0.2:10.1.0.2" {
+		t.Errorf("all the shards should be merged (synced: %v, state: %v)", synced, result)
+	}
+}
+
+// the static services of kpng kube --static-services
+const staticServices = `
+services:
+- service:
+    name: external-db
+    ips:
+      clusterips: { v4: [ 10.96.100.1 ] }
+  endpoints:
+  - endpoint:
+      ips: { v4: [ 192.0.2.20 ] }
+- service:
+    name: svc-a
+    ips:
+      clusterips: { v4: [ 10.96.100.2 ] }
+  endpoints:
+  - endpoint:
+      ips: { v4: [ 192.0.2.21 ] }
+`
+
+func TestStaticServices(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "static.yaml")
+	if err := os.WriteFile(file, []byte(staticServices), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	staticStore := proxystore.New()
+	go (&file2store.Job{FilePath: file, Store: staticStore}).Run(ctx)
+
+	j := &Job{
+		Sources: []Source{
+			{Name: "kube", Store: clusterStore(true, "10.96.0.1", []string{"svc-a"}, "10.1.0.1")},
+			{Name: "static", Store: staticStore},
+		},
+		Store:     proxystore.New(),
+		Conflicts: FirstWins,
+	}
+	go j.Run(ctx)
+
+	// the cluster's service wins over the static one
+	expected := "external-db=10.96.100.1:192.0.2.20 svc-a=10.96.0.1:10.1.0.1"
+
+	var result []string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if _, result = state(j.Store); strings.Join(result, " ") == expected {
+			return
+		}
+	}
+	t.Errorf("expected %q, got %q", expected, result)
+}
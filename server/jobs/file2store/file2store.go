@@ -109,7 +109,7 @@ func (j *Job) Run(ctx context.Context) {
 					ep.ServiceName = svc.Name
 
 					if ep.Conditions == nil {
-						ep.Conditions = &globalv1.EndpointConditions{Ready: true}
+						ep.Conditions = &globalv1.EndpointConditions{Ready: true, Serving: true}
 					}
 
 					h.Write(serde.Marshal(ep))
@@ -0,0 +1,227 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube2store
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+// AnnotationDNSEndpoints makes the endpoints of a service the addresses (A and AAAA records)
+// of the given hostname. The service should have no selector. The EndpointSlices of FQDN
+// addresses are resolved the same way.
+const AnnotationDNSEndpoints = "kpng.sigs.k8s.io/dns-endpoints"
+
+// dnsSourceSuffix is appended to the service name to get the source of its DNS endpoints.
+const dnsSourceSuffix = ".dns"
+
+type dnsResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// dnsEndpoints periodically resolves the hostnames of the annotated services and of the FQDN
+// EndpointSlices to their endpoints.
+type dnsEndpoints struct {
+	store    *proxystore.Store
+	resolver dnsResolver
+	interval time.Duration
+
+	mu      sync.Mutex
+	sources map[serviceKey]*dnsSource // by namespace and source name
+	changed chan struct{}
+}
+
+// dnsSource are the endpoints of a source, resolved from hostnames.
+type dnsSource []dnsEndpoint
+
+// dnsEndpoint is an endpoint having the addresses of a hostname, each as an endpoint.
+type dnsEndpoint struct {
+	hostname string
+	// info is the endpoint, without addresses
+	info *globalv1.EndpointInfo
+}
+
+func (s dnsSource) equal(other dnsSource) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for i := range s {
+		if s[i].hostname != other[i].hostname || !proto.Equal(s[i].info, other[i].info) {
+			return false
+		}
+	}
+	return true
+}
+
+func newDNSEndpoints(store *proxystore.Store, interval time.Duration) *dnsEndpoints {
+	return &dnsEndpoints{
+		store:    store,
+		resolver: net.DefaultResolver,
+		interval: interval,
+		sources:  map[serviceKey]*dnsSource{},
+		changed:  make(chan struct{}, 1),
+	}
+}
+
+// set registers the hostname of a service, or unregisters the service if hostname is empty.
+func (d *dnsEndpoints) set(namespace, name, hostname string) {
+	source := name + dnsSourceSuffix
+
+	var endpoints dnsSource
+	if hostname != "" {
+		endpoints = dnsSource{{
+			hostname: hostname,
+			info: &globalv1.EndpointInfo{
+				Namespace:   namespace,
+				ServiceName: name,
+				SourceName:  source,
+				Endpoint:    &localv1.Endpoint{Hostname: hostname},
+				Conditions:  &globalv1.EndpointConditions{Ready: true, Serving: true},
+				Topology:    &globalv1.TopologyInfo{},
+			},
+		}}
+	}
+
+	d.setSource(namespace, source, endpoints)
+}
+
+// setSource registers the endpoints of a source, or unregisters the source if there are none.
+func (d *dnsEndpoints) setSource(namespace, source string, endpoints dnsSource) {
+	key := serviceKey{namespace, source}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if current, ok := d.sources[key]; ok && current.equal(endpoints) {
+		return
+	}
+
+	if len(endpoints) == 0 {
+		if _, ok := d.sources[key]; !ok {
+			return
+		}
+		delete(d.sources, key)
+
+		d.store.Update(func(tx *proxystore.Tx) {
+			tx.DelEndpointsOfSource(namespace, source)
+		})
+		return
+	}
+
+	d.sources[key] = &endpoints
+
+	select {
+	case d.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (d *dnsEndpoints) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.changed:
+		}
+
+		d.resolveAll(ctx)
+	}
+}
+
+func (d *dnsEndpoints) resolveAll(ctx context.Context) {
+	d.mu.Lock()
+	sources := make(map[serviceKey]*dnsSource, len(d.sources))
+	for key, source := range d.sources {
+		sources[key] = source
+	}
+	d.mu.Unlock()
+
+	// the hostnames shared by sources are resolved once
+	resolved := map[string][]string{}
+
+	for key, source := range sources {
+		infos := make([]*globalv1.EndpointInfo, 0, len(*source))
+		failed := false
+
+		for _, ep := range *source {
+			ips, ok := resolved[ep.hostname]
+			if !ok {
+				var err error
+				ips, err = d.resolve(ctx, ep.hostname)
+				if err != nil {
+					// keep the last known endpoints
+					klog.Warning("failed to resolve ", ep.hostname, " for the endpoints ", key.namespace, "/", key.name, ": ", err)
+					failed = true
+					break
+				}
+				resolved[ep.hostname] = ips
+			}
+
+			for _, ip := range ips {
+				info := proto.Clone(ep.info).(*globalv1.EndpointInfo)
+				info.Endpoint.AddAddress(ip)
+
+				infos = append(infos, info)
+			}
+		}
+
+		if failed {
+			continue
+		}
+
+		d.mu.Lock()
+		if d.sources[key] == source { // not changed or removed while resolving
+			d.store.Update(func(tx *proxystore.Tx) {
+				tx.SetEndpointsOfSource(key.namespace, key.name, infos)
+			})
+		}
+		d.mu.Unlock()
+	}
+}
+
+// resolve returns the sorted addresses of a hostname.
+func (d *dnsEndpoints) resolve(ctx context.Context, hostname string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.interval)
+	defer cancel()
+
+	addrs, err := d.resolver.LookupIPAddr(ctx, hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP.String())
+	}
+	sort.Strings(ips)
+
+	return ips, nil
+}
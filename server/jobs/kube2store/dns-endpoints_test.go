@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube2store
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupIPAddr(ctx context.Context, host string) (addrs []net.IPAddr, err error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return
+}
+
+func TestDNSEndpoints(t *testing.T) {
+	store := proxystore.New()
+
+	resolver := fakeResolver{"db.example.com": {"192.0.2.2", "2001:db8::2"}}
+
+	d := newDNSEndpoints(store, time.Minute)
+	d.resolver = resolver
+
+	endpoints := func() (ips []string) {
+		store.Update(func(tx *proxystore.Tx) {
+			tx.EachEndpointOfService("default", "db", func(ei *globalv1.EndpointInfo) {
+				ips = append(ips, ei.Endpoint.IPs.All()...)
+			})
+		})
+		return
+	}
+
+	d.set("default", "db", "db.example.com")
+	d.resolveAll(context.Background())
+
+	if ips := endpoints(); len(ips) != 2 {
+		t.Fatalf("expected 2 endpoints, got %v", ips)
+	}
+
+	// resolution errors keep the last known endpoints
+	delete(resolver, "db.example.com")
+	d.resolveAll(context.Background())
+
+	if ips := endpoints(); len(ips) != 2 {
+		t.Fatalf("expected the endpoints to be kept, got %v", ips)
+	}
+
+	d.set("default", "db", "")
+
+	if ips := endpoints(); len(ips) != 0 {
+		t.Fatalf("expected no endpoints, got %v", ips)
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube2store
+
+import (
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// AnnotationDSCP sets the DSCP value of the packets sent to a service (see localv1.Service.DSCP),
+// as a number (0 to 63) or a class name (ie: "EF", "AF41" or "CS3").
+const AnnotationDSCP = "kpng.sigs.k8s.io/dscp"
+
+// dscpOf returns the DSCP value of a service, 0 if not set or invalid.
+func dscpOf(svc *v1.Service) uint32 {
+	value, ok := svc.Annotations[AnnotationDSCP]
+	if !ok {
+		return 0
+	}
+
+	dscp, ok := parseDSCP(strings.TrimSpace(value))
+	if !ok {
+		klog.Warningf("service %s/%s: ignoring invalid DSCP %q (expected 0-63, EF, AF11-AF43 or CS0-CS7)",
+			svc.Namespace, svc.Name, value)
+		return 0
+	}
+	return dscp
+}
+
+func parseDSCP(value string) (uint32, bool) {
+	name := strings.ToUpper(value)
+
+	switch {
+	case name == "EF":
+		return 46, true
+
+	case len(name) == 3 && strings.HasPrefix(name, "CS") && name[2] >= '0' && name[2] <= '7':
+		return uint32(name[2]-'0') << 3, true
+
+	case len(name) == 4 && strings.HasPrefix(name, "AF") && name[2] >= '1' && name[2] <= '4' && name[3] >= '1' && name[3] <= '3':
+		// AFxy is class x with drop precedence y
+		return uint32(name[2]-'0')<<3 | uint32(name[3]-'0')<<1, true
+	}
+
+	dscp, err := strconv.ParseUint(value, 0, 6)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(dscp), true
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube2store
+
+import (
+	"strconv"
+	"strings"
+
+	discovery "k8s.io/api/discovery/v1"
+	"k8s.io/klog/v2"
+)
+
+// AnnotationEndpointWeights gives weights to the endpoints of an EndpointSlice, as a comma-separated
+// list of <address or pod name>=<weight> (ie: "10.1.0.5=3,web-0=2"). Endpoints not listed keep the
+// default weight. It stands in for a weight field until EndpointSlices have one.
+const AnnotationEndpointWeights = "kpng.sigs.k8s.io/endpoint-weights"
+
+// maxEndpointWeight is the highest weight accepted (the highest the backends can all honor).
+const maxEndpointWeight = 65535
+
+// endpointWeights are the weights of the endpoints of a slice, by address or pod name.
+type endpointWeights map[string]int32
+
+func endpointWeightsOf(eps *discovery.EndpointSlice) endpointWeights {
+	value := eps.Annotations[AnnotationEndpointWeights]
+	if value == "" {
+		return nil
+	}
+
+	weights := endpointWeights{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, weightStr, ok := strings.Cut(entry, "=")
+		weight, err := strconv.ParseInt(weightStr, 10, 32)
+		if !ok || key == "" || err != nil || weight < 1 || weight > maxEndpointWeight {
+			klog.Warningf("endpoint slice %s/%s: ignoring invalid weight %q (expected <address or pod name>=<1-%d>)",
+				eps.Namespace, eps.Name, entry, maxEndpointWeight)
+			continue
+		}
+
+		weights[key] = int32(weight)
+	}
+	return weights
+}
+
+// of returns the weight of an endpoint, 0 if not set.
+func (w endpointWeights) of(ep *discovery.Endpoint) int32 {
+	if len(w) == 0 {
+		return 0
+	}
+
+	if t := ep.TargetRef; t != nil && t.Kind == "Pod" {
+		if weight, ok := w[t.Name]; ok {
+			return weight
+		}
+	}
+
+	for _, addr := range ep.Addresses {
+		if weight, ok := w[addr]; ok {
+			return weight
+		}
+	}
+	return 0
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube2store
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEndpointWeights(t *testing.T) {
+	eps := &discovery.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "web-abcde",
+			Annotations: map[string]string{
+				AnnotationEndpointWeights: "10.1.0.5=3, web-0=2,10.1.0.7=0,10.1.0.8=x,=4",
+			},
+		},
+	}
+
+	weights := endpointWeightsOf(eps)
+
+	for _, tc := range []struct {
+		name     string
+		endpoint discovery.Endpoint
+		weight   int32
+	}{
+		{"by address", discovery.Endpoint{Addresses: []string{"10.1.0.5"}}, 3},
+		{"by pod name", discovery.Endpoint{
+			Addresses: []string{"10.1.0.6"},
+			TargetRef: &v1.ObjectReference{Kind: "Pod", Name: "web-0"},
+		}, 2},
+		{"invalid weight", discovery.Endpoint{Addresses: []string{"10.1.0.7"}}, 0},
+		{"not listed", discovery.Endpoint{Addresses: []string{"10.1.0.9"}}, 0},
+	} {
+		if weight := weights.of(&tc.endpoint); weight != tc.weight {
+			t.Errorf("%s: expected weight %d, got %d", tc.name, tc.weight, weight)
+		}
+	}
+
+	if len(weights) != 2 {
+		t.Errorf("expected only the valid weights, got %v", weights)
+	}
+
+	if weights := endpointWeightsOf(&discovery.EndpointSlice{}); weights.of(&discovery.Endpoint{}) != 0 {
+		t.Error("expected no weight without the annotation")
+	}
+}
@@ -0,0 +1,369 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube2store
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+// Gateway API objects.
+var (
+	gatewaysGVR  = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1beta1", Resource: "gateways"}
+	tcpRoutesGVR = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1alpha2", Resource: "tcproutes"}
+	udpRoutesGVR = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1alpha2", Resource: "udproutes"}
+)
+
+// gatewayServiceSuffix is appended to "<gateway>.<listener>" to get the name of a listener's
+// service in the store.
+const gatewayServiceSuffix = ".gateway"
+
+// gateway is the part of a Gateway we use.
+type gateway struct {
+	Namespace string
+	Name      string
+	Spec      struct {
+		GatewayClassName string `json:"gatewayClassName"`
+		Listeners        []struct {
+			Name     string `json:"name"`
+			Port     int32  `json:"port"`
+			Protocol string `json:"protocol"`
+		} `json:"listeners"`
+		Addresses []gatewayAddress `json:"addresses"`
+	} `json:"spec"`
+	Status struct {
+		Addresses []gatewayAddress `json:"addresses"`
+	} `json:"status"`
+}
+
+type gatewayAddress struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// l4Route is the part of a TCPRoute or an UDPRoute we use.
+type l4Route struct {
+	Namespace string
+	Name      string
+	Spec      struct {
+		ParentRefs []struct {
+			Namespace   string `json:"namespace"`
+			Name        string `json:"name"`
+			SectionName string `json:"sectionName"`
+			Port        int32  `json:"port"`
+		} `json:"parentRefs"`
+		Rules []struct {
+			BackendRefs []struct {
+				Kind      string `json:"kind"`
+				Namespace string `json:"namespace"`
+				Name      string `json:"name"`
+				Port      int32  `json:"port"`
+			} `json:"backendRefs"`
+		} `json:"rules"`
+	} `json:"spec"`
+}
+
+func fromUnstructured(obj interface{}, v interface{}) (namespace, name string, err error) {
+	u := obj.(*unstructured.Unstructured)
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, v)
+	return u.GetNamespace(), u.GetName(), err
+}
+
+// gatewayTranslator produces a service for each TCP or UDP listener of the gateways of a class,
+// with the endpoints of the backends of the routes attached to it.
+type gatewayTranslator struct {
+	className string
+	store     *proxystore.Store
+
+	gateways, tcpRoutes, udpRoutes cache.Store
+
+	changed chan struct{}
+
+	// services produced by the last translation
+	services map[serviceKey]bool
+}
+
+type serviceKey struct{ namespace, name string }
+
+func (j Job) watchGatewayRoutes(stopCh <-chan struct{}) {
+	if j.Dynamic == nil {
+		return
+	}
+	for _, gvr := range []schema.GroupVersionResource{gatewaysGVR, tcpRoutesGVR, udpRoutesGVR} {
+		if !j.resourceAvailable(gvr) {
+			return
+		}
+	}
+
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(j.Dynamic, j.Config.ResyncPeriod)
+
+	t := &gatewayTranslator{
+		className: j.Config.GatewayClassName,
+		store:     j.Store,
+		changed:   make(chan struct{}, 1),
+		services:  map[serviceKey]bool{},
+	}
+
+	synced := make([]cache.InformerSynced, 0, 3)
+	for _, v := range []struct {
+		gvr   schema.GroupVersionResource
+		store *cache.Store
+	}{
+		{gatewaysGVR, &t.gateways},
+		{tcpRoutesGVR, &t.tcpRoutes},
+		{udpRoutesGVR, &t.udpRoutes},
+	} {
+		informer := factory.ForResource(v.gvr).Informer()
+		informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(interface{}) { t.trigger() },
+			UpdateFunc: func(interface{}, interface{}) { t.trigger() },
+			DeleteFunc: func(interface{}) { t.trigger() },
+		})
+		go informer.Run(stopCh)
+
+		*v.store = informer.GetStore()
+		synced = append(synced, informer.HasSynced)
+	}
+
+	cache.WaitForCacheSync(stopCh, synced...)
+
+	go t.run(stopCh)
+}
+
+func (t *gatewayTranslator) trigger() {
+	select {
+	case t.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (t *gatewayTranslator) run(stopCh <-chan struct{}) {
+	// the backends' endpoints also come from the store
+	go func() {
+		var (
+			rev    uint64
+			closed bool
+		)
+		for {
+			rev, closed = t.store.View(rev, func(tx *proxystore.Tx) {})
+			if closed {
+				return
+			}
+			t.trigger()
+		}
+	}()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-t.changed:
+		}
+
+		t.store.Update(t.translate)
+	}
+}
+
+func (t *gatewayTranslator) routes(store cache.Store) (routes []*l4Route) {
+	for _, obj := range store.List() {
+		route := &l4Route{}
+
+		var err error
+		route.Namespace, route.Name, err = fromUnstructured(obj, route)
+		if err != nil {
+			klog.Error("invalid route: ", err)
+			continue
+		}
+
+		routes = append(routes, route)
+	}
+	return
+}
+
+func (t *gatewayTranslator) translate(tx *proxystore.Tx) {
+	tcpRoutes, udpRoutes := t.routes(t.tcpRoutes), t.routes(t.udpRoutes)
+
+	services := map[serviceKey]bool{}
+
+	for _, obj := range t.gateways.List() {
+		gw := &gateway{}
+
+		var err error
+		gw.Namespace, gw.Name, err = fromUnstructured(obj, gw)
+		if err != nil {
+			klog.Error("invalid gateway: ", err)
+			continue
+		}
+
+		if gw.Spec.GatewayClassName != t.className {
+			continue
+		}
+
+		addresses := gw.Status.Addresses
+		if len(addresses) == 0 {
+			addresses = gw.Spec.Addresses
+		}
+
+		ips := localv1.NewIPSet()
+		for _, addr := range addresses {
+			if addr.Type == "" || addr.Type == "IPAddress" {
+				ips.Add(addr.Value)
+			}
+		}
+
+		if ips.IsEmpty() {
+			klog.V(1).Info("gateway ", gw.Namespace, "/", gw.Name, " has no address yet")
+			continue
+		}
+
+		for _, listener := range gw.Spec.Listeners {
+			var routes []*l4Route
+			switch listener.Protocol {
+			case "TCP":
+				routes = tcpRoutes
+			case "UDP":
+				routes = udpRoutes
+			default:
+				continue // not an L4 listener
+			}
+
+			service := &localv1.Service{
+				Namespace: gw.Namespace,
+				Name:      gw.Name + "." + listener.Name + gatewayServiceSuffix,
+				Type:      "ClusterIP",
+				IPs:       &localv1.ServiceIPs{ClusterIPs: ips},
+				Ports: []*localv1.PortMapping{{
+					Name:     listener.Name,
+					Port:     listener.Port,
+					Protocol: localv1.ParseProtocol(listener.Protocol),
+					// the target port of each endpoint depends on its backend, so it's an override
+					TargetPortName: listener.Name,
+				}},
+			}
+
+			endpoints := make([]*globalv1.EndpointInfo, 0)
+			for _, route := range routes {
+				if !route.attachedTo(gw, listener.Name, listener.Port) {
+					continue
+				}
+				endpoints = append(endpoints, t.routeEndpoints(tx, route, service)...)
+			}
+
+			tx.SetService(service)
+			tx.SetEndpointsOfSource(service.Namespace, service.Name, endpoints)
+
+			services[serviceKey{service.Namespace, service.Name}] = true
+		}
+	}
+
+	// remove the services of removed gateways or listeners
+	for key := range t.services {
+		if services[key] {
+			continue
+		}
+
+		tx.DelService(key.namespace, key.name)
+		tx.DelEndpointsOfSource(key.namespace, key.name)
+	}
+
+	t.services = services
+}
+
+func (route *l4Route) attachedTo(gw *gateway, listenerName string, listenerPort int32) bool {
+	for _, ref := range route.Spec.ParentRefs {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = route.Namespace
+		}
+
+		if namespace != gw.Namespace || ref.Name != gw.Name {
+			continue
+		}
+		if ref.SectionName != "" && ref.SectionName != listenerName {
+			continue
+		}
+		if ref.Port != 0 && ref.Port != listenerPort {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// routeEndpoints returns the endpoints of the route's backend services, for the given gateway service.
+func (t *gatewayTranslator) routeEndpoints(tx *proxystore.Tx, route *l4Route, service *localv1.Service) (endpoints []*globalv1.EndpointInfo) {
+	port := service.Ports[0]
+
+	for _, rule := range route.Spec.Rules {
+		for _, ref := range rule.BackendRefs {
+			if ref.Kind != "" && ref.Kind != "Service" {
+				continue
+			}
+
+			// cross-namespace references would require a ReferenceGrant
+			if ref.Namespace != "" && ref.Namespace != route.Namespace {
+				klog.V(1).Info("route ", route.Namespace, "/", route.Name, ": ignoring cross-namespace backend ", ref.Namespace, "/", ref.Name)
+				continue
+			}
+
+			backend := tx.GetService(route.Namespace, ref.Name)
+			if backend == nil {
+				continue
+			}
+
+			var backendPort *localv1.PortMapping
+			for _, p := range backend.Ports {
+				if p.Port == ref.Port && p.Protocol == port.Protocol {
+					backendPort = p
+					break
+				}
+			}
+			if backendPort == nil {
+				continue
+			}
+
+			tx.EachEndpointOfService(route.Namespace, ref.Name, func(ei *globalv1.EndpointInfo) {
+				endpoints = append(endpoints, &globalv1.EndpointInfo{
+					Namespace:   service.Namespace,
+					SourceName:  service.Name,
+					ServiceName: service.Name,
+					PodName:     ei.PodName,
+					Topology:    ei.Topology,
+					Hints:       ei.Hints,
+					Conditions:  ei.Conditions,
+					Endpoint: &localv1.Endpoint{
+						IPs: ei.Endpoint.IPs,
+						PortOverrides: []*localv1.PortName{{
+							Name: port.Name,
+							Port: ei.Endpoint.PortMapping(backendPort),
+						}},
+					},
+				})
+			})
+		}
+	}
+
+	return
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube2store
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+
+	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+func object(kind, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind":     kind,
+		"metadata": map[string]interface{}{"namespace": "default", "name": name},
+		"spec":     spec,
+	}}
+}
+
+func TestGatewayTranslation(t *testing.T) {
+	store := proxystore.New()
+
+	tr := &gatewayTranslator{
+		className: "kpng",
+		store:     store,
+		gateways:  cache.NewStore(cache.MetaNamespaceKeyFunc),
+		tcpRoutes: cache.NewStore(cache.MetaNamespaceKeyFunc),
+		udpRoutes: cache.NewStore(cache.MetaNamespaceKeyFunc),
+		services:  map[serviceKey]bool{},
+	}
+
+	tr.gateways.Add(object("Gateway", "gw", map[string]interface{}{
+		"gatewayClassName": "kpng",
+		"addresses":        []interface{}{map[string]interface{}{"value": "192.0.2.10"}},
+		"listeners": []interface{}{
+			map[string]interface{}{"name": "db", "port": int64(5432), "protocol": "TCP"},
+			map[string]interface{}{"name": "web", "port": int64(443), "protocol": "HTTPS"},
+		},
+	}))
+	tr.tcpRoutes.Add(object("TCPRoute", "db", map[string]interface{}{
+		"parentRefs": []interface{}{map[string]interface{}{"name": "gw", "sectionName": "db"}},
+		"rules": []interface{}{map[string]interface{}{
+			"backendRefs": []interface{}{map[string]interface{}{"name": "postgres", "port": int64(5432)}},
+		}},
+	}))
+
+	store.Update(func(tx *proxystore.Tx) {
+		tx.SetService(&localv1.Service{
+			Namespace: "default",
+			Name:      "postgres",
+			Ports: []*localv1.PortMapping{
+				{Name: "pg", Port: 5432, Protocol: localv1.Protocol_TCP, TargetPortName: "pg"},
+			},
+		})
+		tx.SetEndpointsOfSource("default", "postgres-abcde", []*globalv1.EndpointInfo{{
+			Namespace:   "default",
+			SourceName:  "postgres-abcde",
+			ServiceName: "postgres",
+			Endpoint: &localv1.Endpoint{
+				IPs:           localv1.NewIPSet("10.1.0.5"),
+				PortOverrides: []*localv1.PortName{{Name: "pg", Port: 15432}},
+			},
+		}})
+
+		tr.translate(tx)
+	})
+
+	store.Update(func(tx *proxystore.Tx) {
+		svc := tx.GetService("default", "gw.db"+gatewayServiceSuffix)
+		if svc == nil {
+			t.Fatal("no service for the TCP listener")
+		}
+		if svc.IPs.ClusterIPs.First() != "192.0.2.10" {
+			t.Errorf("unexpected IPs: %v", svc.IPs)
+		}
+		if tx.GetService("default", "gw.web"+gatewayServiceSuffix) != nil {
+			t.Error("HTTPS listeners should be ignored")
+		}
+
+		count := 0
+		tx.EachEndpointOfService("default", svc.Name, func(ei *globalv1.EndpointInfo) {
+			count++
+			if port := ei.Endpoint.PortMapping(svc.Ports[0]); port != 15432 {
+				t.Errorf("expected target port 15432, got %d", port)
+			}
+		})
+		if count != 1 {
+			t.Errorf("expected 1 endpoint, got %d", count)
+		}
+	})
+
+	// removing the gateway removes its services
+	tr.gateways.Delete(object("Gateway", "gw", nil))
+	store.Update(tr.translate)
+
+	store.Update(func(tx *proxystore.Tx) {
+		if tx.GetService("default", "gw.db"+gatewayServiceSuffix) != nil {
+			t.Error("service not removed with its gateway")
+		}
+	})
+}
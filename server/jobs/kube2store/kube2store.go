@@ -24,9 +24,13 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 
@@ -52,6 +56,38 @@ type K8sConfig struct {
 
 	NodeLabelGlobs      []string
 	NodeAnnotationGlobs []string
+
+	// ExcludeServices lists the services ("namespace/name") kpng must not handle, like the ones
+	// annotated with AnnotationExcludeService.
+	ExcludeServices []string
+
+	// NoTrackServices lists the services ("namespace/name") whose UDP traffic bypasses
+	// conntrack, like the ones annotated with AnnotationNoTrack.
+	NoTrackServices []string
+
+	// WatchServiceImports adds the multi-cluster services (ServiceImport) to the services.
+	WatchServiceImports bool
+
+	// GatewayClassName enables the translation of the TCPRoutes and UDPRoutes attached to the
+	// Gateways of this class to services.
+	GatewayClassName string
+
+	// DNSEndpointsInterval is the resolution interval of the services annotated with
+	// AnnotationDNSEndpoints (0 to ignore the annotation).
+	DNSEndpointsInterval time.Duration
+
+	// APIQPS and APIBurst are the client-side rate limits of the requests to the API server.
+	APIQPS   float32
+	APIBurst int
+
+	// ResyncPeriod is the resync period of the informers (0 to disable the resyncs).
+	ResyncPeriod time.Duration
+
+	// Shards is the number of jobs the namespaces are split between (by hash), and Shard the
+	// index of this job's namespaces. The services, endpoints and service imports of the other
+	// namespaces are ignored; the shards are merged by federating their APIs.
+	Shards int
+	Shard  int
 }
 
 // TODO: need to find a better home for this
@@ -59,6 +95,18 @@ const (
 	// LabelServiceProxyName indicates that an alternative service
 	// proxy will implement this Service.
 	LabelServiceProxyName = "service.kubernetes.io/service-proxy-name"
+
+	// AnnotationExcludeService set to "true" tells KPNG to ignore a service (ie: because
+	// another controller handles its datapath).
+	AnnotationExcludeService = "kpng.sigs.k8s.io/exclude"
+
+	// AnnotationHealthCheck configures the active health checking of the endpoints of a service
+	// (see the prober job). It's always kept in the service's annotations.
+	AnnotationHealthCheck = "kpng.sigs.k8s.io/health-check"
+
+	// AnnotationNoTrack set to "true" tells the backends to bypass conntrack for the UDP
+	// traffic of a service's endpoints (see localv1.Service.NoTrack).
+	AnnotationNoTrack = "kpng.sigs.k8s.io/notrack"
 )
 
 func (c *K8sConfig) BindFlags(flags *pflag.FlagSet) {
@@ -71,32 +119,69 @@ func (c *K8sConfig) BindFlags(flags *pflag.FlagSet) {
 		"kubernetes.io/hostname", "topology.kubernetes.io/zone", "topology.kubernetes.io/region",
 	}, "node labels to include")
 	flags.StringSliceVar(&c.NodeAnnotationGlobs, "with-node-annotations", nil, "node annotations to include")
+
+	flags.StringSliceVar(&c.ExcludeServices, "exclude-services", nil, "services (namespace/name) to ignore, in addition to the ones annotated with "+AnnotationExcludeService+"=true")
+	flags.StringSliceVar(&c.NoTrackServices, "notrack-services", nil, "services (namespace/name) whose UDP traffic bypasses conntrack, in addition to the ones annotated with "+AnnotationNoTrack+"=true")
+
+	flags.BoolVar(&c.WatchServiceImports, "watch-service-imports", false, "watch multi-cluster ServiceImports (if their CRD is installed)")
+	flags.StringVar(&c.GatewayClassName, "gateway-class", "", "translate the L4 routes of the Gateways of this class to services (disabled if not set)")
+	flags.DurationVar(&c.DNSEndpointsInterval, "dns-endpoints-interval", 30*time.Second, "resolution interval of the services annotated with "+AnnotationDNSEndpoints+" (0 to disable)")
+
+	flags.Float32Var(&c.APIQPS, "kube-api-qps", 5, "QPS to use while talking with the Kubernetes API server")
+	flags.IntVar(&c.APIBurst, "kube-api-burst", 10, "burst to use while talking with the Kubernetes API server")
+	flags.DurationVar(&c.ResyncPeriod, "informers-resync-period", 30*time.Second, "resync period of the Kubernetes informers (0 to disable)")
+
+	flags.IntVar(&c.Shards, "namespace-shards", 1, "split the namespaces between this number of kpng servers, to be merged with kpng federate --sync-all-sources")
+	flags.IntVar(&c.Shard, "namespace-shard", 0, "index of the namespaces handled by this kpng server, from 0 to --namespace-shards - 1")
+}
+
+// ApplyClientConfig sets the rate limits of the Kubernetes client built from cfg.
+func (c *K8sConfig) ApplyClientConfig(cfg *rest.Config) {
+	cfg.QPS = c.APIQPS
+	cfg.Burst = c.APIBurst
 }
 
 type Job struct {
 	Kube   *kubernetes.Clientset
 	Store  *proxystore.Store
 	Config *K8sConfig
+
+	// Dynamic is required to watch CRDs (ie: ServiceImports)
+	Dynamic dynamic.Interface
 }
 
 func (j Job) Run(ctx context.Context) {
 	stopCh := ctx.Done()
 
 	// start informers
-	factory := informers.NewSharedInformerFactoryWithOptions(j.Kube, time.Second*30)
+	factory := informers.NewSharedInformerFactoryWithOptions(j.Kube, j.Config.ResyncPeriod)
 	factory.Start(stopCh)
 
 	labelSelector := j.getLabelSelector().String()
 	klog.Info("service label selector: ", labelSelector)
-	svcFactory := informers.NewSharedInformerFactoryWithOptions(j.Kube, time.Second*30,
+	svcFactory := informers.NewSharedInformerFactoryWithOptions(j.Kube, j.Config.ResyncPeriod,
 		informers.WithTweakListOptions(func(options *metav1.ListOptions) { options.LabelSelector = labelSelector }))
 	svcFactory.Start(stopCh)
 
 	// start watches
+	if j.Config.WatchServiceImports {
+		j.watchServiceImports(stopCh)
+	}
+	if j.Config.GatewayClassName != "" {
+		j.watchGatewayRoutes(stopCh)
+	}
+
 	coreFactory := factory.Core().V1()
 
-	servicesInformer := svcFactory.Core().V1().Services().Informer()
-	servicesInformer.AddEventHandler(&serviceEventHandler{j.eventHandler(servicesInformer)})
+	var dns *dnsEndpoints
+	if interval := j.Config.DNSEndpointsInterval; interval > 0 {
+		dns = newDNSEndpoints(j.Store, interval)
+		go dns.Run(ctx)
+	}
+
+	servicesInformer := j.servicesInformer(svcFactory, labelSelector)
+	servicesInformer.AddEventHandler(j.shardHandler(servicesInformer,
+		&serviceEventHandler{j.eventHandler(servicesInformer), dns}, proxystore.Services))
 	go servicesInformer.Run(stopCh)
 
 	nodesInformer := coreFactory.Nodes().Informer()
@@ -104,13 +189,26 @@ func (j Job) Run(ctx context.Context) {
 	go nodesInformer.Run(stopCh)
 
 	slicesInformer := factory.Discovery().V1().EndpointSlices().Informer()
-	slicesInformer.AddEventHandler(&sliceEventHandler{j.eventHandler(slicesInformer)})
+	slicesInformer.AddEventHandler(j.shardHandler(slicesInformer,
+		&sliceEventHandler{j.eventHandler(slicesInformer), dns}, proxystore.Endpoints))
 	go slicesInformer.Run(stopCh)
 
 	<-stopCh
 	j.Store.Close()
 }
 
+// servicesInformer returns the informer of the services. They're watched unstructured when
+// possible, to read the ipMode of their load-balancer ingresses (see toService).
+func (j Job) servicesInformer(svcFactory informers.SharedInformerFactory, labelSelector string) cache.SharedIndexInformer {
+	if j.Dynamic == nil {
+		return svcFactory.Core().V1().Services().Informer()
+	}
+
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(j.Dynamic, j.Config.ResyncPeriod, metav1.NamespaceAll,
+		func(options *metav1.ListOptions) { options.LabelSelector = labelSelector })
+	return factory.ForResource(v1.SchemeGroupVersion.WithResource("services")).Informer()
+}
+
 func (j Job) eventHandler(informer cache.SharedIndexInformer) eventHandler {
 	return eventHandler{
 		k8sConfig: j.Config,
@@ -141,3 +239,21 @@ func (j Job) getLabelSelector() labels.Selector {
 
 	return labelSelector
 }
+
+// resourceAvailable checks that a resource (ie: from a CRD) is served by the API server.
+func (j Job) resourceAvailable(gvr schema.GroupVersionResource) bool {
+	resources, err := j.Kube.Discovery().ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		klog.Warning(gvr.Resource, " not available: ", err)
+		return false
+	}
+
+	for _, resource := range resources.APIResources {
+		if resource.Name == gvr.Resource {
+			return true
+		}
+	}
+
+	klog.Warning(gvr.Resource, " not available: no such resource in ", resources.GroupVersion)
+	return false
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube2store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/rest"
+)
+
+func TestK8sConfigClientFlags(t *testing.T) {
+	cfg := &K8sConfig{}
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	cfg.BindFlags(flags)
+
+	if err := flags.Parse([]string{"--kube-api-qps=50", "--kube-api-burst=100", "--informers-resync-period=0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.ResyncPeriod != time.Duration(0) {
+		t.Errorf("expected resyncs to be disabled, got %v", cfg.ResyncPeriod)
+	}
+
+	restCfg := &rest.Config{}
+	cfg.ApplyClientConfig(restCfg)
+
+	if restCfg.QPS != 50 || restCfg.Burst != 100 {
+		t.Errorf("expected QPS 50 and burst 100, got %v and %d", restCfg.QPS, restCfg.Burst)
+	}
+}
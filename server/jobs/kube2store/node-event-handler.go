@@ -20,6 +20,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 
 	globalv1 "sigs.k8s.io/kpng/api/globalv1"
+	localv1 "sigs.k8s.io/kpng/api/localv1"
 	proxystore "sigs.k8s.io/kpng/server/proxystore"
 )
 
@@ -41,6 +42,7 @@ func (h *nodeEventHandler) OnAdd(obj interface{}) {
 		},
 		Labels:      globsFilter(node.Labels, h.k8sConfig.NodeLabelGlobs),
 		Annotations: globsFilter(node.Annotations, h.k8sConfig.NodeAnnotationGlobs),
+		IPs:         nodeIPs(node),
 	}
 
 	h.s.Update(func(tx *proxystore.Tx) {
@@ -63,3 +65,15 @@ func (h *nodeEventHandler) OnDelete(oldObj interface{}) {
 		h.updateSync(proxystore.Nodes, tx)
 	})
 }
+
+// nodeIPs returns the internal and external addresses of a node, used by the endpoints of its
+// hostNetwork pods.
+func nodeIPs(node *v1.Node) *localv1.IPSet {
+	ips := &localv1.IPSet{}
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == v1.NodeInternalIP || addr.Type == v1.NodeExternalIP {
+			ips.Add(addr.Address)
+		}
+	}
+	return ips
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube2store
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	localv1 "sigs.k8s.io/kpng/api/localv1"
+)
+
+// AnnotationPriority sets the programming priority class of a service (see
+// localv1.Service.Priority): "critical", "high", "normal" or "low".
+const AnnotationPriority = "kpng.sigs.k8s.io/priority"
+
+// priorityOf returns the priority class of a service, normal if not set or invalid.
+func priorityOf(svc *v1.Service) localv1.Priority {
+	value, ok := svc.Annotations[AnnotationPriority]
+	if !ok {
+		return localv1.Priority_NormalPriority
+	}
+
+	priority, ok := localv1.ParsePriority(value)
+	if !ok {
+		klog.Warningf("service %s/%s: ignoring invalid priority %q (expected critical, high, normal or low)",
+			svc.Namespace, svc.Name, value)
+	}
+	return priority
+}
@@ -18,6 +18,8 @@ package kube2store
 
 import (
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/klog/v2"
 
@@ -25,10 +27,86 @@ import (
 	"sigs.k8s.io/kpng/server/proxystore"
 )
 
-type serviceEventHandler struct{ eventHandler }
+type serviceEventHandler struct {
+	eventHandler
+
+	// dns resolves the endpoints of annotated services, if enabled
+	dns *dnsEndpoints
+}
+
+func (h *serviceEventHandler) excluded(svc *v1.Service) bool {
+	if svc.Annotations[AnnotationExcludeService] == "true" {
+		return true
+	}
+
+	for _, name := range h.k8sConfig.ExcludeServices {
+		if name == svc.Namespace+"/"+svc.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *serviceEventHandler) noTrack(svc *v1.Service) bool {
+	if svc.Annotations[AnnotationNoTrack] == "true" {
+		return true
+	}
+
+	for _, name := range h.k8sConfig.NoTrackServices {
+		if name == svc.Namespace+"/"+svc.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ipModeProxy is the ipMode of the load-balancer ingresses sending the traffic to the nodes.
+const ipModeProxy = "Proxy"
+
+// toService returns the service of an informer's object, with the ipMode of its load-balancer
+// ingresses by IP. The ipMode is only known for the unstructured services: the typed services of
+// k8s.io/api v0.25 don't have the field (added in v1.29), it's dropped when decoding them.
+func toService(obj interface{}) (svc *v1.Service, ipModes map[string]string, err error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return obj.(*v1.Service), nil, nil
+	}
+
+	svc = &v1.Service{}
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, svc); err != nil {
+		return
+	}
+
+	ingresses, _, _ := unstructured.NestedSlice(u.Object, "status", "loadBalancer", "ingress")
+	for _, ingress := range ingresses {
+		ingress, _ := ingress.(map[string]interface{})
+		ip, _, _ := unstructured.NestedString(ingress, "ip")
+		ipMode, _, _ := unstructured.NestedString(ingress, "ipMode")
+		if ip == "" || ipMode == "" {
+			continue
+		}
+		if ipModes == nil {
+			ipModes = map[string]string{}
+		}
+		ipModes[ip] = ipMode
+	}
+	return
+}
 
 func (h *serviceEventHandler) onChange(obj interface{}) {
-	svc := obj.(*v1.Service)
+	svc, ipModes, err := toService(obj)
+	if err != nil {
+		klog.Error("invalid service: ", err)
+		return
+	}
+
+	if h.excluded(svc) {
+		klog.V(2).Info("service ", svc.Namespace, "/", svc.Name, " is excluded")
+		h.OnDelete(svc) // in case it was not excluded before
+		return
+	}
 
 	internalTrafficPolicy := v1.ServiceInternalTrafficPolicyCluster
 	if svc.Spec.InternalTrafficPolicy != nil {
@@ -47,6 +125,22 @@ func (h *serviceEventHandler) onChange(obj interface{}) {
 		},
 		ExternalTrafficToLocal: svc.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal,
 		InternalTrafficToLocal: internalTrafficPolicy == v1.ServiceInternalTrafficPolicyLocal,
+		ExternalName:           svc.Spec.ExternalName,
+		NoTrack:                h.noTrack(svc),
+		DSCP:                   dscpOf(svc),
+		Priority:               priorityOf(svc),
+	}
+
+	if svc.Spec.Type == v1.ServiceTypeLoadBalancer && svc.Spec.AllocateLoadBalancerNodePorts != nil {
+		service.NoLoadBalancerNodePorts = !*svc.Spec.AllocateLoadBalancerNodePorts
+	}
+
+	// the prober needs the health check, whatever the annotations included
+	if healthCheck, ok := svc.Annotations[AnnotationHealthCheck]; ok {
+		if service.Annotations == nil {
+			service.Annotations = map[string]string{}
+		}
+		service.Annotations[AnnotationHealthCheck] = healthCheck
 	}
 
 	// extract cluster IPs with backward compatibility (k8s before ClusterIPs)
@@ -78,12 +172,20 @@ func (h *serviceEventHandler) onChange(obj interface{}) {
 		}
 	}
 
-	// load balancer IPs
+	// load balancer IPs, the ones with the Proxy ipMode being also in ProxyLoadBalancerIPs
 	if len(svc.Status.LoadBalancer.Ingress) != 0 {
 		ips := localv1.NewIPSet()
 		for _, ingress := range svc.Status.LoadBalancer.Ingress {
-			if ingress.IP != "" {
-				ips.Add(ingress.IP)
+			if ingress.IP == "" {
+				continue
+			}
+			ips.Add(ingress.IP)
+
+			if ipModes[ingress.IP] == ipModeProxy {
+				if service.IPs.ProxyLoadBalancerIPs == nil {
+					service.IPs.ProxyLoadBalancerIPs = localv1.NewIPSet()
+				}
+				service.IPs.ProxyLoadBalancerIPs.Add(ingress.IP)
 			}
 		}
 		service.IPs.LoadBalancerIPs = ips
@@ -121,6 +223,10 @@ func (h *serviceEventHandler) onChange(obj interface{}) {
 		tx.SetService(service)
 		h.updateSync(proxystore.Services, tx)
 	})
+
+	if h.dns != nil {
+		h.dns.set(svc.Namespace, svc.Name, svc.Annotations[AnnotationDNSEndpoints])
+	}
 }
 
 func (h *serviceEventHandler) OnAdd(obj interface{}) {
@@ -132,10 +238,18 @@ func (h *serviceEventHandler) OnUpdate(oldObj, newObj interface{}) {
 }
 
 func (h *serviceEventHandler) OnDelete(oldObj interface{}) {
-	svc := oldObj.(*v1.Service)
+	svc, _, err := toService(oldObj)
+	if err != nil {
+		klog.Error("invalid service: ", err)
+		return
+	}
 
 	h.s.Update(func(tx *proxystore.Tx) {
 		tx.DelService(svc.Namespace, svc.Name)
 		h.updateSync(proxystore.Services, tx)
 	})
+
+	if h.dns != nil {
+		h.dns.set(svc.Namespace, svc.Name, "")
+	}
 }
@@ -1,11 +1,14 @@
 package kube2store
 
 import (
+	"reflect"
 	"testing"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
+	localv1 "sigs.k8s.io/kpng/api/localv1"
 	"sigs.k8s.io/kpng/server/proxystore"
 )
 
@@ -62,6 +65,302 @@ func TestServiceEventHandlerTrafficPolicy(t *testing.T) {
 	}
 }
 
+func TestServiceEventHandlerExclusion(t *testing.T) {
+	store := proxystore.New()
+
+	handler := serviceEventHandler{
+		eventHandler: eventHandler{
+			s:       store,
+			syncSet: true,
+			k8sConfig: &K8sConfig{
+				ExcludeServices: []string{"default/by-flag"},
+			},
+		},
+	}
+
+	svc := func(name string, annotations map[string]string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "default",
+				Name:        name,
+				Annotations: annotations,
+			},
+			Spec: v1.ServiceSpec{
+				Type: v1.ServiceTypeClusterIP,
+			},
+		}
+	}
+
+	handler.onChange(svc("handled", nil))
+	handler.onChange(svc("by-flag", nil))
+	handler.onChange(svc("by-annotation", map[string]string{AnnotationExcludeService: "true"}))
+
+	// a service becoming excluded is removed
+	handler.onChange(svc("later", nil))
+	handler.onChange(svc("later", map[string]string{AnnotationExcludeService: "true"}))
+
+	names := []string{}
+	store.View(0, func(tx *proxystore.Tx) {
+		tx.Each(proxystore.Services, func(kv *proxystore.KV) bool {
+			names = append(names, kv.Name)
+			return true
+		})
+	})
+
+	if len(names) != 1 || names[0] != "handled" {
+		t.Errorf("expected only the handled service, got %v", names)
+	}
+}
+
+func TestServiceEventHandlerNoLoadBalancerNodePorts(t *testing.T) {
+	store := proxystore.New()
+
+	handler := serviceEventHandler{
+		eventHandler: eventHandler{
+			s:         store,
+			syncSet:   true,
+			k8sConfig: &K8sConfig{},
+		},
+	}
+
+	for testIdx, test := range []struct {
+		Type     v1.ServiceType
+		Allocate *bool
+		Expected bool
+	}{
+		{v1.ServiceTypeLoadBalancer, nil, false},
+		{v1.ServiceTypeLoadBalancer, ref(true), false},
+		{v1.ServiceTypeLoadBalancer, ref(false), true},
+		{v1.ServiceTypeNodePort, ref(false), false},
+	} {
+		handler.onChange(&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "test-svc",
+			},
+			Spec: v1.ServiceSpec{
+				Type:                          test.Type,
+				AllocateLoadBalancerNodePorts: test.Allocate,
+			},
+		})
+
+		store.View(0, func(tx *proxystore.Tx) {
+			tx.Each(proxystore.Services, func(kv *proxystore.KV) bool {
+				if kv.Service.Service.NoLoadBalancerNodePorts != test.Expected {
+					t.Errorf("test[%d]: expected %v, got %v", testIdx, test.Expected, kv.Service.Service.NoLoadBalancerNodePorts)
+				}
+				return true
+			})
+		})
+	}
+}
+
+func TestServiceEventHandlerIPMode(t *testing.T) {
+	store := proxystore.New()
+
+	handler := serviceEventHandler{
+		eventHandler: eventHandler{
+			s:         store,
+			syncSet:   true,
+			k8sConfig: &K8sConfig{},
+		},
+	}
+
+	// as received by the dynamic informer
+	handler.OnAdd(&unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"namespace": "default", "name": "lb"},
+		"spec": map[string]interface{}{
+			"type":       "LoadBalancer",
+			"clusterIPs": []interface{}{"10.96.0.10"},
+			"ports":      []interface{}{map[string]interface{}{"port": int64(80), "protocol": "TCP"}},
+		},
+		"status": map[string]interface{}{
+			"loadBalancer": map[string]interface{}{
+				"ingress": []interface{}{
+					map[string]interface{}{"ip": "198.51.100.10", "ipMode": "VIP"},
+					map[string]interface{}{"ip": "198.51.100.11", "ipMode": "Proxy"},
+					map[string]interface{}{"ip": "198.51.100.12"},
+					map[string]interface{}{"hostname": "lb.example.com", "ipMode": "Proxy"},
+				},
+			},
+		},
+	}})
+
+	var ips *localv1.ServiceIPs
+	store.View(0, func(tx *proxystore.Tx) {
+		if svc := tx.GetService("default", "lb"); svc != nil {
+			ips = svc.IPs
+		}
+	})
+
+	if ips == nil {
+		t.Fatal("service not found")
+	}
+	if expected := []string{"198.51.100.10", "198.51.100.11", "198.51.100.12"}; !reflect.DeepEqual(ips.LoadBalancerIPs.All(), expected) {
+		t.Errorf("expected the load balancer IPs %v, got %v", expected, ips.LoadBalancerIPs.All())
+	}
+	if expected := []string{"198.51.100.11"}; !reflect.DeepEqual(ips.ProxyLoadBalancerIPs.All(), expected) {
+		t.Errorf("expected the proxy load balancer IPs %v, got %v", expected, ips.ProxyLoadBalancerIPs.All())
+	}
+	if ips.ClusterIPs.First() != "10.96.0.10" {
+		t.Errorf("expected the cluster IP, got %v", ips.ClusterIPs)
+	}
+
+	// the typed services have no ipMode
+	handler.OnAdd(&v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "lb"},
+		Spec:       v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+		Status: v1.ServiceStatus{LoadBalancer: v1.LoadBalancerStatus{
+			Ingress: []v1.LoadBalancerIngress{{IP: "198.51.100.11"}},
+		}},
+	})
+	store.View(0, func(tx *proxystore.Tx) {
+		if svc := tx.GetService("default", "lb"); svc == nil || svc.IPs.ProxyLoadBalancerIPs != nil {
+			t.Errorf("expected no proxy load balancer IPs, got %v", svc)
+		}
+	})
+}
+
 func ref[T any](v T) *T {
 	return &v
 }
+
+func TestServiceEventHandlerNoTrack(t *testing.T) {
+	store := proxystore.New()
+
+	handler := serviceEventHandler{
+		eventHandler: eventHandler{
+			s:       store,
+			syncSet: true,
+			k8sConfig: &K8sConfig{
+				NoTrackServices: []string{"default/by-flag"},
+			},
+		},
+	}
+
+	svc := func(name string, annotations map[string]string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "default",
+				Name:        name,
+				Annotations: annotations,
+			},
+			Spec: v1.ServiceSpec{
+				Type: v1.ServiceTypeClusterIP,
+			},
+		}
+	}
+
+	handler.onChange(svc("tracked", nil))
+	handler.onChange(svc("by-flag", nil))
+	handler.onChange(svc("by-annotation", map[string]string{AnnotationNoTrack: "true"}))
+
+	noTrack := map[string]bool{}
+	store.View(0, func(tx *proxystore.Tx) {
+		tx.Each(proxystore.Services, func(kv *proxystore.KV) bool {
+			noTrack[kv.Name] = kv.Service.Service.NoTrack
+			return true
+		})
+	})
+
+	expected := map[string]bool{"tracked": false, "by-flag": true, "by-annotation": true}
+	if !reflect.DeepEqual(noTrack, expected) {
+		t.Errorf("expected %v, got %v", expected, noTrack)
+	}
+}
+
+func TestServiceEventHandlerDSCP(t *testing.T) {
+	store := proxystore.New()
+
+	handler := serviceEventHandler{
+		eventHandler: eventHandler{
+			s:         store,
+			syncSet:   true,
+			k8sConfig: &K8sConfig{},
+		},
+	}
+
+	for name, value := range map[string]string{
+		"number":  "46",
+		"hex":     "0x12",
+		"ef":      "EF",
+		"af":      "af41",
+		"cs":      "CS1",
+		"invalid": "64",
+		"unknown": "AF51",
+	} {
+		handler.onChange(&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "default",
+				Name:        name,
+				Annotations: map[string]string{AnnotationDSCP: value},
+			},
+			Spec: v1.ServiceSpec{
+				Type: v1.ServiceTypeClusterIP,
+			},
+		})
+	}
+
+	dscp := map[string]uint32{}
+	store.View(0, func(tx *proxystore.Tx) {
+		tx.Each(proxystore.Services, func(kv *proxystore.KV) bool {
+			dscp[kv.Name] = kv.Service.Service.DSCP
+			return true
+		})
+	})
+
+	expected := map[string]uint32{"number": 46, "hex": 18, "ef": 46, "af": 34, "cs": 8, "invalid": 0, "unknown": 0}
+	if !reflect.DeepEqual(dscp, expected) {
+		t.Errorf("expected %v, got %v", expected, dscp)
+	}
+}
+
+func TestServiceEventHandlerPriority(t *testing.T) {
+	store := proxystore.New()
+
+	handler := serviceEventHandler{
+		eventHandler: eventHandler{
+			s:         store,
+			syncSet:   true,
+			k8sConfig: &K8sConfig{},
+		},
+	}
+
+	for name, value := range map[string]string{
+		"critical": "critical",
+		"high":     "High",
+		"low":      "low",
+		"invalid":  "urgent",
+		"none":     "",
+	} {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec:       v1.ServiceSpec{Type: v1.ServiceTypeClusterIP},
+		}
+		if value != "" {
+			svc.Annotations = map[string]string{AnnotationPriority: value}
+		}
+		handler.onChange(svc)
+	}
+
+	priority := map[string]localv1.Priority{}
+	store.View(0, func(tx *proxystore.Tx) {
+		tx.Each(proxystore.Services, func(kv *proxystore.KV) bool {
+			priority[kv.Name] = kv.Service.Service.Priority
+			return true
+		})
+	})
+
+	expected := map[string]localv1.Priority{
+		"critical": localv1.Priority_CriticalPriority,
+		"high":     localv1.Priority_HighPriority,
+		"low":      localv1.Priority_LowPriority,
+		"invalid":  localv1.Priority_NormalPriority,
+		"none":     localv1.Priority_NormalPriority,
+	}
+	if !reflect.DeepEqual(priority, expected) {
+		t.Errorf("expected %v, got %v", expected, priority)
+	}
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube2store
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+// Multi-Cluster Services API (KEP-1645) objects.
+var serviceImportsGVR = schema.GroupVersionResource{
+	Group:    "multicluster.x-k8s.io",
+	Version:  "v1alpha1",
+	Resource: "serviceimports",
+}
+
+const (
+	// LabelMultiClusterServiceName is set on the EndpointSlices of an imported service.
+	LabelMultiClusterServiceName = "multicluster.kubernetes.io/service-name"
+
+	// serviceImportSuffix is appended to the name of a ServiceImport to get the name of its
+	// service in the store. It can't collide with a Service as dots are invalid in their names.
+	serviceImportSuffix = ".clusterset"
+)
+
+// serviceImport is the part of a ServiceImport we use.
+type serviceImport struct {
+	Namespace string
+	Name      string
+	Spec      serviceImportSpec `json:"spec"`
+}
+
+type serviceImportSpec struct {
+	Ports                 []serviceImportPort `json:"ports"`
+	IPs                   []string            `json:"ips"`
+	Type                  string              `json:"type"`
+	SessionAffinity       string              `json:"sessionAffinity"`
+	SessionAffinityConfig *struct {
+		ClientIP *struct {
+			TimeoutSeconds *int32 `json:"timeoutSeconds"`
+		} `json:"clientIP"`
+	} `json:"sessionAffinityConfig"`
+}
+
+type serviceImportPort struct {
+	Name     string `json:"name"`
+	Protocol string `json:"protocol"`
+	Port     int32  `json:"port"`
+}
+
+func (j Job) watchServiceImports(stopCh <-chan struct{}) {
+	if j.Dynamic == nil || !j.resourceAvailable(serviceImportsGVR) {
+		return
+	}
+
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(j.Dynamic, j.Config.ResyncPeriod)
+
+	informer := factory.ForResource(serviceImportsGVR).Informer()
+	informer.AddEventHandler(j.shardHandler(informer, &serviceImportEventHandler{j.eventHandler(informer)}))
+	go informer.Run(stopCh)
+
+	// imported services must be there when services are synced
+	cache.WaitForCacheSync(stopCh, informer.HasSynced)
+}
+
+type serviceImportEventHandler struct{ eventHandler }
+
+func toServiceImport(obj interface{}) (si *serviceImport, err error) {
+	u := obj.(*unstructured.Unstructured)
+
+	si = &serviceImport{}
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, si); err != nil {
+		return
+	}
+
+	si.Namespace, si.Name = u.GetNamespace(), u.GetName()
+	return
+}
+
+func (h *serviceImportEventHandler) onChange(obj interface{}) {
+	si, err := toServiceImport(obj)
+	if err != nil {
+		klog.Error("invalid service import: ", err)
+		return
+	}
+
+	if si.Spec.Type != "ClusterSetIP" {
+		// headless imports have no VIP to program
+		h.OnDelete(obj)
+		return
+	}
+
+	service := &localv1.Service{
+		Namespace: si.Namespace,
+		Name:      si.Name + serviceImportSuffix,
+		Type:      "ClusterIP",
+		Labels: map[string]string{
+			LabelMultiClusterServiceName: si.Name,
+		},
+		IPs: &localv1.ServiceIPs{
+			ClusterIPs: localv1.NewIPSet(si.Spec.IPs...),
+		},
+	}
+
+	if si.Spec.SessionAffinity == "ClientIP" {
+		timeout := int32(10800) // same default as services
+		if cfg := si.Spec.SessionAffinityConfig; cfg != nil && cfg.ClientIP != nil && cfg.ClientIP.TimeoutSeconds != nil {
+			timeout = *cfg.ClientIP.TimeoutSeconds
+		}
+
+		service.SessionAffinity = &localv1.Service_ClientIP{
+			ClientIP: &localv1.ClientIPAffinity{TimeoutSeconds: timeout},
+		}
+	}
+
+	service.Ports = make([]*localv1.PortMapping, 0, len(si.Spec.Ports))
+	for _, port := range si.Spec.Ports {
+		protocol := port.Protocol
+		if protocol == "" {
+			protocol = "TCP"
+		}
+
+		service.Ports = append(service.Ports, &localv1.PortMapping{
+			Name:     port.Name,
+			Port:     port.Port,
+			Protocol: localv1.ParseProtocol(protocol),
+			// imports have no target port: backends take the port of the endpoint slices with
+			// the same name.
+			TargetPortName: port.Name,
+		})
+	}
+
+	h.s.Update(func(tx *proxystore.Tx) {
+		klog.V(3).Info("service import ", service.Namespace, "/", si.Name)
+		tx.SetService(service)
+	})
+}
+
+func (h *serviceImportEventHandler) OnAdd(obj interface{}) {
+	h.onChange(obj)
+}
+
+func (h *serviceImportEventHandler) OnUpdate(oldObj, newObj interface{}) {
+	h.onChange(newObj)
+}
+
+func (h *serviceImportEventHandler) OnDelete(oldObj interface{}) {
+	if tombstone, ok := oldObj.(cache.DeletedFinalStateUnknown); ok {
+		oldObj = tombstone.Obj
+	}
+
+	u := oldObj.(*unstructured.Unstructured)
+
+	h.s.Update(func(tx *proxystore.Tx) {
+		tx.DelService(u.GetNamespace(), u.GetName()+serviceImportSuffix)
+	})
+}
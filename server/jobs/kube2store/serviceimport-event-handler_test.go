@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube2store
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+func TestServiceImport(t *testing.T) {
+	store := proxystore.New()
+	handler := serviceImportEventHandler{
+		eventHandler: eventHandler{
+			s:         store,
+			syncSet:   true,
+			k8sConfig: &K8sConfig{WatchServiceImports: true},
+		},
+	}
+
+	si := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "multicluster.x-k8s.io/v1alpha1",
+		"kind":       "ServiceImport",
+		"metadata": map[string]interface{}{
+			"namespace": "default",
+			"name":      "web",
+		},
+		"spec": map[string]interface{}{
+			"type":            "ClusterSetIP",
+			"ips":             []interface{}{"10.42.0.10"},
+			"sessionAffinity": "ClientIP",
+			"ports": []interface{}{
+				map[string]interface{}{"name": "http", "protocol": "TCP", "port": int64(80)},
+			},
+		},
+	}}
+
+	handler.OnAdd(si)
+
+	var svc *localv1.Service
+	store.View(0, func(tx *proxystore.Tx) {
+		tx.Each(proxystore.Services, func(kv *proxystore.KV) bool {
+			svc = kv.Service.Service
+			return false
+		})
+	})
+
+	if svc == nil {
+		t.Fatal("no service created")
+	}
+	if svc.Name != "web"+serviceImportSuffix {
+		t.Errorf("unexpected name: %s", svc.Name)
+	}
+	if ips := svc.IPs.ClusterIPs.V4; len(ips) != 1 || ips[0] != "10.42.0.10" {
+		t.Errorf("unexpected cluster IPs: %v", ips)
+	}
+	if svc.GetClientIP().GetTimeoutSeconds() != 10800 {
+		t.Errorf("unexpected session affinity: %v", svc.SessionAffinity)
+	}
+
+	// endpoint ports are resolved by name
+	if port := svc.Ports[0]; port.TargetPort != 0 || port.TargetPortName != "http" {
+		t.Errorf("expected the target port to be named http, got %d/%q", port.TargetPort, port.TargetPortName)
+	}
+	ep := &localv1.Endpoint{PortOverrides: []*localv1.PortName{{Name: "http", Port: 8080}}}
+	if port := ep.PortMapping(svc.Ports[0]); port != 8080 {
+		t.Errorf("expected target port 8080, got %d", port)
+	}
+
+	handler.OnDelete(si)
+
+	store.Update(func(tx *proxystore.Tx) {
+		tx.Each(proxystore.Services, func(kv *proxystore.KV) bool {
+			t.Errorf("service not deleted: %s", kv.Path())
+			return true
+		})
+	})
+}
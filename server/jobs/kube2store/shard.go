@@ -0,0 +1,164 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube2store
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+// Sharded tells if the namespaces are split between several jobs.
+func (c *K8sConfig) Sharded() bool {
+	return c.Shards > 1
+}
+
+// ValidateShards checks the sharding options.
+func (c *K8sConfig) ValidateShards() error {
+	if c.Shards < 1 {
+		return fmt.Errorf("invalid number of shards: %d", c.Shards)
+	}
+	if c.Shard < 0 || c.Shard >= c.Shards {
+		return fmt.Errorf("invalid shard %d: must be in [0, %d)", c.Shard, c.Shards)
+	}
+	if c.Sharded() && c.GatewayClassName != "" {
+		// the routes' backends may be in the namespaces of other shards
+		return errors.New("the gateway routes can't be translated by a shard")
+	}
+	return nil
+}
+
+// ownsNamespace tells if the namespace is handled by this shard.
+func (c *K8sConfig) ownsNamespace(namespace string) bool {
+	if !c.Sharded() {
+		return true
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(namespace))
+	return int(h.Sum32()%uint32(c.Shards)) == c.Shard
+}
+
+func (c *K8sConfig) ownsObject(obj interface{}) bool {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		klog.Error("failed to get the key of an object: ", err)
+		return false
+	}
+
+	namespace, _, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		klog.Error("invalid object key: ", err)
+		return false
+	}
+
+	return c.ownsNamespace(namespace)
+}
+
+// stripForeign reduces the objects of the namespaces owned by other shards to the metadata the
+// informer needs to track them, so they don't use memory.
+func (c *K8sConfig) stripForeign(obj interface{}) (interface{}, error) {
+	if c.ownsObject(obj) {
+		return obj, nil
+	}
+
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metav1.PartialObjectMetadata{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       m.GetNamespace(),
+			Name:            m.GetName(),
+			UID:             m.GetUID(),
+			ResourceVersion: m.GetResourceVersion(),
+		},
+	}, nil
+}
+
+// shardFilter passes the events of the namespaces owned by the shard to its handler.
+type shardFilter struct {
+	eventHandler
+	handler cache.ResourceEventHandler
+
+	// syncSets are the sets the handler marks as synced
+	syncSets []proxystore.Set
+}
+
+// shardHandler returns the handler of the informer's events, filtered by shard if the namespaces
+// are sharded.
+func (j Job) shardHandler(informer cache.SharedIndexInformer, handler cache.ResourceEventHandler, syncSets ...proxystore.Set) cache.ResourceEventHandler {
+	if !j.Config.Sharded() {
+		return handler
+	}
+
+	if err := informer.SetTransform(j.Config.stripForeign); err != nil {
+		klog.Warning("failed to strip the objects of other shards: ", err)
+	}
+
+	return &shardFilter{
+		eventHandler: j.eventHandler(informer),
+		handler:      handler,
+		syncSets:     syncSets,
+	}
+}
+
+// skipped still marks the sets as synced, as the shard may own none of the objects.
+func (f *shardFilter) skipped() {
+	if f.syncSet || len(f.syncSets) == 0 || !f.informer.HasSynced() {
+		return
+	}
+
+	f.s.Update(func(tx *proxystore.Tx) {
+		for _, set := range f.syncSets {
+			tx.SetSync(set)
+		}
+	})
+	f.syncSet = true
+}
+
+func (f *shardFilter) OnAdd(obj interface{}) {
+	if !f.k8sConfig.ownsObject(obj) {
+		f.skipped()
+		return
+	}
+	f.handler.OnAdd(obj)
+}
+
+func (f *shardFilter) OnUpdate(oldObj, newObj interface{}) {
+	if !f.k8sConfig.ownsObject(newObj) {
+		f.skipped()
+		return
+	}
+	f.handler.OnUpdate(oldObj, newObj)
+}
+
+func (f *shardFilter) OnDelete(oldObj interface{}) {
+	if !f.k8sConfig.ownsObject(oldObj) {
+		f.skipped()
+		return
+	}
+	f.handler.OnDelete(oldObj)
+}
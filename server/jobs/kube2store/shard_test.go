@@ -0,0 +1,132 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube2store
+
+import (
+	"fmt"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestOwnsNamespace(t *testing.T) {
+	const shards = 4
+
+	owned := make([]int, shards)
+	for i := 0; i < 1000; i++ {
+		namespace := fmt.Sprintf("ns-%d", i)
+
+		owners := 0
+		for shard := 0; shard < shards; shard++ {
+			if (&K8sConfig{Shards: shards, Shard: shard}).ownsNamespace(namespace) {
+				owners++
+				owned[shard]++
+			}
+		}
+
+		if owners != 1 {
+			t.Fatalf("namespace %s is owned by %d shards", namespace, owners)
+		}
+	}
+
+	for shard, count := range owned {
+		if count == 0 {
+			t.Errorf("shard %d owns no namespace", shard)
+		}
+	}
+
+	if !(&K8sConfig{Shards: 1}).ownsNamespace("any") {
+		t.Error("a single shard should own every namespace")
+	}
+}
+
+func TestValidateShards(t *testing.T) {
+	for _, tc := range []struct {
+		cfg K8sConfig
+		ok  bool
+	}{
+		{K8sConfig{Shards: 1}, true},
+		{K8sConfig{Shards: 3, Shard: 2}, true},
+		{K8sConfig{Shards: 0}, false},
+		{K8sConfig{Shards: 3, Shard: 3}, false},
+		{K8sConfig{Shards: 3, Shard: -1}, false},
+		{K8sConfig{Shards: 3, GatewayClassName: "kpng"}, false},
+	} {
+		if err := tc.cfg.ValidateShards(); (err == nil) != tc.ok {
+			t.Errorf("%+v: unexpected result: %v", tc.cfg, err)
+		}
+	}
+}
+
+type recordingHandler struct{ names []string }
+
+func (h *recordingHandler) OnAdd(obj interface{}) {
+	h.names = append(h.names, obj.(*v1.Service).Name)
+}
+func (h *recordingHandler) OnUpdate(oldObj, newObj interface{}) { h.OnAdd(newObj) }
+func (h *recordingHandler) OnDelete(oldObj interface{}) {
+	if tombstone, ok := oldObj.(cache.DeletedFinalStateUnknown); ok {
+		oldObj = tombstone.Obj
+	}
+	h.OnAdd(oldObj)
+}
+
+func TestShardFilter(t *testing.T) {
+	cfg := &K8sConfig{Shards: 2}
+
+	// find a namespace of each shard
+	var mine, other string
+	for i := 0; mine == "" || other == ""; i++ {
+		namespace := fmt.Sprintf("ns-%d", i)
+		if cfg.ownsNamespace(namespace) {
+			mine = namespace
+		} else {
+			other = namespace
+		}
+	}
+
+	svc := func(namespace, name string) *v1.Service {
+		return &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	}
+
+	handler := &recordingHandler{}
+	f := &shardFilter{eventHandler: eventHandler{k8sConfig: cfg}, handler: handler}
+
+	f.OnAdd(svc(mine, "a"))
+	f.OnAdd(svc(other, "b"))
+	f.OnUpdate(svc(other, "c"), svc(other, "c"))
+	f.OnDelete(cache.DeletedFinalStateUnknown{Key: mine + "/d", Obj: svc(mine, "d")})
+	f.OnDelete(cache.DeletedFinalStateUnknown{Key: other + "/e", Obj: svc(other, "e")})
+
+	if fmt.Sprint(handler.names) != "[a d]" {
+		t.Errorf("only the services of the shard should be handled, got %v", handler.names)
+	}
+
+	stripped, err := cfg.stripForeign(svc(other, "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := stripped.(*metav1.PartialObjectMetadata); !ok || m.Namespace != other || m.Name != "b" {
+		t.Errorf("the services of other shards should be reduced to their metadata, got %#v", stripped)
+	}
+
+	if kept, _ := cfg.stripForeign(svc(mine, "a")); kept.(*v1.Service).Name != "a" {
+		t.Error("the services of the shard should be kept")
+	}
+}
@@ -29,18 +29,31 @@ import (
 
 const hostNameLabel = "kubernetes.io/hostname"
 
-type sliceEventHandler struct{ eventHandler }
+type sliceEventHandler struct {
+	eventHandler
 
-func serviceNameFrom(eps *discovery.EndpointSlice) string {
+	// dns resolves the endpoints of the FQDN slices, if enabled
+	dns *dnsEndpoints
+}
+
+func (h sliceEventHandler) serviceNameFrom(eps *discovery.EndpointSlice) string {
 	if eps.Labels == nil {
 		return ""
 	}
-	return eps.Labels[discovery.LabelServiceName]
+	if name := eps.Labels[discovery.LabelServiceName]; name != "" {
+		return name
+	}
+	if h.k8sConfig.WatchServiceImports {
+		if name := eps.Labels[LabelMultiClusterServiceName]; name != "" {
+			return name + serviceImportSuffix
+		}
+	}
+	return ""
 }
 
 func (h sliceEventHandler) OnAdd(obj interface{}) {
 	eps := obj.(*discovery.EndpointSlice)
-	serviceName := serviceNameFrom(eps)
+	serviceName := h.serviceNameFrom(eps)
 	if serviceName == "" {
 		// no name => not associated with a service => ignore
 		return
@@ -48,6 +61,24 @@ func (h sliceEventHandler) OnAdd(obj interface{}) {
 
 	// compute endpoints
 	infos := make([]*globalv1.EndpointInfo, 0, len(eps.Endpoints))
+	weights := endpointWeightsOf(eps)
+
+	// the ports are the same for all the endpoints of the slice, so they share them; the
+	// user-managed slices may have unnamed ports, and ports without number (all the ports)
+	ports := make([]*localv1.PortName, 0, len(eps.Ports))
+	for _, port := range eps.Ports {
+		if port.Port == nil {
+			continue
+		}
+		name := ""
+		if port.Name != nil {
+			name = *port.Name
+		}
+		ports = append(ports, &localv1.PortName{Name: name, Port: *port.Port})
+	}
+
+	fqdn := eps.AddressType == discovery.AddressTypeFQDN
+	var dnsSource dnsSource
 
 	for _, sliceEndpoint := range eps.Endpoints {
 		info := &globalv1.EndpointInfo{
@@ -85,23 +116,47 @@ func (h sliceEventHandler) OnAdd(obj interface{}) {
 			sort.Strings(info.Hints.Zones) // stable zone order
 		}
 
-		if r := sliceEndpoint.Conditions.Ready; r != nil && *r {
+		// an unknown readiness is ready (ie: in the slices written by hand), and so is an unknown
+		// serving condition of a ready endpoint
+		if r := sliceEndpoint.Conditions.Ready; r == nil || *r {
 			info.Conditions.Ready = true
 		}
+		if s := sliceEndpoint.Conditions.Serving; s == nil {
+			info.Conditions.Serving = info.Conditions.Ready
+		} else {
+			info.Conditions.Serving = *s
+		}
+		if t := sliceEndpoint.Conditions.Terminating; t != nil {
+			info.Conditions.Terminating = *t
+		}
 
-		for _, addr := range sliceEndpoint.Addresses {
-			info.Endpoint.AddAddress(addr)
+		info.Endpoint.Weight = weights.of(&sliceEndpoint)
+		info.Endpoint.PortOverrides = ports
+
+		if fqdn {
+			for _, hostname := range sliceEndpoint.Addresses {
+				dnsSource = append(dnsSource, dnsEndpoint{hostname: hostname, info: info})
+			}
+			continue
 		}
 
-		ports := make([]*localv1.PortName, 0, len(eps.Ports))
-		for _, port := range eps.Ports {
-			ports = append(ports, &localv1.PortName{Name: *port.Name, Port: *port.Port})
+		for _, addr := range sliceEndpoint.Addresses {
+			info.Endpoint.AddAddress(addr)
 		}
-		info.Endpoint.PortOverrides = ports
 
 		infos = append(infos, info)
 	}
 
+	if fqdn {
+		if h.dns == nil {
+			klog.V(1).Info("ignoring the FQDN endpoints of ", eps.Namespace, "/", eps.Name, " (--dns-endpoints-interval is 0)")
+			return
+		}
+		// the slice is the source of the resolved endpoints
+		h.dns.setSource(eps.Namespace, eps.Name, dnsSource)
+		return
+	}
+
 	h.s.Update(func(tx *proxystore.Tx) {
 		tx.SetEndpointsOfSource(eps.Namespace, eps.Name, infos)
 		h.updateSync(proxystore.Endpoints, tx)
@@ -123,6 +178,11 @@ func (h sliceEventHandler) OnUpdate(oldObj, newObj interface{}) {
 func (h sliceEventHandler) OnDelete(oldObj interface{}) {
 	eps := oldObj.(*discovery.EndpointSlice)
 
+	if h.dns != nil && eps.AddressType == discovery.AddressTypeFQDN {
+		h.dns.setSource(eps.Namespace, eps.Name, nil)
+		return
+	}
+
 	h.s.Update(func(tx *proxystore.Tx) {
 		tx.DelEndpointsOfSource(eps.Namespace, eps.Name)
 		h.updateSync(proxystore.Endpoints, tx)
@@ -0,0 +1,136 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube2store
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	discovery "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+func TestSliceEventHandlerUserManaged(t *testing.T) {
+	store := proxystore.New()
+
+	dns := newDNSEndpoints(store, time.Minute)
+	dns.resolver = fakeResolver{"db.example.com": {"192.0.2.2", "192.0.2.3"}}
+
+	handler := sliceEventHandler{
+		eventHandler: eventHandler{s: store, syncSet: true, k8sConfig: &K8sConfig{}},
+		dns:          dns,
+	}
+
+	slice := func(name string, addressType discovery.AddressType, addresses ...string) *discovery.EndpointSlice {
+		return &discovery.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      name,
+				Labels:    map[string]string{discovery.LabelServiceName: name},
+			},
+			AddressType: addressType,
+			// no name, readiness nor node, like the slices written by hand
+			Endpoints: []discovery.Endpoint{{Addresses: addresses}},
+			Ports:     []discovery.EndpointPort{{Port: ref(int32(5432))}},
+		}
+	}
+
+	handler.OnAdd(slice("manual", discovery.AddressTypeIPv4, "192.0.2.1"))
+	handler.OnAdd(slice("db", discovery.AddressTypeFQDN, "db.example.com"))
+	dns.resolveAll(context.Background())
+
+	endpoints := func(service string) (ips []string) {
+		store.View(0, func(tx *proxystore.Tx) {
+			tx.EachEndpointOfService("default", service, func(ei *globalv1.EndpointInfo) {
+				if !ei.Conditions.Ready {
+					t.Errorf("%s: endpoint %v is not ready", service, ei.Endpoint.IPs)
+				}
+				if port := ei.Endpoint.PortMapping(&localv1.PortMapping{TargetPort: 80}); port != 5432 {
+					t.Errorf("%s: expected the port of the slice, got %d", service, port)
+				}
+				ips = append(ips, ei.Endpoint.IPs.All()...)
+			})
+		})
+		sort.Strings(ips)
+		return
+	}
+
+	if ips := endpoints("manual"); !reflect.DeepEqual(ips, []string{"192.0.2.1"}) {
+		t.Errorf("manual: unexpected endpoints %v", ips)
+	}
+	if ips := endpoints("db"); !reflect.DeepEqual(ips, []string{"192.0.2.2", "192.0.2.3"}) {
+		t.Errorf("db: unexpected endpoints %v", ips)
+	}
+
+	handler.OnDelete(slice("db", discovery.AddressTypeFQDN, "db.example.com"))
+
+	if ips := endpoints("db"); len(ips) != 0 {
+		t.Errorf("db: expected no endpoints, got %v", ips)
+	}
+}
+
+func TestSliceEventHandlerConditions(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		conditions discovery.EndpointConditions
+		expected   *globalv1.EndpointConditions
+	}{
+		{"unknown", discovery.EndpointConditions{}, &globalv1.EndpointConditions{Ready: true, Serving: true}},
+		{"ready", discovery.EndpointConditions{Ready: ref(true)}, &globalv1.EndpointConditions{Ready: true, Serving: true}},
+		{"not ready", discovery.EndpointConditions{Ready: ref(false)}, &globalv1.EndpointConditions{}},
+		{"serving terminating", discovery.EndpointConditions{Ready: ref(false), Serving: ref(true), Terminating: ref(true)},
+			&globalv1.EndpointConditions{Serving: true, Terminating: true}},
+		{"terminating", discovery.EndpointConditions{Ready: ref(false), Serving: ref(false), Terminating: ref(true)},
+			&globalv1.EndpointConditions{Terminating: true}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			store := proxystore.New()
+			handler := sliceEventHandler{eventHandler: eventHandler{s: store, syncSet: true, k8sConfig: &K8sConfig{}}}
+
+			handler.OnAdd(&discovery.EndpointSlice{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "default",
+					Name:      "svc-abcde",
+					Labels:    map[string]string{discovery.LabelServiceName: "svc"},
+				},
+				AddressType: discovery.AddressTypeIPv4,
+				Endpoints:   []discovery.Endpoint{{Addresses: []string{"10.1.0.1"}, Conditions: test.conditions}},
+			})
+
+			found := false
+			store.View(0, func(tx *proxystore.Tx) {
+				tx.EachEndpointOfService("default", "svc", func(ei *globalv1.EndpointInfo) {
+					found = true
+					c := ei.Conditions
+					if c.Ready != test.expected.Ready || c.Serving != test.expected.Serving || c.Terminating != test.expected.Terminating {
+						t.Errorf("expected %v, got %v", test.expected, c)
+					}
+				})
+			})
+			if !found {
+				t.Error("endpoint not found")
+			}
+		})
+	}
+}
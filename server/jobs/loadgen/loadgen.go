@@ -0,0 +1,343 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package loadgen generates synthetic service and endpoints churn, to measure the behavior of kpng
+// at scale. The load is sent to a Target: the kube API, or a file read by the `kpng file` source.
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/time/rate"
+	"k8s.io/klog/v2"
+)
+
+type Config struct {
+	// Services is the number of services created.
+	Services int
+	// EndpointsPerService is the number of endpoints of each service.
+	EndpointsPerService int
+
+	// CreateRate is the rate of the service creations (per second) until Services are created.
+	CreateRate float64
+	// ChurnRate is the rate of the changes (per second) once the services are created.
+	ChurnRate float64
+	// UpdateRatio is the ratio of the changes updating endpoints, the others delete or recreate services.
+	UpdateRatio float64
+	// Duration of the churn (0 to run until interrupted).
+	Duration time.Duration
+	// Cleanup deletes the services at the end.
+	Cleanup bool
+	Seed    int64
+
+	Namespace   string
+	ServiceCIDR string
+	PodCIDR     string
+
+	// MetricsURL is scraped every ScrapeInterval, and the kpng metrics are logged with the stats.
+	MetricsURL     string
+	MetricsPrefix  string
+	ScrapeInterval time.Duration
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.IntVar(&c.Services, "services", 1000, "Number of services")
+	flags.IntVar(&c.EndpointsPerService, "endpoints-per-service", 10, "Number of endpoints per service")
+
+	flags.Float64Var(&c.CreateRate, "create-rate", 100, "Service creations per second until all the services are created")
+	flags.Float64Var(&c.ChurnRate, "churn-rate", 50, "Changes per second once the services are created")
+	flags.Float64Var(&c.UpdateRatio, "update-ratio", 0.8, "Ratio of the changes updating endpoints; the others delete or recreate a service")
+	flags.DurationVar(&c.Duration, "duration", 10*time.Minute, "Duration of the churn (0 to run until interrupted)")
+	flags.BoolVar(&c.Cleanup, "cleanup", true, "Delete the services when done")
+	flags.Int64Var(&c.Seed, "seed", 0, "Seed of the changes (0 for a random seed)")
+
+	flags.StringVar(&c.Namespace, "namespace", "kpng-loadgen", "Namespace of the services")
+	flags.StringVar(&c.ServiceCIDR, "service-cidr", "10.96.0.0/12", "Range of the service IPs (file output only, the API allocates them)")
+	flags.StringVar(&c.PodCIDR, "pod-cidr", "10.244.0.0/16", "Range of the endpoint IPs")
+
+	flags.StringVar(&c.MetricsURL, "metrics-url", "", "kpng metrics to scrape (ie: http://127.0.0.1:9099/metrics, see --exportMetrics)")
+	flags.StringVar(&c.MetricsPrefix, "metrics-prefix", "kpng_", "Prefix of the scraped metrics to report")
+	flags.DurationVar(&c.ScrapeInterval, "scrape-interval", 10*time.Second, "Interval of the stats and metrics reports")
+}
+
+// Service is a synthetic service.
+type Service struct {
+	Namespace string
+	Name      string
+	// ClusterIP is only set for the targets not allocating it.
+	ClusterIP string
+	Port      int32
+	Endpoints []string
+}
+
+// Target receives the load.
+type Target interface {
+	Create(ctx context.Context, svc *Service) error
+	Update(ctx context.Context, svc *Service) error
+	Delete(ctx context.Context, svc *Service) error
+	// Flush is called every second, for targets batching the changes.
+	Flush(ctx context.Context) error
+}
+
+// Stats counts the changes sent to the target.
+type Stats struct {
+	Creates, Updates, Deletes, Errors uint64
+}
+
+func (s *Stats) String() string {
+	return fmt.Sprintf("creates=%d updates=%d deletes=%d errors=%d",
+		atomic.LoadUint64(&s.Creates), atomic.LoadUint64(&s.Updates),
+		atomic.LoadUint64(&s.Deletes), atomic.LoadUint64(&s.Errors))
+}
+
+type Job struct {
+	Config *Config
+	Target Target
+
+	Stats Stats
+
+	rng      *rand.Rand
+	services []*Service
+	podIPs   *ipRange
+}
+
+func (j *Job) Run(ctx context.Context) (err error) {
+	cfg := j.Config
+
+	if cfg.Services <= 0 || cfg.CreateRate <= 0 || cfg.ChurnRate <= 0 {
+		return fmt.Errorf("services, create rate and churn rate must be positive")
+	}
+
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	j.rng = rand.New(rand.NewSource(seed))
+
+	j.podIPs, err = newIPRange(cfg.PodCIDR)
+	if err != nil {
+		return
+	}
+	serviceIPs, err := newIPRange(cfg.ServiceCIDR)
+	if err != nil {
+		return
+	}
+
+	j.services = make([]*Service, cfg.Services)
+	for i := range j.services {
+		j.services[i] = &Service{
+			Namespace: cfg.Namespace,
+			Name:      fmt.Sprintf("loadgen-%d", i),
+			ClusterIP: serviceIPs.next().String(),
+			Port:      80,
+		}
+	}
+
+	klog.Infof("load: %d services with %d endpoints, seed %d", cfg.Services, cfg.EndpointsPerService, seed)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go j.flushLoop(ctx)
+	go j.report(ctx)
+
+	defer func() {
+		if cfg.Cleanup {
+			j.cleanup()
+		}
+		klog.Info("load done: ", j.Stats.String())
+	}()
+
+	// create the services
+	limiter := rate.NewLimiter(rate.Limit(cfg.CreateRate), 1)
+	for _, svc := range j.services {
+		if limiter.Wait(ctx) != nil {
+			return nil
+		}
+		j.create(ctx, svc)
+	}
+
+	klog.Info("all services created: ", j.Stats.String())
+
+	// churn
+	churnCtx := ctx
+	if cfg.Duration != 0 {
+		var cancelChurn context.CancelFunc
+		churnCtx, cancelChurn = context.WithTimeout(ctx, cfg.Duration)
+		defer cancelChurn()
+	}
+
+	limiter = rate.NewLimiter(rate.Limit(cfg.ChurnRate), 1)
+	for limiter.Wait(churnCtx) == nil {
+		j.change(churnCtx)
+	}
+
+	return nil
+}
+
+func (j *Job) change(ctx context.Context) {
+	svc := j.services[j.rng.Intn(len(j.services))]
+
+	switch {
+	case svc.Endpoints == nil:
+		j.create(ctx, svc)
+
+	case j.rng.Float64() < j.Config.UpdateRatio:
+		// replace an endpoint
+		svc.Endpoints[j.rng.Intn(len(svc.Endpoints))] = j.podIPs.next().String()
+		j.count(&j.Stats.Updates, j.Target.Update(ctx, svc))
+
+	default:
+		j.count(&j.Stats.Deletes, j.Target.Delete(ctx, svc))
+		svc.Endpoints = nil
+	}
+}
+
+func (j *Job) create(ctx context.Context, svc *Service) {
+	svc.Endpoints = make([]string, j.Config.EndpointsPerService)
+	for i := range svc.Endpoints {
+		svc.Endpoints[i] = j.podIPs.next().String()
+	}
+
+	j.count(&j.Stats.Creates, j.Target.Create(ctx, svc))
+}
+
+func (j *Job) count(counter *uint64, err error) {
+	if err != nil {
+		if atomic.AddUint64(&j.Stats.Errors, 1) <= 10 {
+			klog.Error("load: ", err)
+		}
+		return
+	}
+	atomic.AddUint64(counter, 1)
+}
+
+func (j *Job) cleanup() {
+	klog.Info("deleting the services")
+
+	ctx := context.Background()
+	for _, svc := range j.services {
+		if svc.Endpoints == nil {
+			continue
+		}
+		j.count(&j.Stats.Deletes, j.Target.Delete(ctx, svc))
+		svc.Endpoints = nil
+	}
+
+	if err := j.Target.Flush(ctx); err != nil {
+		klog.Error("load: ", err)
+	}
+}
+
+func (j *Job) flushLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := j.Target.Flush(ctx); err != nil && ctx.Err() == nil {
+			klog.Error("load: ", err)
+		}
+	}
+}
+
+func (j *Job) report(ctx context.Context) {
+	if j.Config.ScrapeInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(j.Config.ScrapeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		klog.Info("load: ", j.Stats.String())
+
+		if j.Config.MetricsURL == "" {
+			continue
+		}
+
+		samples, err := scrape(ctx, j.Config.MetricsURL, j.Config.MetricsPrefix)
+		if err != nil {
+			klog.Error("failed to scrape metrics: ", err)
+			continue
+		}
+		for _, sample := range samples {
+			klog.Info("metric: ", sample)
+		}
+	}
+}
+
+// ipRange gives the IPs of a CIDR in sequence, wrapping around at the end.
+type ipRange struct {
+	base net.IP
+	size uint64
+	n    uint64
+}
+
+func newIPRange(cidr string) (*ipRange, error) {
+	ip, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		ipNet.IP = ip4
+	}
+
+	ones, bits := ipNet.Mask.Size()
+	hostBits := bits - ones
+	if hostBits > 32 {
+		hostBits = 32
+	}
+	if hostBits < 2 {
+		return nil, fmt.Errorf("range too small: %s", cidr)
+	}
+
+	// skip the network address and the broadcast address
+	return &ipRange{base: ipNet.IP, size: (uint64(1) << hostBits) - 2}, nil
+}
+
+func (r *ipRange) next() net.IP {
+	offset := r.n%r.size + 1
+	r.n++
+
+	ip := make(net.IP, len(r.base))
+	copy(ip, r.base)
+
+	for i := len(ip) - 1; i >= 0 && offset != 0; i-- {
+		sum := uint64(ip[i]) + offset&0xff
+		ip[i] = byte(sum)
+		offset = offset>>8 + sum>>8
+	}
+	return ip
+}
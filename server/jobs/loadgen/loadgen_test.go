@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadgen
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"sigs.k8s.io/kpng/server/jobs/store2file"
+)
+
+func testConfig() *Config {
+	return &Config{
+		Services:            20,
+		EndpointsPerService: 3,
+		CreateRate:          10000,
+		ChurnRate:           10000,
+		UpdateRatio:         0.5,
+		Duration:            100 * time.Millisecond,
+		Seed:                1,
+		Namespace:           "load",
+		ServiceCIDR:         "10.96.0.0/12",
+		PodCIDR:             "10.244.0.0/16",
+		ScrapeInterval:      time.Minute,
+	}
+}
+
+func TestIPRange(t *testing.T) {
+	r, err := newIPRange("10.0.0.0/30")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ips := []string{}
+	for i := 0; i < 3; i++ {
+		ips = append(ips, r.next().String())
+	}
+
+	if got := strings.Join(ips, ","); got != "10.0.0.1,10.0.0.2,10.0.0.1" {
+		t.Errorf("unexpected IPs: %s", got)
+	}
+
+	r, err = newIPRange("fd00::/64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.n = 255
+	if ip := r.next().String(); ip != "fd00::100" {
+		t.Errorf("unexpected IP: %s", ip)
+	}
+}
+
+func TestKubeTarget(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	job := &Job{Config: testConfig(), Target: &KubeTarget{Client: client}}
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if job.Stats.Errors != 0 || job.Stats.Updates == 0 || job.Stats.Deletes == 0 {
+		t.Errorf("unexpected stats: %s", job.Stats.String())
+	}
+
+	present := 0
+	for _, svc := range job.services {
+		if svc.Endpoints != nil {
+			present++
+		}
+	}
+
+	ctx := context.Background()
+	services, _ := client.CoreV1().Services("load").List(ctx, metav1.ListOptions{})
+	slices, _ := client.DiscoveryV1().EndpointSlices("load").List(ctx, metav1.ListOptions{})
+
+	if len(services.Items) != present || len(slices.Items) != present {
+		t.Errorf("expected %d services and slices, got %d and %d", present, len(services.Items), len(slices.Items))
+	}
+}
+
+func TestFileTarget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.yaml")
+
+	cfg := testConfig()
+	cfg.Cleanup = true
+
+	target := &FileTarget{Path: path}
+	job := &Job{Config: cfg, Target: target}
+
+	svc := &Service{Namespace: "load", Name: "a", ClusterIP: "10.96.0.1", Port: 80, Endpoints: []string{"10.244.0.1"}}
+	target.Create(context.Background(), svc)
+	if err := target.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	state := readState(t, path)
+	if len(state.Services) != 1 || state.Services[0].Service.Name != "a" || len(state.Services[0].Endpoints) != 1 {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+
+	target.Delete(context.Background(), svc)
+
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if state := readState(t, path); len(state.Services) != 0 {
+		t.Errorf("expected the services to be deleted, got %d", len(state.Services))
+	}
+}
+
+func readState(t *testing.T, path string) *store2file.GlobalState {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := &store2file.GlobalState{}
+	if err := yaml.UnmarshalStrict(data, state); err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func TestFilterSamples(t *testing.T) {
+	input := `# HELP kpng_k8s_api_events_total events
+# TYPE kpng_k8s_api_events_total counter
+kpng_k8s_api_events_total{event="add"} 12
+go_goroutines 42
+`
+	samples, err := filterSamples(strings.NewReader(input), "kpng_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 1 || samples[0] != `kpng_k8s_api_events_total{event="add"} 12` {
+		t.Errorf("unexpected samples: %q", samples)
+	}
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadgen
+
+import (
+	"context"
+	"net"
+	"os"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+
+	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/server/jobs/store2file"
+)
+
+// ManagedBy is the endpointslice.kubernetes.io/managed-by label of the slices created by the load, so
+// the endpoint slice controller leaves them alone.
+const ManagedBy = "kpng-loadgen"
+
+// KubeTarget sends the load to the kube API, as selector-less services with their endpoint slices.
+type KubeTarget struct {
+	Client kubernetes.Interface
+}
+
+var _ Target = &KubeTarget{}
+
+func (t *KubeTarget) Create(ctx context.Context, svc *Service) error {
+	_, err := t.Client.CoreV1().Namespaces().Create(ctx, &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: svc.Namespace},
+	}, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	_, err = t.Client.CoreV1().Services(svc.Namespace).Create(ctx, &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: svc.Name},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Name:       "http",
+				Protocol:   v1.ProtocolTCP,
+				Port:       svc.Port,
+				TargetPort: intstr.FromInt(int(svc.Port)),
+			}},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+
+	_, err = t.Client.DiscoveryV1().EndpointSlices(svc.Namespace).Create(ctx, t.slice(svc), metav1.CreateOptions{})
+	return err
+}
+
+func (t *KubeTarget) Update(ctx context.Context, svc *Service) error {
+	_, err := t.Client.DiscoveryV1().EndpointSlices(svc.Namespace).Update(ctx, t.slice(svc), metav1.UpdateOptions{})
+	return err
+}
+
+func (t *KubeTarget) Delete(ctx context.Context, svc *Service) error {
+	err := t.Client.DiscoveryV1().EndpointSlices(svc.Namespace).Delete(ctx, svc.Name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	return t.Client.CoreV1().Services(svc.Namespace).Delete(ctx, svc.Name, metav1.DeleteOptions{})
+}
+
+func (t *KubeTarget) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (t *KubeTarget) slice(svc *Service) *discovery.EndpointSlice {
+	ready := true
+	name := "http"
+	protocol := v1.ProtocolTCP
+
+	slice := &discovery.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: svc.Name,
+			Labels: map[string]string{
+				discovery.LabelServiceName: svc.Name,
+				discovery.LabelManagedBy:   ManagedBy,
+			},
+		},
+		AddressType: discovery.AddressTypeIPv4,
+		Ports:       []discovery.EndpointPort{{Name: &name, Protocol: &protocol, Port: &svc.Port}},
+	}
+
+	if len(svc.Endpoints) != 0 && isIPv6(svc.Endpoints[0]) {
+		slice.AddressType = discovery.AddressTypeIPv6
+	}
+
+	for _, ip := range svc.Endpoints {
+		slice.Endpoints = append(slice.Endpoints, discovery.Endpoint{
+			Addresses:  []string{ip},
+			Conditions: discovery.EndpointConditions{Ready: &ready},
+		})
+	}
+
+	return slice
+}
+
+// FileTarget sends the load to a file in the format of the `kpng file` source, rewritten every
+// second if it changed.
+type FileTarget struct {
+	Path string
+
+	mu       sync.Mutex
+	services map[string]store2file.ServiceAndEndpoints
+	changed  bool
+}
+
+var _ Target = &FileTarget{}
+
+func (t *FileTarget) set(svc *Service) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.services == nil {
+		t.services = map[string]store2file.ServiceAndEndpoints{}
+	}
+
+	sae := store2file.ServiceAndEndpoints{
+		Service: &localv1.Service{
+			Namespace: svc.Namespace,
+			Name:      svc.Name,
+			Type:      "ClusterIP",
+			IPs: &localv1.ServiceIPs{
+				ClusterIPs: ipSet(svc.ClusterIP),
+			},
+			Ports: []*localv1.PortMapping{{
+				Name:       "http",
+				Protocol:   localv1.Protocol_TCP,
+				Port:       svc.Port,
+				TargetPort: svc.Port,
+			}},
+		},
+	}
+
+	for _, ip := range svc.Endpoints {
+		sae.Endpoints = append(sae.Endpoints, &globalv1.EndpointInfo{
+			Endpoint: &localv1.Endpoint{IPs: ipSet(ip)},
+		})
+	}
+
+	t.services[svc.Namespace+"/"+svc.Name] = sae
+	t.changed = true
+}
+
+func (t *FileTarget) Create(_ context.Context, svc *Service) error {
+	t.set(svc)
+	return nil
+}
+
+func (t *FileTarget) Update(_ context.Context, svc *Service) error {
+	t.set(svc)
+	return nil
+}
+
+func (t *FileTarget) Delete(_ context.Context, svc *Service) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.services, svc.Namespace+"/"+svc.Name)
+	t.changed = true
+	return nil
+}
+
+func (t *FileTarget) Flush(_ context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.changed {
+		return nil
+	}
+
+	keys := make([]string, 0, len(t.services))
+	for key := range t.services {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	state := store2file.GlobalState{}
+	for _, key := range keys {
+		state.Services = append(state.Services, t.services[key])
+	}
+
+	data, err := yaml.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	// write then rename, so the source never reads a partial file
+	tmp := t.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, t.Path); err != nil {
+		return err
+	}
+
+	t.changed = false
+	return nil
+}
+
+func isIPv6(ip string) bool {
+	return net.ParseIP(ip).To4() == nil
+}
+
+func ipSet(ip string) *localv1.IPSet {
+	if isIPv6(ip) {
+		return &localv1.IPSet{V6: []string{ip}}
+	}
+	return &localv1.IPSet{V4: []string{ip}}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/api/localv1"
+)
+
+// check is the health check of a service, parsed from kube2store.AnnotationHealthCheck:
+// <tcp|http>[:<port>][/<path>] (ie: "tcp", "tcp:8080", "http:8080/healthz"). Without a port,
+// the target port of the first TCP port of the service is checked.
+type check struct {
+	http bool
+	port int32
+	path string
+}
+
+func parseCheck(value string) (c check, err error) {
+	head, path, hasPath := strings.Cut(strings.TrimSpace(value), "/")
+	proto, portStr, hasPort := strings.Cut(head, ":")
+
+	switch proto {
+	case "tcp":
+		if hasPath {
+			return c, fmt.Errorf("invalid health check %q: a tcp check has no path", value)
+		}
+	case "http":
+		c.http = true
+		c.path = "/" + path
+	default:
+		return c, fmt.Errorf("invalid health check %q: unknown protocol %q (expected tcp or http)", value, proto)
+	}
+
+	if hasPort {
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil || port == 0 {
+			return c, fmt.Errorf("invalid health check %q: invalid port %q", value, portStr)
+		}
+		c.port = int32(port)
+	}
+
+	return
+}
+
+// address returns the address to check for an endpoint, or "" if there's none.
+func (c check) address(svc *localv1.Service, ei *globalv1.EndpointInfo) string {
+	ep := ei.Endpoint
+	if ep == nil || ep.IPs == nil || ep.IPs.IsEmpty() {
+		return ""
+	}
+
+	port := c.port
+	if port == 0 {
+		for _, p := range svc.Ports {
+			if p.Protocol == localv1.Protocol_TCP {
+				port = ep.PortMapping(p)
+				break
+			}
+		}
+	}
+	if port == 0 {
+		return ""
+	}
+
+	return net.JoinHostPort(ep.IPs.First(), strconv.Itoa(int(port)))
+}
+
+// probe checks the address, returning nil if it's healthy. An HTTP check is healthy if it gets
+// a 2xx or 3xx response.
+func (c check) probe(ctx context.Context, addr string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if !c.http {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+addr+c.path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("unhealthy status: %s", resp.Status)
+	}
+	return nil
+}
+
+var httpClient = &http.Client{
+	// a redirect is a healthy response, don't follow it
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+	Transport: &http.Transport{
+		DisableKeepAlives: true,
+	},
+}
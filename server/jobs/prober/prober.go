@@ -0,0 +1,380 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package prober actively health checks the endpoints of the services annotated with
+// kube2store.AnnotationHealthCheck, and masks the failing ones (as not ready) in the state fed to
+// the backends. It detects failures faster than the kubelet's readiness probes when configured
+// with a shorter interval.
+package prober
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/server/jobs/kube2store"
+	"sigs.k8s.io/kpng/server/proxystore"
+	"sigs.k8s.io/kpng/server/serde"
+)
+
+type Config struct {
+	// Interval between the checks of an endpoint (0 disables the health checking).
+	Interval time.Duration
+	// Timeout of a check.
+	Timeout time.Duration
+	// FailureThreshold is the number of consecutive failed checks masking an endpoint.
+	FailureThreshold int
+	// SuccessThreshold is the number of consecutive successful checks unmasking an endpoint.
+	SuccessThreshold int
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.DurationVar(&c.Interval, "health-check-interval", 0, "interval between the checks of the endpoints of the services annotated with "+kube2store.AnnotationHealthCheck+" (0 to disable)")
+	flags.DurationVar(&c.Timeout, "health-check-timeout", time.Second, "timeout of an endpoint health check")
+	flags.IntVar(&c.FailureThreshold, "health-check-failure-threshold", 3, "consecutive failed checks masking an endpoint")
+	flags.IntVar(&c.SuccessThreshold, "health-check-success-threshold", 1, "consecutive successful checks unmasking an endpoint")
+}
+
+func (c *Config) Enabled() bool {
+	return c.Interval > 0
+}
+
+// Job copies the Source into the Store, with the endpoints failing their health check marked
+// as not ready. If all the checked endpoints of a service fail, none is masked (the checks
+// themselves are more likely to be broken).
+type Job struct {
+	Source *proxystore.Store
+	Store  *proxystore.Store
+	Config *Config
+
+	health map[string]*health
+}
+
+// health is the state of a checked endpoint.
+type health struct {
+	failures  int
+	successes int
+	failing   bool
+	seen      bool
+}
+
+// target is an endpoint to check.
+type target struct {
+	check check
+	addr  string
+}
+
+// key identifies a target across endpoint updates.
+func (t target) key(namespace, service string) string {
+	scheme := "tcp"
+	if t.check.http {
+		scheme = "http"
+	}
+	return namespace + "/" + service + " " + scheme + "://" + t.addr + t.check.path
+}
+
+type snapshot struct {
+	synced bool
+	kvs    []*proxystore.KV
+}
+
+func (j *Job) Run(ctx context.Context) {
+	defer j.Store.Close()
+
+	var (
+		mu      sync.Mutex
+		snap    = &snapshot{}
+		changed = make(chan struct{}, 1)
+	)
+
+	go func() {
+		var (
+			rev    uint64
+			closed bool
+		)
+
+		for {
+			var s *snapshot
+			rev, closed = j.Source.View(rev, func(tx *proxystore.Tx) {
+				s = takeSnapshot(tx)
+			})
+
+			if closed {
+				return
+			}
+
+			mu.Lock()
+			snap = s
+			mu.Unlock()
+
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(j.Config.Interval)
+	defer ticker.Stop()
+
+	for {
+		mustUpdate := false
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+			mustUpdate = true
+		case <-ticker.C:
+		}
+
+		mu.Lock()
+		s := snap
+		mu.Unlock()
+
+		if j.probe(ctx, s) || mustUpdate {
+			j.update(s)
+		}
+	}
+}
+
+func takeSnapshot(tx *proxystore.Tx) *snapshot {
+	snap := &snapshot{synced: tx.AllSynced()}
+
+	for _, set := range proxystore.AllSets {
+		tx.Each(set, func(kv *proxystore.KV) bool {
+			snap.kvs = append(snap.kvs, kv)
+			return true
+		})
+	}
+
+	return snap
+}
+
+// targets returns the target of each endpoint to check, by target key. The endpoints of the
+// services with an invalid health check are not checked.
+func targets(snap *snapshot) (byKey map[string]target, ofEndpoint map[*globalv1.EndpointInfo]string) {
+	checks := map[string]check{}
+	services := map[string]*localv1.Service{}
+
+	for _, kv := range snap.kvs {
+		if kv.Set != proxystore.Services {
+			continue
+		}
+
+		svc := kv.Service.Service
+		value, ok := svc.Annotations[kube2store.AnnotationHealthCheck]
+		if !ok {
+			continue
+		}
+
+		c, err := parseCheck(value)
+		if err != nil {
+			klog.Warningf("service %s/%s: %v", svc.Namespace, svc.Name, err)
+			continue
+		}
+
+		fullName := svc.Namespace + "/" + svc.Name
+		checks[fullName] = c
+		services[fullName] = svc
+	}
+
+	byKey = map[string]target{}
+	ofEndpoint = map[*globalv1.EndpointInfo]string{}
+
+	for _, kv := range snap.kvs {
+		if kv.Set != proxystore.Endpoints {
+			continue
+		}
+
+		ei := kv.Endpoint
+		fullName := ei.Namespace + "/" + ei.ServiceName
+
+		c, ok := checks[fullName]
+		if !ok || ei.Conditions == nil || !ei.Conditions.Ready {
+			continue
+		}
+
+		t := target{check: c, addr: c.address(services[fullName], ei)}
+		if t.addr == "" {
+			continue
+		}
+
+		key := t.key(ei.Namespace, ei.ServiceName)
+		byKey[key] = t
+		ofEndpoint[ei] = key
+	}
+
+	return
+}
+
+// probe checks every target once, returning true if an endpoint started or stopped failing.
+func (j *Job) probe(ctx context.Context, snap *snapshot) (changed bool) {
+	if j.health == nil {
+		j.health = map[string]*health{}
+	}
+
+	byKey, _ := targets(snap)
+
+	type result struct {
+		key string
+		err error
+	}
+
+	results := make(chan result, len(byKey))
+	for key, t := range byKey {
+		go func(key string, t target) {
+			results <- result{key, t.check.probe(ctx, t.addr, j.Config.Timeout)}
+		}(key, t)
+	}
+
+	for range byKey {
+		r := <-results
+
+		h := j.health[r.key]
+		if h == nil {
+			h = &health{}
+			j.health[r.key] = h
+		}
+		h.seen = true
+
+		if r.err == nil {
+			h.failures = 0
+			h.successes++
+
+			if h.failing && h.successes >= j.Config.SuccessThreshold {
+				klog.Infof("health check %s: passing again", r.key)
+				h.failing = false
+				changed = true
+			}
+			continue
+		}
+
+		h.successes = 0
+		h.failures++
+		klog.V(2).Infof("health check %s failed: %v", r.key, r.err)
+
+		if !h.failing && h.failures >= j.Config.FailureThreshold {
+			klog.Infof("health check %s: failing (%v), masking the endpoint", r.key, r.err)
+			h.failing = true
+			changed = true
+		}
+	}
+
+	// forget the endpoints gone
+	for key, h := range j.health {
+		if !h.seen {
+			delete(j.health, key)
+			if h.failing {
+				changed = true
+			}
+			continue
+		}
+		h.seen = false
+	}
+
+	return
+}
+
+// masked returns the endpoints to mark as not ready.
+func (j *Job) masked(snap *snapshot) map[*globalv1.EndpointInfo]bool {
+	_, ofEndpoint := targets(snap)
+
+	// per service, are all the checked endpoints failing?
+	allFailing := map[string]bool{}
+	for ei, key := range ofEndpoint {
+		fullName := ei.Namespace + "/" + ei.ServiceName
+
+		failing := j.health[key] != nil && j.health[key].failing
+		if all, ok := allFailing[fullName]; ok {
+			allFailing[fullName] = all && failing
+		} else {
+			allFailing[fullName] = failing
+		}
+	}
+
+	masked := map[*globalv1.EndpointInfo]bool{}
+	for ei, key := range ofEndpoint {
+		if h := j.health[key]; h != nil && h.failing && !allFailing[ei.Namespace+"/"+ei.ServiceName] {
+			masked[ei] = true
+		}
+	}
+
+	return masked
+}
+
+func (j *Job) update(snap *snapshot) {
+	masked := j.masked(snap)
+
+	j.Store.Update(func(tx *proxystore.Tx) {
+		desired := map[proxystore.Set]map[string]bool{}
+		for _, set := range proxystore.AllSets {
+			desired[set] = map[string]bool{}
+		}
+
+		for _, kv := range snap.kvs {
+			path := kv.Path()
+			desired[kv.Set][path] = true
+
+			if kv.Set == proxystore.Endpoints && masked[kv.Endpoint] {
+				tx.SetRaw(kv.Set, path, notReady(kv.Endpoint))
+				continue
+			}
+
+			tx.SetRaw(kv.Set, path, kv.Value)
+		}
+
+		for _, set := range proxystore.AllSets {
+			toDel := make([]string, 0)
+			tx.Each(set, func(kv *proxystore.KV) bool {
+				if path := kv.Path(); !desired[set][path] {
+					toDel = append(toDel, path)
+				}
+				return true
+			})
+
+			for _, path := range toDel {
+				tx.DelRaw(set, path)
+			}
+		}
+
+		if snap.synced {
+			for _, set := range proxystore.AllSets {
+				tx.SetSync(set)
+			}
+		}
+	})
+}
+
+// notReady returns a copy of the endpoint marked as not ready.
+func notReady(ei *globalv1.EndpointInfo) *globalv1.EndpointInfo {
+	ei = proto.Clone(ei).(*globalv1.EndpointInfo)
+	ei.Conditions = &globalv1.EndpointConditions{Ready: false}
+
+	ei.Hash = serde.Hash(&globalv1.EndpointInfo{
+		Endpoint:   ei.Endpoint,
+		Conditions: ei.Conditions,
+		Topology:   ei.Topology,
+	})
+
+	return ei
+}
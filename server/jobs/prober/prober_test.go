@@ -0,0 +1,158 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/server/jobs/kube2store"
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+func TestParseCheck(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		check check
+		err   bool
+	}{
+		{"tcp", check{}, false},
+		{"tcp:8080", check{port: 8080}, false},
+		{"http", check{http: true, path: "/"}, false},
+		{"http:8080/healthz", check{http: true, port: 8080, path: "/healthz"}, false},
+		{"tcp/healthz", check{}, true},
+		{"udp:53", check{}, true},
+		{"http:x/", check{}, true},
+		{"tcp:70000", check{}, true},
+	} {
+		c, err := parseCheck(tc.value)
+		if (err != nil) != tc.err {
+			t.Errorf("%q: unexpected error: %v", tc.value, err)
+			continue
+		}
+		if !tc.err && c != tc.check {
+			t.Errorf("%q: expected %+v, got %+v", tc.value, tc.check, c)
+		}
+	}
+}
+
+func TestJob(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	port := int32(l.Addr().(*net.TCPAddr).Port)
+
+	source := proxystore.New()
+	setEndpoints := func(ips ...string) {
+		source.Update(func(tx *proxystore.Tx) {
+			eis := make([]*globalv1.EndpointInfo, 0, len(ips))
+			for _, ip := range ips {
+				eis = append(eis, &globalv1.EndpointInfo{
+					Namespace:   "default",
+					SourceName:  "web-abcde",
+					ServiceName: "web",
+					Endpoint:    &localv1.Endpoint{IPs: &localv1.IPSet{V4: []string{ip}}},
+					Conditions:  &globalv1.EndpointConditions{Ready: true},
+				})
+			}
+			tx.SetEndpointsOfSource("default", "web-abcde", eis)
+		})
+	}
+
+	source.Update(func(tx *proxystore.Tx) {
+		tx.SetService(&localv1.Service{
+			Namespace:   "default",
+			Name:        "web",
+			Annotations: map[string]string{kube2store.AnnotationHealthCheck: "tcp"},
+			Ports:       []*localv1.PortMapping{{Protocol: localv1.Protocol_TCP, Port: 80, TargetPort: port}},
+		})
+
+		for _, set := range proxystore.AllSets {
+			tx.SetSync(set)
+		}
+	})
+
+	// 127.0.0.2 is not listening
+	setEndpoints("127.0.0.1", "127.0.0.2")
+
+	j := &Job{
+		Source: source,
+		Store:  proxystore.New(),
+		Config: &Config{Timeout: time.Second, FailureThreshold: 2, SuccessThreshold: 1},
+	}
+
+	ready := func() (ips []string) {
+		j.Store.View(0, func(tx *proxystore.Tx) {
+			tx.EachEndpointOfService("default", "web", func(ei *globalv1.EndpointInfo) {
+				if ei.Conditions.Ready {
+					ips = append(ips, ei.Endpoint.IPs.First())
+				}
+			})
+		})
+		sort.Strings(ips)
+		return
+	}
+
+	round := func() {
+		var snap *snapshot
+		source.View(0, func(tx *proxystore.Tx) { snap = takeSnapshot(tx) })
+
+		j.probe(context.Background(), snap)
+		j.update(snap)
+	}
+
+	for i, expected := range [][]string{
+		{"127.0.0.1", "127.0.0.2"}, // below the failure threshold
+		{"127.0.0.1"},
+		{"127.0.0.1"},
+	} {
+		round()
+
+		if ips := ready(); !equal(ips, expected) {
+			t.Errorf("round %d: expected %v ready, got %v", i, expected, ips)
+		}
+	}
+
+	// all checked endpoints failing: nothing is masked
+	setEndpoints("127.0.0.2", "127.0.0.3")
+	round()
+	round()
+
+	if ips := ready(); !equal(ips, []string{"127.0.0.2", "127.0.0.3"}) {
+		t.Errorf("expected all endpoints ready, got %v", ips)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package relay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"sigs.k8s.io/kpng/api/localv1"
+)
+
+// A recording starts with its magic and the start time of the stream (unix nanoseconds), followed
+// by its records: the offset of the message since the start (nanoseconds), its kind, the length of
+// the message and the message, marshaled.
+const magic = "kpngrec1"
+
+const (
+	kindRequest byte = 'r' // a WatchReq from the backend
+	kindOp      byte = 'o' // an OpItem from the server
+)
+
+// record is a message of a stream, received offset after its start.
+type record struct {
+	offset time.Duration
+	req    *localv1.WatchReq
+	op     *localv1.OpItem
+}
+
+// recorder writes the messages of a stream.
+type recorder struct {
+	mu    sync.Mutex
+	f     *os.File
+	w     *bufio.Writer
+	start time.Time
+	err   error
+}
+
+// createRecorder creates the recording of a new stream in dir, named stream-<n>.rec after the
+// first free n.
+func createRecorder(dir string, start time.Time) (*recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	for n := 1; ; n++ {
+		path := filepath.Join(dir, fmt.Sprintf("stream-%d.rec", n))
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		r := &recorder{f: f, w: bufio.NewWriter(f), start: start}
+
+		header := make([]byte, len(magic)+8)
+		copy(header, magic)
+		binary.BigEndian.PutUint64(header[len(magic):], uint64(start.UnixNano()))
+		r.write(header)
+
+		return r, r.err
+	}
+}
+
+func (r *recorder) Name() string { return r.f.Name() }
+
+// Request records a request of the backend.
+func (r *recorder) Request(req *localv1.WatchReq) { r.record(kindRequest, req) }
+
+// Op records an operation of the server. The records are flushed at the end of each change set.
+func (r *recorder) Op(op *localv1.OpItem) {
+	r.record(kindOp, op)
+
+	if _, ok := op.Op.(*localv1.OpItem_Sync); ok {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.err == nil {
+			r.err = r.w.Flush()
+		}
+	}
+}
+
+func (r *recorder) record(kind byte, msg proto.Message) {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		panic(err) // unexpected
+	}
+
+	header := make([]byte, 8+1+4)
+	binary.BigEndian.PutUint64(header, uint64(timeNow().Sub(r.start)))
+	header[8] = kind
+	binary.BigEndian.PutUint32(header[9:], uint32(len(data)))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.write(header)
+	r.write(data)
+}
+
+// write writes data, keeping the first error. Assumes r.mu is held.
+func (r *recorder) write(data []byte) {
+	if r.err == nil {
+		_, r.err = r.w.Write(data)
+	}
+}
+
+// Close flushes and closes the recording, returning the first error writing it.
+func (r *recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+	if err := r.f.Close(); r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// reader reads the records of a recording.
+type reader struct {
+	r     *bufio.Reader
+	start time.Time
+}
+
+func newReader(r io.Reader) (*reader, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(magic)+8)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("failed to read the header: %w", err)
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, errors.New("not a kpng recording")
+	}
+
+	start := time.Unix(0, int64(binary.BigEndian.Uint64(header[len(magic):])))
+	return &reader{r: br, start: start}, nil
+}
+
+// next returns the next record, or io.EOF at the end of the recording.
+func (r *reader) next() (rec record, err error) {
+	header := make([]byte, 8+1+4)
+	if _, err = io.ReadFull(r.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = errors.New("truncated record")
+		}
+		return
+	}
+
+	data := make([]byte, binary.BigEndian.Uint32(header[9:]))
+	if _, err = io.ReadFull(r.r, data); err != nil {
+		err = errors.New("truncated record")
+		return
+	}
+
+	rec.offset = time.Duration(binary.BigEndian.Uint64(header))
+
+	switch kind := header[8]; kind {
+	case kindRequest:
+		rec.req = &localv1.WatchReq{}
+		err = proto.Unmarshal(data, rec.req)
+	case kindOp:
+		rec.op = &localv1.OpItem{}
+		err = proto.Unmarshal(data, rec.op)
+	default:
+		err = fmt.Errorf("unknown record kind %q", kind)
+	}
+	return
+}
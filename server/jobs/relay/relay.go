@@ -0,0 +1,170 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package relay proxies the localv1 stream between the server and the backends, recording its
+// messages with their timing, and replays the recordings to the backends with the same timing, to
+// investigate the performance of a backend on a real change history.
+package relay
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/spf13/pflag"
+	"google.golang.org/grpc"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/tlsflags"
+	"sigs.k8s.io/kpng/server/pkg/apiwatch"
+	"sigs.k8s.io/kpng/server/pkg/server"
+)
+
+type Config struct {
+	// BindSpec is the listen spec the backends connect to.
+	BindSpec string
+	// Record is the directory the streams are recorded in (none if empty).
+	Record string
+	// Replay is the recording served to the backends instead of relaying the server.
+	Replay string
+	// Speed multiplies the pace of the replay (0 to replay as fast as the backend goes).
+	Speed float64
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&c.BindSpec, "listen", "tcp://127.0.0.1:12091", "listen spec of the relay, formatted as protocol://address")
+	flags.StringVar(&c.Record, "record", "", "record the relayed streams in this directory, one stream-<n>.rec file per stream")
+	flags.StringVar(&c.Replay, "replay", "", "replay this recording (a stream-<n>.rec file) to the backends instead of relaying the --api server")
+	flags.Float64Var(&c.Speed, "replay-speed", 1, "pace of the replay relative to the recording (0 to replay as fast as possible)")
+}
+
+type Job struct {
+	apiwatch.Watch
+	Config *Config
+}
+
+func New(cfg *Config) *Job {
+	return &Job{
+		Watch: apiwatch.Watch{
+			TLSFlags: &tlsflags.Flags{},
+		},
+		Config: cfg,
+	}
+}
+
+func (j *Job) Run(ctx context.Context) error {
+	if j.Config.Replay != "" && j.Config.Record != "" {
+		return errors.New("a replay can't be recorded")
+	}
+
+	lis := server.MustListen(j.Config.BindSpec)
+
+	srv := grpc.NewServer()
+	if j.Config.Replay != "" {
+		localv1.RegisterSetsServer(srv, &replayServer{path: j.Config.Replay, speed: j.Config.Speed})
+	} else {
+		localv1.RegisterSetsServer(srv, &relayServer{job: j})
+	}
+
+	go func() {
+		<-ctx.Done()
+		srv.Stop()
+	}()
+
+	return srv.Serve(lis)
+}
+
+// relayServer relays the streams of the backends to the server.
+type relayServer struct {
+	localv1.UnimplementedSetsServer
+	job *Job
+}
+
+func (s *relayServer) Watch(down localv1.Sets_WatchServer) (err error) {
+	ctx, cancel := context.WithCancel(down.Context())
+	defer cancel()
+
+	conn, err := s.job.Dial()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	up, err := localv1.NewSetsClient(conn).Watch(ctx)
+	if err != nil {
+		return
+	}
+
+	var rec *recorder
+	if s.job.Config.Record != "" {
+		rec, err = createRecorder(s.job.Config.Record, timeNow())
+		if err != nil {
+			return
+		}
+		klog.Info("recording a stream in ", rec.Name())
+
+		defer func() {
+			if err := rec.Close(); err != nil {
+				klog.Error("failed to record the stream in ", rec.Name(), ": ", err)
+			}
+		}()
+	}
+
+	errs := make(chan error, 2)
+
+	// the requests of the backend
+	go func() {
+		for {
+			req, err := down.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if rec != nil {
+				rec.Request(req)
+			}
+			if err = up.Send(req); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	// the operations of the server
+	go func() {
+		for {
+			op, err := up.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if rec != nil {
+				rec.Op(op)
+			}
+			if err = down.Send(op); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	err = <-errs
+	if err == io.EOF {
+		err = nil
+	}
+	return
+}
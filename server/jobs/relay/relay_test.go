@@ -0,0 +1,137 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package relay
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"sigs.k8s.io/kpng/api/localv1"
+)
+
+// fakeStream is a backend stream sending requests and recording the operations it receives.
+type fakeStream struct {
+	grpc.ServerStream
+	reqs int
+	ops  []string
+	now  func() time.Time
+}
+
+func (s *fakeStream) Context() context.Context { return context.Background() }
+
+func (s *fakeStream) Recv() (*localv1.WatchReq, error) {
+	if s.reqs == 0 {
+		return nil, io.EOF
+	}
+	s.reqs--
+	return &localv1.WatchReq{NodeName: "node"}, nil
+}
+
+func (s *fakeStream) Send(op *localv1.OpItem) error {
+	s.ops = append(s.ops, s.now().Format("15:04:05")+" "+opString(op))
+	return nil
+}
+
+func opString(op *localv1.OpItem) string {
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Set:
+		return "set " + v.Set.Ref.Path
+	case *localv1.OpItem_Delete:
+		return "del " + v.Delete.Path
+	case *localv1.OpItem_Sync:
+		return "sync"
+	}
+	return "reset"
+}
+
+func TestRecordReplay(t *testing.T) {
+	clock := time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)
+	defer func(now func() time.Time, sleep func(time.Duration)) { timeNow, timeSleep = now, sleep }(timeNow, timeSleep)
+	timeNow = func() time.Time { return clock }
+	timeSleep = func(d time.Duration) { clock = clock.Add(d) }
+
+	dir := t.TempDir()
+
+	rec, err := createRecorder(dir, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := &localv1.OpItem{Op: &localv1.OpItem_Set{Set: &localv1.Value{Ref: &localv1.Ref{Set: localv1.Set_ServicesSet, Path: "ns/svc"}}}}
+	del := &localv1.OpItem{Op: &localv1.OpItem_Delete{Delete: &localv1.Ref{Set: localv1.Set_ServicesSet, Path: "ns/svc"}}}
+	sync := &localv1.OpItem{Op: &localv1.OpItem_Sync{Sync: &localv1.EmptyOp{}}}
+
+	rec.Request(&localv1.WatchReq{NodeName: "node"})
+	clock = clock.Add(time.Second)
+	rec.Op(set)
+	rec.Op(sync)
+	rec.Request(&localv1.WatchReq{NodeName: "node"})
+	clock = clock.Add(10 * time.Second)
+	rec.Op(del)
+	rec.Op(sync)
+
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the next stream doesn't overwrite it
+	rec2, err := createRecorder(dir, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec2.Close()
+	if rec2.Name() != filepath.Join(dir, "stream-2.rec") {
+		t.Errorf("second stream recorded in %s", rec2.Name())
+	}
+
+	for _, tc := range []struct {
+		speed    float64
+		expected []string
+	}{
+		{1, []string{"10:00:01 set ns/svc", "10:00:01 sync", "10:00:11 del ns/svc", "10:00:11 sync"}},
+		{2, []string{"10:00:00 set ns/svc", "10:00:00 sync", "10:00:05 del ns/svc", "10:00:05 sync"}},
+		{0, []string{"10:00:00 set ns/svc", "10:00:00 sync", "10:00:00 del ns/svc", "10:00:00 sync"}},
+	} {
+		clock = time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)
+
+		stream := &fakeStream{reqs: 2, now: timeNow}
+		srv := &replayServer{path: rec.Name(), speed: tc.speed}
+		if err := srv.Watch(stream); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(stream.ops, tc.expected) {
+			t.Errorf("speed %v: replayed\n%q\nexpected\n%q", tc.speed, stream.ops, tc.expected)
+		}
+	}
+
+	// the replay waits for the requests of the backend
+	stream := &fakeStream{reqs: 1, now: timeNow}
+	if err := (&replayServer{path: rec.Name()}).Watch(stream); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+	if len(stream.ops) != 2 {
+		t.Errorf("expected the first change set only, got %q", stream.ops)
+	}
+
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package relay
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/kpng/api/localv1"
+)
+
+var (
+	timeNow   = time.Now
+	timeSleep = time.Sleep
+)
+
+// replayServer serves a recording to the backends.
+type replayServer struct {
+	localv1.UnimplementedSetsServer
+	path  string
+	speed float64
+}
+
+// Watch sends the recorded operations to the backend, waiting for its requests where they were
+// recorded. An operation is not sent before its offset in the recording (divided by the speed)
+// since the start of the stream, but late operations are not skipped: a slower backend delays the
+// rest of the replay.
+func (s *replayServer) Watch(down localv1.Sets_WatchServer) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := newReader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.path, err)
+	}
+
+	klog.Info("replaying ", s.path, ", recorded at ", r.start)
+	start := timeNow()
+
+	for n := 0; ; n++ {
+		rec, err := r.next()
+		if err == io.EOF {
+			klog.Info("replayed ", n, " messages in ", timeNow().Sub(start))
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", s.path, err)
+		}
+
+		if rec.req != nil {
+			if _, err := down.Recv(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if s.speed > 0 {
+			at := start.Add(time.Duration(float64(rec.offset) / s.speed))
+			if wait := at.Sub(timeNow()); wait > 0 {
+				timeSleep(wait)
+			}
+		}
+
+		if err := down.Send(rec.op); err != nil {
+			return err
+		}
+	}
+}
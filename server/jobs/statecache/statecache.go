@@ -0,0 +1,250 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package statecache persists the global state of the store to a local file, so a restarted
+// server can serve the backends the last known state while its informers resync, instead of
+// waiting for them.
+//
+// The file is a sequence of localv1.Value messages, each prefixed by its length as a varint,
+// holding the set, the path and the marshaled value of an entry of the store.
+package statecache
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+type Config struct {
+	// Path is the file of the state (disabled if empty)
+	Path string
+	// Interval is the minimum interval between writes
+	Interval time.Duration
+	// MaxAge is the age after which a state is not restored
+	MaxAge time.Duration
+}
+
+func (c *Config) BindFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&c.Path, "state-cache", "", "Persist the global state to this file, and serve it on restart while the informers resync (disabled if empty)")
+	flags.DurationVar(&c.Interval, "state-cache-interval", 30*time.Second, "Minimum interval between writes of the state cache")
+	flags.DurationVar(&c.MaxAge, "state-cache-max-age", time.Hour, "Don't restore a state cache older than this (0 to always restore it)")
+}
+
+func (c *Config) Enabled() bool {
+	return c.Path != ""
+}
+
+// Restore loads the state cache in the store, if it exists and is recent enough.
+func Restore(store *proxystore.Store, cfg *Config) error {
+	stat, err := os.Stat(cfg.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if age := time.Since(stat.ModTime()); cfg.MaxAge != 0 && age > cfg.MaxAge {
+		klog.Info("not restoring the state cache, too old (", age.Truncate(time.Second), ")")
+		return nil
+	}
+
+	f, err := os.Open(cfg.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	values, err := read(bufio.NewReader(f))
+	if err != nil {
+		return fmt.Errorf("invalid state cache %s: %w", cfg.Path, err)
+	}
+
+	store.Update(func(tx *proxystore.Tx) {
+		for _, v := range values {
+			tx.Restore(v.set, v.path, v.value)
+		}
+	})
+
+	klog.Info("restored ", len(values), " entries from the state cache")
+	return nil
+}
+
+type entry struct {
+	set   proxystore.Set
+	path  string
+	value proxystore.Hashed
+}
+
+func read(r *bufio.Reader) (entries []entry, err error) {
+	buf := []byte{}
+
+	for {
+		var size uint64
+		size, err = binary.ReadUvarint(r)
+		if err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return
+		}
+
+		if uint64(cap(buf)) < size {
+			buf = make([]byte, size)
+		}
+		buf = buf[:size]
+
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return
+		}
+
+		v := &localv1.Value{}
+		if err = proto.Unmarshal(buf, v); err != nil {
+			return
+		}
+
+		var value interface {
+			proxystore.Hashed
+			proto.Message
+		}
+
+		switch v.Ref.GetSet() {
+		case proxystore.Services:
+			value = &globalv1.ServiceInfo{}
+		case proxystore.Endpoints:
+			value = &globalv1.EndpointInfo{}
+		case proxystore.Nodes:
+			value = &globalv1.NodeInfo{}
+		default:
+			return nil, fmt.Errorf("unknown set %v", v.Ref.GetSet())
+		}
+
+		if err = proto.Unmarshal(v.Bytes, value); err != nil {
+			return
+		}
+
+		entries = append(entries, entry{set: v.Ref.Set, path: v.Ref.Path, value: value})
+	}
+}
+
+// Job writes the state of the store to the state cache when it changes, at most every interval.
+type Job struct {
+	Store  *proxystore.Store
+	Config *Config
+}
+
+func (j *Job) Run(ctx context.Context) {
+	var (
+		rev    uint64
+		closed bool
+	)
+
+	for {
+		var data []byte
+		count := 0
+
+		rev, closed = j.Store.View(rev, func(tx *proxystore.Tx) {
+			// don't write back a restored state before the source confirmed it
+			if tx.SourceSynced() {
+				data, count = snapshot(tx)
+			}
+		})
+
+		if closed || ctx.Err() != nil {
+			return
+		}
+
+		if data != nil {
+			if err := writeFile(j.Config.Path, data); err != nil {
+				klog.Error("failed to write the state cache: ", err)
+			} else {
+				klog.V(1).Info("wrote ", count, " entries to the state cache")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(j.Config.Interval):
+		}
+	}
+}
+
+// snapshot returns the entries of the store in the format of the state cache.
+func snapshot(tx *proxystore.Tx) (data []byte, count int) {
+	for _, set := range proxystore.AllSets {
+		tx.Each(set, func(kv *proxystore.KV) bool {
+			data = appendEntry(data, kv)
+			count++
+			return true
+		})
+	}
+	return
+}
+
+func appendEntry(data []byte, kv *proxystore.KV) []byte {
+	value, err := proto.Marshal(kv.Value.(proto.Message))
+	if err != nil {
+		panic(err) // the values of the store are valid
+	}
+
+	v, err := proto.Marshal(&localv1.Value{
+		Ref:   &localv1.Ref{Set: kv.Set, Path: kv.Path()},
+		Bytes: value,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	data = protowire.AppendVarint(data, uint64(len(v)))
+	return append(data, v...)
+}
+
+// writeFile replaces the file atomically, so a crash never leaves a truncated state.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
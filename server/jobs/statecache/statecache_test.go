@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statecache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+func TestRestore(t *testing.T) {
+	cfg := &Config{Path: filepath.Join(t.TempDir(), "state")}
+
+	svc := func(name string) *localv1.Service {
+		return &localv1.Service{Namespace: "default", Name: name}
+	}
+
+	// save the state of a previous run
+	prev := proxystore.New()
+	prev.Update(func(tx *proxystore.Tx) {
+		tx.SetService(svc("svc0"))
+		tx.SetService(svc("svc1"))
+		tx.SetEndpointsOfSource("default", "svc0", []*globalv1.EndpointInfo{{
+			Namespace:   "default",
+			SourceName:  "svc0",
+			ServiceName: "svc0",
+			Endpoint:    &localv1.Endpoint{IPs: &localv1.IPSet{V4: []string{"10.0.0.1"}}},
+		}})
+		tx.SetNode(&globalv1.Node{Name: "node0"})
+		for _, set := range proxystore.AllSets {
+			tx.SetSync(set)
+		}
+	})
+
+	prev.View(0, func(tx *proxystore.Tx) {
+		data, count := snapshot(tx)
+		if count == 0 {
+			t.Error("empty snapshot")
+		}
+		if err := writeFile(cfg.Path, data); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// restore it
+	store := proxystore.New()
+	if err := Restore(store, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	store.View(0, func(tx *proxystore.Tx) {
+		if !tx.AllSynced() {
+			t.Error("the restored store should be synced")
+		}
+		if tx.GetService("default", "svc1") == nil || tx.GetNode("node0") == nil {
+			t.Error("missing restored entries")
+		}
+		eps := 0
+		tx.EachEndpointOfService("default", "svc0", func(*globalv1.EndpointInfo) { eps++ })
+		if eps != 1 {
+			t.Errorf("expected 1 restored endpoint, got %d", eps)
+		}
+	})
+
+	// the informers resync: svc1 was deleted while down
+	store.Update(func(tx *proxystore.Tx) {
+		tx.SetService(svc("svc0"))
+		tx.SetSync(proxystore.Services)
+	})
+
+	store.View(0, func(tx *proxystore.Tx) {
+		if tx.GetService("default", "svc0") == nil {
+			t.Error("svc0 should be kept")
+		}
+		if tx.GetService("default", "svc1") != nil {
+			t.Error("svc1 should be deleted on sync")
+		}
+		if tx.GetNode("node0") == nil {
+			t.Error("the nodes should be kept until their set syncs")
+		}
+	})
+}
+
+func TestJobWaitsForSource(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{Path: filepath.Join(dir, "state")}
+
+	prev := proxystore.New()
+	prev.Update(func(tx *proxystore.Tx) {
+		tx.SetService(&localv1.Service{Namespace: "default", Name: "svc0"})
+	})
+	prev.View(0, func(tx *proxystore.Tx) {
+		data, _ := snapshot(tx)
+		if err := writeFile(cfg.Path, data); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	store := proxystore.New()
+	if err := Restore(store, cfg); err != nil {
+		t.Fatal(err)
+	}
+	// the nodes and endpoints have nothing restored, so the source syncs them first
+	store.Update(func(tx *proxystore.Tx) {
+		tx.SetSync(proxystore.Endpoints)
+		tx.SetSync(proxystore.Nodes)
+	})
+
+	out := filepath.Join(dir, "written")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go (&Job{Store: store, Config: &Config{Path: out, Interval: time.Millisecond}}).Run(ctx)
+
+	time.Sleep(50 * time.Millisecond)
+	if _, err := os.Stat(out); err == nil {
+		t.Fatal("the restored state was written before the source synced")
+	}
+
+	store.Update(func(tx *proxystore.Tx) {
+		tx.SetService(&localv1.Service{Namespace: "default", Name: "svc0"})
+		tx.SetSync(proxystore.Services)
+	})
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := os.Stat(out); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the state was not written after the source synced")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRestoreMissing(t *testing.T) {
+	store := proxystore.New()
+	if err := Restore(store, &Config{Path: filepath.Join(t.TempDir(), "state")}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store2api
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"sigs.k8s.io/kpng/client/tlsflags"
+)
+
+// listener is an address the APIs are served on, with its own TLS settings.
+type listener struct {
+	bindSpec string
+	// tls are the settings of the clients authentication, nil to accept all clients
+	tls *tlsflags.Flags
+}
+
+// parseBindSpec parses a listen spec (protocol://address), optionally followed by the TLS settings
+// of the listener, replacing the --listen-tls-* ones (tls=on needs a certificate, a key and a CA):
+//
+//	unix:///run/kpng/api.sock?tls=off
+//	tcp://:12091?tls-crt=debug.crt&tls-key=debug.key&tls-ca=debug-ca.crt
+func parseBindSpec(spec string, defaultTLS *tlsflags.Flags) (*listener, error) {
+	bindSpec, query, found := strings.Cut(spec, "?")
+	l := &listener{bindSpec: bindSpec, tls: defaultTLS}
+	if !found {
+		return l, nil
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen spec %q: %w", spec, err)
+	}
+
+	tlsFlags := &tlsflags.Flags{}
+	if defaultTLS != nil {
+		*tlsFlags = *defaultTLS
+	}
+	l.tls = tlsFlags
+
+	for key := range values {
+		value := values.Get(key)
+
+		switch key {
+		case "tls":
+			if value != "on" && value != "off" {
+				return nil, fmt.Errorf("invalid listen spec %q: tls must be on or off", spec)
+			}
+		case "tls-crt":
+			tlsFlags.CertFile = value
+		case "tls-key":
+			tlsFlags.KeyFile = value
+		case "tls-ca":
+			tlsFlags.CAFile = value
+		default:
+			return nil, fmt.Errorf("invalid listen spec %q: unknown setting %q", spec, key)
+		}
+	}
+
+	switch values.Get("tls") {
+	case "off":
+		l.tls = nil
+	case "on":
+		// without all of them, the listener would serve plaintext or not authenticate the clients
+		if tlsFlags.CertFile == "" || tlsFlags.KeyFile == "" || tlsFlags.CAFile == "" {
+			return nil, fmt.Errorf("invalid listen spec %q: tls=on needs a certificate, a key and a CA", spec)
+		}
+	}
+	return l, nil
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store2api
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/kpng/client/tlsflags"
+)
+
+func TestParseBindSpec(t *testing.T) {
+	defaultTLS := &tlsflags.Flags{KeyFile: "kpng.key", CertFile: "kpng.crt", CAFile: "ca.crt"}
+
+	for _, tc := range []struct {
+		spec     string
+		bindSpec string
+		tls      *tlsflags.Flags
+	}{
+		{"tcp://:12090", "tcp://:12090", defaultTLS},
+		{"unix:///run/kpng/api.sock?tls=off", "unix:///run/kpng/api.sock", nil},
+		{"tcp://:12091?tls=on", "tcp://:12091", defaultTLS},
+		{"tcp://:12091?tls-crt=debug.crt&tls-key=debug.key", "tcp://:12091",
+			&tlsflags.Flags{KeyFile: "debug.key", CertFile: "debug.crt", CAFile: "ca.crt"}},
+	} {
+		l, err := parseBindSpec(tc.spec, defaultTLS)
+		if err != nil {
+			t.Errorf("%s: %v", tc.spec, err)
+			continue
+		}
+		if l.bindSpec != tc.bindSpec || !reflect.DeepEqual(l.tls, tc.tls) {
+			t.Errorf("%s: got %s %+v, expected %s %+v", tc.spec, l.bindSpec, l.tls, tc.bindSpec, tc.tls)
+		}
+	}
+
+	for _, spec := range []string{"tcp://:12090?tls=maybe", "tcp://:12090?auth=none", "tcp://:12090?tls=%zz"} {
+		if _, err := parseBindSpec(spec, defaultTLS); err == nil {
+			t.Errorf("%s: expected an error", spec)
+		}
+	}
+
+	// tls=on is refused unless the listener can authenticate its clients
+	for _, tc := range []struct {
+		spec       string
+		defaultTLS *tlsflags.Flags
+	}{
+		{"tcp://:12091?tls=on", nil},
+		{"tcp://:12091?tls=on", &tlsflags.Flags{}},
+		{"tcp://:12091?tls=on&tls-crt=debug.crt&tls-key=debug.key", nil},
+		{"tcp://:12091?tls=on&tls-ca=", defaultTLS},
+	} {
+		if _, err := parseBindSpec(tc.spec, tc.defaultTLS); err == nil {
+			t.Errorf("%s (default TLS %+v): expected an error", tc.spec, tc.defaultTLS)
+		}
+	}
+
+	l, err := parseBindSpec("tcp://:12091?tls=on&tls-crt=debug.crt&tls-key=debug.key&tls-ca=debug-ca.crt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (&tlsflags.Flags{KeyFile: "debug.key", CertFile: "debug.crt", CAFile: "debug-ca.crt"}); !reflect.DeepEqual(l.tls, expected) {
+		t.Errorf("expected %+v, got %+v", expected, l.tls)
+	}
+}
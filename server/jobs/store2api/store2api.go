@@ -19,70 +19,205 @@ package store2api
 import (
 	"context"
 	"crypto/tls"
+	"net/http"
+	"time"
 
 	"github.com/spf13/pflag"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
+	"k8s.io/klog/v2"
 
+	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/api/localv2"
+	"sigs.k8s.io/kpng/client/grpcflags"
 	"sigs.k8s.io/kpng/client/tlsflags"
+	nodeendpoints "sigs.k8s.io/kpng/server/pkg/endpoints"
 	"sigs.k8s.io/kpng/server/pkg/server"
 	"sigs.k8s.io/kpng/server/pkg/server/endpoints"
+	"sigs.k8s.io/kpng/server/pkg/server/gateway"
 	"sigs.k8s.io/kpng/server/pkg/server/global"
 	"sigs.k8s.io/kpng/server/proxystore"
 )
 
 type Config struct {
-	BindSpec  string
+	// BindSpecs are the listen specs of the APIs, each optionally followed by its TLS settings
+	// (see parseBindSpec)
+	BindSpecs []string
 	GlobalAPI bool
 	LocalAPI  bool
-	TLS       *tlsflags.Flags
+	// Reflection enables the gRPC server reflection, to use tools like grpcurl
+	Reflection bool
+	TLS        *tlsflags.Flags
+	GRPC       *grpcflags.Flags
+
+	// GatewayAddress is the address of the REST+JSON gateway of the read APIs, disabled if empty
+	GatewayAddress string
+
+	// HostNetworkEndpoints is how the local API finds the endpoints using a node IP local.
+	HostNetworkEndpoints nodeendpoints.HostNetworkMode
 }
 
 func (c *Config) BindFlags(flags *pflag.FlagSet) {
-	flags.StringVar(&c.BindSpec, "listen", "tcp://:12090", "serve globalv1 API")
+	flags.StringArrayVar(&c.BindSpecs, "listen", []string{"tcp://:12090"}, "serve globalv1 API (systemd://[name] to use a socket passed by systemd), repeat to listen on several specs; the TLS settings of a spec are overridden with ?tls=off or ?tls-crt=...&tls-key=...&tls-ca=...")
 	flags.BoolVar(&c.GlobalAPI, "globalv1-api", true, "serve globalv1 API")
 	flags.BoolVar(&c.LocalAPI, "local-api", true, "serve local API")
+	flags.BoolVar(&c.Reflection, "grpc-reflection", false, "enable the gRPC server reflection (ie: for grpcurl)")
+	flags.StringVar(&c.GatewayAddress, "gateway-listen", "", "serve the global and node state read APIs as REST+JSON on this address (ie: 127.0.0.1:12091), with their OpenAPI document at "+gateway.OpenAPIPath+"; the gateway has no authentication")
+
+	c.HostNetworkEndpoints = nodeendpoints.HostNetworkNodeName
+	flags.Var(&c.HostNetworkEndpoints, "host-network-endpoints", nodeendpoints.HostNetworkFlagUsage)
 
 	if c.TLS == nil {
 		c.TLS = &tlsflags.Flags{}
 	}
 
 	c.TLS.Bind(flags, "listen-")
+
+	if c.GRPC == nil {
+		c.GRPC = &grpcflags.Flags{}
+	}
+
+	c.GRPC.BindServer(flags, "")
 }
 
+const gatewayReadHeaderTimeout = 5 * time.Second
+
 type Job struct {
 	Store  *proxystore.Store
 	Config *Config
 }
 
 func (j *Job) Run(ctx context.Context) error {
-	lis := server.MustListen(j.Config.BindSpec)
+	listeners := make([]*listener, 0, len(j.Config.BindSpecs))
+	for _, spec := range j.Config.BindSpecs {
+		l, err := parseBindSpec(spec, j.Config.TLS)
+		if err != nil {
+			return err
+		}
+		listeners = append(listeners, l)
+	}
+
+	servers := make([]*grpc.Server, 0, len(listeners))
+	errs := make(chan error, len(listeners)+1)
+
+	for _, l := range listeners {
+		lis := server.MustListen(l.bindSpec)
+		srv := j.newServer(l.tls)
+		servers = append(servers, srv)
+
+		go func() { errs <- srv.Serve(lis) }()
+	}
 
-	// setup gRPC server
-	var srv *grpc.Server
-	if tlsCfg := j.Config.TLS.Config(); tlsCfg == nil {
-		srv = grpc.NewServer()
-	} else {
+	var gatewaySrv *http.Server
+	if j.Config.GatewayAddress != "" {
+		var err error
+		if gatewaySrv, err = j.newGateway(ctx); err != nil {
+			return err
+		}
+
+		go func() { errs <- gatewaySrv.ListenAndServe() }()
+	}
+
+	go j.notifyReady()
+
+	// handle exit
+	go func() {
+		_, _ = <-ctx.Done()
+		server.Notify("STOPPING=1")
+		for _, srv := range servers {
+			srv.Stop()
+		}
+		if gatewaySrv != nil {
+			gatewaySrv.Close()
+		}
+	}()
+
+	// a failed listener stops the others
+	err := <-errs
+	for _, srv := range servers {
+		srv.Stop()
+	}
+	if gatewaySrv != nil {
+		gatewaySrv.Close()
+	}
+	return err
+}
+
+// newGateway returns the HTTP server of the REST+JSON gateway of the enabled APIs, calling their
+// servers in-process.
+func (j *Job) newGateway(ctx context.Context) (*http.Server, error) {
+	var (
+		globalSrv globalv1.SetsServer
+		localSrv  localv2.SetsServer
+	)
+	if j.Config.GlobalAPI {
+		globalSrv = &global.Server{Store: j.Store}
+	}
+	if j.Config.LocalAPI {
+		localSrv = &endpoints.ServerV2{Store: j.Store, HostNetworkEndpoints: j.Config.HostNetworkEndpoints}
+	}
+
+	handler, err := gateway.Handler(ctx, globalSrv, localSrv)
+	if err != nil {
+		return nil, err
+	}
+
+	klog.Info("serving the REST+JSON gateway on ", j.Config.GatewayAddress)
+
+	return &http.Server{
+		Addr:              j.Config.GatewayAddress,
+		Handler:           handler,
+		ReadHeaderTimeout: gatewayReadHeaderTimeout,
+	}, nil
+}
+
+// newServer returns a gRPC server of the APIs, authenticating the clients with the tls settings if
+// set.
+func (j *Job) newServer(tlsFlags *tlsflags.Flags) *grpc.Server {
+	opts := j.Config.GRPC.ServerOptions()
+	if tlsCfg := tlsFlags.Config(); tlsCfg != nil {
 		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
 		tlsCfg.ClientCAs = tlsCfg.RootCAs
 
 		creds := credentials.NewTLS(tlsCfg)
-		srv = grpc.NewServer(grpc.Creds(creds))
+		opts = append(opts, grpc.Creds(creds))
 	}
 
-	// setup server
+	srv := grpc.NewServer(opts...)
+
 	if j.Config.GlobalAPI {
 		global.Setup(srv, j.Store)
 	}
 	if j.Config.LocalAPI {
-		endpoints.Setup(srv, j.Store)
+		endpoints.Setup(srv, j.Store, j.Config.HostNetworkEndpoints)
+	}
+	if j.Config.Reflection {
+		reflection.Register(srv)
 	}
 
-	// handle exit
-	go func() {
-		_, _ = <-ctx.Done()
-		srv.Stop()
-	}()
+	return srv
+}
 
-	return srv.Serve(lis)
+// notifyReady tells systemd the API is ready once the store is synced, so the units of the backends
+// can be ordered after kpng's one.
+func (j *Job) notifyReady() {
+	var (
+		rev    uint64
+		closed bool
+		synced bool
+	)
+
+	for !synced {
+		rev, closed = j.Store.View(rev, func(tx *proxystore.Tx) {
+			synced = tx.AllSynced()
+		})
+		if closed {
+			return
+		}
+	}
+
+	if err := server.Notify("READY=1"); err != nil {
+		klog.Error("failed to notify systemd: ", err)
+	}
 }
@@ -44,6 +44,9 @@ func (j *Job) Run(ctx context.Context) (err error) {
 	var (
 		rev    uint64
 		closed bool
+
+		// initialSync is true once the first complete state was marked
+		initialSync bool
 	)
 
 	for {
@@ -62,12 +65,14 @@ func (j *Job) Run(ctx context.Context) (err error) {
 			w.SendReset()
 		}
 
-		updated := false
+		updated, markInitial := false, false
 		for !updated {
 			// block until the revision has been
 			// incremented... then, we update our state from the
 			// proxystore
+			synced := false
 			rev, closed = j.Store.View(rev, func(tx *proxystore.Tx) {
+				synced = tx.AllSynced()
 				j.Sink.Update(tx, w)
 			})
 
@@ -81,6 +86,18 @@ func (j *Job) Run(ctx context.Context) (err error) {
 
 			// send the diff
 			updated = j.Sink.SendDiff(w)
+
+			// the clients are told the first complete state, sent once the store is synced
+			// even if the state is empty
+			if synced && !initialSync {
+				updated = true
+				markInitial = true
+				initialSync = true
+			}
+		}
+
+		if markInitial {
+			w.SendInitialSync()
 		}
 
 		// signal the change set is fully sent
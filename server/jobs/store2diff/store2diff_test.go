@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store2diff
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/lightdiffstore"
+	"sigs.k8s.io/kpng/server/pkg/server/watchstate"
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+// servicesSink sends the services of the store, synced or not, and records the operations.
+type servicesSink struct {
+	ops chan string
+}
+
+func (*servicesSink) Wait() error { return nil }
+
+func (*servicesSink) Update(tx *proxystore.Tx, w *watchstate.WatchState) {
+	svcs := w.StoreFor(localv1.Set_ServicesSet)
+	tx.Each(proxystore.Services, func(kv *proxystore.KV) bool {
+		svcs.Set([]byte(kv.Namespace+"/"+kv.Name), kv.Service.Hash, kv.Service.Service)
+		return true
+	})
+}
+
+func (*servicesSink) SendDiff(w *watchstate.WatchState) (updated bool) {
+	count := w.SendUpdates(localv1.Set_ServicesSet)
+	count += w.SendDeletes(localv1.Set_ServicesSet)
+	w.Reset(lightdiffstore.ItemDeleted)
+	return count != 0
+}
+
+func (s *servicesSink) Send(op *localv1.OpItem) error {
+	switch v := op.Op.(type) {
+	case *localv1.OpItem_Reset_:
+		s.ops <- "reset"
+	case *localv1.OpItem_Set:
+		s.ops <- "set " + v.Set.Ref.Path
+	case *localv1.OpItem_Delete:
+		s.ops <- "del " + v.Delete.Path
+	case *localv1.OpItem_InitialSync:
+		s.ops <- "initial"
+	case *localv1.OpItem_Sync:
+		s.ops <- "sync"
+	}
+	return nil
+}
+
+func TestInitialSyncMarker(t *testing.T) {
+	store := proxystore.New()
+	defer store.Close()
+
+	sink := &servicesSink{ops: make(chan string, 100)}
+	job := &Job{Store: store, Sets: []localv1.Set{localv1.Set_ServicesSet}, Sink: sink}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go job.Run(ctx)
+
+	expect := func(ops ...string) {
+		t.Helper()
+		got := make([]string, 0, len(ops))
+		for range ops {
+			select {
+			case op := <-sink.ops:
+				got = append(got, op)
+			case <-time.After(time.Second):
+				t.Fatalf("expected %q, got %q", ops, got)
+			}
+		}
+		if !reflect.DeepEqual(got, ops) {
+			t.Errorf("expected %q, got %q", ops, got)
+		}
+	}
+
+	setService := func(name string) {
+		store.Update(func(tx *proxystore.Tx) {
+			tx.SetService(&localv1.Service{Namespace: "ns", Name: name})
+		})
+	}
+
+	// a partial state is not marked
+	setService("a")
+	expect("reset", "set ns/a", "sync")
+
+	// the marker precedes the sync of the first complete state, even without changes
+	store.Update(func(tx *proxystore.Tx) {
+		for _, set := range proxystore.AllSets {
+			tx.SetSync(set)
+		}
+	})
+	expect("initial", "sync")
+
+	// then only once
+	setService("b")
+	expect("set ns/b", "sync")
+
+	select {
+	case op := <-sink.ops:
+		t.Errorf("unexpected op %q", op)
+	default:
+	}
+}
@@ -21,11 +21,15 @@ import (
 	"runtime/trace"
 	"strconv"
 
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/kpng/api/globalv1"
 	"sigs.k8s.io/kpng/api/localv1"
 	"sigs.k8s.io/kpng/client/lightdiffstore"
 	"sigs.k8s.io/kpng/client/localsink"
 	"sigs.k8s.io/kpng/server/jobs/store2diff"
 	"sigs.k8s.io/kpng/server/pkg/endpoints"
+	"sigs.k8s.io/kpng/server/pkg/metrics"
 	"sigs.k8s.io/kpng/server/pkg/server/watchstate"
 	"sigs.k8s.io/kpng/server/proxystore"
 	"sigs.k8s.io/kpng/server/serde"
@@ -34,11 +38,16 @@ import (
 type Job struct {
 	Store *proxystore.Store
 	Sink  localsink.Sink
+
+	// HostNetworkEndpoints is how the endpoints using a node IP are found local (see
+	// endpoints.HostNetworkMode), by node name if not set.
+	HostNetworkEndpoints endpoints.HostNetworkMode
 }
 
 func (j *Job) Run(ctx context.Context) error {
 	run := &jobRun{
-		Sink: j.Sink,
+		Sink:        j.Sink,
+		hostNetwork: j.HostNetworkEndpoints,
 	}
 
 	job := &store2diff.Job{
@@ -49,6 +58,7 @@ func (j *Job) Run(ctx context.Context) error {
 			localv1.Set_EndpointsSet, // setN 0
 			localv1.Set_EndpointsSet, // setN 1
 			// 2nd endpoints set for endpoints which do not have a corresponding pod name
+			localv1.Set_ExternalNamesSet, // setN 0
 		},
 		Sink: run,
 	}
@@ -60,11 +70,16 @@ func (j *Job) Run(ctx context.Context) error {
 
 type jobRun struct {
 	localsink.Sink
-	nodeName string
+	nodeName    string
+	families    []localv1.IPFamily
+	terminating bool
+	hostNetwork endpoints.HostNetworkMode
 }
 
 func (s *jobRun) Wait() (err error) {
 	s.nodeName, err = s.WaitRequest()
+	s.families = localsink.IPFamilies(s.Sink)
+	s.terminating = localsink.WithTerminatingEndpoints(s.Sink)
 	return
 }
 
@@ -84,22 +99,38 @@ func (s *jobRun) Update(tx *proxystore.Tx, w *watchstate.WatchState) {
 	svcs := w.StoreForN(localv1.Set_ServicesSet, 0)
 	seps := w.StoreForN(localv1.Set_EndpointsSet, 0)
 	sepsAnonymous := w.StoreForN(localv1.Set_EndpointsSet, 1)
+	externalNames := w.StoreForN(localv1.Set_ExternalNamesSet, 0)
 
 	// set all new values
-	tx.Each(proxystore.Services, func(kv *proxystore.KV) bool {
+	EachForNode(tx, nodeName, s.hostNetwork, s.terminating, func(kv *proxystore.KV, endpoints []*globalv1.EndpointInfo) {
 		key := []byte(kv.Namespace + "/" + kv.Name)
 
 		if trace.IsEnabled() {
 			trace.Log(ctx, "service", string(key))
 		}
-		svcs.Set(key, kv.Service.Hash, kv.Service.Service)
 
-		// iterate through ONLY the endpoints which are valid for
-		// this node to loadbalance to (i.e. in cases of
-		// topology constraints or trafficPolicy=Local,
-		// some endpoints may not be available for
-		// node to route to).
-		for _, ei := range endpoints.ForNode(tx, kv.Service, nodeName) {
+		// ExternalName services have no rules (nor endpoints), they're only informational
+		if kv.Service.Service.Type == "ExternalName" {
+			externalNames.Set(key, kv.Service.Hash, kv.Service.Service)
+			return
+		}
+
+		// only the IPs of the requested families are sent; the hashes of the whole values still
+		// change with them
+		svc := kv.Service.Service.ForIPFamilies(s.families...)
+		if svc == nil {
+			// no cluster IP of the requested families
+			return
+		}
+
+		svcs.Set(key, kv.Service.Hash, svc)
+
+		for _, ei := range endpoints {
+			ep := ei.Endpoint.ForIPFamilies(s.families...)
+			if ep == nil {
+				continue
+			}
+
 			// endpoints are not hashed, so hash, but hash ONLY the endpoint.
 			// to avoid false diff triggering in cases where endpoint metadata
 			// not relevant for "local" decision making (i.e. an endpoint
@@ -129,9 +160,47 @@ func (s *jobRun) Update(tx *proxystore.Tx, w *watchstate.WatchState) {
 			}
 
 			// Insert or update this key in the diffstore
-			set.Set(epKey, hash, ei.Endpoint)
+			set.Set(epKey, hash, ep)
+		}
+	}, func(kind, key string, err error) {
+		klog.Error("rejecting ", kind, " ", key, ": ", err)
+		metrics.Kpng_invalid_objects.WithLabelValues(kind).Inc()
+	})
+}
+
+// EachForNode calls accept with the services of the store and their endpoints for the given
+// node, as sent to it, and reject with the services and endpoints rejected by the validation.
+// The serving terminating endpoints are included, with their conditions, if withTerminating.
+func EachForNode(tx *proxystore.Tx, nodeName string, hostNetwork endpoints.HostNetworkMode, withTerminating bool,
+	accept func(kv *proxystore.KV, endpoints []*globalv1.EndpointInfo),
+	reject func(kind, key string, err error)) {
+
+	tx.Each(proxystore.Services, func(kv *proxystore.KV) bool {
+		key := kv.Namespace + "/" + kv.Name
+
+		// reject what the backends can't handle; not setting it deletes any previous version
+		if err := kv.Service.Service.Validate(); err != nil {
+			reject("service", key, err)
+			return true
+		}
+
+		// iterate through ONLY the endpoints which are valid for
+		// this node to loadbalance to (i.e. in cases of
+		// topology constraints or trafficPolicy=Local,
+		// some endpoints may not be available for
+		// node to route to).
+		nodeEndpoints := endpoints.ForNodeWithMode(tx, kv.Service, nodeName, hostNetwork, withTerminating)
+
+		valid := make([]*globalv1.EndpointInfo, 0, len(nodeEndpoints))
+		for _, ei := range nodeEndpoints {
+			if err := ei.Endpoint.Validate(); err != nil {
+				reject("endpoint", key+"/"+ei.PodName, err)
+				continue
+			}
+			valid = append(valid, ei)
 		}
 
+		accept(kv, valid)
 		return true
 	})
 }
@@ -163,6 +232,10 @@ func (*jobRun) SendDiff(w *watchstate.WatchState) (updated bool) {
 	// prematurely.
 	count += w.SendDeletes(localv1.Set_ServicesSet)
 
+	// ExternalName services are independent of the others
+	count += w.SendUpdates(localv1.Set_ExternalNamesSet)
+	count += w.SendDeletes(localv1.Set_ExternalNamesSet)
+
 	// Tell the diffstore that every item is now in the previous
 	// window, so the store is empty.
 	w.Reset(lightdiffstore.ItemDeleted)
@@ -17,26 +17,51 @@ limitations under the License.
 package apiwatch
 
 import (
+	"time"
+
 	"github.com/spf13/pflag"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 
+	"sigs.k8s.io/kpng/client/grpcflags"
 	"sigs.k8s.io/kpng/client/tlsflags"
 )
 
+// DefaultErrorDelay is the delay before watching again after an error, when ErrorDelay is not set.
+const DefaultErrorDelay = 5 * time.Second
+
 type Watch struct {
 	Server   string
 	TLSFlags *tlsflags.Flags
+	GRPC     *grpcflags.Flags
+
+	// ErrorDelay is the delay before watching again after an error.
+	ErrorDelay time.Duration
 }
 
 func (w *Watch) BindFlags(flags *pflag.FlagSet) {
 	flags.StringVar(&w.Server, "api", "127.0.0.1:12090", "Remote API server to query")
+	flags.DurationVar(&w.ErrorDelay, "api-client-error-delay", DefaultErrorDelay, "duration to wait before watching again after errors")
 	w.TLSFlags.Bind(flags, "api-client-")
+
+	if w.GRPC == nil {
+		w.GRPC = &grpcflags.Flags{}
+	}
+	w.GRPC.BindClient(flags, "api-client-")
+}
+
+// WaitAfterError sleeps for the error delay.
+func (w *Watch) WaitAfterError() {
+	delay := w.ErrorDelay
+	if delay == 0 {
+		delay = DefaultErrorDelay
+	}
+	time.Sleep(delay)
 }
 
 func (w *Watch) Dial() (conn *grpc.ClientConn, err error) {
 	// connect to API
-	opts := []grpc.DialOption{}
+	opts := w.GRPC.DialOptions()
 
 	if cfg := w.TLSFlags.Config(); cfg == nil {
 		opts = append(opts, grpc.WithInsecure())
@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kpng/api/globalv1"
+)
+
+// HostNetworkMode is the way the endpoints using an IP of a node (ie: the hostNetwork pods) are
+// found local to a node.
+type HostNetworkMode string
+
+const (
+	// HostNetworkNodeName makes them local to the node of their topology, like the others.
+	HostNetworkNodeName HostNetworkMode = "node-name"
+	// HostNetworkNodeIP makes them local to the node having their IP, even if their topology
+	// names another node or none.
+	HostNetworkNodeIP HostNetworkMode = "node-ip"
+	// HostNetworkExclude makes them never local, so they don't count as local endpoints of the
+	// services with a Local traffic policy.
+	HostNetworkExclude HostNetworkMode = "exclude"
+)
+
+// HostNetworkFlagUsage is the usage of the flags setting a HostNetworkMode.
+const HostNetworkFlagUsage = "how the endpoints using a node IP (hostNetwork pods) are found local to a node: " +
+	"node-name (by their node, like the others), node-ip (by their IP) or exclude (never local)"
+
+func (m *HostNetworkMode) String() string { return string(*m) }
+func (m *HostNetworkMode) Type() string   { return "mode" }
+
+func (m *HostNetworkMode) Set(s string) error {
+	switch mode := HostNetworkMode(s); mode {
+	case HostNetworkNodeName, HostNetworkNodeIP, HostNetworkExclude:
+		*m = mode
+		return nil
+	default:
+		return fmt.Errorf("invalid mode %q (expected %s, %s or %s)", s, HostNetworkNodeName, HostNetworkNodeIP, HostNetworkExclude)
+	}
+}
+
+// isLocal tells if the endpoint is local to the node, according to the mode.
+func (m HostNetworkMode) isLocal(info *globalv1.EndpointInfo, node *globalv1.Node) bool {
+	// the endpoints without node (ie: outside of the cluster) are never local by name
+	byName := info.Topology.GetNode() != "" && info.Topology.GetNode() == node.Name
+
+	switch m {
+	case HostNetworkNodeIP:
+		return byName || hasNodeIP(info, node)
+
+	case HostNetworkExclude:
+		return byName && !hasNodeIP(info, node)
+
+	default:
+		return byName
+	}
+}
+
+// hasNodeIP tells if an IP of the endpoint is one of the node's.
+func hasNodeIP(info *globalv1.EndpointInfo, node *globalv1.Node) bool {
+	nodeIPs := node.GetIPs().All()
+	if len(nodeIPs) == 0 {
+		return false
+	}
+
+	for _, ip := range info.Endpoint.GetIPs().All() {
+		for _, nodeIP := range nodeIPs {
+			if ip == nodeIP {
+				return true
+			}
+		}
+	}
+	return false
+}
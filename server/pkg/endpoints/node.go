@@ -27,6 +27,13 @@ import (
 const hostnameLabel = "kubernetes.io/hostname"
 
 func ForNode(tx *proxystore.Tx, si *globalv1.ServiceInfo, nodeName string) (endpoints []*globalv1.EndpointInfo) {
+	return ForNodeWithMode(tx, si, nodeName, HostNetworkNodeName, false)
+}
+
+// ForNodeWithMode is ForNode, finding the local endpoints of hostNetwork pods with the given mode.
+// With withTerminating, the endpoints not ready but serving while terminating are included, and
+// the conditions of the endpoints are set.
+func ForNodeWithMode(tx *proxystore.Tx, si *globalv1.ServiceInfo, nodeName string, hostNetwork HostNetworkMode, withTerminating bool) (endpoints []*globalv1.EndpointInfo) {
 	node := tx.GetNode(nodeName)
 
 	if node == nil {
@@ -45,11 +52,15 @@ func ForNode(tx *proxystore.Tx, si *globalv1.ServiceInfo, nodeName string) (endp
 	tx.EachEndpointOfService(svc.Namespace, svc.Name, func(info *globalv1.EndpointInfo) {
 		info = proto.Clone(info).(*globalv1.EndpointInfo)
 
-		info.Endpoint.Local = info.Topology.Node == nodeName
+		info.Endpoint.Local = hostNetwork.isLocal(info, node)
 
-		if !info.Conditions.Ready {
+		c := info.Conditions
+		if !c.Ready && !(withTerminating && c.Serving && c.Terminating) {
 			return
 		}
+		if withTerminating {
+			info.Endpoint.Conditions = &localv1.EndpointConditions{Ready: c.Ready, Serving: c.Serving, Terminating: c.Terminating}
+		}
 
 		if hints := info.Hints; hints != nil {
 			if len(hints.Zones) != 0 {
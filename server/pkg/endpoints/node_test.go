@@ -18,6 +18,10 @@ package endpoints
 
 import (
 	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
 
 	"sigs.k8s.io/kpng/api/localv1"
 	"sigs.k8s.io/kpng/api/globalv1"
@@ -95,3 +99,171 @@ func ExampleForNodeWithTopology() {
 	//   - service test:
 	//     - ep V4:"10.2.1.1"
 }
+
+func TestForNodeEndpointWithoutNode(t *testing.T) {
+	store := proxystore.New()
+
+	store.Update(func(tx *proxystore.Tx) {
+		tx.SetService(&localv1.Service{
+			Namespace: "test",
+			Name:      "external",
+			Type:      "ClusterIP",
+			IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.1.2.4")},
+			Ports:     []*localv1.PortMapping{{Port: 443}},
+		})
+
+		// a manually-curated endpoint, outside of the cluster
+		tx.SetEndpointsOfSource("test", "external", []*globalv1.EndpointInfo{{
+			Namespace:   "test",
+			SourceName:  "external",
+			ServiceName: "external",
+			Endpoint:    &localv1.Endpoint{IPs: localv1.NewIPSet("192.0.2.10")},
+			Topology:    &globalv1.TopologyInfo{},
+			Conditions:  &globalv1.EndpointConditions{Ready: true},
+		}})
+	})
+
+	store.View(0, func(tx *proxystore.Tx) {
+		tx.Each(proxystore.Services, func(kv *proxystore.KV) bool {
+			for _, nodeName := range []string{"host-a", ""} {
+				endpoints := ForNode(tx, kv.Service, nodeName)
+				if len(endpoints) != 1 {
+					t.Fatalf("node %q: expected 1 endpoint, got %d", nodeName, len(endpoints))
+				}
+				if endpoints[0].Endpoint.Local {
+					t.Errorf("node %q: the endpoint without node is local", nodeName)
+				}
+			}
+			return true
+		})
+	})
+}
+
+func TestForNodeHostNetwork(t *testing.T) {
+	store := proxystore.New()
+
+	store.Update(func(tx *proxystore.Tx) {
+		for name, ip := range map[string]string{"host-a": "192.168.0.1", "host-b": "192.168.0.2"} {
+			tx.SetNode(&globalv1.Node{
+				Name:     name,
+				Topology: &globalv1.TopologyInfo{Node: name},
+				IPs:      localv1.NewIPSet(ip),
+			})
+		}
+
+		tx.SetService(&localv1.Service{
+			Namespace: "test",
+			Name:      "test",
+			Type:      "NodePort",
+			IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.1.2.3")},
+			Ports:     []*localv1.PortMapping{{Port: 80, NodePort: 30080}},
+
+			ExternalTrafficToLocal: true,
+		})
+
+		endpoint := func(ip, node string) *globalv1.EndpointInfo {
+			return &globalv1.EndpointInfo{
+				Namespace:   "test",
+				SourceName:  "test-abcde",
+				ServiceName: "test",
+				Endpoint:    &localv1.Endpoint{IPs: localv1.NewIPSet(ip)},
+				Topology:    &globalv1.TopologyInfo{Node: node},
+				Conditions:  &globalv1.EndpointConditions{Ready: true},
+			}
+		}
+
+		tx.SetEndpointsOfSource("test", "test-abcde", []*globalv1.EndpointInfo{
+			endpoint("10.2.0.1", "host-a"),    // pod
+			endpoint("192.168.0.1", "host-a"), // hostNetwork pod
+			endpoint("192.168.0.2", ""),       // hostNetwork pod, without node
+		})
+	})
+
+	for _, test := range []struct {
+		mode     HostNetworkMode
+		expected map[string]bool
+	}{
+		{HostNetworkNodeName, map[string]bool{"10.2.0.1": true, "192.168.0.1": true, "192.168.0.2": false}},
+		{HostNetworkNodeIP, map[string]bool{"10.2.0.1": true, "192.168.0.1": true, "192.168.0.2": false}},
+		{HostNetworkExclude, map[string]bool{"10.2.0.1": true, "192.168.0.1": false, "192.168.0.2": false}},
+	} {
+		for node, expected := range map[string]map[string]bool{
+			"host-a": test.expected,
+			"host-b": {"10.2.0.1": false, "192.168.0.1": false, "192.168.0.2": test.mode == HostNetworkNodeIP},
+		} {
+			local := map[string]bool{}
+			store.View(0, func(tx *proxystore.Tx) {
+				tx.Each(proxystore.Services, func(kv *proxystore.KV) bool {
+					for _, info := range ForNodeWithMode(tx, kv.Service, node, test.mode, false) {
+						local[info.Endpoint.IPs.First()] = info.Endpoint.Local
+					}
+					return true
+				})
+			})
+
+			if !reflect.DeepEqual(local, expected) {
+				t.Errorf("%s on %s: expected %v, got %v", test.mode, node, expected, local)
+			}
+		}
+	}
+}
+
+func TestForNodeWithTerminating(t *testing.T) {
+	store := proxystore.New()
+	store.Update(func(tx *proxystore.Tx) {
+		tx.SetService(&localv1.Service{
+			Namespace: "test",
+			Name:      "test",
+			Type:      "ClusterIP",
+			IPs:       &localv1.ServiceIPs{ClusterIPs: localv1.NewIPSet("10.1.2.3")},
+			Ports:     []*localv1.PortMapping{{Port: 80}},
+		})
+
+		endpoint := func(ip string, conditions *globalv1.EndpointConditions) *globalv1.EndpointInfo {
+			return &globalv1.EndpointInfo{
+				Namespace:   "test",
+				SourceName:  "test-abcde",
+				ServiceName: "test",
+				Endpoint:    &localv1.Endpoint{IPs: localv1.NewIPSet(ip)},
+				Topology:    &globalv1.TopologyInfo{},
+				Conditions:  conditions,
+			}
+		}
+
+		tx.SetEndpointsOfSource("test", "test-abcde", []*globalv1.EndpointInfo{
+			endpoint("10.2.0.1", &globalv1.EndpointConditions{Ready: true, Serving: true}),
+			endpoint("10.2.0.2", &globalv1.EndpointConditions{Serving: true, Terminating: true}),
+			endpoint("10.2.0.3", &globalv1.EndpointConditions{Terminating: true}),
+		})
+	})
+
+	for _, withTerminating := range []bool{false, true} {
+		conditions := map[string]*localv1.EndpointConditions{}
+		store.View(0, func(tx *proxystore.Tx) {
+			tx.Each(proxystore.Services, func(kv *proxystore.KV) bool {
+				for _, info := range ForNodeWithMode(tx, kv.Service, "host-a", HostNetworkNodeName, withTerminating) {
+					conditions[info.Endpoint.IPs.First()] = info.Endpoint.Conditions
+				}
+				return true
+			})
+		})
+
+		expected := map[string]*localv1.EndpointConditions{"10.2.0.1": nil}
+		if withTerminating {
+			expected = map[string]*localv1.EndpointConditions{
+				"10.2.0.1": {Ready: true, Serving: true},
+				"10.2.0.2": {Serving: true, Terminating: true},
+			}
+		}
+
+		if len(conditions) != len(expected) {
+			t.Errorf("withTerminating=%v: expected %v, got %v", withTerminating, expected, conditions)
+			continue
+		}
+		for ip, c := range expected {
+			if got, ok := conditions[ip]; !ok || !proto.Equal(got, c) {
+				t.Errorf("withTerminating=%v: %s: expected %v, got %v", withTerminating, ip, c, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package intern deduplicates the strings kept in memory, like the IPs, node and zone names of
+// the endpoints: on big clusters, each one is decoded again with every object it appears in.
+package intern
+
+// Strings is a pool of strings, returning a single copy of equal strings. It's not safe for
+// concurrent use.
+type Strings struct {
+	strings map[string]string
+}
+
+func New() *Strings {
+	return &Strings{strings: map[string]string{}}
+}
+
+// Intern returns the copy of s in the pool, adding s to the pool if it's not already in it.
+func (p *Strings) Intern(s string) string {
+	if s == "" {
+		return s
+	}
+
+	if is, ok := p.strings[s]; ok {
+		return is
+	}
+
+	p.strings[s] = s
+	return s
+}
+
+// InternAll interns each string of ss in place.
+func (p *Strings) InternAll(ss []string) {
+	for i, s := range ss {
+		ss[i] = p.Intern(s)
+	}
+}
+
+// Len returns the number of strings in the pool.
+func (p *Strings) Len() int {
+	return len(p.strings)
+}
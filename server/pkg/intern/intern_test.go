@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package intern
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+// data returns the address of the bytes of s.
+func data(s string) uintptr {
+	return (*reflect.StringHeader)(unsafe.Pointer(&s)).Data
+}
+
+func TestIntern(t *testing.T) {
+	p := New()
+
+	first := string([]byte("10.0.0.1"))
+	second := string([]byte("10.0.0.1"))
+	if data(first) == data(second) {
+		t.Fatal("the test strings must not share their bytes")
+	}
+
+	if is := p.Intern(first); data(is) != data(first) {
+		t.Error("the first string should be added to the pool")
+	}
+	if is := p.Intern(second); is != second || data(is) != data(first) {
+		t.Error("an equal string should return the first one")
+	}
+
+	ss := []string{string([]byte("10.0.0.1")), "10.0.0.2", ""}
+	p.InternAll(ss)
+
+	if data(ss[0]) != data(first) {
+		t.Error("InternAll should replace the strings by the ones of the pool")
+	}
+	if ss[1] != "10.0.0.2" || ss[2] != "" {
+		t.Errorf("InternAll changed the strings: %q", ss)
+	}
+
+	if p.Len() != 2 {
+		t.Errorf("expected 2 strings in the pool (the empty string is not interned), got %d", p.Len())
+	}
+}
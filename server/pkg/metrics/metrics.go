@@ -29,6 +29,78 @@ var Kpng_node_local_events = prometheus.NewCounter(prometheus.CounterOpts{
 	Help: "The total number of received events from the Kubernetes API for a given node",
 })
 
+var Kpng_invalid_objects = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "kpng_invalid_objects_total",
+	Help: "The total number of services and endpoints rejected by the validation before being sent to a node",
+}, []string{"kind"})
+
+var Kpng_backend = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "kpng_backend_info",
+	Help: "The backends run by kpng (always 1), and whether they were given explicitly, selected by to-auto, or migrated from or to by to-migrate",
+}, []string{"backend", "selection"})
+
+var Kpng_self_test_probes = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "kpng_self_test_probes_total",
+	Help: "The total number of connections to service cluster IPs made by the self-test of the node, by backend and result",
+}, []string{"backend", "result"})
+
+var Kpng_self_test_failing = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "kpng_self_test_failing_targets",
+	Help: "The number of service cluster IP ports unreachable in the last self-test of the node",
+}, []string{"backend"})
+
+var Kpng_sync_failures = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "kpng_sync_failures_total",
+	Help: "The total number of syncs reported as failed by the backend",
+}, []string{"backend"})
+
+var Kpng_sync_retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "kpng_sync_retries_total",
+	Help: "The total number of times the last state was re-delivered to the backend after a failed sync",
+}, []string{"backend"})
+
+var Kpng_backend_panics = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "kpng_backend_panics_total",
+	Help: "The total number of panics of the backend recovered by kpng, in its sink (restarting it) or in its goroutines",
+}, []string{"backend", "where"})
+
+var Kpng_rejected_services = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "kpng_rejected_services",
+	Help: "The number of services the backend refused to program at its last sync, as it can't support them",
+}, []string{"backend"})
+
+var Kpng_sync_period = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "kpng_sync_period_seconds",
+	Help: "The minimum delay between two syncs of the backend, raised under sustained changes",
+}, []string{"backend"})
+
+var Kpng_sync_change_rate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "kpng_sync_change_rate",
+	Help: "The number of changes per second synced by the backend, smoothed over the syncs",
+}, []string{"backend"})
+
+var Kpng_sync_backpressure = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "kpng_sync_backpressure_seconds_total",
+	Help: "The total time the syncs of the backend were delayed to batch the changes",
+}, []string{"backend"})
+
+var Kpng_service_programming_latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "kpng_service_programming_duration_seconds",
+	Help:    "The time from a change of a service of --latency-services received by the node to its programming by the backend",
+	Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+}, []string{"backend", "namespace", "service"})
+
+var Kpng_priority_programming_latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "kpng_priority_class_programming_duration_seconds",
+	Help:    "The time from a change of a service of the priority class received by the node to its programming by the backend, with --latency-priority-classes",
+	Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+}, []string{"backend", "priority"})
+
+var Kpng_initial_sync_progress = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "kpng_initial_sync_progress_ratio",
+	Help: "The share of the services of the initial state programmed by the backend at startup, with --initial-sync-batch or --critical-service-labels",
+}, []string{"backend"})
+
 // StartMetricsServer runs the prometheus listener so that KPNG metrics can be collected
 // TODO add TLS Auth if configured
 func StartMetricsServer(bindAddress string,
@@ -20,9 +20,12 @@ import (
 	"google.golang.org/grpc"
 
 	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/api/localv2"
+	"sigs.k8s.io/kpng/server/pkg/endpoints"
 	"sigs.k8s.io/kpng/server/proxystore"
 )
 
-func Setup(s grpc.ServiceRegistrar, store *proxystore.Store) {
-	localv1.RegisterSetsServer(s, &Server{Store: store})
+func Setup(s grpc.ServiceRegistrar, store *proxystore.Store, hostNetwork endpoints.HostNetworkMode) {
+	localv1.RegisterSetsServer(s, &Server{Store: store, HostNetworkEndpoints: hostNetwork})
+	localv2.RegisterSetsServer(s, &ServerV2{Store: store, HostNetworkEndpoints: hostNetwork})
 }
@@ -0,0 +1,164 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/api/localv2"
+	"sigs.k8s.io/kpng/server/jobs/store2localdiff"
+	"sigs.k8s.io/kpng/server/pkg/endpoints"
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+// ServerV2 serves the localv2 API, converting the localv1 diffs.
+type ServerV2 struct {
+	localv2.UnimplementedSetsServer
+
+	Store *proxystore.Store
+
+	// HostNetworkEndpoints is passed to store2localdiff.Job.
+	HostNetworkEndpoints endpoints.HostNetworkMode
+}
+
+func (s *ServerV2) Watch(res localv2.Sets_WatchServer) error {
+	remote := ""
+	{
+		ctxPeer, _ := peer.FromContext(res.Context())
+		remote = ctxPeer.Addr.String()
+	}
+
+	klog.Info("new localv2 connection from ", remote)
+	defer klog.Info("localv2 connection from ", remote, " closed")
+
+	job := &store2localdiff.Job{
+		Store:                s.Store,
+		Sink:                 &serverSinkV2{Sets_WatchServer: res, remote: remote},
+		HostNetworkEndpoints: s.HostNetworkEndpoints,
+	}
+
+	return job.Run(res.Context())
+}
+
+// GetSnapshot returns the current state of a node, as sent by Watch.
+func (s *ServerV2) GetSnapshot(ctx context.Context, req *localv2.SnapshotReq) (*localv2.Snapshot, error) {
+	snapshot := &localv2.Snapshot{
+		Capabilities: localv2.Negotiate(req.Capabilities, localv2.Supported),
+	}
+
+	synced := false
+
+	_, closed := s.Store.View(0, func(tx *proxystore.Tx) {
+		if synced = tx.AllSynced(); !synced {
+			return
+		}
+
+		withTerminating := snapshot.Capabilities.Has(localv2.Capability_WithEndpointConditions)
+		store2localdiff.EachForNode(tx, req.NodeName, s.HostNetworkEndpoints, withTerminating, func(kv *proxystore.KV, endpoints []*globalv1.EndpointInfo) {
+			if kv.Service.Service.Type == "ExternalName" {
+				return // not in localv2, as in Watch
+			}
+
+			seps := &localv2.ServiceEndpoints{
+				Service:   localv2.ServiceFromV1(kv.Service.Service),
+				Endpoints: make([]*localv2.Endpoint, 0, len(endpoints)),
+			}
+
+			for _, ei := range endpoints {
+				ep := localv2.EndpointFromV1(ei.Endpoint)
+				ep.Restrict(snapshot.Capabilities)
+				seps.Endpoints = append(seps.Endpoints, ep)
+			}
+
+			snapshot.Services = append(snapshot.Services, seps)
+		}, func(kind, key string, err error) {
+			snapshot.Rejected = append(snapshot.Rejected, &localv2.Rejection{Kind: kind, Path: key, Reason: err.Error()})
+		})
+	})
+
+	switch {
+	case closed:
+		return nil, grpc.Errorf(codes.Unavailable, "store closed")
+	case !synced:
+		return nil, grpc.Errorf(codes.Unavailable, "store not synced yet")
+	}
+
+	return snapshot, nil
+}
+
+type serverSinkV2 struct {
+	localv2.Sets_WatchServer
+	remote string
+
+	// negotiated capabilities, nil until the first request
+	negotiated *localv2.Capabilities
+}
+
+func (s *serverSinkV2) Setup() { /* noop */ }
+
+func (s *serverSinkV2) WaitRequest() (nodeName string, err error) {
+	req, err := s.Recv()
+
+	if err != nil {
+		err = grpc.Errorf(codes.Aborted, "recv error: %v", err)
+		return
+	}
+
+	if s.negotiated == nil {
+		s.negotiated = localv2.Negotiate(req.Capabilities, localv2.Supported)
+
+		klog.V(1).Info("remote ", s.remote, " requested ", req.Capabilities, ", negotiated ", s.negotiated)
+
+		err = s.Sets_WatchServer.Send(&localv2.OpItem{Op: &localv2.OpItem_Hello{Hello: s.negotiated}})
+		if err != nil {
+			return
+		}
+	}
+
+	klog.V(1).Info("remote ", s.remote, " requested node ", req.NodeName)
+
+	nodeName = req.NodeName
+	return
+}
+
+func (s *serverSinkV2) Reset() {}
+
+// WithTerminatingEndpoints returns true if the endpoint conditions were negotiated: the remote
+// receives the terminating endpoints with their conditions.
+func (s *serverSinkV2) WithTerminatingEndpoints() bool {
+	return s.negotiated.Has(localv2.Capability_WithEndpointConditions)
+}
+
+func (s *serverSinkV2) Send(op *localv1.OpItem) error {
+	v2, err := localv2.OpItemFromV1(op, s.negotiated)
+	if err != nil {
+		return grpc.Errorf(codes.Internal, "conversion to localv2 failed: %v", err)
+	}
+	if v2 == nil {
+		return nil
+	}
+
+	return s.Sets_WatchServer.Send(v2)
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"context"
+	"testing"
+
+	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/api/localv2"
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+func TestGetSnapshot(t *testing.T) {
+	store := proxystore.New()
+	srv := &ServerV2{Store: store}
+
+	store.Update(func(tx *proxystore.Tx) {
+		tx.SetService(&localv1.Service{Namespace: "default", Name: "valid", IPs: &localv1.ServiceIPs{
+			ClusterIPs: localv1.NewIPSet("10.0.0.1"),
+		}})
+		tx.SetService(&localv1.Service{Namespace: "default", Name: "invalid"})
+
+		tx.SetEndpointsOfSource("default", "valid-abcde", []*globalv1.EndpointInfo{
+			{
+				Namespace:   "default",
+				SourceName:  "valid-abcde",
+				ServiceName: "valid",
+				Conditions:  &globalv1.EndpointConditions{Ready: true},
+				Topology:    &globalv1.TopologyInfo{Node: "other"},
+				Endpoint:    &localv1.Endpoint{IPs: localv1.NewIPSet("10.1.0.1"), Weight: 3},
+			},
+		})
+	})
+
+	ctx := context.Background()
+	req := &localv2.SnapshotReq{
+		NodeName:     "node",
+		Capabilities: &localv2.Capabilities{Capabilities: []localv2.Capability{localv2.Capability_WithEndpointWeights}},
+	}
+
+	if _, err := srv.GetSnapshot(ctx, req); err == nil {
+		t.Error("expected an error before the store is synced")
+	}
+
+	store.Update(func(tx *proxystore.Tx) {
+		for _, set := range proxystore.AllSets {
+			tx.SetSync(set)
+		}
+	})
+
+	snapshot, err := srv.GetSnapshot(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !snapshot.Capabilities.Has(localv2.Capability_WithEndpointWeights) || snapshot.Capabilities.Has(localv2.Capability_WithEndpointConditions) {
+		t.Errorf("unexpected capabilities %v", snapshot.Capabilities)
+	}
+
+	if len(snapshot.Services) != 1 || snapshot.Services[0].Service.Name != "valid" {
+		t.Fatalf("expected only the valid service, got %v", snapshot.Services)
+	}
+
+	eps := snapshot.Services[0].Endpoints
+	if len(eps) != 1 || eps[0].IPs.V4[0] != "10.1.0.1" || eps[0].Weight != 3 || eps[0].Conditions != nil {
+		t.Errorf("unexpected endpoints %v", eps)
+	}
+
+	if len(snapshot.Rejected) != 1 || snapshot.Rejected[0].Path != "default/invalid" {
+		t.Errorf("expected the invalid service to be rejected, got %v", snapshot.Rejected)
+	}
+}
@@ -24,7 +24,9 @@ import (
 	"k8s.io/klog/v2"
 
 	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/client/localsink"
 	"sigs.k8s.io/kpng/server/jobs/store2localdiff"
+	"sigs.k8s.io/kpng/server/pkg/endpoints"
 	"sigs.k8s.io/kpng/server/proxystore"
 )
 
@@ -32,6 +34,9 @@ type Server struct {
 	localv1.UnimplementedSetsServer
 
 	Store *proxystore.Store
+
+	// HostNetworkEndpoints is passed to store2localdiff.Job.
+	HostNetworkEndpoints endpoints.HostNetworkMode
 }
 
 var syncItem = &localv1.OpItem{Op: &localv1.OpItem_Sync{}}
@@ -47,8 +52,9 @@ func (s *Server) Watch(res localv1.Sets_WatchServer) error {
 	defer klog.Info("connection from ", remote, " closed")
 
 	job := &store2localdiff.Job{
-		Store: s.Store,
-		Sink:  serverSink{res, remote},
+		Store:                s.Store,
+		Sink:                 &serverSink{Sets_WatchServer: res, remote: remote},
+		HostNetworkEndpoints: s.HostNetworkEndpoints,
 	}
 
 	return job.Run(res.Context())
@@ -56,12 +62,19 @@ func (s *Server) Watch(res localv1.Sets_WatchServer) error {
 
 type serverSink struct {
 	localv1.Sets_WatchServer
-	remote string
+	remote      string
+	families    []localv1.IPFamily
+	terminating bool
 }
 
-func (s serverSink) Setup() { /* noop */ }
+var (
+	_ localsink.IPFamiliesRequester           = &serverSink{}
+	_ localsink.TerminatingEndpointsRequester = &serverSink{}
+)
+
+func (s *serverSink) Setup() { /* noop */ }
 
-func (s serverSink) WaitRequest() (nodeName string, err error) {
+func (s *serverSink) WaitRequest() (nodeName string, err error) {
 	req, err := s.Recv()
 
 	if err != nil {
@@ -72,7 +85,15 @@ func (s serverSink) WaitRequest() (nodeName string, err error) {
 	klog.V(1).Info("remote ", s.remote, " requested node ", req.NodeName)
 
 	nodeName = req.NodeName
+	s.families = req.IPFamilies
+	s.terminating = req.WithTerminatingEndpoints
 	return
 }
 
-func (s serverSink) Reset() {}
+// IPFamilies returns the IP families requested by the remote.
+func (s *serverSink) IPFamilies() []localv1.IPFamily { return s.families }
+
+// WithTerminatingEndpoints returns true if the remote requested the terminating endpoints.
+func (s *serverSink) WithTerminatingEndpoints() bool { return s.terminating }
+
+func (s *serverSink) Reset() {}
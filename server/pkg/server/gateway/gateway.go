@@ -0,0 +1,75 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gateway serves the read APIs of kpng (the global state and the state of a node) as
+// REST+JSON, with their OpenAPI document, for dashboards and scripts without protobuf tooling.
+//
+// The handlers in globalv1 and localv2 and openapi.json are generated from the API protos with
+// the HTTP mapping of gateway.yaml:
+//
+//	protoc --grpc-gateway_out=. --grpc-gateway_opt=paths=source_relative,standalone=true,grpc_api_configuration=gateway.yaml \
+//	  --openapiv2_out=. --openapiv2_opt=allow_merge=true,merge_file_name=gateway,grpc_api_configuration=gateway.yaml \
+//	  api/globalv1/api.proto api/localv2/api.proto
+//
+// (then moved here, the OpenAPI document being renamed openapi.json).
+package gateway
+
+import (
+	"context"
+	_ "embed"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+
+	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/api/localv2"
+	globalv1gw "sigs.k8s.io/kpng/server/pkg/server/gateway/globalv1"
+	localv2gw "sigs.k8s.io/kpng/server/pkg/server/gateway/localv2"
+)
+
+//go:embed openapi.json
+var openAPI []byte
+
+// OpenAPIPath is the path of the OpenAPI document of the gateway.
+const OpenAPIPath = "/openapi.json"
+
+// Handler returns the gateway of the given API servers, called in-process. A nil server is not
+// served.
+func Handler(ctx context.Context, global globalv1.SetsServer, local localv2.SetsServer) (http.Handler, error) {
+	mux := runtime.NewServeMux()
+
+	if global != nil {
+		if err := globalv1gw.RegisterSetsHandlerServer(ctx, mux, global); err != nil {
+			return nil, err
+		}
+	}
+
+	if local != nil {
+		if err := localv2gw.RegisterSetsHandlerServer(ctx, mux, local); err != nil {
+			return nil, err
+		}
+	}
+
+	err := mux.HandlePath(http.MethodGet, OpenAPIPath, func(w http.ResponseWriter, _ *http.Request, _ map[string]string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPI)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mux, nil
+}
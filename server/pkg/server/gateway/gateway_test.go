@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/server/pkg/server/endpoints"
+	"sigs.k8s.io/kpng/server/pkg/server/global"
+	"sigs.k8s.io/kpng/server/proxystore"
+)
+
+func TestHandler(t *testing.T) {
+	store := proxystore.New()
+
+	store.Update(func(tx *proxystore.Tx) {
+		for _, ns := range []string{"default", "other"} {
+			tx.SetService(&localv1.Service{Namespace: ns, Name: "web", Type: "ClusterIP", IPs: &localv1.ServiceIPs{
+				ClusterIPs: localv1.NewIPSet("10.0.0.1"),
+			}})
+		}
+		tx.SetNode(&globalv1.Node{Name: "node-a"})
+
+		for _, set := range proxystore.AllSets {
+			tx.SetSync(set)
+		}
+	})
+
+	handler, err := Handler(context.Background(), &global.Server{Store: store}, &endpoints.ServerV2{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	get := func(path string, v interface{}) {
+		t.Helper()
+
+		res, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		body, _ := io.ReadAll(res.Body)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: %s: %s", path, res.Status, body)
+		}
+		if err := json.Unmarshal(body, v); err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+	}
+
+	state := struct {
+		Services []struct {
+			Service struct{ Namespace, Name string }
+		}
+		Nodes []struct {
+			Node struct{ Name string }
+		}
+	}{}
+	get("/v1/global/state?Namespace=other", &state)
+
+	if len(state.Services) != 1 || state.Services[0].Service.Namespace != "other" {
+		t.Errorf("expected the service of the other namespace, got %+v", state.Services)
+	}
+	if len(state.Nodes) != 1 || state.Nodes[0].Node.Name != "node-a" {
+		t.Errorf("expected node-a, got %+v", state.Nodes)
+	}
+
+	snapshot := struct {
+		Services []struct {
+			Service struct{ Name string }
+		}
+	}{}
+	get("/v2/nodes/node-a/state", &snapshot)
+
+	if len(snapshot.Services) != 2 {
+		t.Errorf("expected the 2 services, got %+v", snapshot.Services)
+	}
+
+	openAPI := struct {
+		Paths map[string]interface{}
+	}{}
+	get(OpenAPIPath, &openAPI)
+
+	for _, path := range []string{"/v1/global/state", "/v2/nodes/{NodeName}/state"} {
+		if _, ok := openAPI.Paths[path]; !ok {
+			t.Errorf("expected %s in the OpenAPI document", path)
+		}
+	}
+}
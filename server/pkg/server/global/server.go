@@ -17,8 +17,13 @@ limitations under the License.
 package global
 
 import (
-	"sigs.k8s.io/kpng/api/localv1"
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
 	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/api/localv1"
 	"sigs.k8s.io/kpng/server/jobs/store2globaldiff"
 	"sigs.k8s.io/kpng/server/proxystore"
 )
@@ -42,6 +47,52 @@ func (s *Server) Watch(res globalv1.Sets_WatchServer) error {
 	return job.Run(res.Context())
 }
 
+// GetState returns the current global state, restricted to a namespace if requested (but for the
+// nodes).
+func (s *Server) GetState(ctx context.Context, req *globalv1.GlobalStateReq) (*globalv1.GlobalState, error) {
+	state := &globalv1.GlobalState{}
+
+	synced := false
+
+	_, closed := s.Store.View(0, func(tx *proxystore.Tx) {
+		if synced = tx.AllSynced(); !synced {
+			return
+		}
+
+		inNamespace := func(kv *proxystore.KV) bool {
+			return req.Namespace == "" || kv.Namespace == req.Namespace
+		}
+
+		tx.Each(proxystore.Services, func(kv *proxystore.KV) bool {
+			if inNamespace(kv) {
+				state.Services = append(state.Services, kv.Service)
+			}
+			return true
+		})
+
+		tx.Each(proxystore.Endpoints, func(kv *proxystore.KV) bool {
+			if inNamespace(kv) {
+				state.Endpoints = append(state.Endpoints, kv.Endpoint)
+			}
+			return true
+		})
+
+		tx.Each(proxystore.Nodes, func(kv *proxystore.KV) bool {
+			state.Nodes = append(state.Nodes, kv.Node)
+			return true
+		})
+	})
+
+	switch {
+	case closed:
+		return nil, grpc.Errorf(codes.Unavailable, "store closed")
+	case !synced:
+		return nil, grpc.Errorf(codes.Unavailable, "store not synced yet")
+	}
+
+	return state, nil
+}
+
 type resWrap struct {
 	globalv1.Sets_WatchServer
 }
@@ -33,6 +33,17 @@ func MustListen(bindSpec string) net.Listener {
 
 	protocol, addr := parts[0], parts[1]
 
+	// socket passed by systemd, optionally selected by name (ie: systemd://kpng-api)
+	if protocol == "systemd" {
+		lis, err := activationListener(addr)
+		if err != nil {
+			klog.Error("failed to listen on ", bindSpec, ": ", err)
+			os.Exit(1)
+		}
+		klog.Info("listening on ", bindSpec, " (", lis.Addr(), ")")
+		return lis
+	}
+
 	// handle protocol specifics
 	afterListen := osPrepareListen(protocol, addr)
 
@@ -0,0 +1,95 @@
+//go:build !windows
+// +build !windows
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd (SD_LISTEN_FDS_START).
+const listenFdsStart = 3
+
+// activationFd returns the file descriptor of the socket passed by systemd with the given name
+// (FileDescriptorName= of the socket unit), or the first one if name is empty.
+func activationFd(name string) (int, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return 0, fmt.Errorf("no socket passed by systemd")
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return 0, fmt.Errorf("no socket passed by systemd")
+	}
+
+	if name == "" {
+		return listenFdsStart, nil
+	}
+
+	for i, fdName := range strings.Split(os.Getenv("LISTEN_FDNAMES"), ":") {
+		if i < count && fdName == name {
+			return listenFdsStart + i, nil
+		}
+	}
+	return 0, fmt.Errorf("no socket named %q passed by systemd", name)
+}
+
+// activationListener returns the listener passed by systemd (socket activation).
+func activationListener(name string) (net.Listener, error) {
+	fd, err := activationFd(name)
+	if err != nil {
+		return nil, err
+	}
+
+	syscall.CloseOnExec(fd)
+
+	f := os.NewFile(uintptr(fd), "systemd:"+name)
+	defer f.Close()
+
+	return net.FileListener(f)
+}
+
+// Notify sends a state (ie: READY=1) to systemd. It does nothing when not started by systemd
+// with Type=notify.
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	// abstract socket
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
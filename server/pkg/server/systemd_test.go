@@ -0,0 +1,80 @@
+//go:build !windows
+// +build !windows
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestActivationFd(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "2")
+	t.Setenv("LISTEN_FDNAMES", "kpng-api:kpng-other")
+
+	for name, expected := range map[string]int{"": 3, "kpng-api": 3, "kpng-other": 4} {
+		if fd, err := activationFd(name); err != nil || fd != expected {
+			t.Errorf("%q: expected fd %d, got %d (%v)", name, expected, fd, err)
+		}
+	}
+
+	if _, err := activationFd("unknown"); err == nil {
+		t.Error("expected an error for an unknown socket")
+	}
+
+	// passed to another process
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	if _, err := activationFd(""); err == nil {
+		t.Error("expected an error for sockets of another process")
+	}
+}
+
+func TestNotify(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", socket)
+
+	if err := Notify("READY=1"); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "READY=1" {
+		t.Errorf("unexpected state: %q", buf[:n])
+	}
+
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := Notify("READY=1"); err != nil {
+		t.Errorf("notify without systemd should be a no-op, got %v", err)
+	}
+}
@@ -170,6 +170,13 @@ func (w *WatchState) SendSync() {
 	w.send(syncItem)
 }
 
+var initialSyncItem = &localv1.OpItem{Op: &localv1.OpItem_InitialSync{}}
+
+// SendInitialSync marks the next sync as the one of the first complete state.
+func (w *WatchState) SendInitialSync() {
+	w.send(initialSyncItem)
+}
+
 var resetItem = &localv1.OpItem{Op: &localv1.OpItem_Reset_{}}
 
 func (w *WatchState) SendReset() {
@@ -17,6 +17,7 @@ limitations under the License.
 package proxystore
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"sync"
@@ -26,6 +27,7 @@ import (
 
 	"sigs.k8s.io/kpng/api/globalv1"
 	"sigs.k8s.io/kpng/api/localv1"
+	"sigs.k8s.io/kpng/server/pkg/intern"
 	"sigs.k8s.io/kpng/server/pkg/metrics"
 	"sigs.k8s.io/kpng/server/serde"
 )
@@ -39,6 +41,17 @@ type Store struct {
 
 	// set sync info
 	sync map[Set]bool
+
+	// restored holds the paths of the entries restored from a previous run and not set since,
+	// by set
+	restored map[Set]map[string]bool
+
+	// strings interns the strings of the endpoints. It's compacted when deletedEndpoints, the
+	// endpoint entries deleted since the last compaction, reaches compactedEndpoints, the number
+	// of endpoint entries then.
+	strings            *intern.Strings
+	compactedEndpoints int
+	deletedEndpoints   int
 }
 
 type Set = localv1.Set
@@ -51,15 +64,21 @@ const (
 
 var AllSets = []Set{Services, Endpoints, Nodes}
 
+// treeDegree is the degree of the store's btree: small nodes cost more memory than the entries
+// of the endpoints on big clusters.
+const treeDegree = 32
+
 type Hashed interface {
 	GetHash() uint64
 }
 
 func New() *Store {
 	return &Store{
-		c:    sync.NewCond(&sync.Mutex{}),
-		tree: btree.New(2),
-		sync: map[Set]bool{},
+		c:        sync.NewCond(&sync.Mutex{}),
+		tree:     btree.New(treeDegree),
+		sync:     map[Set]bool{},
+		restored: map[Set]map[string]bool{},
+		strings:  intern.New(),
 	}
 }
 
@@ -83,6 +102,8 @@ func (s *Store) Update(update func(tx *Tx)) {
 		return // nothing changed
 	}
 
+	s.compactStrings()
+
 	// TODO check if the update really updated something
 	s.c.L.Lock()
 	s.rev++
@@ -102,12 +123,37 @@ func (s *Store) Update(update func(tx *Tx)) {
 }
 
 func (s *Store) View(afterRev uint64, view func(tx *Tx)) (rev uint64, closed bool) {
+	return s.ViewContext(context.Background(), afterRev, view)
+}
+
+// ViewContext is View, but stops waiting for a revision after afterRev when ctx is done, returning
+// closed as if the store was closed.
+func (s *Store) ViewContext(ctx context.Context, afterRev uint64, view func(tx *Tx)) (rev uint64, closed bool) {
+	if done := ctx.Done(); done != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+
+		go func() {
+			select {
+			case <-done:
+				s.c.L.Lock()
+				s.c.Broadcast()
+				s.c.L.Unlock()
+			case <-stop:
+			}
+		}()
+	}
+
 	s.c.L.Lock()
-	for s.rev <= afterRev && !s.closed {
+	for s.rev <= afterRev && !s.closed && ctx.Err() == nil {
 		s.c.Wait()
 	}
 	s.c.L.Unlock()
 
+	if ctx.Err() != nil {
+		return afterRev, true
+	}
+
 	s.RLock()
 	defer s.RUnlock()
 
@@ -160,25 +206,39 @@ func (tx *Tx) Reset() {
 			tx.changes++
 		}
 	}
+
+	tx.s.restored = map[Set]map[string]bool{}
 }
 
 func (tx *Tx) set(kv *KV) {
 	tx.roPanic()
+	tx.refreshed(kv)
+
 	prev := tx.s.tree.Get(kv)
 
 	if prev != nil && prev.(*KV).Value.GetHash() == kv.Value.GetHash() {
 		return // not changed
 	}
 
+	if kv.Endpoint != nil {
+		tx.s.internEndpoint(kv.Endpoint)
+	}
+
 	tx.s.tree.ReplaceOrInsert(kv)
 	tx.changes++
 }
 
 func (tx *Tx) del(kv *KV) {
 	tx.roPanic()
+	tx.refreshed(kv)
+
 	i := tx.s.tree.Delete(kv)
 	if i != nil {
 		tx.changes++
+
+		if kv.Set == Endpoints {
+			tx.s.deletedEndpoints++
+		}
 	}
 }
 
@@ -207,6 +267,30 @@ func (tx *Tx) SetRaw(set Set, path string, value Hashed) {
 	}
 }
 
+// Restore sets an entry restored from a previous run, and marks its set as synced: the restored
+// state is served until the source of the store syncs. The restored entries that are not set
+// again by then are deleted by the next SetSync of their set.
+func (tx *Tx) Restore(set Set, path string, value Hashed) {
+	tx.SetRaw(set, path, value)
+
+	if tx.s.restored[set] == nil {
+		tx.s.restored[set] = map[string]bool{}
+	}
+	tx.s.restored[set][path] = true
+
+	if !tx.s.sync[set] {
+		tx.s.sync[set] = true
+		tx.changes++
+	}
+}
+
+// refreshed records that an entry was set or deleted since it was restored.
+func (tx *Tx) refreshed(kv *KV) {
+	if restored := tx.s.restored[kv.Set]; len(restored) != 0 {
+		delete(restored, kv.Path())
+	}
+}
+
 func (tx *Tx) DelRaw(set Set, path string) {
 	kv := &KV{}
 	kv.Set = set
@@ -224,12 +308,32 @@ func (tx *Tx) AllSynced() bool {
 	}
 	return true
 }
+
+// SourceSynced returns true when the source of the store synced every set, the sets synced only
+// by a restored state not counting.
+func (tx *Tx) SourceSynced() bool {
+	return tx.AllSynced() && len(tx.s.restored) == 0
+}
+
 func (tx *Tx) IsSynced(set Set) bool {
 	return tx.s.sync[set]
 }
 func (tx *Tx) SetSync(set Set) {
 	tx.roPanic()
 
+	// the entries of a previous run that the source didn't set again are stale
+	if restored := tx.s.restored[set]; restored != nil {
+		delete(tx.s.restored, set)
+		tx.changes++ // the set is now synced by the source (see SourceSynced)
+
+		for path := range restored {
+			tx.DelRaw(set, path)
+		}
+		if len(restored) != 0 {
+			klog.Info("deleted ", len(restored), " stale restored entries of ", set)
+		}
+	}
+
 	if !tx.s.sync[set] {
 		tx.s.sync[set] = true
 		tx.changes++
@@ -238,6 +342,16 @@ func (tx *Tx) SetSync(set Set) {
 
 // Services funcs
 
+func (tx *Tx) GetService(namespace, name string) *localv1.Service {
+	i := tx.s.tree.Get(&KV{Set: Services, Namespace: namespace, Name: name})
+
+	if i == nil {
+		return nil
+	}
+
+	return i.(*KV).Service.Service
+}
+
 func (tx *Tx) SetService(s *localv1.Service) {
 	si := &globalv1.ServiceInfo{
 		Service: s,
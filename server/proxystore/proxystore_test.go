@@ -17,9 +17,14 @@ limitations under the License.
 package proxystore
 
 import (
+	"context"
 	"fmt"
+	"reflect"
+	"runtime"
 	"sort"
 	"testing"
+	"time"
+	"unsafe"
 
 	"sigs.k8s.io/kpng/api/localv1"
 	"sigs.k8s.io/kpng/api/globalv1"
@@ -121,3 +126,154 @@ func TestSessionAffinitySetClientIP(t *testing.T) {
 		})
 	})
 }
+
+// decoded returns a copy of s, like the strings decoded from each object received.
+func decoded(s string) string {
+	return string([]byte(s))
+}
+
+// benchEndpoints returns the endpoint slices of 100 services of 100 pods, each pod being the
+// endpoint of 3 services, decoded like kube2store does.
+func benchEndpoints(update int) (slices [][]*globalv1.EndpointInfo) {
+	for svc := 0; svc < 100; svc++ {
+		namespace := decoded("default")
+		sourceName := decoded(fmt.Sprint("svc-", svc, "-abcde"))
+		serviceName := decoded(fmt.Sprint("svc-", svc))
+		ports := []*localv1.PortName{{Name: decoded("http"), Port: 8080}}
+
+		infos := make([]*globalv1.EndpointInfo, 0, 100)
+		for pod := 0; pod < 100; pod++ {
+			podN := svc/3*100 + pod
+			infos = append(infos, &globalv1.EndpointInfo{
+				Namespace:   namespace,
+				SourceName:  sourceName,
+				ServiceName: serviceName,
+				PodName:     decoded(fmt.Sprint("pod-", podN)),
+				Endpoint: &localv1.Endpoint{
+					Hostname:      decoded(fmt.Sprint("pod-", podN)),
+					IPs:           &localv1.IPSet{V4: []string{decoded(fmt.Sprintf("10.%d.%d.%d", podN>>16, podN>>8&0xff, podN&0xff))}},
+					PortOverrides: ports,
+					// the last pods are updated each time
+					Weight: int32(update * (pod / 90)),
+				},
+				Conditions: &globalv1.EndpointConditions{Ready: true},
+				Topology: &globalv1.TopologyInfo{
+					Node: decoded(fmt.Sprint("ip-10-0-", podN%100, "-", podN%7, ".eu-west-1.compute.internal")),
+					Zone: decoded(fmt.Sprint("eu-west-1", string(rune('a'+podN%3)))),
+				},
+			})
+		}
+		slices = append(slices, infos)
+	}
+	return
+}
+
+// BenchmarkSetEndpointsOfSource reports the heap used by 10000 endpoints, set with 10 updates of
+// 10% of them.
+func BenchmarkSetEndpointsOfSource(b *testing.B) {
+	var mem runtime.MemStats
+
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+		runtime.ReadMemStats(&mem)
+		before := mem.HeapAlloc
+
+		s := New()
+		for update := 0; update < 10; update++ {
+			slices := benchEndpoints(update)
+
+			s.Update(func(tx *Tx) {
+				for _, infos := range slices {
+					tx.SetEndpointsOfSource(infos[0].Namespace, infos[0].SourceName, infos)
+				}
+			})
+		}
+
+		runtime.GC()
+		runtime.ReadMemStats(&mem)
+		b.ReportMetric(float64(mem.HeapAlloc-before)/10000, "heap-B/endpoint")
+		runtime.KeepAlive(s)
+	}
+}
+
+func TestEndpointStringsInterned(t *testing.T) {
+	s := New()
+
+	endpoint := func(source, ip, node string) *globalv1.EndpointInfo {
+		return &globalv1.EndpointInfo{
+			Namespace:   decoded("default"),
+			SourceName:  source,
+			ServiceName: "svc0",
+			Endpoint:    &localv1.Endpoint{IPs: localv1.NewIPSet(ip)},
+			Conditions:  &globalv1.EndpointConditions{Ready: true},
+			Topology:    &globalv1.TopologyInfo{Node: decoded(node)},
+		}
+	}
+
+	s.Update(func(tx *Tx) {
+		tx.SetEndpointsOfSource("default", "svc0-a", []*globalv1.EndpointInfo{endpoint("svc0-a", "10.0.0.1", "node-1")})
+		tx.SetEndpointsOfSource("default", "svc0-b", []*globalv1.EndpointInfo{endpoint("svc0-b", "10.0.0.2", "node-1")})
+	})
+
+	data := func(s string) uintptr {
+		return (*reflect.StringHeader)(unsafe.Pointer(&s)).Data
+	}
+
+	nodes := map[uintptr]bool{}
+	s.View(0, func(tx *Tx) {
+		tx.EachEndpointOfService("default", "svc0", func(ei *globalv1.EndpointInfo) {
+			nodes[data(ei.Topology.Node)] = true
+		})
+	})
+
+	if len(nodes) != 1 {
+		t.Errorf("the endpoints should share their node name, got %d copies", len(nodes))
+	}
+
+	// the strings of the removed endpoints are dropped once enough endpoints were deleted
+	s.Update(func(tx *Tx) {
+		for i := 0; i < minStringsToCompact; i++ {
+			source := fmt.Sprint("svc1-", i)
+			tx.SetEndpointsOfSource("default", source, []*globalv1.EndpointInfo{endpoint(source, "10.0.1.1", fmt.Sprint("node-", i))})
+		}
+	})
+	s.Update(func(tx *Tx) {
+		for i := 0; i < minStringsToCompact; i++ {
+			tx.DelEndpointsOfSource("default", fmt.Sprint("svc1-", i))
+		}
+	})
+
+	// default, svc0, svc0-a, svc0-b and node-1
+	if n := s.strings.Len(); n != 5 {
+		t.Errorf("expected 5 strings in the pool after the compaction, got %d", n)
+	}
+}
+
+func TestViewContext(t *testing.T) {
+	s := New()
+	s.Update(func(tx *Tx) { tx.SetService(&localv1.Service{Namespace: "ns", Name: "svc"}) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	rev, closed := s.ViewContext(ctx, 0, func(*Tx) {})
+	if rev != 1 || closed {
+		t.Fatalf("expected the revision 1, got %d (closed: %v)", rev, closed)
+	}
+
+	done := make(chan bool)
+	go func() {
+		_, closed := s.ViewContext(ctx, rev, func(*Tx) { t.Error("unexpected view") })
+		done <- closed
+	}()
+
+	cancel()
+
+	select {
+	case closed := <-done:
+		if !closed {
+			t.Error("expected the view to report the store as closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the view should stop waiting when the context is done")
+	}
+}
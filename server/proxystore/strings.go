@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxystore
+
+import (
+	"github.com/google/btree"
+
+	"sigs.k8s.io/kpng/api/globalv1"
+	"sigs.k8s.io/kpng/server/pkg/intern"
+)
+
+// minStringsToCompact is the size of the strings pool below which it's never compacted.
+const minStringsToCompact = 1024
+
+// endpointStrings calls f with the strings of ei repeated across the endpoints (namespaces, names,
+// nodes, zones...). The IPs and pod names, mostly unique, would cost more in the pool than they
+// save.
+func endpointStrings(ei *globalv1.EndpointInfo, f func(s *string)) {
+	f(&ei.Namespace)
+	f(&ei.SourceName)
+	f(&ei.ServiceName)
+
+	if ep := ei.Endpoint; ep != nil {
+		for _, port := range ep.PortOverrides {
+			f(&port.Name)
+		}
+	}
+
+	if topo := ei.Topology; topo != nil {
+		f(&topo.Node)
+		f(&topo.Zone)
+	}
+
+	if hints := ei.Hints; hints != nil {
+		for i := range hints.Zones {
+			f(&hints.Zones[i])
+		}
+	}
+}
+
+// internEndpoint replaces the strings of an endpoint entering the store by their copy in the pool.
+func (s *Store) internEndpoint(ei *globalv1.EndpointInfo) {
+	endpointStrings(ei, func(str *string) {
+		*str = s.strings.Intern(*str)
+	})
+
+	// the hostname of a pod is usually its name
+	if ep := ei.Endpoint; ep != nil && ep.Hostname == ei.PodName {
+		ep.Hostname = ei.PodName
+	}
+}
+
+// compactStrings drops the strings of the pool no longer used by the endpoints of the store, once
+// as many endpoints were deleted as there were at the last compaction.
+func (s *Store) compactStrings() {
+	if s.strings.Len() < minStringsToCompact || s.deletedEndpoints == 0 || s.deletedEndpoints < s.compactedEndpoints {
+		return
+	}
+
+	// the strings of the endpoints are already interned: only read them, as the endpoints may
+	// be read outside of the store's lock.
+	strings := intern.New()
+	endpoints := 0
+
+	s.tree.AscendGreaterOrEqual(&KV{Set: Endpoints}, func(i btree.Item) bool {
+		kv := i.(*KV)
+		if kv.Set != Endpoints {
+			return false
+		}
+
+		endpointStrings(kv.Endpoint, func(str *string) {
+			strings.Intern(*str)
+		})
+		endpoints++
+		return true
+	})
+
+	s.strings = strings
+	s.compactedEndpoints = endpoints
+	s.deletedEndpoints = 0
+}